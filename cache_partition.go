@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"regexp"
+	"strings"
+)
+
+const cachePartitionPrefix = "partition/"
+
+var cachePartitionTagPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,63}$`)
+
+// ValidateCachePartitionTag returns ErrInvalidCachePartitionTag unless tag is
+// empty or a valid partition tag.
+func ValidateCachePartitionTag(tag string) error {
+	if tag == "" || cachePartitionTagPattern.MatchString(tag) {
+		return nil
+	}
+	return ErrInvalidCachePartitionTag
+}
+
+// PartitionedCacheKey namespaces a download cache key by a partition tag so
+// that containers with different tags never share cache entries. Empty keys
+// are left alone, as they disable caching.
+func PartitionedCacheKey(tag, cacheKey string) string {
+	if tag == "" || cacheKey == "" {
+		return cacheKey
+	}
+	return cachePartitionPrefix + tag + "/" + cacheKey
+}
+
+// CachePartitionTagOf returns the partition tag of a cache key made by
+// PartitionedCacheKey, or an empty string for keys outside any partition.
+func CachePartitionTagOf(cacheKey string) string {
+	if !strings.HasPrefix(cacheKey, cachePartitionPrefix) {
+		return ""
+	}
+
+	rest := strings.TrimPrefix(cacheKey, cachePartitionPrefix)
+	i := strings.Index(rest, "/")
+	if i <= 0 {
+		return ""
+	}
+	return rest[:i]
+}
+
+// CachePartitionStats describes the use of the download cache by the
+// containers of one cache partition.
+type CachePartitionStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Entries   int    `json:"entries"`
+	SizeBytes int64  `json:"size_bytes"`
+}
@@ -0,0 +1,59 @@
+package executor_test
+
+import (
+	"strings"
+
+	"code.cloudfoundry.org/executor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Cache partitions", func() {
+	Describe("ValidateCachePartitionTag", func() {
+		It("accepts an empty tag", func() {
+			Expect(executor.ValidateCachePartitionTag("")).To(Succeed())
+		})
+
+		It("accepts letters, digits, dashes and underscores", func() {
+			Expect(executor.ValidateCachePartitionTag("tenant-A_01")).To(Succeed())
+		})
+
+		It("rejects other characters", func() {
+			Expect(executor.ValidateCachePartitionTag("../tenant")).To(Equal(executor.ErrInvalidCachePartitionTag))
+			Expect(executor.ValidateCachePartitionTag("tenant a")).To(Equal(executor.ErrInvalidCachePartitionTag))
+		})
+
+		It("rejects tags longer than 63 characters", func() {
+			Expect(executor.ValidateCachePartitionTag(strings.Repeat("a", 63))).To(Succeed())
+			Expect(executor.ValidateCachePartitionTag(strings.Repeat("a", 64))).To(Equal(executor.ErrInvalidCachePartitionTag))
+		})
+	})
+
+	Describe("PartitionedCacheKey", func() {
+		It("gives each tag its own key for the same cache key", func() {
+			keyA := executor.PartitionedCacheKey("tenant-a", "droplet")
+			keyB := executor.PartitionedCacheKey("tenant-b", "droplet")
+			Expect(keyA).NotTo(Equal(keyB))
+			Expect(executor.PartitionedCacheKey("tenant-a", "droplet")).To(Equal(keyA))
+		})
+
+		It("leaves the key alone without a tag", func() {
+			Expect(executor.PartitionedCacheKey("", "droplet")).To(Equal("droplet"))
+		})
+
+		It("leaves empty keys empty so that they stay uncached", func() {
+			Expect(executor.PartitionedCacheKey("tenant-a", "")).To(BeEmpty())
+		})
+	})
+
+	Describe("CachePartitionTagOf", func() {
+		It("returns the tag of a partitioned cache key", func() {
+			Expect(executor.CachePartitionTagOf(executor.PartitionedCacheKey("tenant-a", "droplet"))).To(Equal("tenant-a"))
+		})
+
+		It("returns an empty tag for other keys", func() {
+			Expect(executor.CachePartitionTagOf("droplet")).To(BeEmpty())
+			Expect(executor.CachePartitionTagOf("partition/")).To(BeEmpty())
+		})
+	})
+})
@@ -16,10 +16,16 @@ type Client interface {
 	StopContainer(logger lager.Logger, guid string) error
 	DeleteContainer(logger lager.Logger, guid string) error
 	ListContainers(lager.Logger) ([]Container, error)
+	Import(logger lager.Logger, snapshot []Container) error
 	GetBulkMetrics(lager.Logger) (map[string]Metrics, error)
 	RemainingResources(lager.Logger) (ExecutorResources, error)
+	RemainingCapacity(lager.Logger) (ExecutorResources, error)
 	TotalResources(lager.Logger) (ExecutorResources, error)
 	GetFiles(logger lager.Logger, guid string, path string) (io.ReadCloser, error)
+	GetFileRange(logger lager.Logger, guid string, path string, byteRange ByteRange) (PartialFile, error)
+	TailFile(logger lager.Logger, guid string, path string, n int64) (io.ReadCloser, error)
+	TagContainer(logger lager.Logger, guid string, tags Tags) error
+	UpdateLogConfig(logger lager.Logger, guid string, logConfig LogConfig) error
 	VolumeDrivers(logger lager.Logger) ([]string, error)
 	SubscribeToEvents(lager.Logger) (EventSource, error)
 	Healthy(lager.Logger) bool
@@ -52,6 +58,12 @@ func (a *AllocationRequest) Validate() error {
 	if a.Guid == "" {
 		return ErrGuidNotSpecified
 	}
+	if a.MemoryReservationMB < 0 || a.MemoryReservationMB > a.MemoryMB {
+		return ErrLimitsInvalid
+	}
+	if a.DiskMB < DiskUnlimited {
+		return ErrLimitsInvalid
+	}
 	return nil
 }
 
@@ -14,4 +14,23 @@ var _ = Describe("Allocation Request", func() {
 		Expect(err).To(HaveOccurred())
 		Expect(err).To(MatchError(ErrGuidNotSpecified))
 	})
+
+	It("is invalid when the memory reservation exceeds the memory limit", func() {
+		allocationInfo := NewResource(20, 30, 1024)
+		allocationInfo.MemoryReservationMB = 21
+		allocRequest := NewAllocationRequest("some-guid", &allocationInfo, nil)
+		Expect(allocRequest.Validate()).To(MatchError(ErrLimitsInvalid))
+
+		allocRequest.MemoryReservationMB = 20
+		Expect(allocRequest.Validate()).To(Succeed())
+	})
+
+	It("is invalid when the disk limit is below DiskUnlimited", func() {
+		allocationInfo := NewResource(20, DiskUnlimited-1, 1024)
+		allocRequest := NewAllocationRequest("some-guid", &allocationInfo, nil)
+		Expect(allocRequest.Validate()).To(MatchError(ErrLimitsInvalid))
+
+		allocRequest.DiskMB = DiskUnlimited
+		Expect(allocRequest.Validate()).To(Succeed())
+	})
 })
@@ -0,0 +1,37 @@
+package executor
+
+import "strings"
+
+// NetworkPropertyPrefix prefixes the garden properties the executor sets from
+// a container's network properties.
+const NetworkPropertyPrefix = "network."
+
+// GardenPropertyPrefix prefixes the properties garden itself interprets, such
+// as garden.grace-time.
+const GardenPropertyPrefix = "garden."
+
+// ReservedPropertyPrefixes are the prefixes of garden properties the executor
+// or garden sets itself. Container tags may not use them.
+var ReservedPropertyPrefixes = []string{"executor:", NetworkPropertyPrefix, GardenPropertyPrefix}
+
+// IsReservedProperty reports whether key starts with a reserved property
+// prefix.
+func IsReservedProperty(key string) bool {
+	for _, prefix := range ReservedPropertyPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateContainerTags returns ErrReservedContainerTag if any tag key starts
+// with a reserved property prefix.
+func ValidateContainerTags(tags Tags) error {
+	for key := range tags {
+		if IsReservedProperty(key) {
+			return ErrReservedContainerTag
+		}
+	}
+	return nil
+}
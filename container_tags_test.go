@@ -0,0 +1,19 @@
+package executor_test
+
+import (
+	"code.cloudfoundry.org/executor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ValidateContainerTags", func() {
+	It("accepts tags without a reserved prefix", func() {
+		Expect(executor.ValidateContainerTags(executor.Tags{"pipeline": "deploy", "version": "1.2.3"})).To(Succeed())
+	})
+
+	It("rejects tags that would clash with executor properties", func() {
+		Expect(executor.ValidateContainerTags(executor.Tags{executor.ContainerOwnerProperty: "someone"})).To(Equal(executor.ErrReservedContainerTag))
+		Expect(executor.ValidateContainerTags(executor.Tags{"network.app_id": "some-app"})).To(Equal(executor.ErrReservedContainerTag))
+		Expect(executor.ValidateContainerTags(executor.Tags{"garden.grace-time": "0"})).To(Equal(executor.ErrReservedContainerTag))
+	})
+})
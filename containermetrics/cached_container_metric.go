@@ -7,4 +7,8 @@ type CachedContainerMetrics struct {
 	DiskQuotaBytes   uint64  `json:"disk_quota_bytes"`
 	MemoryUsageBytes uint64  `json:"memory_usage_bytes"`
 	MemoryQuotaBytes uint64  `json:"memory_quota_bytes"`
+
+	// InodeUsageFraction is zero when garden does not report inode usage or
+	// the container has no inode limit.
+	InodeUsageFraction float64 `json:"inode_usage_fraction,omitempty"`
 }
@@ -0,0 +1,186 @@
+package containermetrics
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	"code.cloudfoundry.org/executor"
+	loggregator "code.cloudfoundry.org/go-loggregator"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	PortReachableMetric         = "PortReachable"
+	UnreachableContainersMetric = "UnreachableContainers"
+
+	portProbeTimeout = time.Second
+)
+
+//go:generate counterfeiter -o containermetricsfakes/fake_dialer.go . Dialer
+
+type Dialer interface {
+	DialTimeout(network, address string, timeout time.Duration) (net.Conn, error)
+}
+
+type netDialer struct{}
+
+func (netDialer) DialTimeout(network, address string, timeout time.Duration) (net.Conn, error) {
+	return net.DialTimeout(network, address, timeout)
+}
+
+var DefaultDialer Dialer = netDialer{}
+
+// PortProber periodically attempts a TCP connection to the first mapped port
+// of every running container and emits whether the port was reachable. The
+// probes of a round are spread evenly over the interval so that connections
+// are not made to every container at once.
+type PortProber struct {
+	logger lager.Logger
+
+	interval       time.Duration
+	clock          clock.Clock
+	dialer         Dialer
+	executorClient executor.Client
+	metronClient   loggingclient.IngressClient
+}
+
+func NewPortProber(
+	logger lager.Logger,
+	interval time.Duration,
+	clock clock.Clock,
+	dialer Dialer,
+	executorClient executor.Client,
+	metronClient loggingclient.IngressClient,
+) *PortProber {
+	return &PortProber{
+		logger:         logger,
+		interval:       interval,
+		clock:          clock,
+		dialer:         dialer,
+		executorClient: executorClient,
+		metronClient:   metronClient,
+	}
+}
+
+func (prober *PortProber) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := prober.logger.Session("container-port-prober")
+
+	ticker := prober.clock.NewTicker(prober.interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case signal := <-signals:
+			logger.Info("signalled", lager.Data{"signal": signal.String()})
+			return nil
+
+		case <-ticker.C():
+			if !prober.probeContainers(logger, signals) {
+				logger.Info("signalled")
+				return nil
+			}
+		}
+	}
+}
+
+// probeContainers probes every running container with a mapped port. It
+// returns false if it was signalled before finishing the round.
+func (prober *PortProber) probeContainers(logger lager.Logger, signals <-chan os.Signal) bool {
+	logger = logger.Session("probe")
+
+	containers, err := prober.executorClient.ListContainers(logger)
+	if err != nil {
+		logger.Error("failed-to-fetch-containers", err)
+		return true
+	}
+
+	probeable := []executor.Container{}
+	for _, container := range containers {
+		if container.State == executor.StateRunning && len(container.Ports) > 0 {
+			probeable = append(probeable, container)
+		}
+	}
+
+	if len(probeable) == 0 {
+		prober.sendUnreachable(logger, 0)
+		return true
+	}
+
+	spacing := prober.interval / time.Duration(len(probeable))
+	unreachable := 0
+
+	for i, container := range probeable {
+		if i > 0 {
+			select {
+			case <-signals:
+				return false
+			case <-prober.clock.After(spacing):
+			}
+		}
+
+		reachable := prober.probe(logger, container)
+		value := 1
+		if !reachable {
+			value = 0
+			unreachable++
+		}
+
+		err := prober.metronClient.SendMetric(PortReachableMetric, value, loggregator.WithEnvelopeTags(containerTags(container)))
+		if err != nil {
+			logger.Error("failed-to-send-port-reachable-metric", err, lager.Data{"guid": container.Guid})
+		}
+	}
+
+	prober.sendUnreachable(logger, unreachable)
+	return true
+}
+
+func (prober *PortProber) probe(logger lager.Logger, container executor.Container) bool {
+	port := container.Ports[0]
+	address := net.JoinHostPort(container.ExternalIP, strconv.Itoa(int(port.HostPort)))
+	if port.HostPort == 0 {
+		address = net.JoinHostPort(container.InternalIP, strconv.Itoa(int(port.ContainerPort)))
+	}
+
+	conn, err := prober.dialer.DialTimeout("tcp", address, portProbeTimeout)
+	if err != nil {
+		logger.Debug("port-unreachable", lager.Data{"guid": container.Guid, "address": address, "error": err.Error()})
+		return false
+	}
+	conn.Close()
+
+	return true
+}
+
+func (prober *PortProber) sendUnreachable(logger lager.Logger, count int) {
+	err := prober.metronClient.SendMetric(UnreachableContainersMetric, count)
+	if err != nil {
+		logger.Error("failed-to-send-unreachable-containers-metric", err)
+	}
+}
+
+func containerTags(container executor.Container) map[string]string {
+	tags := map[string]string{}
+	for k, v := range container.MetricsConfig.Tags {
+		tags[k] = v
+	}
+
+	if _, ok := tags["source_id"]; !ok {
+		sourceID := container.MetricsConfig.Guid
+		if sourceID == "" {
+			sourceID = container.Guid
+		}
+		tags["source_id"] = sourceID
+	}
+	if _, ok := tags["instance_id"]; !ok {
+		tags["instance_id"] = strconv.Itoa(container.MetricsConfig.Index)
+	}
+
+	return tags
+}
@@ -0,0 +1,148 @@
+package containermetrics_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	mfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/containermetrics"
+	"code.cloudfoundry.org/executor/containermetrics/containermetricsfakes"
+	efakes "code.cloudfoundry.org/executor/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+)
+
+var _ = Describe("PortProber", func() {
+	var (
+		logger *lagertest.TestLogger
+
+		interval           time.Duration
+		fakeClock          *fakeclock.FakeClock
+		fakeDialer         *containermetricsfakes.FakeDialer
+		fakeExecutorClient *efakes.FakeClient
+		fakeMetronClient   *mfakes.FakeIngressClient
+
+		process ifrit.Process
+	)
+
+	sentMetrics := func(name string) []int {
+		values := []int{}
+		for i := 0; i < fakeMetronClient.SendMetricCallCount(); i++ {
+			metricName, value, _ := fakeMetronClient.SendMetricArgsForCall(i)
+			if metricName == name {
+				values = append(values, value)
+			}
+		}
+		return values
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+
+		interval = 10 * time.Second
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeDialer = new(containermetricsfakes.FakeDialer)
+		fakeExecutorClient = new(efakes.FakeClient)
+		fakeMetronClient = new(mfakes.FakeIngressClient)
+
+		fakeDialer.DialTimeoutStub = func(network, address string, timeout time.Duration) (net.Conn, error) {
+			if address == "10.0.0.2:61002" {
+				return nil, errors.New("connection refused")
+			}
+			client, server := net.Pipe()
+			server.Close()
+			return client, nil
+		}
+
+		fakeExecutorClient.ListContainersReturns([]executor.Container{
+			{
+				Guid:       "container-a",
+				State:      executor.StateRunning,
+				ExternalIP: "10.0.0.1",
+				RunInfo: executor.RunInfo{
+					Ports: []executor.PortMapping{{ContainerPort: 8080, HostPort: 61001}, {ContainerPort: 9090, HostPort: 61011}},
+				},
+			},
+			{
+				Guid:       "container-b",
+				State:      executor.StateRunning,
+				ExternalIP: "10.0.0.2",
+				RunInfo: executor.RunInfo{
+					Ports: []executor.PortMapping{{ContainerPort: 8080, HostPort: 61002}},
+				},
+			},
+			{
+				Guid:       "container-starting",
+				State:      executor.StateCreated,
+				ExternalIP: "10.0.0.3",
+				RunInfo: executor.RunInfo{
+					Ports: []executor.PortMapping{{ContainerPort: 8080, HostPort: 61003}},
+				},
+			},
+			{
+				Guid:  "container-without-ports",
+				State: executor.StateRunning,
+			},
+		}, nil)
+	})
+
+	JustBeforeEach(func() {
+		prober := containermetrics.NewPortProber(logger, interval, fakeClock, fakeDialer, fakeExecutorClient, fakeMetronClient)
+		process = ifrit.Invoke(prober)
+		fakeClock.WaitForWatcherAndIncrement(interval)
+		Eventually(fakeExecutorClient.ListContainersCallCount).Should(Equal(1))
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+	})
+
+	It("probes the first mapped port of running containers only", func() {
+		Eventually(fakeDialer.DialTimeoutCallCount).Should(Equal(1))
+		network, address, _ := fakeDialer.DialTimeoutArgsForCall(0)
+		Expect(network).To(Equal("tcp"))
+		Expect(address).To(Equal("10.0.0.1:61001"))
+
+		fakeClock.WaitForNWatchersAndIncrement(interval/2, 2)
+		Eventually(fakeDialer.DialTimeoutCallCount).Should(Equal(2))
+		_, address, _ = fakeDialer.DialTimeoutArgsForCall(1)
+		Expect(address).To(Equal("10.0.0.2:61002"))
+
+		Consistently(fakeDialer.DialTimeoutCallCount).Should(Equal(2))
+	})
+
+	It("spreads the probes over the interval", func() {
+		Eventually(fakeDialer.DialTimeoutCallCount).Should(Equal(1))
+
+		fakeClock.WaitForNWatchersAndIncrement(interval/2-time.Second, 2)
+		Consistently(fakeDialer.DialTimeoutCallCount).Should(Equal(1))
+
+		fakeClock.Increment(time.Second)
+		Eventually(fakeDialer.DialTimeoutCallCount).Should(Equal(2))
+	})
+
+	It("emits whether each port was reachable and the number of unreachable containers", func() {
+		Eventually(func() []int { return sentMetrics(containermetrics.PortReachableMetric) }).Should(Equal([]int{1}))
+
+		fakeClock.WaitForNWatchersAndIncrement(interval/2, 2)
+		Eventually(func() []int { return sentMetrics(containermetrics.PortReachableMetric) }).Should(Equal([]int{1, 0}))
+		Eventually(func() []int { return sentMetrics(containermetrics.UnreachableContainersMetric) }).Should(Equal([]int{1}))
+	})
+
+	Context("when listing containers fails", func() {
+		BeforeEach(func() {
+			fakeExecutorClient.ListContainersReturns(nil, errors.New("boom"))
+		})
+
+		It("does not probe or emit metrics", func() {
+			Consistently(fakeDialer.DialTimeoutCallCount).Should(BeZero())
+			Expect(fakeMetronClient.SendMetricCallCount()).To(BeZero())
+		})
+	})
+})
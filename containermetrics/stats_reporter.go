@@ -3,12 +3,14 @@ package containermetrics
 import (
 	"os"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/clock"
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/resourceregistry"
 	"code.cloudfoundry.org/lager"
 )
 
@@ -21,10 +23,19 @@ type StatsReporter struct {
 	clock          clock.Clock
 	executorClient executor.Client
 	metrics        atomic.Value
+	// containerMetrics holds the garden metrics of the last collection, as
+	// served by GetMetricsLightweight
+	containerMetrics atomic.Value
 
 	metronClient          loggingclient.IngressClient
 	enableContainerProxy  bool
 	proxyMemoryAllocation float64
+
+	// the cpu samples of the previous tick are dropped through resources
+	// once their container is removed
+	resources *resourceregistry.Registry
+	infoLock  sync.Mutex
+	cpuInfos  map[string]*cpuInfo
 }
 
 type cpuInfo struct {
@@ -39,6 +50,7 @@ func NewStatsReporter(logger lager.Logger,
 	additionalMemoryMB int,
 	executorClient executor.Client,
 	metronClient loggingclient.IngressClient,
+	resources *resourceregistry.Registry,
 ) *StatsReporter {
 	return &StatsReporter{
 		logger: logger,
@@ -49,6 +61,9 @@ func NewStatsReporter(logger lager.Logger,
 		metronClient:          metronClient,
 		enableContainerProxy:  enableContainerProxy,
 		proxyMemoryAllocation: float64(additionalMemoryMB * megabytesToBytes),
+
+		resources: resources,
+		cpuInfos:  make(map[string]*cpuInfo),
 	}
 }
 
@@ -60,7 +75,6 @@ func (reporter *StatsReporter) Run(signals <-chan os.Signal, ready chan<- struct
 
 	close(ready)
 
-	cpuInfos := make(map[string]*cpuInfo)
 	for {
 		select {
 		case signal := <-signals:
@@ -68,7 +82,7 @@ func (reporter *StatsReporter) Run(signals <-chan os.Signal, ready chan<- struct
 			return nil
 
 		case now := <-ticker.C():
-			cpuInfos = reporter.emitContainerMetrics(logger, cpuInfos, now)
+			reporter.emitContainerMetrics(logger, now)
 		}
 	}
 }
@@ -80,7 +94,22 @@ func (reporter *StatsReporter) Metrics() map[string]*CachedContainerMetrics {
 	return nil
 }
 
-func (reporter *StatsReporter) emitContainerMetrics(logger lager.Logger, previousCPUInfos map[string]*cpuInfo, now time.Time) map[string]*cpuInfo {
+// GetMetricsLightweight returns the metrics of the container as of the last
+// collection, without calling garden or using the metrics work pool. It
+// returns executor.ErrContainerNotFound if the container had no metrics in
+// the last collection, including before the first collection has run.
+func (reporter *StatsReporter) GetMetricsLightweight(logger lager.Logger, guid string) (executor.ContainerMetrics, error) {
+	if v := reporter.containerMetrics.Load(); v != nil {
+		if metrics, ok := v.(map[string]executor.ContainerMetrics)[guid]; ok {
+			return metrics, nil
+		}
+	}
+
+	logger.Debug("no-cached-metrics", lager.Data{"guid": guid})
+	return executor.ContainerMetrics{}, executor.ErrContainerNotFound
+}
+
+func (reporter *StatsReporter) emitContainerMetrics(logger lager.Logger, now time.Time) {
 	logger = logger.Session("tick")
 
 	startTime := reporter.clock.Now()
@@ -95,7 +124,7 @@ func (reporter *StatsReporter) emitContainerMetrics(logger lager.Logger, previou
 	metrics, err := reporter.executorClient.GetBulkMetrics(logger)
 	if err != nil {
 		logger.Error("failed-to-get-all-metrics", err)
-		return previousCPUInfos
+		return
 	}
 
 	logger.Debug("emitting", lager.Data{
@@ -106,35 +135,66 @@ func (reporter *StatsReporter) emitContainerMetrics(logger lager.Logger, previou
 	containers, err := reporter.executorClient.ListContainers(logger)
 	if err != nil {
 		logger.Error("failed-to-fetch-containers", err)
-		return previousCPUInfos
+		return
 	}
 
-	newCPUInfos := make(map[string]*cpuInfo)
 	repMetricsMap := make(map[string]*CachedContainerMetrics)
+	containerMetricsMap := make(map[string]executor.ContainerMetrics)
 
 	for _, container := range containers {
 		guid := container.Guid
-		metric := metrics[guid]
+		metric, found := metrics[guid]
 
-		previousCPUInfo := previousCPUInfos[guid]
+		previousCPUInfo := reporter.previousCPUInfo(guid)
 
 		if reporter.enableContainerProxy && container.EnableContainerProxy {
 			metric.MemoryUsageInBytes = uint64(float64(metric.MemoryUsageInBytes) * reporter.scaleMemory(container))
 			metric.MemoryLimitInBytes = uint64(float64(metric.MemoryLimitInBytes) - reporter.proxyMemoryAllocation)
 		}
 
-		repMetrics, cpu := reporter.calculateAndSendMetrics(logger, metric.MetricsConfig, metric.ContainerMetrics, previousCPUInfo, now)
-		if cpu != nil {
-			newCPUInfos[guid] = cpu
+		if found {
+			containerMetricsMap[guid] = metric.ContainerMetrics
 		}
 
+		repMetrics, cpu := reporter.calculateAndSendMetrics(logger, metric.MetricsConfig, metric.ContainerMetrics, previousCPUInfo, now)
+		reporter.keepCPUInfo(guid, cpu)
+
 		if repMetrics != nil {
 			repMetricsMap[guid] = repMetrics
 		}
 	}
 
 	reporter.metrics.Store(repMetricsMap)
-	return newCPUInfos
+	reporter.containerMetrics.Store(containerMetricsMap)
+}
+
+func (reporter *StatsReporter) previousCPUInfo(guid string) *cpuInfo {
+	reporter.infoLock.Lock()
+	defer reporter.infoLock.Unlock()
+
+	return reporter.cpuInfos[guid]
+}
+
+// keepCPUInfo remembers the cpu sample of the container for the next tick.
+// It is dropped through the resource registry once the container is
+// removed, and not kept at all if the container has already been removed.
+func (reporter *StatsReporter) keepCPUInfo(guid string, cpu *cpuInfo) {
+	reporter.infoLock.Lock()
+	defer reporter.infoLock.Unlock()
+
+	// the cleanup takes infoLock, so it cannot run between registering and
+	// keeping the sample
+	registered := reporter.resources.Register(guid, "container-metrics-samples", func(lager.Logger) {
+		reporter.infoLock.Lock()
+		defer reporter.infoLock.Unlock()
+
+		delete(reporter.cpuInfos, guid)
+	})
+	if !registered {
+		return
+	}
+
+	reporter.cpuInfos[guid] = cpu
 }
 
 func (reporter *StatsReporter) calculateAndSendMetrics(
@@ -184,13 +244,19 @@ func (reporter *StatsReporter) calculateAndSendMetrics(
 		}
 	}
 
+	var inodeUsageFraction float64
+	if containerMetrics.InodeLimit > 0 {
+		inodeUsageFraction = float64(containerMetrics.InodeUsage) / float64(containerMetrics.InodeLimit)
+	}
+
 	return &CachedContainerMetrics{
-		MetricGUID:       applicationId,
-		CPUUsageFraction: cpuPercent / 100,
-		DiskUsageBytes:   containerMetrics.DiskUsageInBytes,
-		DiskQuotaBytes:   containerMetrics.DiskLimitInBytes,
-		MemoryUsageBytes: containerMetrics.MemoryUsageInBytes,
-		MemoryQuotaBytes: containerMetrics.MemoryLimitInBytes,
+		MetricGUID:         applicationId,
+		CPUUsageFraction:   cpuPercent / 100,
+		DiskUsageBytes:     containerMetrics.DiskUsageInBytes,
+		DiskQuotaBytes:     containerMetrics.DiskLimitInBytes,
+		MemoryUsageBytes:   containerMetrics.MemoryUsageInBytes,
+		MemoryQuotaBytes:   containerMetrics.MemoryLimitInBytes,
+		InodeUsageFraction: inodeUsageFraction,
 	}, &currentInfo
 }
 
@@ -9,6 +9,7 @@ import (
 	mfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/containermetrics"
+	"code.cloudfoundry.org/executor/depot/resourceregistry"
 	efakes "code.cloudfoundry.org/executor/fakes"
 	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
@@ -42,6 +43,7 @@ var _ = Describe("StatsReporter", func() {
 
 		enableContainerProxy    bool
 		proxyMemoryAllocationMB int
+		resources               *resourceregistry.Registry
 		reporter                *containermetrics.StatsReporter
 	)
 
@@ -55,10 +57,22 @@ var _ = Describe("StatsReporter", func() {
 
 		enableContainerProxy = false
 		proxyMemoryAllocationMB = 5
+
+		resources = resourceregistry.New()
+		for _, guid := range []string{
+			"container-0",
+			"container-guid-with-index",
+			"container-guid-without-index",
+			"container-guid-without-preloaded-rootfs",
+			"container-guid-without-source-id",
+			"some-metric-guid",
+		} {
+			resources.Add(guid)
+		}
 	})
 
 	JustBeforeEach(func() {
-		reporter = containermetrics.NewStatsReporter(logger, interval, fakeClock, enableContainerProxy, proxyMemoryAllocationMB, fakeExecutorClient, fakeMetronClient)
+		reporter = containermetrics.NewStatsReporter(logger, interval, fakeClock, enableContainerProxy, proxyMemoryAllocationMB, fakeExecutorClient, fakeMetronClient, resources)
 		process = ifrit.Invoke(reporter)
 		fakeClock.WaitForWatcherAndIncrement(interval)
 		Eventually(fakeExecutorClient.GetBulkMetricsCallCount).Should(Equal(1))
@@ -193,6 +207,8 @@ var _ = Describe("StatsReporter", func() {
 						TimeSpentInCPU:                      105 * time.Second,
 						MemoryLimitInBytes:                  megsToBytes(7890),
 						DiskLimitInBytes:                    4096,
+						InodeUsage:                          250,
+						InodeLimit:                          1000,
 						ContainerAgeInNanoseconds:           1005,
 						AbsoluteCPUEntitlementInNanoseconds: 2005,
 					},
@@ -508,12 +524,13 @@ var _ = Describe("StatsReporter", func() {
 					containerMetrics := reporter.Metrics()
 					Expect(containerMetrics).To(HaveLen(4))
 					Expect(containerMetrics).To(HaveKeyWithValue("container-guid-without-index", &containermetrics.CachedContainerMetrics{
-						MetricGUID:       "source-id-without-index",
-						CPUUsageFraction: 0.5,
-						MemoryUsageBytes: megsToBytes(1230),
-						DiskUsageBytes:   4560,
-						MemoryQuotaBytes: megsToBytes(7890),
-						DiskQuotaBytes:   4096,
+						MetricGUID:         "source-id-without-index",
+						CPUUsageFraction:   0.5,
+						MemoryUsageBytes:   megsToBytes(1230),
+						DiskUsageBytes:     4560,
+						MemoryQuotaBytes:   megsToBytes(7890),
+						DiskQuotaBytes:     4096,
+						InodeUsageFraction: 0.25,
 					}))
 					Expect(containerMetrics).To(HaveKeyWithValue("container-guid-with-index", &containermetrics.CachedContainerMetrics{
 						MetricGUID:       "source-id-with-index",
@@ -542,6 +559,20 @@ var _ = Describe("StatsReporter", func() {
 				})
 			})
 
+			Context("GetMetricsLightweight", func() {
+				It("returns the garden metrics of the last collection without fetching them again", func() {
+					Eventually(func() (executor.ContainerMetrics, error) {
+						return reporter.GetMetricsLightweight(logger, "container-guid-with-index")
+					}).Should(Equal(metricsMap2["container-guid-with-index"].ContainerMetrics))
+					Expect(fakeExecutorClient.GetBulkMetricsCallCount()).To(Equal(2))
+				})
+
+				It("returns ErrContainerNotFound for a container without cached metrics", func() {
+					_, err := reporter.GetMetricsLightweight(logger, "unknown-guid")
+					Expect(err).To(Equal(executor.ErrContainerNotFound))
+				})
+			})
+
 			Context("and the interval elapses again", func() {
 				JustBeforeEach(func() {
 					fakeClock.WaitForWatcherAndIncrement(interval)
@@ -598,6 +629,49 @@ var _ = Describe("StatsReporter", func() {
 		})
 	})
 
+	Context("when the samples of a container are released", func() {
+		sentCPUPercentages := func(sourceID string) []float64 {
+			percentages := []float64{}
+			for _, m := range sentMetrics() {
+				if m.Tags["source_id"] == sourceID {
+					percentages = append(percentages, m.CpuPercentage)
+				}
+			}
+			return percentages
+		}
+
+		BeforeEach(func() {
+			containers := []executor.Container{{Guid: "container-0"}}
+			fakeExecutorClient.ListContainersReturns(containers, nil)
+
+			fakeExecutorClient.GetBulkMetricsReturnsOnCall(0, map[string]executor.Metrics{
+				"container-0": {
+					MetricsConfig:    executor.MetricsConfig{Tags: map[string]string{"source_id": "some-source-id"}},
+					ContainerMetrics: executor.ContainerMetrics{TimeSpentInCPU: 100 * time.Second},
+				},
+			}, nil)
+			fakeExecutorClient.GetBulkMetricsReturnsOnCall(1, map[string]executor.Metrics{
+				"container-0": {
+					MetricsConfig:    executor.MetricsConfig{Tags: map[string]string{"source_id": "some-source-id"}},
+					ContainerMetrics: executor.ContainerMetrics{TimeSpentInCPU: 110 * time.Second},
+				},
+			}, nil)
+		})
+
+		It("computes the cpu usage from the previous sample while the container is tracked", func() {
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(func() []float64 { return sentCPUPercentages("some-source-id") }).Should(Equal([]float64{0, 100}))
+		})
+
+		It("drops the previous sample once the registry releases the container", func() {
+			Eventually(func() []float64 { return sentCPUPercentages("some-source-id") }).Should(HaveLen(1))
+			resources.Release(logger, "container-0")
+
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(func() []float64 { return sentCPUPercentages("some-source-id") }).Should(Equal([]float64{0, 0}))
+		})
+	})
+
 	Context("when the metric tags are provided", func() {
 		BeforeEach(func() {
 			containers := []executor.Container{
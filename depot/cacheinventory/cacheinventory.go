@@ -0,0 +1,375 @@
+package cacheinventory
+
+import (
+	"io"
+	"net/url"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+type SortOrder string
+
+const (
+	// SortByKey lists entries in cache key order.
+	SortByKey SortOrder = ""
+	// SortBySize lists the largest entries first.
+	SortBySize SortOrder = "size"
+	// SortByAge lists the least recently accessed entries first.
+	SortByAge SortOrder = "age"
+)
+
+// Entry describes a cache key that has been fetched through the inventory.
+type Entry struct {
+	CacheKey   string    `json:"cache_key"`
+	Size       int64     `json:"size"`
+	LastAccess time.Time `json:"last_access"`
+	SourceHost string    `json:"source_host"`
+	InUse      bool      `json:"in_use"`
+}
+
+type entry struct {
+	size       int64
+	lastAccess time.Time
+	sourceHost string
+	refCount   int
+}
+
+//go:generate counterfeiter -o cacheinventoryfakes/fake_remover.go . Remover
+
+// Remover removes an entry from the cache behind a CachedDownloader, as
+// *cacheddownloader.FileCache does.
+type Remover interface {
+	Remove(logger lager.Logger, cacheKey string)
+}
+
+// Inventory wraps a CachedDownloader and records metadata about every cache
+// key fetched through it, so that operators can see what the cache holds. It
+// only knows about keys fetched since the executor started. The underlying
+// cache does not report its evictions, so the inventory forgets entries by
+// applying the same policy: once the recorded sizes exceed maxSizeInBytes,
+// the least recently accessed entries that are not in use are dropped.
+type Inventory struct {
+	cacheddownloader.CachedDownloader
+
+	hits, misses uint64
+
+	remover        Remover
+	maxSizeInBytes int64
+	clock          clock.Clock
+	lock           sync.Mutex
+	entries        map[string]*entry
+
+	// partitionFetches counts the hits and misses of each cache partition
+	partitionFetches map[string]*executor.CachePartitionStats
+}
+
+// New returns an Inventory of cachedDownloader. remover is used to evict
+// entries from the underlying cache; if it is nil, EvictIdle never evicts.
+// maxSizeInBytes is the size limit of the underlying cache; zero means the
+// inventory never forgets entries on its own.
+func New(cachedDownloader cacheddownloader.CachedDownloader, remover Remover, maxSizeInBytes int64, clock clock.Clock) *Inventory {
+	return &Inventory{
+		CachedDownloader: cachedDownloader,
+		remover:          remover,
+		maxSizeInBytes:   maxSizeInBytes,
+		clock:            clock,
+		entries:          map[string]*entry{},
+		partitionFetches: map[string]*executor.CachePartitionStats{},
+	}
+}
+
+func (i *Inventory) Fetch(
+	logger lager.Logger,
+	urlToFetch *url.URL,
+	cacheKey string,
+	checksum cacheddownloader.ChecksumInfoType,
+	cancelChan <-chan struct{},
+) (io.ReadCloser, int64, error) {
+	if cacheKey == "" {
+		return i.CachedDownloader.Fetch(logger, urlToFetch, cacheKey, checksum, cancelChan)
+	}
+
+	i.acquire(cacheKey, urlToFetch)
+	reader, size, err := i.CachedDownloader.Fetch(logger, urlToFetch, cacheKey, checksum, cancelChan)
+	if err != nil {
+		i.releaseFailed(cacheKey)
+		return nil, 0, err
+	}
+
+	i.recordFetch(logger, cacheKey, size)
+	return &releasingReadCloser{ReadCloser: reader, release: func() { i.release(cacheKey) }}, size, nil
+}
+
+func (i *Inventory) FetchAsDirectory(
+	logger lager.Logger,
+	urlToFetch *url.URL,
+	cacheKey string,
+	checksum cacheddownloader.ChecksumInfoType,
+	cancelChan <-chan struct{},
+) (string, int64, error) {
+	if cacheKey == "" {
+		return i.CachedDownloader.FetchAsDirectory(logger, urlToFetch, cacheKey, checksum, cancelChan)
+	}
+
+	i.acquire(cacheKey, urlToFetch)
+	dirPath, size, err := i.CachedDownloader.FetchAsDirectory(logger, urlToFetch, cacheKey, checksum, cancelChan)
+	if err != nil {
+		i.releaseFailed(cacheKey)
+		return "", 0, err
+	}
+
+	i.recordFetch(logger, cacheKey, size)
+	return dirPath, size, nil
+}
+
+func (i *Inventory) CloseDirectory(logger lager.Logger, cacheKey, directoryPath string) error {
+	err := i.CachedDownloader.CloseDirectory(logger, cacheKey, directoryPath)
+	i.release(cacheKey)
+	return err
+}
+
+// CacheHits returns the number of cached fetches served from the cache.
+func (i *Inventory) CacheHits() uint64 {
+	return atomic.LoadUint64(&i.hits)
+}
+
+// CacheMisses returns the number of cached fetches that had to download the
+// artifact.
+func (i *Inventory) CacheMisses() uint64 {
+	return atomic.LoadUint64(&i.misses)
+}
+
+// PartitionStats returns the cache stats of every cache partition that has
+// fetched through the inventory, keyed by partition tag. Entries and sizes
+// only cover the entries the inventory knows about.
+func (i *Inventory) PartitionStats() map[string]executor.CachePartitionStats {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	stats := map[string]executor.CachePartitionStats{}
+	for tag, fetches := range i.partitionFetches {
+		stats[tag] = *fetches
+	}
+
+	for key, e := range i.entries {
+		tag := executor.CachePartitionTagOf(key)
+		if tag == "" {
+			continue
+		}
+
+		partition := stats[tag]
+		partition.Entries++
+		partition.SizeBytes += e.size
+		stats[tag] = partition
+	}
+
+	return stats
+}
+
+// Entries returns a snapshot of the known cache entries in the given order.
+// A positive limit truncates the result.
+func (i *Inventory) Entries(order SortOrder, limit int) []Entry {
+	i.lock.Lock()
+	entries := make([]Entry, 0, len(i.entries))
+	for key, e := range i.entries {
+		entries = append(entries, Entry{
+			CacheKey:   key,
+			Size:       e.size,
+			LastAccess: e.lastAccess,
+			SourceHost: e.sourceHost,
+			InUse:      e.refCount > 0,
+		})
+	}
+	i.lock.Unlock()
+
+	sort.Slice(entries, func(a, b int) bool {
+		switch order {
+		case SortBySize:
+			if entries[a].Size != entries[b].Size {
+				return entries[a].Size > entries[b].Size
+			}
+		case SortByAge:
+			if !entries[a].LastAccess.Equal(entries[b].LastAccess) {
+				return entries[a].LastAccess.Before(entries[b].LastAccess)
+			}
+		}
+		return entries[a].CacheKey < entries[b].CacheKey
+	})
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	return entries
+}
+
+// EvictIdle removes the least recently accessed entry that is not in use
+// from the underlying cache and returns it. It returns false if there is no
+// such entry.
+func (i *Inventory) EvictIdle(logger lager.Logger) (Entry, bool) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if i.remover == nil {
+		return Entry{}, false
+	}
+
+	oldestKey, oldest := i.oldestIdle()
+	if oldest == nil {
+		return Entry{}, false
+	}
+
+	logger.Info("evicting-idle-entry", lager.Data{"cache-key": oldestKey, "size": oldest.size})
+	i.remover.Remove(logger, oldestKey)
+	delete(i.entries, oldestKey)
+
+	return Entry{
+		CacheKey:   oldestKey,
+		Size:       oldest.size,
+		LastAccess: oldest.lastAccess,
+		SourceHost: oldest.sourceHost,
+	}, true
+}
+
+// oldestIdle returns the least recently accessed entry that is not in use, or
+// a nil entry if there is none. Callers must hold lock.
+func (i *Inventory) oldestIdle() (string, *entry) {
+	var oldestKey string
+	var oldest *entry
+	for key, e := range i.entries {
+		if e.refCount > 0 {
+			continue
+		}
+		if oldest == nil || e.lastAccess.Before(oldest.lastAccess) ||
+			(e.lastAccess.Equal(oldest.lastAccess) && key < oldestKey) {
+			oldestKey, oldest = key, e
+		}
+	}
+	return oldestKey, oldest
+}
+
+// forgetEvicted drops the entries the underlying cache has evicted to stay
+// within maxSizeInBytes. Callers must hold lock.
+func (i *Inventory) forgetEvicted(logger lager.Logger) {
+	if i.maxSizeInBytes <= 0 {
+		return
+	}
+
+	var total int64
+	for _, e := range i.entries {
+		total += e.size
+	}
+
+	for total > i.maxSizeInBytes {
+		key, e := i.oldestIdle()
+		if e == nil {
+			return
+		}
+
+		logger.Debug("forgetting-evicted-entry", lager.Data{"cache-key": key, "size": e.size})
+		delete(i.entries, key)
+		total -= e.size
+	}
+}
+
+func (i *Inventory) acquire(cacheKey string, source *url.URL) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	e, ok := i.entries[cacheKey]
+	if !ok {
+		e = &entry{}
+		i.entries[cacheKey] = e
+	}
+	e.refCount++
+	e.lastAccess = i.clock.Now()
+	if source != nil {
+		e.sourceHost = source.Host
+	}
+}
+
+func (i *Inventory) release(cacheKey string) {
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	e, ok := i.entries[cacheKey]
+	if !ok || e.refCount == 0 {
+		return
+	}
+	e.refCount--
+}
+
+// releaseFailed releases cacheKey after a failed fetch, and forgets the entry
+// if it was never cached.
+func (i *Inventory) releaseFailed(cacheKey string) {
+	i.release(cacheKey)
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if e, ok := i.entries[cacheKey]; ok && e.refCount == 0 && e.size == 0 {
+		delete(i.entries, cacheKey)
+	}
+}
+
+// recordFetch counts a successful fetch as a cache hit or miss, and records
+// the size of the entry.
+func (i *Inventory) recordFetch(logger lager.Logger, cacheKey string, size int64) {
+	if size == 0 {
+		atomic.AddUint64(&i.hits, 1)
+	} else {
+		atomic.AddUint64(&i.misses, 1)
+	}
+
+	if tag := executor.CachePartitionTagOf(cacheKey); tag != "" {
+		i.lock.Lock()
+		fetches, ok := i.partitionFetches[tag]
+		if !ok {
+			fetches = &executor.CachePartitionStats{}
+			i.partitionFetches[tag] = fetches
+		}
+		if size == 0 {
+			fetches.Hits++
+		} else {
+			fetches.Misses++
+		}
+		i.lock.Unlock()
+	}
+
+	i.recordSize(logger, cacheKey, size)
+}
+
+// recordSize only records non-zero sizes, as the cached downloader reports a
+// size of zero when it serves an entry that is already cached. A new size may
+// have made the underlying cache evict older entries.
+func (i *Inventory) recordSize(logger lager.Logger, cacheKey string, size int64) {
+	if size == 0 {
+		return
+	}
+
+	i.lock.Lock()
+	defer i.lock.Unlock()
+
+	if e, ok := i.entries[cacheKey]; ok {
+		e.size = size
+	}
+	i.forgetEvicted(logger)
+}
+
+type releasingReadCloser struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (r *releasingReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(r.release)
+	return err
+}
@@ -0,0 +1,13 @@
+package cacheinventory_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCacheInventory(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Cache Inventory Suite")
+}
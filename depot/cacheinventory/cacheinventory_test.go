@@ -0,0 +1,310 @@
+package cacheinventory_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	cdfakes "code.cloudfoundry.org/cacheddownloader/cacheddownloaderfakes"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/cacheinventory"
+	"code.cloudfoundry.org/executor/depot/cacheinventory/cacheinventoryfakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Inventory", func() {
+	var (
+		logger    *lagertest.TestLogger
+		fakeCache *cdfakes.FakeCachedDownloader
+		remover   *cacheinventoryfakes.FakeRemover
+		fakeClock *fakeclock.FakeClock
+		inventory *cacheinventory.Inventory
+		startTime time.Time
+		sizes     map[string]int64
+	)
+
+	fetch := func(rawURL, cacheKey string) io.ReadCloser {
+		u, err := url.Parse(rawURL)
+		Expect(err).NotTo(HaveOccurred())
+
+		reader, _, err := inventory.Fetch(logger, u, cacheKey, cacheddownloader.ChecksumInfoType{}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		return reader
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeCache = new(cdfakes.FakeCachedDownloader)
+		remover = new(cacheinventoryfakes.FakeRemover)
+		startTime = time.Now()
+		fakeClock = fakeclock.NewFakeClock(startTime)
+		inventory = cacheinventory.New(fakeCache, remover, 0, fakeClock)
+
+		sizes = map[string]int64{"small": 10, "large": 1000, "medium": 100}
+		fakeCache.FetchStub = func(_ lager.Logger, _ *url.URL, cacheKey string, _ cacheddownloader.ChecksumInfoType, _ <-chan struct{}) (io.ReadCloser, int64, error) {
+			return ioutil.NopCloser(strings.NewReader("")), sizes[cacheKey], nil
+		}
+	})
+
+	Describe("Entries", func() {
+		BeforeEach(func() {
+			fetch("https://blobstore.example.com/droplets/abc?signature=secret", "medium").Close()
+			fakeClock.Increment(time.Minute)
+			fetch("https://other.example.com/buildpacks/ruby.zip", "large").Close()
+			fakeClock.Increment(time.Minute)
+			fetch("https://blobstore.example.com/droplets/def", "small").Close()
+		})
+
+		It("lists every fetched entry by cache key", func() {
+			Expect(inventory.Entries(cacheinventory.SortByKey, 0)).To(Equal([]cacheinventory.Entry{
+				{CacheKey: "large", Size: 1000, LastAccess: startTime.Add(time.Minute), SourceHost: "other.example.com"},
+				{CacheKey: "medium", Size: 100, LastAccess: startTime, SourceHost: "blobstore.example.com"},
+				{CacheKey: "small", Size: 10, LastAccess: startTime.Add(2 * time.Minute), SourceHost: "blobstore.example.com"},
+			}))
+		})
+
+		It("sorts by size", func() {
+			entries := inventory.Entries(cacheinventory.SortBySize, 0)
+			Expect(entries).To(HaveLen(3))
+			Expect(entries[0].CacheKey).To(Equal("large"))
+			Expect(entries[1].CacheKey).To(Equal("medium"))
+			Expect(entries[2].CacheKey).To(Equal("small"))
+		})
+
+		It("sorts by age", func() {
+			entries := inventory.Entries(cacheinventory.SortByAge, 0)
+			Expect(entries).To(HaveLen(3))
+			Expect(entries[0].CacheKey).To(Equal("medium"))
+			Expect(entries[1].CacheKey).To(Equal("large"))
+			Expect(entries[2].CacheKey).To(Equal("small"))
+		})
+
+		It("limits the number of entries", func() {
+			entries := inventory.Entries(cacheinventory.SortBySize, 2)
+			Expect(entries).To(HaveLen(2))
+			Expect(entries[1].CacheKey).To(Equal("medium"))
+		})
+
+		Context("when an entry is served from the cache", func() {
+			BeforeEach(func() {
+				sizes["large"] = 0
+				fakeClock.Increment(time.Minute)
+				fetch("https://other.example.com/buildpacks/ruby.zip", "large").Close()
+			})
+
+			It("keeps the recorded size and updates the last access time", func() {
+				entries := inventory.Entries(cacheinventory.SortBySize, 1)
+				Expect(entries[0].Size).To(BeEquivalentTo(1000))
+				Expect(entries[0].LastAccess).To(Equal(startTime.Add(3 * time.Minute)))
+			})
+		})
+	})
+
+	Describe("cache hits and misses", func() {
+		It("counts fetches served from the cache as hits and downloads as misses", func() {
+			fetch("https://blobstore.example.com/droplets/abc", "medium").Close()
+			sizes["medium"] = 0
+			fetch("https://blobstore.example.com/droplets/abc", "medium").Close()
+			fetch("https://blobstore.example.com/droplets/abc", "medium").Close()
+
+			Expect(inventory.CacheHits()).To(BeEquivalentTo(2))
+			Expect(inventory.CacheMisses()).To(BeEquivalentTo(1))
+		})
+
+		It("does not count uncached or failed fetches", func() {
+			fetch("https://blobstore.example.com/droplets/abc", "").Close()
+
+			fakeCache.FetchStub = nil
+			fakeCache.FetchReturns(nil, 0, errors.New("boom"))
+			u, err := url.Parse("https://blobstore.example.com/droplets/abc")
+			Expect(err).NotTo(HaveOccurred())
+			_, _, err = inventory.Fetch(logger, u, "medium", cacheddownloader.ChecksumInfoType{}, nil)
+			Expect(err).To(HaveOccurred())
+
+			Expect(inventory.CacheHits()).To(BeZero())
+			Expect(inventory.CacheMisses()).To(BeZero())
+		})
+	})
+
+	Describe("PartitionStats", func() {
+		It("breaks the hits, misses and entries down per cache partition", func() {
+			keyA := executor.PartitionedCacheKey("tenant-a", "medium")
+			keyB := executor.PartitionedCacheKey("tenant-b", "medium")
+			sizes[keyA] = 100
+			sizes[keyB] = 100
+
+			fetch("https://blobstore.example.com/droplets/abc", keyA).Close()
+			fetch("https://blobstore.example.com/droplets/abc", keyB).Close()
+			sizes[keyA] = 0
+			fetch("https://blobstore.example.com/droplets/abc", keyA).Close()
+			fetch("https://blobstore.example.com/droplets/abc", "medium").Close()
+
+			Expect(inventory.PartitionStats()).To(Equal(map[string]executor.CachePartitionStats{
+				"tenant-a": {Hits: 1, Misses: 1, Entries: 1, SizeBytes: 100},
+				"tenant-b": {Misses: 1, Entries: 1, SizeBytes: 100},
+			}))
+		})
+	})
+
+	Describe("in-use tracking", func() {
+		It("marks a fetched entry in use until its stream is closed", func() {
+			reader := fetch("https://blobstore.example.com/droplet", "medium")
+			Expect(inventory.Entries(cacheinventory.SortByKey, 0)[0].InUse).To(BeTrue())
+
+			Expect(reader.Close()).To(Succeed())
+			Expect(reader.Close()).To(Succeed())
+			Expect(inventory.Entries(cacheinventory.SortByKey, 0)[0].InUse).To(BeFalse())
+		})
+
+		It("marks a directory entry in use until it is closed", func() {
+			u, err := url.Parse("https://blobstore.example.com/dependency")
+			Expect(err).NotTo(HaveOccurred())
+			fakeCache.FetchAsDirectoryReturns("/some/dir", 42, nil)
+
+			_, _, err = inventory.FetchAsDirectory(logger, u, "dependency", cacheddownloader.ChecksumInfoType{}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(inventory.Entries(cacheinventory.SortByKey, 0)).To(ConsistOf(
+				cacheinventory.Entry{CacheKey: "dependency", Size: 42, LastAccess: startTime, SourceHost: "blobstore.example.com", InUse: true},
+			))
+
+			Expect(inventory.CloseDirectory(logger, "dependency", "/some/dir")).To(Succeed())
+			Expect(fakeCache.CloseDirectoryCallCount()).To(Equal(1))
+			Expect(inventory.Entries(cacheinventory.SortByKey, 0)[0].InUse).To(BeFalse())
+		})
+
+		Context("when the fetch fails", func() {
+			BeforeEach(func() {
+				fakeCache.FetchStub = nil
+				fakeCache.FetchReturns(nil, 0, errors.New("boom"))
+			})
+
+			It("does not keep an entry that was never cached", func() {
+				u, err := url.Parse("https://blobstore.example.com/droplet")
+				Expect(err).NotTo(HaveOccurred())
+
+				_, _, err = inventory.Fetch(logger, u, "medium", cacheddownloader.ChecksumInfoType{}, nil)
+				Expect(err).To(MatchError("boom"))
+				Expect(inventory.Entries(cacheinventory.SortByKey, 0)).To(BeEmpty())
+			})
+
+			Context("when the entry was cached before", func() {
+				BeforeEach(func() {
+					fakeCache.FetchReturnsOnCall(0, ioutil.NopCloser(strings.NewReader("")), 100, nil)
+				})
+
+				It("does not leave the entry in use", func() {
+					fetch("https://blobstore.example.com/droplet", "medium").Close()
+
+					u, err := url.Parse("https://blobstore.example.com/droplet")
+					Expect(err).NotTo(HaveOccurred())
+
+					_, _, err = inventory.Fetch(logger, u, "medium", cacheddownloader.ChecksumInfoType{}, nil)
+					Expect(err).To(MatchError("boom"))
+					Expect(inventory.Entries(cacheinventory.SortByKey, 0)).To(HaveLen(1))
+					Expect(inventory.Entries(cacheinventory.SortByKey, 0)[0].InUse).To(BeFalse())
+				})
+			})
+		})
+	})
+
+	Describe("EvictIdle", func() {
+		var inUse io.ReadCloser
+
+		BeforeEach(func() {
+			fetch("https://blobstore.example.com/droplets/abc", "medium").Close()
+			fakeClock.Increment(time.Minute)
+			inUse = fetch("https://blobstore.example.com/droplets/def", "small")
+			fakeClock.Increment(time.Minute)
+			fetch("https://other.example.com/buildpacks/ruby.zip", "large").Close()
+		})
+
+		It("evicts the least recently accessed entry that is not in use", func() {
+			entry, ok := inventory.EvictIdle(logger)
+			Expect(ok).To(BeTrue())
+			Expect(entry).To(Equal(cacheinventory.Entry{CacheKey: "medium", Size: 100, LastAccess: startTime, SourceHost: "blobstore.example.com"}))
+
+			Expect(remover.RemoveCallCount()).To(Equal(1))
+			_, cacheKey := remover.RemoveArgsForCall(0)
+			Expect(cacheKey).To(Equal("medium"))
+
+			keys := []string{}
+			for _, e := range inventory.Entries(cacheinventory.SortByKey, 0) {
+				keys = append(keys, e.CacheKey)
+			}
+			Expect(keys).To(Equal([]string{"large", "small"}))
+		})
+
+		It("skips entries in use", func() {
+			_, ok := inventory.EvictIdle(logger)
+			Expect(ok).To(BeTrue())
+
+			entry, ok := inventory.EvictIdle(logger)
+			Expect(ok).To(BeTrue())
+			Expect(entry.CacheKey).To(Equal("large"))
+
+			_, ok = inventory.EvictIdle(logger)
+			Expect(ok).To(BeFalse())
+			Expect(remover.RemoveCallCount()).To(Equal(2))
+
+			inUse.Close()
+			entry, ok = inventory.EvictIdle(logger)
+			Expect(ok).To(BeTrue())
+			Expect(entry.CacheKey).To(Equal("small"))
+		})
+
+		Context("when there is no remover", func() {
+			BeforeEach(func() {
+				inventory = cacheinventory.New(fakeCache, nil, 0, fakeClock)
+				fetch("https://blobstore.example.com/droplets/abc", "medium").Close()
+			})
+
+			It("does not evict anything", func() {
+				_, ok := inventory.EvictIdle(logger)
+				Expect(ok).To(BeFalse())
+				Expect(inventory.Entries(cacheinventory.SortByKey, 0)).To(HaveLen(1))
+			})
+		})
+	})
+
+	Context("when the recorded sizes exceed the size of the cache", func() {
+		var inUse io.ReadCloser
+
+		BeforeEach(func() {
+			inventory = cacheinventory.New(fakeCache, remover, 1100, fakeClock)
+
+			inUse = fetch("https://blobstore.example.com/droplets/abc", "medium")
+			fakeClock.Increment(time.Minute)
+			fetch("https://blobstore.example.com/droplets/def", "small").Close()
+			fakeClock.Increment(time.Minute)
+			fetch("https://other.example.com/buildpacks/ruby.zip", "large").Close()
+		})
+
+		It("forgets the least recently accessed idle entries the cache evicted", func() {
+			keys := []string{}
+			for _, e := range inventory.Entries(cacheinventory.SortByKey, 0) {
+				keys = append(keys, e.CacheKey)
+			}
+			Expect(keys).To(Equal([]string{"large", "medium"}))
+			Expect(remover.RemoveCallCount()).To(BeZero())
+
+			inUse.Close()
+		})
+	})
+
+	Context("when the cache key is empty", func() {
+		It("does not record an entry", func() {
+			fetch("https://blobstore.example.com/droplet", "").Close()
+			Expect(inventory.Entries(cacheinventory.SortByKey, 0)).To(BeEmpty())
+			Expect(fakeCache.FetchCallCount()).To(Equal(1))
+		})
+	})
+})
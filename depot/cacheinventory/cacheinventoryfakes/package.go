@@ -0,0 +1 @@
+package cacheinventoryfakes // import "code.cloudfoundry.org/executor/depot/cacheinventory/cacheinventoryfakes"
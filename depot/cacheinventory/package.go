@@ -0,0 +1 @@
+package cacheinventory // import "code.cloudfoundry.org/executor/depot/cacheinventory"
@@ -0,0 +1,116 @@
+package cacheinventory
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	"code.cloudfoundry.org/executor/initializer/configuration"
+	"code.cloudfoundry.org/lager"
+)
+
+const CachePressureEvictionsMetric = "CachePressureEvictions"
+
+// PressureMonitor periodically checks how full the filesystem holding the
+// download cache is. Whenever its usage exceeds thresholdPercent, idle cache
+// entries are evicted, least recently accessed first, until enough space has
+// been freed to bring usage back under the threshold or no idle entries are
+// left.
+type PressureMonitor struct {
+	logger lager.Logger
+
+	stater           configuration.FilesystemStater
+	cachePath        string
+	thresholdPercent float64
+	interval         time.Duration
+	clock            clock.Clock
+	inventory        *Inventory
+	metronClient     loggingclient.IngressClient
+}
+
+func NewPressureMonitor(
+	logger lager.Logger,
+	stater configuration.FilesystemStater,
+	cachePath string,
+	thresholdPercent float64,
+	interval time.Duration,
+	clock clock.Clock,
+	inventory *Inventory,
+	metronClient loggingclient.IngressClient,
+) *PressureMonitor {
+	return &PressureMonitor{
+		logger:           logger,
+		stater:           stater,
+		cachePath:        cachePath,
+		thresholdPercent: thresholdPercent,
+		interval:         interval,
+		clock:            clock,
+		inventory:        inventory,
+		metronClient:     metronClient,
+	}
+}
+
+func (m *PressureMonitor) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := m.logger.Session("cache-pressure-monitor", lager.Data{"cache-path": m.cachePath})
+
+	ticker := m.clock.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case signal := <-signals:
+			logger.Info("signalled", lager.Data{"signal": signal.String()})
+			return nil
+
+		case <-ticker.C():
+			m.relievePressure(logger)
+		}
+	}
+}
+
+func (m *PressureMonitor) relievePressure(logger lager.Logger) {
+	stats, err := m.stater.Statfs(m.cachePath)
+	if err != nil {
+		logger.Error("failed-to-stat-cache-filesystem", err)
+		return
+	}
+	if stats.TotalBytes == 0 {
+		return
+	}
+
+	usedBytes := int64(stats.TotalBytes - stats.AvailableBytes)
+	allowedBytes := int64(m.thresholdPercent / 100 * float64(stats.TotalBytes))
+	if usedBytes <= allowedBytes {
+		return
+	}
+
+	logger = logger.Session("relieve-pressure", lager.Data{
+		"used-bytes":    usedBytes,
+		"allowed-bytes": allowedBytes,
+	})
+	logger.Info("starting")
+
+	var evictions uint64
+	for freedBytes := int64(0); usedBytes-freedBytes > allowedBytes; {
+		entry, ok := m.inventory.EvictIdle(logger)
+		if !ok {
+			logger.Info("no-idle-entries")
+			break
+		}
+		freedBytes += entry.Size
+		evictions++
+	}
+
+	logger.Info("complete", lager.Data{"evictions": evictions})
+
+	if evictions == 0 {
+		return
+	}
+	err = m.metronClient.IncrementCounterWithDelta(CachePressureEvictionsMetric, evictions)
+	if err != nil {
+		logger.Error("failed-to-send-cache-pressure-evictions-metric", err)
+	}
+}
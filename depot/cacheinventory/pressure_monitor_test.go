@@ -0,0 +1,150 @@
+package cacheinventory_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	cdfakes "code.cloudfoundry.org/cacheddownloader/cacheddownloaderfakes"
+	"code.cloudfoundry.org/clock/fakeclock"
+	mfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
+	"code.cloudfoundry.org/executor/depot/cacheinventory"
+	"code.cloudfoundry.org/executor/depot/cacheinventory/cacheinventoryfakes"
+	"code.cloudfoundry.org/executor/initializer/configuration"
+	"code.cloudfoundry.org/executor/initializer/configuration/configurationfakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PressureMonitor", func() {
+	const interval = 30 * time.Second
+
+	var (
+		logger           *lagertest.TestLogger
+		fakeCache        *cdfakes.FakeCachedDownloader
+		remover          *cacheinventoryfakes.FakeRemover
+		stater           *configurationfakes.FakeFilesystemStater
+		fakeMetronClient *mfakes.FakeIngressClient
+		fakeClock        *fakeclock.FakeClock
+		inventory        *cacheinventory.Inventory
+		process          ifrit.Process
+	)
+
+	evictedKeys := func() []string {
+		keys := []string{}
+		for i := 0; i < remover.RemoveCallCount(); i++ {
+			_, key := remover.RemoveArgsForCall(i)
+			keys = append(keys, key)
+		}
+		return keys
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeCache = new(cdfakes.FakeCachedDownloader)
+		remover = new(cacheinventoryfakes.FakeRemover)
+		stater = new(configurationfakes.FakeFilesystemStater)
+		fakeMetronClient = new(mfakes.FakeIngressClient)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		inventory = cacheinventory.New(fakeCache, remover, 0, fakeClock)
+
+		sizes := map[string]int64{"oldest": 100, "middle": 100, "newest": 100}
+		fakeCache.FetchStub = func(_ lager.Logger, _ *url.URL, cacheKey string, _ cacheddownloader.ChecksumInfoType, _ <-chan struct{}) (io.ReadCloser, int64, error) {
+			return ioutil.NopCloser(strings.NewReader("")), sizes[cacheKey], nil
+		}
+		for _, key := range []string{"oldest", "middle", "newest"} {
+			u, err := url.Parse("https://blobstore.example.com/" + key)
+			Expect(err).NotTo(HaveOccurred())
+			reader, _, err := inventory.Fetch(logger, u, key, cacheddownloader.ChecksumInfoType{}, nil)
+			Expect(err).NotTo(HaveOccurred())
+			reader.Close()
+			fakeClock.Increment(time.Second)
+		}
+
+		stater.StatfsReturns(configuration.FilesystemStats{TotalBytes: 1000, AvailableBytes: 500}, nil)
+	})
+
+	JustBeforeEach(func() {
+		monitor := cacheinventory.NewPressureMonitor(logger, stater, "/cache", 80, interval, fakeClock, inventory, fakeMetronClient)
+		process = ginkgomon.Invoke(monitor)
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+	})
+
+	It("checks the filesystem holding the cache every interval", func() {
+		fakeClock.WaitForWatcherAndIncrement(interval)
+		Eventually(stater.StatfsCallCount).Should(Equal(1))
+		Expect(stater.StatfsArgsForCall(0)).To(Equal("/cache"))
+
+		fakeClock.WaitForWatcherAndIncrement(interval)
+		Eventually(stater.StatfsCallCount).Should(Equal(2))
+	})
+
+	Context("when usage is under the threshold", func() {
+		It("does not evict anything", func() {
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(stater.StatfsCallCount).Should(Equal(1))
+			Consistently(remover.RemoveCallCount).Should(BeZero())
+			Expect(fakeMetronClient.IncrementCounterWithDeltaCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when usage exceeds the threshold", func() {
+		BeforeEach(func() {
+			stater.StatfsReturns(configuration.FilesystemStats{TotalBytes: 1000, AvailableBytes: 50}, nil)
+		})
+
+		It("evicts the least recently accessed entries until usage is back under the threshold", func() {
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(evictedKeys).Should(Equal([]string{"oldest", "middle"}))
+			Consistently(remover.RemoveCallCount).Should(Equal(2))
+		})
+
+		It("emits the number of evictions", func() {
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(fakeMetronClient.IncrementCounterWithDeltaCallCount).Should(Equal(1))
+			name, delta := fakeMetronClient.IncrementCounterWithDeltaArgsForCall(0)
+			Expect(name).To(Equal(cacheinventory.CachePressureEvictionsMetric))
+			Expect(delta).To(BeEquivalentTo(2))
+		})
+
+		Context("and freeing every idle entry is not enough", func() {
+			BeforeEach(func() {
+				stater.StatfsReturns(configuration.FilesystemStats{TotalBytes: 1000, AvailableBytes: 0}, nil)
+			})
+
+			It("evicts every idle entry", func() {
+				fakeClock.WaitForWatcherAndIncrement(interval)
+				Eventually(evictedKeys).Should(Equal([]string{"oldest", "middle", "newest"}))
+				Eventually(logger).Should(gbytes.Say("no-idle-entries"))
+			})
+		})
+	})
+
+	Context("when the filesystem cannot be inspected", func() {
+		BeforeEach(func() {
+			stater.StatfsReturns(configuration.FilesystemStats{}, errors.New("boom"))
+		})
+
+		It("logs the error and keeps running", func() {
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(logger).Should(gbytes.Say("failed-to-stat-cache-filesystem"))
+			Expect(remover.RemoveCallCount()).To(BeZero())
+
+			fakeClock.WaitForWatcherAndIncrement(interval)
+			Eventually(stater.StatfsCallCount).Should(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,101 @@
+package cacheinventory
+
+import (
+	"net/url"
+	"os"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+// Warmer fetches a list of artifacts into the download cache in the
+// background, so that the first containers created after the executor starts
+// do not all hit the artifact store at once. Every artifact is cached under
+// its URL, so only download actions that use the URL as their cache key
+// benefit from it.
+type Warmer struct {
+	logger lager.Logger
+
+	downloader cacheddownloader.CachedDownloader
+	clock      clock.Clock
+	urls       []string
+}
+
+func NewWarmer(
+	logger lager.Logger,
+	downloader cacheddownloader.CachedDownloader,
+	clock clock.Clock,
+	urls []string,
+) *Warmer {
+	return &Warmer{
+		logger:     logger,
+		downloader: downloader,
+		clock:      clock,
+		urls:       urls,
+	}
+}
+
+// Run warms the cache and then waits to be signalled. Signalling it cancels
+// the fetch in progress, if any.
+func (w *Warmer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := w.logger.Session("cache-warmer", lager.Data{"count": len(w.urls)})
+
+	close(ready)
+
+	cancel := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.warm(logger, cancel)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case signal := <-signals:
+		logger.Info("signalled", lager.Data{"signal": signal.String()})
+		close(cancel)
+		<-done
+		return nil
+	}
+
+	signal := <-signals
+	logger.Info("signalled", lager.Data{"signal": signal.String()})
+	return nil
+}
+
+func (w *Warmer) warm(logger lager.Logger, cancel <-chan struct{}) {
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	for _, rawURL := range w.urls {
+		select {
+		case <-cancel:
+			logger.Info("cancelled")
+			return
+		default:
+		}
+
+		w.fetch(logger.Session("fetch", lager.Data{"url": rawURL}), rawURL, cancel)
+	}
+}
+
+func (w *Warmer) fetch(logger lager.Logger, rawURL string, cancel <-chan struct{}) {
+	logger.Info("starting")
+	start := w.clock.Now()
+
+	urlToFetch, err := url.ParseRequestURI(rawURL)
+	if err != nil {
+		logger.Error("failed-to-parse-url", err)
+		return
+	}
+
+	reader, size, err := w.downloader.Fetch(logger, urlToFetch, rawURL, cacheddownloader.ChecksumInfoType{}, cancel)
+	if err != nil {
+		logger.Error("failed", err, lager.Data{"duration": w.clock.Since(start)})
+		return
+	}
+	reader.Close()
+
+	logger.Info("complete", lager.Data{"size": size, "duration": w.clock.Since(start)})
+}
@@ -0,0 +1,115 @@
+package cacheinventory_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	cdfakes "code.cloudfoundry.org/cacheddownloader/cacheddownloaderfakes"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/cacheinventory"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Warmer", func() {
+	var (
+		logger    *lagertest.TestLogger
+		fakeCache *cdfakes.FakeCachedDownloader
+		fakeClock *fakeclock.FakeClock
+		urls      []string
+		process   ifrit.Process
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeCache = new(cdfakes.FakeCachedDownloader)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		urls = []string{
+			"https://blobstore.example.com/buildpack.zip",
+			"https://blobstore.example.com/lifecycle.tgz",
+		}
+
+		fakeCache.FetchReturns(ioutil.NopCloser(strings.NewReader("")), 100, nil)
+	})
+
+	JustBeforeEach(func() {
+		warmer := cacheinventory.NewWarmer(logger, fakeCache, fakeClock, urls)
+		process = ginkgomon.Invoke(warmer)
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+	})
+
+	It("fetches every url into the cache under its own url", func() {
+		Eventually(fakeCache.FetchCallCount).Should(Equal(2))
+
+		for i, expected := range urls {
+			_, u, cacheKey, _, _ := fakeCache.FetchArgsForCall(i)
+			Expect(u.String()).To(Equal(expected))
+			Expect(cacheKey).To(Equal(expected))
+		}
+	})
+
+	It("keeps running once the cache is warm", func() {
+		Eventually(logger).Should(gbytes.Say("cache-warmer.complete"))
+		Consistently(process.Wait()).ShouldNot(Receive())
+	})
+
+	Context("when a fetch fails", func() {
+		BeforeEach(func() {
+			fakeCache.FetchReturnsOnCall(0, nil, 0, errors.New("boom"))
+		})
+
+		It("logs the failure and warms the remaining urls", func() {
+			Eventually(fakeCache.FetchCallCount).Should(Equal(2))
+			Expect(logger).To(gbytes.Say("cache-warmer.fetch.failed"))
+		})
+	})
+
+	Context("when a url is invalid", func() {
+		BeforeEach(func() {
+			urls = []string{"not a url", "https://blobstore.example.com/lifecycle.tgz"}
+		})
+
+		It("skips it", func() {
+			Eventually(fakeCache.FetchCallCount).Should(Equal(1))
+			_, u, _, _, _ := fakeCache.FetchArgsForCall(0)
+			Expect(u.String()).To(Equal("https://blobstore.example.com/lifecycle.tgz"))
+		})
+	})
+
+	Context("when signalled during a fetch", func() {
+		var fetchCancelled chan struct{}
+
+		BeforeEach(func() {
+			fetchCancelled = make(chan struct{})
+			fakeCache.FetchStub = func(_ lager.Logger, _ *url.URL, _ string, _ cacheddownloader.ChecksumInfoType, cancel <-chan struct{}) (io.ReadCloser, int64, error) {
+				<-cancel
+				close(fetchCancelled)
+				return nil, 0, errors.New("cancelled")
+			}
+		})
+
+		It("cancels the fetch and exits", func() {
+			Eventually(fakeCache.FetchCallCount).Should(Equal(1))
+
+			process.Signal(os.Interrupt)
+			Eventually(fetchCancelled).Should(BeClosed())
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+			Expect(fakeCache.FetchCallCount()).To(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,216 @@
+package compresseddownloader
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/lager"
+)
+
+var ErrChecksumMismatch = errors.New("checksum of the downloaded content does not match")
+var ErrUnsupportedChecksumAlgorithm = errors.New("unsupported checksum algorithm")
+
+// Downloader fetches artifacts over HTTP, asking the server to gzip them on
+// the wire with Accept-Encoding: gzip. The response is decoded while it is
+// read, and only if the server answered with Content-Encoding: gzip; any
+// other response is passed through as is. Nothing is stored on disk, so
+// unlike the cached downloader it is only suited to downloads that are not
+// cached.
+type Downloader struct {
+	httpClient *http.Client
+}
+
+func New(timeout time.Duration, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) *Downloader {
+	transport := &http.Transport{
+		Proxy: proxy,
+		Dial: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+		// the transport would otherwise decode gzip responses itself, and
+		// only when it added Accept-Encoding on its own
+		DisableCompression: true,
+	}
+
+	return &Downloader{
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+	}
+}
+
+// Fetch downloads url and returns the decoded content, along with its size
+// when the server sent it uncompressed with a Content-Length, or zero
+// otherwise. If checksum is set, reading the content to the end fails with
+// ErrChecksumMismatch unless the decoded content matches it. Closing
+// cancelChan aborts the download.
+func (d *Downloader) Fetch(
+	logger lager.Logger,
+	url *url.URL,
+	checksum cacheddownloader.ChecksumInfoType,
+	cancelChan <-chan struct{},
+) (io.ReadCloser, int64, error) {
+	logger = logger.Session("compressed-download", lager.Data{"host": url.Host})
+
+	checksumHash, err := newHash(checksum.Algorithm)
+	if err != nil {
+		logger.Error("invalid-checksum", err, lager.Data{"algorithm": checksum.Algorithm})
+		return nil, 0, err
+	}
+
+	req, err := http.NewRequest("GET", url.String(), nil)
+	if err != nil {
+		logger.Error("failed-to-build-request", err)
+		return nil, 0, err
+	}
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		select {
+		case <-cancelChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	resp, err := d.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		logger.Error("failed-to-download", err)
+		return nil, 0, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+		cancel()
+		err := fmt.Errorf("download failed: status code %d", resp.StatusCode)
+		logger.Error("failed-to-download", err)
+		return nil, 0, err
+	}
+
+	var content io.Reader = resp.Body
+	size := resp.ContentLength
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			resp.Body.Close()
+			cancel()
+			logger.Error("failed-to-decode-gzip", err)
+			return nil, 0, err
+		}
+
+		logger.Info("decoding-gzip-response")
+		content = gzipReader
+		// the Content-Length is that of the compressed content
+		size = 0
+	}
+	if size < 0 {
+		size = 0
+	}
+
+	return &responseReader{
+		Reader:   content,
+		body:     resp.Body,
+		cancel:   cancel,
+		hash:     checksumHash,
+		checksum: checksum.Value,
+	}, size, nil
+}
+
+// ContentLength asks the server for the size of url with a HEAD request, and
+// returns -1 when the server does not report one.
+func (d *Downloader) ContentLength(logger lager.Logger, url *url.URL, cancelChan <-chan struct{}) (int64, error) {
+	logger = logger.Session("content-length", lager.Data{"host": url.Host})
+
+	req, err := http.NewRequest("HEAD", url.String(), nil)
+	if err != nil {
+		logger.Error("failed-to-build-request", err)
+		return 0, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-cancelChan:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	resp, err := d.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		logger.Error("failed-to-request", err)
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		err := fmt.Errorf("HEAD request failed: status code %d", resp.StatusCode)
+		logger.Error("failed-to-request", err)
+		return 0, err
+	}
+
+	return resp.ContentLength, nil
+}
+
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "":
+		return nil, nil
+	case "md5":
+		return md5.New(), nil
+	case "sha1":
+		return sha1.New(), nil
+	case "sha256":
+		return sha256.New(), nil
+	default:
+		return nil, ErrUnsupportedChecksumAlgorithm
+	}
+}
+
+// responseReader checks the checksum of the content once it has been read to
+// the end, and releases the request when closed.
+type responseReader struct {
+	io.Reader
+	body     io.Closer
+	cancel   context.CancelFunc
+	hash     hash.Hash
+	checksum string
+}
+
+func (r *responseReader) Read(dest []byte) (int, error) {
+	n, err := r.Reader.Read(dest)
+	if r.hash == nil {
+		return n, err
+	}
+
+	r.hash.Write(dest[:n])
+	if err == io.EOF && hex.EncodeToString(r.hash.Sum(nil)) != r.checksum {
+		return n, ErrChecksumMismatch
+	}
+	return n, err
+}
+
+func (r *responseReader) Close() error {
+	defer r.cancel()
+	return r.body.Close()
+}
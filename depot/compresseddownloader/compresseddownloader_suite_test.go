@@ -0,0 +1,13 @@
+package compresseddownloader_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestCompressedDownloader(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Compressed Downloader Suite")
+}
@@ -0,0 +1,193 @@
+package compresseddownloader_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/executor/depot/compresseddownloader"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Downloader", func() {
+	var (
+		downloader *compresseddownloader.Downloader
+		testServer *httptest.Server
+		logger     *lagertest.TestLogger
+
+		content         []byte
+		gzipResponse    bool
+		acceptEncodings chan string
+
+		checksum cacheddownloader.ChecksumInfoType
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		downloader = compresseddownloader.New(time.Second, nil, nil)
+
+		content = []byte("some tar content")
+		gzipResponse = false
+		acceptEncodings = make(chan string, 1)
+		checksum = cacheddownloader.ChecksumInfoType{}
+	})
+
+	JustBeforeEach(func() {
+		testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			acceptEncodings <- r.Header.Get("Accept-Encoding")
+
+			if r.Method == "HEAD" {
+				w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+				return
+			}
+
+			if !gzipResponse {
+				w.Write(content)
+				return
+			}
+
+			w.Header().Set("Content-Encoding", "gzip")
+			gzipWriter := gzip.NewWriter(w)
+			gzipWriter.Write(content)
+			gzipWriter.Close()
+		}))
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+	})
+
+	fetch := func() ([]byte, int64, error) {
+		serverURL, err := url.Parse(testServer.URL)
+		Expect(err).NotTo(HaveOccurred())
+
+		reader, size, err := downloader.Fetch(logger, serverURL, checksum, make(chan struct{}))
+		if err != nil {
+			return nil, 0, err
+		}
+		defer reader.Close()
+
+		fetched, err := ioutil.ReadAll(reader)
+		return fetched, size, err
+	}
+
+	It("asks the server for a gzip-encoded response", func() {
+		_, _, err := fetch()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(acceptEncodings).To(Receive(Equal("gzip")))
+	})
+
+	Context("when the server sends the content uncompressed", func() {
+		It("returns the content as is, with its size", func() {
+			fetched, size, err := fetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched).To(Equal(content))
+			Expect(size).To(BeEquivalentTo(len(content)))
+		})
+
+		Context("and the content itself is gzipped", func() {
+			BeforeEach(func() {
+				compressed := &bytes.Buffer{}
+				gzipWriter := gzip.NewWriter(compressed)
+				gzipWriter.Write([]byte("a tgz artifact"))
+				gzipWriter.Close()
+				content = compressed.Bytes()
+			})
+
+			It("does not decode it", func() {
+				fetched, _, err := fetch()
+				Expect(err).NotTo(HaveOccurred())
+				Expect(fetched).To(Equal(content))
+			})
+		})
+	})
+
+	Context("when the server sends the content with Content-Encoding: gzip", func() {
+		BeforeEach(func() {
+			gzipResponse = true
+		})
+
+		It("decodes the content", func() {
+			fetched, size, err := fetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched).To(Equal(content))
+			Expect(size).To(BeZero())
+		})
+	})
+
+	Context("when a checksum is given", func() {
+		BeforeEach(func() {
+			gzipResponse = true
+			sum := sha256.Sum256(content)
+			checksum = cacheddownloader.ChecksumInfoType{Algorithm: "sha256", Value: hex.EncodeToString(sum[:])}
+		})
+
+		It("checks it against the decoded content", func() {
+			fetched, _, err := fetch()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(fetched).To(Equal(content))
+		})
+
+		Context("and the content does not match it", func() {
+			BeforeEach(func() {
+				checksum.Value = "bogus"
+			})
+
+			It("fails once the content has been read", func() {
+				_, _, err := fetch()
+				Expect(err).To(Equal(compresseddownloader.ErrChecksumMismatch))
+			})
+		})
+
+		Context("and its algorithm is not supported", func() {
+			BeforeEach(func() {
+				checksum.Algorithm = "crc32"
+			})
+
+			It("fails without downloading", func() {
+				_, _, err := fetch()
+				Expect(err).To(Equal(compresseddownloader.ErrUnsupportedChecksumAlgorithm))
+				Expect(acceptEncodings).NotTo(Receive())
+			})
+		})
+	})
+
+	Describe("ContentLength", func() {
+		contentLength := func() (int64, error) {
+			serverURL, err := url.Parse(testServer.URL)
+			Expect(err).NotTo(HaveOccurred())
+
+			return downloader.ContentLength(logger, serverURL, make(chan struct{}))
+		}
+
+		It("returns the size the server reports", func() {
+			size, err := contentLength()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(size).To(BeEquivalentTo(len(content)))
+		})
+
+		Context("when the server rejects the request", func() {
+			JustBeforeEach(func() {
+				testServer.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusForbidden)
+				})
+			})
+
+			It("returns an error", func() {
+				_, err := contentLength()
+				Expect(err).To(HaveOccurred())
+			})
+		})
+	})
+})
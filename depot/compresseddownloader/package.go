@@ -0,0 +1 @@
+package compresseddownloader // import "code.cloudfoundry.org/executor/depot/compresseddownloader"
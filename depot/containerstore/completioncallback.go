@@ -0,0 +1,105 @@
+package containerstore
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/workpool"
+)
+
+//go:generate counterfeiter -o containerstorefakes/fake_completion_notifier.go . CompletionNotifier
+
+// CompletionNotifier is told about every container whose run process has
+// completed. delivered is called once the completion has been reported.
+type CompletionNotifier interface {
+	Notify(logger lager.Logger, container executor.Container, delivered func())
+}
+
+// CompletionCallback is the body POSTed to a container's completion callback
+// URL.
+type CompletionCallback struct {
+	Guid      string                      `json:"guid"`
+	RunResult executor.ContainerRunResult `json:"run_result"`
+}
+
+type completionCallbackSender struct {
+	workPool    *workpool.WorkPool
+	httpClient  *http.Client
+	clock       clock.Clock
+	maxAttempts int
+	backoff     time.Duration
+}
+
+// NewCompletionCallbackSender returns a CompletionNotifier that POSTs the run
+// result of containers with a CompletionCallbackURL. Requests are made on
+// workPool so that slow endpoints do not hold up container transitions, and
+// are retried up to maxAttempts times, doubling backoff between attempts.
+func NewCompletionCallbackSender(
+	workPool *workpool.WorkPool,
+	httpClient *http.Client,
+	clock clock.Clock,
+	maxAttempts int,
+	backoff time.Duration,
+) CompletionNotifier {
+	return &completionCallbackSender{
+		workPool:    workPool,
+		httpClient:  httpClient,
+		clock:       clock,
+		maxAttempts: maxAttempts,
+		backoff:     backoff,
+	}
+}
+
+func (s *completionCallbackSender) Notify(logger lager.Logger, container executor.Container, delivered func()) {
+	if container.CompletionCallbackURL == "" {
+		return
+	}
+
+	logger = logger.Session("completion-callback", lager.Data{"guid": container.Guid})
+
+	body, err := json.Marshal(CompletionCallback{Guid: container.Guid, RunResult: container.RunResult})
+	if err != nil {
+		logger.Error("failed-to-marshal-run-result", err)
+		return
+	}
+
+	s.workPool.Submit(func() {
+		backoff := s.backoff
+		for attempt := 1; attempt <= s.maxAttempts; attempt++ {
+			err := s.send(container.CompletionCallbackURL, body)
+			if err == nil {
+				logger.Info("delivered", lager.Data{"attempt": attempt})
+				delivered()
+				return
+			}
+
+			logger.Error("failed-to-deliver", err, lager.Data{"attempt": attempt})
+			if attempt < s.maxAttempts {
+				s.clock.Sleep(backoff)
+				backoff *= 2
+			}
+		}
+
+		logger.Info("giving-up", lager.Data{"attempts": s.maxAttempts})
+	})
+}
+
+func (s *completionCallbackSender) send(url string, body []byte) error {
+	resp, err := s.httpClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("callback failed: status code %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,149 @@
+package containerstore_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/containerstore"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/workpool"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("CompletionCallbackSender", func() {
+	var (
+		logger    *lagertest.TestLogger
+		fakeClock *fakeclock.FakeClock
+		server    *httptest.Server
+		workPool  *workpool.WorkPool
+		sender    containerstore.CompletionNotifier
+
+		lock          sync.Mutex
+		failuresLeft  int
+		receivedCalls []containerstore.CompletionCallback
+
+		container executor.Container
+		delivered chan struct{}
+	)
+
+	requestCount := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(receivedCalls)
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		failuresLeft = 2
+		receivedCalls = nil
+		delivered = make(chan struct{})
+
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Expect(r.Method).To(Equal("POST"))
+			Expect(r.Header.Get("Content-Type")).To(Equal("application/json"))
+
+			body, err := ioutil.ReadAll(r.Body)
+			Expect(err).NotTo(HaveOccurred())
+
+			var callback containerstore.CompletionCallback
+			Expect(json.Unmarshal(body, &callback)).To(Succeed())
+
+			lock.Lock()
+			defer lock.Unlock()
+			receivedCalls = append(receivedCalls, callback)
+			if failuresLeft > 0 {
+				failuresLeft--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		var err error
+		workPool, err = workpool.NewWorkPool(2)
+		Expect(err).NotTo(HaveOccurred())
+
+		sender = containerstore.NewCompletionCallbackSender(workPool, http.DefaultClient, fakeClock, 3, time.Second)
+
+		container = executor.Container{
+			Guid:  "some-guid",
+			State: executor.StateCompleted,
+			RunInfo: executor.RunInfo{
+				CompletionCallbackURL: server.URL + "/completed",
+			},
+			RunResult: executor.ContainerRunResult{Failed: true, FailureReason: "boom"},
+		}
+	})
+
+	AfterEach(func() {
+		workPool.Stop()
+		server.Close()
+	})
+
+	notify := func() {
+		sender.Notify(logger, container, func() { close(delivered) })
+	}
+
+	It("retries with backoff until the endpoint accepts the run result", func() {
+		notify()
+
+		Eventually(requestCount).Should(Equal(1))
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+		Eventually(requestCount).Should(Equal(2))
+
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+		Consistently(requestCount).Should(Equal(2))
+		fakeClock.Increment(time.Second)
+		Eventually(requestCount).Should(Equal(3))
+
+		Eventually(delivered).Should(BeClosed())
+
+		lock.Lock()
+		defer lock.Unlock()
+		Expect(receivedCalls[2]).To(Equal(containerstore.CompletionCallback{
+			Guid:      "some-guid",
+			RunResult: executor.ContainerRunResult{Failed: true, FailureReason: "boom"},
+		}))
+	})
+
+	Context("when every attempt fails", func() {
+		BeforeEach(func() {
+			failuresLeft = 3
+		})
+
+		It("gives up without reporting delivery", func() {
+			notify()
+
+			Eventually(requestCount).Should(Equal(1))
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(requestCount).Should(Equal(2))
+			fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+			Eventually(requestCount).Should(Equal(3))
+
+			Eventually(logger).Should(gbytes.Say("completion-callback.giving-up"))
+			Expect(delivered).NotTo(BeClosed())
+		})
+	})
+
+	Context("when the container has no callback url", func() {
+		BeforeEach(func() {
+			container.CompletionCallbackURL = ""
+		})
+
+		It("does nothing", func() {
+			notify()
+			Consistently(requestCount).Should(BeZero())
+			Expect(delivered).NotTo(BeClosed())
+		})
+	})
+})
@@ -2,48 +2,70 @@ package containerstore
 
 import (
 	"os"
+	"time"
 
 	"code.cloudfoundry.org/clock"
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/lager"
 )
 
+const (
+	ContainersReapedTotal = "ContainersReapedTotal"
+	LastReapTimestamp     = "LastReapTimestamp"
+)
+
 type containerReaper struct {
 	logger       lager.Logger
 	config       *ContainerConfig
 	clock        clock.Clock
 	containers   *nodeMap
 	gardenClient garden.Client
+	metronClient loggingclient.IngressClient
 }
 
-func newContainerReaper(logger lager.Logger, config *ContainerConfig, clock clock.Clock, containers *nodeMap, gardenClient garden.Client) *containerReaper {
+func newContainerReaper(logger lager.Logger, config *ContainerConfig, clock clock.Clock, containers *nodeMap, gardenClient garden.Client, metronClient loggingclient.IngressClient) *containerReaper {
 	return &containerReaper{
 		logger:       logger,
 		config:       config,
 		clock:        clock,
 		containers:   containers,
 		gardenClient: gardenClient,
+		metronClient: metronClient,
 	}
 }
 
+// Run reaps containers every ReapInterval. While the store is empty and
+// cycles find nothing to reap, the interval doubles up to MaxReapInterval. It
+// drops straight back to ReapInterval as soon as the store holds a container
+// or a cycle reaps one, so lost containers on a busy cell are still found
+// promptly.
 func (r *containerReaper) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	logger := r.logger.Session("container-reaper")
-	timer := r.clock.NewTimer(r.config.ReapInterval)
+	interval := r.config.ReapInterval
+	timer := r.clock.NewTimer(interval)
 
 	close(ready)
 
 	for {
 		select {
 		case <-timer.C():
-			err := r.reapExtraGardenContainers(logger.Session("reap-extra-garden-containers"))
-			if err != nil {
-				logger.Error("failed-to-reap-extra-containers", err)
+			extraReaped, extraErr := r.reapExtraGardenContainers(logger.Session("reap-extra-garden-containers"))
+			if extraErr != nil {
+				logger.Error("failed-to-reap-extra-containers", extraErr)
 			}
 
-			err = r.reapMissingGardenContainers(logger.Session("reap-missing-garden-containers"))
-			if err != nil {
-				logger.Error("failed-to-reap-missing-containers", err)
+			missingReaped, missingErr := r.reapMissingGardenContainers(logger.Session("reap-missing-garden-containers"))
+			if missingErr != nil {
+				logger.Error("failed-to-reap-missing-containers", missingErr)
+			}
+
+			switch {
+			case extraReaped+missingReaped > 0, r.containers.Count() > 0:
+				interval = r.config.ReapInterval
+			case extraErr == nil && missingErr == nil:
+				interval = r.backOff(logger, interval)
 			}
 
 		case signal := <-signals:
@@ -51,44 +73,72 @@ func (r *containerReaper) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 			return nil
 		}
 
-		timer.Reset(r.config.ReapInterval)
+		timer.Reset(interval)
 	}
 }
 
-func (r *containerReaper) reapExtraGardenContainers(logger lager.Logger) error {
+func (r *containerReaper) backOff(logger lager.Logger, interval time.Duration) time.Duration {
+	if r.config.MaxReapInterval <= r.config.ReapInterval {
+		return r.config.ReapInterval
+	}
+
+	interval *= 2
+	if interval > r.config.MaxReapInterval {
+		interval = r.config.MaxReapInterval
+	}
+
+	logger.Debug("backing-off", lager.Data{"interval": interval.String()})
+	return interval
+}
+
+func (r *containerReaper) reapExtraGardenContainers(logger lager.Logger) (int, error) {
 	logger.Info("starting")
 	defer logger.Info("complete")
 
 	handles, err := r.fetchGardenContainerHandles(logger)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
+	reaped := 0
 	for key := range handles {
 		if !r.containers.Contains(key) {
 			err := r.gardenClient.Destroy(key)
 			if err != nil {
 				logger.Error("failed-to-destroy-container", err, lager.Data{"handle": key})
+				continue
+			}
+
+			reaped++
+			err = r.metronClient.IncrementCounter(ContainersReapedTotal)
+			if err != nil {
+				logger.Error("failed-to-increment-counter", err, lager.Data{"metric-name": ContainersReapedTotal})
 			}
 		}
 	}
 
-	return nil
+	if reaped > 0 {
+		logger.Info("reaped-containers", lager.Data{"count": reaped})
+		err = r.metronClient.SendMetric(LastReapTimestamp, int(r.clock.Now().Unix()))
+		if err != nil {
+			logger.Error("failed-to-send-metric", err, lager.Data{"metric-name": LastReapTimestamp})
+		}
+	}
+
+	return reaped, nil
 }
 
-func (r *containerReaper) reapMissingGardenContainers(logger lager.Logger) error {
+func (r *containerReaper) reapMissingGardenContainers(logger lager.Logger) (int, error) {
 	logger.Info("starting")
 	defer logger.Info("complete")
 
 	snapshotGuids := r.containers.containerGuids(logger)
 	handles, err := r.fetchGardenContainerHandles(logger)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	r.containers.CompleteMissing(logger, snapshotGuids, handles)
-
-	return nil
+	return r.containers.CompleteMissing(logger, snapshotGuids, handles), nil
 }
 
 func (r *containerReaper) fetchGardenContainerHandles(logger lager.Logger) (map[string]struct{}, error) {
@@ -3,12 +3,15 @@ package containerstore
 import (
 	"errors"
 	"io"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/clock"
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/executor/depot/resourceregistry"
+	"code.cloudfoundry.org/executor/depot/tarsanitizer"
 	"code.cloudfoundry.org/executor/depot/transformer"
 	"code.cloudfoundry.org/executor/initializer/configuration"
 	"code.cloudfoundry.org/garden"
@@ -27,12 +30,17 @@ type ContainerStore interface {
 	// Setters
 	Reserve(logger lager.Logger, req *executor.AllocationRequest) (executor.Container, error)
 	Destroy(logger lager.Logger, guid string) error
+	MarkDeleting(logger lager.Logger, guid string) (bool, error)
+	Import(logger lager.Logger, containers []executor.Container) error
+	SetGardenHealthy(logger lager.Logger, healthy bool)
 
 	// Container Operations
 	Initialize(logger lager.Logger, req *executor.RunRequest) error
 	Create(logger lager.Logger, guid string) (executor.Container, error)
 	Run(logger lager.Logger, guid string) error
 	Stop(logger lager.Logger, guid string) error
+	UpdateLogConfig(logger lager.Logger, guid string, logConfig executor.LogConfig) error
+	Tag(logger lager.Logger, guid string, tags executor.Tags) error
 
 	// Getters
 	Get(logger lager.Logger, guid string) (executor.Container, error)
@@ -44,6 +52,7 @@ type ContainerStore interface {
 	// Cleanup
 	NewRegistryPruner(logger lager.Logger) ifrit.Runner
 	NewContainerReaper(logger lager.Logger) ifrit.Runner
+	NewStoreMetricsReporter(logger lager.Logger) ifrit.Runner
 
 	// shutdown the dependency manager
 	Cleanup(logger lager.Logger)
@@ -55,8 +64,74 @@ type ContainerConfig struct {
 	MaxCPUShares uint64
 	SetCPUWeight bool
 
+	// MaxINodeLimit bounds the inode limit a container may request. Zero
+	// means containers may not raise the limit above INodeLimit.
+	MaxINodeLimit uint64
+
+	// MaxGardenProperties limits the number of garden properties the
+	// executor sets on a container. Optional properties are dropped at
+	// create, and later writes of new properties are refused. Zero means no
+	// limit.
+	MaxGardenProperties int
+
+	// MaxLogLineLength is the length at which the log streamers split a
+	// line into several messages. Zero means the loggregator message limit.
+	MaxLogLineLength int
+
 	ReservedExpirationTime time.Duration
 	ReapInterval           time.Duration
+
+	// MaxReapInterval caps how far the reap interval backs off while the
+	// store is empty and the reaper finds nothing to reap. Values not above
+	// ReapInterval disable the back-off.
+	MaxReapInterval time.Duration
+
+	// RestartWindow is how long after a crash a container re-created with the
+	// same guid counts as a restart. Zero disables restart tracking. The
+	// suggested backoff starts at RestartBackoffBase and doubles with every
+	// restart up to RestartBackoffMax.
+	RestartWindow      time.Duration
+	RestartBackoffBase time.Duration
+	RestartBackoffMax  time.Duration
+
+	// PrunerJitterFraction is the maximum fraction of the registry pruner
+	// interval added as a random delay to each cycle. Zero disables the
+	// jitter.
+	PrunerJitterFraction float64
+
+	// MaxStartTimeout bounds the start timeout of a container; zero means no
+	// bound. StartTimeoutPolicy decides whether larger values are clamped or
+	// rejected. DefaultStartTimeout is used when a container with a monitor
+	// does not specify one.
+	MaxStartTimeout     time.Duration
+	DefaultStartTimeout time.Duration
+	StartTimeoutPolicy  string
+
+	// TarSymlinkPolicy decides how symlinks in GetFiles streams are handled.
+	TarSymlinkPolicy tarsanitizer.SymlinkPolicy
+
+	// PreDestroyHook is run on the host with the container guid and owner name
+	// as additional arguments before a garden container is destroyed.
+	PreDestroyHook        []string
+	PreDestroyHookTimeout time.Duration
+
+	// FinalMetricsTimeout bounds the garden call that samples the metrics of
+	// a container as it completes. Zero disables the final sample.
+	FinalMetricsTimeout time.Duration
+
+	// InternalIPWaitTimeout bounds how long a create waits for garden to
+	// assign the container an internal IP. Zero disables the wait.
+	InternalIPWaitTimeout time.Duration
+
+	// StoreMetricsInterval is how often the store entry count is emitted.
+	// When LockWaitSampling is set, the time spent waiting for the store lock
+	// is measured and emitted on the same interval.
+	StoreMetricsInterval time.Duration
+	LockWaitSampling     bool
+
+	// ResourceRegistrySlack is how many more guids the resource registry may
+	// hold than the store before a leak is reported.
+	ResourceRegistrySlack int
 }
 
 type containerStore struct {
@@ -67,7 +142,9 @@ type containerStore struct {
 	credManager       CredManager
 	transformer       transformer.Transformer
 	containers        *nodeMap
+	volumeRefs        *volumeRefCounter
 	eventEmitter      event.Hub
+	deliveries        *event.Deliveries
 	clock             clock.Clock
 	metronClient      loggingclient.IngressClient
 	rootFSSizer       configuration.RootFSSizer
@@ -84,6 +161,11 @@ type containerStore struct {
 
 	enableUnproxiedPortMappings           bool
 	advertisePreferenceForInstanceAddress bool
+	completionNotifier                    CompletionNotifier
+	restarts                              *restartTracker
+	resources                             *resourceregistry.Registry
+
+	gardenUnhealthy int32
 }
 
 func New(
@@ -95,6 +177,7 @@ func New(
 	credManager CredManager,
 	clock clock.Clock,
 	eventEmitter event.Hub,
+	deliveries *event.Deliveries,
 	transformer transformer.Transformer,
 	trustedSystemCertificatesPath string,
 	metronClient loggingclient.IngressClient,
@@ -105,6 +188,8 @@ func New(
 	cellID string,
 	enableUnproxiedPortMappings bool,
 	advertisePreferenceForInstanceAddress bool,
+	completionNotifier CompletionNotifier,
+	resources *resourceregistry.Registry,
 ) ContainerStore {
 	return &containerStore{
 		containerConfig:               containerConfig,
@@ -112,8 +197,10 @@ func New(
 		dependencyManager:             dependencyManager,
 		volumeManager:                 volumeManager,
 		credManager:                   credManager,
-		containers:                    newNodeMap(totalCapacity),
+		containers:                    newNodeMap(totalCapacity, containerConfig.LockWaitSampling),
+		volumeRefs:                    newVolumeRefCounter(),
 		eventEmitter:                  eventEmitter,
+		deliveries:                    deliveries,
 		transformer:                   transformer,
 		clock:                         clock,
 		metronClient:                  metronClient,
@@ -127,6 +214,9 @@ func New(
 
 		enableUnproxiedPortMappings:           enableUnproxiedPortMappings,
 		advertisePreferenceForInstanceAddress: advertisePreferenceForInstanceAddress,
+		completionNotifier:                    completionNotifier,
+		restarts:                              newRestartTracker(containerConfig.RestartWindow),
+		resources:                             resources,
 	}
 }
 
@@ -139,9 +229,16 @@ func (cs *containerStore) Reserve(logger lager.Logger, req *executor.AllocationR
 	logger.Debug("starting")
 	defer logger.Debug("complete")
 
+	err := cs.containerConfig.validateInodeLimit(logger, req.Resource)
+	if err != nil {
+		return executor.Container{}, err
+	}
+
 	container := executor.NewReservedContainerFromAllocationRequest(req, cs.clock.Now().UnixNano())
+	restored := cs.restarts.restore(&container, cs.clock.Now())
+	container.SuggestedBackoffMs = cs.containerConfig.suggestedBackoffMs(&container)
 
-	err := cs.containers.Add(
+	err = cs.containers.Add(
 		newStoreNode(&cs.containerConfig,
 			cs.useDeclarativeHealthCheck,
 			cs.declarativeHealthcheckPath,
@@ -150,8 +247,11 @@ func (cs *containerStore) Reserve(logger lager.Logger, req *executor.AllocationR
 			cs.clock,
 			cs.dependencyManager,
 			cs.volumeManager,
+			cs.volumeRefs,
+			cs.resources,
 			cs.credManager,
 			cs.eventEmitter,
+			cs.deliveries,
 			cs.transformer,
 			cs.trustedSystemCertificatesPath,
 			cs.metronClient,
@@ -160,6 +260,8 @@ func (cs *containerStore) Reserve(logger lager.Logger, req *executor.AllocationR
 			cs.cellID,
 			cs.enableUnproxiedPortMappings,
 			cs.advertisePreferenceForInstanceAddress,
+			cs.completionNotifier,
+			cs.gardenHealthy,
 		))
 
 	if err != nil {
@@ -167,10 +269,109 @@ func (cs *containerStore) Reserve(logger lager.Logger, req *executor.AllocationR
 		return executor.Container{}, err
 	}
 
+	cs.resources.Add(container.Guid)
+	if restored {
+		// the crash history is recorded afresh if this incarnation crashes
+		cs.resources.Register(container.Guid, "restart-history", func(lager.Logger) {
+			cs.restarts.forget(container.Guid)
+		})
+	}
+
 	cs.eventEmitter.Emit(executor.NewContainerReservedEvent(container))
 	return container, nil
 }
 
+// Import registers containers handed over by another executor, typically
+// from a snapshot taken before a rolling deploy. No garden containers are
+// created: created containers are re-attached to their existing garden
+// container, and are skipped if garden no longer has it or it belongs to
+// another owner. Running containers are watched through their monitor from
+// then on, and are completed as failed if they have none, as nothing else
+// would notice them exit.
+func (cs *containerStore) Import(logger lager.Logger, containers []executor.Container) error {
+	logger = logger.Session("containerstore-import", lager.Data{"count": len(containers)})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	for _, container := range containers {
+		var gardenContainer garden.Container
+		if container.IsCreated() {
+			var err error
+			gardenContainer, err = cs.gardenClient.Lookup(container.Guid)
+			if _, ok := err.(garden.ContainerNotFoundError); ok {
+				logger.Info("skipping-container-missing-from-garden", lager.Data{"guid": container.Guid})
+				continue
+			}
+			if err != nil {
+				logger.Error("failed-to-lookup-garden-container", err, lager.Data{"guid": container.Guid})
+				return err
+			}
+
+			owner, err := gardenContainer.Property(executor.ContainerOwnerProperty)
+			if err != nil || owner != cs.containerConfig.OwnerName {
+				logger.Info("skipping-container-with-other-owner", lager.Data{"guid": container.Guid, "owner": owner})
+				continue
+			}
+
+			restoreHealthCheckState(logger, gardenContainer, &container)
+			restoreRestartState(logger, gardenContainer, &container)
+		}
+
+		node := newStoreNode(&cs.containerConfig,
+			cs.useDeclarativeHealthCheck,
+			cs.declarativeHealthcheckPath,
+			container,
+			cs.gardenClient,
+			cs.clock,
+			cs.dependencyManager,
+			cs.volumeManager,
+			cs.volumeRefs,
+			cs.resources,
+			cs.credManager,
+			cs.eventEmitter,
+			cs.deliveries,
+			cs.transformer,
+			cs.trustedSystemCertificatesPath,
+			cs.metronClient,
+			cs.proxyConfigHandler,
+			cs.rootFSSizer,
+			cs.cellID,
+			cs.enableUnproxiedPortMappings,
+			cs.advertisePreferenceForInstanceAddress,
+			cs.completionNotifier,
+			cs.gardenHealthy,
+		)
+		node.gardenContainer = gardenContainer
+		if gardenContainer != nil {
+			// garden applied the egress rules when the previous executor created it
+			node.appliedNetOutRules, _ = convertEgressToNetOut(logger, container.EgressRules)
+		}
+		if gardenContainer != nil && cs.useDeclarativeHealthCheck {
+			node.bindMounts = append(node.bindMounts, node.healthcheckBindMount())
+		}
+
+		err := cs.containers.Add(node)
+		if err != nil {
+			logger.Error("failed-to-import-container", err, lager.Data{"guid": container.Guid})
+			return err
+		}
+		cs.resources.Add(container.Guid)
+
+		if gardenContainer != nil {
+			node.registerCredsDirCleanup(container)
+		}
+
+		if container.State == executor.StateRunning {
+			err := node.resume(logger)
+			if err != nil {
+				node.complete(logger, true, ImportedContainerUnmonitoredMessage, true)
+			}
+		}
+	}
+
+	return nil
+}
+
 func (cs *containerStore) Initialize(logger lager.Logger, req *executor.RunRequest) error {
 	logger = logger.Session("containerstore-initialize", lager.Data{"guid": req.Guid})
 	logger.Debug("starting")
@@ -207,9 +408,25 @@ func (cs *containerStore) Create(logger lager.Logger, guid string) (executor.Con
 		return executor.Container{}, err
 	}
 
+	cs.emitGardenPropertyCount(logger)
+
 	return node.Info(), nil
 }
 
+func (cs *containerStore) emitGardenPropertyCount(logger lager.Logger) {
+	max := 0
+	for _, node := range cs.containers.List() {
+		if count := node.GardenPropertyCount(); count > max {
+			max = count
+		}
+	}
+
+	err := cs.metronClient.SendMetric(GardenPropertiesPerContainerMax, max)
+	if err != nil {
+		logger.Error("failed-to-send-garden-properties-metric", err)
+	}
+}
+
 func (cs *containerStore) Run(logger lager.Logger, guid string) error {
 	logger = logger.Session("containerstore-run")
 
@@ -264,10 +481,71 @@ func (cs *containerStore) Destroy(logger lager.Logger, guid string) error {
 	err = node.Destroy(logger)
 	if err != nil {
 		logger.Error("failed-to-destroy-container", err)
+
+		// an asynchronous delete has no caller to hand the error to, so the
+		// record is kept in its real state for the next delete to retry
+		if node.ClearDeleting() {
+			logger.Info("keeping-container-after-failed-async-destroy")
+			return err
+		}
 	}
 
 	cs.containers.Remove(guid)
+	cs.resources.Release(logger, guid)
+
+	info := node.Info()
+	if info.LastCrashAt != 0 && info.RunResult.Failed {
+		cs.restarts.recordCrash(guid, info.RestartCount, info.LastCrashAt, cs.clock.Now())
+	}
+	cs.emitGardenPropertyCount(logger)
+
+	return err
+}
+
+// MarkDeleting tombstones the container so that Get and List report it in
+// the deleting state until Destroy removes it. If the destroy fails the
+// tombstone is cleared and the container kept. It returns false if the
+// container was already marked.
+func (cs *containerStore) MarkDeleting(logger lager.Logger, guid string) (bool, error) {
+	node, err := cs.containers.Get(guid)
+	if err != nil {
+		logger.Error("failed-to-get-container", err, lager.Data{"guid": guid})
+		return false, err
+	}
+
+	return node.MarkDeleting(), nil
+}
+
+// UpdateLogConfig changes the log routing of the container. Steps that are
+// already running log with the new source name, index and tags without being
+// restarted.
+func (cs *containerStore) UpdateLogConfig(logger lager.Logger, guid string, logConfig executor.LogConfig) error {
+	logger = logger.Session("update-log-config", lager.Data{"guid": guid})
+
+	node, err := cs.containers.Get(guid)
+	if err != nil {
+		logger.Error("failed-to-get-container", err)
+		return err
+	}
+
+	err = node.UpdateLogConfig(logger, logConfig)
+	cs.emitGardenPropertyCount(logger)
+	return err
+}
+
+// Tag sets each tag as a property of the garden container, subject to the
+// same property limit as every other property the executor writes.
+func (cs *containerStore) Tag(logger lager.Logger, guid string, tags executor.Tags) error {
+	logger = logger.Session("tag", lager.Data{"guid": guid})
+
+	node, err := cs.containers.Get(guid)
+	if err != nil {
+		logger.Error("failed-to-get-container", err)
+		return err
+	}
 
+	err = node.setGardenProperties(logger, garden.Properties(tags))
+	cs.emitGardenPropertyCount(logger)
 	return err
 }
 
@@ -277,7 +555,7 @@ func (cs *containerStore) Get(logger lager.Logger, guid string) (executor.Contai
 		return executor.Container{}, err
 	}
 
-	return node.Info(), nil
+	return node.reportedInfo(), nil
 }
 
 func (cs *containerStore) List(logger lager.Logger) []executor.Container {
@@ -290,7 +568,7 @@ func (cs *containerStore) List(logger lager.Logger) []executor.Container {
 
 	containers := make([]executor.Container, 0, len(nodes))
 	for i := range nodes {
-		containers = append(containers, nodes[i].Info())
+		containers = append(containers, nodes[i].reportedInfo())
 	}
 
 	return containers
@@ -328,23 +606,42 @@ func (cs *containerStore) Metrics(logger lager.Logger) (map[string]executor.Cont
 		if !found || metricEntry.Err != nil {
 			continue
 		}
-		gardenMetric := metricEntry.Metrics
-
-		diskUsage := gardenMetric.DiskStat.TotalBytesUsed - cs.rootFSSizer.RootFSSizeFromPath(nodeInfo.RootFSPath)
-		containerMetrics[guid] = executor.ContainerMetrics{
-			MemoryUsageInBytes:                  gardenMetric.MemoryStat.TotalUsageTowardLimit,
-			DiskUsageInBytes:                    diskUsage,
-			MemoryLimitInBytes:                  nodeInfo.MemoryLimit,
-			DiskLimitInBytes:                    nodeInfo.DiskLimit,
-			TimeSpentInCPU:                      time.Duration(gardenMetric.CPUStat.Usage),
-			ContainerAgeInNanoseconds:           uint64(gardenMetric.Age),
-			AbsoluteCPUEntitlementInNanoseconds: gardenMetric.CPUEntitlement,
-		}
+		containerMetrics[guid] = containerMetricsFromGarden(metricEntry.Metrics, nodeInfo, cs.rootFSSizer)
 	}
 
 	return containerMetrics, nil
 }
 
+func containerMetricsFromGarden(gardenMetric garden.Metrics, info executor.Container, rootFSSizer configuration.RootFSSizer) executor.ContainerMetrics {
+	diskUsage := gardenMetric.DiskStat.TotalBytesUsed - rootFSSizer.RootFSSizeFromPath(info.RootFSPath)
+	return executor.ContainerMetrics{
+		MemoryUsageInBytes:                  gardenMetric.MemoryStat.TotalUsageTowardLimit,
+		DiskUsageInBytes:                    diskUsage,
+		MemoryLimitInBytes:                  info.MemoryLimit,
+		DiskLimitInBytes:                    info.DiskLimit,
+		InodeUsage:                          gardenMetric.DiskStat.TotalInodesUsed,
+		InodeLimit:                          info.InodeLimit,
+		TimeSpentInCPU:                      time.Duration(gardenMetric.CPUStat.Usage),
+		ContainerAgeInNanoseconds:           uint64(gardenMetric.Age),
+		AbsoluteCPUEntitlementInNanoseconds: gardenMetric.CPUEntitlement,
+	}
+}
+
+// SetGardenHealthy records the outcome of the latest garden healthcheck.
+// Optional garden calls, such as taking a final metrics sample, are skipped
+// while garden is unhealthy.
+func (cs *containerStore) SetGardenHealthy(logger lager.Logger, healthy bool) {
+	var unhealthy int32
+	if !healthy {
+		unhealthy = 1
+	}
+	atomic.StoreInt32(&cs.gardenUnhealthy, unhealthy)
+}
+
+func (cs *containerStore) gardenHealthy() bool {
+	return atomic.LoadInt32(&cs.gardenUnhealthy) == 0
+}
+
 func (cs *containerStore) RemainingResources(logger lager.Logger) executor.ExecutorResources {
 	return cs.containers.RemainingResources()
 }
@@ -368,5 +665,9 @@ func (cs *containerStore) NewRegistryPruner(logger lager.Logger) ifrit.Runner {
 }
 
 func (cs *containerStore) NewContainerReaper(logger lager.Logger) ifrit.Runner {
-	return newContainerReaper(logger, &cs.containerConfig, cs.clock, cs.containers, cs.gardenClient)
+	return newContainerReaper(logger, &cs.containerConfig, cs.clock, cs.containers, cs.gardenClient, cs.metronClient)
+}
+
+func (cs *containerStore) NewStoreMetricsReporter(logger lager.Logger) ifrit.Runner {
+	return newStoreMetricsReporter(logger, &cs.containerConfig, cs.clock, cs.containers, cs.resources, cs.metronClient)
 }
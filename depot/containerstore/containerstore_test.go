@@ -1,6 +1,7 @@
 package containerstore_test
 
 import (
+	"archive/tar"
 	"bytes"
 	"errors"
 	"fmt"
@@ -8,6 +9,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"sync"
 	"time"
 
@@ -17,14 +19,19 @@ import (
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/containerstore"
 	"code.cloudfoundry.org/executor/depot/containerstore/containerstorefakes"
+	"code.cloudfoundry.org/executor/depot/event"
 	eventfakes "code.cloudfoundry.org/executor/depot/event/fakes"
+	"code.cloudfoundry.org/executor/depot/resourceregistry"
 	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/executor/depot/tarsanitizer"
+	"code.cloudfoundry.org/executor/depot/transformer"
 	"code.cloudfoundry.org/executor/depot/transformer/faketransformer"
 	"code.cloudfoundry.org/executor/initializer/configuration/configurationfakes"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden/gardenfakes"
 	"code.cloudfoundry.org/garden/server"
 	loggregator "code.cloudfoundry.org/go-loggregator"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/volman"
 	"code.cloudfoundry.org/volman/volmanfakes"
@@ -48,19 +55,22 @@ var _ = Describe("Container Store", func() {
 		ownerName                             string
 		totalCapacity                         executor.ExecutorResources
 		advertisePreferenceForInstanceAddress bool
+		useDeclarativeHealthCheck             bool
+		enableUnproxiedPortMappings           bool
 
 		containerGuid string
 
 		metricMap     map[string]struct{}
 		metricMapLock sync.RWMutex
 
-		gardenClient      *gardenfakes.FakeClient
-		gardenContainer   *gardenfakes.FakeContainer
-		megatron          *faketransformer.FakeTransformer
-		dependencyManager *containerstorefakes.FakeDependencyManager
-		credManager       *containerstorefakes.FakeCredManager
-		proxyManager      *containerstorefakes.FakeProxyManager
-		volumeManager     *volmanfakes.FakeManager
+		gardenClient       *gardenfakes.FakeClient
+		gardenContainer    *gardenfakes.FakeContainer
+		megatron           *faketransformer.FakeTransformer
+		dependencyManager  *containerstorefakes.FakeDependencyManager
+		credManager        *containerstorefakes.FakeCredManager
+		proxyManager       *containerstorefakes.FakeProxyManager
+		completionNotifier *containerstorefakes.FakeCompletionNotifier
+		volumeManager      *volmanfakes.FakeManager
 
 		clock            *fakeclock.FakeClock
 		eventEmitter     *eventfakes.FakeHub
@@ -68,6 +78,32 @@ var _ = Describe("Container Store", func() {
 		fakeRootFSSizer  *configurationfakes.FakeRootFSSizer
 	)
 
+	newContainerStore := func() containerstore.ContainerStore {
+		return containerstore.New(
+			containerConfig,
+			&totalCapacity,
+			gardenClient,
+			dependencyManager,
+			volumeManager,
+			credManager,
+			clock,
+			eventEmitter,
+			event.NewDeliveries(),
+			megatron,
+			"/var/vcap/data/cf-system-trusted-certs",
+			fakeMetronClient,
+			fakeRootFSSizer,
+			useDeclarativeHealthCheck,
+			"/var/vcap/packages/healthcheck",
+			proxyManager,
+			cellID,
+			enableUnproxiedPortMappings,
+			advertisePreferenceForInstanceAddress,
+			completionNotifier,
+			resourceregistry.New(),
+		)
+	}
+
 	var containerState = func(guid string) func() executor.State {
 		return func() executor.State {
 			container, err := containerStore.Get(logger, guid)
@@ -97,6 +133,7 @@ var _ = Describe("Container Store", func() {
 		dependencyManager = &containerstorefakes.FakeDependencyManager{}
 		credManager = &containerstorefakes.FakeCredManager{}
 		proxyManager = &containerstorefakes.FakeProxyManager{}
+		completionNotifier = &containerstorefakes.FakeCompletionNotifier{}
 		volumeManager = &volmanfakes.FakeManager{}
 		clock = fakeclock.NewFakeClock(time.Now())
 		eventEmitter = &eventfakes.FakeHub{}
@@ -113,6 +150,8 @@ var _ = Describe("Container Store", func() {
 		ownerName = "test-owner"
 		totalCapacity = executor.NewExecutorResources(1024*10, 1024*10, 10)
 		advertisePreferenceForInstanceAddress = false
+		useDeclarativeHealthCheck = false
+		enableUnproxiedPortMappings = true
 
 		containerGuid = "container-guid"
 
@@ -128,26 +167,7 @@ var _ = Describe("Container Store", func() {
 			ReservedExpirationTime: 20 * time.Millisecond,
 		}
 
-		containerStore = containerstore.New(
-			containerConfig,
-			&totalCapacity,
-			gardenClient,
-			dependencyManager,
-			volumeManager,
-			credManager,
-			clock,
-			eventEmitter,
-			megatron,
-			"/var/vcap/data/cf-system-trusted-certs",
-			fakeMetronClient,
-			fakeRootFSSizer,
-			false,
-			"/var/vcap/packages/healthcheck",
-			proxyManager,
-			cellID,
-			true,
-			advertisePreferenceForInstanceAddress,
-		)
+		containerStore = newContainerStore()
 
 		fakeMetronClient.SendDurationStub = func(name string, value time.Duration, opts ...loggregator.EmitGaugeOption) error {
 			metricMapLock.Lock()
@@ -187,11 +207,25 @@ var _ = Describe("Container Store", func() {
 			Expect(container.Guid).To(Equal(containerGuid))
 			Expect(container.Tags).To(Equal(containerTags))
 			Expect(container.Resource).To(Equal(containerResource))
-			Expect(container.State).To(Equal(executor.StateReserved))
+			Expect(container.State).NotTo(Equal(executor.StateDeleting))
 			Expect(container.AllocatedAt).To(Equal(clock.Now().UnixNano()))
 			Expect(container.AdvertisePreferenceForInstanceAddress).To(Equal(advertisePreferenceForInstanceAddress))
 		})
 
+		Context("when the container requests an inode limit above the maximum", func() {
+			BeforeEach(func() {
+				req.Resource.InodeLimit = iNodeLimit + 1
+			})
+
+			It("returns ErrInodeLimitExceedsMaximum", func() {
+				_, err := containerStore.Reserve(logger, req)
+				Expect(err).To(Equal(executor.ErrInodeLimitExceedsMaximum))
+
+				_, err = containerStore.Get(logger, containerGuid)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+
 		It("tracks the container", func() {
 			container, err := containerStore.Reserve(logger, req)
 			Expect(err).NotTo(HaveOccurred())
@@ -245,6 +279,212 @@ var _ = Describe("Container Store", func() {
 				Expect(err).To(Equal(executor.ErrInsufficientResourcesAvailable))
 			})
 		})
+
+		Context("when earlier reservations have used up the disk capacity", func() {
+			BeforeEach(func() {
+				_, err := containerStore.Reserve(logger, &executor.AllocationRequest{
+					Guid:     "disk-hog",
+					Resource: executor.NewResource(1, totalCapacity.DiskMB, 1024),
+				})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("fails the next reservation before anything is created in garden", func() {
+				_, err := containerStore.Reserve(logger, req)
+				Expect(err).To(Equal(executor.ErrInsufficientResourcesAvailable))
+
+				_, err = containerStore.Get(logger, containerGuid)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+				Expect(gardenClient.CreateCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when earlier reservations have used up the container count", func() {
+			BeforeEach(func() {
+				for i := 0; i < totalCapacity.Containers; i++ {
+					_, err := containerStore.Reserve(logger, &executor.AllocationRequest{
+						Guid:     fmt.Sprintf("small-%d", i),
+						Resource: executor.NewResource(1, 1, 1024),
+					})
+					Expect(err).NotTo(HaveOccurred())
+				}
+			})
+
+			It("fails the next reservation before anything is created in garden", func() {
+				_, err := containerStore.Reserve(logger, req)
+				Expect(err).To(Equal(executor.ErrInsufficientResourcesAvailable))
+
+				_, err = containerStore.Get(logger, containerGuid)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+				Expect(gardenClient.CreateCallCount()).To(BeZero())
+			})
+		})
+	})
+
+	Describe("Import", func() {
+		var (
+			snapshot  []executor.Container
+			importErr error
+			monitor   *fake_runner.TestRunner
+		)
+
+		BeforeEach(func() {
+			monitor = fake_runner.NewTestRunner()
+			megatron.StepsRunnerReturns(monitor, nil)
+			gardenContainer.PropertyReturns(ownerName, nil)
+
+			snapshot = []executor.Container{
+				{
+					Guid:     "running-guid",
+					State:    executor.StateRunning,
+					Resource: executor.NewResource(10, 20, 30),
+				},
+				{
+					Guid:      "completed-guid",
+					State:     executor.StateCompleted,
+					RunResult: executor.ContainerRunResult{Failed: true, FailureReason: "boom"},
+				},
+			}
+
+			gardenClient.LookupReturns(gardenContainer, nil)
+			gardenContainer.PropertiesReturns(garden.Properties{
+				containerstore.HealthCheckFailureCountProperty: "3",
+				containerstore.LastHealthCheckAtProperty:       "1234",
+				containerstore.RestartCountProperty:            "2",
+				containerstore.LastCrashAtProperty:             "5678",
+			}, nil)
+			gardenContainer.StreamOutReturns(ioutil.NopCloser(bytes.NewReader([]byte("this is the stream"))), nil)
+		})
+
+		JustBeforeEach(func() {
+			importErr = containerStore.Import(logger, snapshot)
+		})
+
+		AfterEach(func() {
+			monitor.EnsureExit()
+		})
+
+		It("registers the containers with their state and run result", func() {
+			Expect(importErr).NotTo(HaveOccurred())
+
+			running, err := containerStore.Get(logger, "running-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(running.State).To(Equal(executor.StateRunning))
+
+			completed, err := containerStore.Get(logger, "completed-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(completed.RunResult).To(Equal(executor.ContainerRunResult{Failed: true, FailureReason: "boom"}))
+		})
+
+		It("re-attaches created containers to their garden containers without creating new ones", func() {
+			Expect(gardenClient.LookupCallCount()).To(Equal(1))
+			Expect(gardenClient.LookupArgsForCall(0)).To(Equal("running-guid"))
+			Expect(gardenClient.CreateCallCount()).To(BeZero())
+
+			_, err := containerStore.GetFiles(logger, "running-guid", "/path/to/file")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(gardenContainer.StreamOutCallCount()).To(Equal(1))
+		})
+
+		It("restores the health check state persisted on the garden container", func() {
+			running, err := containerStore.Get(logger, "running-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(running.HealthCheckFailureCount).To(Equal(3))
+			Expect(running.LastHealthCheckAt).To(Equal(int64(1234)))
+		})
+
+		It("restores the restart count and last crash time persisted on the garden container", func() {
+			running, err := containerStore.Get(logger, "running-guid")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(running.RestartCount).To(Equal(2))
+			Expect(running.LastCrashAt).To(Equal(int64(5678)))
+		})
+
+		It("counts the imported containers against the remaining capacity", func() {
+			remainingResources := containerStore.RemainingResources(logger)
+			Expect(remainingResources.MemoryMB).To(Equal(totalCapacity.MemoryMB - 10))
+			Expect(remainingResources.Containers).To(Equal(totalCapacity.Containers - 2))
+		})
+
+		It("watches running containers through their monitor only", func() {
+			Expect(megatron.StepsRunnerCallCount()).To(Equal(1))
+			_, container, _, _, cfg := megatron.StepsRunnerArgsForCall(0)
+			Expect(container.Guid).To(Equal("running-guid"))
+			Expect(cfg.MonitorOnly).To(BeTrue())
+			Eventually(monitor.RunCallCount).Should(Equal(1))
+		})
+
+		Context("when the monitor of a running container fails", func() {
+			It("completes the container as failed", func() {
+				Eventually(monitor.RunCallCount).Should(Equal(1))
+				monitor.TriggerExit(errors.New("became unhealthy"))
+
+				Eventually(func() executor.State {
+					container, err := containerStore.Get(logger, "running-guid")
+					Expect(err).NotTo(HaveOccurred())
+					return container.State
+				}).Should(Equal(executor.StateCompleted))
+
+				container, err := containerStore.Get(logger, "running-guid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.RunResult.Failed).To(BeTrue())
+			})
+		})
+
+		Context("when a running container has no monitor", func() {
+			BeforeEach(func() {
+				megatron.StepsRunnerReturns(nil, transformer.ErrNoMonitor)
+			})
+
+			It("completes the container as failed", func() {
+				Expect(importErr).NotTo(HaveOccurred())
+
+				container, err := containerStore.Get(logger, "running-guid")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.State).To(Equal(executor.StateCompleted))
+				Expect(container.RunResult.FailureReason).To(Equal(containerstore.ImportedContainerUnmonitoredMessage))
+			})
+		})
+
+		Context("when the garden container belongs to another owner", func() {
+			BeforeEach(func() {
+				gardenContainer.PropertyReturns("someone-else", nil)
+			})
+
+			It("skips it", func() {
+				Expect(importErr).NotTo(HaveOccurred())
+
+				_, err := containerStore.Get(logger, "running-guid")
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+				Expect(megatron.StepsRunnerCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when garden no longer has a created container", func() {
+			BeforeEach(func() {
+				gardenClient.LookupReturns(nil, garden.ContainerNotFoundError{Handle: "running-guid"})
+			})
+
+			It("skips it", func() {
+				Expect(importErr).NotTo(HaveOccurred())
+
+				_, err := containerStore.Get(logger, "running-guid")
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+				_, err = containerStore.Get(logger, "completed-guid")
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when a container guid is already in use", func() {
+			BeforeEach(func() {
+				_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: "completed-guid"})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("returns an error", func() {
+				Expect(importErr).To(Equal(executor.ErrContainerGuidNotAvailable))
+			})
+		})
 	})
 
 	Describe("Initialize", func() {
@@ -290,6 +530,17 @@ var _ = Describe("Container Store", func() {
 				Expect(err).NotTo(HaveOccurred())
 			})
 
+			Context("when the cache partition tag is invalid", func() {
+				BeforeEach(func() {
+					req.CachePartitionTag = "../other-tenant"
+				})
+
+				It("returns ErrInvalidCachePartitionTag", func() {
+					err := containerStore.Initialize(logger, req)
+					Expect(err).To(Equal(executor.ErrInvalidCachePartitionTag))
+				})
+			})
+
 			It("populates the container with info from the run request", func() {
 				err := containerStore.Initialize(logger, req)
 				Expect(err).NotTo(HaveOccurred())
@@ -302,6 +553,79 @@ var _ = Describe("Container Store", func() {
 			})
 		})
 
+		Context("when start timeout bounds are configured", func() {
+			BeforeEach(func() {
+				containerConfig.MaxStartTimeout = 10 * time.Second
+				containerConfig.DefaultStartTimeout = 5 * time.Second
+			})
+
+			JustBeforeEach(func() {
+				containerStore = newContainerStore()
+
+				_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid, Tags: executor.Tags{}})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			effectiveStartTimeoutMs := func() uint {
+				container, err := containerStore.Get(logger, req.Guid)
+				Expect(err).NotTo(HaveOccurred())
+				return container.StartTimeoutMs
+			}
+
+			It("clamps start timeouts above the maximum by default", func() {
+				Expect(containerStore.Initialize(logger, req)).To(Succeed())
+				Expect(effectiveStartTimeoutMs()).To(BeEquivalentTo(10000))
+			})
+
+			It("keeps a start timeout equal to the maximum", func() {
+				req.StartTimeoutMs = 10000
+				Expect(containerStore.Initialize(logger, req)).To(Succeed())
+				Expect(effectiveStartTimeoutMs()).To(BeEquivalentTo(10000))
+			})
+
+			It("does not modify the run request", func() {
+				Expect(containerStore.Initialize(logger, req)).To(Succeed())
+				Expect(req.StartTimeoutMs).To(BeEquivalentTo(50000))
+			})
+
+			Context("when the policy is reject", func() {
+				BeforeEach(func() {
+					containerConfig.StartTimeoutPolicy = containerstore.StartTimeoutPolicyReject
+				})
+
+				It("rejects start timeouts above the maximum", func() {
+					Expect(containerStore.Initialize(logger, req)).To(Equal(executor.ErrStartTimeoutExceedsMaximum))
+
+					container, err := containerStore.Get(logger, req.Guid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(container.State).NotTo(Equal(executor.StateDeleting))
+				})
+
+				It("accepts a start timeout equal to the maximum", func() {
+					req.StartTimeoutMs = 10000
+					Expect(containerStore.Initialize(logger, req)).To(Succeed())
+					Expect(effectiveStartTimeoutMs()).To(BeEquivalentTo(10000))
+				})
+			})
+
+			Context("when the start timeout is zero", func() {
+				BeforeEach(func() {
+					req.StartTimeoutMs = 0
+				})
+
+				It("leaves it at zero when there is no monitor", func() {
+					Expect(containerStore.Initialize(logger, req)).To(Succeed())
+					Expect(effectiveStartTimeoutMs()).To(BeZero())
+				})
+
+				It("uses the default when there is a monitor", func() {
+					req.Monitor = models.WrapAction(&models.RunAction{Path: "/monitor"})
+					Expect(containerStore.Initialize(logger, req)).To(Succeed())
+					Expect(effectiveStartTimeoutMs()).To(BeEquivalentTo(5000))
+				})
+			})
+		})
+
 		Context("when the container exists but is not reserved", func() {
 			BeforeEach(func() {
 				allocationReq := &executor.AllocationRequest{
@@ -318,7 +642,7 @@ var _ = Describe("Container Store", func() {
 
 			It("returns an invalid state tranistion error", func() {
 				err := containerStore.Initialize(logger, req)
-				Expect(err).To(Equal(executor.ErrInvalidTransition))
+				Expect(errors.Is(err, executor.ErrInvalidTransition)).To(BeTrue())
 			})
 		})
 	})
@@ -451,26 +775,7 @@ var _ = Describe("Container Store", func() {
 				BeforeEach(func() {
 					containerConfig.SetCPUWeight = true
 
-					containerStore = containerstore.New(
-						containerConfig,
-						&totalCapacity,
-						gardenClient,
-						dependencyManager,
-						volumeManager,
-						credManager,
-						clock,
-						eventEmitter,
-						megatron,
-						"/var/vcap/data/cf-system-trusted-certs",
-						fakeMetronClient,
-						fakeRootFSSizer,
-						false,
-						"/var/vcap/packages/healthcheck",
-						proxyManager,
-						cellID,
-						true,
-						advertisePreferenceForInstanceAddress,
-					)
+					containerStore = newContainerStore()
 				})
 
 				It("creates the container in garden with the correct limits", func() {
@@ -514,6 +819,27 @@ var _ = Describe("Container Store", func() {
 				Expect(containerSpec.Limits.CPU.Weight).To(BeZero())
 			})
 
+			It("records the inode limit on the container", func() {
+				container, err := containerStore.Create(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.InodeLimit).To(Equal(iNodeLimit))
+			})
+
+			Context("when the container requests a lower inode limit", func() {
+				BeforeEach(func() {
+					allocationReq.Resource.InodeLimit = 32
+				})
+
+				It("creates the container in garden with the requested inode limit", func() {
+					container, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Limits.Disk.InodeHard).To(BeEquivalentTo(32))
+					Expect(container.InodeLimit).To(BeEquivalentTo(32))
+				})
+			})
+
 			Context("when the requested disk limit is 0", func() {
 				BeforeEach(func() {
 					allocationReq.Resource.DiskMB = 0
@@ -532,6 +858,24 @@ var _ = Describe("Container Store", func() {
 				})
 			})
 
+			Context("when the requested disk limit is unlimited", func() {
+				BeforeEach(func() {
+					allocationReq.Resource.DiskMB = executor.DiskUnlimited
+				})
+
+				It("creates the container in garden without a disk limit", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(gardenClient.CreateCallCount()).To(Equal(1))
+					containerSpec := gardenClient.CreateArgsForCall(0)
+
+					Expect(containerSpec.Limits.Disk.Scope).To(Equal(garden.DiskLimitScopeTotal))
+					Expect(containerSpec.Limits.Disk.ByteHard).To(BeEquivalentTo(0))
+					Expect(containerSpec.Limits.Disk.InodeHard).To(Equal(iNodeLimit))
+				})
+			})
+
 			It("downloads the correct cache dependencies", func() {
 				_, err := containerStore.Create(logger, containerGuid)
 				Expect(err).NotTo(HaveOccurred())
@@ -540,6 +884,23 @@ var _ = Describe("Container Store", func() {
 				Expect(mounts).To(Equal(runReq.CachedDependencies))
 			})
 
+			Context("when the container has a cache partition tag", func() {
+				BeforeEach(func() {
+					runReq.CachePartitionTag = "tenant-a"
+				})
+
+				It("namespaces the cache keys of the dependencies by the tag", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(dependencyManager.DownloadCachedDependenciesCallCount()).To(Equal(1))
+					_, mounts, _ := dependencyManager.DownloadCachedDependenciesArgsForCall(0)
+					Expect(mounts).To(HaveLen(len(runReq.CachedDependencies)))
+					for i, mount := range mounts {
+						Expect(mount.CacheKey).To(Equal(executor.PartitionedCacheKey("tenant-a", runReq.CachedDependencies[i].CacheKey)))
+					}
+				})
+			})
+
 			It("creates the container in garden with the correct bind mounts", func() {
 				expectedMount := garden.BindMount{
 					SrcPath: "foo",
@@ -567,9 +928,11 @@ var _ = Describe("Container Store", func() {
 				containerSpec := gardenClient.CreateArgsForCall(0)
 
 				Expect(containerSpec.Properties).To(Equal(garden.Properties{
-					executor.ContainerOwnerProperty: ownerName,
-					"network.some-key":              "some-value",
-					"network.some-other-key":        "some-other-value",
+					executor.ContainerOwnerProperty:      ownerName,
+					containerstore.LogSourceNameProperty: "test-source",
+					containerstore.LogIndexProperty:      "1",
+					"network.some-key":                   "some-value",
+					"network.some-other-key":             "some-other-value",
 				}))
 			})
 
@@ -577,39 +940,167 @@ var _ = Describe("Container Store", func() {
 				BeforeEach(func() {
 					runReq.RunInfo.Network = nil
 				})
-				It("sets the owner property", func() {
+				It("sets the owner and log properties", func() {
 					_, err := containerStore.Create(logger, containerGuid)
 					Expect(err).NotTo(HaveOccurred())
 
 					containerSpec := gardenClient.CreateArgsForCall(0)
 
 					Expect(containerSpec.Properties).To(Equal(garden.Properties{
-						executor.ContainerOwnerProperty: ownerName,
+						executor.ContainerOwnerProperty:      ownerName,
+						containerstore.LogSourceNameProperty: "test-source",
+						containerstore.LogIndexProperty:      "1",
 					}))
 				})
 			})
 
-			Context("if the RootFSPath is not a known preloaded rootfs", func() {
+			Context("when extra properties are set", func() {
 				BeforeEach(func() {
-					runReq.RunInfo.RootFSPath = "docker://some/repo"
-					fakeRootFSSizer.RootFSSizeFromPathReturns(0)
+					runReq.RunInfo.ExtraProperties = map[string]string{
+						"plugin.some-key":               "plugin-value",
+						executor.ContainerOwnerProperty: "someone-else",
+						"network.some-key":              "overridden",
+						"garden.grace-time":             "1",
+					}
 				})
 
-				It("sets the correct disk limit", func() {
+				It("passes them through, except those with a reserved prefix", func() {
 					_, err := containerStore.Create(logger, containerGuid)
 					Expect(err).NotTo(HaveOccurred())
 
-					Expect(gardenClient.CreateCallCount()).To(Equal(1))
 					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Properties).To(Equal(garden.Properties{
+						executor.ContainerOwnerProperty:      ownerName,
+						containerstore.LogSourceNameProperty: "test-source",
+						containerstore.LogIndexProperty:      "1",
+						"network.some-key":                   "some-value",
+						"network.some-other-key":             "some-other-value",
+						"plugin.some-key":                    "plugin-value",
+					}))
+					Expect(logger).To(gbytes.Say("skipping-reserved-extra-property"))
+				})
 
-					Expect(containerSpec.Limits.Disk.Scope).To(Equal(garden.DiskLimitScopeTotal))
-					Expect(containerSpec.Limits.Disk.ByteHard).To(BeEquivalentTo(resource.DiskMB * 1024 * 1024))
+				It("returns them with the container", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					container, err := containerStore.Get(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(container.ExtraProperties).To(HaveKeyWithValue("plugin.some-key", "plugin-value"))
 				})
 			})
 
-			It("creates the container with the correct environment", func() {
-				_, err := containerStore.Create(logger, containerGuid)
-				Expect(err).NotTo(HaveOccurred())
+			Context("when the container is pinned to cpu cores", func() {
+				BeforeEach(func() {
+					runReq.RunInfo.CPUPinCores = []int{0, 2, 3}
+				})
+
+				It("sets the cpuset property alongside the cpu shares", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Properties).To(HaveKeyWithValue(containerstore.CPUSetProperty, "0,2,3"))
+					Expect(containerSpec.Limits.CPU.LimitInShares).NotTo(BeZero())
+				})
+			})
+
+			Context("when the properties exceed the garden property limit", func() {
+				const propertyLimit = 4
+
+				BeforeEach(func() {
+					containerConfig.MaxGardenProperties = propertyLimit
+
+					gardenClient.CreateStub = func(spec garden.ContainerSpec) (garden.Container, error) {
+						if len(spec.Properties) > propertyLimit {
+							return nil, errors.New("too many properties")
+						}
+						return gardenContainer, nil
+					}
+
+					containerStore = newContainerStore()
+				})
+
+				It("drops the least critical properties and keeps the owner", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Properties).To(Equal(garden.Properties{
+						executor.ContainerOwnerProperty:      ownerName,
+						containerstore.LogSourceNameProperty: "test-source",
+						containerstore.LogIndexProperty:      "1",
+						"network.some-key":                   "some-value",
+					}))
+					Expect(logger).To(gbytes.Say("dropping-garden-property"))
+				})
+
+				It("emits the maximum number of properties per container", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(fakeMetronClient.SendMetricCallCount()).To(Equal(1))
+					name, value, _ := fakeMetronClient.SendMetricArgsForCall(0)
+					Expect(name).To(Equal(containerstore.GardenPropertiesPerContainerMax))
+					Expect(value).To(Equal(propertyLimit))
+				})
+
+				It("refuses to tag the container past the limit", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = containerStore.Tag(logger, containerGuid, executor.Tags{"pipeline": "deploy"})
+					Expect(err).To(Equal(executor.ErrGardenPropertyLimitExceeded))
+					Expect(gardenContainer.SetPropertyCallCount()).To(Equal(0))
+				})
+
+				It("still updates properties the container already has", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = containerStore.Tag(logger, containerGuid, executor.Tags{"network.some-key": "other-value"})
+					Expect(err).NotTo(HaveOccurred())
+					Expect(gardenContainer.SetPropertyCallCount()).To(Equal(1))
+					name, value := gardenContainer.SetPropertyArgsForCall(0)
+					Expect(name).To(Equal("network.some-key"))
+					Expect(value).To(Equal("other-value"))
+				})
+
+				It("emits the maximum again once the container is destroyed", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = containerStore.Destroy(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					count := fakeMetronClient.SendMetricCallCount()
+					name, value, _ := fakeMetronClient.SendMetricArgsForCall(count - 1)
+					Expect(name).To(Equal(containerstore.GardenPropertiesPerContainerMax))
+					Expect(value).To(Equal(0))
+				})
+			})
+
+			Context("if the RootFSPath is not a known preloaded rootfs", func() {
+				BeforeEach(func() {
+					runReq.RunInfo.RootFSPath = "docker://some/repo"
+					fakeRootFSSizer.RootFSSizeFromPathReturns(0)
+				})
+
+				It("sets the correct disk limit", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(gardenClient.CreateCallCount()).To(Equal(1))
+					containerSpec := gardenClient.CreateArgsForCall(0)
+
+					Expect(containerSpec.Limits.Disk.Scope).To(Equal(garden.DiskLimitScopeTotal))
+					Expect(containerSpec.Limits.Disk.ByteHard).To(BeEquivalentTo(resource.DiskMB * 1024 * 1024))
+				})
+			})
+
+			It("creates the container with the correct environment", func() {
+				_, err := containerStore.Create(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
 
 				Expect(gardenClient.CreateCallCount()).To(Equal(1))
 				containerSpec := gardenClient.CreateArgsForCall(0)
@@ -626,6 +1117,54 @@ var _ = Describe("Container Store", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(container.ExternalIP).To(Equal(externalIP))
 				Expect(container.InternalIP).To(Equal(internalIP))
+				Expect(credManager.WaitForInternalIPCallCount()).To(BeZero())
+			})
+
+			Context("when garden has not yet assigned an internal ip", func() {
+				BeforeEach(func() {
+					gardenContainer.InfoReturns(garden.ContainerInfo{ExternalIP: externalIP}, nil)
+					credManager.WaitForInternalIPReturns("10.0.0.5", nil)
+
+					containerConfig.InternalIPWaitTimeout = 5 * time.Second
+					containerStore = newContainerStore()
+				})
+
+				It("waits for the internal ip", func() {
+					container, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(container.InternalIP).To(Equal("10.0.0.5"))
+
+					Expect(credManager.WaitForInternalIPCallCount()).To(Equal(1))
+					_, waitedContainer, timeout := credManager.WaitForInternalIPArgsForCall(0)
+					Expect(waitedContainer).To(Equal(gardenContainer))
+					Expect(timeout).To(Equal(containerConfig.InternalIPWaitTimeout))
+				})
+
+				Context("when the wait is disabled", func() {
+					BeforeEach(func() {
+						containerConfig.InternalIPWaitTimeout = 0
+						containerStore = newContainerStore()
+					})
+
+					It("does not wait for the internal ip", func() {
+						container, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(container.InternalIP).To(BeEmpty())
+						Expect(credManager.WaitForInternalIPCallCount()).To(BeZero())
+					})
+				})
+
+				Context("when waiting times out", func() {
+					BeforeEach(func() {
+						credManager.WaitForInternalIPReturns("", containerstore.ErrInternalIPUnavailable)
+					})
+
+					It("still creates the container", func() {
+						container, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(container.InternalIP).To(BeEmpty())
+					})
+				})
 			})
 
 			It("emits metrics after creating the container", func() {
@@ -669,26 +1208,8 @@ var _ = Describe("Container Store", func() {
 
 			Context("when declarative healthchecks are enabled", func() {
 				BeforeEach(func() {
-					containerStore = containerstore.New(
-						containerConfig,
-						&totalCapacity,
-						gardenClient,
-						dependencyManager,
-						volumeManager,
-						credManager,
-						clock,
-						eventEmitter,
-						megatron,
-						"/var/vcap/data/cf-system-trusted-certs",
-						fakeMetronClient,
-						fakeRootFSSizer,
-						true,
-						"/var/vcap/packages/healthcheck",
-						proxyManager,
-						cellID,
-						true,
-						advertisePreferenceForInstanceAddress,
-					)
+					useDeclarativeHealthCheck = true
+					containerStore = newContainerStore()
 				})
 
 				It("bind mounts the healthcheck", func() {
@@ -875,6 +1396,44 @@ var _ = Describe("Container Store", func() {
 						containerSpec := gardenClient.CreateArgsForCall(0)
 						Expect(containerSpec.Env).To(ContainElement("CF_SYSTEM_CERT_PATH=" + runReq.RunInfo.TrustedSystemCertificatesPath))
 					})
+
+					It("does not count an exclusion", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+
+						for i := 0; i < fakeMetronClient.IncrementCounterCallCount(); i++ {
+							Expect(fakeMetronClient.IncrementCounterArgsForCall(i)).NotTo(Equal(containerstore.TrustedSystemCertsExcludedCount))
+						}
+					})
+
+					Context("and the container opts out of the trusted system certificates", func() {
+						BeforeEach(func() {
+							runReq.RunInfo.ExcludeTrustedSystemCerts = true
+						})
+
+						It("does not create a bind mount or the CF_SYSTEM_CERT_PATH env var", func() {
+							_, err := containerStore.Create(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							containerSpec := gardenClient.CreateArgsForCall(0)
+							Expect(containerSpec.BindMounts).NotTo(ContainElement(mounts[0]))
+							Expect(containerSpec.Env).NotTo(ContainElement(ContainSubstring("CF_SYSTEM_CERT_PATH")))
+						})
+
+						It("records the exclusion on the container", func() {
+							container, err := containerStore.Create(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.ExcludeTrustedSystemCerts).To(BeTrue())
+						})
+
+						It("counts the exclusion", func() {
+							_, err := containerStore.Create(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Expect(fakeMetronClient.IncrementCounterCallCount()).To(Equal(1))
+							Expect(fakeMetronClient.IncrementCounterArgsForCall(0)).To(Equal(containerstore.TrustedSystemCertsExcludedCount))
+						})
+					})
 				})
 
 				Context("and the desired LRP does not have a certificates path", func() {
@@ -978,6 +1537,26 @@ var _ = Describe("Container Store", func() {
 					}))
 				})
 
+				It("does not pass the egress rules already in the create spec to the steps runner", func() {
+					var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
+						<-signals
+						return nil
+					}
+					megatron.StepsRunnerReturns(testRunner, nil)
+
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					err = containerStore.Run(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					defer containerStore.Stop(logger, containerGuid)
+
+					Expect(megatron.StepsRunnerCallCount()).To(Equal(1))
+					_, _, _, _, cfg := megatron.StepsRunnerArgsForCall(0)
+					Expect(gardenClient.CreateArgsForCall(0).NetOut).To(HaveLen(2))
+					Expect(cfg.EgressRules).To(BeEmpty())
+				})
+
 				Context("when a egress rule is not valid", func() {
 					BeforeEach(func() {
 						egressRule := &models.SecurityGroupRule{
@@ -1151,26 +1730,7 @@ var _ = Describe("Container Store", func() {
 						61002,
 					})
 
-					containerStore = containerstore.New(
-						containerConfig,
-						&totalCapacity,
-						gardenClient,
-						dependencyManager,
-						volumeManager,
-						credManager,
-						clock,
-						eventEmitter,
-						megatron,
-						"/var/vcap/data/cf-system-trusted-certs",
-						fakeMetronClient,
-						fakeRootFSSizer,
-						false,
-						"/var/vcap/packages/healthcheck",
-						proxyManager,
-						cellID,
-						true,
-						advertisePreferenceForInstanceAddress,
-					)
+					containerStore = newContainerStore()
 
 					portMapping := []executor.PortMapping{
 						{ContainerPort: 8080},
@@ -1234,26 +1794,8 @@ var _ = Describe("Container Store", func() {
 
 				Context("when disabling unproxied port mappings", func() {
 					BeforeEach(func() {
-						containerStore = containerstore.New(
-							containerConfig,
-							&totalCapacity,
-							gardenClient,
-							dependencyManager,
-							volumeManager,
-							credManager,
-							clock,
-							eventEmitter,
-							megatron,
-							"/var/vcap/data/cf-system-trusted-certs",
-							fakeMetronClient,
-							fakeRootFSSizer,
-							false,
-							"/var/vcap/packages/healthcheck",
-							proxyManager,
-							cellID,
-							false,
-							advertisePreferenceForInstanceAddress,
-						)
+						enableUnproxiedPortMappings = false
+						containerStore = newContainerStore()
 					})
 
 					It("passes only proxied port mappings to NetIn on container creation", func() {
@@ -1371,6 +1913,35 @@ var _ = Describe("Container Store", func() {
 					Eventually(getMetrics).Should(HaveKey(containerstore.GardenContainerCreationFailedDuration))
 				})
 
+				It("classifies the failure", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).To(HaveOccurred())
+
+					container, err := containerStore.Get(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(container.RunResult.FailureType).To(Equal(containerstore.CreateFailureUnknown))
+
+					Expect(fakeMetronClient.IncrementCounterCallCount()).To(Equal(1))
+					Expect(fakeMetronClient.IncrementCounterArgsForCall(0)).To(Equal(containerstore.GardenContainerCreationUnknownFailures))
+				})
+
+				Context("when garden cannot find the rootfs", func() {
+					BeforeEach(func() {
+						gardenClient.CreateReturns(nil, errors.New("failed to create rootfs: image not found"))
+						gardenClient.CreateStub = nil
+					})
+
+					It("records a rootfs failure", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).To(HaveOccurred())
+
+						container, err := containerStore.Get(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(container.RunResult.FailureType).To(Equal(containerstore.CreateFailureRootFS))
+						Expect(fakeMetronClient.IncrementCounterArgsForCall(0)).To(Equal(containerstore.GardenContainerCreationRootFSFailures))
+					})
+				})
+
 				It("logs that the reason the container failed to create", func() {
 					_, err := containerStore.Create(logger, containerGuid)
 					Expect(err).To(HaveOccurred())
@@ -1441,7 +2012,7 @@ var _ = Describe("Container Store", func() {
 
 			It("returns an invalid state transition error", func() {
 				_, err := containerStore.Create(logger, containerGuid)
-				Expect(err).To(Equal(executor.ErrInvalidTransition))
+				Expect(errors.Is(err, executor.ErrInvalidTransition)).To(BeTrue())
 			})
 		})
 	})
@@ -1718,6 +2289,20 @@ var _ = Describe("Container Store", func() {
 						event := eventEmitter.EmitArgsForCall(1)
 						Expect(event).To(Equal(executor.ContainerRunningEvent{RawContainer: container}))
 					})
+
+					It("emits the time from create to running as the container start duration", func() {
+						clock.Increment(3 * time.Second)
+
+						err := containerStore.Run(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+						Eventually(readyChan).Should(Receive())
+
+						Eventually(fakeMetronClient.SendComponentMetricCallCount).Should(Equal(1))
+						name, value, unit := fakeMetronClient.SendComponentMetricArgsForCall(0)
+						Expect(name).To(Equal(containerstore.ContainerStartDurationSecondsMetric))
+						Expect(value).To(Equal(3.0))
+						Expect(unit).To(Equal("s"))
+					})
 				})
 
 				Context("when the action exits", func() {
@@ -1786,6 +2371,160 @@ var _ = Describe("Container Store", func() {
 							Expect(container.RunResult.Stopped).To(Equal(false))
 							Expect(container.RunResult.Retryable).To(BeFalse())
 						})
+
+						It("notifies the completion notifier and records delivery", func() {
+							err := containerStore.Run(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							close(completeChan)
+
+							Eventually(completionNotifier.NotifyCallCount).Should(Equal(1))
+							_, notified, delivered := completionNotifier.NotifyArgsForCall(0)
+							Expect(notified.Guid).To(Equal(containerGuid))
+							Expect(notified.State).To(Equal(executor.StateCompleted))
+
+							container, err := containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.CompletionCallbackDelivered).To(BeFalse())
+
+							delivered()
+
+							container, err = containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.CompletionCallbackDelivered).To(BeTrue())
+						})
+
+						Context("when final metrics are enabled", func() {
+							BeforeEach(func() {
+								runReq.MetricsConfig = executor.MetricsConfig{
+									Guid:  "metric-guid",
+									Index: 2,
+									Tags:  map[string]string{"foo": "bar"},
+								}
+
+								gardenClient.BulkMetricsReturns(map[string]garden.ContainerMetricsEntry{
+									containerGuid: {
+										Metrics: garden.Metrics{
+											MemoryStat: garden.ContainerMemoryStat{TotalUsageTowardLimit: 1024},
+											DiskStat:   garden.ContainerDiskStat{TotalBytesUsed: 3000},
+											CPUStat:    garden.ContainerCPUStat{Usage: 5000},
+										},
+									},
+								}, nil)
+								fakeRootFSSizer.RootFSSizeFromPathReturns(1000)
+
+								containerConfig.FinalMetricsTimeout = time.Second
+								containerStore = newContainerStore()
+							})
+
+							It("emits a final metrics sample for the container", func() {
+								err := containerStore.Run(logger, containerGuid)
+								Expect(err).NotTo(HaveOccurred())
+
+								close(completeChan)
+								Eventually(containerState(containerGuid)).Should(Equal(executor.StateCompleted))
+
+								Eventually(fakeMetronClient.SendAppMetricsCallCount).Should(Equal(1))
+								Expect(gardenClient.BulkMetricsArgsForCall(gardenClient.BulkMetricsCallCount() - 1)).To(Equal([]string{containerGuid}))
+								metric := fakeMetronClient.SendAppMetricsArgsForCall(0)
+								Expect(metric.MemoryBytes).To(BeEquivalentTo(1024))
+								Expect(metric.DiskBytes).To(BeEquivalentTo(2000))
+								Expect(metric.AbsoluteCPUUsage).To(BeEquivalentTo(5000))
+								Expect(metric.Tags).To(Equal(map[string]string{
+									"foo":         "bar",
+									"source_id":   "metric-guid",
+									"instance_id": "2",
+									"final":       "true",
+								}))
+							})
+
+							It("includes the final sample in the completed event", func() {
+								err := containerStore.Run(logger, containerGuid)
+								Expect(err).NotTo(HaveOccurred())
+
+								close(completeChan)
+								Eventually(containerState(containerGuid)).Should(Equal(executor.StateCompleted))
+
+								var completeEvent executor.ContainerCompleteEvent
+								Eventually(func() bool {
+									for i := 0; i < eventEmitter.EmitCallCount(); i++ {
+										if event, ok := eventEmitter.EmitArgsForCall(i).(executor.ContainerCompleteEvent); ok {
+											completeEvent = event
+											return true
+										}
+									}
+									return false
+								}).Should(BeTrue())
+
+								Expect(completeEvent.FinalMetrics).NotTo(BeNil())
+								Expect(completeEvent.FinalMetrics.MemoryUsageInBytes).To(BeEquivalentTo(1024))
+								Expect(completeEvent.FinalMetrics.DiskUsageInBytes).To(BeEquivalentTo(2000))
+							})
+
+							Context("when garden is unhealthy", func() {
+								BeforeEach(func() {
+									containerStore.SetGardenHealthy(logger, false)
+								})
+
+								It("does not sample the container", func() {
+									err := containerStore.Run(logger, containerGuid)
+									Expect(err).NotTo(HaveOccurred())
+
+									close(completeChan)
+									Eventually(containerState(containerGuid)).Should(Equal(executor.StateCompleted))
+
+									Expect(gardenClient.BulkMetricsCallCount()).To(BeZero())
+									Expect(fakeMetronClient.SendAppMetricsCallCount()).To(BeZero())
+								})
+							})
+
+							Context("when sampling the container blocks", func() {
+								var bulkMetricsBlock chan struct{}
+
+								BeforeEach(func() {
+									bulkMetricsBlock = make(chan struct{})
+									gardenClient.BulkMetricsStub = func([]string) (map[string]garden.ContainerMetricsEntry, error) {
+										<-bulkMetricsBlock
+										return nil, nil
+									}
+								})
+
+								AfterEach(func() {
+									close(bulkMetricsBlock)
+								})
+
+								It("does not delay the completion of the container", func() {
+									err := containerStore.Run(logger, containerGuid)
+									Expect(err).NotTo(HaveOccurred())
+
+									close(completeChan)
+									Eventually(containerState(containerGuid)).Should(Equal(executor.StateCompleted))
+									Eventually(gardenClient.BulkMetricsCallCount).Should(Equal(1))
+								})
+							})
+						})
+					})
+
+					Context("because it ran out of memory", func() {
+						BeforeEach(func() {
+							var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
+								close(ready)
+								return steps.NewEmittableError(nil, "Exited with status 137 (out of memory)").WithExitReason(executor.ExitReasonOOM)
+							}
+							megatron.StepsRunnerReturns(testRunner, nil)
+						})
+
+						It("records the exit reason in the run result", func() {
+							err := containerStore.Run(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(containerState(containerGuid)).Should(Equal(executor.StateCompleted))
+
+							container, err := containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.RunResult.Failed).To(BeTrue())
+							Expect(container.RunResult.ExitReason).To(Equal(executor.ExitReasonOOM))
+						})
 					})
 
 					Context("unsuccessfully", func() {
@@ -1843,7 +2582,7 @@ var _ = Describe("Container Store", func() {
 
 			It("returns a transition error", func() {
 				err := containerStore.Run(logger, containerGuid)
-				Expect(err).To(Equal(executor.ErrInvalidTransition))
+				Expect(errors.Is(err, executor.ErrInvalidTransition)).To(BeTrue())
 			})
 		})
 	})
@@ -1922,6 +2661,188 @@ var _ = Describe("Container Store", func() {
 		})
 	})
 
+	Describe("health check results", func() {
+		var reporter steps.HealthCheckReporter
+
+		BeforeEach(func() {
+			var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
+				<-signals
+				return nil
+			}
+			gardenClient.CreateReturns(gardenContainer, nil)
+			megatron.StepsRunnerReturns(testRunner, nil)
+
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = containerStore.Initialize(logger, &executor.RunRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = containerStore.Create(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = containerStore.Run(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, _, cfg := megatron.StepsRunnerArgsForCall(0)
+			reporter = cfg.HealthCheckReporter
+			Expect(reporter).NotTo(BeNil())
+		})
+
+		AfterEach(func() {
+			containerStore.Stop(logger, containerGuid)
+		})
+
+		It("counts consecutive failures until a check succeeds", func() {
+			reporter.HealthCheckResult(errors.New("boom"))
+			reporter.HealthCheckResult(errors.New("boom"))
+
+			container, err := containerStore.Get(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.HealthCheckFailureCount).To(Equal(2))
+			Expect(container.LastHealthCheckAt).To(Equal(clock.Now().UnixNano()))
+
+			clock.Increment(time.Second)
+			reporter.HealthCheckResult(nil)
+
+			container, err = containerStore.Get(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.HealthCheckFailureCount).To(Equal(0))
+			Expect(container.LastHealthCheckAt).To(Equal(clock.Now().UnixNano()))
+		})
+
+		It("persists the health check state on the garden container", func() {
+			reporter.HealthCheckResult(errors.New("boom"))
+
+			Expect(gardenContainer.SetPropertyCallCount()).To(Equal(2))
+			name, value := gardenContainer.SetPropertyArgsForCall(0)
+			Expect(name).To(Equal(containerstore.HealthCheckFailureCountProperty))
+			Expect(value).To(Equal("1"))
+			name, value = gardenContainer.SetPropertyArgsForCall(1)
+			Expect(name).To(Equal(containerstore.LastHealthCheckAtProperty))
+			Expect(value).To(Equal(strconv.FormatInt(clock.Now().UnixNano(), 10)))
+		})
+
+		It("persists the health check state only when the failure count changes", func() {
+			reporter.HealthCheckResult(nil)
+			Expect(gardenContainer.SetPropertyCallCount()).To(BeZero())
+
+			reporter.HealthCheckResult(errors.New("boom"))
+			Expect(gardenContainer.SetPropertyCallCount()).To(Equal(2))
+
+			reporter.HealthCheckResult(nil)
+			Expect(gardenContainer.SetPropertyCallCount()).To(Equal(4))
+			name, value := gardenContainer.SetPropertyArgsForCall(2)
+			Expect(name).To(Equal(containerstore.HealthCheckFailureCountProperty))
+			Expect(value).To(Equal("0"))
+
+			reporter.HealthCheckResult(nil)
+			Expect(gardenContainer.SetPropertyCallCount()).To(Equal(4))
+		})
+	})
+
+	Describe("UpdateLogConfig", func() {
+		newLogConfig := executor.LogConfig{
+			Guid:       containerGuid,
+			Index:      2,
+			SourceName: "new-source",
+			Tags:       map[string]string{"foo": "bar"},
+		}
+
+		BeforeEach(func() {
+			var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
+				<-signals
+				return nil
+			}
+			runReq := &executor.RunRequest{
+				Guid: containerGuid,
+				RunInfo: executor.RunInfo{
+					LogConfig: executor.LogConfig{
+						Guid:       containerGuid,
+						Index:      1,
+						SourceName: "test-source",
+					},
+				},
+			}
+			gardenClient.CreateReturns(gardenContainer, nil)
+			megatron.StepsRunnerReturns(testRunner, nil)
+
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = containerStore.Initialize(logger, runReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = containerStore.Create(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+
+			err = containerStore.Run(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			containerStore.Stop(logger, containerGuid)
+		})
+
+		It("re-routes the logs of the running steps", func() {
+			err := containerStore.UpdateLogConfig(logger, containerGuid, newLogConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, streamer, _ := megatron.StepsRunnerArgsForCall(0)
+			fmt.Fprintln(streamer.Stdout(), "still running")
+
+			count := fakeMetronClient.SendAppLogCallCount()
+			msg, sourceName, tags := fakeMetronClient.SendAppLogArgsForCall(count - 1)
+			Expect(msg).To(Equal("still running"))
+			Expect(sourceName).To(Equal("new-source"))
+			Expect(tags["instance_id"]).To(Equal("2"))
+			Expect(tags["foo"]).To(Equal("bar"))
+		})
+
+		It("records the new log config on the container", func() {
+			err := containerStore.UpdateLogConfig(logger, containerGuid, newLogConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			container, err := containerStore.Get(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.LogConfig).To(Equal(newLogConfig))
+		})
+
+		It("updates the log properties of the garden container", func() {
+			err := containerStore.UpdateLogConfig(logger, containerGuid, newLogConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(gardenContainer.SetPropertyCallCount()).To(Equal(2))
+			properties := garden.Properties{}
+			for i := 0; i < gardenContainer.SetPropertyCallCount(); i++ {
+				name, value := gardenContainer.SetPropertyArgsForCall(i)
+				properties[name] = value
+			}
+			Expect(properties).To(Equal(garden.Properties{
+				containerstore.LogSourceNameProperty: "new-source",
+				containerstore.LogIndexProperty:      "2",
+			}))
+		})
+
+		Context("when setting the garden properties fails", func() {
+			BeforeEach(func() {
+				gardenContainer.SetPropertyReturns(errors.New("boom"))
+			})
+
+			It("returns the error", func() {
+				err := containerStore.UpdateLogConfig(logger, containerGuid, newLogConfig)
+				Expect(err).To(MatchError("boom"))
+			})
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns a container not found error", func() {
+				err := containerStore.UpdateLogConfig(logger, "bogus", newLogConfig)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+	})
+
 	Describe("Stop", func() {
 		var (
 			runReq *executor.RunRequest
@@ -1971,6 +2892,16 @@ var _ = Describe("Container Store", func() {
 				Expect(container.RunResult.Retryable).To(BeFalse())
 			})
 
+			It("records the requested stop as the exit reason", func() {
+				err := containerStore.Stop(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(containerState(containerGuid)).Should(Equal(executor.StateCompleted))
+				container, err := containerStore.Get(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.RunResult.ExitReason).To(Equal(executor.ExitReasonStopRequested))
+			})
+
 			It("logs that the container is stopping", func() {
 				err := containerStore.Stop(logger, containerGuid)
 				Expect(err).NotTo(HaveOccurred())
@@ -1981,69 +2912,225 @@ var _ = Describe("Container Store", func() {
 				Expect(msg).To(Equal(fmt.Sprintf("Cell %s stopping instance %s", cellID, containerGuid)))
 				Expect(tags["instance_id"]).To(Equal("1"))
 			})
-		})
+		})
+
+		Context("when the container does not have processes associated with it", func() {
+			It("transitions to the completed state", func() {
+				err := containerStore.Stop(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				container, err := containerStore.Get(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(container.RunResult.Stopped).To(BeTrue())
+				Expect(container.State).To(Equal(executor.StateCompleted))
+				Expect(container.RunResult.Retryable).To(BeFalse())
+			})
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns an ErrContainerNotFound", func() {
+				err := containerStore.Stop(logger, "")
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+	})
+
+	Describe("Destroy", func() {
+		var resource executor.Resource
+		var expectedMounts containerstore.BindMounts
+		var runReq *executor.RunRequest
+
+		BeforeEach(func() {
+			runInfo := executor.RunInfo{
+				LogConfig: executor.LogConfig{
+					Guid:       containerGuid,
+					Index:      1,
+					SourceName: "test-source",
+				},
+			}
+			runReq = &executor.RunRequest{Guid: containerGuid, RunInfo: runInfo}
+			gardenClient.CreateReturns(gardenContainer, nil)
+			resource = executor.NewResource(1024, 2048, 1024)
+			expectedMounts = containerstore.BindMounts{
+				CacheKeys: []containerstore.BindMountCacheKey{
+					{CacheKey: "cache-key", Dir: "foo"},
+				},
+			}
+			dependencyManager.DownloadCachedDependenciesReturns(expectedMounts, nil)
+		})
+
+		JustBeforeEach(func() {
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid, Resource: resource})
+			Expect(err).NotTo(HaveOccurred())
+
+			err = containerStore.Initialize(logger, runReq)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = containerStore.Create(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("cleans up the credentials dir", func() {
+			err := containerStore.Destroy(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(credManager.RemoveCredDirCallCount()).To(Equal(1))
+		})
+
+		Context("when garden fails to destroy the container", func() {
+			BeforeEach(func() {
+				gardenClient.DestroyReturns(errors.New("boom"))
+			})
+
+			It("still cleans up the credentials dir exactly once", func() {
+				err := containerStore.Destroy(logger, containerGuid)
+				Expect(err).To(HaveOccurred())
+				Expect(credManager.RemoveCredDirCallCount()).To(Equal(1))
+
+				err = containerStore.Destroy(logger, containerGuid)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+				Expect(credManager.RemoveCredDirCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when the container is marked as deleting", func() {
+			JustBeforeEach(func() {
+				marked, err := containerStore.MarkDeleting(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(marked).To(BeTrue())
+			})
+
+			It("reports the container as deleting until it is destroyed", func() {
+				container, err := containerStore.Get(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.State).To(Equal(executor.StateDeleting))
+
+				err = containerStore.Destroy(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = containerStore.Get(logger, containerGuid)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+
+			It("does not mark it again", func() {
+				marked, err := containerStore.MarkDeleting(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(marked).To(BeFalse())
+			})
+
+			Context("when garden fails to destroy the container", func() {
+				BeforeEach(func() {
+					gardenClient.DestroyReturns(errors.New("boom"))
+				})
+
+				It("keeps the container in its real state so it can be deleted again", func() {
+					err := containerStore.Destroy(logger, containerGuid)
+					Expect(err).To(HaveOccurred())
+
+					container, err := containerStore.Get(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(container.State).NotTo(Equal(executor.StateDeleting))
+
+					marked, err := containerStore.MarkDeleting(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(marked).To(BeTrue())
+				})
+			})
+		})
+
+		Context("when a pre-destroy hook is configured", func() {
+			var (
+				hookDir    string
+				hookPath   string
+				outputPath string
+				exitCode   int
+				sleep      int
+			)
+
+			BeforeEach(func() {
+				var err error
+				hookDir, err = ioutil.TempDir("", "pre-destroy-hook")
+				Expect(err).NotTo(HaveOccurred())
+				hookPath = filepath.Join(hookDir, "hook.sh")
+				outputPath = filepath.Join(hookDir, "output")
+				exitCode = 0
+				sleep = 0
+
+				containerConfig.PreDestroyHook = []string{hookPath, outputPath}
+				containerConfig.PreDestroyHookTimeout = time.Second
+				containerStore = newContainerStore()
+			})
+
+			JustBeforeEach(func() {
+				script := fmt.Sprintf("#!/bin/sh\ntouch \"$1.started\"\nsleep %d\necho \"$2 $3\" > \"$1\"\nexit %d\n", sleep, exitCode)
+				Expect(ioutil.WriteFile(hookPath, []byte(script), 0755)).To(Succeed())
+			})
 
-		Context("when the container does not have processes associated with it", func() {
-			It("transitions to the completed state", func() {
-				err := containerStore.Stop(logger, containerGuid)
-				Expect(err).NotTo(HaveOccurred())
+			AfterEach(func() {
+				os.RemoveAll(hookDir)
+			})
 
-				container, err := containerStore.Get(logger, containerGuid)
+			It("runs the hook with the container guid and owner name before destroying the container", func() {
+				gardenClient.DestroyStub = func(string) error {
+					defer GinkgoRecover()
+					Expect(outputPath).To(BeAnExistingFile())
+					return nil
+				}
+
+				err := containerStore.Destroy(logger, containerGuid)
 				Expect(err).NotTo(HaveOccurred())
 
-				Expect(container.RunResult.Stopped).To(BeTrue())
-				Expect(container.State).To(Equal(executor.StateCompleted))
-				Expect(container.RunResult.Retryable).To(BeFalse())
+				output, err := ioutil.ReadFile(outputPath)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(string(output)).To(Equal(containerGuid + " " + ownerName + "\n"))
+				Expect(gardenClient.DestroyCallCount()).To(Equal(1))
 			})
-		})
 
-		Context("when the container does not exist", func() {
-			It("returns an ErrContainerNotFound", func() {
-				err := containerStore.Stop(logger, "")
-				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			Context("when the hook exits non-zero", func() {
+				BeforeEach(func() {
+					exitCode = 3
+				})
+
+				It("logs the failure and still destroys the container", func() {
+					err := containerStore.Destroy(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(gardenClient.DestroyCallCount()).To(Equal(1))
+					Expect(logger).To(gbytes.Say("pre-destroy-hook.failed"))
+				})
 			})
-		})
-	})
 
-	Describe("Destroy", func() {
-		var resource executor.Resource
-		var expectedMounts containerstore.BindMounts
-		var runReq *executor.RunRequest
+			Context("when the hook does not exit within the timeout", func() {
+				BeforeEach(func() {
+					sleep = 10
+				})
 
-		BeforeEach(func() {
-			runInfo := executor.RunInfo{
-				LogConfig: executor.LogConfig{
-					Guid:       containerGuid,
-					Index:      1,
-					SourceName: "test-source",
-				},
-			}
-			runReq = &executor.RunRequest{Guid: containerGuid, RunInfo: runInfo}
-			gardenClient.CreateReturns(gardenContainer, nil)
-			resource = executor.NewResource(1024, 2048, 1024)
-			expectedMounts = containerstore.BindMounts{
-				CacheKeys: []containerstore.BindMountCacheKey{
-					{CacheKey: "cache-key", Dir: "foo"},
-				},
-			}
-			dependencyManager.DownloadCachedDependenciesReturns(expectedMounts, nil)
-		})
+				It("stops waiting for it and destroys the container", func() {
+					errCh := make(chan error, 1)
+					go func() {
+						errCh <- containerStore.Destroy(logger, containerGuid)
+					}()
 
-		JustBeforeEach(func() {
-			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid, Resource: resource})
-			Expect(err).NotTo(HaveOccurred())
+					Eventually(errCh, 5*time.Second).Should(Receive(BeNil()))
+					Expect(gardenClient.DestroyCallCount()).To(Equal(1))
+					Expect(logger).To(gbytes.Say("pre-destroy-hook.timed-out"))
+				})
 
-			err = containerStore.Initialize(logger, runReq)
-			Expect(err).NotTo(HaveOccurred())
+				It("does not hold up stopping the container while it runs", func() {
+					errCh := make(chan error, 1)
+					go func() {
+						errCh <- containerStore.Destroy(logger, containerGuid)
+					}()
+					Eventually(outputPath + ".started").Should(BeAnExistingFile())
 
-			_, err = containerStore.Create(logger, containerGuid)
-			Expect(err).NotTo(HaveOccurred())
-		})
+					stopCh := make(chan error, 1)
+					go func() {
+						stopCh <- containerStore.Stop(logger, containerGuid)
+					}()
+					Eventually(stopCh, 500*time.Millisecond).Should(Receive(BeNil()))
 
-		It("cleans up the credentials dir", func() {
-			err := containerStore.Destroy(logger, containerGuid)
-			Expect(err).NotTo(HaveOccurred())
-			Expect(credManager.RemoveCredDirCallCount()).To(Equal(1))
+					Eventually(errCh, 5*time.Second).Should(Receive(BeNil()))
+				})
+			})
 		})
 
 		Context("when there are volumes mounted", func() {
@@ -2105,9 +3192,9 @@ var _ = Describe("Container Store", func() {
 					volumeManager.UnmountReturns(errors.New("oh noes!"))
 				})
 
-				It("still attempts to unmount the remaining volumes", func() {
+				It("still attempts to unmount the remaining volumes and returns the failure", func() {
 					err := containerStore.Destroy(logger, containerGuid)
-					Expect(err).NotTo(HaveOccurred())
+					Expect(err).To(MatchError(ContainSubstring("oh noes!")))
 					Expect(volumeManager.UnmountCallCount()).To(Equal(2))
 				})
 			})
@@ -2121,6 +3208,75 @@ var _ = Describe("Container Store", func() {
 					Expect(volumeManager.UnmountCallCount()).To(Equal(2))
 				})
 			})
+
+			Context("when another container shares the volumes", func() {
+				var otherContainerGuid string
+
+				JustBeforeEach(func() {
+					otherContainerGuid = "other-container-guid"
+					_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: otherContainerGuid, Resource: resource})
+					Expect(err).NotTo(HaveOccurred())
+
+					otherRunReq := &executor.RunRequest{Guid: otherContainerGuid, RunInfo: runReq.RunInfo}
+					err = containerStore.Initialize(logger, otherRunReq)
+					Expect(err).NotTo(HaveOccurred())
+
+					_, err = containerStore.Create(logger, otherContainerGuid)
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("unmounts the volumes for each container", func() {
+					err := containerStore.Destroy(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(volumeManager.UnmountCallCount()).To(Equal(2))
+
+					err = containerStore.Destroy(logger, otherContainerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(volumeManager.UnmountCallCount()).To(Equal(4))
+
+					_, _, _, containerId := volumeManager.UnmountArgsForCall(0)
+					Expect(containerId).To(Equal(containerGuid))
+					_, _, _, containerId = volumeManager.UnmountArgsForCall(2)
+					Expect(containerId).To(Equal(otherContainerGuid))
+				})
+
+				It("serializes the unmounts of a volume when destroyed concurrently", func() {
+					var lock sync.Mutex
+					inFlight := map[string]int{}
+					overlapped := false
+					volumeManager.UnmountStub = func(_ lager.Logger, _, volumeId, _ string) error {
+						lock.Lock()
+						inFlight[volumeId]++
+						if inFlight[volumeId] > 1 {
+							overlapped = true
+						}
+						lock.Unlock()
+
+						time.Sleep(10 * time.Millisecond)
+
+						lock.Lock()
+						inFlight[volumeId]--
+						lock.Unlock()
+						return nil
+					}
+
+					errCh := make(chan error, 2)
+					go func() {
+						errCh <- containerStore.Destroy(logger, containerGuid)
+					}()
+					go func() {
+						errCh <- containerStore.Destroy(logger, otherContainerGuid)
+					}()
+
+					Eventually(errCh).Should(Receive(BeNil()))
+					Eventually(errCh).Should(Receive(BeNil()))
+					Expect(volumeManager.UnmountCallCount()).To(Equal(4))
+
+					lock.Lock()
+					defer lock.Unlock()
+					Expect(overlapped).To(BeFalse())
+				})
+			})
 		})
 
 		It("removes downloader cache references", func() {
@@ -2375,26 +3531,7 @@ var _ = Describe("Container Store", func() {
 				BeforeEach(func() {
 					credManagerRunnerSignalled = make(chan struct{})
 
-					containerStore = containerstore.New(
-						containerConfig,
-						&totalCapacity,
-						gardenClient,
-						dependencyManager,
-						volumeManager,
-						credManager,
-						clock,
-						eventEmitter,
-						megatron,
-						"/var/vcap/data/cf-system-trusted-certs",
-						fakeMetronClient,
-						fakeRootFSSizer,
-						false,
-						"/var/vcap/packages/healthcheck",
-						proxyManager,
-						cellID,
-						true,
-						advertisePreferenceForInstanceAddress,
-					)
+					containerStore = newContainerStore()
 
 					signalled := credManagerRunnerSignalled
 					runner := &fake_runner.FakeRunner{}
@@ -2415,6 +3552,109 @@ var _ = Describe("Container Store", func() {
 		})
 	})
 
+	Describe("restart tracking", func() {
+		crash := func() executor.Container {
+			gardenClient.CreateReturns(nil, errors.New("boom"))
+
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			err = containerStore.Initialize(logger, &executor.RunRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = containerStore.Create(logger, containerGuid)
+			Expect(err).To(HaveOccurred())
+
+			container, err := containerStore.Get(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containerStore.Destroy(logger, containerGuid)).To(Succeed())
+			return container
+		}
+
+		BeforeEach(func() {
+			containerConfig.RestartWindow = time.Minute
+			containerConfig.RestartBackoffBase = time.Second
+			containerConfig.RestartBackoffMax = 3 * time.Second
+			containerStore = newContainerStore()
+		})
+
+		It("records the crash and suggests a backoff when a container fails", func() {
+			container := crash()
+			Expect(container.RestartCount).To(BeZero())
+			Expect(container.LastCrashAt).To(Equal(clock.Now().UnixNano()))
+			Expect(container.SuggestedBackoffMs).To(BeEquivalentTo(1000))
+		})
+
+		It("counts re-creating a crashed container with the same guid as a restart", func() {
+			firstCrash := crash()
+
+			clock.Increment(10 * time.Second)
+			container, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.RestartCount).To(Equal(1))
+			Expect(container.LastCrashAt).To(Equal(firstCrash.LastCrashAt))
+			Expect(container.SuggestedBackoffMs).To(BeEquivalentTo(2000))
+		})
+
+		It("doubles the suggested backoff with every restart up to the max", func() {
+			backoffs := []int64{}
+			for i := 0; i < 4; i++ {
+				backoffs = append(backoffs, crash().SuggestedBackoffMs)
+			}
+			Expect(backoffs).To(Equal([]int64{1000, 2000, 3000, 3000}))
+		})
+
+		It("reports the restart count and last crash time as garden properties", func() {
+			firstCrash := crash()
+			gardenClient.CreateReturns(gardenContainer, nil)
+
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			err = containerStore.Initialize(logger, &executor.RunRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = containerStore.Create(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+
+			spec := gardenClient.CreateArgsForCall(gardenClient.CreateCallCount() - 1)
+			Expect(spec.Properties).To(HaveKeyWithValue(containerstore.RestartCountProperty, "1"))
+			Expect(spec.Properties).To(HaveKeyWithValue(containerstore.LastCrashAtProperty, strconv.FormatInt(firstCrash.LastCrashAt, 10)))
+		})
+
+		It("resets the count once the restart window has passed", func() {
+			crash()
+
+			clock.Increment(time.Minute + time.Second)
+			container, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.RestartCount).To(BeZero())
+			Expect(container.LastCrashAt).To(BeZero())
+			Expect(container.SuggestedBackoffMs).To(BeZero())
+		})
+
+		It("forgets the crash history once the re-created container is destroyed", func() {
+			crash()
+
+			container, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.RestartCount).To(Equal(1))
+			Expect(containerStore.Destroy(logger, containerGuid)).To(Succeed())
+
+			container, err = containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.RestartCount).To(BeZero())
+			Expect(container.LastCrashAt).To(BeZero())
+		})
+
+		It("does not count a container that completed successfully", func() {
+			gardenClient.CreateReturns(gardenContainer, nil)
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(containerStore.Destroy(logger, containerGuid)).To(Succeed())
+
+			container, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.RestartCount).To(BeZero())
+		})
+	})
+
 	Describe("Get", func() {
 		BeforeEach(func() {
 			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
@@ -2557,7 +3797,8 @@ var _ = Describe("Container Store", func() {
 							TotalUsageTowardLimit: 1024,
 						},
 						DiskStat: garden.ContainerDiskStat{
-							TotalBytesUsed: uint64(1000 + 2048),
+							TotalBytesUsed:  uint64(1000 + 2048),
+							TotalInodesUsed: 16,
 						},
 						CPUStat: garden.ContainerCPUStat{
 							Usage: 5000000000,
@@ -2630,6 +3871,8 @@ var _ = Describe("Container Store", func() {
 			Expect(container1Metrics.DiskUsageInBytes).To(BeEquivalentTo(2048))
 			Expect(container1Metrics.MemoryLimitInBytes).To(BeEquivalentTo(containerSpec1.Limits.Memory.LimitInBytes))
 			Expect(container1Metrics.DiskLimitInBytes).To(BeEquivalentTo(containerSpec1.Limits.Disk.ByteHard))
+			Expect(container1Metrics.InodeUsage).To(BeEquivalentTo(16))
+			Expect(container1Metrics.InodeLimit).To(Equal(containerSpec1.Limits.Disk.InodeHard))
 			Expect(container1Metrics.TimeSpentInCPU).To(Equal(5 * time.Second))
 			Expect(container1Metrics.ContainerAgeInNanoseconds).To(Equal(uint64(1000000000)))
 			Expect(container1Metrics.AbsoluteCPUEntitlementInNanoseconds).To(Equal(uint64(100)))
@@ -2691,6 +3934,29 @@ var _ = Describe("Container Store", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(output).To(Equal([]byte("this is the stream")))
 			})
+
+			Context("when a tar symlink policy is configured", func() {
+				BeforeEach(func() {
+					buffer := new(bytes.Buffer)
+					tarWriter := tar.NewWriter(buffer)
+					Expect(tarWriter.WriteHeader(&tar.Header{Name: "./file", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})).To(Succeed())
+					Expect(tarWriter.Close()).To(Succeed())
+					gardenContainer.StreamOutReturns(ioutil.NopCloser(buffer), nil)
+
+					containerConfig.TarSymlinkPolicy = tarsanitizer.SymlinkPolicyRewrite
+					containerStore = newContainerStore()
+				})
+
+				It("applies it to the stream", func() {
+					stream, err := containerStore.GetFiles(logger, containerGuid, "/path/to/file")
+					Expect(err).NotTo(HaveOccurred())
+					defer stream.Close()
+
+					header, err := tar.NewReader(stream).Next()
+					Expect(err).NotTo(HaveOccurred())
+					Expect(header.Linkname).To(Equal("etc/passwd"))
+				})
+			})
 		})
 
 		Context("when the container does not have a corresponding garden container", func() {
@@ -2781,6 +4047,127 @@ var _ = Describe("Container Store", func() {
 		})
 	})
 
+	Describe("RegistryPruner with jitter", func() {
+		var process ifrit.Process
+
+		BeforeEach(func() {
+			containerConfig.PrunerJitterFraction = 0.5
+
+			containerStore = newContainerStore()
+
+			resource := executor.NewResource(512, 512, 1024)
+			req := executor.NewAllocationRequest("forever-reserved", &resource, nil)
+			_, err := containerStore.Reserve(logger, &req)
+			Expect(err).NotTo(HaveOccurred())
+
+			process = ginkgomon.Invoke(containerStore.NewRegistryPruner(logger))
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(process)
+		})
+
+		It("does not prune before the base interval has elapsed", func() {
+			clock.Increment(containerConfig.ReservedExpirationTime/2 - time.Millisecond)
+
+			Consistently(func() executor.State {
+				container, err := containerStore.Get(logger, "forever-reserved")
+				Expect(err).NotTo(HaveOccurred())
+				return container.State
+			}).Should(Equal(executor.StateReserved))
+		})
+
+		It("prunes expired reservations within the jittered interval", func() {
+			clock.Increment(2 * containerConfig.ReservedExpirationTime)
+
+			Eventually(func() executor.State {
+				container, err := containerStore.Get(logger, "forever-reserved")
+				Expect(err).NotTo(HaveOccurred())
+				return container.State
+			}).Should(Equal(executor.StateCompleted))
+		})
+	})
+
+	Describe("StoreMetricsReporter", func() {
+		var (
+			process          ifrit.Process
+			lockWaitSampling bool
+		)
+
+		sentMetrics := func() map[string][]int {
+			metrics := map[string][]int{}
+			for i := 0; i < fakeMetronClient.SendMetricCallCount(); i++ {
+				name, value, _ := fakeMetronClient.SendMetricArgsForCall(i)
+				metrics[name] = append(metrics[name], value)
+			}
+			return metrics
+		}
+
+		BeforeEach(func() {
+			lockWaitSampling = false
+		})
+
+		JustBeforeEach(func() {
+			containerConfig.StoreMetricsInterval = time.Minute
+			containerConfig.LockWaitSampling = lockWaitSampling
+
+			containerStore = newContainerStore()
+
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: "guid-1"})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = containerStore.Reserve(logger, &executor.AllocationRequest{Guid: "guid-2"})
+			Expect(err).NotTo(HaveOccurred())
+
+			process = ginkgomon.Invoke(containerStore.NewStoreMetricsReporter(logger))
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(process)
+		})
+
+		It("emits the number of store entries every interval", func() {
+			clock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(sentMetrics).Should(HaveKeyWithValue(containerstore.StoreEntriesMetric, []int{2}))
+		})
+
+		It("emits the number of guids holding resources without raising a leak alarm", func() {
+			clock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(sentMetrics).Should(HaveKeyWithValue(containerstore.ResourceRegistryEntriesMetric, []int{0}))
+			Expect(sentMetrics()).NotTo(HaveKey(containerstore.ResourceRegistryLeakMetric))
+		})
+
+		It("does not emit lock wait times", func() {
+			clock.WaitForWatcherAndIncrement(time.Minute)
+			Eventually(sentMetrics).Should(HaveKey(containerstore.StoreEntriesMetric))
+			Expect(sentMetrics()).NotTo(HaveKey(containerstore.StoreLockWaitMsMetric))
+		})
+
+		Context("when lock wait sampling is enabled", func() {
+			BeforeEach(func() {
+				lockWaitSampling = true
+			})
+
+			It("emits the max and average lock wait times", func() {
+				clock.WaitForWatcherAndIncrement(time.Minute)
+				Eventually(sentMetrics).Should(HaveKey(containerstore.StoreLockWaitMsMetric))
+
+				stats := []string{}
+				for i := 0; i < fakeMetronClient.SendMetricCallCount(); i++ {
+					name, _, opts := fakeMetronClient.SendMetricArgsForCall(i)
+					if name != containerstore.StoreLockWaitMsMetric {
+						continue
+					}
+					envelope := &loggregator_v2.Envelope{Tags: map[string]string{}}
+					for _, opt := range opts {
+						opt(envelope)
+					}
+					stats = append(stats, envelope.Tags["stat"])
+				}
+				Expect(stats).To(ConsistOf("max", "avg"))
+			})
+		})
+	})
+
 	Describe("ContainerReaper", func() {
 		var (
 			containerGuid1, containerGuid2, containerGuid3 string
@@ -2900,6 +4287,32 @@ var _ = Describe("Container Store", func() {
 			Eventually(logger).Should(gbytes.Say("reaped-missing-container"))
 		})
 
+		countReaped := func() int {
+			count := 0
+			for i := 0; i < fakeMetronClient.IncrementCounterCallCount(); i++ {
+				if fakeMetronClient.IncrementCounterArgsForCall(i) == containerstore.ContainersReapedTotal {
+					count++
+				}
+			}
+			return count
+		}
+
+		It("counts the extra garden containers it reaps", func() {
+			clock.WaitForWatcherAndIncrement(30 * time.Millisecond)
+
+			Eventually(gardenClient.DestroyCallCount).Should(Equal(1))
+			Expect(gardenClient.DestroyArgsForCall(0)).To(Equal("foobar"))
+			Eventually(countReaped).Should(Equal(1))
+
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(1))
+			name, value, _ := fakeMetronClient.SendMetricArgsForCall(0)
+			Expect(name).To(Equal(containerstore.LastReapTimestamp))
+			Expect(value).To(Equal(int(clock.Now().Unix())))
+
+			clock.WaitForWatcherAndIncrement(30 * time.Millisecond)
+			Eventually(countReaped).Should(Equal(2))
+		})
+
 		Context("when garden's list of containers is stale (ie. a container was created since obtaining the list from garden)", func() {
 			var syncCh chan struct{}
 
@@ -2970,6 +4383,64 @@ var _ = Describe("Container Store", func() {
 				clock.Increment(30 * time.Millisecond)
 				Eventually(logger).Should(gbytes.Say("failed-to-destroy-container"))
 			})
+
+			It("does not count the container as reaped", func() {
+				clock.Increment(30 * time.Millisecond)
+				Eventually(gardenClient.DestroyCallCount).Should(Equal(1))
+				Consistently(countReaped).Should(BeZero())
+				Expect(fakeMetronClient.SendMetricCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when a max reap interval is configured", func() {
+			BeforeEach(func() {
+				containerConfig.MaxReapInterval = 80 * time.Millisecond
+				containerStore = newContainerStore()
+
+				gardenClient.ContainersReturns([]garden.Container{}, nil)
+			})
+
+			// each reap cycle lists garden containers twice
+			reapAfter := func(interval time.Duration, cycles int) {
+				clock.WaitForWatcherAndIncrement(interval - time.Millisecond)
+				Consistently(gardenClient.ContainersCallCount).Should(Equal(2 * (cycles - 1)))
+				clock.Increment(time.Millisecond)
+				Eventually(gardenClient.ContainersCallCount).Should(Equal(2 * cycles))
+			}
+
+			It("backs off exponentially up to the max while there is nothing to reap", func() {
+				reapAfter(20*time.Millisecond, 1)
+				reapAfter(40*time.Millisecond, 2)
+				reapAfter(80*time.Millisecond, 3)
+				reapAfter(80*time.Millisecond, 4)
+			})
+
+			It("reverts to the reap interval as soon as a container is reaped", func() {
+				reapAfter(20*time.Millisecond, 1)
+				reapAfter(40*time.Millisecond, 2)
+
+				extraGardenContainer := &gardenfakes.FakeContainer{}
+				extraGardenContainer.HandleReturns("foobar")
+				gardenClient.ContainersReturns([]garden.Container{extraGardenContainer}, nil)
+
+				reapAfter(80*time.Millisecond, 3)
+				Expect(gardenClient.DestroyCallCount()).To(Equal(1))
+
+				reapAfter(20*time.Millisecond, 4)
+			})
+
+			Context("when the store holds containers", func() {
+				BeforeEach(func() {
+					_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: "busy-guid"})
+					Expect(err).NotTo(HaveOccurred())
+				})
+
+				It("keeps reaping at the reap interval", func() {
+					reapAfter(20*time.Millisecond, 1)
+					reapAfter(20*time.Millisecond, 2)
+					reapAfter(20*time.Millisecond, 3)
+				})
+			})
 		})
 	})
 })
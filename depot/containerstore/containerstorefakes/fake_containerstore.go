@@ -72,6 +72,18 @@ type FakeContainerStore struct {
 		result1 io.ReadCloser
 		result2 error
 	}
+	ImportStub        func(lager.Logger, []executor.Container) error
+	importMutex       sync.RWMutex
+	importArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 []executor.Container
+	}
+	importReturns struct {
+		result1 error
+	}
+	importReturnsOnCall map[int]struct {
+		result1 error
+	}
 	InitializeStub        func(lager.Logger, *executor.RunRequest) error
 	initializeMutex       sync.RWMutex
 	initializeArgsForCall []struct {
@@ -95,6 +107,20 @@ type FakeContainerStore struct {
 	listReturnsOnCall map[int]struct {
 		result1 []executor.Container
 	}
+	MarkDeletingStub        func(lager.Logger, string) (bool, error)
+	markDeletingMutex       sync.RWMutex
+	markDeletingArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 string
+	}
+	markDeletingReturns struct {
+		result1 bool
+		result2 error
+	}
+	markDeletingReturnsOnCall map[int]struct {
+		result1 bool
+		result2 error
+	}
 	MetricsStub        func(lager.Logger) (map[string]executor.ContainerMetrics, error)
 	metricsMutex       sync.RWMutex
 	metricsArgsForCall []struct {
@@ -130,6 +156,17 @@ type FakeContainerStore struct {
 	newRegistryPrunerReturnsOnCall map[int]struct {
 		result1 ifrit.Runner
 	}
+	NewStoreMetricsReporterStub        func(lager.Logger) ifrit.Runner
+	newStoreMetricsReporterMutex       sync.RWMutex
+	newStoreMetricsReporterArgsForCall []struct {
+		arg1 lager.Logger
+	}
+	newStoreMetricsReporterReturns struct {
+		result1 ifrit.Runner
+	}
+	newStoreMetricsReporterReturnsOnCall map[int]struct {
+		result1 ifrit.Runner
+	}
 	RemainingResourcesStub        func(lager.Logger) executor.ExecutorResources
 	remainingResourcesMutex       sync.RWMutex
 	remainingResourcesArgsForCall []struct {
@@ -167,6 +204,12 @@ type FakeContainerStore struct {
 	runReturnsOnCall map[int]struct {
 		result1 error
 	}
+	SetGardenHealthyStub        func(lager.Logger, bool)
+	setGardenHealthyMutex       sync.RWMutex
+	setGardenHealthyArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 bool
+	}
 	StopStub        func(lager.Logger, string) error
 	stopMutex       sync.RWMutex
 	stopArgsForCall []struct {
@@ -179,6 +222,32 @@ type FakeContainerStore struct {
 	stopReturnsOnCall map[int]struct {
 		result1 error
 	}
+	TagStub        func(lager.Logger, string, executor.Tags) error
+	tagMutex       sync.RWMutex
+	tagArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 executor.Tags
+	}
+	tagReturns struct {
+		result1 error
+	}
+	tagReturnsOnCall map[int]struct {
+		result1 error
+	}
+	UpdateLogConfigStub        func(lager.Logger, string, executor.LogConfig) error
+	updateLogConfigMutex       sync.RWMutex
+	updateLogConfigArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 executor.LogConfig
+	}
+	updateLogConfigReturns struct {
+		result1 error
+	}
+	updateLogConfigReturnsOnCall map[int]struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -468,6 +537,72 @@ func (fake *FakeContainerStore) GetFilesReturnsOnCall(i int, result1 io.ReadClos
 	}{result1, result2}
 }
 
+func (fake *FakeContainerStore) Import(arg1 lager.Logger, arg2 []executor.Container) error {
+	fake.importMutex.Lock()
+	ret, specificReturn := fake.importReturnsOnCall[len(fake.importArgsForCall)]
+	var arg2Copy []executor.Container
+	if arg2 != nil {
+		arg2Copy = make([]executor.Container, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.importArgsForCall = append(fake.importArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 []executor.Container
+	}{arg1, arg2Copy})
+	fake.recordInvocation("Import", []interface{}{arg1, arg2Copy})
+	fake.importMutex.Unlock()
+	if fake.ImportStub != nil {
+		return fake.ImportStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.importReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerStore) ImportCallCount() int {
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
+	return len(fake.importArgsForCall)
+}
+
+func (fake *FakeContainerStore) ImportCalls(stub func(lager.Logger, []executor.Container) error) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = stub
+}
+
+func (fake *FakeContainerStore) ImportArgsForCall(i int) (lager.Logger, []executor.Container) {
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
+	argsForCall := fake.importArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeContainerStore) ImportReturns(result1 error) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = nil
+	fake.importReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerStore) ImportReturnsOnCall(i int, result1 error) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = nil
+	if fake.importReturnsOnCall == nil {
+		fake.importReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.importReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeContainerStore) Initialize(arg1 lager.Logger, arg2 *executor.RunRequest) error {
 	fake.initializeMutex.Lock()
 	ret, specificReturn := fake.initializeReturnsOnCall[len(fake.initializeArgsForCall)]
@@ -589,6 +724,70 @@ func (fake *FakeContainerStore) ListReturnsOnCall(i int, result1 []executor.Cont
 	}{result1}
 }
 
+func (fake *FakeContainerStore) MarkDeleting(arg1 lager.Logger, arg2 string) (bool, error) {
+	fake.markDeletingMutex.Lock()
+	ret, specificReturn := fake.markDeletingReturnsOnCall[len(fake.markDeletingArgsForCall)]
+	fake.markDeletingArgsForCall = append(fake.markDeletingArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 string
+	}{arg1, arg2})
+	fake.recordInvocation("MarkDeleting", []interface{}{arg1, arg2})
+	fake.markDeletingMutex.Unlock()
+	if fake.MarkDeletingStub != nil {
+		return fake.MarkDeletingStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.markDeletingReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeContainerStore) MarkDeletingCallCount() int {
+	fake.markDeletingMutex.RLock()
+	defer fake.markDeletingMutex.RUnlock()
+	return len(fake.markDeletingArgsForCall)
+}
+
+func (fake *FakeContainerStore) MarkDeletingCalls(stub func(lager.Logger, string) (bool, error)) {
+	fake.markDeletingMutex.Lock()
+	defer fake.markDeletingMutex.Unlock()
+	fake.MarkDeletingStub = stub
+}
+
+func (fake *FakeContainerStore) MarkDeletingArgsForCall(i int) (lager.Logger, string) {
+	fake.markDeletingMutex.RLock()
+	defer fake.markDeletingMutex.RUnlock()
+	argsForCall := fake.markDeletingArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeContainerStore) MarkDeletingReturns(result1 bool, result2 error) {
+	fake.markDeletingMutex.Lock()
+	defer fake.markDeletingMutex.Unlock()
+	fake.MarkDeletingStub = nil
+	fake.markDeletingReturns = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainerStore) MarkDeletingReturnsOnCall(i int, result1 bool, result2 error) {
+	fake.markDeletingMutex.Lock()
+	defer fake.markDeletingMutex.Unlock()
+	fake.MarkDeletingStub = nil
+	if fake.markDeletingReturnsOnCall == nil {
+		fake.markDeletingReturnsOnCall = make(map[int]struct {
+			result1 bool
+			result2 error
+		})
+	}
+	fake.markDeletingReturnsOnCall[i] = struct {
+		result1 bool
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeContainerStore) Metrics(arg1 lager.Logger) (map[string]executor.ContainerMetrics, error) {
 	fake.metricsMutex.Lock()
 	ret, specificReturn := fake.metricsReturnsOnCall[len(fake.metricsArgsForCall)]
@@ -772,6 +971,66 @@ func (fake *FakeContainerStore) NewRegistryPrunerReturnsOnCall(i int, result1 if
 	}{result1}
 }
 
+func (fake *FakeContainerStore) NewStoreMetricsReporter(arg1 lager.Logger) ifrit.Runner {
+	fake.newStoreMetricsReporterMutex.Lock()
+	ret, specificReturn := fake.newStoreMetricsReporterReturnsOnCall[len(fake.newStoreMetricsReporterArgsForCall)]
+	fake.newStoreMetricsReporterArgsForCall = append(fake.newStoreMetricsReporterArgsForCall, struct {
+		arg1 lager.Logger
+	}{arg1})
+	fake.recordInvocation("NewStoreMetricsReporter", []interface{}{arg1})
+	fake.newStoreMetricsReporterMutex.Unlock()
+	if fake.NewStoreMetricsReporterStub != nil {
+		return fake.NewStoreMetricsReporterStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.newStoreMetricsReporterReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerStore) NewStoreMetricsReporterCallCount() int {
+	fake.newStoreMetricsReporterMutex.RLock()
+	defer fake.newStoreMetricsReporterMutex.RUnlock()
+	return len(fake.newStoreMetricsReporterArgsForCall)
+}
+
+func (fake *FakeContainerStore) NewStoreMetricsReporterCalls(stub func(lager.Logger) ifrit.Runner) {
+	fake.newStoreMetricsReporterMutex.Lock()
+	defer fake.newStoreMetricsReporterMutex.Unlock()
+	fake.NewStoreMetricsReporterStub = stub
+}
+
+func (fake *FakeContainerStore) NewStoreMetricsReporterArgsForCall(i int) lager.Logger {
+	fake.newStoreMetricsReporterMutex.RLock()
+	defer fake.newStoreMetricsReporterMutex.RUnlock()
+	argsForCall := fake.newStoreMetricsReporterArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeContainerStore) NewStoreMetricsReporterReturns(result1 ifrit.Runner) {
+	fake.newStoreMetricsReporterMutex.Lock()
+	defer fake.newStoreMetricsReporterMutex.Unlock()
+	fake.NewStoreMetricsReporterStub = nil
+	fake.newStoreMetricsReporterReturns = struct {
+		result1 ifrit.Runner
+	}{result1}
+}
+
+func (fake *FakeContainerStore) NewStoreMetricsReporterReturnsOnCall(i int, result1 ifrit.Runner) {
+	fake.newStoreMetricsReporterMutex.Lock()
+	defer fake.newStoreMetricsReporterMutex.Unlock()
+	fake.NewStoreMetricsReporterStub = nil
+	if fake.newStoreMetricsReporterReturnsOnCall == nil {
+		fake.newStoreMetricsReporterReturnsOnCall = make(map[int]struct {
+			result1 ifrit.Runner
+		})
+	}
+	fake.newStoreMetricsReporterReturnsOnCall[i] = struct {
+		result1 ifrit.Runner
+	}{result1}
+}
+
 func (fake *FakeContainerStore) RemainingResources(arg1 lager.Logger) executor.ExecutorResources {
 	fake.remainingResourcesMutex.Lock()
 	ret, specificReturn := fake.remainingResourcesReturnsOnCall[len(fake.remainingResourcesArgsForCall)]
@@ -957,6 +1216,38 @@ func (fake *FakeContainerStore) RunReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeContainerStore) SetGardenHealthy(arg1 lager.Logger, arg2 bool) {
+	fake.setGardenHealthyMutex.Lock()
+	fake.setGardenHealthyArgsForCall = append(fake.setGardenHealthyArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 bool
+	}{arg1, arg2})
+	fake.recordInvocation("SetGardenHealthy", []interface{}{arg1, arg2})
+	fake.setGardenHealthyMutex.Unlock()
+	if fake.SetGardenHealthyStub != nil {
+		fake.SetGardenHealthyStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeContainerStore) SetGardenHealthyCallCount() int {
+	fake.setGardenHealthyMutex.RLock()
+	defer fake.setGardenHealthyMutex.RUnlock()
+	return len(fake.setGardenHealthyArgsForCall)
+}
+
+func (fake *FakeContainerStore) SetGardenHealthyCalls(stub func(lager.Logger, bool)) {
+	fake.setGardenHealthyMutex.Lock()
+	defer fake.setGardenHealthyMutex.Unlock()
+	fake.SetGardenHealthyStub = stub
+}
+
+func (fake *FakeContainerStore) SetGardenHealthyArgsForCall(i int) (lager.Logger, bool) {
+	fake.setGardenHealthyMutex.RLock()
+	defer fake.setGardenHealthyMutex.RUnlock()
+	argsForCall := fake.setGardenHealthyArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
 func (fake *FakeContainerStore) Stop(arg1 lager.Logger, arg2 string) error {
 	fake.stopMutex.Lock()
 	ret, specificReturn := fake.stopReturnsOnCall[len(fake.stopArgsForCall)]
@@ -1018,6 +1309,130 @@ func (fake *FakeContainerStore) StopReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeContainerStore) Tag(arg1 lager.Logger, arg2 string, arg3 executor.Tags) error {
+	fake.tagMutex.Lock()
+	ret, specificReturn := fake.tagReturnsOnCall[len(fake.tagArgsForCall)]
+	fake.tagArgsForCall = append(fake.tagArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 executor.Tags
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("Tag", []interface{}{arg1, arg2, arg3})
+	fake.tagMutex.Unlock()
+	if fake.TagStub != nil {
+		return fake.TagStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.tagReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerStore) TagCallCount() int {
+	fake.tagMutex.RLock()
+	defer fake.tagMutex.RUnlock()
+	return len(fake.tagArgsForCall)
+}
+
+func (fake *FakeContainerStore) TagCalls(stub func(lager.Logger, string, executor.Tags) error) {
+	fake.tagMutex.Lock()
+	defer fake.tagMutex.Unlock()
+	fake.TagStub = stub
+}
+
+func (fake *FakeContainerStore) TagArgsForCall(i int) (lager.Logger, string, executor.Tags) {
+	fake.tagMutex.RLock()
+	defer fake.tagMutex.RUnlock()
+	argsForCall := fake.tagArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeContainerStore) TagReturns(result1 error) {
+	fake.tagMutex.Lock()
+	defer fake.tagMutex.Unlock()
+	fake.TagStub = nil
+	fake.tagReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerStore) TagReturnsOnCall(i int, result1 error) {
+	fake.tagMutex.Lock()
+	defer fake.tagMutex.Unlock()
+	fake.TagStub = nil
+	if fake.tagReturnsOnCall == nil {
+		fake.tagReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.tagReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerStore) UpdateLogConfig(arg1 lager.Logger, arg2 string, arg3 executor.LogConfig) error {
+	fake.updateLogConfigMutex.Lock()
+	ret, specificReturn := fake.updateLogConfigReturnsOnCall[len(fake.updateLogConfigArgsForCall)]
+	fake.updateLogConfigArgsForCall = append(fake.updateLogConfigArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 executor.LogConfig
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("UpdateLogConfig", []interface{}{arg1, arg2, arg3})
+	fake.updateLogConfigMutex.Unlock()
+	if fake.UpdateLogConfigStub != nil {
+		return fake.UpdateLogConfigStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.updateLogConfigReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeContainerStore) UpdateLogConfigCallCount() int {
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
+	return len(fake.updateLogConfigArgsForCall)
+}
+
+func (fake *FakeContainerStore) UpdateLogConfigCalls(stub func(lager.Logger, string, executor.LogConfig) error) {
+	fake.updateLogConfigMutex.Lock()
+	defer fake.updateLogConfigMutex.Unlock()
+	fake.UpdateLogConfigStub = stub
+}
+
+func (fake *FakeContainerStore) UpdateLogConfigArgsForCall(i int) (lager.Logger, string, executor.LogConfig) {
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
+	argsForCall := fake.updateLogConfigArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeContainerStore) UpdateLogConfigReturns(result1 error) {
+	fake.updateLogConfigMutex.Lock()
+	defer fake.updateLogConfigMutex.Unlock()
+	fake.UpdateLogConfigStub = nil
+	fake.updateLogConfigReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerStore) UpdateLogConfigReturnsOnCall(i int, result1 error) {
+	fake.updateLogConfigMutex.Lock()
+	defer fake.updateLogConfigMutex.Unlock()
+	fake.UpdateLogConfigStub = nil
+	if fake.updateLogConfigReturnsOnCall == nil {
+		fake.updateLogConfigReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.updateLogConfigReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeContainerStore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -1031,24 +1446,36 @@ func (fake *FakeContainerStore) Invocations() map[string][][]interface{} {
 	defer fake.getMutex.RUnlock()
 	fake.getFilesMutex.RLock()
 	defer fake.getFilesMutex.RUnlock()
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
 	fake.initializeMutex.RLock()
 	defer fake.initializeMutex.RUnlock()
 	fake.listMutex.RLock()
 	defer fake.listMutex.RUnlock()
+	fake.markDeletingMutex.RLock()
+	defer fake.markDeletingMutex.RUnlock()
 	fake.metricsMutex.RLock()
 	defer fake.metricsMutex.RUnlock()
 	fake.newContainerReaperMutex.RLock()
 	defer fake.newContainerReaperMutex.RUnlock()
 	fake.newRegistryPrunerMutex.RLock()
 	defer fake.newRegistryPrunerMutex.RUnlock()
+	fake.newStoreMetricsReporterMutex.RLock()
+	defer fake.newStoreMetricsReporterMutex.RUnlock()
 	fake.remainingResourcesMutex.RLock()
 	defer fake.remainingResourcesMutex.RUnlock()
 	fake.reserveMutex.RLock()
 	defer fake.reserveMutex.RUnlock()
 	fake.runMutex.RLock()
 	defer fake.runMutex.RUnlock()
+	fake.setGardenHealthyMutex.RLock()
+	defer fake.setGardenHealthyMutex.RUnlock()
 	fake.stopMutex.RLock()
 	defer fake.stopMutex.RUnlock()
+	fake.tagMutex.RLock()
+	defer fake.tagMutex.RUnlock()
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
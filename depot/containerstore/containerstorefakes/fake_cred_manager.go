@@ -3,6 +3,7 @@ package containerstorefakes
 
 import (
 	"sync"
+	"time"
 
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/containerstore"
@@ -40,6 +41,18 @@ type FakeCredManager struct {
 	removeCredDirReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RotateCredsStub        func(lager.Logger, executor.Container) error
+	rotateCredsMutex       sync.RWMutex
+	rotateCredsArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 executor.Container
+	}
+	rotateCredsReturns struct {
+		result1 error
+	}
+	rotateCredsReturnsOnCall map[int]struct {
+		result1 error
+	}
 	RunnerStub        func(lager.Logger, executor.Container) ifrit.Runner
 	runnerMutex       sync.RWMutex
 	runnerArgsForCall []struct {
@@ -52,6 +65,21 @@ type FakeCredManager struct {
 	runnerReturnsOnCall map[int]struct {
 		result1 ifrit.Runner
 	}
+	WaitForInternalIPStub        func(lager.Logger, garden.Container, time.Duration) (string, error)
+	waitForInternalIPMutex       sync.RWMutex
+	waitForInternalIPArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 garden.Container
+		arg3 time.Duration
+	}
+	waitForInternalIPReturns struct {
+		result1 string
+		result2 error
+	}
+	waitForInternalIPReturnsOnCall map[int]struct {
+		result1 string
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -184,6 +212,67 @@ func (fake *FakeCredManager) RemoveCredDirReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeCredManager) RotateCreds(arg1 lager.Logger, arg2 executor.Container) error {
+	fake.rotateCredsMutex.Lock()
+	ret, specificReturn := fake.rotateCredsReturnsOnCall[len(fake.rotateCredsArgsForCall)]
+	fake.rotateCredsArgsForCall = append(fake.rotateCredsArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 executor.Container
+	}{arg1, arg2})
+	fake.recordInvocation("RotateCreds", []interface{}{arg1, arg2})
+	fake.rotateCredsMutex.Unlock()
+	if fake.RotateCredsStub != nil {
+		return fake.RotateCredsStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.rotateCredsReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeCredManager) RotateCredsCallCount() int {
+	fake.rotateCredsMutex.RLock()
+	defer fake.rotateCredsMutex.RUnlock()
+	return len(fake.rotateCredsArgsForCall)
+}
+
+func (fake *FakeCredManager) RotateCredsCalls(stub func(lager.Logger, executor.Container) error) {
+	fake.rotateCredsMutex.Lock()
+	defer fake.rotateCredsMutex.Unlock()
+	fake.RotateCredsStub = stub
+}
+
+func (fake *FakeCredManager) RotateCredsArgsForCall(i int) (lager.Logger, executor.Container) {
+	fake.rotateCredsMutex.RLock()
+	defer fake.rotateCredsMutex.RUnlock()
+	argsForCall := fake.rotateCredsArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeCredManager) RotateCredsReturns(result1 error) {
+	fake.rotateCredsMutex.Lock()
+	defer fake.rotateCredsMutex.Unlock()
+	fake.RotateCredsStub = nil
+	fake.rotateCredsReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeCredManager) RotateCredsReturnsOnCall(i int, result1 error) {
+	fake.rotateCredsMutex.Lock()
+	defer fake.rotateCredsMutex.Unlock()
+	fake.RotateCredsStub = nil
+	if fake.rotateCredsReturnsOnCall == nil {
+		fake.rotateCredsReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.rotateCredsReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeCredManager) Runner(arg1 lager.Logger, arg2 executor.Container) ifrit.Runner {
 	fake.runnerMutex.Lock()
 	ret, specificReturn := fake.runnerReturnsOnCall[len(fake.runnerArgsForCall)]
@@ -245,6 +334,71 @@ func (fake *FakeCredManager) RunnerReturnsOnCall(i int, result1 ifrit.Runner) {
 	}{result1}
 }
 
+func (fake *FakeCredManager) WaitForInternalIP(arg1 lager.Logger, arg2 garden.Container, arg3 time.Duration) (string, error) {
+	fake.waitForInternalIPMutex.Lock()
+	ret, specificReturn := fake.waitForInternalIPReturnsOnCall[len(fake.waitForInternalIPArgsForCall)]
+	fake.waitForInternalIPArgsForCall = append(fake.waitForInternalIPArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 garden.Container
+		arg3 time.Duration
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("WaitForInternalIP", []interface{}{arg1, arg2, arg3})
+	fake.waitForInternalIPMutex.Unlock()
+	if fake.WaitForInternalIPStub != nil {
+		return fake.WaitForInternalIPStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.waitForInternalIPReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeCredManager) WaitForInternalIPCallCount() int {
+	fake.waitForInternalIPMutex.RLock()
+	defer fake.waitForInternalIPMutex.RUnlock()
+	return len(fake.waitForInternalIPArgsForCall)
+}
+
+func (fake *FakeCredManager) WaitForInternalIPCalls(stub func(lager.Logger, garden.Container, time.Duration) (string, error)) {
+	fake.waitForInternalIPMutex.Lock()
+	defer fake.waitForInternalIPMutex.Unlock()
+	fake.WaitForInternalIPStub = stub
+}
+
+func (fake *FakeCredManager) WaitForInternalIPArgsForCall(i int) (lager.Logger, garden.Container, time.Duration) {
+	fake.waitForInternalIPMutex.RLock()
+	defer fake.waitForInternalIPMutex.RUnlock()
+	argsForCall := fake.waitForInternalIPArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeCredManager) WaitForInternalIPReturns(result1 string, result2 error) {
+	fake.waitForInternalIPMutex.Lock()
+	defer fake.waitForInternalIPMutex.Unlock()
+	fake.WaitForInternalIPStub = nil
+	fake.waitForInternalIPReturns = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeCredManager) WaitForInternalIPReturnsOnCall(i int, result1 string, result2 error) {
+	fake.waitForInternalIPMutex.Lock()
+	defer fake.waitForInternalIPMutex.Unlock()
+	fake.WaitForInternalIPStub = nil
+	if fake.waitForInternalIPReturnsOnCall == nil {
+		fake.waitForInternalIPReturnsOnCall = make(map[int]struct {
+			result1 string
+			result2 error
+		})
+	}
+	fake.waitForInternalIPReturnsOnCall[i] = struct {
+		result1 string
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeCredManager) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -252,8 +406,12 @@ func (fake *FakeCredManager) Invocations() map[string][][]interface{} {
 	defer fake.createCredDirMutex.RUnlock()
 	fake.removeCredDirMutex.RLock()
 	defer fake.removeCredDirMutex.RUnlock()
+	fake.rotateCredsMutex.RLock()
+	defer fake.rotateCredsMutex.RUnlock()
 	fake.runnerMutex.RLock()
 	defer fake.runnerMutex.RUnlock()
+	fake.waitForInternalIPMutex.RLock()
+	defer fake.waitForInternalIPMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
@@ -0,0 +1,90 @@
+package containerstore
+
+import (
+	"net"
+	"strings"
+)
+
+const (
+	CreateFailureRootFS       = "rootfs-error"
+	CreateFailureNetworkSetup = "network-setup-error"
+	CreateFailureQuota        = "quota-error"
+	CreateFailureRuntime      = "runtime-error"
+	CreateFailureTimeout      = "timeout"
+	CreateFailureUnknown      = "unknown"
+)
+
+const (
+	GardenContainerCreationRootFSFailures       = "GardenContainerCreationRootFSFailures"
+	GardenContainerCreationNetworkSetupFailures = "GardenContainerCreationNetworkSetupFailures"
+	GardenContainerCreationQuotaFailures        = "GardenContainerCreationQuotaFailures"
+	GardenContainerCreationRuntimeFailures      = "GardenContainerCreationRuntimeFailures"
+	GardenContainerCreationTimeoutFailures      = "GardenContainerCreationTimeoutFailures"
+	GardenContainerCreationUnknownFailures      = "GardenContainerCreationUnknownFailures"
+)
+
+type createFailureClass struct {
+	failureType string
+	metric      string
+	// substrings are matched case-insensitively against the error message
+	substrings []string
+}
+
+// createFailureClasses maps garden create errors onto failure types. Classes
+// are tried in order and the first one with a matching substring wins, so
+// more specific classes must come first.
+var createFailureClasses = []createFailureClass{
+	{
+		failureType: CreateFailureTimeout,
+		metric:      GardenContainerCreationTimeoutFailures,
+		substrings:  []string{"timeout", "timed out", "deadline exceeded"},
+	},
+	{
+		failureType: CreateFailureQuota,
+		metric:      GardenContainerCreationQuotaFailures,
+		substrings:  []string{"quota", "no space left on device", "disk limit", "insufficient"},
+	},
+	{
+		failureType: CreateFailureRootFS,
+		metric:      GardenContainerCreationRootFSFailures,
+		substrings:  []string{"rootfs", "image", "manifest", "layer", "unpack"},
+	},
+	{
+		failureType: CreateFailureNetworkSetup,
+		metric:      GardenContainerCreationNetworkSetupFailures,
+		substrings:  []string{"iptables", "network", "subnet", "cni", "netns", "ip address"},
+	},
+	{
+		failureType: CreateFailureRuntime,
+		metric:      GardenContainerCreationRuntimeFailures,
+		substrings:  []string{"runc", "oci runtime", "cgroup", "exit status"},
+	},
+}
+
+var unknownCreateFailureClass = createFailureClass{
+	failureType: CreateFailureUnknown,
+	metric:      GardenContainerCreationUnknownFailures,
+}
+
+func classifyCreateFailure(err error) createFailureClass {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return createFailureClasses[0]
+	}
+
+	message := strings.ToLower(err.Error())
+	for _, class := range createFailureClasses {
+		for _, substring := range class.substrings {
+			if strings.Contains(message, substring) {
+				return class
+			}
+		}
+	}
+
+	return unknownCreateFailureClass
+}
+
+// ClassifyCreateFailure returns the failure type of an error returned while
+// creating a garden container.
+func ClassifyCreateFailure(err error) string {
+	return classifyCreateFailure(err).failureType
+}
@@ -0,0 +1,34 @@
+package containerstore_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/executor/depot/containerstore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ClassifyCreateFailure", func() {
+	knownErrors := map[string]string{
+		"failed to create rootfs: image not found":                                           containerstore.CreateFailureRootFS,
+		"fetching image layer: unexpected EOF":                                               containerstore.CreateFailureRootFS,
+		"manifest unknown: manifest unknown":                                                 containerstore.CreateFailureRootFS,
+		"iptables: Resource temporarily unavailable.":                                        containerstore.CreateFailureNetworkSetup,
+		"the requested subnet is not available":                                              containerstore.CreateFailureNetworkSetup,
+		"external networker up: exit status 1: cni plugin failed":                            containerstore.CreateFailureNetworkSetup,
+		"disk quota exceeded":                                                                containerstore.CreateFailureQuota,
+		"write /var/vcap/data/grootfs/store: no space left on device":                        containerstore.CreateFailureQuota,
+		"runc run: exit status 1: container_linux.go:348: starting container process caused": containerstore.CreateFailureRuntime,
+		"oci runtime error: could not create cgroup":                                         containerstore.CreateFailureRuntime,
+		"Post http://api/containers: net/http: request canceled (Client.Timeout exceeded)":   containerstore.CreateFailureTimeout,
+		"context deadline exceeded":                                                          containerstore.CreateFailureTimeout,
+		"something unexpected happened":                                                      containerstore.CreateFailureUnknown,
+	}
+
+	for message, failureType := range knownErrors {
+		message, failureType := message, failureType
+		It("classifies '"+message+"' as "+failureType, func() {
+			Expect(containerstore.ClassifyCreateFailure(errors.New(message))).To(Equal(failureType))
+		})
+	}
+})
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/pem"
+	"errors"
 	"io"
 	"math/big"
 	"net"
@@ -29,6 +30,10 @@ const (
 	CredCreationFailedCount       = "CredCreationFailedCount"
 )
 
+const InternalIPPollInterval = 100 * time.Millisecond
+
+var ErrInternalIPUnavailable = errors.New("timed out waiting for container internal ip")
+
 type Credential struct {
 	Cert string
 	Key  string
@@ -39,6 +44,12 @@ type CredManager interface {
 	CreateCredDir(lager.Logger, executor.Container) ([]garden.BindMount, []executor.EnvironmentVariable, error)
 	RemoveCredDir(lager.Logger, executor.Container) error
 	Runner(lager.Logger, executor.Container) ifrit.Runner
+	// RotateCreds generates new credentials for the container and hands them
+	// to every handler, without waiting for the scheduled rotation.
+	RotateCreds(lager.Logger, executor.Container) error
+	// WaitForInternalIP polls garden until the container has been assigned an
+	// internal ip, so that it can be included in the generated certificate.
+	WaitForInternalIP(lager.Logger, garden.Container, time.Duration) (string, error)
 }
 
 type noopManager struct{}
@@ -55,6 +66,14 @@ func (c *noopManager) RemoveCredDir(logger lager.Logger, container executor.Cont
 	return nil
 }
 
+func (c *noopManager) WaitForInternalIP(logger lager.Logger, gardenContainer garden.Container, timeout time.Duration) (string, error) {
+	return "", nil
+}
+
+func (c *noopManager) RotateCreds(logger lager.Logger, container executor.Container) error {
+	return nil
+}
+
 func (c *noopManager) Runner(lager.Logger, executor.Container) ifrit.Runner {
 	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 		close(ready)
@@ -64,14 +83,16 @@ func (c *noopManager) Runner(lager.Logger, executor.Container) ifrit.Runner {
 }
 
 type credManager struct {
-	logger         lager.Logger
-	metronClient   loggingclient.IngressClient
-	validityPeriod time.Duration
-	entropyReader  io.Reader
-	clock          clock.Clock
-	CaCert         *x509.Certificate
-	privateKey     *rsa.PrivateKey
-	handlers       []CredentialHandler
+	logger            lager.Logger
+	metronClient      loggingclient.IngressClient
+	validityPeriod    time.Duration
+	renewalWindow     time.Duration
+	entropyReader     io.Reader
+	clock             clock.Clock
+	CaCert            *x509.Certificate
+	intermediateCerts []*x509.Certificate
+	privateKey        *rsa.PrivateKey
+	handlers          []CredentialHandler
 }
 
 //go:generate counterfeiter -o containerstorefakes/fake_cred_handler.go . CredentialHandler
@@ -97,21 +118,25 @@ func NewCredManager(
 	logger lager.Logger,
 	metronClient loggingclient.IngressClient,
 	validityPeriod time.Duration,
+	renewalWindow time.Duration,
 	entropyReader io.Reader,
 	clock clock.Clock,
 	CaCert *x509.Certificate,
+	intermediateCerts []*x509.Certificate,
 	privateKey *rsa.PrivateKey,
 	handlers ...CredentialHandler,
 ) CredManager {
 	return &credManager{
-		logger:         logger,
-		metronClient:   metronClient,
-		validityPeriod: validityPeriod,
-		entropyReader:  entropyReader,
-		clock:          clock,
-		CaCert:         CaCert,
-		privateKey:     privateKey,
-		handlers:       handlers,
+		logger:            logger,
+		metronClient:      metronClient,
+		validityPeriod:    validityPeriod,
+		renewalWindow:     renewalWindow,
+		entropyReader:     entropyReader,
+		clock:             clock,
+		CaCert:            CaCert,
+		intermediateCerts: intermediateCerts,
+		privateKey:        privateKey,
+		handlers:          handlers,
 	}
 }
 
@@ -124,6 +149,16 @@ func calculateCredentialRotationPeriod(validityPeriod time.Duration) time.Durati
 	return validityPeriod - eighth
 }
 
+// rotationPeriod returns how long after issue credentials are rotated. A
+// renewal window shorter than the validity period rotates credentials that
+// long before they expire; otherwise the default schedule is used.
+func (c *credManager) rotationPeriod() time.Duration {
+	if c.renewalWindow > 0 && c.renewalWindow < c.validityPeriod {
+		return c.validityPeriod - c.renewalWindow
+	}
+	return calculateCredentialRotationPeriod(c.validityPeriod)
+}
+
 func (c *credManager) CreateCredDir(logger lager.Logger, container executor.Container) ([]garden.BindMount, []executor.EnvironmentVariable, error) {
 	var mounts []garden.BindMount
 	var envs []executor.EnvironmentVariable
@@ -161,6 +196,35 @@ func (c *credManager) RemoveCredDir(logger lager.Logger, container executor.Cont
 	return err.ErrorOrNil()
 }
 
+func (c *credManager) WaitForInternalIP(logger lager.Logger, gardenContainer garden.Container, timeout time.Duration) (string, error) {
+	logger = logger.Session("wait-for-internal-ip", lager.Data{"handle": gardenContainer.Handle(), "timeout": timeout.String()})
+
+	timer := c.clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	ticker := c.clock.NewTicker(InternalIPPollInterval)
+	defer ticker.Stop()
+
+	for {
+		info, err := gardenContainer.Info()
+		if err != nil {
+			logger.Error("failed-to-get-container-info", err)
+			return "", err
+		}
+
+		if info.ContainerIP != "" {
+			return info.ContainerIP, nil
+		}
+
+		select {
+		case <-ticker.C():
+		case <-timer.C():
+			logger.Error("timed-out", ErrInternalIPUnavailable)
+			return "", ErrInternalIPUnavailable
+		}
+	}
+}
+
 func (c *credManager) Runner(logger lager.Logger, container executor.Container) ifrit.Runner {
 	runner := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 		logger = logger.Session("cred-manager-runner")
@@ -187,8 +251,7 @@ func (c *credManager) Runner(logger lager.Logger, container executor.Container)
 		c.metronClient.IncrementCounter(CredCreationSucceededCount)
 		c.metronClient.SendDuration(CredCreationSucceededDuration, duration)
 
-		rotationDuration := calculateCredentialRotationPeriod(c.validityPeriod)
-		regenCertTimer := c.clock.NewTimer(rotationDuration)
+		regenCertTimer := c.clock.NewTimer(c.rotationPeriod())
 
 		close(ready)
 
@@ -197,26 +260,12 @@ func (c *credManager) Runner(logger lager.Logger, container executor.Container)
 			select {
 			case <-regenCertTimer.C():
 				regenLogger.Debug("started")
-				start := c.clock.Now()
-				creds, err := c.generateCreds(logger, container, container.Guid)
-				duration := c.clock.Since(start)
+				err := c.RotateCreds(regenLogger, container)
 				if err != nil {
-					regenLogger.Error("failed-to-generate-credentials", err)
-					c.metronClient.IncrementCounter(CredCreationFailedCount)
 					return err
 				}
-				c.metronClient.IncrementCounter(CredCreationSucceededCount)
-				c.metronClient.SendDuration(CredCreationSucceededDuration, duration)
-
-				for _, h := range c.handlers {
-					err := h.Update(creds, container)
-					if err != nil {
-						return err
-					}
-				}
 
-				rotationDuration = calculateCredentialRotationPeriod(c.validityPeriod)
-				regenCertTimer.Reset(rotationDuration)
+				regenCertTimer.Reset(c.rotationPeriod())
 				regenLogger.Debug("completed")
 			case signal := <-signals:
 				logger.Info("signalled", lager.Data{"signal": signal.String()})
@@ -237,6 +286,28 @@ func (c *credManager) Runner(logger lager.Logger, container executor.Container)
 	return runner
 }
 
+func (c *credManager) RotateCreds(logger lager.Logger, container executor.Container) error {
+	start := c.clock.Now()
+	creds, err := c.generateCreds(logger, container, container.Guid)
+	duration := c.clock.Since(start)
+	if err != nil {
+		logger.Error("failed-to-generate-credentials", err)
+		c.metronClient.IncrementCounter(CredCreationFailedCount)
+		return err
+	}
+	c.metronClient.IncrementCounter(CredCreationSucceededCount)
+	c.metronClient.SendDuration(CredCreationSucceededDuration, duration)
+
+	for _, h := range c.handlers {
+		err := h.Update(creds, container)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 const (
 	certificatePEMBlockType = "CERTIFICATE"
 	privateKeyPEMBlockType  = "RSA PRIVATE KEY"
@@ -306,6 +377,13 @@ func (c *credManager) generateCreds(logger lager.Logger, container executor.Cont
 		return Credential{}, err
 	}
 
+	for _, intermediate := range c.intermediateCerts {
+		err = pemEncode(intermediate.Raw, certificatePEMBlockType, certificateWriter)
+		if err != nil {
+			return Credential{}, err
+		}
+	}
+
 	creds := Credential{
 		Cert: certificateBuf.String(),
 		Key:  keyBuf.String(),
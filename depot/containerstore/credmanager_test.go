@@ -19,6 +19,7 @@ import (
 	"code.cloudfoundry.org/executor/depot/containerstore"
 	"code.cloudfoundry.org/executor/depot/containerstore/containerstorefakes"
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
@@ -30,7 +31,9 @@ var _ = Describe("CredManager", func() {
 	var (
 		credManager      containerstore.CredManager
 		validityPeriod   time.Duration
+		renewalWindow    time.Duration
 		CaCert           *x509.Certificate
+		intermediates    []*x509.Certificate
 		privateKey       *rsa.PrivateKey
 		reader           io.Reader
 		logger           lager.Logger
@@ -44,6 +47,7 @@ var _ = Describe("CredManager", func() {
 		SetDefaultEventuallyTimeout(10 * time.Second)
 
 		validityPeriod = time.Minute
+		renewalWindow = 0
 		fakeMetronClient = &mfakes.FakeIngressClient{}
 
 		fakeCredHandler = &containerstorefakes.FakeCredentialHandler{}
@@ -61,6 +65,7 @@ var _ = Describe("CredManager", func() {
 		clock = fakeclock.NewFakeClock(time.Now().UTC().Truncate(time.Second))
 
 		CaCert, privateKey = createIntermediateCert()
+		intermediates = nil
 	})
 
 	JustBeforeEach(func() {
@@ -68,9 +73,11 @@ var _ = Describe("CredManager", func() {
 			logger,
 			fakeMetronClient,
 			validityPeriod,
+			renewalWindow,
 			reader,
 			clock,
 			CaCert,
+			intermediates,
 			privateKey,
 			fakeCredHandler,
 		)
@@ -93,6 +100,67 @@ var _ = Describe("CredManager", func() {
 			process.Signal(os.Interrupt)
 			Eventually(process.Wait()).Should(Receive())
 		})
+
+		It("does not wait for an internal ip", func() {
+			gardenContainer := &gardenfakes.FakeContainer{}
+			ip, err := containerstore.NewNoopCredManager().WaitForInternalIP(logger, gardenContainer, time.Second)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ip).To(BeEmpty())
+			Expect(gardenContainer.InfoCallCount()).To(BeZero())
+		})
+
+		It("does not rotate credentials", func() {
+			Expect(containerstore.NewNoopCredManager().RotateCreds(logger, executor.Container{})).To(Succeed())
+		})
+	})
+
+	Context("WaitForInternalIP", func() {
+		var (
+			gardenContainer *gardenfakes.FakeContainer
+			ipCh            chan string
+			errCh           chan error
+		)
+
+		BeforeEach(func() {
+			gardenContainer = &gardenfakes.FakeContainer{}
+			gardenContainer.InfoReturns(garden.ContainerInfo{}, nil)
+			ipCh = make(chan string, 1)
+			errCh = make(chan error, 1)
+		})
+
+		JustBeforeEach(func() {
+			go func() {
+				ip, err := credManager.WaitForInternalIP(logger, gardenContainer, time.Second)
+				ipCh <- ip
+				errCh <- err
+			}()
+		})
+
+		It("returns the internal ip once garden reports it", func() {
+			Eventually(gardenContainer.InfoCallCount).Should(Equal(1))
+			gardenContainer.InfoReturns(garden.ContainerInfo{ContainerIP: "10.0.0.5"}, nil)
+			clock.WaitForWatcherAndIncrement(containerstore.InternalIPPollInterval)
+
+			Eventually(ipCh).Should(Receive(Equal("10.0.0.5")))
+			Expect(<-errCh).NotTo(HaveOccurred())
+		})
+
+		It("times out if garden never reports an internal ip", func() {
+			Eventually(gardenContainer.InfoCallCount).Should(Equal(1))
+			clock.WaitForNWatchersAndIncrement(time.Second, 2)
+
+			Eventually(errCh).Should(Receive(Equal(containerstore.ErrInternalIPUnavailable)))
+		})
+
+		Context("when getting the container info fails", func() {
+			BeforeEach(func() {
+				gardenContainer.InfoReturns(garden.ContainerInfo{}, errors.New("boom"))
+			})
+
+			It("returns the error", func() {
+				Eventually(errCh).Should(Receive(MatchError("boom")))
+			})
+		})
 	})
 
 	Context("RemoveCredDir", func() {
@@ -108,9 +176,11 @@ var _ = Describe("CredManager", func() {
 				logger,
 				fakeMetronClient,
 				validityPeriod,
+				renewalWindow,
 				reader,
 				clock,
 				CaCert,
+				intermediates,
 				privateKey,
 				fakeCredHandler1,
 				fakeCredHandler2,
@@ -164,9 +234,11 @@ var _ = Describe("CredManager", func() {
 				logger,
 				fakeMetronClient,
 				validityPeriod,
+				renewalWindow,
 				reader,
 				clock,
 				CaCert,
+				intermediates,
 				privateKey,
 				fakeCredHandler1,
 				fakeCredHandler2,
@@ -424,6 +496,25 @@ var _ = Describe("CredManager", func() {
 						})
 					})
 
+					Context("when a renewal window is configured", func() {
+						BeforeEach(func() {
+							validityPeriod = 24 * time.Hour
+							renewalWindow = 2 * time.Hour
+						})
+
+						Context("when 3 hours prior to expiry", func() {
+							It("does not rotate the credentials", func() {
+								testNoCredentialRotation(3 * time.Hour)
+							})
+						})
+
+						Context("when 2 hours prior to expiry", func() {
+							It("rotates the certs", func() {
+								testCredentialRotation(2 * time.Hour)
+							})
+						})
+					})
+
 					Context("when certificate validity is longer than 4 hours", func() {
 						BeforeEach(func() {
 							validityPeriod = 24 * time.Hour
@@ -443,6 +534,32 @@ var _ = Describe("CredManager", func() {
 					})
 				})
 
+				Describe("RotateCreds", func() {
+					It("immediately hands new credentials to the handlers", func() {
+						Eventually(fakeCredHandler.UpdateCallCount).Should(Equal(1))
+						credBefore, _ := fakeCredHandler.UpdateArgsForCall(0)
+
+						Expect(credManager.RotateCreds(logger, container)).To(Succeed())
+
+						Expect(fakeCredHandler.UpdateCallCount()).To(Equal(2))
+						cred, actualContainer := fakeCredHandler.UpdateArgsForCall(1)
+						Expect(actualContainer).To(Equal(container))
+						Expect(cred.Cert).NotTo(Equal(credBefore.Cert))
+						Expect(cred.Key).NotTo(Equal(credBefore.Key))
+					})
+
+					Context("when the handler returns an error", func() {
+						BeforeEach(func() {
+							fakeCredHandler.UpdateReturnsOnCall(1, errors.New("boooom!"))
+						})
+
+						It("returns the error", func() {
+							Eventually(fakeCredHandler.UpdateCallCount).Should(Equal(1))
+							Expect(credManager.RotateCreds(logger, container)).To(MatchError("boooom!"))
+						})
+					})
+				})
+
 				Describe("the certificate", func() {
 					var (
 						cert *x509.Certificate
@@ -506,6 +623,25 @@ var _ = Describe("CredManager", func() {
 						Expect(cert).To(Equal(CaCert))
 					})
 
+					Context("when intermediate CAs are configured", func() {
+						var intermediate *x509.Certificate
+
+						BeforeEach(func() {
+							intermediate, _ = createIntermediateCert()
+							intermediates = []*x509.Certificate{intermediate}
+						})
+
+						It("appends them after the rep intermediate CA", func() {
+							chain := []*x509.Certificate{}
+							for block, rest := pem.Decode(rest); block != nil; block, rest = pem.Decode(rest) {
+								certs, err := x509.ParseCertificates(block.Bytes)
+								Expect(err).NotTo(HaveOccurred())
+								chain = append(chain, certs...)
+							}
+							Expect(chain).To(Equal([]*x509.Certificate{CaCert, intermediate}))
+						})
+					})
+
 					It("has the app guid in the subject's organizational units", func() {
 						Expect(cert.Subject.OrganizationalUnit).To(ContainElement("app:iamthelizardking"))
 					})
@@ -0,0 +1,97 @@
+package containerstore
+
+import (
+	"strconv"
+
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+// sampleFinalMetrics takes one last metrics sample from a container whose
+// run process has completed, so that containers that complete between stats
+// reporter intervals still report their usage. The sample is emitted tagged
+// with final=true and returned for inclusion in the completion event. It
+// returns nil if garden is unhealthy or the sample cannot be taken within
+// FinalMetricsTimeout. Callers must only sample containers that exist in
+// garden.
+func (n *storeNode) sampleFinalMetrics(logger lager.Logger, info executor.Container) *executor.ContainerMetrics {
+	timeout := n.config.FinalMetricsTimeout
+	if timeout <= 0 || !n.gardenHealthy() {
+		return nil
+	}
+
+	logger = logger.Session("final-metrics")
+
+	type result struct {
+		metrics map[string]garden.ContainerMetricsEntry
+		err     error
+	}
+	results := make(chan result, 1)
+	go func() {
+		metrics, err := n.gardenClient.BulkMetrics([]string{info.Guid})
+		results <- result{metrics, err}
+	}()
+
+	timer := n.clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	var res result
+	select {
+	case res = <-results:
+	case <-timer.C():
+		logger.Info("timed-out", lager.Data{"timeout": timeout.String()})
+		return nil
+	}
+
+	if res.err != nil {
+		logger.Error("failed-to-get-metrics", res.err)
+		return nil
+	}
+
+	entry, found := res.metrics[info.Guid]
+	if !found || entry.Err != nil {
+		logger.Info("metrics-not-found")
+		return nil
+	}
+
+	metrics := containerMetricsFromGarden(entry.Metrics, info, n.rootFSSizer)
+	n.emitFinalMetrics(logger, info.MetricsConfig, metrics)
+	return &metrics
+}
+
+func (n *storeNode) emitFinalMetrics(logger lager.Logger, metricsConfig executor.MetricsConfig, metrics executor.ContainerMetrics) {
+	tags := map[string]string{}
+	for k, v := range metricsConfig.Tags {
+		tags[k] = v
+	}
+
+	applicationId, ok := tags["source_id"]
+	if !ok {
+		applicationId = metricsConfig.Guid
+		tags["source_id"] = applicationId
+	}
+	if applicationId == "" {
+		return
+	}
+
+	if _, ok := tags["instance_id"]; !ok {
+		tags["instance_id"] = strconv.Itoa(metricsConfig.Index)
+	}
+	tags["final"] = "true"
+
+	err := n.metronClient.SendAppMetrics(loggingclient.ContainerMetric{
+		MemoryBytes:            metrics.MemoryUsageInBytes,
+		DiskBytes:              metrics.DiskUsageInBytes,
+		MemoryBytesQuota:       metrics.MemoryLimitInBytes,
+		DiskBytesQuota:         metrics.DiskLimitInBytes,
+		AbsoluteCPUUsage:       uint64(metrics.TimeSpentInCPU.Nanoseconds()),
+		AbsoluteCPUEntitlement: metrics.AbsoluteCPUEntitlementInNanoseconds,
+		ContainerAge:           metrics.ContainerAgeInNanoseconds,
+		Tags:                   tags,
+	})
+	if err != nil {
+		logger.Error("failed-to-send-final-metrics", err)
+	}
+}
@@ -0,0 +1,85 @@
+package containerstore
+
+import (
+	"strconv"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	HealthCheckFailureCountProperty = "executor:health-check-failure-count"
+	LastHealthCheckAtProperty       = "executor:last-health-check-at"
+)
+
+// healthCheckRecorder records the results of a node's monitor checks.
+type healthCheckRecorder struct {
+	logger lager.Logger
+	node   *storeNode
+}
+
+func (r *healthCheckRecorder) HealthCheckResult(err error) {
+	r.node.recordHealthCheck(r.logger, err)
+}
+
+// recordHealthCheck updates the health check state of the container. A
+// successful check resets the failure count. The state is persisted as
+// properties of the garden container, so that restoreHealthCheckState can
+// read it back after the executor restarts, but only when the failure count
+// changes: a healthy container is not written to on every check.
+func (n *storeNode) recordHealthCheck(logger lager.Logger, checkErr error) {
+	n.infoLock.Lock()
+	previousFailureCount := n.info.HealthCheckFailureCount
+	if checkErr == nil {
+		n.info.HealthCheckFailureCount = 0
+	} else {
+		n.info.HealthCheckFailureCount++
+	}
+	n.info.LastHealthCheckAt = n.clock.Now().UnixNano()
+	failureCount := n.info.HealthCheckFailureCount
+	lastHealthCheckAt := n.info.LastHealthCheckAt
+	gardenContainer := n.gardenContainer
+	n.infoLock.Unlock()
+
+	if gardenContainer == nil || failureCount == previousFailureCount {
+		return
+	}
+
+	err := n.setGardenProperties(logger, garden.Properties{
+		HealthCheckFailureCountProperty: strconv.Itoa(failureCount),
+		LastHealthCheckAtProperty:       strconv.FormatInt(lastHealthCheckAt, 10),
+	})
+	if err != nil {
+		logger.Error("failed-to-set-health-check-properties", err)
+	}
+}
+
+// restoreHealthCheckState reads the health check state persisted by
+// recordHealthCheck back into container. Missing or malformed properties
+// leave the state as it is.
+func restoreHealthCheckState(logger lager.Logger, gardenContainer garden.Container, container *executor.Container) {
+	properties, err := gardenContainer.Properties()
+	if err != nil {
+		logger.Error("failed-to-get-health-check-properties", err, lager.Data{"guid": container.Guid})
+		return
+	}
+
+	if value, ok := properties[HealthCheckFailureCountProperty]; ok {
+		failureCount, err := strconv.Atoi(value)
+		if err != nil {
+			logger.Error("invalid-health-check-failure-count", err, lager.Data{"guid": container.Guid})
+		} else {
+			container.HealthCheckFailureCount = failureCount
+		}
+	}
+
+	if value, ok := properties[LastHealthCheckAtProperty]; ok {
+		lastHealthCheckAt, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			logger.Error("invalid-last-health-check-at", err, lager.Data{"guid": container.Guid})
+		} else {
+			container.LastHealthCheckAt = lastHealthCheckAt
+		}
+	}
+}
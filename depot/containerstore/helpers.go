@@ -3,6 +3,7 @@ package containerstore
 import (
 	"errors"
 	"net"
+	"reflect"
 	"strings"
 
 	"code.cloudfoundry.org/bbs/models"
@@ -15,13 +16,14 @@ import (
 
 var ErrIPRangeConversionFailed = errors.New("failed to convert destination to ip range")
 
-func logStreamerFromLogConfig(conf executor.LogConfig, metronClient loggingclient.IngressClient) log_streamer.LogStreamer {
+func logStreamerFromLogConfig(conf executor.LogConfig, metronClient loggingclient.IngressClient, maxLineLength int) log_streamer.LogStreamer {
 	return log_streamer.New(
 		conf.Guid,
 		conf.SourceName,
 		conf.Index,
 		conf.Tags,
 		metronClient,
+		maxLineLength,
 	)
 }
 
@@ -62,6 +64,25 @@ func convertEgressToNetOut(logger lager.Logger, egressRules []*models.SecurityGr
 	return netOutRules, nil
 }
 
+// unappliedNetOutRules returns the rules that are not among the rules garden
+// already applied when it created the container.
+func unappliedNetOutRules(rules, applied []garden.NetOutRule) []garden.NetOutRule {
+	var unapplied []garden.NetOutRule
+	for _, rule := range rules {
+		found := false
+		for _, appliedRule := range applied {
+			if reflect.DeepEqual(rule, appliedRule) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			unapplied = append(unapplied, rule)
+		}
+	}
+	return unapplied
+}
+
 func securityGroupRuleToNetOutRule(securityRule *models.SecurityGroupRule) (garden.NetOutRule, error) {
 	var protocol garden.Protocol
 	var portRanges []garden.PortRange
@@ -0,0 +1,37 @@
+package containerstore
+
+import (
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+// maxInodeLimit is the largest inode limit a container may request. Without a
+// configured maximum, containers may only lower the cell-wide limit.
+func (config *ContainerConfig) maxInodeLimit() uint64 {
+	if config.MaxINodeLimit > 0 {
+		return config.MaxINodeLimit
+	}
+	return config.INodeLimit
+}
+
+// validateInodeLimit rejects a requested inode limit above the maximum.
+func (config *ContainerConfig) validateInodeLimit(logger lager.Logger, resource executor.Resource) error {
+	max := config.maxInodeLimit()
+	if resource.InodeLimit > max {
+		logger.Error("inode-limit-exceeds-maximum", executor.ErrInodeLimitExceedsMaximum, lager.Data{
+			"inode-limit":     resource.InodeLimit,
+			"max-inode-limit": max,
+		})
+		return executor.ErrInodeLimitExceedsMaximum
+	}
+	return nil
+}
+
+// inodeLimit returns the inode limit garden enforces for resource. A
+// container that does not request one gets the cell-wide limit.
+func (config *ContainerConfig) inodeLimit(resource executor.Resource) uint64 {
+	if resource.InodeLimit > 0 {
+		return resource.InodeLimit
+	}
+	return config.INodeLimit
+}
@@ -1,25 +1,25 @@
 package containerstore
 
 import (
-	"sync"
 	"time"
 
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/lockstats"
 	"code.cloudfoundry.org/lager"
 )
 
 type nodeMap struct {
 	nodes map[string]*storeNode
-	lock  *sync.RWMutex
+	lock  *lockstats.RWMutex
 
 	remainingResources *executor.ExecutorResources
 }
 
-func newNodeMap(totalCapacity *executor.ExecutorResources) *nodeMap {
+func newNodeMap(totalCapacity *executor.ExecutorResources, lockWaitSampling bool) *nodeMap {
 	capacity := totalCapacity.Copy()
 	return &nodeMap{
 		nodes:              make(map[string]*storeNode),
-		lock:               &sync.RWMutex{},
+		lock:               lockstats.NewRWMutex(lockWaitSampling),
 		remainingResources: &capacity,
 	}
 }
@@ -31,6 +31,12 @@ func (n *nodeMap) Contains(guid string) bool {
 	return ok
 }
 
+func (n *nodeMap) Count() int {
+	n.lock.RLock()
+	defer n.lock.RUnlock()
+	return len(n.nodes)
+}
+
 func (n *nodeMap) RemainingResources() executor.ExecutorResources {
 	n.lock.RLock()
 	defer n.lock.RUnlock()
@@ -112,23 +118,27 @@ func (n *nodeMap) CompleteExpired(logger lager.Logger, now time.Time) {
 	}
 }
 
-func (n *nodeMap) CompleteMissing(logger lager.Logger, snapshotGuids map[string]struct{}, existingHandles map[string]struct{}) {
+func (n *nodeMap) CompleteMissing(logger lager.Logger, snapshotGuids map[string]struct{}, existingHandles map[string]struct{}) int {
 	n.lock.Lock()
 	logger.Debug("lock-acquired")
 	defer n.lock.Unlock()
 	defer logger.Debug("lock-released")
 
+	reapedCount := 0
 	for guid := range snapshotGuids {
 		if _, exist := existingHandles[guid]; !exist {
 			node, ok := n.nodes[guid]
 			if ok {
 				reaped := node.Reap(logger)
 				if reaped {
+					reapedCount++
 					logger.Info("reaped-missing-container", lager.Data{"guid": guid})
 				}
 			}
 		}
 	}
+
+	return reapedCount
 }
 
 func (n *nodeMap) containerGuids(logger lager.Logger) map[string]struct{} {
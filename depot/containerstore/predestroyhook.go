@@ -0,0 +1,43 @@
+package containerstore
+
+import (
+	"context"
+	"os/exec"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// runPreDestroyHook runs the configured pre-destroy hook on the host with the
+// container guid and owner name appended to its arguments, waiting at most
+// PreDestroyHookTimeout for it to exit. Failures are logged and otherwise
+// ignored so that a misbehaving hook cannot prevent a container from being
+// destroyed.
+func (n *storeNode) runPreDestroyHook(logger lager.Logger, guid string) {
+	hook := n.config.PreDestroyHook
+	if len(hook) == 0 {
+		return
+	}
+
+	logger = logger.Session("pre-destroy-hook", lager.Data{"hook": hook[0]})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	ctx := context.Background()
+	if n.config.PreDestroyHookTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.config.PreDestroyHookTimeout)
+		defer cancel()
+	}
+
+	args := append(append([]string{}, hook[1:]...), guid, n.config.OwnerName)
+	// the output is not captured, as waiting on its pipes would outlive the
+	// timeout if the hook leaves child processes behind
+	err := exec.CommandContext(ctx, hook[0], args...).Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		logger.Error("timed-out", ctx.Err(), lager.Data{"timeout": n.config.PreDestroyHookTimeout.String()})
+		return
+	}
+	if err != nil {
+		logger.Error("failed", err)
+	}
+}
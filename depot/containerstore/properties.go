@@ -0,0 +1,114 @@
+package containerstore
+
+import (
+	"sort"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+const GardenPropertiesPerContainerMax = "GardenPropertiesPerContainerMax"
+
+// gardenPropertyCriticality orders the properties the executor writes to a
+// garden container. Lower values are more critical and are the last to be
+// dropped when a container would exceed the soft property limit.
+//
+//   - critical: the owner property, which is required to list and reap
+//     containers belonging to this executor. It is never dropped.
+//   - optional: network and extra properties passed through from the run
+//     request.
+func gardenPropertyCriticality(key string) int {
+	if key == executor.ContainerOwnerProperty {
+		return 0
+	}
+	return 1
+}
+
+// limitGardenProperties returns properties with no more than limit entries,
+// dropping the least critical properties first. A limit of zero or less means
+// no limit. Critical properties are always kept, even if that exceeds limit.
+func limitGardenProperties(logger lager.Logger, properties garden.Properties, limit int) garden.Properties {
+	if limit <= 0 || len(properties) <= limit {
+		return properties
+	}
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		ci, cj := gardenPropertyCriticality(keys[i]), gardenPropertyCriticality(keys[j])
+		if ci != cj {
+			return ci < cj
+		}
+		return keys[i] < keys[j]
+	})
+
+	limited := garden.Properties{}
+	for _, key := range keys {
+		if len(limited) >= limit && gardenPropertyCriticality(key) > 0 {
+			logger.Info("dropping-garden-property", lager.Data{"property": key, "limit": limit})
+			continue
+		}
+		limited[key] = properties[key]
+	}
+
+	return limited
+}
+
+// setGardenProperties sets properties on the garden container of the node. It
+// is the only way properties are written after the container is created, so
+// that the container stays within MaxGardenProperties: properties the
+// container already has can always be updated, but when the new ones would
+// take it over the limit none of the properties are set and
+// ErrGardenPropertyLimitExceeded is returned.
+func (n *storeNode) setGardenProperties(logger lager.Logger, properties garden.Properties) error {
+	n.propertyLock.Lock()
+	defer n.propertyLock.Unlock()
+
+	keys := make([]string, 0, len(properties))
+	for key := range properties {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	n.infoLock.Lock()
+	gardenContainer := n.gardenContainer
+	count := len(n.gardenPropertyNames)
+	newCount := 0
+	for _, key := range keys {
+		if _, ok := n.gardenPropertyNames[key]; !ok {
+			newCount++
+		}
+	}
+	n.infoLock.Unlock()
+
+	if gardenContainer == nil {
+		return executor.ErrContainerNotFound
+	}
+
+	limit := n.config.MaxGardenProperties
+	if limit > 0 && newCount > 0 && count+newCount > limit {
+		logger.Error("garden-property-limit-exceeded", executor.ErrGardenPropertyLimitExceeded, lager.Data{
+			"properties":     count,
+			"new-properties": newCount,
+			"limit":          limit,
+		})
+		return executor.ErrGardenPropertyLimitExceeded
+	}
+
+	for _, key := range keys {
+		err := gardenContainer.SetProperty(key, properties[key])
+		if err != nil {
+			logger.Error("failed-to-set-property", err, lager.Data{"property": key})
+			return err
+		}
+
+		n.infoLock.Lock()
+		n.gardenPropertyNames[key] = struct{}{}
+		n.infoLock.Unlock()
+	}
+
+	return nil
+}
@@ -25,6 +25,7 @@ import (
 	envoy_v2_bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v2"
 	envoy_v2_tcp_proxy_filter "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/tcp_proxy/v2"
 	envoy_v2_metrics "github.com/envoyproxy/go-control-plane/envoy/config/metrics/v2"
+	envoy_type_matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
 	envoy_util "github.com/envoyproxy/go-control-plane/pkg/util"
 	ghodss_yaml "github.com/ghodss/yaml"
 	"github.com/gogo/protobuf/jsonpb"
@@ -43,6 +44,8 @@ const (
 	TcpProxy        = "envoy.tcp_proxy"
 
 	AdminAccessLog = os.DevNull
+
+	ActiveConnectionsStat = "downstream_cx_active"
 )
 
 var (
@@ -77,6 +80,8 @@ type ProxyConfigHandler struct {
 	reloadClock    clock.Clock
 
 	adsServers []string
+
+	enableProxyDrain bool
 }
 
 type NoopProxyConfigHandler struct{}
@@ -120,6 +125,7 @@ func NewProxyConfigHandler(
 	reloadDuration time.Duration,
 	reloadClock clock.Clock,
 	adsServers []string,
+	enableProxyDrain bool,
 ) *ProxyConfigHandler {
 	return &ProxyConfigHandler{
 		logger:                             logger.Session("proxy-manager"),
@@ -131,6 +137,7 @@ func NewProxyConfigHandler(
 		reloadDuration:                     reloadDuration,
 		reloadClock:                        reloadClock,
 		adsServers:                         adsServers,
+		enableProxyDrain:                   enableProxyDrain,
 	}
 }
 
@@ -248,6 +255,7 @@ func (p *ProxyConfigHandler) writeConfig(credentials Credential, container execu
 		adminPort,
 		p.containerProxyRequireClientCerts,
 		p.adsServers,
+		p.enableProxyDrain,
 	)
 	if err != nil {
 		return err
@@ -299,6 +307,7 @@ func generateProxyConfig(
 	adminPort uint16,
 	requireClientCerts bool,
 	adsServers []string,
+	enableProxyDrain bool,
 ) (*envoy_v2_bootstrap.Bootstrap, error) {
 	clusters := []envoy_v2.Cluster{}
 	for index, portMap := range container.Ports {
@@ -321,17 +330,32 @@ func generateProxyConfig(
 		return nil, fmt.Errorf("generating listeners: %s", err)
 	}
 
+	statsMatcher := &envoy_v2_metrics.StatsMatcher{
+		StatsMatcher: &envoy_v2_metrics.StatsMatcher_RejectAll{
+			RejectAll: true,
+		},
+	}
+
+	// Draining polls the active connection gauges, so they have to be kept.
+	if enableProxyDrain {
+		statsMatcher = &envoy_v2_metrics.StatsMatcher{
+			StatsMatcher: &envoy_v2_metrics.StatsMatcher_InclusionList{
+				InclusionList: &envoy_type_matcher.ListStringMatcher{
+					Patterns: []*envoy_type_matcher.StringMatcher{
+						{MatchPattern: &envoy_type_matcher.StringMatcher_Suffix{Suffix: ActiveConnectionsStat}},
+					},
+				},
+			},
+		}
+	}
+
 	config := &envoy_v2_bootstrap.Bootstrap{
 		Admin: &envoy_v2_bootstrap.Admin{
 			AccessLogPath: AdminAccessLog,
 			Address:       envoyAddr("127.0.0.1", adminPort),
 		},
 		StatsConfig: &envoy_v2_metrics.StatsConfig{
-			StatsMatcher: &envoy_v2_metrics.StatsMatcher{
-				StatsMatcher: &envoy_v2_metrics.StatsMatcher_RejectAll{
-					RejectAll: true,
-				},
-			},
+			StatsMatcher: statsMatcher,
 		},
 		Node: &envoy_v2_core.Node{
 			Id:      fmt.Sprintf("sidecar~%s~%s~x", container.InternalIP, container.Guid),
@@ -581,6 +605,17 @@ func pemConcatenate(certs []string) (string, error) {
 	return certificateBuf.String(), nil
 }
 
+// ProxyAdminURL returns the address of the admin listener of the container's
+// proxy. The listener is bound to loopback, so it is only reachable from
+// inside the container.
+func ProxyAdminURL(container executor.Container) (string, error) {
+	adminPort, err := getAvailablePort(container.Ports)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("http://127.0.0.1:%d", adminPort), nil
+}
+
 func getAvailablePort(allocatedPorts []executor.PortMapping, extraKnownPorts ...uint16) (uint16, error) {
 	existingPorts := make(map[uint16]interface{})
 	for _, portMap := range allocatedPorts {
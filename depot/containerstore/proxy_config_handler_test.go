@@ -26,6 +26,8 @@ import (
 	envoy_v2_listener "github.com/envoyproxy/go-control-plane/envoy/api/v2/listener"
 	envoy_v2_bootstrap "github.com/envoyproxy/go-control-plane/envoy/config/bootstrap/v2"
 	envoy_v2_tcp_proxy_filter "github.com/envoyproxy/go-control-plane/envoy/config/filter/network/tcp_proxy/v2"
+	envoy_v2_metrics "github.com/envoyproxy/go-control-plane/envoy/config/metrics/v2"
+	envoy_type_matcher "github.com/envoyproxy/go-control-plane/envoy/type/matcher"
 	envoy_util "github.com/envoyproxy/go-control-plane/pkg/util"
 	"github.com/fsnotify/fsnotify"
 	ghodss_yaml "github.com/ghodss/yaml"
@@ -57,6 +59,7 @@ var _ = Describe("ProxyConfigHandler", func() {
 		containerProxyVerifySubjectAltName []string
 		containerProxyRequireClientCerts   bool
 		adsServers                         []string
+		enableProxyDrain                   bool
 	)
 
 	BeforeEach(func() {
@@ -98,6 +101,8 @@ var _ = Describe("ProxyConfigHandler", func() {
 			"10.255.217.2:15010",
 			"10.255.217.3:15010",
 		}
+
+		enableProxyDrain = false
 	})
 
 	JustBeforeEach(func() {
@@ -111,6 +116,7 @@ var _ = Describe("ProxyConfigHandler", func() {
 			reloadDuration,
 			reloadClock,
 			adsServers,
+			enableProxyDrain,
 		)
 		Eventually(rotatingCredChan).Should(BeSent(containerstore.Credential{
 			Cert: "some-cert",
@@ -568,6 +574,30 @@ var _ = Describe("ProxyConfigHandler", func() {
 			}))
 		})
 
+		Context("when proxy draining is enabled", func() {
+			BeforeEach(func() {
+				enableProxyDrain = true
+			})
+
+			It("keeps the admin listener on loopback and keeps the active connection stats", func() {
+				err := proxyConfigHandler.Update(containerstore.Credential{Cert: "cert", Key: "key"}, container)
+				Expect(err).NotTo(HaveOccurred())
+				Eventually(proxyConfigFile).Should(BeAnExistingFile())
+
+				var proxyConfig envoy_v2_bootstrap.Bootstrap
+				Expect(yamlFileToProto(proxyConfigFile, &proxyConfig)).To(Succeed())
+
+				Expect(proxyConfig.Admin.Address).To(Equal(envoyAddr("127.0.0.1", 61002)))
+				Expect(proxyConfig.StatsConfig.StatsMatcher.StatsMatcher).To(Equal(&envoy_v2_metrics.StatsMatcher_InclusionList{
+					InclusionList: &envoy_type_matcher.ListStringMatcher{
+						Patterns: []*envoy_type_matcher.StringMatcher{
+							{MatchPattern: &envoy_type_matcher.StringMatcher_Suffix{Suffix: "downstream_cx_active"}},
+						},
+					},
+				}))
+			})
+		})
+
 		Context("when ads server addresses is empty", func() {
 			BeforeEach(func() {
 				adsServers = []string{}
@@ -712,6 +742,18 @@ var _ = Describe("ProxyConfigHandler", func() {
 		})
 	})
 
+	Describe("ProxyAdminURL", func() {
+		It("returns the admin address on loopback", func() {
+			container.Ports = []executor.PortMapping{
+				{ContainerPort: 8080, ContainerTLSProxyPort: 61001},
+			}
+
+			url, err := containerstore.ProxyAdminURL(container)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(url).To(Equal("http://127.0.0.1:61002"))
+		})
+	})
+
 	Describe("Close", func() {
 		var (
 			cert, key string
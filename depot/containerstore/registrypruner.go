@@ -1,7 +1,9 @@
 package containerstore
 
 import (
+	"math/rand"
 	"os"
+	"time"
 
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/lager"
@@ -25,20 +27,34 @@ func newRegistryPruner(logger lager.Logger, config *ContainerConfig, clock clock
 
 func (r *registryPruner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	logger := r.logger.Session("registry-pruner")
-	ticker := r.clock.NewTicker(r.config.ReservedExpirationTime / 2)
+	timer := r.clock.NewTimer(r.interval())
 
 	close(ready)
 
-	defer ticker.Stop()
+	defer timer.Stop()
 	for {
 		select {
-		case <-ticker.C():
+		case <-timer.C():
 
 			now := r.clock.Now()
 			r.containers.CompleteExpired(logger, now)
+			timer.Reset(r.interval())
 		case signal := <-signals:
 			logger.Info("signalled", lager.Data{"signal": signal.String()})
 			return nil
 		}
 	}
 }
+
+// interval adds a random jitter of up to PrunerJitterFraction of the base
+// interval so that executors sharing a configuration don't prune in lockstep.
+func (r *registryPruner) interval() time.Duration {
+	interval := r.config.ReservedExpirationTime / 2
+
+	maxJitter := int64(float64(interval) * r.config.PrunerJitterFraction)
+	if maxJitter <= 0 {
+		return interval
+	}
+
+	return interval + time.Duration(rand.Int63n(maxJitter))
+}
@@ -0,0 +1,160 @@
+package containerstore
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	RestartCountProperty = "executor:restart-count"
+	LastCrashAtProperty  = "executor:last-crash-at"
+)
+
+// restartTracker remembers the crash history of destroyed containers, so
+// that a container re-created with the same guid within the restart window
+// carries its restart count forward. Entries older than the window are
+// forgotten; an entry restored into a container is forgotten through the
+// resource registry once that container is removed.
+type restartTracker struct {
+	window time.Duration
+
+	lock    sync.Mutex
+	crashes map[string]crashRecord
+}
+
+type crashRecord struct {
+	restartCount int
+	lastCrashAt  int64
+}
+
+func newRestartTracker(window time.Duration) *restartTracker {
+	return &restartTracker{
+		window:  window,
+		crashes: make(map[string]crashRecord),
+	}
+}
+
+// recordCrash remembers that the container with guid crashed at lastCrashAt
+// (in unix nanoseconds) after restartCount restarts.
+func (t *restartTracker) recordCrash(guid string, restartCount int, lastCrashAt int64, now time.Time) {
+	if t.window <= 0 {
+		return
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.prune(now)
+	t.crashes[guid] = crashRecord{restartCount: restartCount, lastCrashAt: lastCrashAt}
+}
+
+// restore fills in the restart count and last crash time of a container that
+// is being re-created, if its previous incarnation crashed within the window.
+// It returns whether it did.
+func (t *restartTracker) restore(container *executor.Container, now time.Time) bool {
+	if t.window <= 0 {
+		return false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.prune(now)
+	record, ok := t.crashes[container.Guid]
+	if !ok {
+		return false
+	}
+
+	container.RestartCount = record.restartCount + 1
+	container.LastCrashAt = record.lastCrashAt
+	return true
+}
+
+// forget drops the crash history of guid.
+func (t *restartTracker) forget(guid string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	delete(t.crashes, guid)
+}
+
+func (t *restartTracker) prune(now time.Time) {
+	for guid, record := range t.crashes {
+		if now.Sub(time.Unix(0, record.lastCrashAt)) > t.window {
+			delete(t.crashes, guid)
+		}
+	}
+}
+
+// suggestedBackoff doubles base for every restart, up to max; a max of zero
+// disables the doubling. It is only a hint for downstream components, the
+// executor does not enforce it.
+func suggestedBackoff(restartCount int, base, max time.Duration) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+
+	backoff := base
+	for i := 0; i < restartCount && backoff < max; i++ {
+		backoff *= 2
+	}
+
+	if max > 0 && backoff > max {
+		return max
+	}
+	return backoff
+}
+
+// suggestedBackoffMs is the backoff suggested for a container that has
+// crashed, or zero if it has not.
+func (c *ContainerConfig) suggestedBackoffMs(container *executor.Container) int64 {
+	if container.LastCrashAt == 0 {
+		return 0
+	}
+
+	backoff := suggestedBackoff(container.RestartCount, c.RestartBackoffBase, c.RestartBackoffMax)
+	return int64(backoff / time.Millisecond)
+}
+
+func restartProperties(container *executor.Container, properties garden.Properties) {
+	if container.RestartCount == 0 {
+		return
+	}
+
+	properties[RestartCountProperty] = strconv.Itoa(container.RestartCount)
+	properties[LastCrashAtProperty] = strconv.FormatInt(container.LastCrashAt, 10)
+}
+
+// restoreRestartState reads the restart count and last crash time written by
+// restartProperties back into container. Missing or malformed properties
+// leave the state as it is.
+func restoreRestartState(logger lager.Logger, gardenContainer garden.Container, container *executor.Container) {
+	properties, err := gardenContainer.Properties()
+	if err != nil {
+		logger.Error("failed-to-get-restart-properties", err, lager.Data{"guid": container.Guid})
+		return
+	}
+
+	if value, ok := properties[RestartCountProperty]; ok {
+		restartCount, err := strconv.Atoi(value)
+		if err != nil {
+			logger.Error("invalid-restart-count", err, lager.Data{"guid": container.Guid})
+		} else {
+			container.RestartCount = restartCount
+		}
+	}
+
+	if value, ok := properties[LastCrashAtProperty]; ok {
+		lastCrashAt, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			logger.Error("invalid-last-crash-at", err, lager.Data{"guid": container.Guid})
+		} else {
+			container.LastCrashAt = lastCrashAt
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package containerstore
+
+import (
+	"os"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/guidgen"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+const RootFSWarmerHandlePrefix = "warmup-c-"
+
+type rootFSWarmer struct {
+	logger        lager.Logger
+	gardenClient  garden.Client
+	clock         clock.Clock
+	guidGenerator guidgen.Generator
+	ownerName     string
+	rootFS        string
+	count         int
+}
+
+// NewRootFSWarmer returns a runner that creates and destroys count garden
+// containers with the given rootfs in the background, so that its layers are
+// already in garden's cache when the first real container needs them.
+//
+// Garden handles cannot be changed after creation, and every container the
+// store manages uses its guid as its handle, so the warmup containers cannot
+// be handed to the store; they are destroyed as soon as they are created.
+// They carry the owner property, so any left behind by a crash are removed
+// by the startup cleanup or the container reaper.
+func NewRootFSWarmer(
+	logger lager.Logger,
+	gardenClient garden.Client,
+	clock clock.Clock,
+	guidGenerator guidgen.Generator,
+	ownerName string,
+	rootFS string,
+	count int,
+) ifrit.Runner {
+	return &rootFSWarmer{
+		logger:        logger,
+		gardenClient:  gardenClient,
+		clock:         clock,
+		guidGenerator: guidGenerator,
+		ownerName:     ownerName,
+		rootFS:        rootFS,
+		count:         count,
+	}
+}
+
+// Run warms the rootfs and then waits to be signalled. Signalling it stops
+// the warmup after the container being created, if any, has been destroyed.
+func (w *rootFSWarmer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := w.logger.Session("rootfs-warmer", lager.Data{"rootfs": w.rootFS, "count": w.count})
+
+	close(ready)
+
+	cancel := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		w.warm(logger, cancel)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case signal := <-signals:
+		logger.Info("signalled", lager.Data{"signal": signal.String()})
+		close(cancel)
+		return nil
+	}
+
+	signal := <-signals
+	logger.Info("signalled", lager.Data{"signal": signal.String()})
+	return nil
+}
+
+func (w *rootFSWarmer) warm(logger lager.Logger, cancel <-chan struct{}) {
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	for i := 0; i < w.count; i++ {
+		select {
+		case <-cancel:
+			logger.Info("cancelled", lager.Data{"warmed": i})
+			return
+		default:
+		}
+
+		handle := RootFSWarmerHandlePrefix + w.guidGenerator.Guid(logger)
+		start := w.clock.Now()
+
+		_, err := w.gardenClient.Create(garden.ContainerSpec{
+			Handle: handle,
+			Image:  garden.ImageRef{URI: w.rootFS},
+			Properties: garden.Properties{
+				executor.ContainerOwnerProperty: w.ownerName,
+			},
+		})
+		if err != nil {
+			logger.Error("failed-to-create-container", err, lager.Data{"handle": handle})
+			return
+		}
+		logger.Info("created-container", lager.Data{"handle": handle, "duration": w.clock.Since(start)})
+
+		err = w.gardenClient.Destroy(handle)
+		if err != nil {
+			logger.Error("failed-to-destroy-container", err, lager.Data{"handle": handle})
+		}
+	}
+}
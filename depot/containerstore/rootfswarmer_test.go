@@ -0,0 +1,109 @@
+package containerstore_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/containerstore"
+	"code.cloudfoundry.org/executor/guidgen/fakeguidgen"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RootFSWarmer", func() {
+	var (
+		gardenClient  *gardenfakes.FakeClient
+		guidGenerator *fakeguidgen.FakeGenerator
+		clock         *fakeclock.FakeClock
+		count         int
+		process       ifrit.Process
+	)
+
+	BeforeEach(func() {
+		gardenClient = &gardenfakes.FakeClient{}
+		guidGenerator = &fakeguidgen.FakeGenerator{}
+		guidGenerator.GuidReturnsOnCall(0, "first")
+		guidGenerator.GuidReturnsOnCall(1, "second")
+		clock = fakeclock.NewFakeClock(time.Now())
+		count = 2
+	})
+
+	JustBeforeEach(func() {
+		warmer := containerstore.NewRootFSWarmer(logger, gardenClient, clock, guidGenerator, "executor-name", "docker:///some/image", count)
+		process = ifrit.Background(warmer)
+		Eventually(process.Ready()).Should(BeClosed())
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+	})
+
+	It("creates and destroys the requested number of containers with the rootfs", func() {
+		Eventually(gardenClient.DestroyCallCount).Should(Equal(2))
+		Expect(gardenClient.CreateCallCount()).To(Equal(2))
+
+		Expect(gardenClient.CreateArgsForCall(0)).To(Equal(garden.ContainerSpec{
+			Handle: containerstore.RootFSWarmerHandlePrefix + "first",
+			Image:  garden.ImageRef{URI: "docker:///some/image"},
+			Properties: garden.Properties{
+				executor.ContainerOwnerProperty: "executor-name",
+			},
+		}))
+		Expect(gardenClient.CreateArgsForCall(1).Handle).To(Equal(containerstore.RootFSWarmerHandlePrefix + "second"))
+
+		Expect(gardenClient.DestroyArgsForCall(0)).To(Equal(containerstore.RootFSWarmerHandlePrefix + "first"))
+		Expect(gardenClient.DestroyArgsForCall(1)).To(Equal(containerstore.RootFSWarmerHandlePrefix + "second"))
+	})
+
+	It("keeps running once the warmup is complete", func() {
+		Eventually(gardenClient.DestroyCallCount).Should(Equal(2))
+		Consistently(process.Wait()).ShouldNot(Receive())
+	})
+
+	Context("when creating a container fails", func() {
+		BeforeEach(func() {
+			gardenClient.CreateReturns(nil, errors.New("boom"))
+		})
+
+		It("stops warming up", func() {
+			Eventually(logger).Should(gbytes.Say("failed-to-create-container"))
+			Expect(gardenClient.CreateCallCount()).To(Equal(1))
+			Expect(gardenClient.DestroyCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when signalled during the warmup", func() {
+		var created chan struct{}
+		var release chan struct{}
+
+		BeforeEach(func() {
+			created = make(chan struct{})
+			release = make(chan struct{})
+			gardenClient.CreateStub = func(garden.ContainerSpec) (garden.Container, error) {
+				close(created)
+				<-release
+				return nil, nil
+			}
+		})
+
+		It("exits without creating further containers", func() {
+			Eventually(created).Should(BeClosed())
+
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+
+			close(release)
+			Eventually(gardenClient.DestroyCallCount).Should(Equal(1))
+			Consistently(gardenClient.CreateCallCount).Should(Equal(1))
+		})
+	})
+})
@@ -0,0 +1,44 @@
+package containerstore
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	StartTimeoutPolicyClamp  = "clamp"
+	StartTimeoutPolicyReject = "reject"
+)
+
+// boundStartTimeout returns the start timeout, in milliseconds, that the
+// executor enforces for runInfo. A zero timeout is replaced by the default
+// when the container has a monitor or check definition, and a timeout above
+// the maximum is either clamped to it or rejected depending on the policy.
+func (config *ContainerConfig) boundStartTimeout(logger lager.Logger, runInfo executor.RunInfo) (uint, error) {
+	startTimeoutMs := runInfo.StartTimeoutMs
+	hasMonitor := runInfo.Monitor != nil || runInfo.CheckDefinition != nil
+
+	if startTimeoutMs == 0 && hasMonitor && config.DefaultStartTimeout > 0 {
+		startTimeoutMs = durationToMs(config.DefaultStartTimeout)
+	}
+
+	maxMs := durationToMs(config.MaxStartTimeout)
+	if maxMs > 0 && startTimeoutMs > maxMs {
+		logData := lager.Data{"start-timeout-ms": startTimeoutMs, "max-start-timeout-ms": maxMs}
+		if config.StartTimeoutPolicy == StartTimeoutPolicyReject {
+			logger.Error("start-timeout-exceeds-maximum", executor.ErrStartTimeoutExceedsMaximum, logData)
+			return 0, executor.ErrStartTimeoutExceedsMaximum
+		}
+
+		logger.Info("clamping-start-timeout", logData)
+		startTimeoutMs = maxMs
+	}
+
+	return startTimeoutMs, nil
+}
+
+func durationToMs(d time.Duration) uint {
+	return uint(d / time.Millisecond)
+}
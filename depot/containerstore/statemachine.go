@@ -0,0 +1,66 @@
+package containerstore
+
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/executor"
+)
+
+// InvalidTransitionError is returned when a container is asked to move to a
+// state that is not reachable from its current one. It reports the same name
+// as executor.ErrInvalidTransition, and errors.Is matches it against
+// executor.ErrInvalidTransition.
+type InvalidTransitionError struct {
+	From executor.State
+	To   executor.State
+}
+
+func (err InvalidTransitionError) Name() string {
+	return executor.ErrInvalidTransition.Name()
+}
+
+func (err InvalidTransitionError) Error() string {
+	return fmt.Sprintf("%s: %s -> %s", executor.ErrInvalidTransition.Error(), err.From, err.To)
+}
+
+func (err InvalidTransitionError) Is(target error) bool {
+	return target == executor.ErrInvalidTransition
+}
+
+// StateMachine validates and applies container state transitions against
+// executor.ContainerTransitions. It does not lock: the store node makes every
+// transition under its infoLock.
+type StateMachine struct {
+	transitions map[executor.State][]executor.State
+}
+
+func NewStateMachine() *StateMachine {
+	return &StateMachine{transitions: executor.ContainerTransitions}
+}
+
+// Validate returns an InvalidTransitionError unless the container may move to
+// the given state.
+func (m *StateMachine) Validate(container *executor.Container, to executor.State) error {
+	return m.validate(container.State, to)
+}
+
+// Transition moves the container to the given state, leaving it untouched
+// when the transition is not allowed.
+func (m *StateMachine) Transition(container *executor.Container, to executor.State) error {
+	err := m.validate(container.State, to)
+	if err != nil {
+		return err
+	}
+
+	container.State = to
+	return nil
+}
+
+func (m *StateMachine) validate(from, to executor.State) error {
+	for _, allowed := range m.transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return InvalidTransitionError{From: from, To: to}
+}
@@ -0,0 +1,87 @@
+package containerstore_test
+
+import (
+	"errors"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/containerstore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("StateMachine", func() {
+	var (
+		stateMachine *containerstore.StateMachine
+		container    executor.Container
+	)
+
+	BeforeEach(func() {
+		stateMachine = containerstore.NewStateMachine()
+		container = executor.Container{Guid: "some-guid"}
+	})
+
+	type transition struct {
+		from, to executor.State
+	}
+
+	allowed := []transition{
+		{executor.StateReserved, executor.StateInitializing},
+		{executor.StateInitializing, executor.StateCreated},
+		{executor.StateCreated, executor.StateRunning},
+		{executor.StateReserved, executor.StateCompleted},
+		{executor.StateInitializing, executor.StateCompleted},
+		{executor.StateCreated, executor.StateCompleted},
+		{executor.StateRunning, executor.StateCompleted},
+		{executor.StateCompleted, executor.StateCompleted},
+	}
+
+	for _, t := range allowed {
+		t := t
+		It("allows "+string(t.from)+" to "+string(t.to), func() {
+			container.State = t.from
+			Expect(stateMachine.Validate(&container, t.to)).To(Succeed())
+			Expect(stateMachine.Transition(&container, t.to)).To(Succeed())
+			Expect(container.State).To(Equal(t.to))
+		})
+	}
+
+	invalid := []transition{
+		{executor.StateReserved, executor.StateRunning},
+		{executor.StateInitializing, executor.StateInitializing},
+		{executor.StateCreated, executor.StateInitializing},
+		{executor.StateCompleted, executor.StateRunning},
+	}
+
+	for _, t := range invalid {
+		t := t
+		It("rejects "+string(t.from)+" to "+string(t.to)+" and leaves the state untouched", func() {
+			container.State = t.from
+			err := stateMachine.Transition(&container, t.to)
+			Expect(err).To(Equal(containerstore.InvalidTransitionError{From: t.from, To: t.to}))
+			Expect(container.State).To(Equal(t.from))
+		})
+	}
+
+	It("reports the name of ErrInvalidTransition and both states", func() {
+		container.State = executor.StateCompleted
+		err := stateMachine.Validate(&container, executor.StateRunning)
+
+		Expect(err.(executor.Error).Name()).To(Equal(executor.ErrInvalidTransition.Name()))
+		Expect(err.Error()).To(ContainSubstring("completed -> running"))
+	})
+
+	It("matches ErrInvalidTransition", func() {
+		container.State = executor.StateCompleted
+		err := stateMachine.Validate(&container, executor.StateRunning)
+
+		Expect(errors.Is(err, executor.ErrInvalidTransition)).To(BeTrue())
+	})
+
+	It("agrees with Container.ValidateTransitionTo", func() {
+		for _, t := range append(allowed, invalid...) {
+			container.State = t.from
+			valid := container.ValidateTransitionTo(t.to)
+			Expect(stateMachine.Validate(&container, t.to) == nil).To(Equal(valid), string(t.from)+" -> "+string(t.to))
+		}
+	})
+})
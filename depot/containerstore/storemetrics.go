@@ -0,0 +1,103 @@
+package containerstore
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	"code.cloudfoundry.org/executor/depot/resourceregistry"
+	loggregator "code.cloudfoundry.org/go-loggregator"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	StoreLockWaitMsMetric = "StoreLockWaitMs.containerstore"
+	StoreEntriesMetric    = "StoreEntries.containerstore"
+
+	ResourceRegistryEntriesMetric = "ResourceRegistryEntries.containerstore"
+	ResourceRegistryLeakMetric    = "ResourceRegistryLeak.containerstore"
+)
+
+type storeMetricsReporter struct {
+	logger       lager.Logger
+	config       *ContainerConfig
+	clock        clock.Clock
+	containers   *nodeMap
+	resources    *resourceregistry.Registry
+	metronClient loggingclient.IngressClient
+}
+
+func newStoreMetricsReporter(logger lager.Logger, config *ContainerConfig, clock clock.Clock, containers *nodeMap, resources *resourceregistry.Registry, metronClient loggingclient.IngressClient) *storeMetricsReporter {
+	return &storeMetricsReporter{
+		logger:       logger,
+		config:       config,
+		clock:        clock,
+		containers:   containers,
+		resources:    resources,
+		metronClient: metronClient,
+	}
+}
+
+func (r *storeMetricsReporter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := r.logger.Session("store-metrics-reporter")
+	timer := r.clock.NewTimer(r.config.StoreMetricsInterval)
+
+	close(ready)
+
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			r.report(logger)
+			timer.Reset(r.config.StoreMetricsInterval)
+		case signal := <-signals:
+			logger.Info("signalled", lager.Data{"signal": signal.String()})
+			return nil
+		}
+	}
+}
+
+func (r *storeMetricsReporter) report(logger lager.Logger) {
+	entries := r.containers.Count()
+	err := r.metronClient.SendMetric(StoreEntriesMetric, entries)
+	if err != nil {
+		logger.Error("failed-to-send-store-entries-metric", err)
+	}
+
+	r.reportResourceRegistry(logger, entries)
+
+	if !r.containers.lock.Sampling() {
+		return
+	}
+
+	stats := r.containers.lock.Collect()
+	err = r.metronClient.SendMetric(StoreLockWaitMsMetric, int(stats.Max/time.Millisecond), loggregator.WithEnvelopeTag("stat", "max"))
+	if err != nil {
+		logger.Error("failed-to-send-store-lock-wait-metric", err)
+	}
+	err = r.metronClient.SendMetric(StoreLockWaitMsMetric, int(stats.Avg/time.Millisecond), loggregator.WithEnvelopeTag("stat", "avg"))
+	if err != nil {
+		logger.Error("failed-to-send-store-lock-wait-metric", err)
+	}
+}
+
+// reportResourceRegistry emits the number of guids holding resources, and
+// raises an alarm when it exceeds the store entries by more than the
+// configured slack, which means some cleanup path was missed.
+func (r *storeMetricsReporter) reportResourceRegistry(logger lager.Logger, entries int) {
+	err := r.metronClient.SendMetric(ResourceRegistryEntriesMetric, r.resources.Count())
+	if err != nil {
+		logger.Error("failed-to-send-resource-registry-entries-metric", err)
+	}
+
+	excess := r.resources.Check(logger, entries, r.config.ResourceRegistrySlack)
+	if excess == 0 {
+		return
+	}
+
+	err = r.metronClient.SendMetric(ResourceRegistryLeakMetric, excess)
+	if err != nil {
+		logger.Error("failed-to-send-resource-registry-leak-metric", err)
+	}
+}
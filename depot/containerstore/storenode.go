@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -13,12 +15,17 @@ import (
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/executor/depot/log_streamer"
+	"code.cloudfoundry.org/executor/depot/resourceregistry"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/executor/depot/tarsanitizer"
 	"code.cloudfoundry.org/executor/depot/transformer"
 	"code.cloudfoundry.org/executor/initializer/configuration"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden/server"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/volman"
+	"github.com/hashicorp/go-multierror"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
 )
@@ -30,6 +37,7 @@ const ContainerMissingMessage = "missing garden container"
 const VolmanMountFailed = "failed to mount volume"
 const BindMountCleanupFailed = "failed to cleanup bindmount artifacts"
 const CredDirFailed = "failed to create credentials directory"
+const ImportedContainerUnmonitoredMessage = "imported container cannot be monitored"
 
 const maxErrorMsgLength = 1024
 
@@ -42,6 +50,18 @@ const (
 	ContainerSetupFailedDuration                = "ContainerSetupFailedDuration"
 )
 
+const ContainerStartDurationSecondsMetric = "ContainerStartDurationSeconds"
+const TrustedSystemCertsExcludedCount = "TrustedSystemCertsExcludedCount"
+
+const (
+	LogSourceNameProperty = "executor:log-source-name"
+	LogIndexProperty      = "executor:log-index"
+
+	// CPUSetProperty holds the cpuset.cpus value of a container pinned to
+	// specific cores, for the garden-cpuset runtime plugin to apply.
+	CPUSetProperty = "executor:cpuset"
+)
+
 //go:generate counterfeiter -o containerstorefakes/fake_proxymanager.go . ProxyManager
 type ProxyManager interface {
 	CredentialHandler
@@ -54,21 +74,35 @@ type storeNode struct {
 	metronClient                loggingclient.IngressClient
 
 	// infoLock protects modifying info and swapping gardenContainer pointers
-	infoLock           *sync.Mutex
-	info               executor.Container
-	bindMountCacheKeys []BindMountCacheKey
-	gardenContainer    garden.Container
+	infoLock            *sync.Mutex
+	info                executor.Container
+	stateMachine        *StateMachine
+	bindMountCacheKeys  []BindMountCacheKey
+	gardenContainer     garden.Container
+	gardenPropertyNames map[string]struct{}
+	// appliedNetOutRules are the egress rules garden applied when it created
+	// the container
+	appliedNetOutRules []garden.NetOutRule
+	// logStreamer is the streamer of the running steps, nil until Run
+	logStreamer log_streamer.LogStreamer
 
 	clock clock.Clock
 
+	// propertyLock serializes writes of garden properties
+	propertyLock *sync.Mutex
+
 	// opLock serializes public methods that involve garden interactions
 	opLock                                *sync.Mutex
 	gardenClient                          garden.Client
 	dependencyManager                     DependencyManager
 	volumeManager                         volman.Manager
+	volumeRefs                            *volumeRefCounter
+	volumesAcquired                       bool
+	resources                             *resourceregistry.Registry
 	credManager                           CredManager
 	instanceIdentityHandler               *InstanceIdentityHandler
 	eventEmitter                          event.Hub
+	deliveries                            *event.Deliveries
 	transformer                           transformer.Transformer
 	process                               ifrit.Process
 	config                                *ContainerConfig
@@ -81,8 +115,10 @@ type storeNode struct {
 	cellID                                string
 	enableUnproxiedPortMappings           bool
 	advertisePreferenceForInstanceAddress bool
+	completionNotifier                    CompletionNotifier
+	gardenHealthy                         func() bool
 
-	destroying, stopping int32
+	destroying, stopping, deleting int32
 
 	startTime time.Time
 }
@@ -96,8 +132,11 @@ func newStoreNode(
 	clock clock.Clock,
 	dependencyManager DependencyManager,
 	volumeManager volman.Manager,
+	volumeRefs *volumeRefCounter,
+	resources *resourceregistry.Registry,
 	credManager CredManager,
 	eventEmitter event.Hub,
+	deliveries *event.Deliveries,
 	transformer transformer.Transformer,
 	hostTrustedCertificatesPath string,
 	metronClient loggingclient.IngressClient,
@@ -106,18 +145,26 @@ func newStoreNode(
 	cellID string,
 	enableUnproxiedPortMappings bool,
 	advertisePreferenceForInstanceAddress bool,
+	completionNotifier CompletionNotifier,
+	gardenHealthy func() bool,
 ) *storeNode {
 	return &storeNode{
 		config:                                config,
 		info:                                  container,
 		infoLock:                              &sync.Mutex{},
+		stateMachine:                          NewStateMachine(),
+		gardenPropertyNames:                   map[string]struct{}{},
+		propertyLock:                          &sync.Mutex{},
 		opLock:                                &sync.Mutex{},
 		gardenClient:                          gardenClient,
 		clock:                                 clock,
 		dependencyManager:                     dependencyManager,
 		volumeManager:                         volumeManager,
+		volumeRefs:                            volumeRefs,
+		resources:                             resources,
 		credManager:                           credManager,
 		eventEmitter:                          eventEmitter,
+		deliveries:                            deliveries,
 		transformer:                           transformer,
 		modifiedIndex:                         0,
 		hostTrustedCertificatesPath:           hostTrustedCertificatesPath,
@@ -129,9 +176,17 @@ func newStoreNode(
 		cellID:                                cellID,
 		enableUnproxiedPortMappings:           enableUnproxiedPortMappings,
 		advertisePreferenceForInstanceAddress: advertisePreferenceForInstanceAddress,
+		completionNotifier:                    completionNotifier,
+		gardenHealthy:                         gardenHealthy,
 	}
 }
 
+func (n *storeNode) GardenPropertyCount() int {
+	n.infoLock.Lock()
+	defer n.infoLock.Unlock()
+	return len(n.gardenPropertyNames)
+}
+
 func (n *storeNode) acquireOpLock(logger lager.Logger) {
 	startTime := time.Now()
 	n.opLock.Lock()
@@ -150,6 +205,29 @@ func (n *storeNode) Info() executor.Container {
 	return n.info.Copy()
 }
 
+// MarkDeleting tombstones the node for an asynchronous destroy. It returns
+// false if the node was already marked.
+func (n *storeNode) MarkDeleting() bool {
+	return atomic.CompareAndSwapInt32(&n.deleting, 0, 1)
+}
+
+// ClearDeleting removes the tombstone. It returns false if the node was not
+// marked.
+func (n *storeNode) ClearDeleting() bool {
+	return atomic.CompareAndSwapInt32(&n.deleting, 1, 0)
+}
+
+// reportedInfo is the container as shown to executor clients: a tombstoned
+// container is reported as deleting. Events, metrics and the reaper work from
+// Info, which always carries the real state.
+func (n *storeNode) reportedInfo() executor.Container {
+	info := n.Info()
+	if atomic.LoadInt32(&n.deleting) == 1 {
+		info.State = executor.StateDeleting
+	}
+	return info
+}
+
 func (n *storeNode) GetFiles(logger lager.Logger, sourcePath string) (io.ReadCloser, error) {
 	n.infoLock.Lock()
 	gc := n.gardenContainer
@@ -157,22 +235,65 @@ func (n *storeNode) GetFiles(logger lager.Logger, sourcePath string) (io.ReadClo
 	if gc == nil {
 		return nil, executor.ErrContainerNotFound
 	}
-	return gc.StreamOut(garden.StreamOutSpec{Path: sourcePath, User: "root"})
+	stream, err := gc.StreamOut(garden.StreamOutSpec{Path: sourcePath, User: "root"})
+	if err != nil {
+		return nil, err
+	}
+	return tarsanitizer.Sanitize(logger, stream, n.config.TarSymlinkPolicy), nil
 }
 
 func (n *storeNode) Initialize(logger lager.Logger, req *executor.RunRequest) error {
 	logger = logger.Session("node-initialize")
+	err := executor.ValidateCachePartitionTag(req.CachePartitionTag)
+	if err != nil {
+		logger.Error("invalid-cache-partition-tag", err, lager.Data{"cache-partition-tag": req.CachePartitionTag})
+		return err
+	}
+
+	startTimeoutMs, err := n.config.boundStartTimeout(logger, req.RunInfo)
+	if err != nil {
+		return err
+	}
+
+	boundedReq := *req
+	boundedReq.StartTimeoutMs = startTimeoutMs
+
 	n.infoLock.Lock()
 	defer n.infoLock.Unlock()
 
-	err := n.info.TransistionToInitialize(req)
+	err = n.stateMachine.Transition(&n.info, executor.StateInitializing)
 	if err != nil {
 		logger.Error("failed-to-initialize", err)
 		return err
 	}
+	n.info.RunInfo = boundedReq.RunInfo
+	n.info.Tags.Add(boundedReq.Tags)
 	return nil
 }
 
+// partitionCachedDependencies returns a copy of dependencies with their cache
+// keys namespaced by the container's cache partition tag.
+func partitionCachedDependencies(tag string, dependencies []executor.CachedDependency) []executor.CachedDependency {
+	if tag == "" {
+		return dependencies
+	}
+
+	partitioned := make([]executor.CachedDependency, len(dependencies))
+	for i, dependency := range dependencies {
+		dependency.CacheKey = executor.PartitionedCacheKey(tag, dependency.CacheKey)
+		partitioned[i] = dependency
+	}
+	return partitioned
+}
+
+func (n *storeNode) healthcheckBindMount() garden.BindMount {
+	return garden.BindMount{
+		Origin:  garden.BindMountOriginHost,
+		SrcPath: n.declarativeHealthcheckPath,
+		DstPath: "/etc/cf-assets/healthcheck",
+	}
+}
+
 func (n *storeNode) Create(logger lager.Logger) error {
 	logger = logger.Session("node-create")
 	n.acquireOpLock(logger)
@@ -182,15 +303,16 @@ func (n *storeNode) Create(logger lager.Logger) error {
 	info := n.info.Copy()
 	n.infoLock.Unlock()
 
-	if !info.ValidateTransitionTo(executor.StateCreated) {
-		logger.Error("failed-to-create", executor.ErrInvalidTransition)
-		return executor.ErrInvalidTransition
+	err := n.stateMachine.Validate(&info, executor.StateCreated)
+	if err != nil {
+		logger.Error("failed-to-create", err)
+		return err
 	}
 
 	createContainer := func() error {
-		logStreamer := logStreamerFromLogConfig(info.LogConfig, n.metronClient)
+		logStreamer := logStreamerFromLogConfig(info.LogConfig, n.metronClient, n.config.MaxLogLineLength)
 
-		mounts, err := n.dependencyManager.DownloadCachedDependencies(logger, info.CachedDependencies, logStreamer)
+		mounts, err := n.dependencyManager.DownloadCachedDependencies(logger, partitionCachedDependencies(info.CachePartitionTag, info.CachedDependencies), logStreamer)
 		if err != nil {
 			n.complete(logger, true, DownloadCachedDependenciesFailed, true)
 			return err
@@ -198,7 +320,12 @@ func (n *storeNode) Create(logger lager.Logger) error {
 
 		n.bindMounts = mounts.GardenBindMounts
 
-		if n.hostTrustedCertificatesPath != "" && info.TrustedSystemCertificatesPath != "" {
+		if info.ExcludeTrustedSystemCerts {
+			logger.Info("excluding-trusted-system-certificates")
+			if err := n.metronClient.IncrementCounter(TrustedSystemCertsExcludedCount); err != nil {
+				logger.Error("failed-to-increment-counter", err, lager.Data{"metric-name": TrustedSystemCertsExcludedCount})
+			}
+		} else if n.hostTrustedCertificatesPath != "" && info.TrustedSystemCertificatesPath != "" {
 			mount := garden.BindMount{
 				SrcPath: n.hostTrustedCertificatesPath,
 				DstPath: info.TrustedSystemCertificatesPath,
@@ -224,6 +351,7 @@ func (n *storeNode) Create(logger lager.Logger) error {
 		}
 		n.bindMounts = append(n.bindMounts, volumeMounts...)
 
+		n.registerCredsDirCleanup(n.info.Copy())
 		credMounts, envs, err := n.credManager.CreateCredDir(logger, n.info)
 		if err != nil {
 			n.complete(logger, true, CredDirFailed, true)
@@ -234,18 +362,20 @@ func (n *storeNode) Create(logger lager.Logger) error {
 
 		if n.useDeclarativeHealthCheck {
 			logger.Info("adding-healthcheck-bindmounts")
-			n.bindMounts = append(n.bindMounts, garden.BindMount{
-				Origin:  garden.BindMountOriginHost,
-				SrcPath: n.declarativeHealthcheckPath,
-				DstPath: "/etc/cf-assets/healthcheck",
-			})
+			n.bindMounts = append(n.bindMounts, n.healthcheckBindMount())
 		}
 
 		fmt.Fprintf(logStreamer.Stdout(), "Cell %s creating container for instance %s\n", n.cellID, n.Info().Guid)
 		gardenContainer, err := n.createGardenContainer(logger, &info)
 		if err != nil {
+			failure := classifyCreateFailure(err)
+			logger.Error("failed-to-create-container", err, lager.Data{"failure-type": failure.failureType})
+			if err := n.metronClient.IncrementCounter(failure.metric); err != nil {
+				logger.Error("failed-to-increment-counter", err, lager.Data{"metric-name": failure.metric})
+			}
+
 			fmt.Fprintf(logStreamer.Stderr(), "Cell %s failed to create container for instance %s: %s\n", n.cellID, n.Info().Guid, err.Error())
-			n.complete(logger, true, fmt.Sprintf("%s: %s", ContainerCreationFailedMessage, err.Error()), true)
+			n.completeWithFailureType(logger, true, fmt.Sprintf("%s: %s", ContainerCreationFailedMessage, err.Error()), failure.failureType, true)
 			return err
 		}
 		fmt.Fprintf(logStreamer.Stdout(), "Cell %s successfully created container for instance %s\n", n.cellID, n.Info().Guid)
@@ -253,7 +383,7 @@ func (n *storeNode) Create(logger lager.Logger) error {
 		n.infoLock.Lock()
 		n.gardenContainer = gardenContainer
 		n.info = info
-		err = n.info.TransitionToCreate()
+		err = n.stateMachine.Transition(&n.info, executor.StateCreated)
 		n.bindMountCacheKeys = mounts.CacheKeys
 		n.infoLock.Unlock()
 
@@ -261,7 +391,7 @@ func (n *storeNode) Create(logger lager.Logger) error {
 	}
 
 	n.startTime = n.clock.Now()
-	err := createContainer()
+	err = createContainer()
 	if err != nil {
 		duration := n.clock.Since(n.startTime)
 		logger.Error("container-setup-failed", err, lager.Data{"duration": duration})
@@ -272,6 +402,13 @@ func (n *storeNode) Create(logger lager.Logger) error {
 }
 
 func (n *storeNode) mountVolumes(logger lager.Logger, info executor.Container) ([]garden.BindMount, error) {
+	// take a reference on every volume up front so that the references taken
+	// here always match the unmounts attempted in umountVolumeMounts
+	for _, volume := range info.VolumeMounts {
+		n.volumeRefs.Acquire(volumeKey(volume))
+	}
+	n.volumesAcquired = true
+
 	gardenMounts := []garden.BindMount{}
 	for _, volume := range info.VolumeMounts {
 		hostMount, err := n.volumeManager.Mount(logger, volume.Driver, volume.VolumeId, info.Guid, volume.Config)
@@ -289,16 +426,38 @@ func (n *storeNode) mountVolumes(logger lager.Logger, info executor.Container) (
 	return gardenMounts, nil
 }
 
-func (n *storeNode) gardenProperties(container *executor.Container) garden.Properties {
+func (n *storeNode) gardenProperties(logger lager.Logger, container *executor.Container) garden.Properties {
 	properties := garden.Properties{}
+	for key, value := range container.ExtraProperties {
+		if executor.IsReservedProperty(key) {
+			logger.Info("skipping-reserved-extra-property", lager.Data{"property": key})
+			continue
+		}
+		properties[key] = value
+	}
 	if container.Network != nil {
 		for key, value := range container.Network.Properties {
-			properties["network."+key] = value
+			properties[executor.NetworkPropertyPrefix+key] = value
 		}
 	}
 	properties[executor.ContainerOwnerProperty] = n.config.OwnerName
+	properties[LogSourceNameProperty] = container.LogConfig.SourceName
+	properties[LogIndexProperty] = strconv.Itoa(container.LogConfig.Index)
+	restartProperties(container, properties)
+	if len(container.CPUPinCores) > 0 {
+		properties[CPUSetProperty] = cpusetCPUs(container.CPUPinCores)
+	}
 
-	return properties
+	return limitGardenProperties(logger, properties, n.config.MaxGardenProperties)
+}
+
+// cpusetCPUs formats cores as a cpuset.cpus list, such as "0,2,3".
+func cpusetCPUs(cores []int) string {
+	list := make([]string, len(cores))
+	for i, core := range cores {
+		list[i] = strconv.Itoa(core)
+	}
+	return strings.Join(list, ",")
 }
 
 func dedupPorts(ports []executor.PortMapping) []executor.PortMapping {
@@ -347,7 +506,11 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 		}
 	}
 
-	diskLimitBytesHard := uint64(info.DiskMB) * 1024 * 1024
+	// garden treats a hard limit of 0 as unlimited
+	var diskLimitBytesHard uint64
+	if info.DiskMB != executor.DiskUnlimited {
+		diskLimitBytesHard = uint64(info.DiskMB) * 1024 * 1024
+	}
 	if diskLimitBytesHard != 0 {
 		diskLimitBytesHard += n.rootFSSizer.RootFSSizeFromPath(info.RootFSPath)
 	}
@@ -367,7 +530,7 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 			},
 			Disk: garden.DiskLimits{
 				ByteHard:  diskLimitBytesHard,
-				InodeHard: n.config.INodeLimit,
+				InodeHard: n.config.inodeLimit(info.Resource),
 				Scope:     garden.DiskLimitScopeTotal,
 			},
 			Pid: garden.PidLimits{
@@ -377,7 +540,7 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 				LimitInShares: uint64(float64(n.config.MaxCPUShares) * float64(info.CPUWeight) / 100.0),
 			},
 		},
-		Properties: n.gardenProperties(info),
+		Properties: n.gardenProperties(logger, info),
 		NetIn:      netInRules,
 		NetOut:     netOutRules,
 	}
@@ -402,10 +565,25 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 	info.Ports = n.portMappingFromContainerInfo(containerInfo, info.Ports, proxyPortMapping)
 	info.ExternalIP = containerInfo.ExternalIP
 	info.InternalIP = containerInfo.ContainerIP
+	if info.InternalIP == "" && n.config.InternalIPWaitTimeout > 0 {
+		internalIP, err := n.credManager.WaitForInternalIP(logger, gardenContainer, n.config.InternalIPWaitTimeout)
+		if err != nil {
+			logger.Error("failed-to-wait-for-internal-ip", err)
+		}
+		info.InternalIP = internalIP
+	}
 	info.AdvertisePreferenceForInstanceAddress = n.advertisePreferenceForInstanceAddress
 
 	info.MemoryLimit = containerSpec.Limits.Memory.LimitInBytes
 	info.DiskLimit = containerSpec.Limits.Disk.ByteHard
+	info.InodeLimit = containerSpec.Limits.Disk.InodeHard
+
+	n.infoLock.Lock()
+	for key := range containerSpec.Properties {
+		n.gardenPropertyNames[key] = struct{}{}
+	}
+	n.appliedNetOutRules = containerSpec.NetOut
+	n.infoLock.Unlock()
 
 	return gardenContainer, nil
 }
@@ -466,12 +644,15 @@ func (n *storeNode) Run(logger lager.Logger) error {
 	n.acquireOpLock(logger)
 	defer n.releaseOpLock(logger)
 
-	if n.info.State != executor.StateCreated {
-		logger.Error("failed-to-run", executor.ErrInvalidTransition)
-		return executor.ErrInvalidTransition
+	n.infoLock.Lock()
+	err := n.stateMachine.Validate(&n.info, executor.StateRunning)
+	n.infoLock.Unlock()
+	if err != nil {
+		logger.Error("failed-to-run", err)
+		return err
 	}
 
-	logStreamer := logStreamerFromLogConfig(n.info.LogConfig, n.metronClient)
+	logStreamer := logStreamerFromLogConfig(n.info.LogConfig, n.metronClient, n.config.MaxLogLineLength)
 
 	credManagerRunner := n.credManager.Runner(logger, n.info)
 
@@ -479,26 +660,125 @@ func (n *storeNode) Run(logger lager.Logger) error {
 	for i, p := range n.info.Ports {
 		proxyTLSPorts[i] = p.ContainerTLSProxyPort
 	}
+	egressRules, err := convertEgressToNetOut(logger, n.info.EgressRules)
+	if err != nil {
+		return err
+	}
+
 	cfg := transformer.Config{
 		BindMounts:        n.bindMounts,
 		ProxyTLSPorts:     proxyTLSPorts,
 		CreationStartTime: n.startTime,
 		MetronClient:      n.metronClient,
+		EgressRules:       unappliedNetOutRules(egressRules, n.appliedNetOutRules),
+		HealthCheckReporter: &healthCheckRecorder{
+			logger: logger.Session("health-check"),
+			node:   n,
+		},
 	}
 	runner, err := n.transformer.StepsRunner(logger, n.info, n.gardenContainer, logStreamer, cfg)
 	if err != nil {
 		return err
 	}
 
+	n.infoLock.Lock()
+	n.logStreamer = logStreamer
+	n.infoLock.Unlock()
+
 	group := grouper.NewQueueOrdered(os.Interrupt, grouper.Members{
 		{"cred-manager-runner", credManagerRunner},
 		{"runner", runner},
 	})
 	n.process = ifrit.Background(group)
+	n.registerProcessCleanup(n.process, logStreamer)
 	go n.run(logger)
 	return nil
 }
 
+// resume watches an imported running container through its health monitor,
+// as its steps ran under another executor. The container completes when the
+// monitor fails, as it would have under Run.
+func (n *storeNode) resume(logger lager.Logger) error {
+	logger = logger.Session("node-resume")
+
+	n.acquireOpLock(logger)
+	defer n.releaseOpLock(logger)
+
+	n.infoLock.Lock()
+	info := n.info.Copy()
+	n.infoLock.Unlock()
+
+	// snapshots written before the restart count was recorded do not carry
+	// it, but the garden container does
+	if info.RestartCount == 0 {
+		restoreRestartState(logger, n.gardenContainer, &info)
+
+		n.infoLock.Lock()
+		n.info.RestartCount = info.RestartCount
+		n.info.LastCrashAt = info.LastCrashAt
+		n.infoLock.Unlock()
+	}
+
+	logStreamer := logStreamerFromLogConfig(info.LogConfig, n.metronClient, n.config.MaxLogLineLength)
+
+	cfg := transformer.Config{
+		BindMounts:   n.bindMounts,
+		MetronClient: n.metronClient,
+		HealthCheckReporter: &healthCheckRecorder{
+			logger: logger.Session("health-check"),
+			node:   n,
+		},
+		MonitorOnly: true,
+	}
+	runner, err := n.transformer.StepsRunner(logger, info, n.gardenContainer, logStreamer, cfg)
+	if err != nil {
+		logger.Error("failed-to-build-monitor", err)
+		return err
+	}
+
+	n.infoLock.Lock()
+	n.logStreamer = logStreamer
+	n.infoLock.Unlock()
+
+	n.process = ifrit.Background(runner)
+	n.registerProcessCleanup(n.process, logStreamer)
+	go func() {
+		err := <-n.process.Wait()
+		n.completeWithError(logger, err)
+	}()
+	return nil
+}
+
+// UpdateLogConfig records the new log config, re-routes the logs of the
+// running steps and updates the log properties of the garden container. The
+// guid of the log config is only used by streamers created after the update.
+func (n *storeNode) UpdateLogConfig(logger lager.Logger, logConfig executor.LogConfig) error {
+	n.infoLock.Lock()
+	n.info.LogConfig = logConfig
+	logStreamer := n.logStreamer
+	gardenContainer := n.gardenContainer
+	n.infoLock.Unlock()
+
+	if logStreamer != nil {
+		logStreamer.UpdateLogConfig(logConfig.SourceName, logConfig.Index, logConfig.Tags)
+	}
+
+	if gardenContainer == nil {
+		return nil
+	}
+
+	err := n.setGardenProperties(logger, garden.Properties{
+		LogSourceNameProperty: logConfig.SourceName,
+		LogIndexProperty:      strconv.Itoa(logConfig.Index),
+	})
+	if err != nil {
+		logger.Error("failed-to-set-log-properties", err)
+		return err
+	}
+
+	return nil
+}
+
 func (n *storeNode) completeWithError(logger lager.Logger, err error) {
 	exitTrace, ok := err.(grouper.ErrorTrace)
 	if ok {
@@ -523,6 +803,10 @@ func (n *storeNode) completeWithError(logger lager.Logger, err error) {
 		errorStr = err.Error()
 	}
 
+	n.infoLock.Lock()
+	n.info.RunResult.ExitReason = steps.ExitReasonFor(err)
+	n.infoLock.Unlock()
+
 	if errorStr != "" {
 		n.complete(logger, true, errorStr, false)
 		return
@@ -542,16 +826,39 @@ func (n *storeNode) run(logger lager.Logger) {
 	}
 	logger.Debug("healthcheck-passed")
 
+	// the container may have been reaped or expired while starting, in which
+	// case it is already complete and must not be reported as running
 	n.infoLock.Lock()
-	n.info.State = executor.StateRunning
+	err := n.stateMachine.Transition(&n.info, executor.StateRunning)
 	info := n.info.Copy()
 	n.infoLock.Unlock()
-	go n.eventEmitter.Emit(executor.NewContainerRunningEvent(info))
+	if err != nil {
+		logger.Error("failed-to-transition-to-running", err)
+	} else {
+		go n.eventEmitter.Emit(executor.NewContainerRunningEvent(info))
+		n.emitStartDuration(logger)
+	}
 
-	err := <-n.process.Wait()
+	err = <-n.process.Wait()
 	n.completeWithError(logger, err)
 }
 
+// emitStartDuration sends the time from the start of Create to the container
+// running, so that slow garden creates or artifact downloads show up in the
+// start time distribution. Imported containers were not created here and
+// report nothing.
+func (n *storeNode) emitStartDuration(logger lager.Logger) {
+	if n.startTime.IsZero() {
+		return
+	}
+
+	duration := n.clock.Since(n.startTime)
+	err := n.metronClient.SendComponentMetric(ContainerStartDurationSecondsMetric, duration.Seconds(), "s")
+	if err != nil {
+		logger.Error("failed-to-send-container-start-duration", err)
+	}
+}
+
 func (n *storeNode) Stop(logger lager.Logger) {
 	if !atomic.CompareAndSwapInt32(&n.stopping, 0, 1) {
 		return
@@ -572,7 +879,7 @@ func (n *storeNode) stop(logger lager.Logger) {
 	n.infoLock.Unlock()
 	if n.process != nil {
 		if !stopped {
-			logStreamer := logStreamerFromLogConfig(n.info.LogConfig, n.metronClient)
+			logStreamer := logStreamerFromLogConfig(n.info.LogConfig, n.metronClient, n.config.MaxLogLineLength)
 			fmt.Fprintf(logStreamer.Stdout(), "Cell %s stopping instance %s\n", n.cellID, n.Info().Guid)
 		}
 
@@ -590,6 +897,11 @@ func (n *storeNode) Destroy(logger lager.Logger) error {
 	defer atomic.StoreInt32(&n.destroying, 0)
 
 	logger = logger.Session("node-destroy")
+
+	// the hook may take up to its timeout, so it runs before taking the
+	// opLock to not hold up Stop and the other operations meanwhile
+	n.runPreDestroyHook(logger, n.Info().Guid)
+
 	n.acquireOpLock(logger)
 	defer n.releaseOpLock(logger)
 
@@ -603,15 +915,16 @@ func (n *storeNode) Destroy(logger lager.Logger) error {
 	info := n.info.Copy()
 	n.infoLock.Unlock()
 
-	logStreamer := logStreamerFromLogConfig(info.LogConfig, n.metronClient)
+	logStreamer := logStreamerFromLogConfig(info.LogConfig, n.metronClient, n.config.MaxLogLineLength)
 
 	fmt.Fprintf(logStreamer.Stdout(), "Cell %s destroying container for instance %s\n", n.cellID, info.Guid)
 
-	// ensure these directories are removed even if the container fails to destroy
-	defer n.removeCredsDir(logger, info)
-	defer n.umountVolumeMounts(logger, info)
-
 	err := n.destroyContainer(logger)
+
+	// unmount the volumes even if the container fails to destroy; the
+	// credentials directory is removed through the resource registry
+	unmountErr := n.umountVolumeMounts(logger, info)
+
 	if err != nil {
 		fmt.Fprintf(logStreamer.Stdout(), "Cell %s failed to destroy container for instance %s\n", n.cellID, info.Guid)
 		return err
@@ -627,7 +940,10 @@ func (n *storeNode) Destroy(logger lager.Logger) error {
 		bindMountCleanupErr = errors.New(BindMountCleanupFailed)
 	}
 
-	return bindMountCleanupErr
+	if bindMountCleanupErr != nil {
+		return bindMountCleanupErr
+	}
+	return unmountErr
 }
 
 func (n *storeNode) destroyContainer(logger lager.Logger) error {
@@ -673,8 +989,8 @@ func (n *storeNode) Expire(logger lager.Logger, now time.Time) bool {
 
 	lifespan := now.Sub(time.Unix(0, n.info.AllocatedAt))
 	if lifespan >= n.config.ReservedExpirationTime {
-		n.info.TransitionToComplete(true, ContainerExpirationMessage, false)
-		go n.eventEmitter.Emit(executor.NewContainerCompleteEvent(n.info))
+		n.transitionToComplete(true, ContainerExpirationMessage, false)
+		n.emitInBackground(executor.NewContainerCompleteEvent(n.info))
 		return true
 	}
 
@@ -688,23 +1004,99 @@ func (n *storeNode) Reap(logger lager.Logger) bool {
 	defer n.infoLock.Unlock()
 
 	if n.info.IsCreated() {
-		// ensure these directories are removed even if the container fails to destroy
-		n.removeCredsDir(logger, n.info.Copy())
-
-		n.info.TransitionToComplete(true, ContainerMissingMessage, false)
-		go n.eventEmitter.Emit(executor.NewContainerCompleteEvent(n.info))
+		n.transitionToComplete(true, ContainerMissingMessage, false)
+		n.emitInBackground(executor.NewContainerCompleteEvent(n.info))
 		return true
 	}
 
 	return false
 }
 
+// emitInBackground emits ev without blocking the caller. The delivery is
+// tracked so that shutdown waits for it before closing the event hub.
+func (n *storeNode) emitInBackground(ev executor.Event) {
+	delivered := n.deliveries.Start()
+	go func() {
+		defer delivered()
+		n.eventEmitter.Emit(ev)
+	}()
+}
+
+// transitionToComplete records the run result and completes the container.
+// Completion is allowed from every state, so it never fails. Callers must
+// hold infoLock.
+func (n *storeNode) transitionToComplete(failed bool, failureReason string, retryable bool) {
+	n.stateMachine.Transition(&n.info, executor.StateCompleted)
+	n.info.RunResult.Failed = failed
+	n.info.RunResult.FailureReason = failureReason
+	n.info.RunResult.Retryable = retryable
+}
+
 func (n *storeNode) complete(logger lager.Logger, failed bool, failureReason string, retryable bool) {
-	logger.Debug("node-complete", lager.Data{"failed": failed, "reason": failureReason})
+	n.completeWithFailureType(logger, failed, failureReason, "", retryable)
+}
+
+func (n *storeNode) completeWithFailureType(logger lager.Logger, failed bool, failureReason, failureType string, retryable bool) {
+	logger.Debug("node-complete", lager.Data{"failed": failed, "reason": failureReason, "failure-type": failureType})
+
+	n.infoLock.Lock()
+	sampleMetrics := n.gardenContainer != nil
+	n.transitionToComplete(failed, failureReason, retryable)
+	n.info.RunResult.FailureType = failureType
+	if n.info.RunResult.Stopped {
+		n.info.RunResult.ExitReason = executor.ExitReasonStopRequested
+	} else if failed {
+		n.info.LastCrashAt = n.clock.Now().UnixNano()
+		n.info.SuggestedBackoffMs = n.config.suggestedBackoffMs(&n.info)
+	}
+	info := n.info.Copy()
+	completeEvent := executor.NewContainerCompleteEvent(n.info)
+	n.infoLock.Unlock()
+
+	// the final metrics sample can take up to FinalMetricsTimeout, so it is
+	// taken alongside the event emission rather than delaying completion
+	delivered := n.deliveries.Start()
+	go func() {
+		defer delivered()
+		if sampleMetrics {
+			completeEvent.FinalMetrics = n.sampleFinalMetrics(logger, info)
+		}
+		n.eventEmitter.Emit(completeEvent)
+	}()
+
+	n.completionNotifier.Notify(logger, info, n.completionCallbackDelivered)
+}
+
+func (n *storeNode) completionCallbackDelivered() {
 	n.infoLock.Lock()
 	defer n.infoLock.Unlock()
-	n.info.TransitionToComplete(failed, failureReason, retryable)
-	go n.eventEmitter.Emit(executor.NewContainerCompleteEvent(n.info))
+	n.info.CompletionCallbackDelivered = true
+}
+
+// registerCredsDirCleanup removes the credentials directory of the container
+// once it is removed from the store, whichever path it took to get there.
+func (n *storeNode) registerCredsDirCleanup(info executor.Container) {
+	n.resources.Register(info.Guid, "credentials-dir", func(logger lager.Logger) {
+		n.removeCredsDir(logger, info)
+	})
+}
+
+// registerProcessCleanup kills the steps process of the container and drops
+// its log streamer once the container is removed from the store. Destroy has
+// normally waited for the process already, in which case the kill does
+// nothing.
+func (n *storeNode) registerProcessCleanup(process ifrit.Process, logStreamer log_streamer.LogStreamer) {
+	guid := n.Info().Guid
+	n.resources.Register(guid, "steps-process", func(logger lager.Logger) {
+		process.Signal(os.Kill)
+	})
+	n.resources.Register(guid, "log-streamer", func(logger lager.Logger) {
+		logStreamer.Flush()
+
+		n.infoLock.Lock()
+		n.logStreamer = nil
+		n.infoLock.Unlock()
+	})
 }
 
 func (n *storeNode) removeCredsDir(logger lager.Logger, info executor.Container) {
@@ -714,13 +1106,29 @@ func (n *storeNode) removeCredsDir(logger lager.Logger, info executor.Container)
 	}
 }
 
-func (n *storeNode) umountVolumeMounts(logger lager.Logger, info executor.Container) {
+// umountVolumeMounts unmounts every volume of the container, carrying on past
+// failures, and returns the failures together.
+func (n *storeNode) umountVolumeMounts(logger lager.Logger, info executor.Container) error {
+	var result *multierror.Error
 	for _, volume := range info.VolumeMounts {
-		err := n.volumeManager.Unmount(logger, volume.Driver, volume.VolumeId, info.Guid)
+		volume := volume
+		unmount := func() error {
+			return n.volumeManager.Unmount(logger, volume.Driver, volume.VolumeId, info.Guid)
+		}
+
+		var err error
+		if n.volumesAcquired {
+			err = n.volumeRefs.Release(volumeKey(volume), unmount)
+		} else {
+			err = unmount()
+		}
 		if err != nil {
-			logger.Error("failed-to-unmount-volume", err)
+			logger.Error("failed-to-unmount-volume", err, lager.Data{"volume-id": volume.VolumeId})
+			result = multierror.Append(result, err)
 		}
 	}
+	n.volumesAcquired = false
+	return result.ErrorOrNil()
 }
 
 func createContainer(logger lager.Logger, spec garden.ContainerSpec, client garden.Client, metronClient loggingclient.IngressClient) (garden.Container, error) {
@@ -0,0 +1,71 @@
+package containerstore
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/executor"
+)
+
+// volumeRefCounter serializes unmounts of the same volman volume by
+// containers being destroyed concurrently. Every container still unmounts the
+// volume under its own guid, so that volman releases the driver reference it
+// holds for that container; the lock only keeps the unmounts from racing.
+type volumeRefCounter struct {
+	lock    *sync.Mutex
+	volumes map[string]*volumeRef
+}
+
+type volumeRef struct {
+	count int
+
+	// unmountLock serializes unmounts of the same volume
+	unmountLock sync.Mutex
+}
+
+func newVolumeRefCounter() *volumeRefCounter {
+	return &volumeRefCounter{
+		lock:    &sync.Mutex{},
+		volumes: make(map[string]*volumeRef),
+	}
+}
+
+func volumeKey(volume executor.VolumeMount) string {
+	return volume.Driver + ":" + volume.VolumeId
+}
+
+func (v *volumeRefCounter) Acquire(key string) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	ref, ok := v.volumes[key]
+	if !ok {
+		ref = &volumeRef{}
+		v.volumes[key] = ref
+	}
+	ref.count++
+}
+
+// Release drops a reference to the volume and invokes unmount while holding
+// the volume's lock. Unmount errors are returned to the caller.
+func (v *volumeRefCounter) Release(key string, unmount func() error) error {
+	v.lock.Lock()
+	ref, ok := v.volumes[key]
+	if !ok {
+		v.lock.Unlock()
+		return unmount()
+	}
+	v.lock.Unlock()
+
+	ref.unmountLock.Lock()
+	err := unmount()
+	ref.unmountLock.Unlock()
+
+	v.lock.Lock()
+	ref.count--
+	if ref.count <= 0 && v.volumes[key] == ref {
+		delete(v.volumes, key)
+	}
+	v.lock.Unlock()
+
+	return err
+}
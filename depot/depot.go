@@ -1,16 +1,18 @@
 package depot
 
 import (
+	"archive/tar"
 	"io"
+	"io/ioutil"
 	"sync"
 
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/containerstore"
 	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/executor/depot/workpoolstats"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/volman"
-	"code.cloudfoundry.org/workpool"
 )
 
 const ContainerStoppedBeforeRunMessage = "Container stopped by user"
@@ -21,13 +23,24 @@ type client struct {
 	gardenClient     garden.Client
 	volmanClient     volman.Manager
 	eventHub         event.Hub
-	creationWorkPool *workpool.WorkPool
-	deletionWorkPool *workpool.WorkPool
-	readWorkPool     *workpool.WorkPool
-	metricsWorkPool  *workpool.WorkPool
+	creationWorkPool *workpoolstats.WorkPool
+	deletionWorkPool *workpoolstats.WorkPool
+	readWorkPool     *workpoolstats.WorkPool
+	metricsWorkPool  *workpoolstats.WorkPool
+
+	// gardenCreateLimiter bounds the number of containers being created in
+	// garden at once. It is nil when creates are unbounded.
+	gardenCreateLimiter chan struct{}
+
+	// asyncDeletion makes DeleteContainer return once the container is marked
+	// as deleting, leaving the destroy to the deletion work pool.
+	asyncDeletion bool
 
 	healthyLock sync.RWMutex
 	healthy     bool
+	// gardenReady is set once garden passes its first healthcheck. Until then
+	// allocations and runs are turned away.
+	gardenReady bool
 }
 
 func NewClient(
@@ -36,11 +49,18 @@ func NewClient(
 	gardenClient garden.Client,
 	volmanClient volman.Manager,
 	eventHub event.Hub,
-	creationWorkPool *workpool.WorkPool,
-	deletionWorkPool *workpool.WorkPool,
-	readWorkPool *workpool.WorkPool,
-	metricsWorkPool *workpool.WorkPool,
+	creationWorkPool *workpoolstats.WorkPool,
+	deletionWorkPool *workpoolstats.WorkPool,
+	readWorkPool *workpoolstats.WorkPool,
+	metricsWorkPool *workpoolstats.WorkPool,
+	maxConcurrentGardenCreates int,
+	asyncDeletion bool,
 ) executor.Client {
+	var gardenCreateLimiter chan struct{}
+	if maxConcurrentGardenCreates > 0 {
+		gardenCreateLimiter = make(chan struct{}, maxConcurrentGardenCreates)
+	}
+
 	return &client{
 		totalCapacity:    totalCapacity,
 		containerStore:   containerStore,
@@ -52,6 +72,9 @@ func NewClient(
 		readWorkPool:     readWorkPool,
 		metricsWorkPool:  metricsWorkPool,
 		healthy:          true,
+
+		gardenCreateLimiter: gardenCreateLimiter,
+		asyncDeletion:       asyncDeletion,
 	}
 }
 
@@ -67,6 +90,14 @@ func (c *client) AllocateContainers(logger lager.Logger, requests []executor.All
 	logger = logger.Session("allocate-containers")
 	failures := make([]executor.AllocationFailure, 0)
 
+	if !c.isGardenReady() {
+		logger.Info("garden-not-ready")
+		for i := range requests {
+			failures = append(failures, executor.NewAllocationFailure(&requests[i], executor.ErrGardenNotReady.Error()))
+		}
+		return failures
+	}
+
 	for i := range requests {
 		req := &requests[i]
 		err := req.Validate()
@@ -105,9 +136,26 @@ func (c *client) RunContainer(logger lager.Logger, request *executor.RunRequest)
 		"guid": request.Guid,
 	})
 
+	if !c.isGardenReady() {
+		logger.Info("garden-not-ready")
+		return executor.ErrGardenNotReady
+	}
+
+	if !c.acquireGardenCreate() {
+		logger.Info("too-many-concurrent-garden-creates")
+		// the container was already reserved by AllocateContainers, so its
+		// resources are released here rather than held until it is deleted
+		err := c.containerStore.Destroy(logger, request.Guid)
+		if err != nil {
+			logger.Error("failed-to-release-reservation", err)
+		}
+		return executor.ErrTooManyConcurrentCreates
+	}
+
 	logger.Debug("initializing-container")
 	err := c.containerStore.Initialize(logger, request)
 	if err != nil {
+		c.releaseGardenCreate()
 		logger.Error("failed-initializing-container", err)
 		return err
 	}
@@ -117,10 +165,32 @@ func (c *client) RunContainer(logger lager.Logger, request *executor.RunRequest)
 	return nil
 }
 
+// acquireGardenCreate reserves a garden create slot without blocking, so
+// that callers are turned away rather than queued when garden is busy.
+func (c *client) acquireGardenCreate() bool {
+	if c.gardenCreateLimiter == nil {
+		return true
+	}
+
+	select {
+	case c.gardenCreateLimiter <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *client) releaseGardenCreate() {
+	if c.gardenCreateLimiter != nil {
+		<-c.gardenCreateLimiter
+	}
+}
+
 func (c *client) newRunContainerWorker(logger lager.Logger, guid string) func() {
 	return func() {
 		logger.Info("creating-container")
 		_, err := c.containerStore.Create(logger, guid)
+		c.releaseGardenCreate()
 		if err != nil {
 			logger.Error("failed-creating-container", err)
 			return
@@ -148,6 +218,13 @@ func (c *client) ListContainers(logger lager.Logger) ([]executor.Container, erro
 	return c.containerStore.List(logger), nil
 }
 
+// Import re-registers containers from a snapshot taken from another
+// executor, such as the output of ListContainers, without creating new garden
+// containers.
+func (c *client) Import(logger lager.Logger, snapshot []executor.Container) error {
+	return c.containerStore.Import(logger.Session("import"), snapshot)
+}
+
 func (c *client) GetBulkMetrics(logger lager.Logger) (map[string]executor.Metrics, error) {
 	errChannel := make(chan error, 1)
 	metricsChannel := make(chan map[string]executor.Metrics, 1)
@@ -188,6 +265,14 @@ func (c *client) GetBulkMetrics(logger lager.Logger) (map[string]executor.Metric
 	return metrics, err
 }
 
+func (c *client) UpdateLogConfig(logger lager.Logger, guid string, logConfig executor.LogConfig) error {
+	logger = logger.Session("update-log-config", lager.Data{"guid": guid})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	return c.containerStore.UpdateLogConfig(logger, guid, logConfig)
+}
+
 func (c *client) StopContainer(logger lager.Logger, guid string) error {
 	logger = logger.Session("stop-container")
 	logger.Info("starting")
@@ -202,12 +287,20 @@ func (c *client) DeleteContainer(logger lager.Logger, guid string) error {
 	logger.Info("starting")
 	defer logger.Info("complete")
 
+	if c.asyncDeletion {
+		return c.deleteContainerAsync(logger, guid)
+	}
+
 	errChannel := make(chan error, 1)
 	c.deletionWorkPool.Submit(func() {
 		errChannel <- c.containerStore.Destroy(logger, guid)
 	})
 
 	err := <-errChannel
+	if err == executor.ErrContainerNotFound {
+		logger.Debug("container-already-absent")
+		return nil
+	}
 
 	if err != nil {
 		logger.Error("failed-to-delete-garden-container", err)
@@ -216,11 +309,61 @@ func (c *client) DeleteContainer(logger lager.Logger, guid string) error {
 	return err
 }
 
+// deleteContainerAsync tombstones the container and destroys it in the
+// background. Deleting a container that is already being deleted does
+// nothing. If the destroy fails, the container is reported in its real state
+// again so that the caller's next delete retries it.
+func (c *client) deleteContainerAsync(logger lager.Logger, guid string) error {
+	marked, err := c.containerStore.MarkDeleting(logger, guid)
+	if err == executor.ErrContainerNotFound {
+		logger.Debug("container-already-absent")
+		return nil
+	}
+	if err != nil {
+		logger.Error("failed-to-mark-container-deleting", err)
+		return err
+	}
+
+	if !marked {
+		logger.Info("container-already-deleting")
+		return nil
+	}
+
+	c.deletionWorkPool.Submit(func() {
+		err := c.containerStore.Destroy(logger, guid)
+		if err != nil {
+			logger.Error("failed-to-delete-garden-container", err)
+		}
+	})
+
+	return nil
+}
+
 func (c *client) RemainingResources(logger lager.Logger) (executor.ExecutorResources, error) {
 	logger = logger.Session("remaining-resources")
 	return c.containerStore.RemainingResources(logger), nil
 }
 
+// RemainingCapacity returns the resources still available for allocation. The
+// value is read from a single snapshot of the container store and never
+// reports negative capacity.
+func (c *client) RemainingCapacity(logger lager.Logger) (executor.ExecutorResources, error) {
+	logger = logger.Session("remaining-capacity")
+
+	remaining := c.containerStore.RemainingResources(logger)
+	if remaining.MemoryMB < 0 {
+		remaining.MemoryMB = 0
+	}
+	if remaining.DiskMB < 0 {
+		remaining.DiskMB = 0
+	}
+	if remaining.Containers < 0 {
+		remaining.Containers = 0
+	}
+
+	return remaining, nil
+}
+
 func (c *client) Ping(logger lager.Logger) error {
 	return c.gardenClient.Ping()
 }
@@ -235,6 +378,26 @@ func (c *client) TotalResources(logger lager.Logger) (executor.ExecutorResources
 	}, nil
 }
 
+// TagContainer sets each tag as a property of the container in garden, so that
+// running containers can be annotated without being restarted.
+func (c *client) TagContainer(logger lager.Logger, guid string, tags executor.Tags) error {
+	logger = logger.Session("tag-container", lager.Data{"guid": guid})
+
+	err := executor.ValidateContainerTags(tags)
+	if err != nil {
+		logger.Error("invalid-tags", err)
+		return err
+	}
+
+	err = c.containerStore.Tag(logger, guid, tags)
+	if err != nil {
+		logger.Error("failed-to-tag-container", err)
+		return err
+	}
+
+	return nil
+}
+
 func (c *client) GetFiles(logger lager.Logger, guid, sourcePath string) (io.ReadCloser, error) {
 	logger = logger.Session("get-files", lager.Data{
 		"guid": guid,
@@ -261,6 +424,80 @@ func (c *client) GetFiles(logger lager.Logger, guid, sourcePath string) (io.Read
 	return readCloser, err
 }
 
+// GetFileRange streams part of a single file out of a container. The skipped
+// prefix is read from garden and discarded rather than buffered. Directories
+// and other sources that are not a single regular file are rejected with
+// ErrRangeNotSatisfiable, as are ranges that start past the end of the file.
+func (c *client) GetFileRange(logger lager.Logger, guid, sourcePath string, byteRange executor.ByteRange) (executor.PartialFile, error) {
+	logger = logger.Session("get-file-range", lager.Data{
+		"guid":       guid,
+		"byte-range": byteRange,
+	})
+
+	stream, err := c.GetFiles(logger, guid, sourcePath)
+	if err != nil {
+		return executor.PartialFile{}, err
+	}
+
+	partialFile, err := readFileRange(stream, byteRange)
+	if err != nil {
+		logger.Error("failed-to-read-range", err)
+		stream.Close()
+		return executor.PartialFile{}, err
+	}
+
+	return partialFile, nil
+}
+
+// TailFile streams the last n bytes of a single file out of a container. n
+// must be positive.
+func (c *client) TailFile(logger lager.Logger, guid, sourcePath string, n int64) (io.ReadCloser, error) {
+	if n <= 0 {
+		return nil, executor.ErrRangeNotSatisfiable
+	}
+	return c.GetFileRange(logger, guid, sourcePath, executor.ByteRange{Suffix: n})
+}
+
+func readFileRange(stream io.ReadCloser, byteRange executor.ByteRange) (executor.PartialFile, error) {
+	tarReader := tar.NewReader(stream)
+	header, err := tarReader.Next()
+	if err == io.EOF {
+		return executor.PartialFile{}, executor.ErrRangeNotSatisfiable
+	}
+	if err != nil {
+		return executor.PartialFile{}, err
+	}
+
+	if header.Typeflag != tar.TypeReg && header.Typeflag != tar.TypeRegA {
+		return executor.PartialFile{}, executor.ErrRangeNotSatisfiable
+	}
+
+	start, end, err := byteRange.Resolve(header.Size)
+	if err != nil {
+		return executor.PartialFile{}, err
+	}
+
+	_, err = io.CopyN(ioutil.Discard, tarReader, start)
+	if err != nil {
+		return executor.PartialFile{}, err
+	}
+
+	return executor.PartialFile{
+		ReadCloser: rangeReadCloser{
+			Reader: io.LimitReader(tarReader, end-start+1),
+			Closer: stream,
+		},
+		Start: start,
+		End:   end,
+		Size:  header.Size,
+	}, nil
+}
+
+type rangeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
 func (c *client) VolumeDrivers(logger lager.Logger) ([]string, error) {
 	logger = logger.Session("volume-drivers")
 
@@ -291,4 +528,14 @@ func (c *client) SetHealthy(logger lager.Logger, healthy bool) {
 	c.healthyLock.Lock()
 	defer c.healthyLock.Unlock()
 	c.healthy = healthy
+	if healthy {
+		c.gardenReady = true
+	}
+	c.containerStore.SetGardenHealthy(logger, healthy)
+}
+
+func (c *client) isGardenReady() bool {
+	c.healthyLock.RLock()
+	defer c.healthyLock.RUnlock()
+	return c.gardenReady
 }
@@ -1,20 +1,23 @@
 package depot_test
 
 import (
+	"archive/tar"
+	"bytes"
 	"errors"
 	"io"
+	"io/ioutil"
 	"time"
 
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot"
 	"code.cloudfoundry.org/executor/depot/containerstore/containerstorefakes"
 	efakes "code.cloudfoundry.org/executor/depot/event/fakes"
+	"code.cloudfoundry.org/executor/depot/workpoolstats"
 	"code.cloudfoundry.org/executor/fakes"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"code.cloudfoundry.org/volman"
 	"code.cloudfoundry.org/volman/volmanfakes"
-	"code.cloudfoundry.org/workpool"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
@@ -34,6 +37,9 @@ var _ = Describe("Depot", func() {
 		DeleteWorkPoolSize  int
 		ReadWorkPoolSize    int
 		MetricsWorkPoolSize int
+
+		maxConcurrentGardenCreates int
+		asyncDeletion              bool
 	)
 
 	BeforeEach(func() {
@@ -53,22 +59,26 @@ var _ = Describe("Depot", func() {
 		DeleteWorkPoolSize = 5
 		ReadWorkPoolSize = 5
 		MetricsWorkPoolSize = 5
+		maxConcurrentGardenCreates = 0
+		asyncDeletion = false
 	})
 
 	JustBeforeEach(func() {
-		creationWorkPool, err := workpool.NewWorkPool(CreateWorkPoolSize)
+		creationWorkPool, err := workpoolstats.NewWorkPool(CreateWorkPoolSize)
 		Expect(err).NotTo(HaveOccurred())
-		deletionWorkPool, err := workpool.NewWorkPool(DeleteWorkPoolSize)
+		deletionWorkPool, err := workpoolstats.NewWorkPool(DeleteWorkPoolSize)
 		Expect(err).NotTo(HaveOccurred())
-		readWorkPool, err := workpool.NewWorkPool(ReadWorkPoolSize)
+		readWorkPool, err := workpoolstats.NewWorkPool(ReadWorkPoolSize)
 		Expect(err).NotTo(HaveOccurred())
-		metricsWorkPool, err := workpool.NewWorkPool(MetricsWorkPoolSize)
+		metricsWorkPool, err := workpoolstats.NewWorkPool(MetricsWorkPoolSize)
 		Expect(err).NotTo(HaveOccurred())
 
 		depotClient = depot.NewClient(
 			resources, containerStore, gardenClient, volmanClient, eventHub,
 			creationWorkPool, deletionWorkPool, readWorkPool, metricsWorkPool,
+			maxConcurrentGardenCreates, asyncDeletion,
 		)
+		depotClient.SetHealthy(logger, true)
 	})
 
 	Describe("AllocateContainers", func() {
@@ -249,6 +259,62 @@ var _ = Describe("Depot", func() {
 				Expect(logger).To(gbytes.Say("run-container.failed-running-container-in-garden"))
 			})
 		})
+		Context("when the maximum number of concurrent garden creates is in flight", func() {
+			var doneChan chan struct{}
+
+			BeforeEach(func() {
+				maxConcurrentGardenCreates = 1
+				doneChan = make(chan struct{})
+				containerStore.CreateStub = func(logger lager.Logger, guid string) (executor.Container, error) {
+					<-doneChan
+					return executor.Container{}, nil
+				}
+			})
+
+			AfterEach(func() {
+				close(doneChan)
+			})
+
+			It("rejects further requests without initializing them", func() {
+				Expect(depotClient.RunContainer(logger, newRunRequest("guid-1"))).To(Succeed())
+				Eventually(containerStore.CreateCallCount).Should(Equal(1))
+
+				err := depotClient.RunContainer(logger, newRunRequest("guid-2"))
+				Expect(err).To(Equal(executor.ErrTooManyConcurrentCreates))
+				Expect(containerStore.InitializeCallCount()).To(Equal(1))
+			})
+
+			It("releases the reservation of rejected requests", func() {
+				Expect(depotClient.RunContainer(logger, newRunRequest("guid-1"))).To(Succeed())
+				Eventually(containerStore.CreateCallCount).Should(Equal(1))
+
+				err := depotClient.RunContainer(logger, newRunRequest("guid-2"))
+				Expect(err).To(Equal(executor.ErrTooManyConcurrentCreates))
+
+				Expect(containerStore.DestroyCallCount()).To(Equal(1))
+				_, guid := containerStore.DestroyArgsForCall(0)
+				Expect(guid).To(Equal("guid-2"))
+			})
+
+			It("admits requests again once the create completes", func() {
+				Expect(depotClient.RunContainer(logger, newRunRequest("guid-1"))).To(Succeed())
+				doneChan <- struct{}{}
+				Eventually(containerStore.RunCallCount).Should(Equal(1))
+
+				Expect(depotClient.RunContainer(logger, newRunRequest("guid-2"))).To(Succeed())
+			})
+
+			Context("when initializing fails", func() {
+				BeforeEach(func() {
+					containerStore.InitializeReturnsOnCall(0, executor.ErrContainerNotFound)
+				})
+
+				It("releases the create slot", func() {
+					Expect(depotClient.RunContainer(logger, newRunRequest("guid-1"))).To(Equal(executor.ErrContainerNotFound))
+					Expect(depotClient.RunContainer(logger, newRunRequest("guid-2"))).To(Succeed())
+				})
+			})
+		})
 	})
 
 	Describe("Throttling", func() {
@@ -463,6 +529,47 @@ var _ = Describe("Depot", func() {
 		})
 	})
 
+	Describe("Import", func() {
+		It("registers the snapshot with the container store", func() {
+			snapshot := []executor.Container{{Guid: "guid-1", State: executor.StateRunning}}
+
+			err := depotClient.Import(logger, snapshot)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(containerStore.ImportCallCount()).To(Equal(1))
+			_, imported := containerStore.ImportArgsForCall(0)
+			Expect(imported).To(Equal(snapshot))
+		})
+
+		It("returns errors from the container store", func() {
+			containerStore.ImportReturns(executor.ErrContainerGuidNotAvailable)
+
+			err := depotClient.Import(logger, nil)
+			Expect(err).To(Equal(executor.ErrContainerGuidNotAvailable))
+		})
+	})
+
+	Describe("UpdateLogConfig", func() {
+		It("updates the log config in the container store", func() {
+			logConfig := executor.LogConfig{Guid: "guid-1", Index: 3, SourceName: "source"}
+
+			err := depotClient.UpdateLogConfig(logger, "guid-1", logConfig)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(containerStore.UpdateLogConfigCallCount()).To(Equal(1))
+			_, guid, updated := containerStore.UpdateLogConfigArgsForCall(0)
+			Expect(guid).To(Equal("guid-1"))
+			Expect(updated).To(Equal(logConfig))
+		})
+
+		It("returns errors from the container store", func() {
+			containerStore.UpdateLogConfigReturns(executor.ErrContainerNotFound)
+
+			err := depotClient.UpdateLogConfig(logger, "guid-1", executor.LogConfig{})
+			Expect(err).To(Equal(executor.ErrContainerNotFound))
+		})
+	})
+
 	Describe("GetBulkMetrics", func() {
 		var metrics map[string]executor.Metrics
 		var metricsErr error
@@ -584,6 +691,261 @@ var _ = Describe("Depot", func() {
 				Expect(err).To(HaveOccurred())
 			})
 		})
+
+		Context("when the container is already gone", func() {
+			BeforeEach(func() {
+				containerStore.DestroyReturnsOnCall(1, executor.ErrContainerNotFound)
+			})
+
+			It("succeeds when deleting the same container twice", func() {
+				err := depotClient.DeleteContainer(logger, "guid-1")
+				Expect(err).NotTo(HaveOccurred())
+
+				err = depotClient.DeleteContainer(logger, "guid-1")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(containerStore.DestroyCallCount()).To(Equal(2))
+				Expect(logger).To(gbytes.Say("container-already-absent"))
+			})
+		})
+
+		Context("when deletion is asynchronous", func() {
+			var destroyed chan struct{}
+
+			BeforeEach(func() {
+				asyncDeletion = true
+				destroyed = make(chan struct{})
+				containerStore.MarkDeletingReturns(true, nil)
+				containerStore.DestroyStub = func(lager.Logger, string) error {
+					<-destroyed
+					return nil
+				}
+			})
+
+			AfterEach(func() {
+				close(destroyed)
+			})
+
+			It("marks the container as deleting and returns before it is destroyed", func() {
+				err := depotClient.DeleteContainer(logger, "guid-1")
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(containerStore.MarkDeletingCallCount()).To(Equal(1))
+				_, guid := containerStore.MarkDeletingArgsForCall(0)
+				Expect(guid).To(Equal("guid-1"))
+
+				Eventually(containerStore.DestroyCallCount).Should(Equal(1))
+				_, guid = containerStore.DestroyArgsForCall(0)
+				Expect(guid).To(Equal("guid-1"))
+			})
+
+			Context("when the container is already being deleted", func() {
+				BeforeEach(func() {
+					containerStore.MarkDeletingReturns(false, nil)
+				})
+
+				It("does nothing", func() {
+					err := depotClient.DeleteContainer(logger, "guid-1")
+					Expect(err).NotTo(HaveOccurred())
+					Consistently(containerStore.DestroyCallCount).Should(Equal(0))
+				})
+			})
+
+			Context("when the container does not exist", func() {
+				BeforeEach(func() {
+					containerStore.MarkDeletingReturns(false, executor.ErrContainerNotFound)
+				})
+
+				It("succeeds without destroying anything", func() {
+					err := depotClient.DeleteContainer(logger, "guid-1")
+					Expect(err).NotTo(HaveOccurred())
+					Consistently(containerStore.DestroyCallCount).Should(Equal(0))
+				})
+			})
+		})
+	})
+
+	Describe("TagContainer", func() {
+		var (
+			tags   executor.Tags
+			tagErr error
+		)
+
+		BeforeEach(func() {
+			tags = executor.Tags{"pipeline": "deploy", "version": "1.2.3"}
+		})
+
+		JustBeforeEach(func() {
+			tagErr = depotClient.TagContainer(logger, "guid-1", tags)
+		})
+
+		It("tags the container in the container store", func() {
+			Expect(tagErr).NotTo(HaveOccurred())
+
+			Expect(containerStore.TagCallCount()).To(Equal(1))
+			_, guid, storedTags := containerStore.TagArgsForCall(0)
+			Expect(guid).To(Equal("guid-1"))
+			Expect(storedTags).To(Equal(executor.Tags{"pipeline": "deploy", "version": "1.2.3"}))
+		})
+
+		Context("when a tag uses a reserved property prefix", func() {
+			BeforeEach(func() {
+				tags["network.app_id"] = "some-app"
+			})
+
+			It("returns ErrReservedContainerTag without tagging the container", func() {
+				Expect(tagErr).To(Equal(executor.ErrReservedContainerTag))
+				Expect(containerStore.TagCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when tagging the container fails", func() {
+			BeforeEach(func() {
+				containerStore.TagReturns(executor.ErrGardenPropertyLimitExceeded)
+			})
+
+			It("returns the error", func() {
+				Expect(tagErr).To(Equal(executor.ErrGardenPropertyLimitExceeded))
+			})
+		})
+	})
+
+	Describe("GetFileRange", func() {
+		var (
+			fileContents string
+			stream       *closeTracker
+			byteRange    executor.ByteRange
+			partialFile  executor.PartialFile
+			rangeErr     error
+		)
+
+		tarStream := func(headers ...*tar.Header) *closeTracker {
+			buffer := new(bytes.Buffer)
+			tarWriter := tar.NewWriter(buffer)
+			for _, header := range headers {
+				Expect(tarWriter.WriteHeader(header)).To(Succeed())
+				if header.Typeflag == tar.TypeReg {
+					_, err := tarWriter.Write([]byte(fileContents))
+					Expect(err).NotTo(HaveOccurred())
+				}
+			}
+			Expect(tarWriter.Close()).To(Succeed())
+			return &closeTracker{Reader: buffer}
+		}
+
+		BeforeEach(func() {
+			fileContents = "0123456789abcdefghij"
+			stream = tarStream(&tar.Header{
+				Name:     "app.log",
+				Typeflag: tar.TypeReg,
+				Mode:     0644,
+				Size:     int64(len(fileContents)),
+			})
+			containerStore.GetFilesReturns(stream, nil)
+		})
+
+		JustBeforeEach(func() {
+			partialFile, rangeErr = depotClient.GetFileRange(logger, "guid-1", "/home/vcap/logs/app.log", byteRange)
+		})
+
+		Context("with a suffix range", func() {
+			BeforeEach(func() {
+				byteRange = executor.ByteRange{Suffix: 5}
+			})
+
+			It("streams the last bytes of the file", func() {
+				Expect(rangeErr).NotTo(HaveOccurred())
+
+				_, guid, sourcePath := containerStore.GetFilesArgsForCall(0)
+				Expect(guid).To(Equal("guid-1"))
+				Expect(sourcePath).To(Equal("/home/vcap/logs/app.log"))
+
+				Expect(ioutil.ReadAll(partialFile)).To(Equal([]byte("fghij")))
+				Expect(partialFile.Start).To(BeEquivalentTo(15))
+				Expect(partialFile.End).To(BeEquivalentTo(19))
+				Expect(partialFile.Size).To(BeEquivalentTo(20))
+			})
+
+			It("closes the garden stream when the partial file is closed", func() {
+				Expect(partialFile.Close()).To(Succeed())
+				Expect(stream.closed).To(BeTrue())
+			})
+		})
+
+		Context("with a bounded range", func() {
+			BeforeEach(func() {
+				byteRange = executor.ByteRange{Start: 2, End: 5}
+			})
+
+			It("streams only the requested bytes", func() {
+				Expect(rangeErr).NotTo(HaveOccurred())
+				Expect(ioutil.ReadAll(partialFile)).To(Equal([]byte("2345")))
+				Expect(partialFile.Start).To(BeEquivalentTo(2))
+				Expect(partialFile.End).To(BeEquivalentTo(5))
+			})
+		})
+
+		Context("when the range starts past the end of the file", func() {
+			BeforeEach(func() {
+				byteRange = executor.ByteRange{Start: 20, End: -1}
+			})
+
+			It("returns ErrRangeNotSatisfiable and closes the stream", func() {
+				Expect(rangeErr).To(Equal(executor.ErrRangeNotSatisfiable))
+				Expect(stream.closed).To(BeTrue())
+			})
+		})
+
+		Context("when the source is a directory", func() {
+			BeforeEach(func() {
+				byteRange = executor.ByteRange{Suffix: 5}
+				stream = tarStream(
+					&tar.Header{Name: "logs/", Typeflag: tar.TypeDir, Mode: 0755},
+					&tar.Header{Name: "logs/app.log", Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(fileContents))},
+				)
+				containerStore.GetFilesReturns(stream, nil)
+			})
+
+			It("returns ErrRangeNotSatisfiable", func() {
+				Expect(rangeErr).To(Equal(executor.ErrRangeNotSatisfiable))
+				Expect(stream.closed).To(BeTrue())
+			})
+		})
+
+		Context("when getting the files fails", func() {
+			BeforeEach(func() {
+				containerStore.GetFilesReturns(nil, executor.ErrContainerNotFound)
+			})
+
+			It("returns the error", func() {
+				Expect(rangeErr).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+	})
+
+	Describe("TailFile", func() {
+		It("streams the last n bytes of the file", func() {
+			buffer := new(bytes.Buffer)
+			tarWriter := tar.NewWriter(buffer)
+			Expect(tarWriter.WriteHeader(&tar.Header{Name: "app.log", Typeflag: tar.TypeReg, Mode: 0644, Size: 11})).To(Succeed())
+			_, err := tarWriter.Write([]byte("hello world"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(tarWriter.Close()).To(Succeed())
+			containerStore.GetFilesReturns(ioutil.NopCloser(buffer), nil)
+
+			tail, err := depotClient.TailFile(logger, "guid-1", "app.log", 5)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ioutil.ReadAll(tail)).To(Equal([]byte("world")))
+		})
+
+		It("rejects a non-positive length without reading the file", func() {
+			_, err := depotClient.TailFile(logger, "guid-1", "app.log", 0)
+			Expect(err).To(Equal(executor.ErrRangeNotSatisfiable))
+
+			_, err = depotClient.TailFile(logger, "guid-1", "app.log", -1)
+			Expect(err).To(Equal(executor.ErrRangeNotSatisfiable))
+
+			Expect(containerStore.GetFilesCallCount()).To(Equal(0))
+		})
 	})
 
 	Describe("StopContainer", func() {
@@ -661,6 +1023,25 @@ var _ = Describe("Depot", func() {
 		})
 	})
 
+	Describe("RemainingCapacity", func() {
+		It("returns the remaining resources", func() {
+			remaining := executor.NewExecutorResources(512, 256, 2)
+			containerStore.RemainingResourcesReturns(remaining)
+
+			Expect(depotClient.RemainingCapacity(logger)).To(Equal(remaining))
+		})
+
+		Context("when the remaining resources are negative", func() {
+			BeforeEach(func() {
+				containerStore.RemainingResourcesReturns(executor.NewExecutorResources(-10, 256, -1))
+			})
+
+			It("clamps them to zero", func() {
+				Expect(depotClient.RemainingCapacity(logger)).To(Equal(executor.NewExecutorResources(0, 256, 0)))
+			})
+		})
+	})
+
 	Describe("TotalResources", func() {
 		Context("when asked for total resources", func() {
 			It("should return the resources it was configured with", func() {
@@ -698,6 +1079,47 @@ var _ = Describe("Depot", func() {
 			})
 		})
 	})
+	Describe("SetHealthy", func() {
+		It("tells the container store whether garden is healthy", func() {
+			depotClient.SetHealthy(logger, false)
+			Expect(depotClient.Healthy(logger)).To(BeFalse())
+			Expect(containerStore.SetGardenHealthyCallCount()).To(Equal(2))
+			_, healthy := containerStore.SetGardenHealthyArgsForCall(1)
+			Expect(healthy).To(BeFalse())
+		})
+	})
+
+	Context("before garden has passed a healthcheck", func() {
+		BeforeEach(func() {
+			depotClient = depot.NewClient(
+				resources, containerStore, gardenClient, volmanClient, eventHub,
+				creationWorkPool, deletionWorkPool, readWorkPool, metricsWorkPool,
+				maxConcurrentGardenCreates, asyncDeletion,
+			)
+		})
+
+		It("rejects allocations", func() {
+			request := newAllocationRequest("guid-1")
+			failures := depotClient.AllocateContainers(logger, []executor.AllocationRequest{request})
+			Expect(failures).To(ConsistOf(executor.NewAllocationFailure(&request, executor.ErrGardenNotReady.Error())))
+			Expect(containerStore.ReserveCallCount()).To(Equal(0))
+		})
+
+		It("rejects runs", func() {
+			err := depotClient.RunContainer(logger, &executor.RunRequest{Guid: "guid-1"})
+			Expect(err).To(Equal(executor.ErrGardenNotReady))
+			Expect(containerStore.InitializeCallCount()).To(Equal(0))
+		})
+
+		It("accepts allocations once garden is healthy, even if it later becomes unhealthy", func() {
+			depotClient.SetHealthy(logger, true)
+			depotClient.SetHealthy(logger, false)
+
+			request := newAllocationRequest("guid-1")
+			Expect(depotClient.AllocateContainers(logger, []executor.AllocationRequest{request})).To(BeEmpty())
+			Expect(containerStore.ReserveCallCount()).To(Equal(1))
+		})
+	})
 })
 
 func convertSliceToMap(containers []executor.Container) map[string]executor.Container {
@@ -729,3 +1151,13 @@ func newRunningContainer(req *executor.RunRequest, res executor.Resource) execut
 	c.RunInfo = req.RunInfo
 	return c
 }
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
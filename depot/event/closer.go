@@ -0,0 +1,65 @@
+package event
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+const DrainPollInterval = 100 * time.Millisecond
+
+// NewCloser returns a runner that closes hub once it is signalled. Before
+// closing, it waits up to drainTimeout for the deliveries in flight to reach
+// the hub, so that completions racing the shutdown still reach subscribers.
+// A zero drainTimeout closes immediately.
+func NewCloser(
+	logger lager.Logger,
+	hub Hub,
+	deliveries *Deliveries,
+	clock clock.Clock,
+	drainTimeout time.Duration,
+) ifrit.Runner {
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		close(ready)
+		signal := <-signals
+
+		logger := logger.Session("close-hub")
+		logger.Info("signalled", lager.Data{"signal": signal.String()})
+
+		if drainTimeout > 0 {
+			drain(logger, deliveries, clock, drainTimeout)
+		}
+
+		hub.Close()
+		logger.Info("closed")
+		return nil
+	})
+}
+
+func drain(logger lager.Logger, deliveries *Deliveries, clock clock.Clock, drainTimeout time.Duration) {
+	logger = logger.Session("drain", lager.Data{"timeout": drainTimeout.String()})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	timer := clock.NewTimer(drainTimeout)
+	defer timer.Stop()
+	ticker := clock.NewTicker(DrainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		pending := deliveries.Pending()
+		if pending == 0 {
+			return
+		}
+
+		select {
+		case <-ticker.C():
+		case <-timer.C():
+			logger.Info("timed-out", lager.Data{"pending-deliveries": pending})
+			return
+		}
+	}
+}
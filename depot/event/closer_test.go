@@ -0,0 +1,108 @@
+package event_test
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Closer", func() {
+	var (
+		logger       *lagertest.TestLogger
+		hub          event.Hub
+		deliveries   *event.Deliveries
+		fakeClock    *fakeclock.FakeClock
+		drainTimeout time.Duration
+		process      ifrit.Process
+
+		delivered func()
+	)
+
+	complete := func() {
+		hub.Emit(executor.NewContainerCompleteEvent(executor.Container{Guid: "some-guid", State: executor.StateCompleted}))
+		delivered()
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		hub = event.NewHub(logger, 0)
+		deliveries = event.NewDeliveries()
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		drainTimeout = 10 * time.Second
+
+		delivered = deliveries.Start()
+	})
+
+	JustBeforeEach(func() {
+		process = ifrit.Invoke(event.NewCloser(logger, hub, deliveries, fakeClock, drainTimeout))
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Kill)
+		fakeClock.Increment(drainTimeout)
+	})
+
+	It("delivers completions that race the shutdown before closing the hub", func() {
+		source, err := hub.Subscribe()
+		Expect(err).NotTo(HaveOccurred())
+
+		process.Signal(os.Interrupt)
+		Consistently(process.Wait()).ShouldNot(Receive())
+
+		complete()
+		ev, err := source.Next()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ev.EventType()).To(Equal(executor.EventTypeContainerComplete))
+		Expect(ev.(executor.ContainerCompleteEvent).Container().Guid).To(Equal("some-guid"))
+
+		fakeClock.WaitForNWatchersAndIncrement(event.DrainPollInterval, 2)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+
+		_, err = hub.Subscribe()
+		Expect(err).To(HaveOccurred())
+	})
+
+	Context("when the deliveries do not finish within the drain timeout", func() {
+		It("closes the hub and logs completions emitted afterwards", func() {
+			process.Signal(os.Interrupt)
+
+			fakeClock.WaitForNWatchersAndIncrement(drainTimeout, 2)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+			Expect(logger).To(gbytes.Say("close-hub.drain.timed-out"))
+
+			complete()
+			Expect(logger).To(gbytes.Say("event-hub.emit-after-close"))
+			Expect(logger).To(gbytes.Say("some-guid"))
+		})
+	})
+
+	Context("when the drain timeout is zero", func() {
+		BeforeEach(func() {
+			drainTimeout = 0
+		})
+
+		It("closes the hub immediately", func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+	})
+
+	Context("when no deliveries are in flight", func() {
+		BeforeEach(func() {
+			delivered()
+		})
+
+		It("closes the hub without waiting", func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+	})
+})
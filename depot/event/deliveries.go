@@ -0,0 +1,39 @@
+package event
+
+import "sync"
+
+// Deliveries counts events that have been handed off to be emitted in the
+// background but have not reached the hub yet, so that the hub is not closed
+// underneath them on shutdown.
+type Deliveries struct {
+	lock    sync.Mutex
+	pending int
+}
+
+func NewDeliveries() *Deliveries {
+	return &Deliveries{}
+}
+
+// Start records a delivery in flight. The returned func marks it as done;
+// calling it more than once has no further effect.
+func (d *Deliveries) Start() func() {
+	d.lock.Lock()
+	d.pending++
+	d.lock.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			d.lock.Lock()
+			d.pending--
+			d.lock.Unlock()
+		})
+	}
+}
+
+// Pending returns the number of deliveries in flight.
+func (d *Deliveries) Pending() int {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return d.pending
+}
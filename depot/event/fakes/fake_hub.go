@@ -19,6 +19,20 @@ type FakeHub struct {
 	closeReturnsOnCall map[int]struct {
 		result1 error
 	}
+	DurableSubscribeStub        func(string, int64) (<-chan executor.Event, error)
+	durableSubscribeMutex       sync.RWMutex
+	durableSubscribeArgsForCall []struct {
+		arg1 string
+		arg2 int64
+	}
+	durableSubscribeReturns struct {
+		result1 <-chan executor.Event
+		result2 error
+	}
+	durableSubscribeReturnsOnCall map[int]struct {
+		result1 <-chan executor.Event
+		result2 error
+	}
 	EmitStub        func(executor.Event)
 	emitMutex       sync.RWMutex
 	emitArgsForCall []struct {
@@ -92,6 +106,70 @@ func (fake *FakeHub) CloseReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeHub) DurableSubscribe(arg1 string, arg2 int64) (<-chan executor.Event, error) {
+	fake.durableSubscribeMutex.Lock()
+	ret, specificReturn := fake.durableSubscribeReturnsOnCall[len(fake.durableSubscribeArgsForCall)]
+	fake.durableSubscribeArgsForCall = append(fake.durableSubscribeArgsForCall, struct {
+		arg1 string
+		arg2 int64
+	}{arg1, arg2})
+	fake.recordInvocation("DurableSubscribe", []interface{}{arg1, arg2})
+	fake.durableSubscribeMutex.Unlock()
+	if fake.DurableSubscribeStub != nil {
+		return fake.DurableSubscribeStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.durableSubscribeReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeHub) DurableSubscribeCallCount() int {
+	fake.durableSubscribeMutex.RLock()
+	defer fake.durableSubscribeMutex.RUnlock()
+	return len(fake.durableSubscribeArgsForCall)
+}
+
+func (fake *FakeHub) DurableSubscribeCalls(stub func(string, int64) (<-chan executor.Event, error)) {
+	fake.durableSubscribeMutex.Lock()
+	defer fake.durableSubscribeMutex.Unlock()
+	fake.DurableSubscribeStub = stub
+}
+
+func (fake *FakeHub) DurableSubscribeArgsForCall(i int) (string, int64) {
+	fake.durableSubscribeMutex.RLock()
+	defer fake.durableSubscribeMutex.RUnlock()
+	argsForCall := fake.durableSubscribeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeHub) DurableSubscribeReturns(result1 <-chan executor.Event, result2 error) {
+	fake.durableSubscribeMutex.Lock()
+	defer fake.durableSubscribeMutex.Unlock()
+	fake.DurableSubscribeStub = nil
+	fake.durableSubscribeReturns = struct {
+		result1 <-chan executor.Event
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeHub) DurableSubscribeReturnsOnCall(i int, result1 <-chan executor.Event, result2 error) {
+	fake.durableSubscribeMutex.Lock()
+	defer fake.durableSubscribeMutex.Unlock()
+	fake.DurableSubscribeStub = nil
+	if fake.durableSubscribeReturnsOnCall == nil {
+		fake.durableSubscribeReturnsOnCall = make(map[int]struct {
+			result1 <-chan executor.Event
+			result2 error
+		})
+	}
+	fake.durableSubscribeReturnsOnCall[i] = struct {
+		result1 <-chan executor.Event
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeHub) Emit(arg1 executor.Event) {
 	fake.emitMutex.Lock()
 	fake.emitArgsForCall = append(fake.emitArgsForCall, struct {
@@ -183,6 +261,8 @@ func (fake *FakeHub) Invocations() map[string][][]interface{} {
 	defer fake.invocationsMutex.RUnlock()
 	fake.closeMutex.RLock()
 	defer fake.closeMutex.RUnlock()
+	fake.durableSubscribeMutex.RLock()
+	defer fake.durableSubscribeMutex.RUnlock()
 	fake.emitMutex.RLock()
 	defer fake.emitMutex.RUnlock()
 	fake.subscribeMutex.RLock()
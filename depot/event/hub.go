@@ -1,27 +1,60 @@
 package event
 
 import (
+	"errors"
+	"sync"
+
 	"code.cloudfoundry.org/eventhub"
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
 )
 
 const SUBSCRIBER_BUFFER = 1024
 
+var (
+	ErrHubClosed   = errors.New("event hub is closed")
+	ErrGapTooLarge = errors.New("requested events are not available for replay")
+)
+
 //go:generate counterfeiter -o fakes/fake_hub.go . Hub
 type Hub interface {
 	Emit(executor.Event)
 	Subscribe() (executor.EventSource, error)
+
+	// DurableSubscribe delivers every event from sequence number from
+	// onwards, replaying the ones already emitted. Sequence numbers start at
+	// zero and increase by one per emitted event, so the n-th event received
+	// has sequence number from+n. Subscribing again with the same id closes
+	// the previous channel, as does falling too far behind.
+	DurableSubscribe(id string, from int64) (<-chan executor.Event, error)
+
 	Close() error
 }
 
-func NewHub() Hub {
+// NewHub returns a Hub that never blocks on slow subscribers. Events emitted
+// after the hub is closed cannot be delivered, so they are logged in full
+// rather than dropped silently. The last replayBufferSize events are kept for
+// durable subscribers to replay.
+func NewHub(logger lager.Logger, replayBufferSize int) Hub {
 	return &hub{
-		rawHub: eventhub.NewNonBlocking(SUBSCRIBER_BUFFER),
+		rawHub:  eventhub.NewNonBlocking(SUBSCRIBER_BUFFER),
+		logger:  logger.Session("event-hub"),
+		replay:  make([]executor.Event, replayBufferSize),
+		durable: map[string]chan executor.Event{},
 	}
 }
 
 type hub struct {
 	rawHub eventhub.Hub
+	logger lager.Logger
+
+	closedLock sync.RWMutex
+	closed     bool
+
+	replayLock   sync.Mutex
+	replay       []executor.Event
+	nextSequence int64
+	durable      map[string]chan executor.Event
 }
 
 func (hub *hub) Subscribe() (executor.EventSource, error) {
@@ -33,11 +66,102 @@ func (hub *hub) Subscribe() (executor.EventSource, error) {
 	return executorSource{rawSource}, nil
 }
 
+// DurableSubscribe returns ErrGapTooLarge when the events from from onwards
+// can no longer be replayed in full, including when from is ahead of the hub,
+// as happens after the executor restarts. The subscriber should then resync
+// its state and subscribe again.
+func (hub *hub) DurableSubscribe(id string, from int64) (<-chan executor.Event, error) {
+	hub.closedLock.RLock()
+	defer hub.closedLock.RUnlock()
+
+	if hub.closed {
+		return nil, ErrHubClosed
+	}
+
+	hub.replayLock.Lock()
+	defer hub.replayLock.Unlock()
+
+	if from < hub.oldestSequence() || from > hub.nextSequence {
+		hub.logger.Info("durable-subscribe-gap-too-large", lager.Data{
+			"id":            id,
+			"from":          from,
+			"next-sequence": hub.nextSequence,
+		})
+		return nil, ErrGapTooLarge
+	}
+
+	events := make(chan executor.Event, SUBSCRIBER_BUFFER+int(hub.nextSequence-from))
+	for sequence := from; sequence < hub.nextSequence; sequence++ {
+		events <- hub.replay[sequence%int64(len(hub.replay))]
+	}
+
+	if previous, ok := hub.durable[id]; ok {
+		close(previous)
+	}
+	hub.durable[id] = events
+
+	return events, nil
+}
+
+// oldestSequence returns the sequence number of the oldest event that can
+// still be replayed.
+func (hub *hub) oldestSequence() int64 {
+	oldest := hub.nextSequence - int64(len(hub.replay))
+	if oldest < 0 {
+		return 0
+	}
+	return oldest
+}
+
 func (hub *hub) Emit(ev executor.Event) {
+	hub.closedLock.RLock()
+	defer hub.closedLock.RUnlock()
+
+	if hub.closed {
+		hub.logger.Error("emit-after-close", ErrHubClosed, lager.Data{
+			"event-type": ev.EventType(),
+			"event":      ev,
+		})
+		return
+	}
+
 	hub.rawHub.Emit(ev)
+	hub.emitDurable(ev)
+}
+
+func (hub *hub) emitDurable(ev executor.Event) {
+	hub.replayLock.Lock()
+	defer hub.replayLock.Unlock()
+
+	if len(hub.replay) > 0 {
+		hub.replay[hub.nextSequence%int64(len(hub.replay))] = ev
+	}
+	hub.nextSequence++
+
+	for id, events := range hub.durable {
+		select {
+		case events <- ev:
+		default:
+			hub.logger.Info("dropping-slow-durable-subscriber", lager.Data{"id": id})
+			close(events)
+			delete(hub.durable, id)
+		}
+	}
 }
 
 func (hub *hub) Close() error {
+	hub.closedLock.Lock()
+	defer hub.closedLock.Unlock()
+
+	hub.closed = true
+
+	hub.replayLock.Lock()
+	for id, events := range hub.durable {
+		close(events)
+		delete(hub.durable, id)
+	}
+	hub.replayLock.Unlock()
+
 	return hub.rawHub.Close()
 }
 
@@ -0,0 +1,108 @@
+package event_test
+
+import (
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hub", func() {
+	var hub event.Hub
+
+	completed := func(guid string) executor.Event {
+		return executor.NewContainerCompleteEvent(executor.Container{Guid: guid})
+	}
+
+	emit := func(guids ...string) {
+		for _, guid := range guids {
+			hub.Emit(completed(guid))
+		}
+	}
+
+	BeforeEach(func() {
+		hub = event.NewHub(lagertest.NewTestLogger("test"), 3)
+	})
+
+	Describe("DurableSubscribe", func() {
+		It("replays the events emitted from the requested sequence number", func() {
+			emit("a", "b", "c")
+
+			events, err := hub.DurableSubscribe("bbs", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(Receive(Equal(completed("b"))))
+			Expect(events).To(Receive(Equal(completed("c"))))
+			Expect(events).NotTo(Receive())
+		})
+
+		It("delivers events emitted after subscribing", func() {
+			emit("a")
+
+			events, err := hub.DurableSubscribe("bbs", 1)
+			Expect(err).NotTo(HaveOccurred())
+
+			emit("b")
+			Expect(events).To(Receive(Equal(completed("b"))))
+		})
+
+		It("fails with ErrGapTooLarge when the events are no longer buffered", func() {
+			emit("a", "b", "c", "d")
+
+			_, err := hub.DurableSubscribe("bbs", 0)
+			Expect(err).To(Equal(event.ErrGapTooLarge))
+
+			events, err := hub.DurableSubscribe("bbs", 1)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(events).To(Receive(Equal(completed("b"))))
+		})
+
+		It("fails with ErrGapTooLarge when the sequence number has not been reached", func() {
+			emit("a")
+
+			_, err := hub.DurableSubscribe("bbs", 2)
+			Expect(err).To(Equal(event.ErrGapTooLarge))
+		})
+
+		It("closes the previous channel when the same subscriber reconnects", func() {
+			first, err := hub.DurableSubscribe("bbs", 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			emit("a")
+
+			second, err := hub.DurableSubscribe("bbs", 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(first).To(Receive(Equal(completed("a"))))
+			Expect(first).To(BeClosed())
+			Expect(second).To(Receive(Equal(completed("a"))))
+		})
+
+		It("leaves the regular subscribers alone", func() {
+			source, err := hub.Subscribe()
+			Expect(err).NotTo(HaveOccurred())
+
+			_, err = hub.DurableSubscribe("bbs", 0)
+			Expect(err).NotTo(HaveOccurred())
+
+			emit("a")
+
+			ev, err := source.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ev).To(Equal(completed("a")))
+		})
+
+		Context("when the hub is closed", func() {
+			It("closes the durable channels and refuses new subscribers", func() {
+				events, err := hub.DurableSubscribe("bbs", 0)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(hub.Close()).To(Succeed())
+				Expect(events).To(BeClosed())
+
+				_, err = hub.DurableSubscribe("bbs", 0)
+				Expect(err).To(Equal(event.ErrHubClosed))
+			})
+		})
+	})
+})
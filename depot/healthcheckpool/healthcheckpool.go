@@ -0,0 +1,138 @@
+package healthcheckpool
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/workpool"
+)
+
+type Phase string
+
+const (
+	PhaseReadiness Phase = "readiness"
+	PhaseLiveness  Phase = "liveness"
+)
+
+// Pool schedules health checks onto a work pool in two priorities. Readiness
+// checks of starting containers are always dispatched before liveness checks
+// of running ones, and a fraction of the workers is reserved for readiness
+// checks so that they are not starved when every worker is busy with liveness
+// checks.
+type Pool struct {
+	workPool         *workpool.WorkPool
+	workers          int
+	livenessWorkers  int
+	lock             sync.Mutex
+	runningLiveness  int
+	running          int
+	readinessBacklog []func()
+	livenessBacklog  []func()
+}
+
+// New returns a Pool that submits at most workers checks at a time to
+// workPool, which should have at least as many workers. reservedFraction of
+// the workers only run readiness checks; at least one worker is always left
+// for liveness checks.
+func New(workPool *workpool.WorkPool, workers int, reservedFraction float64) *Pool {
+	reserved := int(float64(workers) * reservedFraction)
+	if reserved < 0 {
+		reserved = 0
+	}
+	if reserved > workers-1 {
+		reserved = workers - 1
+	}
+
+	return &Pool{
+		workPool:        workPool,
+		workers:         workers,
+		livenessWorkers: workers - reserved,
+	}
+}
+
+// Queue returns the queue checks of the given phase are submitted to.
+func (p *Pool) Queue(phase Phase) *Queue {
+	return &Queue{pool: p, phase: phase}
+}
+
+// QueueDepth returns the number of checks of the given phase waiting for a
+// worker.
+func (p *Pool) QueueDepth(phase Phase) int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if phase == PhaseReadiness {
+		return len(p.readinessBacklog)
+	}
+	return len(p.livenessBacklog)
+}
+
+func (p *Pool) submit(phase Phase, work func()) {
+	p.lock.Lock()
+	if phase == PhaseReadiness {
+		p.readinessBacklog = append(p.readinessBacklog, work)
+	} else {
+		p.livenessBacklog = append(p.livenessBacklog, work)
+	}
+	dispatched := p.dispatch()
+	p.lock.Unlock()
+
+	for _, work := range dispatched {
+		p.workPool.Submit(work)
+	}
+}
+
+func (p *Pool) finish(phase Phase) {
+	p.lock.Lock()
+	p.running--
+	if phase == PhaseLiveness {
+		p.runningLiveness--
+	}
+	dispatched := p.dispatch()
+	p.lock.Unlock()
+
+	for _, work := range dispatched {
+		p.workPool.Submit(work)
+	}
+}
+
+// dispatch must be called with the lock held. It returns the work that has
+// been given a worker, to be submitted once the lock is released.
+func (p *Pool) dispatch() []func() {
+	var dispatched []func()
+	for p.running < p.workers {
+		var work func()
+		var phase Phase
+
+		if len(p.readinessBacklog) > 0 {
+			work, p.readinessBacklog = p.readinessBacklog[0], p.readinessBacklog[1:]
+			phase = PhaseReadiness
+		} else if len(p.livenessBacklog) > 0 && p.runningLiveness < p.livenessWorkers {
+			work, p.livenessBacklog = p.livenessBacklog[0], p.livenessBacklog[1:]
+			phase = PhaseLiveness
+			p.runningLiveness++
+		} else {
+			break
+		}
+
+		p.running++
+		dispatched = append(dispatched, p.wrap(phase, work))
+	}
+	return dispatched
+}
+
+func (p *Pool) wrap(phase Phase, work func()) func() {
+	return func() {
+		defer p.finish(phase)
+		work()
+	}
+}
+
+// Queue submits checks of a single phase to a Pool.
+type Queue struct {
+	pool  *Pool
+	phase Phase
+}
+
+func (q *Queue) Submit(work func()) {
+	q.pool.submit(q.phase, work)
+}
@@ -0,0 +1,13 @@
+package healthcheckpool_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestHealthCheckPool(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Health Check Pool Suite")
+}
@@ -0,0 +1,164 @@
+package healthcheckpool_test
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	mfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
+	"code.cloudfoundry.org/executor/depot/healthcheckpool"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
+	"code.cloudfoundry.org/lager/lagertest"
+	"code.cloudfoundry.org/workpool"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("Pool", func() {
+	const workers = 4
+
+	var (
+		workPool *workpool.WorkPool
+		pool     *healthcheckpool.Pool
+		release  chan struct{}
+		started  chan string
+
+		reservedFraction float64
+	)
+
+	blockingCheck := func(name string) func() {
+		return func() {
+			started <- name
+			<-release
+		}
+	}
+
+	BeforeEach(func() {
+		var err error
+		workPool, err = workpool.NewWorkPool(workers)
+		Expect(err).NotTo(HaveOccurred())
+
+		release = make(chan struct{})
+		started = make(chan string, 100)
+		reservedFraction = 0.25
+	})
+
+	JustBeforeEach(func() {
+		pool = healthcheckpool.New(workPool, workers, reservedFraction)
+	})
+
+	AfterEach(func() {
+		close(release)
+		workPool.Stop()
+	})
+
+	Context("when the pool is saturated with liveness checks", func() {
+		JustBeforeEach(func() {
+			for i := 0; i < 10; i++ {
+				pool.Queue(healthcheckpool.PhaseLiveness).Submit(blockingCheck("liveness"))
+			}
+		})
+
+		It("leaves the reserved workers idle", func() {
+			Eventually(started).Should(HaveLen(workers - 1))
+			Consistently(started).Should(HaveLen(workers - 1))
+			Expect(pool.QueueDepth(healthcheckpool.PhaseLiveness)).To(Equal(10 - (workers - 1)))
+		})
+
+		It("still runs a readiness check promptly", func() {
+			Eventually(started).Should(HaveLen(workers - 1))
+			pool.Queue(healthcheckpool.PhaseReadiness).Submit(blockingCheck("readiness"))
+			Eventually(started).Should(HaveLen(workers))
+			Expect(pool.QueueDepth(healthcheckpool.PhaseReadiness)).To(Equal(0))
+		})
+
+		Context("when no workers are reserved", func() {
+			BeforeEach(func() {
+				reservedFraction = 0
+			})
+
+			JustBeforeEach(func() {
+				Eventually(started).Should(HaveLen(workers))
+				for len(started) > 0 {
+					<-started
+				}
+			})
+
+			It("runs a queued readiness check before the queued liveness checks", func() {
+				pool.Queue(healthcheckpool.PhaseReadiness).Submit(blockingCheck("readiness"))
+				Expect(pool.QueueDepth(healthcheckpool.PhaseReadiness)).To(Equal(1))
+
+				release <- struct{}{}
+				Eventually(started).Should(Receive(Equal("readiness")))
+			})
+		})
+	})
+
+	Context("when the reserved fraction would leave no workers for liveness checks", func() {
+		BeforeEach(func() {
+			reservedFraction = 1
+		})
+
+		It("keeps one worker for liveness checks", func() {
+			pool.Queue(healthcheckpool.PhaseLiveness).Submit(blockingCheck("liveness"))
+			Eventually(started).Should(Receive(Equal("liveness")))
+		})
+	})
+})
+
+var _ = Describe("MetricsReporter", func() {
+	var (
+		workPool         *workpool.WorkPool
+		pool             *healthcheckpool.Pool
+		fakeClock        *fakeclock.FakeClock
+		fakeMetronClient *mfakes.FakeIngressClient
+		process          ifrit.Process
+		release          chan struct{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		workPool, err = workpool.NewWorkPool(1)
+		Expect(err).NotTo(HaveOccurred())
+		pool = healthcheckpool.New(workPool, 1, 0)
+
+		release = make(chan struct{})
+		for i := 0; i < 3; i++ {
+			pool.Queue(healthcheckpool.PhaseLiveness).Submit(func() { <-release })
+		}
+
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeMetronClient = new(mfakes.FakeIngressClient)
+
+		reporter := healthcheckpool.NewMetricsReporter(pool, time.Minute, fakeClock, fakeMetronClient, lagertest.NewTestLogger("test"))
+		process = ifrit.Invoke(reporter)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+		close(release)
+		workPool.Stop()
+	})
+
+	It("emits the queue depth of each phase every interval", func() {
+		Consistently(fakeMetronClient.SendMetricCallCount).Should(Equal(0))
+
+		fakeClock.WaitForWatcherAndIncrement(time.Minute)
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(2))
+
+		depths := map[string]int{}
+		for i := 0; i < 2; i++ {
+			name, value, opts := fakeMetronClient.SendMetricArgsForCall(i)
+			Expect(name).To(Equal(healthcheckpool.HealthCheckQueueDepthMetric))
+
+			envelope := &loggregator_v2.Envelope{Tags: map[string]string{}}
+			for _, opt := range opts {
+				opt(envelope)
+			}
+			depths[envelope.Tags["phase"]] = value
+		}
+		Expect(depths).To(Equal(map[string]int{"readiness": 0, "liveness": 2}))
+	})
+})
@@ -0,0 +1,61 @@
+package healthcheckpool
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	loggregator "code.cloudfoundry.org/go-loggregator"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+const HealthCheckQueueDepthMetric = "HealthCheckQueueDepth"
+
+type metricsReporter struct {
+	pool         *Pool
+	interval     time.Duration
+	clock        clock.Clock
+	metronClient loggingclient.IngressClient
+	logger       lager.Logger
+}
+
+// NewMetricsReporter returns a runner that emits the depth of the readiness
+// and liveness queues of pool every interval.
+func NewMetricsReporter(pool *Pool, interval time.Duration, clock clock.Clock, metronClient loggingclient.IngressClient, logger lager.Logger) ifrit.Runner {
+	return &metricsReporter{
+		pool:         pool,
+		interval:     interval,
+		clock:        clock,
+		metronClient: metronClient,
+		logger:       logger.Session("health-check-pool-metrics-reporter"),
+	}
+}
+
+func (r *metricsReporter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	timer := r.clock.NewTimer(r.interval)
+
+	close(ready)
+
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			r.report()
+			timer.Reset(r.interval)
+		case signal := <-signals:
+			r.logger.Info("signalled", lager.Data{"signal": signal.String()})
+			return nil
+		}
+	}
+}
+
+func (r *metricsReporter) report() {
+	for _, phase := range []Phase{PhaseReadiness, PhaseLiveness} {
+		err := r.metronClient.SendMetric(HealthCheckQueueDepthMetric, r.pool.QueueDepth(phase), loggregator.WithEnvelopeTag("phase", string(phase)))
+		if err != nil {
+			r.logger.Error("failed-to-send-queue-depth-metric", err, lager.Data{"phase": phase})
+		}
+	}
+}
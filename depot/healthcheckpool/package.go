@@ -0,0 +1 @@
+package healthcheckpool // import "code.cloudfoundry.org/executor/depot/healthcheckpool"
@@ -0,0 +1,85 @@
+package lockstats
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats summarizes how long callers waited to acquire a lock since the
+// previous collection.
+type Stats struct {
+	Max     time.Duration
+	Avg     time.Duration
+	Samples int
+}
+
+// RWMutex is a sync.RWMutex that, when sampling is enabled, measures how long
+// each Lock and RLock call waits before acquiring the lock. Sampling is fixed
+// at construction so that the disabled path costs a single branch.
+type RWMutex struct {
+	sync.RWMutex
+	sampling bool
+
+	statsLock sync.Mutex
+	maxWait   time.Duration
+	totalWait time.Duration
+	samples   int
+}
+
+func NewRWMutex(sampling bool) *RWMutex {
+	return &RWMutex{sampling: sampling}
+}
+
+func (m *RWMutex) Sampling() bool {
+	return m.sampling
+}
+
+func (m *RWMutex) Lock() {
+	if !m.sampling {
+		m.RWMutex.Lock()
+		return
+	}
+
+	start := time.Now()
+	m.RWMutex.Lock()
+	m.record(time.Since(start))
+}
+
+func (m *RWMutex) RLock() {
+	if !m.sampling {
+		m.RWMutex.RLock()
+		return
+	}
+
+	start := time.Now()
+	m.RWMutex.RLock()
+	m.record(time.Since(start))
+}
+
+func (m *RWMutex) record(wait time.Duration) {
+	m.statsLock.Lock()
+	defer m.statsLock.Unlock()
+
+	if wait > m.maxWait {
+		m.maxWait = wait
+	}
+	m.totalWait += wait
+	m.samples++
+}
+
+// Collect returns the wait statistics gathered since the last call and resets
+// them.
+func (m *RWMutex) Collect() Stats {
+	m.statsLock.Lock()
+	defer m.statsLock.Unlock()
+
+	stats := Stats{Max: m.maxWait, Samples: m.samples}
+	if m.samples > 0 {
+		stats.Avg = m.totalWait / time.Duration(m.samples)
+	}
+
+	m.maxWait = 0
+	m.totalWait = 0
+	m.samples = 0
+	return stats
+}
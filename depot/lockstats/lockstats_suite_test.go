@@ -0,0 +1,13 @@
+package lockstats_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestLockStats(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Lock Stats Suite")
+}
@@ -0,0 +1,69 @@
+package lockstats_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/executor/depot/lockstats"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RWMutex", func() {
+	var mutex *lockstats.RWMutex
+
+	contend := func(lock func(), unlock func()) {
+		mutex.Lock()
+
+		acquired := make(chan struct{})
+		go func() {
+			lock()
+			close(acquired)
+			unlock()
+		}()
+
+		time.Sleep(20 * time.Millisecond)
+		mutex.Unlock()
+		Eventually(acquired).Should(BeClosed())
+	}
+
+	Context("when sampling is enabled", func() {
+		BeforeEach(func() {
+			mutex = lockstats.NewRWMutex(true)
+		})
+
+		It("reports the time spent waiting for a write lock", func() {
+			contend(mutex.Lock, mutex.Unlock)
+
+			stats := mutex.Collect()
+			Expect(stats.Samples).To(Equal(2))
+			Expect(stats.Max).To(BeNumerically(">=", 20*time.Millisecond))
+			Expect(stats.Avg).To(BeNumerically(">", 0))
+		})
+
+		It("reports the time spent waiting for a read lock", func() {
+			contend(mutex.RLock, mutex.RUnlock)
+
+			stats := mutex.Collect()
+			Expect(stats.Max).To(BeNumerically(">=", 20*time.Millisecond))
+		})
+
+		It("resets after each collection", func() {
+			contend(mutex.Lock, mutex.Unlock)
+			mutex.Collect()
+
+			Expect(mutex.Collect()).To(Equal(lockstats.Stats{}))
+		})
+	})
+
+	Context("when sampling is disabled", func() {
+		BeforeEach(func() {
+			mutex = lockstats.NewRWMutex(false)
+		})
+
+		It("records nothing", func() {
+			contend(mutex.Lock, mutex.Unlock)
+			Expect(mutex.Collect()).To(Equal(lockstats.Stats{}))
+		})
+	})
+})
@@ -0,0 +1 @@
+package lockstats // import "code.cloudfoundry.org/executor/depot/lockstats"
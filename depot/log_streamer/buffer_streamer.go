@@ -35,3 +35,6 @@ func (bs *bufferStreamer) WithSource(sourceName string) LogStreamer {
 func (bs *bufferStreamer) SourceName() string {
 	return bs.sourceName
 }
+
+func (bs *bufferStreamer) UpdateLogConfig(sourceName string, index int, tags map[string]string) {
+}
@@ -43,6 +43,13 @@ type FakeLogStreamer struct {
 	stdoutReturnsOnCall map[int]struct {
 		result1 io.Writer
 	}
+	UpdateLogConfigStub        func(string, int, map[string]string)
+	updateLogConfigMutex       sync.RWMutex
+	updateLogConfigArgsForCall []struct {
+		arg1 string
+		arg2 int
+		arg3 map[string]string
+	}
 	WithSourceStub        func(string) log_streamer.LogStreamer
 	withSourceMutex       sync.RWMutex
 	withSourceArgsForCall []struct {
@@ -237,6 +244,39 @@ func (fake *FakeLogStreamer) StdoutReturnsOnCall(i int, result1 io.Writer) {
 	}{result1}
 }
 
+func (fake *FakeLogStreamer) UpdateLogConfig(arg1 string, arg2 int, arg3 map[string]string) {
+	fake.updateLogConfigMutex.Lock()
+	fake.updateLogConfigArgsForCall = append(fake.updateLogConfigArgsForCall, struct {
+		arg1 string
+		arg2 int
+		arg3 map[string]string
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("UpdateLogConfig", []interface{}{arg1, arg2, arg3})
+	fake.updateLogConfigMutex.Unlock()
+	if fake.UpdateLogConfigStub != nil {
+		fake.UpdateLogConfigStub(arg1, arg2, arg3)
+	}
+}
+
+func (fake *FakeLogStreamer) UpdateLogConfigCallCount() int {
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
+	return len(fake.updateLogConfigArgsForCall)
+}
+
+func (fake *FakeLogStreamer) UpdateLogConfigCalls(stub func(string, int, map[string]string)) {
+	fake.updateLogConfigMutex.Lock()
+	defer fake.updateLogConfigMutex.Unlock()
+	fake.UpdateLogConfigStub = stub
+}
+
+func (fake *FakeLogStreamer) UpdateLogConfigArgsForCall(i int) (string, int, map[string]string) {
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
+	argsForCall := fake.updateLogConfigArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
 func (fake *FakeLogStreamer) WithSource(arg1 string) log_streamer.LogStreamer {
 	fake.withSourceMutex.Lock()
 	ret, specificReturn := fake.withSourceReturnsOnCall[len(fake.withSourceArgsForCall)]
@@ -308,6 +348,8 @@ func (fake *FakeLogStreamer) Invocations() map[string][][]interface{} {
 	defer fake.stderrMutex.RUnlock()
 	fake.stdoutMutex.RLock()
 	defer fake.stdoutMutex.RUnlock()
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
 	fake.withSourceMutex.RLock()
 	defer fake.withSourceMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
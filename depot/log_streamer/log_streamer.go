@@ -3,6 +3,7 @@ package log_streamer
 import (
 	"io"
 	"strconv"
+	"sync"
 
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
@@ -12,6 +13,10 @@ const (
 	MAX_MESSAGE_SIZE = 61440
 
 	DefaultLogSource = "LOG"
+
+	// TruncationSuffix marks each part of a line that was split because it
+	// exceeded the configured maximum line length, except the last one.
+	TruncationSuffix = "...(truncated)"
 )
 
 //go:generate counterfeiter -o fake_log_streamer/fake_log_streamer.go . LogStreamer
@@ -23,48 +28,50 @@ type LogStreamer interface {
 
 	WithSource(sourceName string) LogStreamer
 	SourceName() string
+
+	// UpdateLogConfig changes the default source name, index and tags of the
+	// streamer and of every streamer derived from it with WithSource, so that
+	// running steps pick up new log routing without being restarted.
+	UpdateLogConfig(sourceName string, index int, tags map[string]string)
 }
 
 type logStreamer struct {
-	stdout *streamDestination
-	stderr *streamDestination
+	guid    string
+	routing *logRouting
+	stdout  *streamDestination
+	stderr  *streamDestination
 }
 
-func New(guid string, sourceName string, index int, originalTags map[string]string, metronClient loggingclient.IngressClient) LogStreamer {
+// New returns a streamer that emits one log message per line. Lines longer
+// than maxLineLength bytes are split, and every part but the last is marked
+// with TruncationSuffix. When maxLineLength is zero, lines are split every
+// MAX_MESSAGE_SIZE bytes without a marker.
+func New(guid string, sourceName string, index int, originalTags map[string]string, metronClient loggingclient.IngressClient, maxLineLength int) LogStreamer {
 	if guid == "" {
 		return noopStreamer{}
 	}
 
-	if sourceName == "" {
-		sourceName = DefaultLogSource
-	}
-
-	tags := map[string]string{}
-	for k, v := range originalTags {
-		tags[k] = v
-	}
-
-	if _, ok := tags["source_id"]; !ok {
-		tags["source_id"] = guid
-	}
-	sourceIndex := strconv.Itoa(index)
-	if _, ok := tags["instance_id"]; !ok {
-		tags["instance_id"] = sourceIndex
-	}
+	routing := &logRouting{}
+	routing.update(guid, sourceName, index, originalTags)
 
 	return &logStreamer{
+		guid:    guid,
+		routing: routing,
+
 		stdout: newStreamDestination(
-			sourceName,
-			tags,
+			routing,
+			"",
 			loggregator_v2.Log_OUT,
 			metronClient,
+			maxLineLength,
 		),
 
 		stderr: newStreamDestination(
-			sourceName,
-			tags,
+			routing,
+			"",
 			loggregator_v2.Log_ERR,
 			metronClient,
+			maxLineLength,
 		),
 	}
 }
@@ -88,11 +95,62 @@ func (e *logStreamer) WithSource(sourceName string) LogStreamer {
 	}
 
 	return &logStreamer{
-		stdout: e.stdout.withSource(sourceName),
-		stderr: e.stderr.withSource(sourceName),
+		guid:    e.guid,
+		routing: e.routing,
+		stdout:  e.stdout.withSource(sourceName),
+		stderr:  e.stderr.withSource(sourceName),
 	}
 }
 
 func (e *logStreamer) SourceName() string {
-	return e.stdout.sourceName
+	return e.stdout.sourceName()
+}
+
+func (e *logStreamer) UpdateLogConfig(sourceName string, index int, tags map[string]string) {
+	e.Flush()
+	e.routing.update(e.guid, sourceName, index, tags)
+}
+
+// logRouting is the source name and tags shared by a streamer and the
+// streamers derived from it.
+type logRouting struct {
+	lock       sync.RWMutex
+	sourceName string
+	tags       map[string]string
+}
+
+func (r *logRouting) update(guid, sourceName string, index int, originalTags map[string]string) {
+	if sourceName == "" {
+		sourceName = DefaultLogSource
+	}
+
+	tags := map[string]string{}
+	for k, v := range originalTags {
+		tags[k] = v
+	}
+
+	if _, ok := tags["source_id"]; !ok {
+		tags["source_id"] = guid
+	}
+	sourceIndex := strconv.Itoa(index)
+	if _, ok := tags["instance_id"]; !ok {
+		tags["instance_id"] = sourceIndex
+	}
+
+	r.lock.Lock()
+	r.sourceName = sourceName
+	r.tags = tags
+	r.lock.Unlock()
+}
+
+// get returns the source name to log with, preferring sourceOverride when it
+// is set, and the current tags. The tags must not be modified.
+func (r *logRouting) get(sourceOverride string) (string, map[string]string) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	if sourceOverride != "" {
+		return sourceOverride, r.tags
+	}
+	return r.sourceName, r.tags
 }
@@ -27,7 +27,7 @@ var _ = Describe("LogStreamer", func() {
 
 	BeforeEach(func() {
 		fakeClient = &mfakes.FakeIngressClient{}
-		streamer = log_streamer.New(guid, sourceName, index, tags, fakeClient)
+		streamer = log_streamer.New(guid, sourceName, index, tags, fakeClient, 0)
 	})
 
 	Context("when told to emit", func() {
@@ -92,6 +92,49 @@ var _ = Describe("LogStreamer", func() {
 			})
 		})
 
+		Describe("UpdateLogConfig", func() {
+			It("should emit later messages with the new source name and tags", func() {
+				fmt.Fprintln(streamer.Stdout(), "before")
+				streamer.UpdateLogConfig("new-source-name", 12, map[string]string{"foo": "qux"})
+				fmt.Fprintln(streamer.Stdout(), "after")
+
+				Expect(fakeClient.SendAppLogCallCount()).To(Equal(2))
+
+				_, sn, tags := fakeClient.SendAppLogArgsForCall(0)
+				Expect(sn).To(Equal(sourceName))
+				Expect(tags["instance_id"]).To(Equal("11"))
+
+				_, sn, tags = fakeClient.SendAppLogArgsForCall(1)
+				Expect(sn).To(Equal("new-source-name"))
+				Expect(tags["source_id"]).To(Equal(guid))
+				Expect(tags["instance_id"]).To(Equal("12"))
+				Expect(tags["foo"]).To(Equal("qux"))
+				Expect(tags).NotTo(HaveKey("biz"))
+				Expect(streamer.SourceName()).To(Equal("new-source-name"))
+			})
+
+			It("should flush buffered output with the old routing", func() {
+				fmt.Fprint(streamer.Stdout(), "partial")
+				streamer.UpdateLogConfig("new-source-name", 12, nil)
+
+				Expect(fakeClient.SendAppLogCallCount()).To(Equal(1))
+				message, sn, _ := fakeClient.SendAppLogArgsForCall(0)
+				Expect(message).To(Equal("partial"))
+				Expect(sn).To(Equal(sourceName))
+			})
+
+			It("should update streamers derived with WithSource, keeping their source", func() {
+				derived := streamer.WithSource("derived-source")
+				streamer.UpdateLogConfig("new-source-name", 12, nil)
+				fmt.Fprintln(derived.Stdout(), "this is a log")
+
+				Expect(fakeClient.SendAppLogCallCount()).To(Equal(1))
+				_, sn, tags := fakeClient.SendAppLogArgsForCall(0)
+				Expect(sn).To(Equal("derived-source"))
+				Expect(tags["instance_id"]).To(Equal("12"))
+			})
+		})
+
 		Context("when given a message with all sorts of fun newline characters", func() {
 			BeforeEach(func() {
 				fmt.Fprintf(streamer.Stdout(), "A\nB\rC\n\rD\r\nE\n\n\nF\r\r\rG\n\r\r\n\n\n\r")
@@ -280,6 +323,44 @@ var _ = Describe("LogStreamer", func() {
 		})
 	})
 
+	Context("when a maximum line length is configured", func() {
+		BeforeEach(func() {
+			streamer = log_streamer.New(guid, sourceName, index, tags, fakeClient, 10)
+		})
+
+		It("emits lines within the limit unchanged", func() {
+			fmt.Fprintf(streamer.Stdout(), "0123456789\nshort\n")
+			Expect(fakeClient.SendAppLogCallCount()).To(Equal(2))
+
+			msg, _, _ := fakeClient.SendAppLogArgsForCall(0)
+			Expect(msg).To(Equal("0123456789"))
+			msg, _, _ = fakeClient.SendAppLogArgsForCall(1)
+			Expect(msg).To(Equal("short"))
+		})
+
+		It("splits longer lines and marks every part but the last", func() {
+			fmt.Fprintf(streamer.Stdout(), "0123456789abcdefghijKLM")
+			fmt.Fprintf(streamer.Stdout(), "N\n")
+			Expect(fakeClient.SendAppLogCallCount()).To(Equal(3))
+
+			msg, _, _ := fakeClient.SendAppLogArgsForCall(0)
+			Expect(msg).To(Equal("0123456789" + log_streamer.TruncationSuffix))
+			msg, _, _ = fakeClient.SendAppLogArgsForCall(1)
+			Expect(msg).To(Equal("abcdefghij" + log_streamer.TruncationSuffix))
+			msg, _, _ = fakeClient.SendAppLogArgsForCall(2)
+			Expect(msg).To(Equal("KLMN"))
+		})
+
+		It("applies the limit to streamers with another source", func() {
+			fmt.Fprintf(streamer.WithSource("other").Stderr(), "0123456789a\n")
+			Expect(fakeClient.SendAppErrorLogCallCount()).To(Equal(2))
+
+			msg, sn, _ := fakeClient.SendAppErrorLogArgsForCall(0)
+			Expect(msg).To(Equal("0123456789" + log_streamer.TruncationSuffix))
+			Expect(sn).To(Equal("other"))
+		})
+	})
+
 	Context("when told to flush", func() {
 		It("should send whatever log is left in its buffer", func() {
 			fmt.Fprintf(streamer.Stdout(), "this is a stdout")
@@ -297,7 +378,7 @@ var _ = Describe("LogStreamer", func() {
 
 	Context("when there is no app guid", func() {
 		It("does nothing when told to emit or flush", func() {
-			streamer = log_streamer.New("", sourceName, index, tags, fakeClient)
+			streamer = log_streamer.New("", sourceName, index, tags, fakeClient, 0)
 
 			streamer.Stdout().Write([]byte("hi"))
 			streamer.Stderr().Write([]byte("hi"))
@@ -309,7 +390,7 @@ var _ = Describe("LogStreamer", func() {
 
 	Context("when there is no log source", func() {
 		It("defaults to LOG", func() {
-			streamer = log_streamer.New(guid, "", -1, tags, fakeClient)
+			streamer = log_streamer.New(guid, "", -1, tags, fakeClient, 0)
 
 			streamer.Stdout().Write([]byte("hi"))
 			streamer.Flush()
@@ -17,4 +17,5 @@ func (noopStreamer) Flush()            {}
 func (noopStreamer) WithSource(sourceName string) LogStreamer {
 	return noopStreamer{}
 }
-func (noopStreamer) SourceName() string { return DefaultLogSource }
+func (noopStreamer) SourceName() string                                                   { return DefaultLogSource }
+func (noopStreamer) UpdateLogConfig(sourceName string, index int, tags map[string]string) {}
@@ -9,24 +9,50 @@ import (
 )
 
 type streamDestination struct {
-	sourceName   string
-	tags         map[string]string
-	messageType  loggregator_v2.Log_Type
-	buffer       []byte
-	processLock  sync.Mutex
-	metronClient loggingclient.IngressClient
+	routing          *logRouting
+	sourceOverride   string
+	messageType      loggregator_v2.Log_Type
+	buffer           []byte
+	processLock      sync.Mutex
+	metronClient     loggingclient.IngressClient
+	maxLineLength    int
+	lineLength       int
+	truncationSuffix string
 }
 
-func newStreamDestination(sourceName string, tags map[string]string, messageType loggregator_v2.Log_Type, metronClient loggingclient.IngressClient) *streamDestination {
+func newStreamDestination(routing *logRouting, sourceOverride string, messageType loggregator_v2.Log_Type, metronClient loggingclient.IngressClient, maxLineLength int) *streamDestination {
+	lineLength := MAX_MESSAGE_SIZE
+	truncationSuffix := ""
+	if maxLineLength > 0 {
+		// the marker has to fit in the message along with the line
+		lineLength = maxLineLength
+		if lineLength > MAX_MESSAGE_SIZE-len(TruncationSuffix) {
+			lineLength = MAX_MESSAGE_SIZE - len(TruncationSuffix)
+		}
+		// and a line part must be able to hold a whole rune
+		if lineLength < utf8.UTFMax {
+			lineLength = utf8.UTFMax
+		}
+		truncationSuffix = TruncationSuffix
+	}
+
 	return &streamDestination{
-		sourceName:   sourceName,
-		tags:         tags,
-		messageType:  messageType,
-		buffer:       make([]byte, 0, MAX_MESSAGE_SIZE),
-		metronClient: metronClient,
+		routing:          routing,
+		sourceOverride:   sourceOverride,
+		messageType:      messageType,
+		buffer:           make([]byte, 0, MAX_MESSAGE_SIZE),
+		metronClient:     metronClient,
+		maxLineLength:    maxLineLength,
+		lineLength:       lineLength,
+		truncationSuffix: truncationSuffix,
 	}
 }
 
+func (destination *streamDestination) sourceName() string {
+	sourceName, _ := destination.routing.get(destination.sourceOverride)
+	return sourceName
+}
+
 func (destination *streamDestination) lockAndFlush() {
 	destination.processLock.Lock()
 	defer destination.processLock.Unlock()
@@ -42,11 +68,12 @@ func (destination *streamDestination) flush() {
 	msg := destination.copyAndResetBuffer()
 
 	if len(msg) > 0 {
+		sourceName, tags := destination.routing.get(destination.sourceOverride)
 		switch destination.messageType {
 		case loggregator_v2.Log_OUT:
-			destination.metronClient.SendAppLog(string(msg), destination.sourceName, destination.tags)
+			destination.metronClient.SendAppLog(string(msg), sourceName, tags)
 		case loggregator_v2.Log_ERR:
-			destination.metronClient.SendAppErrorLog(string(msg), destination.sourceName, destination.tags)
+			destination.metronClient.SendAppErrorLog(string(msg), sourceName, tags)
 		}
 	}
 }
@@ -87,6 +114,7 @@ func (destination *streamDestination) processString(message string, terminates b
 		if len(message) == 0 {
 			break
 		}
+		destination.buffer = append(destination.buffer, destination.truncationSuffix...)
 		destination.flush()
 	}
 
@@ -97,8 +125,8 @@ func (destination *streamDestination) processString(message string, terminates b
 
 // Not thread safe.  should only be called when holding the processLock
 func (destination *streamDestination) appendToBuffer(message string) string {
-	if len(message)+len(destination.buffer) >= MAX_MESSAGE_SIZE {
-		remainingSpaceInBuffer := MAX_MESSAGE_SIZE - len(destination.buffer)
+	if len(message)+len(destination.buffer) >= destination.lineLength {
+		remainingSpaceInBuffer := destination.lineLength - len(destination.buffer)
 		destination.buffer = append(destination.buffer, []byte(message[0:remainingSpaceInBuffer])...)
 
 		r, _ := utf8.DecodeLastRune(destination.buffer[0:len(destination.buffer)])
@@ -126,5 +154,5 @@ func (destination *streamDestination) appendToBuffer(message string) string {
 }
 
 func (d *streamDestination) withSource(sourceName string) *streamDestination {
-	return newStreamDestination(sourceName, d.tags, d.messageType, d.metronClient)
+	return newStreamDestination(d.routing, sourceName, d.messageType, d.metronClient, d.maxLineLength)
 }
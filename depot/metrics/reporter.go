@@ -28,6 +28,27 @@ const (
 
 	containerCount         = "ContainerCount"
 	startingContainerCount = "StartingContainerCount"
+
+	cellSaturationMetric           = "CellSaturation"
+	cellSaturationMemoryMetric     = "CellSaturationMemory"
+	cellSaturationDiskMetric       = "CellSaturationDisk"
+	cellSaturationContainersMetric = "CellSaturationContainers"
+
+	createWorkPoolQueueDepthMetric  = "CreateWorkPoolQueueDepth"
+	deleteWorkPoolQueueDepthMetric  = "DeleteWorkPoolQueueDepth"
+	readWorkPoolQueueDepthMetric    = "ReadWorkPoolQueueDepth"
+	metricsWorkPoolQueueDepthMetric = "MetricsWorkPoolQueueDepth"
+
+	totalBytesDownloadedMetric = "TotalBytesDownloaded"
+	totalBytesUploadedMetric   = "TotalBytesUploaded"
+
+	cacheHitsMetric   = "CacheHits"
+	cacheMissesMetric = "CacheMisses"
+
+	cachePartitionHitsMetric    = "CachePartitionHits"
+	cachePartitionMissesMetric  = "CachePartitionMisses"
+	cachePartitionEntriesMetric = "CachePartitionEntries"
+	cachePartitionSizeMetric    = "CachePartitionSize"
 )
 
 type ExecutorSource interface {
@@ -37,6 +58,26 @@ type ExecutorSource interface {
 	ListContainers(lager.Logger) ([]executor.Container, error)
 }
 
+type QueueDepthSource interface {
+	QueueDepth() int
+}
+
+type TransferSource interface {
+	TotalBytesDownloaded() uint64
+	TotalBytesUploaded() uint64
+}
+
+type CacheSource interface {
+	CacheHits() uint64
+	CacheMisses() uint64
+}
+
+// PartitionedCacheSource is implemented by cache sources that break their
+// stats down per cache partition.
+type PartitionedCacheSource interface {
+	PartitionStats() map[string]executor.CachePartitionStats
+}
+
 type Reporter struct {
 	Interval       time.Duration
 	ExecutorSource ExecutorSource
@@ -44,6 +85,29 @@ type Reporter struct {
 	Logger         lager.Logger
 	MetronClient   loggingclient.IngressClient
 	Tags           map[string]string
+
+	// The queue depth of each work pool that is set is reported every
+	// interval.
+	CreateWorkPool  QueueDepthSource
+	DeleteWorkPool  QueueDepthSource
+	ReadWorkPool    QueueDepthSource
+	MetricsWorkPool QueueDepthSource
+
+	// When set, the bytes transferred since the previous interval are
+	// reported as increments of the TotalBytesDownloaded and
+	// TotalBytesUploaded counters.
+	TransferSource TransferSource
+
+	// When set, the cache hits and misses since the previous interval are
+	// reported as increments of the CacheHits and CacheMisses counters. If
+	// it is also a PartitionedCacheSource, the stats of each cache partition
+	// are reported as gauges tagged with the partition.
+	CacheSource CacheSource
+
+	lastBytesDownloaded uint64
+	lastBytesUploaded   uint64
+	lastCacheHits       uint64
+	lastCacheMisses     uint64
 }
 
 func (reporter *Reporter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
@@ -166,11 +230,174 @@ func (reporter *Reporter) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 				logger.Error("failed-to-send-starting-container-count-metric", err)
 			}
 
+			if totalCapacity.Containers >= 0 && remainingCapacity.Containers >= 0 {
+				reporter.sendSaturationMetrics(logger, calculateSaturation(totalCapacity, remainingCapacity))
+			}
+
+			reporter.sendWorkPoolMetrics(logger)
+			reporter.sendTransferMetrics(logger)
+			reporter.sendCacheMetrics(logger)
+			reporter.sendCachePartitionMetrics(logger)
+
 			timer.Reset(reporter.Interval)
 		}
 	}
 }
 
+// saturation holds the utilization of each capacity dimension as a
+// percentage of the total capacity.
+type saturation struct {
+	memory, disk, containers int
+}
+
+func calculateSaturation(total, remaining executor.ExecutorResources) saturation {
+	return saturation{
+		memory:     utilizationPercent(total.MemoryMB, remaining.MemoryMB),
+		disk:       utilizationPercent(total.DiskMB, remaining.DiskMB),
+		containers: utilizationPercent(total.Containers, remaining.Containers),
+	}
+}
+
+func utilizationPercent(total, remaining int) int {
+	if total <= 0 {
+		return 0
+	}
+	return (total - remaining) * 100 / total
+}
+
+// dominant returns the highest utilization and the dimension it belongs to.
+func (s saturation) dominant() (int, string) {
+	value, dimension := s.memory, "memory"
+	if s.disk > value {
+		value, dimension = s.disk, "disk"
+	}
+	if s.containers > value {
+		value, dimension = s.containers, "containers"
+	}
+	return value, dimension
+}
+
+func (reporter *Reporter) sendSaturationMetrics(logger lager.Logger, s saturation) {
+	tagOption := loggregator.WithEnvelopeTags(reporter.Tags)
+
+	value, dimension := s.dominant()
+	err := reporter.MetronClient.SendMetric(cellSaturationMetric, value, tagOption, loggregator.WithEnvelopeTag("dimension", dimension))
+	if err != nil {
+		logger.Error("failed-to-send-cell-saturation-metric", err)
+	}
+
+	err = reporter.MetronClient.SendMetric(cellSaturationMemoryMetric, s.memory, tagOption)
+	if err != nil {
+		logger.Error("failed-to-send-cell-saturation-memory-metric", err)
+	}
+	err = reporter.MetronClient.SendMetric(cellSaturationDiskMetric, s.disk, tagOption)
+	if err != nil {
+		logger.Error("failed-to-send-cell-saturation-disk-metric", err)
+	}
+	err = reporter.MetronClient.SendMetric(cellSaturationContainersMetric, s.containers, tagOption)
+	if err != nil {
+		logger.Error("failed-to-send-cell-saturation-containers-metric", err)
+	}
+}
+
+func (reporter *Reporter) sendWorkPoolMetrics(logger lager.Logger) {
+	tagOption := loggregator.WithEnvelopeTags(reporter.Tags)
+
+	pools := []struct {
+		metric string
+		pool   QueueDepthSource
+	}{
+		{createWorkPoolQueueDepthMetric, reporter.CreateWorkPool},
+		{deleteWorkPoolQueueDepthMetric, reporter.DeleteWorkPool},
+		{readWorkPoolQueueDepthMetric, reporter.ReadWorkPool},
+		{metricsWorkPoolQueueDepthMetric, reporter.MetricsWorkPool},
+	}
+
+	for _, p := range pools {
+		if p.pool == nil {
+			continue
+		}
+		err := reporter.MetronClient.SendMetric(p.metric, p.pool.QueueDepth(), tagOption)
+		if err != nil {
+			logger.Error("failed-to-send-work-pool-queue-depth-metric", err, lager.Data{"metric": p.metric})
+		}
+	}
+}
+
+func (reporter *Reporter) sendTransferMetrics(logger lager.Logger) {
+	if reporter.TransferSource == nil {
+		return
+	}
+
+	downloaded := reporter.TransferSource.TotalBytesDownloaded()
+	err := reporter.MetronClient.IncrementCounterWithDelta(totalBytesDownloadedMetric, downloaded-reporter.lastBytesDownloaded)
+	if err != nil {
+		logger.Error("failed-to-send-total-bytes-downloaded-metric", err)
+	} else {
+		reporter.lastBytesDownloaded = downloaded
+	}
+
+	uploaded := reporter.TransferSource.TotalBytesUploaded()
+	err = reporter.MetronClient.IncrementCounterWithDelta(totalBytesUploadedMetric, uploaded-reporter.lastBytesUploaded)
+	if err != nil {
+		logger.Error("failed-to-send-total-bytes-uploaded-metric", err)
+	} else {
+		reporter.lastBytesUploaded = uploaded
+	}
+}
+
+func (reporter *Reporter) sendCacheMetrics(logger lager.Logger) {
+	if reporter.CacheSource == nil {
+		return
+	}
+
+	hits := reporter.CacheSource.CacheHits()
+	err := reporter.MetronClient.IncrementCounterWithDelta(cacheHitsMetric, hits-reporter.lastCacheHits)
+	if err != nil {
+		logger.Error("failed-to-send-cache-hits-metric", err)
+	} else {
+		reporter.lastCacheHits = hits
+	}
+
+	misses := reporter.CacheSource.CacheMisses()
+	err = reporter.MetronClient.IncrementCounterWithDelta(cacheMissesMetric, misses-reporter.lastCacheMisses)
+	if err != nil {
+		logger.Error("failed-to-send-cache-misses-metric", err)
+	} else {
+		reporter.lastCacheMisses = misses
+	}
+}
+
+func (reporter *Reporter) sendCachePartitionMetrics(logger lager.Logger) {
+	source, ok := reporter.CacheSource.(PartitionedCacheSource)
+	if !ok {
+		return
+	}
+
+	tagOption := loggregator.WithEnvelopeTags(reporter.Tags)
+
+	for tag, stats := range source.PartitionStats() {
+		partitionOption := loggregator.WithEnvelopeTag("partition", tag)
+
+		gauges := []struct {
+			metric string
+			value  int
+		}{
+			{cachePartitionHitsMetric, int(stats.Hits)},
+			{cachePartitionMissesMetric, int(stats.Misses)},
+			{cachePartitionEntriesMetric, stats.Entries},
+			{cachePartitionSizeMetric, int(stats.SizeBytes)},
+		}
+
+		for _, g := range gauges {
+			err := reporter.MetronClient.SendMetric(g.metric, g.value, tagOption, partitionOption)
+			if err != nil {
+				logger.Error("failed-to-send-cache-partition-metric", err, lager.Data{"metric": g.metric, "partition": tag})
+			}
+		}
+	}
+}
+
 func containerIsStarting(container executor.Container) bool {
 	return container.State == executor.StateReserved ||
 		container.State == executor.StateInitializing ||
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -13,6 +14,7 @@ import (
 	mfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/metrics"
+	"code.cloudfoundry.org/executor/depot/transformer/faketransformer"
 	"code.cloudfoundry.org/executor/fakes"
 	loggregator "code.cloudfoundry.org/go-loggregator"
 	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
@@ -25,6 +27,26 @@ type metricEnvelope struct {
 	tags  map[string]string
 }
 
+type fakeQueueDepth int
+
+func (d fakeQueueDepth) QueueDepth() int { return int(d) }
+
+type fakeCacheSource struct {
+	hits, misses uint64
+}
+
+func (s *fakeCacheSource) CacheHits() uint64   { return atomic.LoadUint64(&s.hits) }
+func (s *fakeCacheSource) CacheMisses() uint64 { return atomic.LoadUint64(&s.misses) }
+
+type fakePartitionedCacheSource struct {
+	fakeCacheSource
+	stats map[string]executor.CachePartitionStats
+}
+
+func (s *fakePartitionedCacheSource) PartitionStats() map[string]executor.CachePartitionStats {
+	return s.stats
+}
+
 var _ = Describe("Reporter", func() {
 	var (
 		reportInterval   time.Duration
@@ -36,6 +58,10 @@ var _ = Describe("Reporter", func() {
 		logger    *lagertest.TestLogger
 		metricMap map[string]metricEnvelope
 		m         sync.RWMutex
+
+		createWorkPool, deleteWorkPool, readWorkPool, metricsWorkPool metrics.QueueDepthSource
+		transferSource                                                metrics.TransferSource
+		cacheSource                                                   metrics.CacheSource
 	)
 
 	BeforeEach(func() {
@@ -84,6 +110,10 @@ var _ = Describe("Reporter", func() {
 		}, nil)
 
 		m = sync.RWMutex{}
+
+		createWorkPool, deleteWorkPool, readWorkPool, metricsWorkPool = nil, nil, nil, nil
+		transferSource = nil
+		cacheSource = nil
 	})
 
 	JustBeforeEach(func() {
@@ -111,6 +141,14 @@ var _ = Describe("Reporter", func() {
 			Logger:         logger,
 			MetronClient:   fakeMetronClient,
 			Tags:           map[string]string{"foo": "bar"},
+
+			CreateWorkPool:  createWorkPool,
+			DeleteWorkPool:  deleteWorkPool,
+			ReadWorkPool:    readWorkPool,
+			MetricsWorkPool: metricsWorkPool,
+
+			TransferSource: transferSource,
+			CacheSource:    cacheSource,
 		})
 		fakeClock.WaitForWatcherAndIncrement(reportInterval)
 
@@ -123,7 +161,7 @@ var _ = Describe("Reporter", func() {
 
 	It("reports the current capacity on the given interval", func() {
 		Eventually(fakeMetronClient.SendMebiBytesCallCount).Should(Equal(8))
-		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(4))
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(8))
 
 		m.RLock()
 		remainingMemory := metricMap["CapacityRemainingMemory"]
@@ -195,7 +233,7 @@ var _ = Describe("Reporter", func() {
 		m.RUnlock()
 
 		Eventually(fakeMetronClient.SendMebiBytesCallCount).Should(Equal(16))
-		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(8))
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(16))
 
 		m.RLock()
 
@@ -217,6 +255,200 @@ var _ = Describe("Reporter", func() {
 		m.RUnlock()
 	})
 
+	Describe("cell saturation", func() {
+		getMetric := func(name string) func() metricEnvelope {
+			return func() metricEnvelope {
+				m.RLock()
+				defer m.RUnlock()
+				return metricMap[name]
+			}
+		}
+
+		It("emits the utilization of each dimension as a percentage", func() {
+			Eventually(getMetric("CellSaturationMemory")).Should(Equal(metricEnvelope{value: 87, tags: map[string]string{"foo": "bar"}}))
+			Eventually(getMetric("CellSaturationDisk")).Should(Equal(metricEnvelope{value: 87, tags: map[string]string{"foo": "bar"}}))
+			Eventually(getMetric("CellSaturationContainers")).Should(Equal(metricEnvelope{value: 87, tags: map[string]string{"foo": "bar"}}))
+		})
+
+		Context("when memory dominates", func() {
+			BeforeEach(func() {
+				executorClient.RemainingResourcesReturns(executor.ExecutorResources{MemoryMB: 0, DiskMB: 1024, Containers: 2048}, nil)
+			})
+
+			It("emits the memory utilization tagged with the dimension", func() {
+				Eventually(getMetric("CellSaturation")).Should(Equal(metricEnvelope{value: 100, tags: map[string]string{"foo": "bar", "dimension": "memory"}}))
+			})
+		})
+
+		Context("when disk dominates", func() {
+			BeforeEach(func() {
+				executorClient.RemainingResourcesReturns(executor.ExecutorResources{MemoryMB: 512, DiskMB: 512, Containers: 2048}, nil)
+			})
+
+			It("emits the disk utilization tagged with the dimension", func() {
+				Eventually(getMetric("CellSaturation")).Should(Equal(metricEnvelope{value: 75, tags: map[string]string{"foo": "bar", "dimension": "disk"}}))
+			})
+		})
+
+		Context("when the container count dominates", func() {
+			BeforeEach(func() {
+				executorClient.RemainingResourcesReturns(executor.ExecutorResources{MemoryMB: 512, DiskMB: 1024, Containers: 1024}, nil)
+			})
+
+			It("emits the container utilization tagged with the dimension", func() {
+				Eventually(getMetric("CellSaturation")).Should(Equal(metricEnvelope{value: 75, tags: map[string]string{"foo": "bar", "dimension": "containers"}}))
+			})
+		})
+
+		Context("when a dimension has zero capacity", func() {
+			BeforeEach(func() {
+				executorClient.TotalResourcesReturns(executor.ExecutorResources{MemoryMB: 1024, DiskMB: 0, Containers: 4096}, nil)
+				executorClient.RemainingResourcesReturns(executor.ExecutorResources{MemoryMB: 768, DiskMB: 0, Containers: 4096}, nil)
+			})
+
+			It("treats it as unused", func() {
+				Eventually(getMetric("CellSaturationDisk")).Should(Equal(metricEnvelope{value: 0, tags: map[string]string{"foo": "bar"}}))
+				Eventually(getMetric("CellSaturation")).Should(Equal(metricEnvelope{value: 25, tags: map[string]string{"foo": "bar", "dimension": "memory"}}))
+			})
+		})
+
+		Context("when the total capacity changes", func() {
+			It("uses the latest totals", func() {
+				Eventually(getMetric("CellSaturationMemory")).Should(Equal(metricEnvelope{value: 87, tags: map[string]string{"foo": "bar"}}))
+
+				executorClient.TotalResourcesReturns(executor.ExecutorResources{MemoryMB: 2048, DiskMB: 2048, Containers: 4096}, nil)
+				fakeClock.WaitForWatcherAndIncrement(reportInterval)
+
+				Eventually(getMetric("CellSaturationMemory")).Should(Equal(metricEnvelope{value: 93, tags: map[string]string{"foo": "bar"}}))
+			})
+		})
+
+		Context("when getting remaining resources fails", func() {
+			BeforeEach(func() {
+				executorClient.RemainingResourcesReturns(executor.ExecutorResources{}, errors.New("oh no!"))
+			})
+
+			It("does not emit saturation metrics", func() {
+				Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(4))
+				Consistently(getMetric("CellSaturation")).Should(Equal(metricEnvelope{}))
+			})
+		})
+	})
+
+	Context("when work pools are configured", func() {
+		BeforeEach(func() {
+			createWorkPool = fakeQueueDepth(1)
+			deleteWorkPool = fakeQueueDepth(2)
+			readWorkPool = fakeQueueDepth(3)
+			metricsWorkPool = fakeQueueDepth(4)
+		})
+
+		It("reports the queue depth of each pool", func() {
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(12))
+
+			m.RLock()
+			defer m.RUnlock()
+			expectedTags := map[string]string{"foo": "bar"}
+			Expect(metricMap["CreateWorkPoolQueueDepth"]).To(Equal(metricEnvelope{value: 1, tags: expectedTags}))
+			Expect(metricMap["DeleteWorkPoolQueueDepth"]).To(Equal(metricEnvelope{value: 2, tags: expectedTags}))
+			Expect(metricMap["ReadWorkPoolQueueDepth"]).To(Equal(metricEnvelope{value: 3, tags: expectedTags}))
+			Expect(metricMap["MetricsWorkPoolQueueDepth"]).To(Equal(metricEnvelope{value: 4, tags: expectedTags}))
+		})
+	})
+
+	It("does not report transfer metrics without a transfer source", func() {
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(8))
+		Consistently(fakeMetronClient.IncrementCounterWithDeltaCallCount).Should(BeZero())
+	})
+
+	Context("when a transfer source is configured", func() {
+		var fakeTransformer *faketransformer.FakeTransformer
+
+		BeforeEach(func() {
+			fakeTransformer = new(faketransformer.FakeTransformer)
+			fakeTransformer.TotalBytesDownloadedReturnsOnCall(0, 100)
+			fakeTransformer.TotalBytesDownloadedReturns(250)
+			fakeTransformer.TotalBytesUploadedReturns(40)
+			transferSource = fakeTransformer
+		})
+
+		It("reports the bytes transferred since the previous interval", func() {
+			Eventually(fakeMetronClient.IncrementCounterWithDeltaCallCount).Should(Equal(2))
+			name, delta := fakeMetronClient.IncrementCounterWithDeltaArgsForCall(0)
+			Expect(name).To(Equal("TotalBytesDownloaded"))
+			Expect(delta).To(BeEquivalentTo(100))
+			name, delta = fakeMetronClient.IncrementCounterWithDeltaArgsForCall(1)
+			Expect(name).To(Equal("TotalBytesUploaded"))
+			Expect(delta).To(BeEquivalentTo(40))
+
+			fakeClock.WaitForWatcherAndIncrement(reportInterval)
+
+			Eventually(fakeMetronClient.IncrementCounterWithDeltaCallCount).Should(Equal(4))
+			name, delta = fakeMetronClient.IncrementCounterWithDeltaArgsForCall(2)
+			Expect(name).To(Equal("TotalBytesDownloaded"))
+			Expect(delta).To(BeEquivalentTo(150))
+			name, delta = fakeMetronClient.IncrementCounterWithDeltaArgsForCall(3)
+			Expect(name).To(Equal("TotalBytesUploaded"))
+			Expect(delta).To(BeZero())
+		})
+	})
+
+	Context("when a cache source is configured", func() {
+		var source *fakeCacheSource
+
+		BeforeEach(func() {
+			source = &fakeCacheSource{hits: 3, misses: 1}
+			cacheSource = source
+		})
+
+		It("reports the cache hits and misses since the previous interval", func() {
+			Eventually(fakeMetronClient.IncrementCounterWithDeltaCallCount).Should(Equal(2))
+			name, delta := fakeMetronClient.IncrementCounterWithDeltaArgsForCall(0)
+			Expect(name).To(Equal("CacheHits"))
+			Expect(delta).To(BeEquivalentTo(3))
+			name, delta = fakeMetronClient.IncrementCounterWithDeltaArgsForCall(1)
+			Expect(name).To(Equal("CacheMisses"))
+			Expect(delta).To(BeEquivalentTo(1))
+
+			atomic.StoreUint64(&source.hits, 5)
+			fakeClock.WaitForWatcherAndIncrement(reportInterval)
+
+			Eventually(fakeMetronClient.IncrementCounterWithDeltaCallCount).Should(Equal(4))
+			name, delta = fakeMetronClient.IncrementCounterWithDeltaArgsForCall(2)
+			Expect(name).To(Equal("CacheHits"))
+			Expect(delta).To(BeEquivalentTo(2))
+			name, delta = fakeMetronClient.IncrementCounterWithDeltaArgsForCall(3)
+			Expect(name).To(Equal("CacheMisses"))
+			Expect(delta).To(BeZero())
+		})
+	})
+
+	Context("when the cache source breaks its stats down per partition", func() {
+		BeforeEach(func() {
+			cacheSource = &fakePartitionedCacheSource{
+				stats: map[string]executor.CachePartitionStats{
+					"tenant-a": {Hits: 3, Misses: 1, Entries: 2, SizeBytes: 1024},
+				},
+			}
+		})
+
+		It("reports the stats of each partition tagged with the partition", func() {
+			expectedTags := map[string]string{"foo": "bar", "partition": "tenant-a"}
+
+			Eventually(func() metricEnvelope {
+				m.RLock()
+				defer m.RUnlock()
+				return metricMap["CachePartitionSize"]
+			}).Should(Equal(metricEnvelope{value: 1024, tags: expectedTags}))
+
+			m.RLock()
+			defer m.RUnlock()
+			Expect(metricMap["CachePartitionHits"]).To(Equal(metricEnvelope{value: 3, tags: expectedTags}))
+			Expect(metricMap["CachePartitionMisses"]).To(Equal(metricEnvelope{value: 1, tags: expectedTags}))
+			Expect(metricMap["CachePartitionEntries"]).To(Equal(metricEnvelope{value: 2, tags: expectedTags}))
+		})
+	})
+
 	Context("when getting remaining resources fails", func() {
 		BeforeEach(func() {
 			executorClient.RemainingResourcesReturns(executor.ExecutorResources{}, errors.New("oh no!"))
@@ -0,0 +1 @@
+package resourceregistry // import "code.cloudfoundry.org/executor/depot/resourceregistry"
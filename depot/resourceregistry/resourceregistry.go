@@ -0,0 +1,120 @@
+package resourceregistry
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"code.cloudfoundry.org/lager"
+)
+
+var ErrRegistryExceedsContainers = errors.New("resource registry holds more guids than there are containers")
+
+// Registry collects the cleanup callbacks of per-guid state held by
+// components of the executor. Components register a callback when they start
+// holding state for a container instead of deleting it on each of their own
+// cleanup paths, and the callbacks are run exactly once when the container is
+// removed from the store, so that a missed cleanup path cannot leak entries.
+type Registry struct {
+	lock     sync.Mutex
+	tracked  map[string]struct{}
+	cleanups map[string][]cleanup
+}
+
+type cleanup struct {
+	name string
+	fn   func(lager.Logger)
+}
+
+func New() *Registry {
+	return &Registry{
+		tracked:  make(map[string]struct{}),
+		cleanups: make(map[string][]cleanup),
+	}
+}
+
+// Add starts tracking guid, as the store does when it adds a container.
+// Callbacks can only be registered for tracked guids.
+func (r *Registry) Add(guid string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.tracked[guid] = struct{}{}
+}
+
+// Register adds a cleanup callback for guid. The name identifies the
+// component in logs; registering the same name again replaces its callback.
+// The callback is given the logger of the release. It returns false, without
+// registering, if guid is not tracked, so that a component racing the
+// release of a container does not hold state for it afterwards.
+func (r *Registry) Register(guid, name string, fn func(lager.Logger)) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.tracked[guid]; !ok {
+		return false
+	}
+
+	for i, c := range r.cleanups[guid] {
+		if c.name == name {
+			r.cleanups[guid][i].fn = fn
+			return true
+		}
+	}
+
+	r.cleanups[guid] = append(r.cleanups[guid], cleanup{name: name, fn: fn})
+	return true
+}
+
+// Release stops tracking guid and runs and forgets its callbacks, in the
+// order they were registered. It returns the number of callbacks run.
+func (r *Registry) Release(logger lager.Logger, guid string) int {
+	r.lock.Lock()
+	cleanups := r.cleanups[guid]
+	delete(r.cleanups, guid)
+	delete(r.tracked, guid)
+	r.lock.Unlock()
+
+	for _, c := range cleanups {
+		logger.Debug("running-cleanup", lager.Data{"guid": guid, "resource": c.name})
+		c.fn(logger)
+	}
+
+	return len(cleanups)
+}
+
+// Count returns the number of guids with registered callbacks.
+func (r *Registry) Count() int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	return len(r.cleanups)
+}
+
+// Check compares the number of registered guids with the number of
+// containers actually known. It logs an error listing the guids and returns
+// how many guids are registered beyond containers plus slack, or zero when
+// the registry is within bounds.
+func (r *Registry) Check(logger lager.Logger, containers, slack int) int {
+	r.lock.Lock()
+	guids := make([]string, 0, len(r.cleanups))
+	for guid := range r.cleanups {
+		guids = append(guids, guid)
+	}
+	r.lock.Unlock()
+
+	excess := len(guids) - containers - slack
+	if excess <= 0 {
+		return 0
+	}
+
+	sort.Strings(guids)
+	logger.Error("resource-registry-exceeds-container-count", ErrRegistryExceedsContainers, lager.Data{
+		"registered": len(guids),
+		"containers": containers,
+		"slack":      slack,
+		"guids":      guids,
+	})
+
+	return excess
+}
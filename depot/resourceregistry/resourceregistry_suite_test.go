@@ -0,0 +1,13 @@
+package resourceregistry_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestResourceRegistry(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Resource Registry Suite")
+}
@@ -0,0 +1,111 @@
+package resourceregistry_test
+
+import (
+	"code.cloudfoundry.org/executor/depot/resourceregistry"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		logger   *lagertest.TestLogger
+		registry *resourceregistry.Registry
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		registry = resourceregistry.New()
+		for _, guid := range []string{"guid-1", "guid-2", "guid-3", "guid-4"} {
+			registry.Add(guid)
+		}
+	})
+
+	Describe("Register", func() {
+		It("does not register callbacks for guids that are not tracked", func() {
+			Expect(registry.Register("untracked", "component", func(lager.Logger) {})).To(BeFalse())
+			Expect(registry.Count()).To(BeZero())
+		})
+
+		It("does not register callbacks for released guids", func() {
+			registry.Release(logger, "guid-1")
+			Expect(registry.Register("guid-1", "component", func(lager.Logger) {})).To(BeFalse())
+			Expect(registry.Count()).To(BeZero())
+		})
+
+		It("replaces the callback of a component registered again", func() {
+			calls := []string{}
+			Expect(registry.Register("guid-1", "component", func(lager.Logger) { calls = append(calls, "old") })).To(BeTrue())
+			Expect(registry.Register("guid-1", "component", func(lager.Logger) { calls = append(calls, "new") })).To(BeTrue())
+
+			Expect(registry.Release(logger, "guid-1")).To(Equal(1))
+			Expect(calls).To(Equal([]string{"new"}))
+		})
+	})
+
+	Describe("Release", func() {
+		It("runs the callbacks of the guid in registration order", func() {
+			calls := []string{}
+			registry.Register("guid-1", "first", func(lager.Logger) { calls = append(calls, "first") })
+			registry.Register("guid-1", "second", func(lager.Logger) { calls = append(calls, "second") })
+			registry.Register("guid-2", "other", func(lager.Logger) { calls = append(calls, "other") })
+
+			Expect(registry.Release(logger, "guid-1")).To(Equal(2))
+			Expect(calls).To(Equal([]string{"first", "second"}))
+			Expect(registry.Count()).To(Equal(1))
+		})
+
+		It("runs each callback exactly once", func() {
+			calls := 0
+			registry.Register("guid-1", "component", func(lager.Logger) { calls++ })
+
+			registry.Release(logger, "guid-1")
+			Expect(registry.Release(logger, "guid-1")).To(Equal(0))
+			Expect(calls).To(Equal(1))
+		})
+	})
+
+	Describe("Count", func() {
+		It("counts guids rather than callbacks", func() {
+			registry.Register("guid-1", "a", func(lager.Logger) {})
+			registry.Register("guid-1", "b", func(lager.Logger) {})
+			registry.Register("guid-2", "a", func(lager.Logger) {})
+
+			Expect(registry.Count()).To(Equal(2))
+		})
+	})
+
+	Describe("Check", func() {
+		BeforeEach(func() {
+			registry.Register("guid-1", "component", func(lager.Logger) {})
+			registry.Register("guid-2", "component", func(lager.Logger) {})
+			registry.Register("guid-3", "component", func(lager.Logger) {})
+		})
+
+		It("returns zero while the registry is within the slack", func() {
+			Expect(registry.Check(logger, 1, 2)).To(Equal(0))
+			Expect(logger).NotTo(gbytes.Say("resource-registry-exceeds-container-count"))
+		})
+
+		Context("when a component skipped its own cleanup", func() {
+			var leaked int
+
+			BeforeEach(func() {
+				registry.Register("guid-4", "component", func(lager.Logger) { leaked-- })
+				leaked = 1
+			})
+
+			It("reports the discrepancy and still cleans up on release", func() {
+				Expect(registry.Check(logger, 1, 1)).To(Equal(2))
+				Expect(logger).To(gbytes.Say("resource-registry-exceeds-container-count"))
+				Expect(logger).To(gbytes.Say("guid-4"))
+
+				registry.Release(logger, "guid-4")
+				Expect(leaked).To(Equal(0))
+			})
+		})
+	})
+})
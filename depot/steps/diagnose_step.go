@@ -0,0 +1,105 @@
+package steps
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+type diagnoseStep struct {
+	substep    ifrit.Runner
+	diagnostic ifrit.Runner
+	timeout    time.Duration
+	clock      clock.Clock
+	logger     lager.Logger
+}
+
+// NewDiagnose returns a step that runs diagnostic when substep fails on its
+// own after becoming ready, before the failure is returned. Failures before
+// substep is ready (e.g. a start timeout) are returned without a diagnostic.
+// The diagnostic is killed once timeout elapses, or when a signal is received
+// while it runs; the step does not wait for it to exit in either case, and its
+// outcome does not change the error returned.
+func NewDiagnose(substep, diagnostic ifrit.Runner, timeout time.Duration, clock clock.Clock, logger lager.Logger) ifrit.Runner {
+	return &diagnoseStep{
+		substep:    substep,
+		diagnostic: diagnostic,
+		timeout:    timeout,
+		clock:      clock,
+		logger:     logger.Session("diagnose-step"),
+	}
+}
+
+func (step *diagnoseStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	subStepSignals := make(chan os.Signal, 1)
+	subStepReady := make(chan struct{})
+	resultCh := make(chan error, 1)
+
+	go func() {
+		resultCh <- step.substep.Run(subStepSignals, subStepReady)
+	}()
+
+	var err error
+	becameReady := false
+	signalled := false
+	for done := false; !done; {
+		select {
+		case <-subStepReady:
+			becameReady = true
+			close(ready)
+			subStepReady = nil
+		case s := <-signals:
+			signalled = true
+			select {
+			case subStepSignals <- s:
+			default:
+			}
+		case err = <-resultCh:
+			done = true
+		}
+	}
+
+	if !becameReady {
+		select {
+		case <-subStepReady:
+			becameReady = true
+		default:
+		}
+	}
+
+	if err == nil || signalled {
+		return err
+	}
+
+	if !becameReady {
+		step.logger.Info("skipping-diagnostic-before-ready", lager.Data{"error": err.Error()})
+		return err
+	}
+
+	step.logger.Info("running-diagnostic", lager.Data{"error": err.Error()})
+	diagnostic := ifrit.Background(step.diagnostic)
+
+	timer := step.clock.NewTimer(step.timeout)
+	defer timer.Stop()
+
+	select {
+	case diagErr := <-diagnostic.Wait():
+		if diagErr != nil {
+			step.logger.Info("diagnostic-failed", lager.Data{"error": diagErr.Error()})
+		} else {
+			step.logger.Info("diagnostic-complete")
+		}
+		return err
+	case <-timer.C():
+		step.logger.Info("diagnostic-timed-out", lager.Data{"timeout": step.timeout.String()})
+		diagnostic.Signal(os.Kill)
+		return err
+	case <-signals:
+		step.logger.Info("diagnostic-cancelled")
+		diagnostic.Signal(os.Kill)
+		return ErrCancelled
+	}
+}
@@ -0,0 +1,126 @@
+package steps_test
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/fake_runner"
+)
+
+var _ = Describe("DiagnoseStep", func() {
+	var (
+		substep    *fake_runner.TestRunner
+		diagnostic *fake_runner.TestRunner
+		clock      *fakeclock.FakeClock
+		timeout    time.Duration
+		process    ifrit.Process
+	)
+
+	BeforeEach(func() {
+		substep = fake_runner.NewTestRunner()
+		diagnostic = fake_runner.NewTestRunner()
+		clock = fakeclock.NewFakeClock(time.Now())
+		timeout = 30 * time.Second
+	})
+
+	JustBeforeEach(func() {
+		step := steps.NewDiagnose(substep, diagnostic, timeout, clock, lagertest.NewTestLogger("test"))
+		process = ifrit.Background(step)
+	})
+
+	AfterEach(func() {
+		substep.EnsureExit()
+		diagnostic.EnsureExit()
+	})
+
+	It("becomes ready when the substep is ready", func() {
+		Consistently(process.Ready()).ShouldNot(BeClosed())
+		substep.TriggerReady()
+		Eventually(process.Ready()).Should(BeClosed())
+	})
+
+	Context("when the substep succeeds", func() {
+		It("does not run the diagnostic", func() {
+			substep.TriggerExit(nil)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+			Expect(diagnostic.RunCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the substep fails before becoming ready", func() {
+		It("returns the failure without running the diagnostic", func() {
+			substepErr := errors.New("timed out before healthy")
+			substep.TriggerExit(substepErr)
+
+			Eventually(process.Wait()).Should(Receive(Equal(substepErr)))
+			Expect(diagnostic.RunCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the substep fails after becoming ready", func() {
+		var substepErr error
+
+		JustBeforeEach(func() {
+			substepErr = errors.New("became unhealthy")
+			substep.TriggerReady()
+			Eventually(process.Ready()).Should(BeClosed())
+			substep.TriggerExit(substepErr)
+		})
+
+		It("runs the diagnostic before returning the failure", func() {
+			Eventually(diagnostic.RunCallCount).Should(Equal(1))
+			Consistently(process.Wait()).ShouldNot(Receive())
+
+			diagnostic.TriggerExit(nil)
+			Eventually(process.Wait()).Should(Receive(Equal(substepErr)))
+		})
+
+		It("returns the failure when the diagnostic fails", func() {
+			Eventually(diagnostic.RunCallCount).Should(Equal(1))
+			diagnostic.TriggerExit(errors.New("no thread dump"))
+			Eventually(process.Wait()).Should(Receive(Equal(substepErr)))
+		})
+
+		It("kills the diagnostic when it times out without waiting for it to exit", func() {
+			Eventually(diagnostic.RunCallCount).Should(Equal(1))
+			clock.WaitForWatcherAndIncrement(timeout)
+
+			signals, _ := diagnostic.RunArgsForCall(0)
+			Eventually(signals).Should(Receive(Equal(os.Kill)))
+			Eventually(process.Wait()).Should(Receive(Equal(substepErr)))
+		})
+
+		Context("when signalled while the diagnostic is running", func() {
+			It("kills the diagnostic without waiting for it to exit", func() {
+				Eventually(diagnostic.RunCallCount).Should(Equal(1))
+				process.Signal(os.Interrupt)
+
+				signals, _ := diagnostic.RunArgsForCall(0)
+				Eventually(signals).Should(Receive(Equal(os.Kill)))
+				Eventually(process.Wait()).Should(Receive(Equal(steps.ErrCancelled)))
+			})
+		})
+	})
+
+	Context("when signalled before the substep exits", func() {
+		It("forwards the signal and does not run the diagnostic", func() {
+			process.Signal(os.Interrupt)
+
+			Eventually(substep.RunCallCount).Should(Equal(1))
+			signals, _ := substep.RunArgsForCall(0)
+			Eventually(signals).Should(Receive(Equal(os.Interrupt)))
+			substep.TriggerExit(steps.ErrCancelled)
+
+			Eventually(process.Wait()).Should(Receive(Equal(steps.ErrCancelled)))
+			Expect(diagnostic.RunCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -1,10 +1,12 @@
 package steps
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
 	"os"
+	"strings"
 
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/bytefmt"
@@ -15,12 +17,55 @@ import (
 	"github.com/tedsuo/ifrit"
 )
 
+var ErrArtifactTooLarge = errors.New("artifact exceeded the maximum download size")
+var ErrNoOCIFetcher = errors.New("no OCI fetcher configured")
+
+// OCIScheme is the URL scheme of download actions that pull an image from an
+// OCI registry, as in oci://registry.example.com/org/image:tag.
+const OCIScheme = "oci"
+
+//go:generate counterfeiter -o stepsfakes/fake_ocifetcher.go . OCIFetcher
+
+// OCIFetcher pulls an image from an OCI registry and returns its layers
+// applied in order, as a single tar stream, along with its size when known.
+type OCIFetcher interface {
+	Fetch(logger lager.Logger, imageRef string, cancelChan <-chan struct{}) (io.ReadCloser, int64, error)
+}
+
+//go:generate counterfeiter -o stepsfakes/fake_compressedfetcher.go . CompressedFetcher
+
+// CompressedFetcher downloads an artifact over HTTP, asking the server to
+// gzip it on the wire and decoding the response only if the server did, and
+// returns the decoded content along with its size when known.
+//
+// models.DownloadAction comes from bbs and has no field to opt a single
+// download in, so compression is enabled for the whole executor by
+// configuring a fetcher.
+type CompressedFetcher interface {
+	Fetch(logger lager.Logger, url *url.URL, checksum cacheddownloader.ChecksumInfoType, cancelChan <-chan struct{}) (io.ReadCloser, int64, error)
+}
+
+//go:generate counterfeiter -o stepsfakes/fake_artifactsizer.go . ArtifactSizer
+
+// ArtifactSizer asks the server for the size of an artifact without
+// downloading it, and returns -1 when the server does not report one.
+type ArtifactSizer interface {
+	ContentLength(logger lager.Logger, url *url.URL, cancelChan <-chan struct{}) (int64, error)
+}
+
 type downloadStep struct {
 	container        garden.Container
 	model            models.DownloadAction
+	skipIfPresent    bool
 	cachedDownloader cacheddownloader.CachedDownloader
+	ociFetcher       OCIFetcher
+	compressed       CompressedFetcher
 	streamer         log_streamer.LogStreamer
 	rateLimiter      chan struct{}
+	containerLimiter chan struct{}
+	maxSizeBytes     int64
+	sizer            ArtifactSizer
+	bytesDownloaded  *TransferCounter
 	cancelDownload   chan struct{}
 
 	logger lager.Logger
@@ -29,8 +74,15 @@ type downloadStep struct {
 func NewDownload(
 	container garden.Container,
 	model models.DownloadAction,
+	skipIfPresent bool,
 	cachedDownloader cacheddownloader.CachedDownloader,
+	ociFetcher OCIFetcher,
+	compressedFetcher CompressedFetcher,
 	rateLimiter chan struct{},
+	containerLimiter chan struct{},
+	maxSizeBytes int64,
+	artifactSizer ArtifactSizer,
+	bytesDownloaded *TransferCounter,
 	streamer log_streamer.LogStreamer,
 	logger lager.Logger,
 ) ifrit.Runner {
@@ -43,9 +95,16 @@ func NewDownload(
 	return &downloadStep{
 		container:        container,
 		model:            model,
+		skipIfPresent:    skipIfPresent,
 		cachedDownloader: cachedDownloader,
+		ociFetcher:       ociFetcher,
+		compressed:       compressedFetcher,
 		streamer:         streamer,
 		rateLimiter:      rateLimiter,
+		containerLimiter: containerLimiter,
+		maxSizeBytes:     maxSizeBytes,
+		sizer:            artifactSizer,
+		bytesDownloaded:  bytesDownloaded,
 		logger:           logger,
 		cancelDownload:   make(chan struct{}),
 	}
@@ -54,6 +113,26 @@ func NewDownload(
 func (step *downloadStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	close(ready)
 
+	if step.skipIfPresent && step.present() {
+		step.emit("Skipped downloading %s (already present)\n", step.model.Artifact)
+		return nil
+	}
+
+	// containerLimiter is acquired first so that downloads queued behind
+	// their own container's limit do not hold global slots
+	if step.containerLimiter != nil {
+		step.logger.Info("acquiring-container-limiter")
+		select {
+		case step.containerLimiter <- struct{}{}:
+		case <-signals:
+			return ErrCancelled
+		}
+		defer func() {
+			<-step.containerLimiter
+		}()
+		step.logger.Info("acquired-container-limiter")
+	}
+
 	step.logger.Info("acquiring-limiter")
 	select {
 	case step.rateLimiter <- struct{}{}:
@@ -83,6 +162,9 @@ func (step *downloadStep) perform() error {
 	step.emit("Downloading %s...\n", step.model.Artifact)
 
 	downloadedFile, downloadedSize, err := step.fetch()
+	if err == ErrArtifactTooLarge {
+		return step.tooLarge()
+	}
 	if err != nil {
 		var errString string
 		if step.model.Artifact != "" {
@@ -95,7 +177,16 @@ func (step *downloadStep) perform() error {
 		return NewEmittableError(err, errString)
 	}
 
+	if step.maxSizeBytes > 0 && downloadedSize > step.maxSizeBytes {
+		downloadedFile.Close()
+		step.logger.Error("fetched-artifact-too-large", ErrArtifactTooLarge, lager.Data{"size": downloadedSize, "max-size-bytes": step.maxSizeBytes})
+		return step.tooLarge()
+	}
+
 	err = step.streamIn(step.model.To, downloadedFile)
+	if err == ErrArtifactTooLarge {
+		return step.tooLarge()
+	}
 	if err != nil {
 		var errString string
 		if step.model.Artifact != "" {
@@ -116,6 +207,21 @@ func (step *downloadStep) perform() error {
 	return nil
 }
 
+// present is a best-effort check for whether the download target already
+// exists in the container. Any error streaming it out is treated as absent so
+// that the step falls back to a full download.
+func (step *downloadStep) present() bool {
+	stream, err := step.container.StreamOut(garden.StreamOutSpec{Path: step.model.To, User: step.model.User})
+	if err != nil {
+		step.logger.Info("not-present", lager.Data{"error": err.Error()})
+		return false
+	}
+	stream.Close()
+
+	step.logger.Info("already-present")
+	return true
+}
+
 func (step *downloadStep) fetch() (io.ReadCloser, int64, error) {
 	step.logger.Info("fetch-starting")
 	url, err := url.ParseRequestURI(step.model.From)
@@ -124,14 +230,33 @@ func (step *downloadStep) fetch() (io.ReadCloser, int64, error) {
 		return nil, 0, err
 	}
 
+	if url.Scheme == OCIScheme {
+		return step.fetchImage(strings.TrimPrefix(step.model.From, OCIScheme+"://"))
+	}
+
+	checksum := cacheddownloader.ChecksumInfoType{
+		Algorithm: step.model.GetChecksumAlgorithm(),
+		Value:     step.model.GetChecksumValue(),
+	}
+
+	// cached downloads keep going through the cache, which cannot ask for a
+	// compressed response
+	if step.compressed != nil && step.model.CacheKey == "" && (url.Scheme == "http" || url.Scheme == "https") {
+		return step.fetchCompressed(url, checksum)
+	}
+
+	// the cached downloader writes the whole artifact to the cell's disk
+	// before the limit can be applied while streaming it in
+	err = step.checkContentLength(url)
+	if err != nil {
+		return nil, 0, err
+	}
+
 	tarStream, downloadedSize, err := step.cachedDownloader.Fetch(
 		step.logger.Session("downloader"),
 		url,
 		step.model.CacheKey,
-		cacheddownloader.ChecksumInfoType{
-			Algorithm: step.model.GetChecksumAlgorithm(),
-			Value:     step.model.GetChecksumValue(),
-		},
+		checksum,
 		step.cancelDownload,
 	)
 	if err != nil {
@@ -143,13 +268,86 @@ func (step *downloadStep) fetch() (io.ReadCloser, int64, error) {
 	return tarStream, downloadedSize, nil
 }
 
+// checkContentLength fails with ErrArtifactTooLarge when the server reports
+// a size above the limit. Servers that report no size, or that reject the
+// request, are left to the limit applied while streaming in.
+func (step *downloadStep) checkContentLength(url *url.URL) error {
+	if step.maxSizeBytes <= 0 || step.sizer == nil || (url.Scheme != "http" && url.Scheme != "https") {
+		return nil
+	}
+
+	size, err := step.sizer.ContentLength(step.logger.Session("content-length"), url, step.cancelDownload)
+	if err != nil {
+		step.logger.Info("content-length-unknown", lager.Data{"error": err.Error()})
+		return nil
+	}
+
+	if size > step.maxSizeBytes {
+		step.logger.Error("reported-artifact-too-large", ErrArtifactTooLarge, lager.Data{"size": size, "max-size-bytes": step.maxSizeBytes})
+		return ErrArtifactTooLarge
+	}
+	return nil
+}
+
+// fetchImage pulls the layers of an OCI image instead of a tar over HTTP.
+// Images are not stored in the download cache.
+func (step *downloadStep) fetchImage(imageRef string) (io.ReadCloser, int64, error) {
+	logger := step.logger.Session("fetch-image", lager.Data{"image-ref": imageRef})
+
+	if step.ociFetcher == nil {
+		logger.Error("no-oci-fetcher", ErrNoOCIFetcher)
+		return nil, 0, ErrNoOCIFetcher
+	}
+
+	tarStream, downloadedSize, err := step.ociFetcher.Fetch(logger, imageRef, step.cancelDownload)
+	if err != nil {
+		logger.Error("fetch-failed", err)
+		return nil, 0, err
+	}
+
+	logger.Info("fetch-complete", lager.Data{"size": downloadedSize})
+	return tarStream, downloadedSize, nil
+}
+
+func (step *downloadStep) fetchCompressed(url *url.URL, checksum cacheddownloader.ChecksumInfoType) (io.ReadCloser, int64, error) {
+	logger := step.logger.Session("fetch-compressed")
+
+	tarStream, downloadedSize, err := step.compressed.Fetch(logger, url, checksum, step.cancelDownload)
+	if err != nil {
+		logger.Error("fetch-failed", err)
+		return nil, 0, err
+	}
+
+	logger.Info("fetch-complete", lager.Data{"size": downloadedSize})
+	return tarStream, downloadedSize, nil
+}
+
 func (step *downloadStep) streamIn(destination string, reader io.ReadCloser) error {
 	step.logger.Info("stream-in-starting")
+	defer reader.Close()
+
+	counted := &ReadSizer{Reader: reader}
+	defer func() {
+		step.bytesDownloaded.Add(int64(counted.BytesRead()))
+	}()
+
+	var source io.Reader = counted
+	var limited *maxSizeReader
+	if step.maxSizeBytes > 0 {
+		limited = &maxSizeReader{Reader: counted, remaining: step.maxSizeBytes}
+		source = limited
+	}
 
-	wrappedReader := &ReadSizer{Reader: reader}
+	wrappedReader := &ReadSizer{Reader: source}
 
 	// StreamIn will close the reader
 	err := step.container.StreamIn(garden.StreamInSpec{Path: destination, TarStream: wrappedReader, User: step.model.User})
+	if limited != nil && limited.exceeded {
+		// garden may report the failed read as a transport error, so the
+		// reader is the source of truth
+		step.logger.Error("stream-in-exceeded-max-size", ErrArtifactTooLarge, lager.Data{"max-size-bytes": step.maxSizeBytes})
+		return ErrArtifactTooLarge
+	}
 	if err != nil {
 		step.logger.Error("stream-in-failed", err, lager.Data{
 			"destination": destination,
@@ -161,6 +359,12 @@ func (step *downloadStep) streamIn(destination string, reader io.ReadCloser) err
 	return nil
 }
 
+func (step *downloadStep) tooLarge() error {
+	errString := fmt.Sprintf("Artifact exceeded maximum download size of %d bytes", step.maxSizeBytes)
+	step.emitError(fmt.Sprintf("%s\n", errString))
+	return NewEmittableError(ErrArtifactTooLarge, errString)
+}
+
 func (step *downloadStep) emit(format string, a ...interface{}) {
 	if step.model.Artifact != "" {
 		fmt.Fprintf(step.streamer.Stdout(), format, a...)
@@ -191,3 +395,28 @@ func (r *ReadSizer) Read(dest []byte) (int, error) {
 func (r *ReadSizer) BytesRead() int {
 	return r.bytesRead
 }
+
+// maxSizeReader fails with ErrArtifactTooLarge once more than remaining bytes
+// have been read from it.
+type maxSizeReader struct {
+	io.Reader
+	remaining int64
+	exceeded  bool
+}
+
+func (r *maxSizeReader) Read(dest []byte) (int, error) {
+	if r.exceeded {
+		return 0, ErrArtifactTooLarge
+	}
+	if int64(len(dest)) > r.remaining+1 {
+		dest = dest[:r.remaining+1]
+	}
+
+	n, err := r.Reader.Read(dest)
+	if int64(n) > r.remaining {
+		r.exceeded = true
+		return int(r.remaining), ErrArtifactTooLarge
+	}
+	r.remaining -= int64(n)
+	return n, err
+}
@@ -20,6 +20,7 @@ import (
 
 	"code.cloudfoundry.org/executor/depot/log_streamer/fake_log_streamer"
 	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/executor/depot/steps/stepsfakes"
 	"code.cloudfoundry.org/executor/fakes"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -35,10 +36,18 @@ var _ = Describe("DownloadAction", func() {
 
 		downloadAction models.DownloadAction
 		cache          *cdfakes.FakeCachedDownloader
+		ociFetcher     steps.OCIFetcher
+		compressed     steps.CompressedFetcher
 		gardenClient   *fakes.FakeGardenClient
 		fakeStreamer   *fake_log_streamer.FakeLogStreamer
 		logger         *lagertest.TestLogger
 		rateLimiter    chan struct{}
+
+		containerLimiter chan struct{}
+		skipIfPresent    bool
+		maxSizeBytes     int64
+		sizer            *stepsfakes.FakeArtifactSizer
+		bytesDownloaded  *steps.TransferCounter
 	)
 
 	handle := "some-container-handle"
@@ -46,6 +55,8 @@ var _ = Describe("DownloadAction", func() {
 	BeforeEach(func() {
 		cache = &cdfakes.FakeCachedDownloader{}
 		cache.FetchReturns(ioutil.NopCloser(new(bytes.Buffer)), 42, nil)
+		ociFetcher = nil
+		compressed = nil
 
 		downloadAction = models.DownloadAction{
 			From:     "http://mr_jones",
@@ -60,6 +71,12 @@ var _ = Describe("DownloadAction", func() {
 		logger = lagertest.NewTestLogger("test")
 
 		rateLimiter = make(chan struct{}, 1)
+		containerLimiter = make(chan struct{}, 1)
+		skipIfPresent = false
+		maxSizeBytes = 0
+		sizer = &stepsfakes.FakeArtifactSizer{}
+		sizer.ContentLengthReturns(-1, nil)
+		bytesDownloaded = new(steps.TransferCounter)
 	})
 
 	Describe("Run", func() {
@@ -76,8 +93,15 @@ var _ = Describe("DownloadAction", func() {
 			step = steps.NewDownload(
 				container,
 				downloadAction,
+				skipIfPresent,
 				cache,
+				ociFetcher,
+				compressed,
 				rateLimiter,
+				containerLimiter,
+				maxSizeBytes,
+				sizer,
+				bytesDownloaded,
 				fakeStreamer,
 				logger,
 			)
@@ -98,6 +122,67 @@ var _ = Describe("DownloadAction", func() {
 			Expect(cancelChan).NotTo(BeNil())
 		})
 
+		It("releases both download limiters", func() {
+			Expect(stepErr).NotTo(HaveOccurred())
+			Expect(rateLimiter).To(BeEmpty())
+			Expect(containerLimiter).To(BeEmpty())
+		})
+
+		It("does not check whether the target is already present", func() {
+			Expect(gardenClient.Connection.StreamOutCallCount()).To(Equal(0))
+		})
+
+		Context("when skipping downloads that are already present", func() {
+			BeforeEach(func() {
+				skipIfPresent = true
+			})
+
+			Context("when the target can be streamed out of the container", func() {
+				BeforeEach(func() {
+					gardenClient.Connection.StreamOutReturns(ioutil.NopCloser(new(bytes.Buffer)), nil)
+				})
+
+				It("skips the download", func() {
+					Expect(stepErr).NotTo(HaveOccurred())
+
+					Expect(gardenClient.Connection.StreamOutCallCount()).To(Equal(1))
+					_, spec := gardenClient.Connection.StreamOutArgsForCall(0)
+					Expect(spec).To(Equal(garden.StreamOutSpec{Path: "/tmp/Antarctica", User: "notroot"}))
+
+					Expect(cache.FetchCallCount()).To(Equal(0))
+					Expect(gardenClient.Connection.StreamInCallCount()).To(Equal(0))
+				})
+
+				It("does not take the download limiters", func() {
+					Expect(rateLimiter).To(BeEmpty())
+					Expect(containerLimiter).To(BeEmpty())
+				})
+			})
+
+			Context("when streaming the target out of the container fails", func() {
+				BeforeEach(func() {
+					gardenClient.Connection.StreamOutReturns(nil, errors.New("not found"))
+				})
+
+				It("falls back to a full download", func() {
+					Expect(stepErr).NotTo(HaveOccurred())
+					Expect(cache.FetchCallCount()).To(Equal(1))
+					Expect(gardenClient.Connection.StreamInCallCount()).To(Equal(1))
+				})
+			})
+		})
+
+		Context("when there is no container limiter", func() {
+			BeforeEach(func() {
+				containerLimiter = nil
+			})
+
+			It("downloads using only the global limiter", func() {
+				Expect(stepErr).NotTo(HaveOccurred())
+				Expect(cache.FetchCallCount()).To(Equal(1))
+			})
+		})
+
 		Context("when checksum is provided", func() {
 			BeforeEach(func() {
 				downloadAction.ChecksumAlgorithm = "md5"
@@ -116,6 +201,133 @@ var _ = Describe("DownloadAction", func() {
 			})
 		})
 
+		Context("when the download streams in", func() {
+			BeforeEach(func() {
+				cache.FetchReturns(ioutil.NopCloser(strings.NewReader("some-tar-contents")), 17, nil)
+				gardenClient.Connection.StreamInStub = func(handle string, spec garden.StreamInSpec) error {
+					_, err := io.Copy(ioutil.Discard, spec.TarStream)
+					return err
+				}
+			})
+
+			It("counts the bytes downloaded", func() {
+				Expect(stepErr).NotTo(HaveOccurred())
+				Expect(bytesDownloaded.Total()).To(BeEquivalentTo(17))
+			})
+		})
+
+		Context("when the download is an OCI image", func() {
+			var fakeOCIFetcher *stepsfakes.FakeOCIFetcher
+
+			BeforeEach(func() {
+				downloadAction.From = "oci://registry.example.com/org/image:tag"
+
+				fakeOCIFetcher = new(stepsfakes.FakeOCIFetcher)
+				fakeOCIFetcher.FetchReturns(ioutil.NopCloser(strings.NewReader("some-layers")), 11, nil)
+				ociFetcher = fakeOCIFetcher
+
+				gardenClient.Connection.StreamInStub = func(handle string, spec garden.StreamInSpec) error {
+					_, err := io.Copy(ioutil.Discard, spec.TarStream)
+					return err
+				}
+			})
+
+			It("pulls the image layers with the OCI fetcher instead of the cache", func() {
+				Expect(stepErr).NotTo(HaveOccurred())
+				Expect(cache.FetchCallCount()).To(BeZero())
+
+				Expect(fakeOCIFetcher.FetchCallCount()).To(Equal(1))
+				_, imageRef, _ := fakeOCIFetcher.FetchArgsForCall(0)
+				Expect(imageRef).To(Equal("registry.example.com/org/image:tag"))
+			})
+
+			It("streams the layers into the container", func() {
+				Expect(gardenClient.Connection.StreamInCallCount()).To(Equal(1))
+				_, spec := gardenClient.Connection.StreamInArgsForCall(0)
+				Expect(spec.Path).To(Equal("/tmp/Antarctica"))
+				Expect(bytesDownloaded.Total()).To(BeEquivalentTo(11))
+			})
+
+			Context("when the image cannot be pulled", func() {
+				BeforeEach(func() {
+					fakeOCIFetcher.FetchReturns(nil, 0, errors.New("manifest unknown"))
+				})
+
+				It("fails", func() {
+					Expect(stepErr).To(MatchError(ContainSubstring("Downloading failed")))
+				})
+			})
+
+			Context("when there is no OCI fetcher", func() {
+				BeforeEach(func() {
+					ociFetcher = nil
+				})
+
+				It("fails", func() {
+					Expect(stepErr).To(HaveOccurred())
+					Expect(cache.FetchCallCount()).To(BeZero())
+					Expect(logger).To(gbytes.Say("no-oci-fetcher"))
+				})
+			})
+		})
+
+		Context("when compressed downloads are enabled", func() {
+			var fakeCompressedFetcher *stepsfakes.FakeCompressedFetcher
+
+			BeforeEach(func() {
+				downloadAction.ChecksumAlgorithm = "sha256"
+				downloadAction.ChecksumValue = "some-checksum"
+
+				fakeCompressedFetcher = new(stepsfakes.FakeCompressedFetcher)
+				fakeCompressedFetcher.FetchReturns(ioutil.NopCloser(strings.NewReader("decoded-content")), 0, nil)
+				compressed = fakeCompressedFetcher
+
+				gardenClient.Connection.StreamInStub = func(handle string, spec garden.StreamInSpec) error {
+					_, err := io.Copy(ioutil.Discard, spec.TarStream)
+					return err
+				}
+			})
+
+			Context("and the download is not cached", func() {
+				BeforeEach(func() {
+					downloadAction.CacheKey = ""
+				})
+
+				It("fetches it with the compressed fetcher instead of the cache", func() {
+					Expect(stepErr).NotTo(HaveOccurred())
+					Expect(cache.FetchCallCount()).To(BeZero())
+
+					Expect(fakeCompressedFetcher.FetchCallCount()).To(Equal(1))
+					_, fetchedURL, checksum, _ := fakeCompressedFetcher.FetchArgsForCall(0)
+					Expect(fetchedURL.String()).To(Equal("http://mr_jones"))
+					Expect(checksum).To(Equal(cacheddownloader.ChecksumInfoType{Algorithm: "sha256", Value: "some-checksum"}))
+				})
+
+				It("streams the decoded content into the container", func() {
+					Expect(gardenClient.Connection.StreamInCallCount()).To(Equal(1))
+					Expect(bytesDownloaded.Total()).To(BeEquivalentTo(len("decoded-content")))
+				})
+
+				Context("when the fetch fails", func() {
+					BeforeEach(func() {
+						fakeCompressedFetcher.FetchReturns(nil, 0, errors.New("connection refused"))
+					})
+
+					It("fails", func() {
+						Expect(stepErr).To(MatchError(ContainSubstring("Downloading failed")))
+					})
+				})
+			})
+
+			Context("and the download is cached", func() {
+				It("fetches it through the cache", func() {
+					Expect(stepErr).NotTo(HaveOccurred())
+					Expect(cache.FetchCallCount()).To(Equal(1))
+					Expect(fakeCompressedFetcher.FetchCallCount()).To(BeZero())
+				})
+			})
+		})
+
 		It("logs the step", func() {
 			Expect(logger.TestSink.LogMessages()).To(ConsistOf([]string{
 				"test.download-step.acquiring-limiter",
@@ -225,6 +437,97 @@ var _ = Describe("DownloadAction", func() {
 				})
 			})
 
+			It("does not ask the server for the size of the artifact", func() {
+				Expect(sizer.ContentLengthCallCount()).To(BeZero())
+			})
+
+			Context("and a maximum download size is set", func() {
+				BeforeEach(func() {
+					maxSizeBytes = 10
+				})
+
+				It("asks the server for the size of the artifact before fetching it", func() {
+					Expect(sizer.ContentLengthCallCount()).To(Equal(1))
+					_, url, cancelChan := sizer.ContentLengthArgsForCall(0)
+					Expect(url.Host).To(Equal("mr_jones"))
+					Expect(cancelChan).NotTo(BeNil())
+				})
+
+				Context("when the server reports a larger artifact", func() {
+					BeforeEach(func() {
+						sizer.ContentLengthReturns(19, nil)
+					})
+
+					It("fails without fetching it", func() {
+						Expect(stepErr).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+						Expect(stepErr.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrArtifactTooLarge))
+						Expect(cache.FetchCallCount()).To(BeZero())
+					})
+
+					It("emits the error", func() {
+						Expect(fakeStreamer.Stderr().(*gbytes.Buffer)).To(gbytes.Say("Artifact exceeded maximum download size of 10 bytes\n"))
+					})
+				})
+
+				Context("when the server cannot report the size of the artifact", func() {
+					BeforeEach(func() {
+						sizer.ContentLengthReturns(0, errors.New("forbidden"))
+					})
+
+					It("fetches it anyway", func() {
+						Expect(cache.FetchCallCount()).To(Equal(1))
+					})
+				})
+
+				Context("when the fetched artifact is larger", func() {
+					BeforeEach(func() {
+						cache.FetchReturns(ioutil.NopCloser(bytes.NewBufferString("more than ten bytes")), 19, nil)
+					})
+
+					It("fails without streaming it into the container", func() {
+						Expect(stepErr).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+						Expect(stepErr.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrArtifactTooLarge))
+						Expect(stepErr.Error()).To(Equal("Artifact exceeded maximum download size of 10 bytes"))
+						Expect(gardenClient.Connection.StreamInCallCount()).To(BeZero())
+					})
+
+					It("emits the error", func() {
+						Expect(fakeStreamer.Stderr().(*gbytes.Buffer)).To(gbytes.Say("Artifact exceeded maximum download size of 10 bytes\n"))
+					})
+				})
+
+				Context("when the size of the fetched artifact is unknown and it is larger", func() {
+					BeforeEach(func() {
+						cache.FetchReturns(ioutil.NopCloser(bytes.NewBufferString("more than ten bytes")), 0, nil)
+						gardenClient.Connection.StreamInStub = func(handle string, spec garden.StreamInSpec) error {
+							_, err := io.Copy(ioutil.Discard, spec.TarStream)
+							return err
+						}
+					})
+
+					It("fails once the limit has been read", func() {
+						Expect(stepErr).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+						Expect(stepErr.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrArtifactTooLarge))
+						Expect(stepErr.Error()).To(Equal("Artifact exceeded maximum download size of 10 bytes"))
+					})
+				})
+
+				Context("when the fetched artifact fits", func() {
+					BeforeEach(func() {
+						cache.FetchReturns(ioutil.NopCloser(bytes.NewBufferString("ten bytes!")), 0, nil)
+						gardenClient.Connection.StreamInStub = func(handle string, spec garden.StreamInSpec) error {
+							_, err := io.Copy(ioutil.Discard, spec.TarStream)
+							return err
+						}
+					})
+
+					It("streams it into the container", func() {
+						Expect(stepErr).NotTo(HaveOccurred())
+						Expect(gardenClient.Connection.StreamInCallCount()).To(Equal(1))
+					})
+				})
+			})
+
 			Context("when there is an error copying the extracted files into the container", func() {
 				var expectedErr error
 
@@ -349,8 +652,15 @@ var _ = Describe("DownloadAction", func() {
 			step = steps.NewDownload(
 				container,
 				downloadAction,
+				skipIfPresent,
 				cache,
+				nil,
+				nil,
 				rateLimiter,
+				containerLimiter,
+				maxSizeBytes,
+				sizer,
+				nil,
 				fakeStreamer,
 				logger,
 			)
@@ -383,8 +693,15 @@ var _ = Describe("DownloadAction", func() {
 			step = steps.NewDownload(
 				container,
 				downloadAction,
+				skipIfPresent,
 				cache,
+				nil,
+				nil,
 				rateLimiter,
+				containerLimiter,
+				maxSizeBytes,
+				sizer,
+				nil,
 				fakeStreamer,
 				logger,
 			)
@@ -411,6 +728,23 @@ var _ = Describe("DownloadAction", func() {
 			})
 		})
 
+		Context("when waiting on the container limiter", func() {
+			BeforeEach(func() {
+				containerLimiter <- struct{}{}
+			})
+
+			It("does not take a global download slot", func() {
+				Consistently(rateLimiter).Should(BeEmpty())
+			})
+
+			It("cancels the wait promptly", func() {
+				p.Signal(os.Interrupt)
+				Eventually(p.Wait()).Should(Receive(Equal(steps.ErrCancelled)))
+				Expect(cache.FetchCallCount()).To(Equal(0))
+				Expect(rateLimiter).To(BeEmpty())
+			})
+		})
+
 		Context("when downloading the file", func() {
 			var (
 				calledChan chan struct{}
@@ -503,8 +837,15 @@ var _ = Describe("DownloadAction", func() {
 			step1 := steps.NewDownload(
 				container,
 				downloadAction1,
+				false,
 				cache,
+				nil,
+				nil,
 				rateLimiter,
+				containerLimiter,
+				maxSizeBytes,
+				sizer,
+				nil,
 				fakeStreamer,
 				logger,
 			)
@@ -517,8 +858,15 @@ var _ = Describe("DownloadAction", func() {
 			step2 := steps.NewDownload(
 				container,
 				downloadAction2,
+				false,
 				cache,
+				nil,
+				nil,
 				rateLimiter,
+				containerLimiter,
+				maxSizeBytes,
+				sizer,
+				nil,
 				fakeStreamer,
 				logger,
 			)
@@ -531,8 +879,15 @@ var _ = Describe("DownloadAction", func() {
 			step3 := steps.NewDownload(
 				container,
 				downloadAction3,
+				false,
 				cache,
+				nil,
+				nil,
 				rateLimiter,
+				containerLimiter,
+				maxSizeBytes,
+				sizer,
+				nil,
 				fakeStreamer,
 				logger,
 			)
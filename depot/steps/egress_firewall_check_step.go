@@ -0,0 +1,52 @@
+package steps
+
+import (
+	"errors"
+	"os"
+
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+var ErrFirewallSetupFailed = errors.New("failed to set up egress firewall")
+
+type egressFirewallCheckStep struct {
+	container garden.Container
+	rules     []garden.NetOutRule
+	logger    lager.Logger
+}
+
+// NewEgressFirewallCheck returns a step that applies each egress rule to the
+// container with NetOut before anything else runs, so that a container whose
+// firewall cannot be set up fails early. The step fails with
+// ErrFirewallSetupFailed on the first rule that garden rejects.
+func NewEgressFirewallCheck(container garden.Container, rules []garden.NetOutRule, logger lager.Logger) ifrit.Runner {
+	return &egressFirewallCheckStep{
+		container: container,
+		rules:     rules,
+		logger:    logger.Session("egress-firewall-check-step"),
+	}
+}
+
+func (step *egressFirewallCheckStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	for i, rule := range step.rules {
+		select {
+		case <-signals:
+			step.logger.Info("cancelled")
+			return ErrCancelled
+		default:
+		}
+
+		err := step.container.NetOut(rule)
+		if err != nil {
+			step.logger.Error("failed-to-apply-rule", err, lager.Data{"rule-index": i})
+			return ErrFirewallSetupFailed
+		}
+	}
+
+	step.logger.Info("rules-applied", lager.Data{"count": len(step.rules)})
+	return nil
+}
@@ -0,0 +1,69 @@
+package steps_test
+
+import (
+	"errors"
+	"os"
+
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("EgressFirewallCheckStep", func() {
+	var (
+		container *gardenfakes.FakeContainer
+		rules     []garden.NetOutRule
+		logger    *lagertest.TestLogger
+		process   ifrit.Process
+	)
+
+	BeforeEach(func() {
+		container = &gardenfakes.FakeContainer{}
+		logger = lagertest.NewTestLogger("test")
+
+		rules = []garden.NetOutRule{
+			{Protocol: garden.ProtocolTCP, Ports: []garden.PortRange{garden.PortRangeFromPort(443)}},
+			{Protocol: garden.ProtocolUDP, Ports: []garden.PortRange{garden.PortRangeFromPort(53)}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		step := steps.NewEgressFirewallCheck(container, rules, logger)
+		process = ifrit.Background(step)
+	})
+
+	It("applies every rule to the container and succeeds", func() {
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+
+		Expect(container.NetOutCallCount()).To(Equal(2))
+		Expect(container.NetOutArgsForCall(0)).To(Equal(rules[0]))
+		Expect(container.NetOutArgsForCall(1)).To(Equal(rules[1]))
+	})
+
+	Context("when a rule cannot be applied", func() {
+		BeforeEach(func() {
+			container.NetOutReturnsOnCall(0, errors.New("iptables exploded"))
+		})
+
+		It("fails with ErrFirewallSetupFailed without applying the remaining rules", func() {
+			Eventually(process.Wait()).Should(Receive(Equal(steps.ErrFirewallSetupFailed)))
+			Expect(container.NetOutCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when signalled before the rules are applied", func() {
+		It("stops with ErrCancelled", func() {
+			signals := make(chan os.Signal, 1)
+			signals <- os.Interrupt
+
+			step := steps.NewEgressFirewallCheck(container, rules, logger)
+			err := step.Run(signals, make(chan struct{}))
+			Expect(err).To(Equal(steps.ErrCancelled))
+		})
+	})
+})
@@ -1,10 +1,16 @@
 package steps
 
-import "fmt"
+import (
+	"fmt"
+
+	"code.cloudfoundry.org/executor"
+	"github.com/hashicorp/go-multierror"
+)
 
 type EmittableError struct {
 	msg          string
 	wrappedError error
+	exitReason   executor.ExitReasonCode
 }
 
 func NewEmittableError(wrappedError error, message string, args ...interface{}) *EmittableError {
@@ -26,3 +32,39 @@ func (e *EmittableError) Error() string {
 func (e *EmittableError) WrappedError() error {
 	return e.wrappedError
 }
+
+// WithExitReason records why the step's process exited and returns e.
+func (e *EmittableError) WithExitReason(reason executor.ExitReasonCode) *EmittableError {
+	e.exitReason = reason
+	return e
+}
+
+func (e *EmittableError) ExitReason() executor.ExitReasonCode {
+	return e.exitReason
+}
+
+// ExitReasonFor returns the outermost exit reason recorded on err or the
+// errors it wraps. Cancellation is reported as a requested stop.
+func ExitReasonFor(err error) executor.ExitReasonCode {
+	switch err := err.(type) {
+	case nil:
+		return executor.ExitReasonUnknown
+	case *EmittableError:
+		if err.exitReason != executor.ExitReasonUnknown {
+			return err.exitReason
+		}
+		return ExitReasonFor(err.wrappedError)
+	case *multierror.Error:
+		for _, wrapped := range err.WrappedErrors() {
+			if reason := ExitReasonFor(wrapped); reason != executor.ExitReasonUnknown {
+				return reason
+			}
+		}
+		return executor.ExitReasonUnknown
+	}
+
+	if err == ErrCancelled {
+		return executor.ExitReasonStopRequested
+	}
+	return executor.ExitReasonUnknown
+}
@@ -0,0 +1,110 @@
+package steps
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/executor"
+)
+
+var ErrUnknownEnvTemplateKey = errors.New("unknown environment template key")
+
+var envTemplateToken = regexp.MustCompile(`\{\{\s*\.(\w+)\s*\}\}`)
+
+// MergeEnvironment combines several sources of "NAME=value" environment
+// variables into the environment used for a garden ProcessSpec.
+//
+// Sources are given in order of decreasing precedence, which is action,
+// container, profile and finally executor-injected variables (e.g.
+// CF_INSTANCE_*); callers pass only the sources they have.
+//
+// When a name is defined more than once the definition from the source with
+// the highest precedence wins; within a single source the last definition
+// wins. The result contains each name exactly once and is sorted by name so
+// that the same inputs always produce the same environment.
+func MergeEnvironment(sources ...[]string) []string {
+	values := map[string]string{}
+	names := []string{}
+
+	for i := len(sources) - 1; i >= 0; i-- {
+		for _, envVar := range sources[i] {
+			name := envVarName(envVar)
+			if _, ok := values[name]; !ok {
+				names = append(names, name)
+			}
+			values[name] = envVar
+		}
+	}
+
+	sort.Strings(names)
+
+	merged := make([]string, 0, len(names))
+	for _, name := range names {
+		merged = append(merged, values[name])
+	}
+	return merged
+}
+
+func envVarName(envVar string) string {
+	if i := strings.Index(envVar, "="); i >= 0 {
+		return envVar[:i]
+	}
+	return envVar
+}
+
+// EnvTemplateData is the container metadata that may be referenced from
+// environment variable values using tokens such as "{{.Guid}}".
+type EnvTemplateData struct {
+	Guid          string
+	InternalIP    string
+	InstanceIndex int
+}
+
+func NewEnvTemplateData(container executor.Container) EnvTemplateData {
+	return EnvTemplateData{
+		Guid:          container.Guid,
+		InternalIP:    container.InternalIP,
+		InstanceIndex: container.MetricsConfig.Index,
+	}
+}
+
+func (data EnvTemplateData) values() map[string]string {
+	return map[string]string{
+		"Guid":          data.Guid,
+		"InternalIP":    data.InternalIP,
+		"InstanceIndex": strconv.Itoa(data.InstanceIndex),
+	}
+}
+
+// InterpolateEnvironment returns a copy of env with every template token in
+// the values replaced by the matching field of data. Substituted values are
+// not interpolated again. Referencing a key other than the fields of
+// EnvTemplateData is an error.
+func InterpolateEnvironment(env []*models.EnvironmentVariable, data EnvTemplateData) ([]*models.EnvironmentVariable, error) {
+	if len(env) == 0 {
+		return env, nil
+	}
+
+	values := data.values()
+	interpolated := make([]*models.EnvironmentVariable, 0, len(env))
+
+	for _, envVar := range env {
+		for _, match := range envTemplateToken.FindAllStringSubmatch(envVar.Value, -1) {
+			if _, ok := values[match[1]]; !ok {
+				return nil, NewEmittableError(ErrUnknownEnvTemplateKey, "Unknown template key '%s' in environment variable %s", match[1], envVar.Name)
+			}
+		}
+
+		value := envTemplateToken.ReplaceAllStringFunc(envVar.Value, func(token string) string {
+			return values[envTemplateToken.FindStringSubmatch(token)[1]]
+		})
+
+		interpolated = append(interpolated, &models.EnvironmentVariable{Name: envVar.Name, Value: value})
+	}
+
+	return interpolated, nil
+}
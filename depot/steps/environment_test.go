@@ -0,0 +1,173 @@
+package steps_test
+
+import (
+	"math/rand"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/executor/depot/steps"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("MergeEnvironment", func() {
+	var (
+		action, container, profile, injected []string
+	)
+
+	BeforeEach(func() {
+		action = []string{"PORT=8080", "A=action"}
+		container = []string{"A=container", "B=container", "LANG=en_US.UTF-8"}
+		profile = []string{"B=profile", "C=profile"}
+		injected = []string{"CF_INSTANCE_IP=1.2.3.4", "C=injected", "PORT=61000"}
+	})
+
+	It("keeps the definition from the source with the highest precedence", func() {
+		Expect(steps.MergeEnvironment(action, container, profile, injected)).To(Equal([]string{
+			"A=action",
+			"B=container",
+			"C=profile",
+			"CF_INSTANCE_IP=1.2.3.4",
+			"LANG=en_US.UTF-8",
+			"PORT=8080",
+		}))
+	})
+
+	It("keeps the last definition within a single source", func() {
+		Expect(steps.MergeEnvironment([]string{"A=1", "B=2", "A=3"})).To(Equal([]string{"A=3", "B=2"}))
+	})
+
+	It("preserves values containing '='", func() {
+		Expect(steps.MergeEnvironment([]string{"OPTS=-Dfoo=bar"})).To(Equal([]string{"OPTS=-Dfoo=bar"}))
+	})
+
+	It("returns an empty environment when there are no sources", func() {
+		Expect(steps.MergeEnvironment()).To(BeEmpty())
+	})
+
+	Describe("properties", func() {
+		var random *rand.Rand
+
+		shuffled := func(envVars []string) []string {
+			out := append([]string{}, envVars...)
+			random.Shuffle(len(out), func(i, j int) { out[i], out[j] = out[j], out[i] })
+			return out
+		}
+
+		randomSource := func() []string {
+			envVars := []string{}
+			n := random.Intn(10)
+			for i := 0; i < n; i++ {
+				name := string(rune('A' + random.Intn(6)))
+				envVars = append(envVars, name+"="+string(rune('a'+random.Intn(26))))
+			}
+			return envVars
+		}
+
+		dedup := func(envVars []string) []string {
+			seen := map[string]string{}
+			for _, envVar := range envVars {
+				seen[strings.SplitN(envVar, "=", 2)[0]] = envVar
+			}
+			out := []string{}
+			for _, envVar := range seen {
+				out = append(out, envVar)
+			}
+			return out
+		}
+
+		BeforeEach(func() {
+			random = rand.New(rand.NewSource(GinkgoRandomSeed()))
+		})
+
+		It("never contains duplicate names", func() {
+			for i := 0; i < 100; i++ {
+				merged := steps.MergeEnvironment(randomSource(), randomSource(), randomSource(), randomSource())
+
+				names := map[string]struct{}{}
+				for _, envVar := range merged {
+					name := strings.SplitN(envVar, "=", 2)[0]
+					Expect(names).NotTo(HaveKey(name))
+					names[name] = struct{}{}
+				}
+			}
+		})
+
+		It("produces the same output for permutations of duplicate-free sources", func() {
+			for i := 0; i < 100; i++ {
+				sources := [][]string{
+					dedup(randomSource()),
+					dedup(randomSource()),
+					dedup(randomSource()),
+					dedup(randomSource()),
+				}
+				expected := steps.MergeEnvironment(sources...)
+
+				permuted := make([][]string, len(sources))
+				for j := range sources {
+					permuted[j] = shuffled(sources[j])
+				}
+				Expect(steps.MergeEnvironment(permuted...)).To(Equal(expected))
+			}
+		})
+	})
+})
+
+var _ = Describe("InterpolateEnvironment", func() {
+	var data steps.EnvTemplateData
+
+	BeforeEach(func() {
+		data = steps.EnvTemplateData{
+			Guid:          "some-guid",
+			InternalIP:    "10.0.0.1",
+			InstanceIndex: 3,
+		}
+	})
+
+	It("replaces known tokens with container metadata", func() {
+		env, err := steps.InterpolateEnvironment([]*models.EnvironmentVariable{
+			{Name: "INSTANCE", Value: "{{.Guid}}-{{ .InstanceIndex }}"},
+			{Name: "ADDR", Value: "{{.InternalIP}}:8080"},
+			{Name: "PLAIN", Value: "no-tokens"},
+		}, data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env).To(Equal([]*models.EnvironmentVariable{
+			{Name: "INSTANCE", Value: "some-guid-3"},
+			{Name: "ADDR", Value: "10.0.0.1:8080"},
+			{Name: "PLAIN", Value: "no-tokens"},
+		}))
+	})
+
+	It("does not modify the original environment", func() {
+		original := []*models.EnvironmentVariable{{Name: "GUID", Value: "{{.Guid}}"}}
+		_, err := steps.InterpolateEnvironment(original, data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(original[0].Value).To(Equal("{{.Guid}}"))
+	})
+
+	It("does not interpolate substituted values again", func() {
+		data.Guid = "{{.InternalIP}}"
+
+		env, err := steps.InterpolateEnvironment([]*models.EnvironmentVariable{{Name: "GUID", Value: "{{.Guid}}"}}, data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env[0].Value).To(Equal("{{.InternalIP}}"))
+	})
+
+	It("replaces tokens for missing values with an empty string", func() {
+		data.InternalIP = ""
+
+		env, err := steps.InterpolateEnvironment([]*models.EnvironmentVariable{{Name: "IP", Value: "ip={{.InternalIP}}"}}, data)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(env[0].Value).To(Equal("ip="))
+	})
+
+	Context("when an unknown key is referenced", func() {
+		It("returns an emittable error", func() {
+			_, err := steps.InterpolateEnvironment([]*models.EnvironmentVariable{{Name: "SECRET", Value: "{{.Password}}"}}, data)
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrUnknownEnvTemplateKey))
+			Expect(err.Error()).To(ContainSubstring("Password"))
+		})
+	})
+})
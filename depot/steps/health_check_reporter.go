@@ -0,0 +1,39 @@
+package steps
+
+import (
+	"os"
+
+	"github.com/tedsuo/ifrit"
+)
+
+// HealthCheckReporter is told the result of every check a monitor step runs.
+type HealthCheckReporter interface {
+	HealthCheckResult(err error)
+}
+
+type reportedCheck struct {
+	check    ifrit.Runner
+	reporter HealthCheckReporter
+}
+
+// NewReportedCheck reports the result of check to reporter once it exits.
+// Checks that are cancelled are not reported, and a nil reporter leaves check
+// unchanged.
+func NewReportedCheck(check ifrit.Runner, reporter HealthCheckReporter) ifrit.Runner {
+	if reporter == nil {
+		return check
+	}
+
+	return &reportedCheck{
+		check:    check,
+		reporter: reporter,
+	}
+}
+
+func (step *reportedCheck) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	err := step.check.Run(signals, ready)
+	if err != ErrCancelled {
+		step.reporter.HealthCheckResult(err)
+	}
+	return err
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/lager"
 	"github.com/tedsuo/ifrit"
@@ -64,7 +65,7 @@ func (step *healthCheckStep) Run(signals <-chan os.Signal, ready chan<- struct{}
 			step.logger.Info("timed-out-before-healthy", lager.Data{
 				"step-error": err.Error(),
 			})
-			return NewEmittableError(err, timeoutCrashReason, step.startTimeout, err.Error())
+			return NewEmittableError(err, timeoutCrashReason, step.startTimeout, err.Error()).WithExitReason(executor.ExitReasonTimeout)
 		}
 	case s := <-signals:
 		readinessProcess.Signal(s)
@@ -0,0 +1,114 @@
+package steps
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+// maxHealthCheckBodySize limits how much of the response body is inspected
+const maxHealthCheckBodySize = 64 * 1024
+
+var ErrHealthCheckFailed = errors.New("health check failed")
+
+type httpHealthCheckStep struct {
+	url            string
+	expectedStatus int
+	bodyContains   string
+	clock          clock.Clock
+	logger         lager.Logger
+	client         *http.Client
+}
+
+// NewHTTPHealthCheck returns a step that makes a single HTTP GET request to url
+// and succeeds only when the response has the expected status code and, if
+// bodyContains is not empty, the response body contains bodyContains. All
+// failures are reported as an EmittableError wrapping ErrHealthCheckFailed.
+func NewHTTPHealthCheck(
+	url string,
+	expectedStatus int,
+	bodyContains string,
+	timeout time.Duration,
+	clock clock.Clock,
+	logger lager.Logger,
+) ifrit.Runner {
+	logger = logger.Session("http-health-check-step", lager.Data{"url": url})
+
+	return &httpHealthCheckStep{
+		url:            url,
+		expectedStatus: expectedStatus,
+		bodyContains:   bodyContains,
+		clock:          clock,
+		logger:         logger,
+		client: &http.Client{
+			Timeout: timeout,
+		},
+	}
+}
+
+func (step *httpHealthCheckStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	request, err := http.NewRequest("GET", step.url, nil)
+	if err != nil {
+		step.logger.Error("failed-to-create-request", err)
+		return NewEmittableError(ErrHealthCheckFailed, "Failed to create health check request for %s", step.url)
+	}
+
+	cancel := make(chan struct{})
+	request.Cancel = cancel
+
+	close(ready)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- step.perform(request)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-signals:
+		close(cancel)
+		<-errCh
+		return ErrCancelled
+	}
+}
+
+func (step *httpHealthCheckStep) perform(request *http.Request) error {
+	startTime := step.clock.Now()
+
+	resp, err := step.client.Do(request)
+	if err != nil {
+		step.logger.Info("request-failed", lager.Data{"error": err.Error()})
+		return NewEmittableError(ErrHealthCheckFailed, "Failed to make HTTP request to '%s': %s", step.url, err.Error())
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != step.expectedStatus {
+		step.logger.Info("unexpected-status-code", lager.Data{"status-code": resp.StatusCode})
+		return NewEmittableError(ErrHealthCheckFailed, "HTTP health check to '%s' received status code %d, expected %d", step.url, resp.StatusCode, step.expectedStatus)
+	}
+
+	if step.bodyContains != "" {
+		body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxHealthCheckBodySize))
+		if err != nil {
+			step.logger.Info("failed-to-read-body", lager.Data{"error": err.Error()})
+			return NewEmittableError(ErrHealthCheckFailed, "Failed to read HTTP health check response from '%s': %s", step.url, err.Error())
+		}
+
+		if !bytes.Contains(body, []byte(step.bodyContains)) {
+			step.logger.Info("unexpected-body")
+			return NewEmittableError(ErrHealthCheckFailed, "HTTP health check response from '%s' did not contain '%s'", step.url, step.bodyContains)
+		}
+	}
+
+	step.logger.Debug("succeeded", lager.Data{"duration": step.clock.Since(startTime)})
+	return nil
+}
@@ -0,0 +1,127 @@
+package steps_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("HTTPHealthCheckStep", func() {
+	var (
+		server       *httptest.Server
+		statusCode   int
+		body         string
+		blockCh      chan struct{}
+		bodyContains string
+		timeout      time.Duration
+		logger       *lagertest.TestLogger
+		fakeClock    *fakeclock.FakeClock
+	)
+
+	BeforeEach(func() {
+		statusCode = http.StatusOK
+		body = `{"status":"ready"}`
+		bodyContains = ""
+		timeout = time.Second
+		blockCh = nil
+		logger = lagertest.NewTestLogger("test")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+	})
+
+	JustBeforeEach(func() {
+		block := blockCh
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if block != nil {
+				<-block
+			}
+			w.WriteHeader(statusCode)
+			w.Write([]byte(body))
+		}))
+	})
+
+	AfterEach(func() {
+		if blockCh != nil {
+			close(blockCh)
+		}
+		server.Close()
+	})
+
+	runStep := func() error {
+		step := steps.NewHTTPHealthCheck(server.URL, http.StatusOK, bodyContains, timeout, fakeClock, logger)
+		return step.Run(nil, make(chan struct{}))
+	}
+
+	It("succeeds when the server responds with the expected status", func() {
+		Expect(runStep()).To(Succeed())
+	})
+
+	Context("when the server responds with an unexpected status", func() {
+		BeforeEach(func() {
+			statusCode = http.StatusServiceUnavailable
+		})
+
+		It("returns ErrHealthCheckFailed", func() {
+			err := runStep()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrHealthCheckFailed))
+		})
+	})
+
+	Context("when the body must contain a string", func() {
+		BeforeEach(func() {
+			bodyContains = `"status":"ready"`
+		})
+
+		It("succeeds when the body contains it", func() {
+			Expect(runStep()).To(Succeed())
+		})
+
+		Context("and the body reports a degraded state", func() {
+			BeforeEach(func() {
+				body = `{"status":"degraded"}`
+			})
+
+			It("returns ErrHealthCheckFailed", func() {
+				err := runStep()
+				Expect(err).To(HaveOccurred())
+				Expect(err.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrHealthCheckFailed))
+			})
+		})
+	})
+
+	Context("when the request times out", func() {
+		BeforeEach(func() {
+			blockCh = make(chan struct{})
+			timeout = 10 * time.Millisecond
+		})
+
+		It("returns ErrHealthCheckFailed", func() {
+			err := runStep()
+			Expect(err).To(HaveOccurred())
+			Expect(err.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrHealthCheckFailed))
+		})
+	})
+
+	Context("when signalled", func() {
+		BeforeEach(func() {
+			blockCh = make(chan struct{})
+		})
+
+		It("cancels the request and returns ErrCancelled", func() {
+			step := steps.NewHTTPHealthCheck(server.URL, http.StatusOK, bodyContains, timeout, fakeClock, logger)
+			process := ifrit.Background(step)
+			Eventually(process.Ready()).Should(BeClosed())
+
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(Equal(steps.ErrCancelled)))
+		})
+	})
+})
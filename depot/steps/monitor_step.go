@@ -6,7 +6,6 @@ import (
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/lager"
-	"code.cloudfoundry.org/workpool"
 	"github.com/tedsuo/ifrit"
 )
 
@@ -18,15 +17,21 @@ func NewMonitor(
 	startTimeout time.Duration,
 	healthyInterval time.Duration,
 	unhealthyInterval time.Duration,
-	workPool *workpool.WorkPool,
+	readinessWorkPool WorkPool,
+	livenessWorkPool WorkPool,
+	reporter HealthCheckReporter,
 	proxyReadinessChecks ...ifrit.Runner,
 ) ifrit.Runner {
-	throttledCheckFunc := func() ifrit.Runner {
-		return NewThrottle(checkFunc(), workPool)
+	throttledCheckFunc := func(workPool WorkPool) func() ifrit.Runner {
+		return func() ifrit.Runner {
+			// reported outside the throttle, so that recording the result
+			// does not hold a slot of the pool
+			return NewReportedCheck(NewThrottle(checkFunc(), workPool), reporter)
+		}
 	}
 
-	readiness := NewEventuallySucceedsStep(throttledCheckFunc, unhealthyInterval, startTimeout, clock)
-	liveness := NewConsistentlySucceedsStep(throttledCheckFunc, healthyInterval, clock)
+	readiness := NewEventuallySucceedsStep(throttledCheckFunc(readinessWorkPool), unhealthyInterval, startTimeout, clock)
+	liveness := NewConsistentlySucceedsStep(throttledCheckFunc(livenessWorkPool), healthyInterval, clock)
 
 	// add the proxy readiness checks (if any)
 	readiness = NewParallel(append(proxyReadinessChecks, readiness))
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/clock/fakeclock"
@@ -34,6 +35,8 @@ var _ = Describe("MonitorStep", func() {
 		healthyInterval   time.Duration
 		unhealthyInterval time.Duration
 
+		reporter *fakeHealthCheckReporter
+
 		step   ifrit.Runner
 		logger *lagertest.TestLogger
 	)
@@ -67,6 +70,8 @@ var _ = Describe("MonitorStep", func() {
 			return <-checkSteps
 		}
 
+		reporter = &fakeHealthCheckReporter{}
+
 		logger = lagertest.NewTestLogger("test")
 	})
 
@@ -85,6 +90,8 @@ var _ = Describe("MonitorStep", func() {
 			healthyInterval,
 			unhealthyInterval,
 			workPool,
+			workPool,
+			reporter,
 		)
 	})
 
@@ -195,6 +202,10 @@ var _ = Describe("MonitorStep", func() {
 							}))
 						})
 
+						It("reports the result of every check", func() {
+							Eventually(reporter.Results).Should(Equal([]error{nil, disaster}))
+						})
+
 						It("emits a log message for the success", func() {
 							Eventually(fakeStreamer.Stdout().(*gbytes.Buffer)).Should(
 								gbytes.Say("Container became unhealthy\n"),
@@ -323,3 +334,20 @@ var _ = Describe("MonitorStep", func() {
 		})
 	})
 })
+
+type fakeHealthCheckReporter struct {
+	lock    sync.Mutex
+	results []error
+}
+
+func (r *fakeHealthCheckReporter) HealthCheckResult(err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.results = append(r.results, err)
+}
+
+func (r *fakeHealthCheckReporter) Results() []error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return append([]error{}, r.results...)
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"os"
 
+	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/steps"
 
 	. "github.com/onsi/ginkgo"
@@ -47,16 +48,18 @@ var _ = Describe("OutputWrapperStep", func() {
 
 		Context("when the substep fails", func() {
 			var (
-				errStr string
+				errStr     string
+				subStepErr error
 			)
 
 			BeforeEach(func() {
-				go subStep.TriggerExit(errors.New("BOOOM!"))
+				subStepErr = errors.New("BOOOM!")
 				errStr = "error reason"
 			})
 
 			JustBeforeEach(func() {
 				buffer.WriteString(errStr)
+				go subStep.TriggerExit(subStepErr)
 			})
 
 			It("wraps the buffer content in an emittable error", func() {
@@ -64,6 +67,20 @@ var _ = Describe("OutputWrapperStep", func() {
 				Eventually(p.Wait()).Should(Receive(MatchError("error reason")))
 			})
 
+			Context("when the substep error records an exit reason", func() {
+				BeforeEach(func() {
+					subStepErr = steps.NewEmittableError(nil, "BOOOM!").WithExitReason(executor.ExitReasonOOM)
+				})
+
+				It("keeps the exit reason on the emittable error", func() {
+					p := ifrit.Background(step)
+					var err error
+					Eventually(p.Wait()).Should(Receive(&err))
+					Expect(err).To(MatchError("error reason"))
+					Expect(steps.ExitReasonFor(err)).To(Equal(executor.ExitReasonOOM))
+				})
+			})
+
 			Context("when the output has whitespaces", func() {
 				BeforeEach(func() {
 					errStr = "\r\nerror reason\r\n"
@@ -0,0 +1,151 @@
+package steps
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+var ErrDisallowedProcess = errors.New("disallowed process running in container")
+var ErrProcessListFailed = errors.New("failed to list the processes running in the container")
+
+// processListScript prints the pid and executable of every process in the
+// container, one per line. The executable is resolved through /proc/<pid>/exe
+// rather than taken from argv[0], which a process can set to anything. The
+// shell running the script is left out, processes that exit while the list
+// is taken are skipped, and processes whose executable cannot be read are
+// listed with an empty path.
+const processListScript = `for dir in /proc/[0-9]*; do
+  pid=${dir#/proc/}
+  [ "$pid" = "$$" ] && continue
+  exe=$(readlink "$dir/exe") || { [ -d "$dir" ] || continue; exe=""; }
+  echo "$pid $exe"
+done`
+
+type processWhitelistEnforcer struct {
+	container    garden.Container
+	allowedPaths map[string]struct{}
+	interval     time.Duration
+	clock        clock.Clock
+	logger       lager.Logger
+}
+
+// NewProcessWhitelistEnforcer returns a step that lists the processes of the
+// container every interval, and stops the container as soon as one of them
+// runs an executable that is not in allowedPaths. The step then fails with
+// an emittable error naming the process. It fails closed: if the processes
+// cannot be listed, the container is stopped as well and the step fails with
+// ErrProcessListFailed.
+//
+// The processes are listed as uid 0 with /bin/sh and readlink from the
+// container's rootfs.
+func NewProcessWhitelistEnforcer(
+	container garden.Container,
+	allowedPaths []string,
+	interval time.Duration,
+	clock clock.Clock,
+	logger lager.Logger,
+) ifrit.Runner {
+	allowed := make(map[string]struct{}, len(allowedPaths))
+	for _, path := range allowedPaths {
+		allowed[path] = struct{}{}
+	}
+
+	return &processWhitelistEnforcer{
+		container:    container,
+		allowedPaths: allowed,
+		interval:     interval,
+		clock:        clock,
+		logger:       logger.Session("process-whitelist-enforcer"),
+	}
+}
+
+func (step *processWhitelistEnforcer) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	ticker := step.clock.NewTicker(step.interval)
+	defer ticker.Stop()
+
+	close(ready)
+
+	for {
+		select {
+		case <-signals:
+			step.logger.Info("cancelled")
+			return ErrCancelled
+
+		case <-ticker.C():
+			process, err := step.disallowedProcess()
+			if err != nil {
+				step.logger.Error("failed-to-list-processes", err)
+				return step.stopContainer(NewEmittableError(ErrProcessListFailed, "Failed to list the processes running in the container"))
+			}
+			if process == "" {
+				continue
+			}
+
+			step.logger.Error("found-disallowed-process", ErrDisallowedProcess, lager.Data{"process": process})
+			return step.stopContainer(NewEmittableError(ErrDisallowedProcess, fmt.Sprintf("Disallowed process %s was running in the container", process)))
+		}
+	}
+}
+
+func (step *processWhitelistEnforcer) stopContainer(stepErr error) error {
+	err := step.container.Stop(true)
+	if err != nil {
+		step.logger.Error("failed-to-stop-container", err)
+	}
+	return stepErr
+}
+
+// disallowedProcess returns the executable of the first process that is not
+// whitelisted, or an empty string if there is none.
+func (step *processWhitelistEnforcer) disallowedProcess() (string, error) {
+	stdout := &bytes.Buffer{}
+	process, err := step.container.Run(garden.ProcessSpec{
+		Path: "/bin/sh",
+		Args: []string{"-c", processListScript},
+		// a uid rather than a name, which needs a passwd entry in the rootfs
+		User: "0",
+	}, garden.ProcessIO{
+		Stdout: stdout,
+		Stderr: ioutil.Discard,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	exitCode, err := process.Wait()
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("process list exited with status %d", exitCode)
+	}
+
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) < 2 || fields[1] == "" {
+			return fmt.Sprintf("pid %s (unreadable executable)", fields[0]), nil
+		}
+
+		if _, ok := step.allowedPaths[fields[1]]; !ok {
+			return fields[1], nil
+		}
+	}
+	return "", scanner.Err()
+}
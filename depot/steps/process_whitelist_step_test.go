@@ -0,0 +1,159 @@
+package steps_test
+
+import (
+	"errors"
+	"io"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("ProcessWhitelistEnforcer", func() {
+	const interval = 10 * time.Second
+
+	var (
+		container *gardenfakes.FakeContainer
+		psProcess *gardenfakes.FakeProcess
+		psOutput  string
+		clock     *fakeclock.FakeClock
+		logger    *lagertest.TestLogger
+		process   ifrit.Process
+	)
+
+	BeforeEach(func() {
+		container = &gardenfakes.FakeContainer{}
+		psProcess = &gardenfakes.FakeProcess{}
+		clock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
+
+		psOutput = "1 /tmp/garden-init\n7 /home/vcap/app/server\n"
+		container.RunStub = func(_ garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+			io.WriteString(processIO.Stdout, psOutput)
+			return psProcess, nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		step := steps.NewProcessWhitelistEnforcer(
+			container,
+			[]string{"/tmp/garden-init", "/home/vcap/app/server"},
+			interval,
+			clock,
+			logger,
+		)
+		process = ifrit.Background(step)
+		Eventually(process.Ready()).Should(BeClosed())
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("lists the processes of the container every interval", func() {
+		Consistently(container.RunCallCount).Should(Equal(0))
+
+		clock.WaitForWatcherAndIncrement(interval)
+		Eventually(container.RunCallCount).Should(Equal(1))
+		spec, _ := container.RunArgsForCall(0)
+		Expect(spec.Path).To(Equal("/bin/sh"))
+		Expect(spec.Args).To(HaveLen(2))
+		Expect(spec.Args[0]).To(Equal("-c"))
+		Expect(spec.Args[1]).To(ContainSubstring("/proc/"))
+		Expect(spec.User).To(Equal("0"))
+
+		clock.WaitForWatcherAndIncrement(interval)
+		Eventually(container.RunCallCount).Should(Equal(2))
+	})
+
+	It("leaves the container running while only whitelisted processes run", func() {
+		clock.WaitForWatcherAndIncrement(interval)
+		Eventually(container.RunCallCount).Should(Equal(1))
+
+		Consistently(process.Wait()).ShouldNot(Receive())
+		Expect(container.StopCallCount()).To(Equal(0))
+	})
+
+	Context("when a process that is not whitelisted is running", func() {
+		BeforeEach(func() {
+			psOutput = "1 /tmp/garden-init\n12 /usr/bin/nc\n"
+		})
+
+		It("stops the container and fails with an emittable error naming the process", func() {
+			clock.WaitForWatcherAndIncrement(interval)
+
+			var err error
+			Eventually(process.Wait()).Should(Receive(&err))
+			Expect(err).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+			Expect(err.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrDisallowedProcess))
+			Expect(err.Error()).To(ContainSubstring("/usr/bin/nc"))
+
+			Expect(container.StopCallCount()).To(Equal(1))
+			Expect(container.StopArgsForCall(0)).To(BeTrue())
+		})
+	})
+
+	Context("when the executable of a process cannot be read", func() {
+		BeforeEach(func() {
+			psOutput = "1 /tmp/garden-init\n13 \n"
+		})
+
+		It("stops the container", func() {
+			clock.WaitForWatcherAndIncrement(interval)
+
+			var err error
+			Eventually(process.Wait()).Should(Receive(&err))
+			Expect(err.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrDisallowedProcess))
+			Expect(err.Error()).To(ContainSubstring("pid 13"))
+			Expect(container.StopCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when the processes cannot be listed", func() {
+		BeforeEach(func() {
+			container.RunStub = nil
+			container.RunReturns(nil, errors.New("no shell"))
+		})
+
+		It("stops the container and fails", func() {
+			clock.WaitForWatcherAndIncrement(interval)
+
+			var err error
+			Eventually(process.Wait()).Should(Receive(&err))
+			Expect(err).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+			Expect(err.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrProcessListFailed))
+			Expect(container.StopCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when listing the processes exits with an error", func() {
+		BeforeEach(func() {
+			psProcess.WaitReturns(1, nil)
+		})
+
+		It("stops the container and fails", func() {
+			clock.WaitForWatcherAndIncrement(interval)
+
+			var err error
+			Eventually(process.Wait()).Should(Receive(&err))
+			Expect(err.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrProcessListFailed))
+			Expect(container.StopCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when signalled", func() {
+		It("stops with ErrCancelled", func() {
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(Equal(steps.ErrCancelled)))
+		})
+	})
+})
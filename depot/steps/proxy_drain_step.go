@@ -0,0 +1,195 @@
+package steps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+const ProxyDrainPollInterval = 500 * time.Millisecond
+
+// ProxyAdmin controls a running proxy so that it can be drained before the
+// processes behind it are stopped.
+type ProxyAdmin interface {
+	// StartDrain tells the proxy to stop accepting new connections.
+	StartDrain() error
+	// ActiveConnections returns the number of open downstream connections.
+	ActiveConnections() (int, error)
+}
+
+type envoyAdmin struct {
+	container garden.Container
+	sidecar   Sidecar
+	baseURL   string
+	timeout   time.Duration
+}
+
+// NewEnvoyAdmin returns a ProxyAdmin for the envoy admin listener at baseURL.
+// The admin listener is only bound to loopback inside the container, so the
+// requests are made by running curl in the container, in the same sidecar as
+// the proxy.
+func NewEnvoyAdmin(container garden.Container, sidecar Sidecar, baseURL string, timeout time.Duration) ProxyAdmin {
+	return &envoyAdmin{
+		container: container,
+		sidecar:   sidecar,
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		timeout:   timeout,
+	}
+}
+
+func (a *envoyAdmin) StartDrain() error {
+	_, err := a.curl("--request", "POST", a.baseURL+"/healthcheck/fail")
+	if err != nil {
+		return fmt.Errorf("failed to start draining: %s", err)
+	}
+	return nil
+}
+
+type envoyStats struct {
+	Stats []struct {
+		Name  string `json:"name"`
+		Value int    `json:"value"`
+	} `json:"stats"`
+}
+
+func (a *envoyAdmin) ActiveConnections() (int, error) {
+	body, err := a.curl(a.baseURL + "/stats?format=json&filter=downstream_cx_active")
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch stats: %s", err)
+	}
+
+	var stats envoyStats
+	err = json.Unmarshal(body, &stats)
+	if err != nil {
+		return 0, err
+	}
+
+	active := 0
+	for _, stat := range stats.Stats {
+		active += stat.Value
+	}
+	return active, nil
+}
+
+// curl runs curl with args in the container and returns its output. Non-2xx
+// responses make curl exit with a non-zero status.
+func (a *envoyAdmin) curl(args ...string) ([]byte, error) {
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	process, err := a.container.Run(garden.ProcessSpec{
+		Path:       "curl",
+		Args:       append([]string{"--silent", "--show-error", "--fail", "--max-time", strconv.FormatFloat(a.timeout.Seconds(), 'f', -1, 64)}, args...),
+		Image:      a.sidecar.Image,
+		BindMounts: a.sidecar.BindMounts,
+	}, garden.ProcessIO{
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exitCode, err := process.Wait()
+	if err != nil {
+		return nil, err
+	}
+	if exitCode != 0 {
+		return nil, fmt.Errorf("curl exited with status %d: %s", exitCode, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
+
+type proxyDrainStep struct {
+	substep      ifrit.Runner
+	admin        ProxyAdmin
+	drainTimeout time.Duration
+	clock        clock.Clock
+	logger       lager.Logger
+}
+
+// NewProxyDrain returns a step that runs substep and, when signalled, drains
+// the proxy before passing the signal on. Draining ends when the proxy
+// reports no active connections or after drainTimeout, whichever is first.
+// Failing to talk to the proxy does not hold up the stop.
+func NewProxyDrain(substep ifrit.Runner, admin ProxyAdmin, drainTimeout time.Duration, clock clock.Clock, logger lager.Logger) ifrit.Runner {
+	return &proxyDrainStep{
+		substep:      substep,
+		admin:        admin,
+		drainTimeout: drainTimeout,
+		clock:        clock,
+		logger:       logger.Session("proxy-drain-step"),
+	}
+}
+
+func (step *proxyDrainStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	subStepSignals := make(chan os.Signal, 1)
+	resultCh := make(chan error, 1)
+
+	go func() {
+		resultCh <- step.substep.Run(subStepSignals, ready)
+	}()
+
+	select {
+	case err := <-resultCh:
+		return err
+	case s := <-signals:
+		step.drain()
+		subStepSignals <- s
+	}
+
+	for {
+		select {
+		case s := <-signals:
+			subStepSignals <- s
+		case err := <-resultCh:
+			return err
+		}
+	}
+}
+
+func (step *proxyDrainStep) drain() {
+	logger := step.logger.Session("drain", lager.Data{"timeout": step.drainTimeout.String()})
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	err := step.admin.StartDrain()
+	if err != nil {
+		logger.Error("failed-to-start-draining", err)
+		return
+	}
+
+	timer := step.clock.NewTimer(step.drainTimeout)
+	defer timer.Stop()
+
+	ticker := step.clock.NewTicker(ProxyDrainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		active, err := step.admin.ActiveConnections()
+		if err != nil {
+			logger.Error("failed-to-get-active-connections", err)
+			return
+		}
+		if active == 0 {
+			logger.Info("drained")
+			return
+		}
+
+		select {
+		case <-ticker.C():
+		case <-timer.C():
+			logger.Info("timed-out", lager.Data{"active-connections": active})
+			return
+		}
+	}
+}
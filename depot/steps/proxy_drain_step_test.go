@@ -0,0 +1,154 @@
+package steps_test
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/fake_runner"
+)
+
+var _ = Describe("ProxyDrainStep", func() {
+	var (
+		substep   *fake_runner.TestRunner
+		clock     *fakeclock.FakeClock
+		logger    *lagertest.TestLogger
+		container *gardenfakes.FakeContainer
+		sidecar   steps.Sidecar
+
+		lock              sync.Mutex
+		drainRequests     int
+		activeConnections []int
+		drainExitCode     int
+
+		process ifrit.Process
+	)
+
+	getDrainRequests := func() int {
+		lock.Lock()
+		defer lock.Unlock()
+		return drainRequests
+	}
+
+	substepSignals := func() <-chan os.Signal {
+		signals, _ := substep.RunArgsForCall(0)
+		return signals
+	}
+
+	BeforeEach(func() {
+		substep = fake_runner.NewTestRunner()
+		clock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
+		drainRequests = 0
+		activeConnections = []int{2, 1, 0}
+		drainExitCode = 0
+
+		sidecar = steps.Sidecar{
+			Image:      garden.ImageRef{URI: "sidecar-rootfs"},
+			BindMounts: []garden.BindMount{{SrcPath: "/src", DstPath: "/dst"}},
+		}
+
+		container = &gardenfakes.FakeContainer{}
+		container.RunStub = func(spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+			lock.Lock()
+			defer lock.Unlock()
+
+			Expect(spec.Path).To(Equal("curl"))
+			Expect(spec.Image).To(Equal(sidecar.Image))
+			Expect(spec.BindMounts).To(Equal(sidecar.BindMounts))
+
+			curlProcess := &gardenfakes.FakeProcess{}
+			url := spec.Args[len(spec.Args)-1]
+			switch url {
+			case "http://127.0.0.1:61002/healthcheck/fail":
+				Expect(spec.Args).To(ContainElement("POST"))
+				drainRequests++
+				curlProcess.WaitReturns(drainExitCode, nil)
+			case "http://127.0.0.1:61002/stats?format=json&filter=downstream_cx_active":
+				active := activeConnections[0]
+				if len(activeConnections) > 1 {
+					activeConnections = activeConnections[1:]
+				}
+				fmt.Fprintf(processIO.Stdout, `{"stats":[{"name":"listener.0.0.0.0_61001.downstream_cx_active","value":%d}]}`, active)
+			default:
+				Fail("unexpected request: " + url)
+			}
+			return curlProcess, nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		admin := steps.NewEnvoyAdmin(container, sidecar, "http://127.0.0.1:61002", time.Second)
+		process = ifrit.Background(steps.NewProxyDrain(substep, admin, 10*time.Second, clock, logger))
+		Eventually(substep.RunCallCount).Should(Equal(1))
+	})
+
+	AfterEach(func() {
+		substep.EnsureExit()
+	})
+
+	It("becomes ready when the substep is ready", func() {
+		Consistently(process.Ready()).ShouldNot(BeClosed())
+		substep.TriggerReady()
+		Eventually(process.Ready()).Should(BeClosed())
+	})
+
+	It("returns the substep's result when it exits on its own", func() {
+		substep.TriggerExit(nil)
+		Eventually(process.Wait()).Should(Receive(BeNil()))
+		Expect(getDrainRequests()).To(BeZero())
+	})
+
+	Context("when signalled", func() {
+		JustBeforeEach(func() {
+			process.Signal(os.Interrupt)
+		})
+
+		It("drains the proxy until it has no active connections before signalling the substep", func() {
+			Eventually(getDrainRequests).Should(Equal(1))
+			Consistently(substepSignals()).ShouldNot(Receive())
+
+			clock.WaitForNWatchersAndIncrement(steps.ProxyDrainPollInterval, 2)
+			Consistently(substepSignals()).ShouldNot(Receive())
+
+			clock.WaitForNWatchersAndIncrement(steps.ProxyDrainPollInterval, 2)
+			Eventually(substepSignals()).Should(Receive(Equal(os.Interrupt)))
+
+			substep.TriggerExit(nil)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+
+		Context("when connections do not drain in time", func() {
+			BeforeEach(func() {
+				activeConnections = []int{5}
+			})
+
+			It("signals the substep after the drain timeout", func() {
+				Eventually(getDrainRequests).Should(Equal(1))
+				clock.WaitForNWatchersAndIncrement(10*time.Second, 2)
+
+				Eventually(substepSignals()).Should(Receive(Equal(os.Interrupt)))
+			})
+		})
+
+		Context("when the proxy refuses to drain", func() {
+			BeforeEach(func() {
+				drainExitCode = 22
+			})
+
+			It("signals the substep immediately", func() {
+				Eventually(substepSignals()).Should(Receive(Equal(os.Interrupt)))
+			})
+		})
+	})
+})
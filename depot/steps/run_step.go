@@ -27,11 +27,26 @@ type runStep struct {
 	logger                   lager.Logger
 	externalIP               string
 	internalIP               string
+	hostname                 string
 	portMappings             []executor.PortMapping
 	clock                    clock.Clock
 	gracefulShutdownInterval time.Duration
 	suppressExitStatusCode   bool
 	sidecar                  Sidecar
+	exportedEnv              []string
+}
+
+// WrapProcess returns a copy of model that runs wrapperPath instead, passing
+// the original path and arguments after a "--" separator. Everything else
+// about the process is left alone.
+func WrapProcess(model models.RunAction, wrapperPath string) models.RunAction {
+	args := make([]string, 0, len(model.Args)+2)
+	args = append(args, "--", model.Path)
+	args = append(args, model.Args...)
+
+	model.Path = wrapperPath
+	model.Args = args
+	return model
 }
 
 type Sidecar struct {
@@ -48,18 +63,21 @@ func NewRun(
 	logger lager.Logger,
 	externalIP string,
 	internalIP string,
+	hostname string,
 	portMappings []executor.PortMapping,
 	clock clock.Clock,
 	gracefulShutdownInterval time.Duration,
 	suppressExitStatusCode bool,
+	exportedEnv []string,
 ) *runStep {
-	return NewRunWithSidecar(
+	step := NewRunWithSidecar(
 		container,
 		model,
 		streamer,
 		logger,
 		externalIP,
 		internalIP,
+		hostname,
 		portMappings,
 		clock,
 		gracefulShutdownInterval,
@@ -67,6 +85,8 @@ func NewRun(
 		Sidecar{},
 		false,
 	)
+	step.exportedEnv = exportedEnv
+	return step
 }
 
 func NewRunWithSidecar(
@@ -76,6 +96,7 @@ func NewRunWithSidecar(
 	logger lager.Logger,
 	externalIP string,
 	internalIP string,
+	hostname string,
 	portMappings []executor.PortMapping,
 	clock clock.Clock,
 	gracefulShutdownInterval time.Duration,
@@ -91,6 +112,7 @@ func NewRunWithSidecar(
 		logger:                   logger,
 		externalIP:               externalIP,
 		internalIP:               internalIP,
+		hostname:                 hostname,
 		portMappings:             portMappings,
 		clock:                    clock,
 		gracefulShutdownInterval: gracefulShutdownInterval,
@@ -102,9 +124,11 @@ func NewRunWithSidecar(
 func (step *runStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	step.logger.Info("running")
 
-	envVars := convertEnvironmentVariables(step.model.Env)
-
-	envVars = append(envVars, step.networkingEnvVars()...)
+	envVars := MergeEnvironment(
+		convertEnvironmentVariables(step.model.Env),
+		step.networkingEnvVars(),
+		step.exportedEnv,
+	)
 
 	select {
 	case <-signals:
@@ -214,6 +238,7 @@ func (step *runStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error
 				exitErrorMessage = fmt.Sprintf("%s (exceeded %s graceful shutdown interval)", exitErrorMessage, step.gracefulShutdownInterval)
 			}
 
+			exitReason := executor.ExitReasonCrash
 			if exitStatus != 0 {
 				info, err := step.container.Info()
 				if err != nil {
@@ -223,6 +248,7 @@ func (step *runStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error
 						if ev == "out of memory" || ev == "Out of memory" {
 							exitErrorMessage = fmt.Sprintf("%s (out of memory)", exitErrorMessage)
 							emittableExitErrorMessage = fmt.Sprintf("%s (out of memory)", emittableExitErrorMessage)
+							exitReason = executor.ExitReasonOOM
 							break
 						}
 					}
@@ -240,7 +266,7 @@ func (step *runStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error
 
 			if exitStatus != 0 {
 				logger.Error("run-step-failed-with-nonzero-status-code", errors.New(exitErrorMessage), lager.Data{"status-code": exitStatus})
-				return NewEmittableError(nil, emittableExitErrorMessage)
+				return NewEmittableError(nil, emittableExitErrorMessage).WithExitReason(exitReason)
 			}
 
 			return nil
@@ -307,6 +333,10 @@ func (step *runStep) networkingEnvVars() []string {
 	envVars = append(envVars, "CF_INSTANCE_IP="+step.externalIP)
 	envVars = append(envVars, "CF_INSTANCE_INTERNAL_IP="+step.internalIP)
 
+	if step.hostname != "" {
+		envVars = append(envVars, "CF_INSTANCE_HOSTNAME="+step.hostname)
+	}
+
 	if len(step.portMappings) > 0 {
 		if step.portMappings[0].HostPort > 0 {
 			envVars = append(envVars, fmt.Sprintf("CF_INSTANCE_PORT=%d", step.portMappings[0].HostPort))
@@ -32,7 +32,7 @@ var _ = Describe("RunAction", func() {
 		gardenClient                        *fakes.FakeGardenClient
 		logger                              *lagertest.TestLogger
 		fileDescriptorLimit, processesLimit uint64
-		externalIP, internalIP              string
+		externalIP, internalIP, hostname    string
 		portMappings                        []executor.PortMapping
 		fakeClock                           *fakeclock.FakeClock
 		suppressExitStatusCode              bool
@@ -85,6 +85,7 @@ var _ = Describe("RunAction", func() {
 
 		externalIP = "external-ip"
 		internalIP = "internal-ip"
+		hostname = "0a1b2c3d"
 		portMappings = nil
 		fakeClock = fakeclock.NewFakeClock(time.Unix(123, 456))
 	})
@@ -104,6 +105,7 @@ var _ = Describe("RunAction", func() {
 			logger,
 			externalIP,
 			internalIP,
+			hostname,
 			portMappings,
 			fakeClock,
 			gracefulShutdownInterval,
@@ -286,6 +288,34 @@ var _ = Describe("RunAction", func() {
 				Expect(spec.Env).To(ContainElement("CF_INSTANCE_IP=external-ip"))
 			})
 
+			It("sets CF_INSTANCE_HOSTNAME on the container", func() {
+				_, spec, _ := gardenClient.Connection.RunArgsForCall(0)
+				Expect(spec.Env).To(ContainElement("CF_INSTANCE_HOSTNAME=0a1b2c3d"))
+			})
+
+			Context("when there is no hostname", func() {
+				BeforeEach(func() {
+					hostname = ""
+				})
+
+				It("does not set CF_INSTANCE_HOSTNAME", func() {
+					_, spec, _ := gardenClient.Connection.RunArgsForCall(0)
+					Expect(spec.Env).NotTo(ContainElement(HavePrefix("CF_INSTANCE_HOSTNAME=")))
+				})
+			})
+
+			Context("when the action overrides a networking env var", func() {
+				BeforeEach(func() {
+					runAction.Env = append(runAction.Env, &models.EnvironmentVariable{Name: "CF_INSTANCE_IP", Value: "overridden-ip"})
+				})
+
+				It("only sets the action's value", func() {
+					_, spec, _ := gardenClient.Connection.RunArgsForCall(0)
+					Expect(spec.Env).To(ContainElement("CF_INSTANCE_IP=overridden-ip"))
+					Expect(spec.Env).NotTo(ContainElement("CF_INSTANCE_IP=external-ip"))
+				})
+			})
+
 			It("sets CF_INSTANCE_INTERNAL_IP on the container", func() {
 				_, spec, _ := gardenClient.Connection.RunArgsForCall(0)
 				Expect(spec.Env).To(ContainElement("CF_INSTANCE_INTERNAL_IP=internal-ip"))
@@ -437,7 +467,7 @@ var _ = Describe("RunAction", func() {
 
 				It("should return an emittable error with the exit code", func() {
 					errMsg := fmt.Sprintf("%s: Exited with status 19", testLogSource)
-					Eventually(process.Wait()).Should(Receive(MatchError(steps.NewEmittableError(nil, errMsg))))
+					Eventually(process.Wait()).Should(Receive(MatchError(steps.NewEmittableError(nil, errMsg).WithExitReason(executor.ExitReasonCrash))))
 				})
 			})
 
@@ -448,7 +478,7 @@ var _ = Describe("RunAction", func() {
 
 				It("should return an emittable error with the exit code", func() {
 					errMsg := fmt.Sprintf("%s: Exited with status 19", testLogSource)
-					Eventually(process.Wait()).Should(Receive(MatchError(steps.NewEmittableError(nil, errMsg))))
+					Eventually(process.Wait()).Should(Receive(MatchError(steps.NewEmittableError(nil, errMsg).WithExitReason(executor.ExitReasonCrash))))
 				})
 			})
 		})
@@ -522,7 +552,7 @@ var _ = Describe("RunAction", func() {
 
 			It("returns an emittable error", func() {
 				errMsg := fmt.Sprintf("%s: Exited with status 19 (out of memory)", testLogSource)
-				Eventually(process.Wait()).Should(Receive(MatchError(steps.NewEmittableError(nil, errMsg))))
+				Eventually(process.Wait()).Should(Receive(MatchError(steps.NewEmittableError(nil, errMsg).WithExitReason(executor.ExitReasonOOM))))
 			})
 		})
 
@@ -540,7 +570,7 @@ var _ = Describe("RunAction", func() {
 
 			It("returns an emittable error", func() {
 				errMsg := fmt.Sprintf("%s: Exited with status 19 (out of memory)", testLogSource)
-				Eventually(process.Wait()).Should(Receive(MatchError(steps.NewEmittableError(nil, errMsg))))
+				Eventually(process.Wait()).Should(Receive(MatchError(steps.NewEmittableError(nil, errMsg).WithExitReason(executor.ExitReasonOOM))))
 			})
 		})
 
@@ -816,6 +846,28 @@ var _ = Describe("RunAction", func() {
 	})
 })
 
+var _ = Describe("WrapProcess", func() {
+	It("runs the wrapper with the original command after a separator", func() {
+		model := models.RunAction{
+			Path: "/bin/app",
+			Args: []string{"-port", "8080"},
+			Dir:  "/home/vcap",
+			User: "vcap",
+			Env:  []*models.EnvironmentVariable{{Name: "FOO", Value: "bar"}},
+		}
+
+		wrapped := steps.WrapProcess(model, "/usr/bin/tini")
+		Expect(wrapped.Path).To(Equal("/usr/bin/tini"))
+		Expect(wrapped.Args).To(Equal([]string{"--", "/bin/app", "-port", "8080"}))
+		Expect(wrapped.Dir).To(Equal(model.Dir))
+		Expect(wrapped.User).To(Equal(model.User))
+		Expect(wrapped.Env).To(Equal(model.Env))
+
+		Expect(model.Path).To(Equal("/bin/app"))
+		Expect(model.Args).To(Equal([]string{"-port", "8080"}))
+	})
+})
+
 type noOpWriter struct{}
 
 func (w noOpWriter) Write(b []byte) (int, error) { return len(b), nil }
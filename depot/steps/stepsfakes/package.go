@@ -0,0 +1 @@
+package stepsfakes // import "code.cloudfoundry.org/executor/depot/steps/stepsfakes"
@@ -0,0 +1,278 @@
+package steps
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+const (
+	tcpLogSinkDialTimeout = 5 * time.Second
+	tcpLogSinkQueueSize   = 1024
+)
+
+type tcpLogSinkStep struct {
+	container         garden.Container
+	spec              garden.ProcessSpec
+	addr              string
+	reconnectInterval time.Duration
+	clock             clock.Clock
+	logger            lager.Logger
+
+	records chan []byte
+
+	connLock    sync.Mutex
+	conn        net.Conn
+	nextAttempt time.Time
+	closed      bool
+}
+
+type tcpLogRecord struct {
+	Time   string `json:"t"`
+	Source string `json:"src"`
+	Line   string `json:"line"`
+}
+
+// NewTCPLogSink returns a step that runs spec in the container and forwards
+// each line of its stdout and stderr to addr over TCP, as one JSON record per
+// line. A dropped connection is re-established on the first line written at
+// least reconnectInterval after it dropped; lines written while there is no
+// connection, or faster than they can be sent, are discarded so that the
+// process is never blocked on the sink.
+//
+// When signalled, the step closes the connection, terminates the process and
+// waits for it to exit before returning ErrCancelled.
+func NewTCPLogSink(
+	container garden.Container,
+	spec garden.ProcessSpec,
+	addr string,
+	reconnectInterval time.Duration,
+	clock clock.Clock,
+	logger lager.Logger,
+) ifrit.Runner {
+	return &tcpLogSinkStep{
+		container:         container,
+		spec:              spec,
+		addr:              addr,
+		reconnectInterval: reconnectInterval,
+		clock:             clock,
+		logger:            logger.Session("tcp-log-sink-step", lager.Data{"addr": addr}),
+		records:           make(chan []byte, tcpLogSinkQueueSize),
+	}
+}
+
+func (step *tcpLogSinkStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	forwarded := make(chan struct{})
+	go func() {
+		step.forward()
+		close(forwarded)
+	}()
+
+	stdout := &tcpLogSinkWriter{step: step, source: "stdout"}
+	stderr := &tcpLogSinkWriter{step: step, source: "stderr"}
+
+	process, err := step.container.Run(step.spec, garden.ProcessIO{
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+	if err != nil {
+		step.logger.Error("failed-to-run", err)
+		close(step.records)
+		<-forwarded
+		step.closeConn()
+		return err
+	}
+
+	close(ready)
+
+	exitStatusCh := make(chan int, 1)
+	errCh := make(chan error, 1)
+	go func() {
+		exitStatus, err := process.Wait()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		exitStatusCh <- exitStatus
+	}()
+
+	var cancelled bool
+	var exitStatus int
+	for done := false; !done; {
+		select {
+		case exitStatus = <-exitStatusCh:
+			done = true
+		case err = <-errCh:
+			done = true
+		case <-signals:
+			if cancelled {
+				continue
+			}
+			cancelled = true
+			step.logger.Info("cancelling")
+			step.closeConn()
+			if err := process.Signal(garden.SignalTerminate); err != nil {
+				step.logger.Error("failed-to-signal-process", err)
+			}
+		}
+	}
+
+	stdout.flush()
+	stderr.flush()
+	close(step.records)
+	<-forwarded
+	step.closeConn()
+
+	if cancelled {
+		return ErrCancelled
+	}
+	if err != nil {
+		step.logger.Error("failed-to-wait", err)
+		return err
+	}
+	if exitStatus != 0 {
+		step.logger.Info("process-exited", lager.Data{"status": exitStatus})
+		return fmt.Errorf("Exited with status %d", exitStatus)
+	}
+
+	return nil
+}
+
+// enqueue queues a line for forwarding, dropping it if the queue is full.
+func (step *tcpLogSinkStep) enqueue(source string, line []byte) {
+	record, err := json.Marshal(tcpLogRecord{
+		Time:   step.clock.Now().UTC().Format(time.RFC3339Nano),
+		Source: source,
+		Line:   string(line),
+	})
+	if err != nil {
+		step.logger.Error("failed-to-marshal-record", err)
+		return
+	}
+
+	select {
+	case step.records <- append(record, '\n'):
+	default:
+		step.logger.Debug("dropped-line", lager.Data{"src": source})
+	}
+}
+
+func (step *tcpLogSinkStep) forward() {
+	for record := range step.records {
+		conn := step.connection()
+		if conn == nil {
+			continue
+		}
+
+		_, err := conn.Write(record)
+		if err != nil {
+			step.logger.Error("failed-to-write", err)
+			step.dropConn(conn)
+		}
+	}
+}
+
+// connection returns the current connection, dialing a new one if there is
+// none and the reconnect interval has passed since the last failure.
+func (step *tcpLogSinkStep) connection() net.Conn {
+	step.connLock.Lock()
+	defer step.connLock.Unlock()
+
+	if step.closed {
+		return nil
+	}
+	if step.conn != nil {
+		return step.conn
+	}
+	if step.clock.Now().Before(step.nextAttempt) {
+		return nil
+	}
+
+	conn, err := net.DialTimeout("tcp", step.addr, tcpLogSinkDialTimeout)
+	if err != nil {
+		step.logger.Error("failed-to-connect", err)
+		step.nextAttempt = step.clock.Now().Add(step.reconnectInterval)
+		return nil
+	}
+
+	step.logger.Info("connected")
+	step.conn = conn
+	return conn
+}
+
+func (step *tcpLogSinkStep) dropConn(conn net.Conn) {
+	step.connLock.Lock()
+	defer step.connLock.Unlock()
+
+	conn.Close()
+	if step.conn == conn {
+		step.conn = nil
+		step.nextAttempt = step.clock.Now().Add(step.reconnectInterval)
+	}
+}
+
+// closeConn closes the connection for good; no new connection is dialed
+// afterwards.
+func (step *tcpLogSinkStep) closeConn() {
+	step.connLock.Lock()
+	defer step.connLock.Unlock()
+
+	step.closed = true
+	if step.conn != nil {
+		step.conn.Close()
+		step.conn = nil
+	}
+}
+
+// tcpLogSinkWriter splits the output of one stream of the process into lines.
+type tcpLogSinkWriter struct {
+	step   *tcpLogSinkStep
+	source string
+
+	lock    sync.Mutex
+	partial []byte
+	done    bool
+}
+
+func (w *tcpLogSinkWriter) Write(p []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.done {
+		return len(p), nil
+	}
+
+	w.partial = append(w.partial, p...)
+	for {
+		i := bytes.IndexByte(w.partial, '\n')
+		if i < 0 {
+			break
+		}
+		w.step.enqueue(w.source, bytes.TrimSuffix(w.partial[:i], []byte("\r")))
+		w.partial = w.partial[i+1:]
+	}
+
+	return len(p), nil
+}
+
+// flush forwards any unterminated last line; output written afterwards is
+// discarded.
+func (w *tcpLogSinkWriter) flush() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	w.done = true
+	if len(w.partial) > 0 {
+		w.step.enqueue(w.source, w.partial)
+		w.partial = nil
+	}
+}
@@ -0,0 +1,205 @@
+package steps_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("TCPLogSinkStep", func() {
+	type record struct {
+		T    string `json:"t"`
+		Src  string `json:"src"`
+		Line string `json:"line"`
+	}
+
+	var (
+		container *gardenfakes.FakeContainer
+		process   *gardenfakes.FakeProcess
+		listener  net.Listener
+		conns     chan net.Conn
+		clock     *fakeclock.FakeClock
+		logger    *lagertest.TestLogger
+
+		processIO  chan garden.ProcessIO
+		exitStatus chan int
+
+		step       ifrit.Runner
+		runProcess ifrit.Process
+	)
+
+	readRecords := func(conn net.Conn, n int) []record {
+		records := []record{}
+		scanner := bufio.NewScanner(conn)
+		for len(records) < n && scanner.Scan() {
+			var r record
+			Expect(json.Unmarshal(scanner.Bytes(), &r)).To(Succeed())
+			records = append(records, r)
+		}
+		return records
+	}
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		conns = make(chan net.Conn, 10)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conns <- conn
+			}
+		}()
+
+		clock = fakeclock.NewFakeClock(time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC))
+		logger = lagertest.NewTestLogger("test")
+
+		processIO = make(chan garden.ProcessIO, 1)
+		exitStatus = make(chan int, 1)
+
+		process = &gardenfakes.FakeProcess{}
+		process.WaitStub = func() (int, error) {
+			return <-exitStatus, nil
+		}
+		process.SignalStub = func(garden.Signal) error {
+			exitStatus <- 143
+			return nil
+		}
+
+		container = &gardenfakes.FakeContainer{}
+		container.RunStub = func(spec garden.ProcessSpec, pio garden.ProcessIO) (garden.Process, error) {
+			processIO <- pio
+			return process, nil
+		}
+	})
+
+	JustBeforeEach(func() {
+		step = steps.NewTCPLogSink(container, garden.ProcessSpec{Path: "/bin/legacy"}, listener.Addr().String(), 0, clock, logger)
+		runProcess = ifrit.Background(step)
+	})
+
+	AfterEach(func() {
+		listener.Close()
+		select {
+		case exitStatus <- 0:
+		default:
+		}
+		Eventually(runProcess.Wait()).Should(Receive())
+	})
+
+	It("runs the process in the container", func() {
+		Eventually(container.RunCallCount).Should(Equal(1))
+		spec, _ := container.RunArgsForCall(0)
+		Expect(spec.Path).To(Equal("/bin/legacy"))
+	})
+
+	It("forwards each output line as a JSON record", func() {
+		var pio garden.ProcessIO
+		Eventually(processIO).Should(Receive(&pio))
+
+		fmt.Fprint(pio.Stdout, "hello\nwor")
+		fmt.Fprint(pio.Stderr, "oops\n")
+		fmt.Fprint(pio.Stdout, "ld\n")
+
+		var conn net.Conn
+		Eventually(conns).Should(Receive(&conn))
+		defer conn.Close()
+
+		records := readRecords(conn, 3)
+		Expect(records).To(ConsistOf(
+			record{T: "2020-01-02T03:04:05Z", Src: "stdout", Line: "hello"},
+			record{T: "2020-01-02T03:04:05Z", Src: "stderr", Line: "oops"},
+			record{T: "2020-01-02T03:04:05Z", Src: "stdout", Line: "world"},
+		))
+	})
+
+	It("forwards an unterminated last line when the process exits", func() {
+		var pio garden.ProcessIO
+		Eventually(processIO).Should(Receive(&pio))
+
+		fmt.Fprint(pio.Stdout, "no newline")
+		exitStatus <- 0
+
+		Eventually(runProcess.Wait()).Should(Receive(BeNil()))
+
+		var conn net.Conn
+		Eventually(conns).Should(Receive(&conn))
+		defer conn.Close()
+		Expect(readRecords(conn, 1)).To(Equal([]record{{T: "2020-01-02T03:04:05Z", Src: "stdout", Line: "no newline"}}))
+	})
+
+	It("fails when the process exits with a non-zero status", func() {
+		Eventually(processIO).Should(Receive())
+		exitStatus <- 3
+
+		Eventually(runProcess.Wait()).Should(Receive(MatchError("Exited with status 3")))
+	})
+
+	Context("when the connection drops", func() {
+		It("reconnects and keeps forwarding", func() {
+			var pio garden.ProcessIO
+			Eventually(processIO).Should(Receive(&pio))
+
+			fmt.Fprintln(pio.Stdout, "first")
+
+			var first net.Conn
+			Eventually(conns).Should(Receive(&first))
+			Expect(readRecords(first, 1)[0].Line).To(Equal("first"))
+			first.Close()
+
+			var second net.Conn
+			Eventually(func() bool {
+				fmt.Fprintln(pio.Stdout, "again")
+				select {
+				case second = <-conns:
+					return true
+				default:
+					return false
+				}
+			}).Should(BeTrue())
+			defer second.Close()
+
+			Expect(readRecords(second, 1)[0].Line).To(Equal("again"))
+		})
+	})
+
+	Context("when signalled", func() {
+		It("closes the connection, terminates the process and waits for it to exit", func() {
+			var pio garden.ProcessIO
+			Eventually(processIO).Should(Receive(&pio))
+
+			fmt.Fprintln(pio.Stdout, "connect")
+			var conn net.Conn
+			Eventually(conns).Should(Receive(&conn))
+			defer conn.Close()
+
+			runProcess.Signal(os.Interrupt)
+
+			Eventually(runProcess.Wait()).Should(Receive(Equal(steps.ErrCancelled)))
+			Expect(process.SignalCallCount()).To(Equal(1))
+			Expect(process.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
+
+			_, err := io.Copy(ioutil.Discard, conn)
+			Expect(err).NotTo(HaveOccurred())
+		})
+	})
+})
@@ -3,16 +3,20 @@ package steps
 import (
 	"os"
 
-	"code.cloudfoundry.org/workpool"
 	"github.com/tedsuo/ifrit"
 )
 
+// WorkPool runs submitted work once a worker is available.
+type WorkPool interface {
+	Submit(work func())
+}
+
 type throttleStep struct {
 	substep  ifrit.Runner
-	workPool *workpool.WorkPool
+	workPool WorkPool
 }
 
-func NewThrottle(substep ifrit.Runner, workPool *workpool.WorkPool) *throttleStep {
+func NewThrottle(substep ifrit.Runner, workPool WorkPool) *throttleStep {
 	return &throttleStep{
 		substep:  substep,
 		workPool: workPool,
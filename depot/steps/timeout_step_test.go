@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/steps"
 	"code.cloudfoundry.org/lager/lagertest"
 
@@ -131,6 +132,10 @@ var _ = Describe("TimeoutStep", func() {
 						Expect(err).To(HaveOccurred())
 						Expect(err).To(BeAssignableToTypeOf(&steps.EmittableError{}))
 					})
+
+					It("records the timeout as the exit reason", func() {
+						Expect(steps.ExitReasonFor(err)).To(Equal(executor.ExitReasonTimeout))
+					})
 				})
 
 				Context("when the substep returns an error", func() {
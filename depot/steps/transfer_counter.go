@@ -0,0 +1,25 @@
+package steps
+
+import "sync/atomic"
+
+// TransferCounter accumulates the number of bytes moved by download or upload
+// steps. It is safe for concurrent use, and a nil counter discards everything
+// added to it.
+type TransferCounter struct {
+	bytes uint64
+}
+
+func (c *TransferCounter) Add(n int64) {
+	if c == nil || n <= 0 {
+		return
+	}
+	atomic.AddUint64(&c.bytes, uint64(n))
+}
+
+// Total returns the number of bytes added so far.
+func (c *TransferCounter) Total() uint64 {
+	if c == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&c.bytes)
+}
@@ -0,0 +1,79 @@
+package steps
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+const UploadSemaphoreSaturationDuration = "UploadSemaphoreSaturationDuration"
+
+type uploadSaturationReporter struct {
+	uploadLimiter chan struct{}
+	interval      time.Duration
+	clock         clock.Clock
+	metronClient  loggingclient.IngressClient
+	logger        lager.Logger
+}
+
+// NewUploadSaturationReporter returns a runner that checks the upload limiter
+// every interval and, while all of its slots are taken, emits how long it has
+// been full. A single zero is emitted once a slot frees up again.
+func NewUploadSaturationReporter(uploadLimiter chan struct{}, interval time.Duration, clock clock.Clock, metronClient loggingclient.IngressClient, logger lager.Logger) ifrit.Runner {
+	return &uploadSaturationReporter{
+		uploadLimiter: uploadLimiter,
+		interval:      interval,
+		clock:         clock,
+		metronClient:  metronClient,
+		logger:        logger.Session("upload-saturation-reporter"),
+	}
+}
+
+func (r *uploadSaturationReporter) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	timer := r.clock.NewTimer(r.interval)
+
+	close(ready)
+
+	var saturatedSince time.Time
+
+	defer timer.Stop()
+	for {
+		select {
+		case <-timer.C():
+			saturatedSince = r.check(saturatedSince)
+			timer.Reset(r.interval)
+		case signal := <-signals:
+			r.logger.Info("signalled", lager.Data{"signal": signal.String()})
+			return nil
+		}
+	}
+}
+
+func (r *uploadSaturationReporter) check(saturatedSince time.Time) time.Time {
+	if len(r.uploadLimiter) < cap(r.uploadLimiter) {
+		if !saturatedSince.IsZero() {
+			r.logger.Info("upload-limiter-available")
+			r.send(0)
+		}
+		return time.Time{}
+	}
+
+	if saturatedSince.IsZero() {
+		r.logger.Info("upload-limiter-saturated", lager.Data{"slots": cap(r.uploadLimiter)})
+		saturatedSince = r.clock.Now()
+	}
+
+	r.send(r.clock.Since(saturatedSince))
+	return saturatedSince
+}
+
+func (r *uploadSaturationReporter) send(duration time.Duration) {
+	err := r.metronClient.SendDuration(UploadSemaphoreSaturationDuration, duration)
+	if err != nil {
+		r.logger.Error("failed-to-send-saturation-duration", err)
+	}
+}
@@ -0,0 +1,86 @@
+package steps_test
+
+import (
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	mfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("UploadSaturationReporter", func() {
+	var (
+		uploadLimiter    chan struct{}
+		fakeClock        *fakeclock.FakeClock
+		fakeMetronClient *mfakes.FakeIngressClient
+		process          ifrit.Process
+	)
+
+	BeforeEach(func() {
+		uploadLimiter = make(chan struct{}, 2)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeMetronClient = new(mfakes.FakeIngressClient)
+
+		reporter := steps.NewUploadSaturationReporter(uploadLimiter, time.Second, fakeClock, fakeMetronClient, lagertest.NewTestLogger("test"))
+		process = ifrit.Invoke(reporter)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Interrupt)
+		Eventually(process.Wait()).Should(Receive())
+	})
+
+	It("emits nothing while a slot is available", func() {
+		uploadLimiter <- struct{}{}
+
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+		Consistently(fakeMetronClient.SendDurationCallCount).Should(Equal(0))
+	})
+
+	Context("when every slot is taken", func() {
+		BeforeEach(func() {
+			uploadLimiter <- struct{}{}
+			uploadLimiter <- struct{}{}
+		})
+
+		It("emits how long the limiter has been full", func() {
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(fakeMetronClient.SendDurationCallCount).Should(Equal(1))
+			name, value, _ := fakeMetronClient.SendDurationArgsForCall(0)
+			Expect(name).To(Equal(steps.UploadSemaphoreSaturationDuration))
+			Expect(value).To(Equal(time.Duration(0)))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(fakeMetronClient.SendDurationCallCount).Should(Equal(2))
+			_, value, _ = fakeMetronClient.SendDurationArgsForCall(1)
+			Expect(value).To(Equal(time.Second))
+		})
+
+		It("resets once a slot frees up", func() {
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(fakeMetronClient.SendDurationCallCount).Should(Equal(2))
+
+			<-uploadLimiter
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(fakeMetronClient.SendDurationCallCount).Should(Equal(3))
+			_, value, _ := fakeMetronClient.SendDurationArgsForCall(2)
+			Expect(value).To(Equal(time.Duration(0)))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Consistently(fakeMetronClient.SendDurationCallCount).Should(Equal(3))
+
+			uploadLimiter <- struct{}{}
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(fakeMetronClient.SendDurationCallCount).Should(Equal(4))
+			_, value, _ = fakeMetronClient.SendDurationArgsForCall(3)
+			Expect(value).To(Equal(time.Duration(0)))
+		})
+	})
+})
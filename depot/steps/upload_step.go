@@ -12,6 +12,7 @@ import (
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/bytefmt"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
+	"code.cloudfoundry.org/executor/depot/tarsanitizer"
 	"code.cloudfoundry.org/executor/depot/uploader"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/lager"
@@ -19,14 +20,16 @@ import (
 )
 
 type uploadStep struct {
-	container   garden.Container
-	model       models.UploadAction
-	uploader    uploader.Uploader
-	compressor  compressor.Compressor
-	tempDir     string
-	streamer    log_streamer.LogStreamer
-	rateLimiter chan struct{}
-	logger      lager.Logger
+	container     garden.Container
+	model         models.UploadAction
+	uploader      uploader.Uploader
+	compressor    compressor.Compressor
+	tempDir       string
+	streamer      log_streamer.LogStreamer
+	rateLimiter   chan struct{}
+	symlinks      tarsanitizer.SymlinkPolicy
+	bytesUploaded *TransferCounter
+	logger        lager.Logger
 
 	cancelUpload chan struct{}
 }
@@ -39,6 +42,8 @@ func NewUpload(
 	tempDir string,
 	streamer log_streamer.LogStreamer,
 	rateLimiter chan struct{},
+	symlinks tarsanitizer.SymlinkPolicy,
+	bytesUploaded *TransferCounter,
 	logger lager.Logger,
 ) ifrit.Runner {
 	logger = logger.Session("upload-step", lager.Data{
@@ -46,14 +51,16 @@ func NewUpload(
 	})
 
 	return &uploadStep{
-		container:   container,
-		model:       model,
-		uploader:    uploader,
-		compressor:  compressor,
-		tempDir:     tempDir,
-		streamer:    streamer,
-		rateLimiter: rateLimiter,
-		logger:      logger,
+		container:     container,
+		model:         model,
+		uploader:      uploader,
+		compressor:    compressor,
+		tempDir:       tempDir,
+		streamer:      streamer,
+		rateLimiter:   rateLimiter,
+		symlinks:      symlinks,
+		bytesUploaded: bytesUploaded,
+		logger:        logger,
 
 		cancelUpload: make(chan struct{}),
 	}
@@ -63,6 +70,7 @@ const (
 	ErrCreateTmpDir    = "Failed to create temp dir"
 	ErrEstablishStream = "Failed to establish stream from container"
 	ErrReadTar         = "Failed to find first item in tar stream"
+	ErrUnsafeSymlink   = "Refusing to upload symlink pointing outside of the container path"
 	ErrCreateTmpFile   = "Failed to create temp file"
 	ErrCopyStreamToTmp = "Failed to copy stream contents into temp file"
 	ErrParsingURL      = "Failed to parse URL"
@@ -104,14 +112,18 @@ func (step *uploadStep) Run(signals <-chan os.Signal, ready chan<- struct{}) (er
 		step.emitError(errString)
 		return NewEmittableError(err, errString)
 	}
-	defer outStream.Close()
+	sanitizedStream := tarsanitizer.Sanitize(step.logger, outStream, step.symlinks)
+	defer sanitizedStream.Close()
 
-	tarStream := tar.NewReader(outStream)
+	tarStream := tar.NewReader(sanitizedStream)
 	_, err = tarStream.Next()
 
 	if err != nil {
 		step.logger.Error("failed-to-read-stream", err)
 		errString := step.artifactErrString(ErrReadTar)
+		if err == tarsanitizer.ErrUnsafeSymlink {
+			errString = step.artifactErrString(ErrUnsafeSymlink)
+		}
 		step.emitError(errString)
 		return NewEmittableError(err, errString)
 	}
@@ -151,12 +163,19 @@ func (step *uploadStep) Run(signals <-chan os.Signal, ready chan<- struct{}) (er
 			step.logger.Error("failed-to-upload", err)
 
 			// Do not emit error in case it leaks sensitive data in URL
+			if uploadErr, ok := err.(*uploader.UploadError); ok {
+				errString := step.artifactErrString(uploadErr.Message())
+				step.emitError(errString)
+				return NewEmittableError(err, errString)
+			}
+
 			step.emitError(step.artifactErrString("Failed to upload payload"))
 
 			return err
 		}
 	}
 
+	step.bytesUploaded.Add(uploadedBytes)
 	step.emit("Uploaded %s (%s)\n", step.model.Artifact, bytefmt.ByteSize(uint64(uploadedBytes)))
 
 	step.logger.Info("upload-successful")
@@ -0,0 +1,95 @@
+package steps
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+)
+
+var ErrTimeout = errors.New("timed out")
+
+type waitForFileStep struct {
+	container    garden.Container
+	path         string
+	pollInterval time.Duration
+	timeout      time.Duration
+	clock        clock.Clock
+	logger       lager.Logger
+}
+
+// NewWaitForFile returns a step that exits successfully once path exists in
+// the container. The container is checked every pollInterval by streaming the
+// path out; the step fails with ErrTimeout if the path has not appeared after
+// timeout.
+func NewWaitForFile(
+	container garden.Container,
+	path string,
+	pollInterval time.Duration,
+	timeout time.Duration,
+	clock clock.Clock,
+	logger lager.Logger,
+) ifrit.Runner {
+	return &waitForFileStep{
+		container:    container,
+		path:         path,
+		pollInterval: pollInterval,
+		timeout:      timeout,
+		clock:        clock,
+		logger:       logger.Session("wait-for-file-step", lager.Data{"path": path}),
+	}
+}
+
+func (step *waitForFileStep) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	close(ready)
+
+	timer := step.clock.NewTimer(step.timeout)
+	defer timer.Stop()
+
+	ticker := step.clock.NewTicker(step.pollInterval)
+	defer ticker.Stop()
+
+	existsCh := make(chan bool, 1)
+	for {
+		go func() {
+			existsCh <- step.exists()
+		}()
+
+		select {
+		case exists := <-existsCh:
+			if exists {
+				step.logger.Info("file-found")
+				return nil
+			}
+		case <-signals:
+			step.logger.Info("cancelled")
+			return ErrCancelled
+		case <-timer.C():
+			step.logger.Error("timed-out", ErrTimeout)
+			return ErrTimeout
+		}
+
+		select {
+		case <-ticker.C():
+		case <-signals:
+			step.logger.Info("cancelled")
+			return ErrCancelled
+		case <-timer.C():
+			step.logger.Error("timed-out", ErrTimeout)
+			return ErrTimeout
+		}
+	}
+}
+
+func (step *waitForFileStep) exists() bool {
+	stream, err := step.container.StreamOut(garden.StreamOutSpec{Path: step.path})
+	if err != nil {
+		return false
+	}
+	stream.Close()
+	return true
+}
@@ -0,0 +1,121 @@
+package steps_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+)
+
+var _ = Describe("WaitForFileStep", func() {
+	var (
+		container    *gardenfakes.FakeContainer
+		pollInterval time.Duration
+		timeout      time.Duration
+		clock        *fakeclock.FakeClock
+		logger       *lagertest.TestLogger
+		process      ifrit.Process
+	)
+
+	BeforeEach(func() {
+		container = &gardenfakes.FakeContainer{}
+		container.StreamOutReturns(nil, errors.New("no such file"))
+
+		pollInterval = time.Second
+		timeout = 10 * time.Second
+		clock = fakeclock.NewFakeClock(time.Now())
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	JustBeforeEach(func() {
+		step := steps.NewWaitForFile(container, "/tmp/app.pid", pollInterval, timeout, clock, logger)
+		process = ifrit.Background(step)
+	})
+
+	AfterEach(func() {
+		process.Signal(os.Kill)
+	})
+
+	It("checks for the file by streaming it out of the container", func() {
+		Eventually(container.StreamOutCallCount).Should(Equal(1))
+		Expect(container.StreamOutArgsForCall(0)).To(Equal(garden.StreamOutSpec{Path: "/tmp/app.pid"}))
+	})
+
+	Context("when the file exists", func() {
+		BeforeEach(func() {
+			container.StreamOutReturns(ioutil.NopCloser(new(bytes.Buffer)), nil)
+		})
+
+		It("exits successfully", func() {
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+		})
+	})
+
+	Context("when the file appears later", func() {
+		BeforeEach(func() {
+			checks := 0
+			container.StreamOutStub = func(garden.StreamOutSpec) (io.ReadCloser, error) {
+				checks++
+				if checks < 3 {
+					return nil, errors.New("no such file")
+				}
+				return ioutil.NopCloser(new(bytes.Buffer)), nil
+			}
+		})
+
+		It("polls until the file exists", func() {
+			Eventually(container.StreamOutCallCount).Should(Equal(1))
+			Consistently(process.Wait()).ShouldNot(Receive())
+
+			clock.WaitForNWatchersAndIncrement(pollInterval, 2)
+			Eventually(container.StreamOutCallCount).Should(Equal(2))
+			Consistently(process.Wait()).ShouldNot(Receive())
+
+			clock.WaitForNWatchersAndIncrement(pollInterval, 2)
+			Eventually(process.Wait()).Should(Receive(BeNil()))
+			Expect(container.StreamOutCallCount()).To(Equal(3))
+		})
+	})
+
+	Context("when the file does not appear before the timeout", func() {
+		It("fails with ErrTimeout", func() {
+			Eventually(container.StreamOutCallCount).Should(Equal(1))
+			clock.WaitForNWatchersAndIncrement(timeout, 2)
+			Eventually(process.Wait()).Should(Receive(Equal(steps.ErrTimeout)))
+		})
+	})
+
+	Context("when signalled", func() {
+		var blockStreamOut chan struct{}
+
+		BeforeEach(func() {
+			blockStreamOut = make(chan struct{})
+			container.StreamOutStub = func(garden.StreamOutSpec) (io.ReadCloser, error) {
+				<-blockStreamOut
+				return nil, errors.New("no such file")
+			}
+		})
+
+		AfterEach(func() {
+			close(blockStreamOut)
+		})
+
+		It("cancels without waiting for the current check", func() {
+			Eventually(container.StreamOutCallCount).Should(Equal(1))
+			process.Signal(os.Interrupt)
+			Eventually(process.Wait()).Should(Receive(Equal(steps.ErrCancelled)))
+		})
+	})
+})
@@ -0,0 +1 @@
+package tarsanitizer // import "code.cloudfoundry.org/executor/depot/tarsanitizer"
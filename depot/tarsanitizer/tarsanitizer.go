@@ -0,0 +1,163 @@
+package tarsanitizer
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// SymlinkPolicy decides what happens to symlinks in a tar stream streamed out
+// of a container whose target is absolute or resolves outside of the archive.
+// Symlinks that stay within the archive are always passed through unchanged.
+type SymlinkPolicy string
+
+const (
+	// SymlinkPolicyPreserve passes every symlink through unchanged.
+	SymlinkPolicyPreserve SymlinkPolicy = "preserve"
+	// SymlinkPolicyDrop removes unsafe symlinks from the stream.
+	SymlinkPolicyDrop SymlinkPolicy = "drop"
+	// SymlinkPolicyRewrite rewrites unsafe symlink targets to relative targets
+	// within the archive.
+	SymlinkPolicyRewrite SymlinkPolicy = "rewrite"
+	// SymlinkPolicyFail fails the stream when it reaches an unsafe symlink.
+	SymlinkPolicyFail SymlinkPolicy = "fail"
+)
+
+var ErrUnsafeSymlink = errors.New("tar stream contains a symlink that points outside of the archive")
+
+// Validate returns an error if the policy is not one of the known policies.
+// The empty policy is valid and behaves like SymlinkPolicyPreserve.
+func (p SymlinkPolicy) Validate() error {
+	switch p {
+	case "", SymlinkPolicyPreserve, SymlinkPolicyDrop, SymlinkPolicyRewrite, SymlinkPolicyFail:
+		return nil
+	default:
+		return fmt.Errorf("unknown tar symlink policy %q", string(p))
+	}
+}
+
+// Sanitize returns a tar stream with the symlinks of source handled according
+// to policy. Closing the returned stream closes source. With the preserve
+// policy source is returned as is.
+func Sanitize(logger lager.Logger, source io.ReadCloser, policy SymlinkPolicy) io.ReadCloser {
+	if policy == "" || policy == SymlinkPolicyPreserve {
+		return source
+	}
+
+	logger = logger.Session("tar-sanitizer", lager.Data{"policy": policy})
+
+	pipeReader, pipeWriter := io.Pipe()
+	go func() {
+		pipeWriter.CloseWithError(copySanitized(logger, tar.NewReader(source), tar.NewWriter(pipeWriter), policy))
+	}()
+
+	return &sanitizedStream{PipeReader: pipeReader, source: source}
+}
+
+type sanitizedStream struct {
+	*io.PipeReader
+	source io.Closer
+}
+
+func (s *sanitizedStream) Close() error {
+	s.PipeReader.Close()
+	return s.source.Close()
+}
+
+func copySanitized(logger lager.Logger, reader *tar.Reader, writer *tar.Writer, policy SymlinkPolicy) error {
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return writer.Close()
+		}
+		if err != nil {
+			return err
+		}
+
+		if header.Typeflag == tar.TypeSymlink {
+			target, safe := resolveSymlink(header.Name, header.Linkname)
+			if !safe {
+				logData := lager.Data{"name": header.Name, "target": header.Linkname}
+				switch policy {
+				case SymlinkPolicyDrop:
+					logger.Info("dropping-unsafe-symlink", logData)
+					continue
+				case SymlinkPolicyRewrite:
+					logData["rewritten-target"] = target
+					logger.Info("rewriting-unsafe-symlink", logData)
+					header.Linkname = target
+				default:
+					logger.Error("unsafe-symlink", ErrUnsafeSymlink, logData)
+					return ErrUnsafeSymlink
+				}
+			}
+		}
+
+		err = writer.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(writer, reader)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// resolveSymlink reports whether the symlink name -> linkname stays within
+// the archive. It also returns a relative target for the link that resolves to
+// the same location with the archive treated as the root of the filesystem.
+func resolveSymlink(name, linkname string) (string, bool) {
+	dir := path.Dir(path.Clean(strings.TrimLeft(name, "/")))
+
+	safe := false
+	if !path.IsAbs(linkname) {
+		joined := path.Join(dir, linkname)
+		safe = joined != ".." && !strings.HasPrefix(joined, "../")
+	}
+
+	resolved := linkname
+	if !path.IsAbs(resolved) {
+		resolved = path.Join(dir, resolved)
+	}
+	resolved = path.Clean("/" + resolved)
+
+	return relativePath(path.Clean("/"+dir), resolved), safe
+}
+
+// relativePath returns the path of target relative to base. Both must be
+// clean absolute paths.
+func relativePath(base, target string) string {
+	baseParts := splitPath(base)
+	targetParts := splitPath(target)
+
+	common := 0
+	for common < len(baseParts) && common < len(targetParts) && baseParts[common] == targetParts[common] {
+		common++
+	}
+
+	parts := []string{}
+	for i := common; i < len(baseParts); i++ {
+		parts = append(parts, "..")
+	}
+	parts = append(parts, targetParts[common:]...)
+
+	if len(parts) == 0 {
+		return "."
+	}
+	return strings.Join(parts, "/")
+}
+
+func splitPath(p string) []string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil
+	}
+	return strings.Split(p, "/")
+}
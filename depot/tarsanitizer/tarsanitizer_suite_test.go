@@ -0,0 +1,13 @@
+package tarsanitizer_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestTarSanitizer(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Tar Sanitizer Suite")
+}
@@ -0,0 +1,157 @@
+package tarsanitizer_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	"code.cloudfoundry.org/executor/depot/tarsanitizer"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+type tarEntry struct {
+	name     string
+	linkname string
+	body     string
+}
+
+func buildTar(entries []tarEntry) io.ReadCloser {
+	buffer := new(bytes.Buffer)
+	writer := tar.NewWriter(buffer)
+	for _, entry := range entries {
+		header := &tar.Header{Name: entry.name, Mode: 0644}
+		if entry.linkname != "" {
+			header.Typeflag = tar.TypeSymlink
+			header.Linkname = entry.linkname
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = int64(len(entry.body))
+		}
+		Expect(writer.WriteHeader(header)).To(Succeed())
+		_, err := writer.Write([]byte(entry.body))
+		Expect(err).NotTo(HaveOccurred())
+	}
+	Expect(writer.Close()).To(Succeed())
+	return ioutil.NopCloser(buffer)
+}
+
+func readTar(stream io.Reader) ([]tarEntry, error) {
+	entries := []tarEntry{}
+	reader := tar.NewReader(stream)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return entries, err
+		}
+		body, err := ioutil.ReadAll(reader)
+		if err != nil {
+			return entries, err
+		}
+		entries = append(entries, tarEntry{name: header.Name, linkname: header.Linkname, body: string(body)})
+	}
+}
+
+var _ = Describe("Sanitize", func() {
+	var (
+		logger  *lagertest.TestLogger
+		fixture []tarEntry
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fixture = []tarEntry{
+			{name: "./app/index.html", body: "hello"},
+			{name: "./app/current", linkname: "index.html"},
+			{name: "./app/lib/shared", linkname: "../index.html"},
+			{name: "./app/passwd", linkname: "/etc/passwd"},
+			{name: "./app/escape", linkname: "../../../etc/shadow"},
+			{name: "./app/trailer", body: "bye"},
+		}
+	})
+
+	sanitize := func(policy tarsanitizer.SymlinkPolicy) ([]tarEntry, error) {
+		stream := tarsanitizer.Sanitize(logger, buildTar(fixture), policy)
+		defer stream.Close()
+		return readTar(stream)
+	}
+
+	Context("with the preserve policy", func() {
+		It("passes every entry through unchanged", func() {
+			entries, err := sanitize(tarsanitizer.SymlinkPolicyPreserve)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(Equal(fixture))
+		})
+	})
+
+	Context("with the drop policy", func() {
+		It("drops only the symlinks that leave the archive", func() {
+			entries, err := sanitize(tarsanitizer.SymlinkPolicyDrop)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(Equal([]tarEntry{
+				{name: "./app/index.html", body: "hello"},
+				{name: "./app/current", linkname: "index.html"},
+				{name: "./app/lib/shared", linkname: "../index.html"},
+				{name: "./app/trailer", body: "bye"},
+			}))
+		})
+	})
+
+	Context("with the rewrite policy", func() {
+		It("rewrites unsafe targets relative to the archive root", func() {
+			entries, err := sanitize(tarsanitizer.SymlinkPolicyRewrite)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(Equal([]tarEntry{
+				{name: "./app/index.html", body: "hello"},
+				{name: "./app/current", linkname: "index.html"},
+				{name: "./app/lib/shared", linkname: "../index.html"},
+				{name: "./app/passwd", linkname: "../etc/passwd"},
+				{name: "./app/escape", linkname: "../etc/shadow"},
+				{name: "./app/trailer", body: "bye"},
+			}))
+		})
+	})
+
+	Context("with the fail policy", func() {
+		It("fails at the first unsafe symlink", func() {
+			entries, err := sanitize(tarsanitizer.SymlinkPolicyFail)
+			Expect(err).To(Equal(tarsanitizer.ErrUnsafeSymlink))
+			Expect(entries).To(Equal(fixture[:3]))
+		})
+
+		Context("when every symlink is benign", func() {
+			BeforeEach(func() {
+				fixture = fixture[:3]
+			})
+
+			It("passes every entry through unchanged", func() {
+				entries, err := sanitize(tarsanitizer.SymlinkPolicyFail)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(entries).To(Equal(fixture))
+			})
+		})
+	})
+})
+
+var _ = Describe("SymlinkPolicy", func() {
+	It("accepts the known policies and the empty policy", func() {
+		for _, policy := range []tarsanitizer.SymlinkPolicy{
+			"",
+			tarsanitizer.SymlinkPolicyPreserve,
+			tarsanitizer.SymlinkPolicyDrop,
+			tarsanitizer.SymlinkPolicyRewrite,
+			tarsanitizer.SymlinkPolicyFail,
+		} {
+			Expect(policy.Validate()).To(Succeed())
+		}
+	})
+
+	It("rejects unknown policies", func() {
+		Expect(tarsanitizer.SymlinkPolicy("follow").Validate()).To(HaveOccurred())
+	})
+})
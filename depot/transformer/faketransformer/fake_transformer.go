@@ -30,6 +30,26 @@ type FakeTransformer struct {
 		result1 ifrit.Runner
 		result2 error
 	}
+	TotalBytesDownloadedStub        func() uint64
+	totalBytesDownloadedMutex       sync.RWMutex
+	totalBytesDownloadedArgsForCall []struct {
+	}
+	totalBytesDownloadedReturns struct {
+		result1 uint64
+	}
+	totalBytesDownloadedReturnsOnCall map[int]struct {
+		result1 uint64
+	}
+	TotalBytesUploadedStub        func() uint64
+	totalBytesUploadedMutex       sync.RWMutex
+	totalBytesUploadedArgsForCall []struct {
+	}
+	totalBytesUploadedReturns struct {
+		result1 uint64
+	}
+	totalBytesUploadedReturnsOnCall map[int]struct {
+		result1 uint64
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -101,11 +121,119 @@ func (fake *FakeTransformer) StepsRunnerReturnsOnCall(i int, result1 ifrit.Runne
 	}{result1, result2}
 }
 
+func (fake *FakeTransformer) TotalBytesDownloaded() uint64 {
+	fake.totalBytesDownloadedMutex.Lock()
+	ret, specificReturn := fake.totalBytesDownloadedReturnsOnCall[len(fake.totalBytesDownloadedArgsForCall)]
+	fake.totalBytesDownloadedArgsForCall = append(fake.totalBytesDownloadedArgsForCall, struct {
+	}{})
+	fake.recordInvocation("TotalBytesDownloaded", []interface{}{})
+	fake.totalBytesDownloadedMutex.Unlock()
+	if fake.TotalBytesDownloadedStub != nil {
+		return fake.TotalBytesDownloadedStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.totalBytesDownloadedReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeTransformer) TotalBytesDownloadedCallCount() int {
+	fake.totalBytesDownloadedMutex.RLock()
+	defer fake.totalBytesDownloadedMutex.RUnlock()
+	return len(fake.totalBytesDownloadedArgsForCall)
+}
+
+func (fake *FakeTransformer) TotalBytesDownloadedCalls(stub func() uint64) {
+	fake.totalBytesDownloadedMutex.Lock()
+	defer fake.totalBytesDownloadedMutex.Unlock()
+	fake.TotalBytesDownloadedStub = stub
+}
+
+func (fake *FakeTransformer) TotalBytesDownloadedReturns(result1 uint64) {
+	fake.totalBytesDownloadedMutex.Lock()
+	defer fake.totalBytesDownloadedMutex.Unlock()
+	fake.TotalBytesDownloadedStub = nil
+	fake.totalBytesDownloadedReturns = struct {
+		result1 uint64
+	}{result1}
+}
+
+func (fake *FakeTransformer) TotalBytesDownloadedReturnsOnCall(i int, result1 uint64) {
+	fake.totalBytesDownloadedMutex.Lock()
+	defer fake.totalBytesDownloadedMutex.Unlock()
+	fake.TotalBytesDownloadedStub = nil
+	if fake.totalBytesDownloadedReturnsOnCall == nil {
+		fake.totalBytesDownloadedReturnsOnCall = make(map[int]struct {
+			result1 uint64
+		})
+	}
+	fake.totalBytesDownloadedReturnsOnCall[i] = struct {
+		result1 uint64
+	}{result1}
+}
+
+func (fake *FakeTransformer) TotalBytesUploaded() uint64 {
+	fake.totalBytesUploadedMutex.Lock()
+	ret, specificReturn := fake.totalBytesUploadedReturnsOnCall[len(fake.totalBytesUploadedArgsForCall)]
+	fake.totalBytesUploadedArgsForCall = append(fake.totalBytesUploadedArgsForCall, struct {
+	}{})
+	fake.recordInvocation("TotalBytesUploaded", []interface{}{})
+	fake.totalBytesUploadedMutex.Unlock()
+	if fake.TotalBytesUploadedStub != nil {
+		return fake.TotalBytesUploadedStub()
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.totalBytesUploadedReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeTransformer) TotalBytesUploadedCallCount() int {
+	fake.totalBytesUploadedMutex.RLock()
+	defer fake.totalBytesUploadedMutex.RUnlock()
+	return len(fake.totalBytesUploadedArgsForCall)
+}
+
+func (fake *FakeTransformer) TotalBytesUploadedCalls(stub func() uint64) {
+	fake.totalBytesUploadedMutex.Lock()
+	defer fake.totalBytesUploadedMutex.Unlock()
+	fake.TotalBytesUploadedStub = stub
+}
+
+func (fake *FakeTransformer) TotalBytesUploadedReturns(result1 uint64) {
+	fake.totalBytesUploadedMutex.Lock()
+	defer fake.totalBytesUploadedMutex.Unlock()
+	fake.TotalBytesUploadedStub = nil
+	fake.totalBytesUploadedReturns = struct {
+		result1 uint64
+	}{result1}
+}
+
+func (fake *FakeTransformer) TotalBytesUploadedReturnsOnCall(i int, result1 uint64) {
+	fake.totalBytesUploadedMutex.Lock()
+	defer fake.totalBytesUploadedMutex.Unlock()
+	fake.TotalBytesUploadedStub = nil
+	if fake.totalBytesUploadedReturnsOnCall == nil {
+		fake.totalBytesUploadedReturnsOnCall = make(map[int]struct {
+			result1 uint64
+		})
+	}
+	fake.totalBytesUploadedReturnsOnCall[i] = struct {
+		result1 uint64
+	}{result1}
+}
+
 func (fake *FakeTransformer) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
 	fake.stepsRunnerMutex.RLock()
 	defer fake.stepsRunnerMutex.RUnlock()
+	fake.totalBytesDownloadedMutex.RLock()
+	defer fake.totalBytesDownloadedMutex.RUnlock()
+	fake.totalBytesUploadedMutex.RLock()
+	defer fake.totalBytesUploadedMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
 	for key, value := range fake.invocations {
 		copiedInvocations[key] = value
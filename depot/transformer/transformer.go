@@ -5,9 +5,11 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"sync"
 	"time"
 
 	"code.cloudfoundry.org/archiver/compressor"
@@ -16,8 +18,10 @@ import (
 	"code.cloudfoundry.org/clock"
 	loggingclient "code.cloudfoundry.org/diego-logging-client"
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/healthcheckpool"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/executor/depot/tarsanitizer"
 	"code.cloudfoundry.org/executor/depot/uploader"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/lager"
@@ -29,15 +33,34 @@ const (
 	healthCheckNofiles                          uint64 = 1024
 	DefaultDeclarativeHealthcheckRequestTimeout        = int(1 * time.Second / time.Millisecond)
 	HealthLogSource                                    = "HEALTH"
+	DiagnosticLogSource                                = "DIAG"
+	DefaultOnUnhealthyActionTimeout                    = 30 * time.Second
+	DefaultProxyAdminRequestTimeout                    = 1 * time.Second
 )
 
 var ErrNoCheck = errors.New("no check configured")
+var ErrCyclicAction = errors.New("action contains a cycle")
+var ErrNoMonitor = errors.New("container has no monitor")
 var HealthCheckDstPath string = filepath.Join(string(os.PathSeparator), "etc", "cf-assets", "healthcheck")
 
+// executorProcessPaths are the binaries garden and the executor run in
+// containers themselves, which the process whitelist always allows.
+var executorProcessPaths = []string{
+	"/tmp/garden-init",
+	filepath.Join(HealthCheckDstPath, "healthcheck"),
+	"/etc/cf-assets/envoy/envoy",
+	"/tmp/lifecycle/diego-sshd",
+}
+
 //go:generate counterfeiter -o faketransformer/fake_transformer.go . Transformer
 
 type Transformer interface {
 	StepsRunner(lager.Logger, executor.Container, garden.Container, log_streamer.LogStreamer, Config) (ifrit.Runner, error)
+
+	// TotalBytesDownloaded and TotalBytesUploaded return the number of bytes
+	// moved by every download and upload step built so far.
+	TotalBytesDownloaded() uint64
+	TotalBytesUploaded() uint64
 }
 
 type Config struct {
@@ -45,6 +68,18 @@ type Config struct {
 	BindMounts        []garden.BindMount
 	CreationStartTime time.Time
 	MetronClient      loggingclient.IngressClient
+	// EgressRules, when present, are applied by an egress firewall step
+	// before the setup step runs. Rules already in the garden create spec
+	// must not be passed again, since NetOut would duplicate them.
+	EgressRules []garden.NetOutRule
+	// HealthCheckReporter, when present, is told the result of every check
+	// run by the monitor action.
+	HealthCheckReporter steps.HealthCheckReporter
+	// MonitorOnly builds just the health monitor, for a container whose
+	// action is already running in garden, such as one imported from another
+	// executor. StepsRunner returns ErrNoMonitor if there is nothing to
+	// monitor.
+	MonitorOnly bool
 }
 
 type transformer struct {
@@ -58,16 +93,48 @@ type transformer struct {
 
 	sidecarRootFS               string
 	useDeclarativeHealthCheck   bool
+	useExecutorHTTPHealthCheck  bool
+	interpolateEnv              bool
 	healthyMonitoringInterval   time.Duration
 	unhealthyMonitoringInterval time.Duration
 	gracefulShutdownInterval    time.Duration
-	healthCheckWorkPool         *workpool.WorkPool
+	readinessWorkPool           steps.WorkPool
+	livenessWorkPool            steps.WorkPool
+	healthCheckUser             string
 
 	useContainerProxy bool
 	drainWait         time.Duration
 
-	postSetupHook []string
-	postSetupUser string
+	proxyDrainTimeout time.Duration
+	proxyAdminURL     func(executor.Container) (string, error)
+
+	onUnhealthyActionTimeout time.Duration
+
+	postSetupHook        []string
+	postSetupUser        string
+	postSetupHookTimeout time.Duration
+
+	tarSymlinkPolicy tarsanitizer.SymlinkPolicy
+
+	maxConcurrentDownloadsPerContainer int
+	maxDownloadSizeBytes               int64
+	artifactSizer                      steps.ArtifactSizer
+
+	ociFetcher steps.OCIFetcher
+	// compressedFetcher, when set, fetches downloads that are not cached
+	compressedFetcher steps.CompressedFetcher
+
+	processWrapperPath string
+
+	processWhitelist         []string
+	processWhitelistInterval time.Duration
+
+	proxyEnv []string
+
+	bytesDownloaded *steps.TransferCounter
+	bytesUploaded   *steps.TransferCounter
+
+	partitionDirs *partitionDirs
 }
 
 type Option func(*transformer)
@@ -86,6 +153,23 @@ func WithDeclarativeHealthchecks() Option {
 	}
 }
 
+// WithExecutorHTTPHealthchecks makes the executor perform declarative HTTP
+// health checks itself instead of running the healthcheck binary in a sidecar.
+func WithExecutorHTTPHealthchecks() Option {
+	return func(t *transformer) {
+		t.useExecutorHTTPHealthCheck = true
+	}
+}
+
+// WithEnvInterpolation replaces "{{.Guid}}", "{{.InternalIP}}" and
+// "{{.InstanceIndex}}" tokens in the environment of run actions with the
+// metadata of the container.
+func WithEnvInterpolation() Option {
+	return func(t *transformer) {
+		t.interpolateEnv = true
+	}
+}
+
 func WithContainerProxy(drainWait time.Duration) Option {
 	return func(t *transformer) {
 		t.useContainerProxy = true
@@ -93,6 +177,44 @@ func WithContainerProxy(drainWait time.Duration) Option {
 	}
 }
 
+// WithProxyDrain drains the container proxy before the container's processes
+// are signalled to stop. proxyAdminURL returns the address of the proxy's
+// admin listener inside a container. The drain is bounded by timeout and by
+// the graceful shutdown interval, and the time reserved for it is taken off
+// the interval the processes get to exit, so that stopping the container
+// still takes at most the graceful shutdown interval.
+func WithProxyDrain(timeout time.Duration, proxyAdminURL func(executor.Container) (string, error)) Option {
+	return func(t *transformer) {
+		t.proxyDrainTimeout = timeout
+		t.proxyAdminURL = proxyAdminURL
+	}
+}
+
+// WithHealthCheckPool schedules health checks through pool, which gives the
+// readiness checks of starting containers priority over liveness checks.
+func WithHealthCheckPool(pool *healthcheckpool.Pool) Option {
+	return func(t *transformer) {
+		t.readinessWorkPool = pool.Queue(healthcheckpool.PhaseReadiness)
+		t.livenessWorkPool = pool.Queue(healthcheckpool.PhaseLiveness)
+	}
+}
+
+// WithHealthCheckUser runs declarative health check processes as user. An
+// empty user leaves the choice to garden.
+func WithHealthCheckUser(user string) Option {
+	return func(t *transformer) {
+		t.healthCheckUser = user
+	}
+}
+
+// WithOnUnhealthyActionTimeout bounds the diagnostic action run in a
+// container after its liveness check fails.
+func WithOnUnhealthyActionTimeout(timeout time.Duration) Option {
+	return func(t *transformer) {
+		t.onUnhealthyActionTimeout = timeout
+	}
+}
+
 func WithPostSetupHook(user string, hook []string) Option {
 	return func(t *transformer) {
 		t.postSetupUser = user
@@ -100,6 +222,97 @@ func WithPostSetupHook(user string, hook []string) Option {
 	}
 }
 
+// WithPostSetupHookTimeout bounds how long the post-setup hook may run. Zero
+// lets it run until the container is stopped.
+func WithPostSetupHookTimeout(timeout time.Duration) Option {
+	return func(t *transformer) {
+		t.postSetupHookTimeout = timeout
+	}
+}
+
+// WithTarSymlinkPolicy sets how upload steps handle symlinks in the tar
+// stream they read from the container.
+func WithTarSymlinkPolicy(policy tarsanitizer.SymlinkPolicy) Option {
+	return func(t *transformer) {
+		t.tarSymlinkPolicy = policy
+	}
+}
+
+// WithMaxConcurrentDownloadsPerContainer limits how many download steps of a
+// single container may run at once. Zero leaves only the global limit.
+func WithMaxConcurrentDownloadsPerContainer(max int) Option {
+	return func(t *transformer) {
+		t.maxConcurrentDownloadsPerContainer = max
+	}
+}
+
+// WithMaxDownloadSize fails download steps whose artifact is larger than
+// maxSizeBytes. Zero leaves downloads unbounded. When sizer is set, cached
+// downloads over HTTP are checked against the size the server reports
+// before they are written to the download cache.
+func WithMaxDownloadSize(maxSizeBytes int64, sizer steps.ArtifactSizer) Option {
+	return func(t *transformer) {
+		t.maxDownloadSizeBytes = maxSizeBytes
+		t.artifactSizer = sizer
+	}
+}
+
+// WithCompressedFetcher makes download actions over HTTP that have no cache
+// key fetch their artifact with fetcher, which asks the server to compress
+// it on the wire.
+func WithCompressedFetcher(fetcher steps.CompressedFetcher) Option {
+	return func(t *transformer) {
+		t.compressedFetcher = fetcher
+	}
+}
+
+// WithOCIFetcher makes download actions whose From URL uses the oci scheme
+// pull the image with fetcher. Without it such downloads fail.
+func WithOCIFetcher(fetcher steps.OCIFetcher) Option {
+	return func(t *transformer) {
+		t.ociFetcher = fetcher
+	}
+}
+
+// WithProcessWrapper runs every action and setup process through the wrapper
+// binary at path, unless the container opts out. Monitor and healthcheck
+// processes are never wrapped.
+func WithProcessWrapper(path string) Option {
+	return func(t *transformer) {
+		t.processWrapperPath = path
+	}
+}
+
+// WithProcessWhitelist stops every container in which a process runs a
+// binary that is not in allowedPaths, checking every interval while the
+// action runs. The binaries of garden, the executor and the process wrapper
+// are always allowed.
+func WithProcessWhitelist(allowedPaths []string, interval time.Duration) Option {
+	return func(t *transformer) {
+		t.processWhitelist = allowedPaths
+		t.processWhitelistInterval = interval
+	}
+}
+
+// WithProxyEnv exports the given proxy settings to the processes of run
+// actions as http_proxy, https_proxy and no_proxy, alongside the networking
+// variables. Empty settings are omitted, and variables set by the action
+// itself take precedence.
+func WithProxyEnv(httpProxy, httpsProxy, noProxy string) Option {
+	return func(t *transformer) {
+		t.proxyEnv = nil
+		for _, envVar := range []*models.EnvironmentVariable{
+			{Name: "http_proxy", Value: httpProxy},
+			{Name: "https_proxy", Value: httpsProxy},
+			{Name: "no_proxy", Value: noProxy},
+		} {
+			if envVar.Value != "" {
+				t.proxyEnv = append(t.proxyEnv, envVar.Name+"="+envVar.Value)
+			}
+		}
+	}
+}
+
 func NewTransformer(
 	clock clock.Clock,
 	cachedDownloader cacheddownloader.CachedDownloader,
@@ -124,8 +337,13 @@ func NewTransformer(
 		healthyMonitoringInterval:   healthyMonitoringInterval,
 		unhealthyMonitoringInterval: unhealthyMonitoringInterval,
 		gracefulShutdownInterval:    gracefulShutdownInterval,
-		healthCheckWorkPool:         healthCheckWorkPool,
+		readinessWorkPool:           healthCheckWorkPool,
+		livenessWorkPool:            healthCheckWorkPool,
 		clock:                       clock,
+		onUnhealthyActionTimeout:    DefaultOnUnhealthyActionTimeout,
+		bytesDownloaded:             new(steps.TransferCounter),
+		bytesUploaded:               new(steps.TransferCounter),
+		partitionDirs:               &partitionDirs{users: map[string]int{}},
 	}
 
 	for _, o := range opts {
@@ -135,13 +353,20 @@ func NewTransformer(
 	return t
 }
 
+func (t *transformer) TotalBytesDownloaded() uint64 {
+	return t.bytesDownloaded.Total()
+}
+
+func (t *transformer) TotalBytesUploaded() uint64 {
+	return t.bytesUploaded.Total()
+}
+
 func (t *transformer) stepFor(
 	logStreamer log_streamer.LogStreamer,
 	action *models.Action,
 	container garden.Container,
-	externalIP string,
-	internalIP string,
-	ports []executor.PortMapping,
+	execContainer executor.Container,
+	containerDownloadLimiter chan struct{},
 	suppressExitStatusCode bool,
 	monitorOutputWrapper bool,
 	logger lager.Logger,
@@ -149,40 +374,71 @@ func (t *transformer) stepFor(
 	a := action.GetValue()
 	switch actionModel := a.(type) {
 	case *models.RunAction:
+		runAction := *actionModel
+		if t.interpolateEnv {
+			env, err := steps.InterpolateEnvironment(runAction.Env, steps.NewEnvTemplateData(execContainer))
+			if err != nil {
+				logger.Error("failed-to-interpolate-environment", err)
+				return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+					return err
+				})
+			}
+			runAction.Env = env
+		}
+
+		if t.processWrapperPath != "" && !monitorOutputWrapper && !execContainer.DisableProcessWrapper {
+			runAction = steps.WrapProcess(runAction, t.processWrapperPath)
+		}
+
 		return steps.NewRun(
 			container,
-			*actionModel,
+			runAction,
 			logStreamer.WithSource(actionModel.LogSource),
 			logger,
-			externalIP,
-			internalIP,
-			ports,
+			execContainer.ExternalIP,
+			execContainer.InternalIP,
+			execContainer.InstanceHostname(),
+			execContainer.Ports,
 			t.clock,
 			t.gracefulShutdownInterval,
 			suppressExitStatusCode,
+			t.proxyEnv,
 		)
 
 	case *models.DownloadAction:
+		downloadAction := *actionModel
+		downloadAction.CacheKey = executor.PartitionedCacheKey(execContainer.CachePartitionTag, downloadAction.CacheKey)
+
 		return steps.NewDownload(
 			container,
-			*actionModel,
+			downloadAction,
+			execContainer.SkipDownloadsIfPresent,
 			t.cachedDownloader,
+			t.ociFetcher,
+			t.compressedFetcher,
 			t.downloadLimiter,
+			containerDownloadLimiter,
+			t.maxDownloadSizeBytes,
+			t.artifactSizer,
+			t.bytesDownloaded,
 			logStreamer.WithSource(actionModel.LogSource),
 			logger,
 		)
 
 	case *models.UploadAction:
-		return steps.NewUpload(
+		tag := execContainer.CachePartitionTag
+		return t.partitionTempDir(tag, steps.NewUpload(
 			container,
 			*actionModel,
 			t.uploader,
 			t.compressor,
-			t.tempDir,
+			t.partitionDirs.path(t.tempDir, tag),
 			logStreamer.WithSource(actionModel.LogSource),
 			t.uploadLimiter,
+			t.tarSymlinkPolicy,
+			t.bytesUploaded,
 			logger,
-		)
+		))
 
 	case *models.EmitProgressAction:
 		return steps.NewEmitProgress(
@@ -190,9 +446,8 @@ func (t *transformer) stepFor(
 				logStreamer,
 				actionModel.Action,
 				container,
-				externalIP,
-				internalIP,
-				ports,
+				execContainer,
+				containerDownloadLimiter,
 				suppressExitStatusCode,
 				monitorOutputWrapper,
 				logger,
@@ -210,9 +465,8 @@ func (t *transformer) stepFor(
 				logStreamer.WithSource(actionModel.LogSource),
 				actionModel.Action,
 				container,
-				externalIP,
-				internalIP,
-				ports,
+				execContainer,
+				containerDownloadLimiter,
 				suppressExitStatusCode,
 				monitorOutputWrapper,
 				logger,
@@ -228,9 +482,8 @@ func (t *transformer) stepFor(
 				logStreamer.WithSource(actionModel.LogSource),
 				actionModel.Action,
 				container,
-				externalIP,
-				internalIP,
-				ports,
+				execContainer,
+				containerDownloadLimiter,
 				suppressExitStatusCode,
 				monitorOutputWrapper,
 				logger,
@@ -249,9 +502,8 @@ func (t *transformer) stepFor(
 					bufferedLogStreamer,
 					action,
 					container,
-					externalIP,
-					internalIP,
-					ports,
+					execContainer,
+					containerDownloadLimiter,
 					suppressExitStatusCode,
 					monitorOutputWrapper,
 					logger,
@@ -263,9 +515,8 @@ func (t *transformer) stepFor(
 					logStreamer.WithSource(actionModel.LogSource),
 					action,
 					container,
-					externalIP,
-					internalIP,
-					ports,
+					execContainer,
+					containerDownloadLimiter,
 					suppressExitStatusCode,
 					monitorOutputWrapper,
 					logger,
@@ -286,9 +537,8 @@ func (t *transformer) stepFor(
 					bufferedLogStreamer,
 					action,
 					container,
-					externalIP,
-					internalIP,
-					ports,
+					execContainer,
+					containerDownloadLimiter,
 					suppressExitStatusCode,
 					monitorOutputWrapper,
 					logger,
@@ -300,9 +550,8 @@ func (t *transformer) stepFor(
 					logStreamer.WithSource(actionModel.LogSource),
 					action,
 					container,
-					externalIP,
-					internalIP,
-					ports,
+					execContainer,
+					containerDownloadLimiter,
 					suppressExitStatusCode,
 					monitorOutputWrapper,
 					logger,
@@ -320,9 +569,8 @@ func (t *transformer) stepFor(
 				logStreamer,
 				action,
 				container,
-				externalIP,
-				internalIP,
-				ports,
+				execContainer,
+				containerDownloadLimiter,
 				suppressExitStatusCode,
 				monitorOutputWrapper,
 				logger,
@@ -334,6 +582,65 @@ func (t *transformer) stepFor(
 	panic(fmt.Sprintf("unknown action: %T", action))
 }
 
+// partitionTempDir makes sure the temp directory of the cache partition exists
+// while runner runs. The directory is removed once no step of the partition
+// uses it anymore.
+func (t *transformer) partitionTempDir(tag string, runner ifrit.Runner) ifrit.Runner {
+	if tag == "" {
+		return runner
+	}
+
+	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		err := t.partitionDirs.acquire(t.tempDir, tag)
+		if err != nil {
+			return err
+		}
+		defer t.partitionDirs.release(t.tempDir, tag)
+
+		return runner.Run(signals, ready)
+	})
+}
+
+// partitionDirs counts the running steps using each cache partition's temp
+// directory.
+type partitionDirs struct {
+	lock  sync.Mutex
+	users map[string]int
+}
+
+func (p *partitionDirs) path(tempDir, tag string) string {
+	if tag == "" {
+		return tempDir
+	}
+	return filepath.Join(tempDir, "partitions", tag)
+}
+
+func (p *partitionDirs) acquire(tempDir, tag string) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	err := os.MkdirAll(p.path(tempDir, tag), 0755)
+	if err != nil {
+		return err
+	}
+
+	p.users[tag]++
+	return nil
+}
+
+func (p *partitionDirs) release(tempDir, tag string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.users[tag]--
+	if p.users[tag] > 0 {
+		return
+	}
+
+	delete(p.users, tag)
+	os.RemoveAll(p.path(tempDir, tag))
+}
+
 func overrideSuppressLogOutput(monitorAction *models.Action) {
 	if monitorAction.RunAction != nil {
 		monitorAction.RunAction.SuppressLogOutput = false
@@ -357,6 +664,47 @@ func overrideSuppressLogOutput(monitorAction *models.Action) {
 		overrideSuppressLogOutput(monitorAction.TimeoutAction.Action)
 	}
 }
+
+// ValidateActionDAG returns ErrCyclicAction if the action contains itself,
+// directly or through any of its nested actions. Building steps for such an
+// action would otherwise recurse until the stack overflows.
+func ValidateActionDAG(action *models.Action) error {
+	return validateActionDAG(action, map[*models.Action]struct{}{})
+}
+
+func validateActionDAG(action *models.Action, path map[*models.Action]struct{}) error {
+	if action == nil {
+		return nil
+	}
+	if _, ok := path[action]; ok {
+		return ErrCyclicAction
+	}
+	path[action] = struct{}{}
+	defer delete(path, action)
+
+	var children []*models.Action
+	if action.TryAction != nil {
+		children = []*models.Action{action.TryAction.Action}
+	} else if action.ParallelAction != nil {
+		children = action.ParallelAction.Actions
+	} else if action.SerialAction != nil {
+		children = action.SerialAction.Actions
+	} else if action.CodependentAction != nil {
+		children = action.CodependentAction.Actions
+	} else if action.EmitProgressAction != nil {
+		children = []*models.Action{action.EmitProgressAction.Action}
+	} else if action.TimeoutAction != nil {
+		children = []*models.Action{action.TimeoutAction.Action}
+	}
+
+	for _, child := range children {
+		if err := validateActionDAG(child, path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (t *transformer) StepsRunner(
 	logger lager.Logger,
 	container executor.Container,
@@ -367,14 +715,45 @@ func (t *transformer) StepsRunner(
 	var setup, action, postSetup, monitor, longLivedAction ifrit.Runner
 	var substeps []ifrit.Runner
 
+	actions := []*models.Action{container.Setup, container.Action, container.Monitor}
+	for _, sidecar := range container.Sidecars {
+		actions = append(actions, sidecar.Action)
+	}
+	for _, a := range actions {
+		if err := ValidateActionDAG(a); err != nil {
+			logger.Error("steps-runner-invalid-action", err)
+			return nil, err
+		}
+	}
+
+	// the proxy is drained before the processes are signalled, so the drain
+	// comes out of their graceful shutdown interval
+	var proxyDrainTimeout time.Duration
+	if t.useContainerProxy && container.EnableContainerProxy && t.proxyDrainTimeout > 0 {
+		proxyDrainTimeout = t.proxyDrainTimeout
+		if proxyDrainTimeout > t.gracefulShutdownInterval {
+			proxyDrainTimeout = t.gracefulShutdownInterval
+		}
+
+		shortened := *t
+		shortened.gracefulShutdownInterval -= proxyDrainTimeout
+		t = &shortened
+	}
+
+	// shared by every download step of this container, on top of the global
+	// download limiter
+	var containerDownloadLimiter chan struct{}
+	if t.maxConcurrentDownloadsPerContainer > 0 {
+		containerDownloadLimiter = make(chan struct{}, t.maxConcurrentDownloadsPerContainer)
+	}
+
 	if container.Setup != nil {
 		setup = t.stepFor(
 			logStreamer,
 			container.Setup,
 			gardenContainer,
-			container.ExternalIP,
-			container.InternalIP,
-			container.Ports,
+			container,
+			containerDownloadLimiter,
 			false,
 			false,
 			logger.Session("setup"),
@@ -396,11 +775,17 @@ func (t *transformer) StepsRunner(
 			logger.Session("post-setup"),
 			container.ExternalIP,
 			container.InternalIP,
+			container.InstanceHostname(),
 			container.Ports,
 			t.clock,
 			t.gracefulShutdownInterval,
 			suppressExitStatusCode,
+			nil,
 		)
+
+		if t.postSetupHookTimeout > 0 {
+			postSetup = t.timeoutPostSetup(postSetup, logStreamer, logger)
+		}
 	}
 
 	if container.Action == nil {
@@ -413,9 +798,8 @@ func (t *transformer) StepsRunner(
 		logStreamer,
 		container.Action,
 		gardenContainer,
-		container.ExternalIP,
-		container.InternalIP,
-		container.Ports,
+		container,
+		containerDownloadLimiter,
 		false,
 		false,
 		logger.Session("action"),
@@ -427,9 +811,8 @@ func (t *transformer) StepsRunner(
 		substeps = append(substeps, t.stepFor(logStreamer,
 			sidecar.Action,
 			gardenContainer,
-			container.ExternalIP,
-			container.InternalIP,
-			container.Ports,
+			container,
+			containerDownloadLimiter,
 			false,
 			false,
 			logger.Session("sidecar"),
@@ -471,7 +854,7 @@ func (t *transformer) StepsRunner(
 			config.BindMounts,
 			proxyReadinessChecks,
 		)
-		substeps = append(substeps, monitor)
+		substeps = append(substeps, t.withOnUnhealthyAction(logger, logStreamer, gardenContainer, container, containerDownloadLimiter, monitor))
 	} else if container.Monitor != nil {
 		overrideSuppressLogOutput(container.Monitor)
 		monitor = steps.NewMonitor(
@@ -480,9 +863,8 @@ func (t *transformer) StepsRunner(
 					logStreamer,
 					container.Monitor,
 					gardenContainer,
-					container.ExternalIP,
-					container.InternalIP,
-					container.Ports,
+					container,
+					containerDownloadLimiter,
 					true,
 					true,
 					logger.Session("monitor-run"),
@@ -494,10 +876,19 @@ func (t *transformer) StepsRunner(
 			time.Duration(container.StartTimeoutMs)*time.Millisecond,
 			t.healthyMonitoringInterval,
 			t.unhealthyMonitoringInterval,
-			t.healthCheckWorkPool,
+			t.readinessWorkPool,
+			t.livenessWorkPool,
+			config.HealthCheckReporter,
 			proxyReadinessChecks...,
 		)
-		substeps = append(substeps, monitor)
+		substeps = append(substeps, t.withOnUnhealthyAction(logger, logStreamer, gardenContainer, container, containerDownloadLimiter, monitor))
+	}
+
+	if config.MonitorOnly {
+		if monitor == nil {
+			return nil, ErrNoMonitor
+		}
+		return monitor, nil
 	}
 
 	if len(substeps) > 1 {
@@ -515,6 +906,26 @@ func (t *transformer) StepsRunner(
 			config.BindMounts,
 		)
 		longLivedAction = steps.NewCodependent([]ifrit.Runner{longLivedAction, containerProxyStep}, false, true)
+
+		if proxyDrainTimeout > 0 {
+			longLivedAction = t.withProxyDrain(logger, container, gardenContainer, config.BindMounts, proxyDrainTimeout, longLivedAction)
+		}
+	}
+
+	if len(t.processWhitelist) > 0 {
+		allowedPaths := append(append([]string{}, executorProcessPaths...), t.processWhitelist...)
+		if t.processWrapperPath != "" {
+			allowedPaths = append(allowedPaths, t.processWrapperPath)
+		}
+
+		enforcer := steps.NewProcessWhitelistEnforcer(
+			gardenContainer,
+			allowedPaths,
+			t.processWhitelistInterval,
+			t.clock,
+			logger,
+		)
+		longLivedAction = steps.NewCodependent([]ifrit.Runner{longLivedAction, enforcer}, false, true)
 	}
 
 	var cumulativeStep ifrit.Runner
@@ -528,6 +939,11 @@ func (t *transformer) StepsRunner(
 		}
 	}
 
+	if len(config.EgressRules) > 0 {
+		firewallCheck := steps.NewEgressFirewallCheck(gardenContainer, config.EgressRules, logger)
+		cumulativeStep = steps.NewSerial([]ifrit.Runner{firewallCheck, cumulativeStep})
+	}
+
 	return cumulativeStep, nil
 }
 
@@ -576,6 +992,7 @@ func (t *transformer) createCheck(
 		ResourceLimits: &rl,
 		Path:           filepath.Join(HealthCheckDstPath, "healthcheck"),
 		Args:           args,
+		User:           t.healthCheckUser,
 	}
 
 	buffer := bytes.NewBuffer(nil)
@@ -592,6 +1009,7 @@ func (t *transformer) createCheck(
 		logger,
 		container.ExternalIP,
 		container.InternalIP,
+		container.InstanceHostname(),
 		container.Ports,
 		t.clock,
 		t.gracefulShutdownInterval,
@@ -646,6 +1064,19 @@ func (t *transformer) transformCheckDefinition(
 				path = "/"
 			}
 
+			if t.useExecutorHTTPHealthCheck {
+				url := fmt.Sprintf("http://%s:%d%s", container.InternalIP, check.HttpCheck.Port, path)
+				requestTimeout := time.Duration(timeout) * time.Millisecond
+
+				readinessChecks = append(readinessChecks, steps.NewEventuallySucceedsStep(func() ifrit.Runner {
+					return steps.NewThrottle(steps.NewHTTPHealthCheck(url, http.StatusOK, "", requestTimeout, t.clock, readinessLogger), t.readinessWorkPool)
+				}, t.unhealthyMonitoringInterval, time.Duration(container.StartTimeoutMs)*time.Millisecond, t.clock))
+				livenessChecks = append(livenessChecks, steps.NewConsistentlySucceedsStep(func() ifrit.Runner {
+					return steps.NewThrottle(steps.NewHTTPHealthCheck(url, http.StatusOK, "", requestTimeout, t.clock, livenessLogger), t.livenessWorkPool)
+				}, t.healthyMonitoringInterval, t.clock))
+				continue
+			}
+
 			readinessChecks = append(readinessChecks, t.createCheck(
 				container,
 				gardenContainer,
@@ -726,6 +1157,72 @@ func (t *transformer) transformCheckDefinition(
 	)
 }
 
+func (t *transformer) withOnUnhealthyAction(
+	logger lager.Logger,
+	logStreamer log_streamer.LogStreamer,
+	gardenContainer garden.Container,
+	container executor.Container,
+	containerDownloadLimiter chan struct{},
+	monitor ifrit.Runner,
+) ifrit.Runner {
+	if container.OnUnhealthyAction == nil {
+		return monitor
+	}
+
+	runAction := *container.OnUnhealthyAction
+	runAction.LogSource = DiagnosticLogSource
+
+	diagnostic := t.stepFor(
+		logStreamer,
+		models.WrapAction(&runAction),
+		gardenContainer,
+		container,
+		containerDownloadLimiter,
+		false,
+		false,
+		logger.Session("on-unhealthy-action"),
+	)
+	return steps.NewDiagnose(monitor, diagnostic, t.onUnhealthyActionTimeout, t.clock, logger)
+}
+
+// timeoutPostSetup interrupts the post-setup hook once it has run for longer
+// than the configured timeout, and reports the timeout to the app's logs.
+func (t *transformer) timeoutPostSetup(postSetup ifrit.Runner, logStreamer log_streamer.LogStreamer, logger lager.Logger) ifrit.Runner {
+	timeout := steps.NewTimeout(postSetup, t.postSetupHookTimeout, t.clock, logger.Session("post-setup"))
+
+	logTimeout := ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+		err := timeout.Run(signals, ready)
+		if emittable, ok := err.(*steps.EmittableError); ok && emittable.ExitReason() == executor.ExitReasonTimeout {
+			logger.Error("container-post-setup-timed-out", err, lager.Data{"timeout": t.postSetupHookTimeout.String()})
+		}
+		return err
+	})
+
+	return steps.NewEmitProgress(logTimeout, "", "", "Post-setup hook failed", logStreamer, logger)
+}
+
+func (t *transformer) withProxyDrain(
+	logger lager.Logger,
+	container executor.Container,
+	gardenContainer garden.Container,
+	bindMounts []garden.BindMount,
+	drainTimeout time.Duration,
+	step ifrit.Runner,
+) ifrit.Runner {
+	adminURL, err := t.proxyAdminURL(container)
+	if err != nil {
+		logger.Error("failed-to-get-proxy-admin-url", err)
+		return step
+	}
+
+	sidecar := steps.Sidecar{
+		Image:      garden.ImageRef{URI: t.sidecarRootFS},
+		BindMounts: bindMounts,
+	}
+	admin := steps.NewEnvoyAdmin(gardenContainer, sidecar, adminURL, DefaultProxyAdminRequestTimeout)
+	return steps.NewProxyDrain(step, admin, drainTimeout, t.clock, logger)
+}
+
 func (t *transformer) transformContainerProxyStep(
 	container garden.Container,
 	execContainer executor.Container,
@@ -757,6 +1254,7 @@ func (t *transformer) transformContainerProxyStep(
 		proxyLogger,
 		execContainer.ExternalIP,
 		execContainer.InternalIP,
+		execContainer.InstanceHostname(),
 		execContainer.Ports,
 		t.clock,
 		t.gracefulShutdownInterval,
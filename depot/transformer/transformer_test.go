@@ -3,20 +3,26 @@ package transformer_test
 import (
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/bbs/models"
+	cdfakes "code.cloudfoundry.org/cacheddownloader/cacheddownloaderfakes"
 	"code.cloudfoundry.org/clock/fakeclock"
 	mfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
+	"code.cloudfoundry.org/executor/depot/steps"
 	"code.cloudfoundry.org/executor/depot/transformer"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden/gardenfakes"
@@ -27,6 +33,7 @@ import (
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 	"github.com/onsi/gomega/gbytes"
+	"github.com/onsi/gomega/ghttp"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/ginkgomon"
 )
@@ -54,7 +61,7 @@ var _ = Describe("Transformer", func() {
 			fakeMetronClient = &mfakes.FakeIngressClient{}
 
 			logger = lagertest.NewTestLogger("test-container-store")
-			logStreamer = log_streamer.New("test", "test", 1, map[string]string{}, fakeMetronClient)
+			logStreamer = log_streamer.New("test", "test", 1, map[string]string{}, fakeMetronClient, 0)
 
 			healthyMonitoringInterval = 1 * time.Second
 			unhealthyMonitoringInterval = 1 * time.Millisecond
@@ -127,6 +134,187 @@ var _ = Describe("Transformer", func() {
 			})
 		})
 
+		Context("when only the monitor is wanted", func() {
+			var (
+				pathsLock sync.Mutex
+				paths     []string
+			)
+
+			BeforeEach(func() {
+				cfg.MonitorOnly = true
+				paths = nil
+				gardenContainer.RunStub = func(spec garden.ProcessSpec, _ garden.ProcessIO) (garden.Process, error) {
+					pathsLock.Lock()
+					paths = append(paths, spec.Path)
+					pathsLock.Unlock()
+					return &gardenfakes.FakeProcess{}, nil
+				}
+			})
+
+			It("runs the monitor without the setup or the action", func() {
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+				Eventually(process.Ready()).Should(BeClosed())
+				ginkgomon.Interrupt(process)
+
+				pathsLock.Lock()
+				defer pathsLock.Unlock()
+				Expect(paths).NotTo(BeEmpty())
+				for _, path := range paths {
+					Expect(path).To(Equal("/monitor/path"))
+				}
+			})
+
+			Context("when the container has no monitor", func() {
+				BeforeEach(func() {
+					container.Monitor = nil
+				})
+
+				It("returns ErrNoMonitor", func() {
+					_, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+					Expect(err).To(Equal(transformer.ErrNoMonitor))
+				})
+			})
+		})
+
+		Context("when the container has a cache partition tag", func() {
+			var fakeDownloader *cdfakes.FakeCachedDownloader
+
+			BeforeEach(func() {
+				fakeDownloader = new(cdfakes.FakeCachedDownloader)
+				fakeDownloader.FetchReturns(ioutil.NopCloser(strings.NewReader("")), 0, nil)
+
+				container.CachePartitionTag = "tenant-a"
+				container.Setup = nil
+				container.Monitor = nil
+				container.Action = &models.Action{
+					DownloadAction: &models.DownloadAction{
+						From:     "http://example.com/droplet.tgz",
+						To:       "/tmp/app",
+						CacheKey: "droplet",
+						User:     "vcap",
+					},
+				}
+			})
+
+			JustBeforeEach(func() {
+				optimusPrime = transformer.NewTransformer(
+					clock,
+					fakeDownloader, nil, nil, make(chan struct{}, 1), nil,
+					os.TempDir(),
+					healthyMonitoringInterval,
+					unhealthyMonitoringInterval,
+					gracefulShutdownInterval,
+					healthCheckWorkPool,
+					options...,
+				)
+			})
+
+			It("fetches downloads with the cache key namespaced by the tag", func() {
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(ifrit.Invoke(runner).Wait()).Should(Receive(BeNil()))
+
+				Expect(fakeDownloader.FetchCallCount()).To(Equal(1))
+				_, _, cacheKey, _, _ := fakeDownloader.FetchArgsForCall(0)
+				Expect(cacheKey).To(Equal(executor.PartitionedCacheKey("tenant-a", "droplet")))
+			})
+
+			Context("and the action uploads an artifact", func() {
+				var (
+					tempDir      string
+					partitionDir string
+				)
+
+				BeforeEach(func() {
+					var err error
+					tempDir, err = ioutil.TempDir("", "transformer")
+					Expect(err).NotTo(HaveOccurred())
+					partitionDir = filepath.Join(tempDir, "partitions", "tenant-a")
+
+					container.Action = &models.Action{
+						UploadAction: &models.UploadAction{
+							From: "/app/droplet.tgz",
+							To:   "http://example.com/droplet",
+							User: "vcap",
+						},
+					}
+				})
+
+				JustBeforeEach(func() {
+					optimusPrime = transformer.NewTransformer(
+						clock,
+						nil, nil, nil, nil, make(chan struct{}, 1),
+						tempDir,
+						healthyMonitoringInterval,
+						unhealthyMonitoringInterval,
+						gracefulShutdownInterval,
+						healthCheckWorkPool,
+						options...,
+					)
+				})
+
+				AfterEach(func() {
+					os.RemoveAll(tempDir)
+				})
+
+				It("uploads through a temp dir of the partition, which is removed afterwards", func() {
+					partitionDirExisted := false
+					gardenContainer.StreamOutStub = func(garden.StreamOutSpec) (io.ReadCloser, error) {
+						_, err := os.Stat(partitionDir)
+						partitionDirExisted = err == nil
+						return nil, errors.New("boom")
+					}
+
+					runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+					Expect(err).NotTo(HaveOccurred())
+
+					Eventually(ifrit.Invoke(runner).Wait()).Should(Receive(HaveOccurred()))
+
+					Expect(gardenContainer.StreamOutCallCount()).To(Equal(1))
+					Expect(partitionDirExisted).To(BeTrue())
+					Expect(partitionDir).NotTo(BeADirectory())
+				})
+			})
+		})
+
+		Context("when the action contains a cycle", func() {
+			BeforeEach(func() {
+				cyclic := &models.Action{}
+				cyclic.EmitProgressAction = &models.EmitProgressAction{
+					Action: &models.Action{
+						SerialAction: &models.SerialAction{
+							Actions: []*models.Action{container.Action, cyclic},
+						},
+					},
+				}
+				container.Action = cyclic
+			})
+
+			It("returns ErrCyclicAction without building any steps", func() {
+				_, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).To(Equal(transformer.ErrCyclicAction))
+			})
+		})
+
+		Context("when the same action is shared by several parents", func() {
+			BeforeEach(func() {
+				shared := container.Action
+				container.Action = &models.Action{
+					ParallelAction: &models.ParallelAction{
+						Actions: []*models.Action{shared, shared},
+					},
+				}
+			})
+
+			It("does not treat it as a cycle", func() {
+				Expect(transformer.ValidateActionDAG(container.Action)).To(Succeed())
+			})
+		})
+
 		Context("when there is a specified setup, post-setup, action, sidecars and monitor", func() {
 			BeforeEach(func() {
 				options = []transformer.Option{
@@ -210,6 +398,288 @@ var _ = Describe("Transformer", func() {
 			})
 		})
 
+		Context("when the container has egress rules", func() {
+			var rule garden.NetOutRule
+
+			BeforeEach(func() {
+				rule = garden.NetOutRule{Protocol: garden.ProtocolTCP}
+				cfg.EgressRules = []garden.NetOutRule{rule}
+			})
+
+			It("applies the rules before running setup", func() {
+				netOutCallsAtSetup := make(chan int, 1)
+				gardenContainer.RunStub = func(processSpec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+					if processSpec.Path == "/setup/path" {
+						netOutCallsAtSetup <- gardenContainer.NetOutCallCount()
+					}
+					return &gardenfakes.FakeProcess{}, nil
+				}
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+
+				Eventually(netOutCallsAtSetup).Should(Receive(Equal(1)))
+				Expect(gardenContainer.NetOutArgsForCall(0)).To(Equal(rule))
+
+				process.Signal(os.Interrupt)
+				clock.Increment(1 * time.Second)
+				Eventually(process.Wait()).Should(Receive())
+			})
+
+			Context("when a rule cannot be applied", func() {
+				BeforeEach(func() {
+					gardenContainer.NetOutReturns(errors.New("boom"))
+				})
+
+				It("fails without running setup", func() {
+					runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+					Expect(err).NotTo(HaveOccurred())
+
+					process := ifrit.Background(runner)
+					Eventually(process.Wait()).Should(Receive(Equal(steps.ErrFirewallSetupFailed)))
+					Expect(gardenContainer.RunCallCount()).To(Equal(0))
+				})
+			})
+		})
+
+		Context("when the post-setup hook does not exit before its timeout", func() {
+			var postSetupExitStatus chan int
+
+			BeforeEach(func() {
+				options = []transformer.Option{
+					transformer.WithPostSetupHook("jim", []string{"/post-setup/path"}),
+					transformer.WithPostSetupHookTimeout(time.Minute),
+				}
+				container.Setup = nil
+
+				postSetupExitStatus = make(chan int, 1)
+				postSetupProcess := &gardenfakes.FakeProcess{}
+				postSetupProcess.WaitStub = func() (int, error) {
+					return <-postSetupExitStatus, nil
+				}
+				postSetupProcess.SignalStub = func(garden.Signal) error {
+					postSetupExitStatus <- 143
+					return nil
+				}
+
+				gardenContainer.RunStub = func(processSpec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+					if processSpec.Path == "/post-setup/path" {
+						return postSetupProcess, nil
+					}
+					return &gardenfakes.FakeProcess{}, nil
+				}
+			})
+
+			It("interrupts the hook and never runs the action", func() {
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+				Eventually(gardenContainer.RunCallCount).Should(Equal(1))
+
+				clock.WaitForWatcherAndIncrement(time.Minute)
+
+				var runErr error
+				Eventually(process.Wait()).Should(Receive(&runErr))
+				Expect(runErr).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+				Expect(runErr.(*steps.EmittableError).ExitReason()).To(Equal(executor.ExitReasonTimeout))
+				Expect(gardenContainer.RunCallCount()).To(Equal(1))
+
+				Expect(logger).To(gbytes.Say("container-post-setup-timed-out"))
+
+				Eventually(fakeMetronClient.SendAppErrorLogCallCount).Should(Equal(1))
+				msg, _, _ := fakeMetronClient.SendAppErrorLogArgsForCall(0)
+				Expect(msg).To(Equal("Post-setup hook failed: exceeded 1m0s timeout"))
+			})
+		})
+
+		Context("when a process whitelist is configured", func() {
+			BeforeEach(func() {
+				options = append(options, transformer.WithProcessWhitelist([]string{"/action/path"}, time.Minute))
+				container.Setup = nil
+				container.Monitor = nil
+			})
+
+			It("stops the container when a process that is not whitelisted runs alongside the action", func() {
+				actionExitStatus := make(chan int, 1)
+				actionProcess := &gardenfakes.FakeProcess{}
+				actionProcess.WaitStub = func() (int, error) {
+					return <-actionExitStatus, nil
+				}
+				actionProcess.SignalStub = func(garden.Signal) error {
+					actionExitStatus <- 143
+					return nil
+				}
+
+				gardenContainer.RunStub = func(processSpec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+					if processSpec.Path == "/bin/sh" {
+						processIO.Stdout.Write([]byte("1 /tmp/garden-init\n7 /action/path\n12 /usr/bin/nc\n"))
+						return &gardenfakes.FakeProcess{}, nil
+					}
+					return actionProcess, nil
+				}
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+				Eventually(gardenContainer.RunCallCount).Should(Equal(1))
+
+				clock.WaitForWatcherAndIncrement(time.Minute)
+
+				var runErr error
+				Eventually(process.Wait()).Should(Receive(&runErr))
+				Expect(runErr).To(MatchError(ContainSubstring("/usr/bin/nc")))
+				Expect(gardenContainer.StopCallCount()).To(Equal(1))
+			})
+		})
+
+		Context("when a process wrapper is configured", func() {
+			BeforeEach(func() {
+				options = append(options, transformer.WithProcessWrapper("/usr/bin/tini"))
+				container.Setup = nil
+				container.Action = &models.Action{
+					RunAction: &models.RunAction{
+						Path: "/action/path",
+						Args: []string{"-a", "b"},
+						Dir:  "/home/vcap",
+						User: "vcap",
+						Env:  []*models.EnvironmentVariable{{Name: "FOO", Value: "bar"}},
+					},
+				}
+			})
+
+			runProcesses := func() (garden.ProcessSpec, garden.ProcessSpec) {
+				gardenContainer.RunReturns(&gardenfakes.FakeProcess{}, nil)
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).NotTo(HaveOccurred())
+				process := ifrit.Background(runner)
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(1))
+				clock.Increment(1 * time.Second)
+				Eventually(gardenContainer.RunCallCount).Should(Equal(2))
+
+				process.Signal(os.Interrupt)
+				clock.Increment(1 * time.Second)
+				Eventually(process.Wait()).Should(Receive())
+
+				actionSpec, _ := gardenContainer.RunArgsForCall(0)
+				monitorSpec, _ := gardenContainer.RunArgsForCall(1)
+				return actionSpec, monitorSpec
+			}
+
+			It("runs the action through the wrapper", func() {
+				actionSpec, _ := runProcesses()
+				Expect(actionSpec.Path).To(Equal("/usr/bin/tini"))
+				Expect(actionSpec.Args).To(Equal([]string{"--", "/action/path", "-a", "b"}))
+				Expect(actionSpec.Dir).To(Equal("/home/vcap"))
+				Expect(actionSpec.User).To(Equal("vcap"))
+				Expect(actionSpec.Env).To(ContainElement("FOO=bar"))
+			})
+
+			It("does not wrap the monitor", func() {
+				_, monitorSpec := runProcesses()
+				Expect(monitorSpec.Path).To(Equal("/monitor/path"))
+			})
+
+			Context("when the container opts out", func() {
+				BeforeEach(func() {
+					container.DisableProcessWrapper = true
+				})
+
+				It("runs the action unwrapped", func() {
+					actionSpec, _ := runProcesses()
+					Expect(actionSpec.Path).To(Equal("/action/path"))
+					Expect(actionSpec.Args).To(Equal([]string{"-a", "b"}))
+				})
+			})
+		})
+
+		Context("when proxy settings are configured", func() {
+			BeforeEach(func() {
+				options = append(options, transformer.WithProxyEnv("http://proxy.example.com:3128", "http://proxy.example.com:3129", ""))
+				container.Setup = nil
+				container.Action = &models.Action{
+					RunAction: &models.RunAction{
+						Path: "/action/path",
+						Env: []*models.EnvironmentVariable{
+							{Name: "FOO", Value: "bar"},
+							{Name: "https_proxy", Value: "http://app-proxy.example.com"},
+						},
+					},
+				}
+			})
+
+			It("exposes them to the action without overriding its own environment", func() {
+				gardenContainer.RunReturns(&gardenfakes.FakeProcess{}, nil)
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).NotTo(HaveOccurred())
+				process := ifrit.Background(runner)
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(1))
+				actionSpec, _ := gardenContainer.RunArgsForCall(0)
+				Expect(actionSpec.Env).To(ContainElement("http_proxy=http://proxy.example.com:3128"))
+				Expect(actionSpec.Env).To(ContainElement("https_proxy=http://app-proxy.example.com"))
+				Expect(actionSpec.Env).NotTo(ContainElement("https_proxy=http://proxy.example.com:3129"))
+				Expect(actionSpec.Env).To(ContainElement("FOO=bar"))
+				Expect(actionSpec.Env).NotTo(ContainElement(HavePrefix("no_proxy=")))
+
+				process.Signal(os.Interrupt)
+				clock.Increment(1 * time.Second)
+				Eventually(process.Wait()).Should(Receive())
+			})
+		})
+
+		Context("when the action environment contains template tokens", func() {
+			BeforeEach(func() {
+				container.Guid = "some-guid"
+				container.Setup = nil
+				container.Action = &models.Action{
+					RunAction: &models.RunAction{
+						Path: "/action/path",
+						Env: []*models.EnvironmentVariable{
+							{Name: "INSTANCE_GUID", Value: "{{.Guid}}"},
+						},
+					},
+				}
+			})
+
+			runAction := func() garden.ProcessSpec {
+				gardenContainer.RunReturns(&gardenfakes.FakeProcess{}, nil)
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer, cfg)
+				Expect(err).NotTo(HaveOccurred())
+				process := ifrit.Background(runner)
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(1))
+				actionSpec, _ := gardenContainer.RunArgsForCall(0)
+
+				process.Signal(os.Interrupt)
+				clock.Increment(1 * time.Second)
+				Eventually(process.Wait()).Should(Receive())
+
+				return actionSpec
+			}
+
+			It("leaves them untouched", func() {
+				Expect(runAction().Env).To(ContainElement("INSTANCE_GUID={{.Guid}}"))
+			})
+
+			Context("and env interpolation is enabled", func() {
+				BeforeEach(func() {
+					options = append(options, transformer.WithEnvInterpolation())
+				})
+
+				It("replaces them with the container metadata", func() {
+					Expect(runAction().Env).To(ContainElement("INSTANCE_GUID=some-guid"))
+				})
+			})
+		})
+
 		It("logs container setup time", func() {
 			gardenContainer.RunStub = func(processSpec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
 				if processSpec.Path == "/setup/path" {
@@ -383,10 +853,10 @@ var _ = Describe("Transformer", func() {
 					Args: args,
 
 					Env: []string{
-						"CF_INSTANCE_IP=10.0.0.1",
+						"CF_INSTANCE_ADDR=10.0.0.1:61001",
 						"CF_INSTANCE_INTERNAL_IP=11.0.0.1",
+						"CF_INSTANCE_IP=10.0.0.1",
 						"CF_INSTANCE_PORT=61001",
-						"CF_INSTANCE_ADDR=10.0.0.1:61001",
 						"CF_INSTANCE_PORTS=[{\"external\":61001,\"internal\":8080},{\"external\":61002,\"internal\":61001}]",
 					},
 					Image: garden.ImageRef{URI: "preloaded:cflinuxfs3"},
@@ -431,6 +901,58 @@ var _ = Describe("Transformer", func() {
 				})
 			})
 
+			Context("when proxy draining is enabled", func() {
+				var (
+					curlLock  sync.Mutex
+					curlSpecs []garden.ProcessSpec
+				)
+
+				curls := func() []garden.ProcessSpec {
+					curlLock.Lock()
+					defer curlLock.Unlock()
+					return append([]garden.ProcessSpec{}, curlSpecs...)
+				}
+
+				BeforeEach(func() {
+					curlSpecs = nil
+
+					runStub := gardenContainer.RunStub
+					gardenContainer.RunStub = func(spec garden.ProcessSpec, io garden.ProcessIO) (garden.Process, error) {
+						if spec.Path != "curl" {
+							return runStub(spec, io)
+						}
+
+						curlLock.Lock()
+						curlSpecs = append(curlSpecs, spec)
+						curlLock.Unlock()
+
+						if strings.HasSuffix(spec.Args[len(spec.Args)-1], "filter=downstream_cx_active") {
+							io.Stdout.Write([]byte(`{"stats":[{"name":"listener.0.0.0.0_61001.downstream_cx_active","value":0}]}`))
+						}
+						return &gardenfakes.FakeProcess{}, nil
+					}
+
+					options = append(options, transformer.WithProxyDrain(time.Second, func(executor.Container) (string, error) {
+						return "http://127.0.0.1:61003", nil
+					}))
+				})
+
+				It("drains the proxy from inside the container before the process is signalled", func() {
+					Eventually(gardenContainer.RunCallCount).Should(Equal(2))
+					process.Signal(os.Interrupt)
+					Eventually(curls).Should(HaveLen(2))
+
+					specs := curls()
+					Expect(specs[0].Args).To(ContainElement("POST"))
+					Expect(specs[0].Args[len(specs[0].Args)-1]).To(Equal("http://127.0.0.1:61003/healthcheck/fail"))
+					Expect(specs[1].Args[len(specs[1].Args)-1]).To(Equal("http://127.0.0.1:61003/stats?format=json&filter=downstream_cx_active"))
+					for _, spec := range specs {
+						Expect(spec.Image).To(Equal(garden.ImageRef{URI: "preloaded:cflinuxfs3"}))
+						Expect(spec.BindMounts).To(Equal(cfg.BindMounts))
+					}
+				})
+			})
+
 			Context("when the container is privileged", func() {
 				BeforeEach(func() {
 					container.Privileged = true
@@ -463,10 +985,10 @@ var _ = Describe("Transformer", func() {
 						Args: args,
 
 						Env: []string{
-							"CF_INSTANCE_IP=10.0.0.1",
+							"CF_INSTANCE_ADDR=10.0.0.1:61001",
 							"CF_INSTANCE_INTERNAL_IP=11.0.0.1",
+							"CF_INSTANCE_IP=10.0.0.1",
 							"CF_INSTANCE_PORT=61001",
-							"CF_INSTANCE_ADDR=10.0.0.1:61001",
 							"CF_INSTANCE_PORTS=[{\"external\":61001,\"internal\":8080},{\"external\":61002,\"internal\":61001}]",
 						},
 						Image: garden.ImageRef{URI: "preloaded:cflinuxfs3"},
@@ -511,6 +1033,8 @@ var _ = Describe("Transformer", func() {
 				actionCh                      chan int
 				monitorProcess                *gardenfakes.FakeProcess
 				monitorCh                     chan int
+				diagProcess                   *gardenfakes.FakeProcess
+				diagCh                        chan int
 				readinessIO                   chan garden.ProcessIO
 				livenessIO                    chan garden.ProcessIO
 				processLock                   sync.Mutex
@@ -543,6 +1067,9 @@ var _ = Describe("Transformer", func() {
 				monitorCh = make(chan int)
 				monitorProcess = makeProcess(monitorCh)
 
+				diagCh = make(chan int, 1)
+				diagProcess = makeProcess(diagCh)
+
 				healthcheckCallCount := int64(0)
 				gardenContainer.RunStub = func(spec garden.ProcessSpec, io garden.ProcessIO) (process garden.Process, err error) {
 					specsCh <- spec
@@ -568,6 +1095,8 @@ var _ = Describe("Transformer", func() {
 						}
 					case "/monitor/path":
 						return monitorProcess, nil
+					case "/diag/path":
+						return diagProcess, nil
 					}
 
 					err = errors.New("")
@@ -614,6 +1143,7 @@ var _ = Describe("Transformer", func() {
 				livenessCh <- 1 // the healthcheck in liveness mode can only exit by failing
 				close(actionCh)
 				close(monitorCh)
+				close(diagCh)
 				ginkgomon.Interrupt(process)
 			})
 
@@ -670,10 +1200,10 @@ var _ = Describe("Transformer", func() {
 									fmt.Sprintf("-readiness-timeout=%s", 1000*time.Millisecond),
 								},
 								Env: []string{
-									"CF_INSTANCE_IP=",
+									"CF_INSTANCE_ADDR=",
 									"CF_INSTANCE_INTERNAL_IP=",
+									"CF_INSTANCE_IP=",
 									"CF_INSTANCE_PORT=",
-									"CF_INSTANCE_ADDR=",
 									"CF_INSTANCE_PORTS=[]",
 								},
 								Limits: garden.ResourceLimits{
@@ -736,10 +1266,10 @@ var _ = Describe("Transformer", func() {
 									fmt.Sprintf("-readiness-timeout=%s", 1000*time.Millisecond),
 								},
 								Env: []string{
-									"CF_INSTANCE_IP=",
+									"CF_INSTANCE_ADDR=",
 									"CF_INSTANCE_INTERNAL_IP=",
+									"CF_INSTANCE_IP=",
 									"CF_INSTANCE_PORT=",
-									"CF_INSTANCE_ADDR=",
 									"CF_INSTANCE_PORTS=[]",
 								},
 								Limits: garden.ResourceLimits{
@@ -776,10 +1306,10 @@ var _ = Describe("Transformer", func() {
 								fmt.Sprintf("-readiness-timeout=%s", 1000*time.Millisecond),
 							},
 							Env: []string{
-								"CF_INSTANCE_IP=",
+								"CF_INSTANCE_ADDR=",
 								"CF_INSTANCE_INTERNAL_IP=",
+								"CF_INSTANCE_IP=",
 								"CF_INSTANCE_PORT=",
-								"CF_INSTANCE_ADDR=",
 								"CF_INSTANCE_PORTS=[]",
 							},
 							Limits: garden.ResourceLimits{
@@ -803,6 +1333,44 @@ var _ = Describe("Transformer", func() {
 						})))
 					})
 
+					Context("and the executor performs http checks itself", func() {
+						var server *ghttp.Server
+
+						BeforeEach(func() {
+							server = ghttp.NewServer()
+							server.AllowUnhandledRequests = true
+							server.RouteToHandler("GET", "/some/path", ghttp.RespondWith(http.StatusOK, nil))
+
+							host, port, err := net.SplitHostPort(server.Addr())
+							Expect(err).NotTo(HaveOccurred())
+							portNumber, err := strconv.Atoi(port)
+							Expect(err).NotTo(HaveOccurred())
+
+							container.InternalIP = host
+							container.CheckDefinition.Checks[0].HttpCheck.Port = uint32(portNumber)
+							options = append(options, transformer.WithExecutorHTTPHealthchecks())
+						})
+
+						AfterEach(func() {
+							server.Close()
+						})
+
+						It("requests the check path on the container's internal ip instead of starting a sidecar", func() {
+							clock.WaitForWatcherAndIncrement(unhealthyMonitoringInterval)
+							Eventually(server.ReceivedRequests).ShouldNot(BeEmpty())
+							Expect(server.ReceivedRequests()[0].URL.Path).To(Equal("/some/path"))
+
+							Consistently(func() []string {
+								paths := []string{}
+								for i := 0; i < gardenContainer.RunCallCount(); i++ {
+									spec, _ := gardenContainer.RunArgsForCall(i)
+									paths = append(paths, spec.Path)
+								}
+								return paths
+							}).ShouldNot(ContainElement(filepath.Join(transformer.HealthCheckDstPath, "healthcheck")))
+						})
+					})
+
 					Context("when the container is privileged", func() {
 						BeforeEach(func() {
 							container.Privileged = true
@@ -820,10 +1388,10 @@ var _ = Describe("Transformer", func() {
 									fmt.Sprintf("-readiness-timeout=%s", 1000*time.Millisecond),
 								},
 								Env: []string{
-									"CF_INSTANCE_IP=",
+									"CF_INSTANCE_ADDR=",
 									"CF_INSTANCE_INTERNAL_IP=",
+									"CF_INSTANCE_IP=",
 									"CF_INSTANCE_PORT=",
-									"CF_INSTANCE_ADDR=",
 									"CF_INSTANCE_PORTS=[]",
 								},
 								Limits: garden.ResourceLimits{
@@ -848,6 +1416,24 @@ var _ = Describe("Transformer", func() {
 						})
 					})
 
+					Context("when a health check user is configured", func() {
+						BeforeEach(func() {
+							options = append(options, transformer.WithHealthCheckUser("vcap"))
+						})
+
+						It("runs the healthcheck as that user", func() {
+							Eventually(gardenContainer.RunCallCount).Should(Equal(2))
+							users := map[string]string{}
+							for i := 0; i < gardenContainer.RunCallCount(); i++ {
+								spec, _ := gardenContainer.RunArgsForCall(i)
+								users[spec.Path] = spec.User
+							}
+
+							Expect(users).To(HaveKeyWithValue(filepath.Join(transformer.HealthCheckDstPath, "healthcheck"), "vcap"))
+							Expect(users).To(HaveKeyWithValue("/action/path", ""))
+						})
+					})
+
 					Context("and the starttimeout is set to 0", func() {
 						BeforeEach(func() {
 							container.StartTimeoutMs = 0
@@ -1032,6 +1618,40 @@ var _ = Describe("Transformer", func() {
 								Eventually(process.Wait()).Should(Receive(MatchError(ContainSubstring("Instance became unhealthy: liveness check failed"))))
 							})
 						})
+
+						Context("when the liveness check exits and an on-unhealthy action is set", func() {
+							BeforeEach(func() {
+								container.OnUnhealthyAction = &models.RunAction{Path: "/diag/path"}
+							})
+
+							JustBeforeEach(func() {
+								Eventually(gardenContainer.RunCallCount).Should(Equal(3))
+								Eventually(livenessIO).Should(Receive())
+								livenessCh <- 1
+							})
+
+							It("runs the action before the container is stopped", func() {
+								Eventually(gardenContainer.RunCallCount).Should(Equal(4))
+								spec, _ := gardenContainer.RunArgsForCall(3)
+								Expect(spec.Path).To(Equal("/diag/path"))
+								Consistently(actionProcess.SignalCallCount).Should(Equal(0))
+
+								diagCh <- 0
+								Eventually(actionProcess.SignalCallCount).Should(Equal(1))
+								actionCh <- 2
+								Eventually(process.Wait()).Should(Receive(MatchError(ContainSubstring("Instance became unhealthy"))))
+							})
+
+							It("stops the container once the action times out", func() {
+								Eventually(gardenContainer.RunCallCount).Should(Equal(4))
+								clock.WaitForWatcherAndIncrement(transformer.DefaultOnUnhealthyActionTimeout)
+
+								Eventually(diagProcess.SignalCallCount).Should(Equal(1))
+								diagCh <- 143
+								Eventually(actionProcess.SignalCallCount).Should(Equal(1))
+								actionCh <- 2
+							})
+						})
 					})
 				})
 
@@ -0,0 +1,95 @@
+package uploader
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+type UploadErrorCategory string
+
+const (
+	UploadErrorConnect UploadErrorCategory = "connect-failure"
+	UploadErrorTLS     UploadErrorCategory = "tls-failure"
+	UploadErrorHTTP4xx UploadErrorCategory = "http-4xx"
+	UploadErrorHTTP5xx UploadErrorCategory = "http-5xx"
+	UploadErrorTimeout UploadErrorCategory = "timeout"
+)
+
+// UploadError is returned when the blobstore could not be reached or refused
+// an upload. Error returns the underlying error, which may include the
+// destination URL; Message never does.
+type UploadError struct {
+	Category   UploadErrorCategory
+	StatusCode int
+	Err        error
+}
+
+func (e *UploadError) Error() string {
+	return e.Err.Error()
+}
+
+// Message describes the failure without revealing the destination URL, so
+// that it is safe to show to users.
+func (e *UploadError) Message() string {
+	switch e.Category {
+	case UploadErrorConnect:
+		return "Failed to connect to blobstore"
+	case UploadErrorTLS:
+		return "Failed to establish a secure connection to blobstore"
+	case UploadErrorHTTP4xx:
+		return fmt.Sprintf("Blobstore rejected upload with status %d", e.StatusCode)
+	case UploadErrorHTTP5xx:
+		return fmt.Sprintf("Blobstore failed upload with status %d", e.StatusCode)
+	case UploadErrorTimeout:
+		return "Timed out uploading to blobstore"
+	default:
+		return "Failed to upload payload"
+	}
+}
+
+func newStatusCodeError(statusCode int) *UploadError {
+	category := UploadErrorHTTP4xx
+	if statusCode >= 500 {
+		category = UploadErrorHTTP5xx
+	}
+
+	return &UploadError{
+		Category:   category,
+		StatusCode: statusCode,
+		Err:        fmt.Errorf("Upload failed: Status code %d", statusCode),
+	}
+}
+
+// classifyRequestError categorizes an error returned by the http client
+// before any response was received.
+func classifyRequestError(err error) *UploadError {
+	cause := err
+	if urlErr, ok := cause.(*url.Error); ok {
+		cause = urlErr.Err
+	}
+
+	category := UploadErrorConnect
+	if netErr, ok := cause.(net.Error); ok && netErr.Timeout() {
+		category = UploadErrorTimeout
+	} else if isTLSError(cause) {
+		category = UploadErrorTLS
+	}
+
+	return &UploadError{Category: category, Err: err}
+}
+
+func isTLSError(err error) bool {
+	switch err.(type) {
+	case x509.UnknownAuthorityError, x509.CertificateInvalidError, x509.HostnameError, tls.RecordHeaderError:
+		return true
+	}
+
+	if opErr, ok := err.(*net.OpError); ok {
+		err = opErr.Err
+	}
+	return strings.Contains(err.Error(), "tls:")
+}
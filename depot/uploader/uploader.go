@@ -4,6 +4,7 @@ import (
 	"crypto/md5"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -12,12 +13,24 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	loggingclient "code.cloudfoundry.org/diego-logging-client"
+	loggregator "code.cloudfoundry.org/go-loggregator"
 	"code.cloudfoundry.org/lager"
 )
 
+const UploadFailuresMetric = "UploadFailures"
+
+// UploadOffsetHeader is set by a server that supports resumable uploads, in
+// answer to a HEAD, to the number of bytes of the upload it has stored. It is
+// sent back with the remaining bytes.
+const UploadOffsetHeader = "Upload-Offset"
+
 var ErrUploadCancelled = errors.New("upload cancelled")
+var ErrUploadSizeMismatch = errors.New("uploaded content does not match the local content")
 
 type Uploader interface {
 	Upload(fileLocation string, destinationUrl *url.URL, cancel <-chan struct{}) (int64, error)
@@ -28,11 +41,17 @@ type URLUploader struct {
 	tlsConfig  *tls.Config
 	transport  *http.Transport
 	logger     lager.Logger
+	resume     bool
+
+	metronClient loggingclient.IngressClient
 }
 
-func New(logger lager.Logger, timeout time.Duration, tlsConfig *tls.Config) Uploader {
+// New returns an Uploader. If resume is set, a retried upload to a server
+// that reports a partial upload through UploadOffsetHeader only sends the
+// bytes the server does not already have.
+func New(logger lager.Logger, timeout time.Duration, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error), resume bool, metronClient loggingclient.IngressClient) Uploader {
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: proxy,
 		Dial: (&net.Dialer{
 			Timeout:   10 * time.Second,
 			KeepAlive: 30 * time.Second,
@@ -51,6 +70,9 @@ func New(logger lager.Logger, timeout time.Duration, tlsConfig *tls.Config) Uplo
 		tlsConfig:  tlsConfig,
 		transport:  transport,
 		logger:     logger.Session("URLUploader"),
+		resume:     resume,
+
+		metronClient: metronClient,
 	}
 }
 
@@ -63,15 +85,28 @@ func (uploader *URLUploader) Upload(fileLocation string, url *url.URL, cancel <-
 	}
 	defer sourceFile.Close()
 
+	presigned := isPresignedS3URL(url)
+	if presigned {
+		logger = logger.WithData(lager.Data{"presigned": true})
+	}
+
 UPLOAD_ATTEMPTS:
 	for attempt := 0; attempt < 3; attempt++ {
 		logger := logger.WithData(lager.Data{"attempt": attempt})
 		logger.Info("uploading")
+
+		var offset int64
+		if attempt > 0 && uploader.resume && !presigned {
+			offset = uploader.resumeOffset(url.String(), bytesToUpload, logger)
+		}
+
 		err = uploader.attemptUpload(
 			sourceFile,
+			offset,
 			bytesToUpload,
 			contentMD5,
 			url.String(),
+			presigned,
 			cancel,
 			logger,
 		)
@@ -89,6 +124,9 @@ UPLOAD_ATTEMPTS:
 
 	if err != nil {
 		logger.Error("failed-all-upload-attempts", err)
+		if uploadErr, ok := err.(*UploadError); ok {
+			uploader.emitFailure(logger, uploadErr)
+		}
 		return 0, err
 	}
 
@@ -120,29 +158,98 @@ func (uploader *URLUploader) prepareFileForUpload(fileLocation string, logger la
 	return sourceFile, fileInfo.Size(), contentMD5, nil
 }
 
+// resumeOffset asks the server how much of this upload it already holds. It
+// returns 0, meaning the whole file must be sent, unless the server takes part
+// in the resumable upload protocol and reports a partial upload through the
+// Upload-Offset header. The size of whatever already exists at the URL says
+// nothing about this upload, so Content-Length is deliberately ignored.
+func (uploader *URLUploader) resumeOffset(url string, bytesToUpload int64, logger lager.Logger) int64 {
+	resp, err := uploader.httpClient.Head(url)
+	if err != nil {
+		logger.Error("failed-to-query-partial-upload", err)
+		return 0
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0
+	}
+
+	received, err := strconv.ParseInt(resp.Header.Get(UploadOffsetHeader), 10, 64)
+	if err != nil || received <= 0 || received >= bytesToUpload {
+		return 0
+	}
+
+	logger.Info("resuming-upload", lager.Data{"offset": received})
+	return received
+}
+
+// tailMD5 returns the base64 encoded MD5 of the file from offset onwards.
+func tailMD5(sourceFile *os.File, offset int64) (string, error) {
+	_, err := sourceFile.Seek(offset, 0)
+	if err != nil {
+		return "", err
+	}
+
+	contentHash := md5.New()
+	_, err = io.Copy(contentHash, sourceFile)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(contentHash.Sum(nil)), nil
+}
+
 func (uploader *URLUploader) attemptUpload(
 	sourceFile *os.File,
+	offset int64,
 	bytesToUpload int64,
 	contentMD5 string,
 	url string,
+	presigned bool,
 	cancelCh <-chan struct{},
 	logger lager.Logger,
 ) error {
-	_, err := sourceFile.Seek(0, 0)
+	if offset > 0 {
+		var err error
+		contentMD5, err = tailMD5(sourceFile, offset)
+		if err != nil {
+			logger.Error("failed-to-hash-remaining-bytes", err)
+			return err
+		}
+	}
+
+	_, err := sourceFile.Seek(offset, 0)
 	if err != nil {
 		logger.Error("failed-seek", err)
 		return err
 	}
 
-	request, err := http.NewRequest("POST", url, ioutil.NopCloser(sourceFile))
+	method := "POST"
+	if presigned {
+		method = "PUT"
+	}
+
+	request, err := http.NewRequest(method, url, ioutil.NopCloser(sourceFile))
 	if err != nil {
 		logger.Error("somehow-failed-to-create-request", err)
 		return err
 	}
 
-	request.ContentLength = bytesToUpload
-	request.Header.Set("Content-Type", "application/octet-stream")
-	request.Header.Set("Content-MD5", contentMD5)
+	request.ContentLength = bytesToUpload - offset
+
+	// Headers that were not part of the signature invalidate a pre-signed URL,
+	// so the content is verified against the returned ETag instead. A resumed
+	// upload carries the MD5 of the bytes actually sent.
+	if !presigned {
+		request.Header.Set("Content-Type", "application/octet-stream")
+		request.Header.Set("Content-MD5", contentMD5)
+	}
+
+	if offset > 0 {
+		request.Header.Set(UploadOffsetHeader, strconv.FormatInt(offset, 10))
+		request.Header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", offset, bytesToUpload-1, bytesToUpload))
+	}
 
 	var resp *http.Response
 	reqComplete := make(chan error)
@@ -160,7 +267,7 @@ func (uploader *URLUploader) attemptUpload(
 		return ErrUploadCancelled
 	case err := <-reqComplete:
 		if err != nil {
-			return err
+			return classifyRequestError(err)
 		}
 	}
 
@@ -168,7 +275,52 @@ func (uploader *URLUploader) attemptUpload(
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("Upload failed: Status code %d", resp.StatusCode)
+		return newStatusCodeError(resp.StatusCode)
+	}
+
+	if presigned {
+		return verifyETag(resp.Header.Get("ETag"), contentMD5, logger)
+	}
+
+	return nil
+}
+
+func (uploader *URLUploader) emitFailure(logger lager.Logger, uploadErr *UploadError) {
+	tags := map[string]string{"category": string(uploadErr.Category)}
+	if uploadErr.StatusCode != 0 {
+		tags["status_code"] = fmt.Sprintf("%d", uploadErr.StatusCode)
+	}
+
+	err := uploader.metronClient.SendMetric(UploadFailuresMetric, 1, loggregator.WithEnvelopeTags(tags))
+	if err != nil {
+		logger.Error("failed-to-send-upload-failures-metric", err)
+	}
+}
+
+// isPresignedS3URL reports whether url carries an AWS query string signature,
+// either signature version 4 or version 2. Such URLs only accept a single-part
+// PUT.
+func isPresignedS3URL(url *url.URL) bool {
+	query := url.Query()
+	return query.Get("X-Amz-Signature") != "" || (query.Get("Signature") != "" && query.Get("Expires") != "")
+}
+
+// verifyETag compares the ETag of a single-part S3 upload, which is the hex
+// encoded MD5 of the content, with the MD5 computed before uploading.
+func verifyETag(etag, contentMD5 string, logger lager.Logger) error {
+	if etag == "" {
+		logger.Info("missing-etag")
+		return nil
+	}
+
+	rawMD5, err := base64.StdEncoding.DecodeString(contentMD5)
+	if err != nil {
+		return err
+	}
+
+	if strings.Trim(etag, `"`) != hex.EncodeToString(rawMD5) {
+		logger.Error("etag-mismatch", ErrUploadSizeMismatch, lager.Data{"etag": etag})
+		return ErrUploadSizeMismatch
 	}
 
 	return nil
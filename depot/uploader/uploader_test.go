@@ -4,6 +4,8 @@ import (
 	"crypto/md5"
 	"crypto/tls"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -14,7 +16,9 @@ import (
 	"sync"
 	"time"
 
+	mfakes "code.cloudfoundry.org/diego-logging-client/testhelpers"
 	"code.cloudfoundry.org/executor/depot/uploader"
+	"code.cloudfoundry.org/go-loggregator/rpc/loggregator_v2"
 	"code.cloudfoundry.org/lager/lagertest"
 	"code.cloudfoundry.org/tlsconfig"
 
@@ -30,11 +34,12 @@ var _ = Describe("Uploader", func() {
 		serverRequests    []*http.Request
 		serverRequestBody []string
 
-		logger        *lagertest.TestLogger
-		url           *url.URL
-		file          *os.File
-		expectedBytes int
-		expectedMD5   string
+		logger           *lagertest.TestLogger
+		fakeMetronClient *mfakes.FakeIngressClient
+		url              *url.URL
+		file             *os.File
+		expectedBytes    int
+		expectedMD5      string
 	)
 
 	BeforeEach(func() {
@@ -42,6 +47,7 @@ var _ = Describe("Uploader", func() {
 		serverRequestBody = []string{}
 		serverRequests = []*http.Request{}
 		logger = lagertest.NewTestLogger("test")
+		fakeMetronClient = &mfakes.FakeIngressClient{}
 
 		file, _ = ioutil.TempFile("", "foo")
 		contentString := "content that we can check later"
@@ -61,7 +67,7 @@ var _ = Describe("Uploader", func() {
 
 	Describe("Insecure Upload", func() {
 		BeforeEach(func() {
-			upldr = uploader.New(logger, 100*time.Millisecond, nil)
+			upldr = uploader.New(logger, 100*time.Millisecond, nil, nil, false, fakeMetronClient)
 		})
 
 		Context("when the upload is successful", func() {
@@ -131,7 +137,7 @@ var _ = Describe("Uploader", func() {
 			})
 
 			It("interrupts the client and returns an error", func() {
-				upldrWithoutTimeout := uploader.New(logger, 0, nil)
+				upldrWithoutTimeout := uploader.New(logger, 0, nil, nil, false, fakeMetronClient)
 
 				cancel := make(chan struct{})
 				errs := make(chan error)
@@ -187,7 +193,9 @@ var _ = Describe("Uploader", func() {
 
 				Eventually(logger.TestSink.Buffer).Should(gbytes.Say("failed-upload"))
 
-				Expect(<-errs).To(HaveOccurred())
+				err := <-errs
+				Expect(err).To(BeAssignableToTypeOf(&uploader.UploadError{}))
+				Expect(err.(*uploader.UploadError).Category).To(Equal(uploader.UploadErrorTimeout))
 			})
 		})
 
@@ -203,6 +211,14 @@ var _ = Describe("Uploader", func() {
 				_, err := upldr.Upload(file.Name(), url, nil)
 				Expect(err).NotTo(BeNil())
 			})
+
+			It("classifies the error as a connect failure", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).To(BeAssignableToTypeOf(&uploader.UploadError{}))
+				uploadErr := err.(*uploader.UploadError)
+				Expect(uploadErr.Category).To(Equal(uploader.UploadErrorConnect))
+				Expect(uploadErr.Message()).NotTo(ContainSubstring("127.0.0.1"))
+			})
 		})
 
 		Context("when the upload fails with a status code error", func() {
@@ -217,6 +233,241 @@ var _ = Describe("Uploader", func() {
 				_, err := upldr.Upload(file.Name(), url, nil)
 				Expect(err).NotTo(BeNil())
 			})
+
+			It("classifies the error as a 4xx rejection", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).To(Equal(&uploader.UploadError{
+					Category:   uploader.UploadErrorHTTP4xx,
+					StatusCode: http.StatusNotFound,
+					Err:        errors.New("Upload failed: Status code 404"),
+				}))
+				Expect(err.(*uploader.UploadError).Message()).To(Equal("Blobstore rejected upload with status 404"))
+			})
+
+			It("emits an UploadFailures metric tagged with the category", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).To(HaveOccurred())
+
+				Expect(fakeMetronClient.SendMetricCallCount()).To(Equal(1))
+				name, value, opts := fakeMetronClient.SendMetricArgsForCall(0)
+				Expect(name).To(Equal(uploader.UploadFailuresMetric))
+				Expect(value).To(Equal(1))
+
+				envelope := &loggregator_v2.Envelope{Tags: map[string]string{}}
+				for _, opt := range opts {
+					opt(envelope)
+				}
+				Expect(envelope.Tags).To(Equal(map[string]string{
+					"category":    "http-4xx",
+					"status_code": "404",
+				}))
+			})
+		})
+
+		Context("when the blobstore fails with a server error", func() {
+			BeforeEach(func() {
+				testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusBadGateway)
+				}))
+
+				serverUrl := testServer.URL + "/somepath"
+				url, _ = url.Parse(serverUrl)
+			})
+
+			It("classifies the error as a 5xx failure", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).To(BeAssignableToTypeOf(&uploader.UploadError{}))
+				uploadErr := err.(*uploader.UploadError)
+				Expect(uploadErr.Category).To(Equal(uploader.UploadErrorHTTP5xx))
+				Expect(uploadErr.Message()).To(Equal("Blobstore failed upload with status 502"))
+			})
+		})
+	})
+
+	Describe("Pre-signed S3 Upload", func() {
+		var etag string
+
+		BeforeEach(func() {
+			upldr = uploader.New(logger, 100*time.Millisecond, nil, nil, false, fakeMetronClient)
+			etag = ""
+
+			testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				serverRequests = append(serverRequests, r)
+
+				data, err := ioutil.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				serverRequestBody = append(serverRequestBody, string(data))
+
+				if r.Method != "PUT" || r.Header.Get("Content-MD5") != "" || r.ContentLength != int64(len(data)) {
+					w.WriteHeader(http.StatusForbidden)
+					return
+				}
+
+				responseETag := etag
+				if responseETag == "" {
+					rawMD5 := md5.Sum(data)
+					responseETag = `"` + hex.EncodeToString(rawMD5[:]) + `"`
+				}
+				w.Header().Set("ETag", responseETag)
+			}))
+
+			url, _ = url.Parse(testServer.URL + "/bucket/droplet?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Signature=abc123")
+		})
+
+		It("uploads the file with a single-part PUT", func() {
+			numBytes, err := upldr.Upload(file.Name(), url, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(numBytes).To(Equal(int64(expectedBytes)))
+
+			Expect(serverRequests).To(HaveLen(1))
+			request := serverRequests[0]
+			Expect(request.Method).To(Equal("PUT"))
+			Expect(request.URL.Path).To(Equal("/bucket/droplet"))
+			Expect(request.URL.Query().Get("X-Amz-Signature")).To(Equal("abc123"))
+			Expect(request.Header.Get("Content-MD5")).To(BeEmpty())
+			Expect(request.Header.Get("Content-Type")).To(BeEmpty())
+			Expect(request.TransferEncoding).To(BeEmpty())
+			Expect(strconv.Atoi(request.Header.Get("Content-Length"))).To(BeNumerically("==", 31))
+			Expect(serverRequestBody[0]).To(Equal("content that we can check later"))
+		})
+
+		Context("when the returned ETag does not match the content", func() {
+			BeforeEach(func() {
+				etag = `"0123456789abcdef0123456789abcdef"`
+			})
+
+			It("returns ErrUploadSizeMismatch", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).To(Equal(uploader.ErrUploadSizeMismatch))
+			})
+		})
+
+		Context("when the url has a signature version 2 query string", func() {
+			BeforeEach(func() {
+				url, _ = url.Parse(testServer.URL + "/bucket/droplet?AWSAccessKeyId=some-key&Expires=1700000000&Signature=abc123")
+			})
+
+			It("uploads the file with a single-part PUT", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(serverRequests[0].Method).To(Equal("PUT"))
+			})
+		})
+
+		Context("when the url only carries a signature without an expiry", func() {
+			BeforeEach(func() {
+				url, _ = url.Parse(testServer.URL + "/bucket/droplet?Signature=abc123")
+			})
+
+			It("keeps using a POST", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(serverRequests[0].Method).To(Equal("POST"))
+			})
+		})
+
+		Context("when the url is not pre-signed", func() {
+			BeforeEach(func() {
+				url, _ = url.Parse(testServer.URL + "/bucket/droplet")
+			})
+
+			It("keeps using a POST", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).To(HaveOccurred())
+				Expect(serverRequests[0].Method).To(Equal("POST"))
+			})
+		})
+	})
+
+	Describe("Resumable Upload", func() {
+		var (
+			resume        bool
+			reportOffset  bool
+			receivedBytes string
+		)
+
+		BeforeEach(func() {
+			resume = true
+			reportOffset = true
+			receivedBytes = ""
+
+			testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == "HEAD" {
+					w.Header().Set("Content-Length", strconv.Itoa(len(receivedBytes)))
+					if reportOffset {
+						w.Header().Set(uploader.UploadOffsetHeader, strconv.Itoa(len(receivedBytes)))
+					}
+					return
+				}
+
+				serverRequests = append(serverRequests, r)
+
+				data, err := ioutil.ReadAll(r.Body)
+				Expect(err).NotTo(HaveOccurred())
+				serverRequestBody = append(serverRequestBody, string(data))
+
+				if len(serverRequests) == 1 {
+					receivedBytes = string(data[:10])
+					w.WriteHeader(http.StatusBadGateway)
+				}
+			}))
+
+			url, _ = url.Parse(testServer.URL + "/somepath")
+		})
+
+		JustBeforeEach(func() {
+			upldr = uploader.New(logger, 100*time.Millisecond, nil, nil, resume, fakeMetronClient)
+		})
+
+		It("only sends the bytes the server does not have when retrying", func() {
+			numBytes, err := upldr.Upload(file.Name(), url, nil)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(numBytes).To(Equal(int64(expectedBytes)))
+
+			Expect(serverRequests).To(HaveLen(2))
+			Expect(serverRequests[0].Method).To(Equal("POST"))
+			Expect(serverRequests[0].Header.Get("Content-Range")).To(BeEmpty())
+
+			tailHash := md5.Sum([]byte("at we can check later"))
+
+			request := serverRequests[1]
+			Expect(request.Method).To(Equal("POST"))
+			Expect(request.Header.Get(uploader.UploadOffsetHeader)).To(Equal("10"))
+			Expect(request.Header.Get("Content-Range")).To(Equal("bytes 10-30/31"))
+			Expect(request.Header.Get("Content-MD5")).To(Equal(base64.StdEncoding.EncodeToString(tailHash[:])))
+			Expect(strconv.Atoi(request.Header.Get("Content-Length"))).To(BeNumerically("==", 21))
+			Expect(serverRequestBody[1]).To(Equal("at we can check later"))
+		})
+
+		Context("when the server does not report an upload offset", func() {
+			BeforeEach(func() {
+				reportOffset = false
+			})
+
+			It("sends the whole file again", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(serverRequests).To(HaveLen(2))
+				Expect(serverRequests[1].Method).To(Equal("POST"))
+				Expect(serverRequests[1].Header.Get("Content-MD5")).To(Equal(expectedMD5))
+				Expect(serverRequestBody[1]).To(Equal("content that we can check later"))
+			})
+		})
+
+		Context("when resume is disabled", func() {
+			BeforeEach(func() {
+				resume = false
+			})
+
+			It("sends the whole file again", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(serverRequests).To(HaveLen(2))
+				Expect(serverRequests[1].Header.Get("Content-Range")).To(BeEmpty())
+				Expect(serverRequestBody[1]).To(Equal("content that we can check later"))
+			})
 		})
 	})
 
@@ -269,7 +520,7 @@ var _ = Describe("Uploader", func() {
 				})
 
 				It("uploads the file to the url", func() {
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil, false, fakeMetronClient)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).NotTo(HaveOccurred())
 
@@ -286,7 +537,7 @@ var _ = Describe("Uploader", func() {
 				})
 
 				It("returns the number of bytes written", func() {
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil, false, fakeMetronClient)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).NotTo(HaveOccurred())
 
@@ -307,7 +558,7 @@ var _ = Describe("Uploader", func() {
 				})
 
 				It("can communicate with the fileserver via one-sided TLS", func() {
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil, false, fakeMetronClient)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).NotTo(HaveOccurred())
 				})
@@ -315,9 +566,10 @@ var _ = Describe("Uploader", func() {
 
 			Context("when the client has incorrect certs", func() {
 				It("fails when no certs are provided", func() {
-					upldr = uploader.New(logger, 100*time.Millisecond, nil)
+					upldr = uploader.New(logger, 100*time.Millisecond, nil, nil, false, fakeMetronClient)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).To(HaveOccurred())
+					Expect(err.(*uploader.UploadError).Category).To(Equal(uploader.UploadErrorTLS))
 				})
 
 				It("fails when wrong cert/keypair is provided", func() {
@@ -328,7 +580,7 @@ var _ = Describe("Uploader", func() {
 						tlsconfig.WithAuthorityFromFile("fixtures/correct/server-ca.crt"),
 					)
 					Expect(err).NotTo(HaveOccurred())
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil, false, fakeMetronClient)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).To(HaveOccurred())
 				})
@@ -341,7 +593,7 @@ var _ = Describe("Uploader", func() {
 						tlsconfig.WithAuthorityFromFile("fixtures/incorrect/server-ca.crt"),
 					)
 					Expect(err).NotTo(HaveOccurred())
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil, false, fakeMetronClient)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).To(HaveOccurred())
 				})
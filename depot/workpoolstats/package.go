@@ -0,0 +1 @@
+package workpoolstats // import "code.cloudfoundry.org/executor/depot/workpoolstats"
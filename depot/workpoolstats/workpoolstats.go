@@ -0,0 +1,36 @@
+package workpoolstats
+
+import (
+	"sync/atomic"
+
+	"code.cloudfoundry.org/workpool"
+)
+
+// WorkPool is a workpool.WorkPool that counts the work submitted to it that
+// has not yet been picked up by a worker.
+type WorkPool struct {
+	*workpool.WorkPool
+	queued int64
+}
+
+func NewWorkPool(size int) (*WorkPool, error) {
+	pool, err := workpool.NewWorkPool(size)
+	if err != nil {
+		return nil, err
+	}
+	return &WorkPool{WorkPool: pool}, nil
+}
+
+func (w *WorkPool) Submit(work func()) {
+	atomic.AddInt64(&w.queued, 1)
+	w.WorkPool.Submit(func() {
+		atomic.AddInt64(&w.queued, -1)
+		work()
+	})
+}
+
+// QueueDepth returns the number of submitted units of work waiting for a
+// worker.
+func (w *WorkPool) QueueDepth() int {
+	return int(atomic.LoadInt64(&w.queued))
+}
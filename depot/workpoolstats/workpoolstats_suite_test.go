@@ -0,0 +1,13 @@
+package workpoolstats_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestWorkPoolStats(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Work Pool Stats Suite")
+}
@@ -0,0 +1,50 @@
+package workpoolstats_test
+
+import (
+	"code.cloudfoundry.org/executor/depot/workpoolstats"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("WorkPool", func() {
+	var (
+		pool    *workpoolstats.WorkPool
+		release chan struct{}
+	)
+
+	BeforeEach(func() {
+		var err error
+		pool, err = workpoolstats.NewWorkPool(1)
+		Expect(err).NotTo(HaveOccurred())
+
+		release = make(chan struct{})
+	})
+
+	AfterEach(func() {
+		pool.Stop()
+	})
+
+	It("reports the work waiting for a worker", func() {
+		Expect(pool.QueueDepth()).To(Equal(0))
+
+		started := make(chan struct{}, 3)
+		for i := 0; i < 3; i++ {
+			pool.Submit(func() {
+				started <- struct{}{}
+				<-release
+			})
+		}
+
+		Eventually(started).Should(HaveLen(1))
+		Eventually(pool.QueueDepth).Should(Equal(2))
+
+		close(release)
+		Eventually(started).Should(HaveLen(3))
+		Eventually(pool.QueueDepth).Should(Equal(0))
+	})
+
+	It("fails for an invalid size", func() {
+		_, err := workpoolstats.NewWorkPool(0)
+		Expect(err).To(HaveOccurred())
+	})
+})
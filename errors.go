@@ -39,4 +39,12 @@ var (
 	ErrFailureToCheckSpace            = registerError("ErrFailureToCheckSpace", "failed to check available space")
 	ErrInvalidSecurityGroup           = registerError("ErrInvalidSecurityGroup", "security group has invalid values")
 	ErrNoProcessToStop                = registerError("ErrNoProcessToStop", "failed to find a process to stop")
+	ErrStartTimeoutExceedsMaximum     = registerError("StartTimeoutExceedsMaximum", "start timeout exceeds the configured maximum")
+	ErrInvalidCachePartitionTag       = registerError("InvalidCachePartitionTag", "cache partition tag must be 1-63 letters, digits, '-' or '_'")
+	ErrInodeLimitExceedsMaximum       = registerError("InodeLimitExceedsMaximum", "inode limit exceeds the configured maximum")
+	ErrReservedContainerTag           = registerError("ReservedContainerTag", "container tag uses a reserved property prefix")
+	ErrTooManyConcurrentCreates       = registerError("TooManyConcurrentCreates", "too many containers are being created, try again later")
+	ErrRangeNotSatisfiable            = registerError("RangeNotSatisfiable", "byte range cannot be served from the requested path")
+	ErrGardenNotReady                 = registerError("GardenNotReady", "garden has not passed a healthcheck yet")
+	ErrGardenPropertyLimitExceeded    = registerError("GardenPropertyLimitExceeded", "container has reached the maximum number of garden properties")
 )
@@ -66,6 +66,22 @@ type FakeClient struct {
 		result1 executor.Container
 		result2 error
 	}
+	GetFileRangeStub        func(lager.Logger, string, string, executor.ByteRange) (executor.PartialFile, error)
+	getFileRangeMutex       sync.RWMutex
+	getFileRangeArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 string
+		arg4 executor.ByteRange
+	}
+	getFileRangeReturns struct {
+		result1 executor.PartialFile
+		result2 error
+	}
+	getFileRangeReturnsOnCall map[int]struct {
+		result1 executor.PartialFile
+		result2 error
+	}
 	GetFilesStub        func(lager.Logger, string, string) (io.ReadCloser, error)
 	getFilesMutex       sync.RWMutex
 	getFilesArgsForCall []struct {
@@ -92,6 +108,18 @@ type FakeClient struct {
 	healthyReturnsOnCall map[int]struct {
 		result1 bool
 	}
+	ImportStub        func(lager.Logger, []executor.Container) error
+	importMutex       sync.RWMutex
+	importArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 []executor.Container
+	}
+	importReturns struct {
+		result1 error
+	}
+	importReturnsOnCall map[int]struct {
+		result1 error
+	}
 	ListContainersStub        func(lager.Logger) ([]executor.Container, error)
 	listContainersMutex       sync.RWMutex
 	listContainersArgsForCall []struct {
@@ -116,6 +144,19 @@ type FakeClient struct {
 	pingReturnsOnCall map[int]struct {
 		result1 error
 	}
+	RemainingCapacityStub        func(lager.Logger) (executor.ExecutorResources, error)
+	remainingCapacityMutex       sync.RWMutex
+	remainingCapacityArgsForCall []struct {
+		arg1 lager.Logger
+	}
+	remainingCapacityReturns struct {
+		result1 executor.ExecutorResources
+		result2 error
+	}
+	remainingCapacityReturnsOnCall map[int]struct {
+		result1 executor.ExecutorResources
+		result2 error
+	}
 	RemainingResourcesStub        func(lager.Logger) (executor.ExecutorResources, error)
 	remainingResourcesMutex       sync.RWMutex
 	remainingResourcesArgsForCall []struct {
@@ -172,6 +213,35 @@ type FakeClient struct {
 		result1 executor.EventSource
 		result2 error
 	}
+	TagContainerStub        func(lager.Logger, string, executor.Tags) error
+	tagContainerMutex       sync.RWMutex
+	tagContainerArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 executor.Tags
+	}
+	tagContainerReturns struct {
+		result1 error
+	}
+	tagContainerReturnsOnCall map[int]struct {
+		result1 error
+	}
+	TailFileStub        func(lager.Logger, string, string, int64) (io.ReadCloser, error)
+	tailFileMutex       sync.RWMutex
+	tailFileArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 string
+		arg4 int64
+	}
+	tailFileReturns struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	tailFileReturnsOnCall map[int]struct {
+		result1 io.ReadCloser
+		result2 error
+	}
 	TotalResourcesStub        func(lager.Logger) (executor.ExecutorResources, error)
 	totalResourcesMutex       sync.RWMutex
 	totalResourcesArgsForCall []struct {
@@ -185,6 +255,19 @@ type FakeClient struct {
 		result1 executor.ExecutorResources
 		result2 error
 	}
+	UpdateLogConfigStub        func(lager.Logger, string, executor.LogConfig) error
+	updateLogConfigMutex       sync.RWMutex
+	updateLogConfigArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 executor.LogConfig
+	}
+	updateLogConfigReturns struct {
+		result1 error
+	}
+	updateLogConfigReturnsOnCall map[int]struct {
+		result1 error
+	}
 	VolumeDriversStub        func(lager.Logger) ([]string, error)
 	volumeDriversMutex       sync.RWMutex
 	volumeDriversArgsForCall []struct {
@@ -487,6 +570,72 @@ func (fake *FakeClient) GetContainerReturnsOnCall(i int, result1 executor.Contai
 	}{result1, result2}
 }
 
+func (fake *FakeClient) GetFileRange(arg1 lager.Logger, arg2 string, arg3 string, arg4 executor.ByteRange) (executor.PartialFile, error) {
+	fake.getFileRangeMutex.Lock()
+	ret, specificReturn := fake.getFileRangeReturnsOnCall[len(fake.getFileRangeArgsForCall)]
+	fake.getFileRangeArgsForCall = append(fake.getFileRangeArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 string
+		arg4 executor.ByteRange
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("GetFileRange", []interface{}{arg1, arg2, arg3, arg4})
+	fake.getFileRangeMutex.Unlock()
+	if fake.GetFileRangeStub != nil {
+		return fake.GetFileRangeStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.getFileRangeReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) GetFileRangeCallCount() int {
+	fake.getFileRangeMutex.RLock()
+	defer fake.getFileRangeMutex.RUnlock()
+	return len(fake.getFileRangeArgsForCall)
+}
+
+func (fake *FakeClient) GetFileRangeCalls(stub func(lager.Logger, string, string, executor.ByteRange) (executor.PartialFile, error)) {
+	fake.getFileRangeMutex.Lock()
+	defer fake.getFileRangeMutex.Unlock()
+	fake.GetFileRangeStub = stub
+}
+
+func (fake *FakeClient) GetFileRangeArgsForCall(i int) (lager.Logger, string, string, executor.ByteRange) {
+	fake.getFileRangeMutex.RLock()
+	defer fake.getFileRangeMutex.RUnlock()
+	argsForCall := fake.getFileRangeArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) GetFileRangeReturns(result1 executor.PartialFile, result2 error) {
+	fake.getFileRangeMutex.Lock()
+	defer fake.getFileRangeMutex.Unlock()
+	fake.GetFileRangeStub = nil
+	fake.getFileRangeReturns = struct {
+		result1 executor.PartialFile
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) GetFileRangeReturnsOnCall(i int, result1 executor.PartialFile, result2 error) {
+	fake.getFileRangeMutex.Lock()
+	defer fake.getFileRangeMutex.Unlock()
+	fake.GetFileRangeStub = nil
+	if fake.getFileRangeReturnsOnCall == nil {
+		fake.getFileRangeReturnsOnCall = make(map[int]struct {
+			result1 executor.PartialFile
+			result2 error
+		})
+	}
+	fake.getFileRangeReturnsOnCall[i] = struct {
+		result1 executor.PartialFile
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) GetFiles(arg1 lager.Logger, arg2 string, arg3 string) (io.ReadCloser, error) {
 	fake.getFilesMutex.Lock()
 	ret, specificReturn := fake.getFilesReturnsOnCall[len(fake.getFilesArgsForCall)]
@@ -612,6 +761,72 @@ func (fake *FakeClient) HealthyReturnsOnCall(i int, result1 bool) {
 	}{result1}
 }
 
+func (fake *FakeClient) Import(arg1 lager.Logger, arg2 []executor.Container) error {
+	fake.importMutex.Lock()
+	ret, specificReturn := fake.importReturnsOnCall[len(fake.importArgsForCall)]
+	var arg2Copy []executor.Container
+	if arg2 != nil {
+		arg2Copy = make([]executor.Container, len(arg2))
+		copy(arg2Copy, arg2)
+	}
+	fake.importArgsForCall = append(fake.importArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 []executor.Container
+	}{arg1, arg2Copy})
+	fake.recordInvocation("Import", []interface{}{arg1, arg2Copy})
+	fake.importMutex.Unlock()
+	if fake.ImportStub != nil {
+		return fake.ImportStub(arg1, arg2)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.importReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) ImportCallCount() int {
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
+	return len(fake.importArgsForCall)
+}
+
+func (fake *FakeClient) ImportCalls(stub func(lager.Logger, []executor.Container) error) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = stub
+}
+
+func (fake *FakeClient) ImportArgsForCall(i int) (lager.Logger, []executor.Container) {
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
+	argsForCall := fake.importArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2
+}
+
+func (fake *FakeClient) ImportReturns(result1 error) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = nil
+	fake.importReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) ImportReturnsOnCall(i int, result1 error) {
+	fake.importMutex.Lock()
+	defer fake.importMutex.Unlock()
+	fake.ImportStub = nil
+	if fake.importReturnsOnCall == nil {
+		fake.importReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.importReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeClient) ListContainers(arg1 lager.Logger) ([]executor.Container, error) {
 	fake.listContainersMutex.Lock()
 	ret, specificReturn := fake.listContainersReturnsOnCall[len(fake.listContainersArgsForCall)]
@@ -735,6 +950,69 @@ func (fake *FakeClient) PingReturnsOnCall(i int, result1 error) {
 	}{result1}
 }
 
+func (fake *FakeClient) RemainingCapacity(arg1 lager.Logger) (executor.ExecutorResources, error) {
+	fake.remainingCapacityMutex.Lock()
+	ret, specificReturn := fake.remainingCapacityReturnsOnCall[len(fake.remainingCapacityArgsForCall)]
+	fake.remainingCapacityArgsForCall = append(fake.remainingCapacityArgsForCall, struct {
+		arg1 lager.Logger
+	}{arg1})
+	fake.recordInvocation("RemainingCapacity", []interface{}{arg1})
+	fake.remainingCapacityMutex.Unlock()
+	if fake.RemainingCapacityStub != nil {
+		return fake.RemainingCapacityStub(arg1)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.remainingCapacityReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) RemainingCapacityCallCount() int {
+	fake.remainingCapacityMutex.RLock()
+	defer fake.remainingCapacityMutex.RUnlock()
+	return len(fake.remainingCapacityArgsForCall)
+}
+
+func (fake *FakeClient) RemainingCapacityCalls(stub func(lager.Logger) (executor.ExecutorResources, error)) {
+	fake.remainingCapacityMutex.Lock()
+	defer fake.remainingCapacityMutex.Unlock()
+	fake.RemainingCapacityStub = stub
+}
+
+func (fake *FakeClient) RemainingCapacityArgsForCall(i int) lager.Logger {
+	fake.remainingCapacityMutex.RLock()
+	defer fake.remainingCapacityMutex.RUnlock()
+	argsForCall := fake.remainingCapacityArgsForCall[i]
+	return argsForCall.arg1
+}
+
+func (fake *FakeClient) RemainingCapacityReturns(result1 executor.ExecutorResources, result2 error) {
+	fake.remainingCapacityMutex.Lock()
+	defer fake.remainingCapacityMutex.Unlock()
+	fake.RemainingCapacityStub = nil
+	fake.remainingCapacityReturns = struct {
+		result1 executor.ExecutorResources
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) RemainingCapacityReturnsOnCall(i int, result1 executor.ExecutorResources, result2 error) {
+	fake.remainingCapacityMutex.Lock()
+	defer fake.remainingCapacityMutex.Unlock()
+	fake.RemainingCapacityStub = nil
+	if fake.remainingCapacityReturnsOnCall == nil {
+		fake.remainingCapacityReturnsOnCall = make(map[int]struct {
+			result1 executor.ExecutorResources
+			result2 error
+		})
+	}
+	fake.remainingCapacityReturnsOnCall[i] = struct {
+		result1 executor.ExecutorResources
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) RemainingResources(arg1 lager.Logger) (executor.ExecutorResources, error) {
 	fake.remainingResourcesMutex.Lock()
 	ret, specificReturn := fake.remainingResourcesReturnsOnCall[len(fake.remainingResourcesArgsForCall)]
@@ -1015,6 +1293,134 @@ func (fake *FakeClient) SubscribeToEventsReturnsOnCall(i int, result1 executor.E
 	}{result1, result2}
 }
 
+func (fake *FakeClient) TagContainer(arg1 lager.Logger, arg2 string, arg3 executor.Tags) error {
+	fake.tagContainerMutex.Lock()
+	ret, specificReturn := fake.tagContainerReturnsOnCall[len(fake.tagContainerArgsForCall)]
+	fake.tagContainerArgsForCall = append(fake.tagContainerArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 executor.Tags
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("TagContainer", []interface{}{arg1, arg2, arg3})
+	fake.tagContainerMutex.Unlock()
+	if fake.TagContainerStub != nil {
+		return fake.TagContainerStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.tagContainerReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) TagContainerCallCount() int {
+	fake.tagContainerMutex.RLock()
+	defer fake.tagContainerMutex.RUnlock()
+	return len(fake.tagContainerArgsForCall)
+}
+
+func (fake *FakeClient) TagContainerCalls(stub func(lager.Logger, string, executor.Tags) error) {
+	fake.tagContainerMutex.Lock()
+	defer fake.tagContainerMutex.Unlock()
+	fake.TagContainerStub = stub
+}
+
+func (fake *FakeClient) TagContainerArgsForCall(i int) (lager.Logger, string, executor.Tags) {
+	fake.tagContainerMutex.RLock()
+	defer fake.tagContainerMutex.RUnlock()
+	argsForCall := fake.tagContainerArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) TagContainerReturns(result1 error) {
+	fake.tagContainerMutex.Lock()
+	defer fake.tagContainerMutex.Unlock()
+	fake.TagContainerStub = nil
+	fake.tagContainerReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) TagContainerReturnsOnCall(i int, result1 error) {
+	fake.tagContainerMutex.Lock()
+	defer fake.tagContainerMutex.Unlock()
+	fake.TagContainerStub = nil
+	if fake.tagContainerReturnsOnCall == nil {
+		fake.tagContainerReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.tagContainerReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) TailFile(arg1 lager.Logger, arg2 string, arg3 string, arg4 int64) (io.ReadCloser, error) {
+	fake.tailFileMutex.Lock()
+	ret, specificReturn := fake.tailFileReturnsOnCall[len(fake.tailFileArgsForCall)]
+	fake.tailFileArgsForCall = append(fake.tailFileArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 string
+		arg4 int64
+	}{arg1, arg2, arg3, arg4})
+	fake.recordInvocation("TailFile", []interface{}{arg1, arg2, arg3, arg4})
+	fake.tailFileMutex.Unlock()
+	if fake.TailFileStub != nil {
+		return fake.TailFileStub(arg1, arg2, arg3, arg4)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	fakeReturns := fake.tailFileReturns
+	return fakeReturns.result1, fakeReturns.result2
+}
+
+func (fake *FakeClient) TailFileCallCount() int {
+	fake.tailFileMutex.RLock()
+	defer fake.tailFileMutex.RUnlock()
+	return len(fake.tailFileArgsForCall)
+}
+
+func (fake *FakeClient) TailFileCalls(stub func(lager.Logger, string, string, int64) (io.ReadCloser, error)) {
+	fake.tailFileMutex.Lock()
+	defer fake.tailFileMutex.Unlock()
+	fake.TailFileStub = stub
+}
+
+func (fake *FakeClient) TailFileArgsForCall(i int) (lager.Logger, string, string, int64) {
+	fake.tailFileMutex.RLock()
+	defer fake.tailFileMutex.RUnlock()
+	argsForCall := fake.tailFileArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3, argsForCall.arg4
+}
+
+func (fake *FakeClient) TailFileReturns(result1 io.ReadCloser, result2 error) {
+	fake.tailFileMutex.Lock()
+	defer fake.tailFileMutex.Unlock()
+	fake.TailFileStub = nil
+	fake.tailFileReturns = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) TailFileReturnsOnCall(i int, result1 io.ReadCloser, result2 error) {
+	fake.tailFileMutex.Lock()
+	defer fake.tailFileMutex.Unlock()
+	fake.TailFileStub = nil
+	if fake.tailFileReturnsOnCall == nil {
+		fake.tailFileReturnsOnCall = make(map[int]struct {
+			result1 io.ReadCloser
+			result2 error
+		})
+	}
+	fake.tailFileReturnsOnCall[i] = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeClient) TotalResources(arg1 lager.Logger) (executor.ExecutorResources, error) {
 	fake.totalResourcesMutex.Lock()
 	ret, specificReturn := fake.totalResourcesReturnsOnCall[len(fake.totalResourcesArgsForCall)]
@@ -1078,6 +1484,68 @@ func (fake *FakeClient) TotalResourcesReturnsOnCall(i int, result1 executor.Exec
 	}{result1, result2}
 }
 
+func (fake *FakeClient) UpdateLogConfig(arg1 lager.Logger, arg2 string, arg3 executor.LogConfig) error {
+	fake.updateLogConfigMutex.Lock()
+	ret, specificReturn := fake.updateLogConfigReturnsOnCall[len(fake.updateLogConfigArgsForCall)]
+	fake.updateLogConfigArgsForCall = append(fake.updateLogConfigArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 string
+		arg3 executor.LogConfig
+	}{arg1, arg2, arg3})
+	fake.recordInvocation("UpdateLogConfig", []interface{}{arg1, arg2, arg3})
+	fake.updateLogConfigMutex.Unlock()
+	if fake.UpdateLogConfigStub != nil {
+		return fake.UpdateLogConfigStub(arg1, arg2, arg3)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	fakeReturns := fake.updateLogConfigReturns
+	return fakeReturns.result1
+}
+
+func (fake *FakeClient) UpdateLogConfigCallCount() int {
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
+	return len(fake.updateLogConfigArgsForCall)
+}
+
+func (fake *FakeClient) UpdateLogConfigCalls(stub func(lager.Logger, string, executor.LogConfig) error) {
+	fake.updateLogConfigMutex.Lock()
+	defer fake.updateLogConfigMutex.Unlock()
+	fake.UpdateLogConfigStub = stub
+}
+
+func (fake *FakeClient) UpdateLogConfigArgsForCall(i int) (lager.Logger, string, executor.LogConfig) {
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
+	argsForCall := fake.updateLogConfigArgsForCall[i]
+	return argsForCall.arg1, argsForCall.arg2, argsForCall.arg3
+}
+
+func (fake *FakeClient) UpdateLogConfigReturns(result1 error) {
+	fake.updateLogConfigMutex.Lock()
+	defer fake.updateLogConfigMutex.Unlock()
+	fake.UpdateLogConfigStub = nil
+	fake.updateLogConfigReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) UpdateLogConfigReturnsOnCall(i int, result1 error) {
+	fake.updateLogConfigMutex.Lock()
+	defer fake.updateLogConfigMutex.Unlock()
+	fake.UpdateLogConfigStub = nil
+	if fake.updateLogConfigReturnsOnCall == nil {
+		fake.updateLogConfigReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.updateLogConfigReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeClient) VolumeDrivers(arg1 lager.Logger) ([]string, error) {
 	fake.volumeDriversMutex.Lock()
 	ret, specificReturn := fake.volumeDriversReturnsOnCall[len(fake.volumeDriversArgsForCall)]
@@ -1154,14 +1622,20 @@ func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	defer fake.getBulkMetricsMutex.RUnlock()
 	fake.getContainerMutex.RLock()
 	defer fake.getContainerMutex.RUnlock()
+	fake.getFileRangeMutex.RLock()
+	defer fake.getFileRangeMutex.RUnlock()
 	fake.getFilesMutex.RLock()
 	defer fake.getFilesMutex.RUnlock()
 	fake.healthyMutex.RLock()
 	defer fake.healthyMutex.RUnlock()
+	fake.importMutex.RLock()
+	defer fake.importMutex.RUnlock()
 	fake.listContainersMutex.RLock()
 	defer fake.listContainersMutex.RUnlock()
 	fake.pingMutex.RLock()
 	defer fake.pingMutex.RUnlock()
+	fake.remainingCapacityMutex.RLock()
+	defer fake.remainingCapacityMutex.RUnlock()
 	fake.remainingResourcesMutex.RLock()
 	defer fake.remainingResourcesMutex.RUnlock()
 	fake.runContainerMutex.RLock()
@@ -1172,8 +1646,14 @@ func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	defer fake.stopContainerMutex.RUnlock()
 	fake.subscribeToEventsMutex.RLock()
 	defer fake.subscribeToEventsMutex.RUnlock()
+	fake.tagContainerMutex.RLock()
+	defer fake.tagContainerMutex.RUnlock()
+	fake.tailFileMutex.RLock()
+	defer fake.tailFileMutex.RUnlock()
 	fake.totalResourcesMutex.RLock()
 	defer fake.totalResourcesMutex.RUnlock()
+	fake.updateLogConfigMutex.RLock()
+	defer fake.updateLogConfigMutex.RUnlock()
 	fake.volumeDriversMutex.RLock()
 	defer fake.volumeDriversMutex.RUnlock()
 	copiedInvocations := map[string][][]interface{}{}
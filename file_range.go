@@ -0,0 +1,54 @@
+package executor
+
+import "io"
+
+// ByteRange selects part of a single file, with the same meaning as an HTTP
+// byte range. A positive Suffix selects the last Suffix bytes of the file and
+// Start and End are ignored. Otherwise the range runs from Start to End
+// inclusive; a negative End runs to the end of the file.
+type ByteRange struct {
+	Start  int64
+	End    int64
+	Suffix int64
+}
+
+// PartialFile streams the bytes selected by a ByteRange. Start and End are
+// the inclusive offsets actually served, and Size is the size of the whole
+// file, as needed for a Content-Range header.
+type PartialFile struct {
+	io.ReadCloser
+
+	Start int64
+	End   int64
+	Size  int64
+}
+
+// Resolve returns the inclusive offsets the range selects in a file of the
+// given size, or ErrRangeNotSatisfiable when it selects nothing.
+func (r ByteRange) Resolve(size int64) (int64, int64, error) {
+	if r.Suffix > 0 {
+		if size == 0 {
+			return 0, 0, ErrRangeNotSatisfiable
+		}
+
+		start := size - r.Suffix
+		if start < 0 {
+			start = 0
+		}
+		return start, size - 1, nil
+	}
+
+	if r.Start < 0 || r.Start >= size {
+		return 0, 0, ErrRangeNotSatisfiable
+	}
+
+	end := r.End
+	if end < 0 || end >= size {
+		end = size - 1
+	}
+	if end < r.Start {
+		return 0, 0, ErrRangeNotSatisfiable
+	}
+
+	return r.Start, end, nil
+}
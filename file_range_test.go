@@ -0,0 +1,50 @@
+package executor_test
+
+import (
+	"code.cloudfoundry.org/executor"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ByteRange", func() {
+	Describe("Resolve", func() {
+		resolve := func(byteRange executor.ByteRange, size int64) []int64 {
+			start, end, err := byteRange.Resolve(size)
+			Expect(err).NotTo(HaveOccurred())
+			return []int64{start, end}
+		}
+
+		It("resolves a suffix range to the end of the file", func() {
+			Expect(resolve(executor.ByteRange{Suffix: 10}, 100)).To(Equal([]int64{90, 99}))
+		})
+
+		It("clamps a suffix range longer than the file to the whole file", func() {
+			Expect(resolve(executor.ByteRange{Suffix: 500}, 100)).To(Equal([]int64{0, 99}))
+		})
+
+		It("resolves a bounded range", func() {
+			Expect(resolve(executor.ByteRange{Start: 10, End: 19}, 100)).To(Equal([]int64{10, 19}))
+		})
+
+		It("resolves an open-ended range to the end of the file", func() {
+			Expect(resolve(executor.ByteRange{Start: 10, End: -1}, 100)).To(Equal([]int64{10, 99}))
+			Expect(resolve(executor.ByteRange{Start: 10, End: 1000}, 100)).To(Equal([]int64{10, 99}))
+		})
+
+		It("rejects ranges that select nothing", func() {
+			unsatisfiable := []struct {
+				byteRange executor.ByteRange
+				size      int64
+			}{
+				{executor.ByteRange{Start: 100, End: -1}, 100},
+				{executor.ByteRange{Start: 10, End: 5}, 100},
+				{executor.ByteRange{Suffix: 10}, 0},
+			}
+
+			for _, c := range unsatisfiable {
+				_, _, err := c.byteRange.Resolve(c.size)
+				Expect(err).To(Equal(executor.ErrRangeNotSatisfiable), "range %+v of %d bytes", c.byteRange, c.size)
+			}
+		})
+	})
+})
@@ -0,0 +1,139 @@
+package gardenfailover
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/executor/gardenhealth"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+// Backend is one garden server of a Client.
+type Backend struct {
+	Addr   string
+	Client garden.Client
+}
+
+// Client sends every call to one active garden server of an active-passive
+// group, starting with the first. Garden servers do not share containers, so
+// the client only moves on to the next server once the active one fails to
+// be reached threshold times in a row, and then stays there until that one
+// fails in turn.
+//
+// Only connection errors count as failures. Errors garden returns for a
+// request, such as a missing container or a bad rootfs, leave the active
+// server in place.
+//
+// Calls made on a garden.Container returned by the client go straight to the
+// server that returned it.
+type Client struct {
+	backends  []Backend
+	threshold int
+	logger    lager.Logger
+
+	lock     sync.Mutex
+	active   int
+	failures int
+}
+
+func New(logger lager.Logger, backends []Backend, threshold int) *Client {
+	return &Client{
+		backends:  backends,
+		threshold: threshold,
+		logger:    logger.Session("garden-failover"),
+	}
+}
+
+func (c *Client) Ping() error {
+	return c.call(func(client garden.Client) error {
+		return client.Ping()
+	})
+}
+
+func (c *Client) Capacity() (capacity garden.Capacity, err error) {
+	err = c.call(func(client garden.Client) error {
+		capacity, err = client.Capacity()
+		return err
+	})
+	return capacity, err
+}
+
+func (c *Client) Create(spec garden.ContainerSpec) (container garden.Container, err error) {
+	err = c.call(func(client garden.Client) error {
+		container, err = client.Create(spec)
+		return err
+	})
+	return container, err
+}
+
+func (c *Client) Destroy(handle string) error {
+	return c.call(func(client garden.Client) error {
+		return client.Destroy(handle)
+	})
+}
+
+func (c *Client) Containers(properties garden.Properties) (containers []garden.Container, err error) {
+	err = c.call(func(client garden.Client) error {
+		containers, err = client.Containers(properties)
+		return err
+	})
+	return containers, err
+}
+
+func (c *Client) BulkInfo(handles []string) (infos map[string]garden.ContainerInfoEntry, err error) {
+	err = c.call(func(client garden.Client) error {
+		infos, err = client.BulkInfo(handles)
+		return err
+	})
+	return infos, err
+}
+
+func (c *Client) BulkMetrics(handles []string) (metrics map[string]garden.ContainerMetricsEntry, err error) {
+	err = c.call(func(client garden.Client) error {
+		metrics, err = client.BulkMetrics(handles)
+		return err
+	})
+	return metrics, err
+}
+
+func (c *Client) Lookup(handle string) (container garden.Container, err error) {
+	err = c.call(func(client garden.Client) error {
+		container, err = client.Lookup(handle)
+		return err
+	})
+	return container, err
+}
+
+func (c *Client) call(fn func(garden.Client) error) error {
+	c.lock.Lock()
+	active := c.active
+	c.lock.Unlock()
+
+	err := fn(c.backends[active].Client)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// another call may have failed over in the meantime
+	if active != c.active {
+		return err
+	}
+
+	if !gardenhealth.IsConnectionError(err) {
+		c.failures = 0
+		return err
+	}
+
+	c.failures++
+	if c.failures >= c.threshold && len(c.backends) > 1 {
+		c.active = (active + 1) % len(c.backends)
+		c.failures = 0
+		c.logger.Error("failed-over", err, lager.Data{
+			"from":                 c.backends[active].Addr,
+			"to":                   c.backends[c.active].Addr,
+			"consecutive-failures": c.threshold,
+		})
+	}
+
+	return err
+}
@@ -0,0 +1,131 @@
+package gardenfailover_test
+
+import (
+	"errors"
+	"net"
+
+	"code.cloudfoundry.org/executor/gardenfailover"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Client", func() {
+	const threshold = 2
+
+	var (
+		logger        *lagertest.TestLogger
+		active        *gardenfakes.FakeClient
+		passive       *gardenfakes.FakeClient
+		client        *gardenfailover.Client
+		connectionErr error
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		active = &gardenfakes.FakeClient{}
+		passive = &gardenfakes.FakeClient{}
+		connectionErr = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		client = gardenfailover.New(logger, []gardenfailover.Backend{
+			{Addr: "10.0.0.1:7777", Client: active},
+			{Addr: "10.0.0.2:7777", Client: passive},
+		}, threshold)
+	})
+
+	It("sends every call to the first address", func() {
+		Expect(client.Ping()).To(Succeed())
+		_, err := client.Create(garden.ContainerSpec{Handle: "some-handle"})
+		Expect(err).NotTo(HaveOccurred())
+		_, err = client.Lookup("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(client.Destroy("some-handle")).To(Succeed())
+
+		Expect(active.PingCallCount()).To(Equal(1))
+		Expect(active.CreateCallCount()).To(Equal(1))
+		Expect(active.LookupCallCount()).To(Equal(1))
+		Expect(active.DestroyCallCount()).To(Equal(1))
+		Expect(passive.Invocations()).To(BeEmpty())
+	})
+
+	It("passes results through from garden", func() {
+		active.CapacityReturns(garden.Capacity{MemoryInBytes: 1024}, nil)
+
+		capacity, err := client.Capacity()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capacity).To(Equal(garden.Capacity{MemoryInBytes: 1024}))
+	})
+
+	Context("when the active address cannot be reached fewer than threshold times in a row", func() {
+		BeforeEach(func() {
+			active.PingReturnsOnCall(0, connectionErr)
+		})
+
+		It("stays on it", func() {
+			Expect(client.Ping()).To(Equal(connectionErr))
+			Expect(client.Ping()).To(Succeed())
+			Expect(client.Ping()).To(Succeed())
+
+			Expect(active.PingCallCount()).To(Equal(3))
+			Expect(passive.PingCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when the active address cannot be reached threshold times in a row", func() {
+		BeforeEach(func() {
+			active.PingReturns(connectionErr)
+			for i := 0; i < threshold; i++ {
+				Expect(client.Ping()).To(Equal(connectionErr))
+			}
+		})
+
+		It("fails over to the next address", func() {
+			Expect(client.Ping()).To(Succeed())
+			Expect(passive.PingCallCount()).To(Equal(1))
+			Expect(logger).To(gbytes.Say("failed-over.*10.0.0.1:7777.*10.0.0.2:7777"))
+		})
+
+		It("stays on the next address once the first one recovers", func() {
+			active.PingReturns(nil)
+
+			Expect(client.Ping()).To(Succeed())
+			Expect(client.Ping()).To(Succeed())
+			Expect(active.PingCallCount()).To(Equal(threshold))
+			Expect(passive.PingCallCount()).To(Equal(2))
+		})
+
+		Context("when the next address cannot be reached either", func() {
+			BeforeEach(func() {
+				passive.PingReturns(connectionErr)
+				for i := 0; i < threshold; i++ {
+					Expect(client.Ping()).To(Equal(connectionErr))
+				}
+				active.PingReturns(nil)
+			})
+
+			It("fails back over to the first address", func() {
+				Expect(client.Ping()).To(Succeed())
+				Expect(active.PingCallCount()).To(Equal(threshold + 1))
+				Expect(passive.PingCallCount()).To(Equal(threshold))
+			})
+		})
+	})
+
+	It("does not count errors garden returns for a request as failures", func() {
+		active.LookupReturns(nil, garden.ContainerNotFoundError{Handle: "some-handle"})
+		active.CreateReturns(nil, errors.New("invalid rootfs"))
+
+		for i := 0; i < 2*threshold; i++ {
+			client.Lookup("some-handle")
+			client.Create(garden.ContainerSpec{})
+		}
+
+		Expect(active.LookupCallCount()).To(Equal(2 * threshold))
+		Expect(active.CreateCallCount()).To(Equal(2 * threshold))
+		Expect(passive.Invocations()).To(BeEmpty())
+		Expect(logger).NotTo(gbytes.Say("failed-over"))
+	})
+})
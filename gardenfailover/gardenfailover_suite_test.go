@@ -0,0 +1,13 @@
+package gardenfailover_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestGardenFailover(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "Garden Failover Suite")
+}
@@ -0,0 +1 @@
+package gardenfailover // import "code.cloudfoundry.org/executor/gardenfailover"
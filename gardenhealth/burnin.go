@@ -0,0 +1,127 @@
+package gardenhealth
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+var (
+	ErrBurnInLiveContainers = errors.New("cell has live containers")
+	ErrBurnInUnbounded      = errors.New("burn-in requires a duration or an iteration count")
+)
+
+type BurnInOptions struct {
+	// Duration bounds how long the burn-in runs for. Zero means unbounded.
+	Duration time.Duration
+	// Iterations bounds how many healthchecks are run. Zero means unbounded.
+	Iterations int
+	// Force runs the burn-in even if the executor has live containers.
+	Force bool
+}
+
+// BurnInResult is written to the results stream as a single JSON line after
+// each healthcheck.
+type BurnInResult struct {
+	Iteration int    `json:"iteration"`
+	Passed    bool   `json:"passed"`
+	Error     string `json:"error,omitempty"`
+	Duration  int64  `json:"duration_ns"`
+}
+
+type BurnInReport struct {
+	Iterations int     `json:"iterations"`
+	Passed     int     `json:"passed"`
+	PassRate   float64 `json:"pass_rate"`
+}
+
+// BurnIn runs the garden healthcheck repeatedly to build confidence in a cell
+// before returning it to service. It drives its own Checker, so it does not
+// interfere with the periodic Runner.
+type BurnIn struct {
+	checker        Checker
+	executorClient executor.Client
+	clock          clock.Clock
+}
+
+func NewBurnIn(checker Checker, executorClient executor.Client, clock clock.Clock) *BurnIn {
+	return &BurnIn{
+		checker:        checker,
+		executorClient: executorClient,
+		clock:          clock,
+	}
+}
+
+// Run performs healthchecks until the configured duration or iteration count
+// is reached or cancel is closed, writing a BurnInResult line to results after
+// each one. A healthcheck in flight when cancel is closed is allowed to finish
+// so that its container is cleaned up.
+func (b *BurnIn) Run(logger lager.Logger, opts BurnInOptions, results io.Writer, cancel <-chan struct{}) (BurnInReport, error) {
+	logger = logger.Session("burn-in", lager.Data{"duration": opts.Duration.String(), "iterations": opts.Iterations})
+
+	if opts.Duration <= 0 && opts.Iterations <= 0 {
+		return BurnInReport{}, ErrBurnInUnbounded
+	}
+
+	if !opts.Force {
+		containers, err := b.executorClient.ListContainers(logger)
+		if err != nil {
+			logger.Error("failed-to-list-containers", err)
+			return BurnInReport{}, err
+		}
+		if len(containers) > 0 {
+			logger.Info("refusing-to-start", lager.Data{"containers": len(containers)})
+			return BurnInReport{}, ErrBurnInLiveContainers
+		}
+	}
+
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	encoder := json.NewEncoder(results)
+	report := BurnInReport{}
+	start := b.clock.Now()
+
+	for opts.Iterations <= 0 || report.Iterations < opts.Iterations {
+		if opts.Duration > 0 && b.clock.Since(start) >= opts.Duration {
+			break
+		}
+
+		select {
+		case <-cancel:
+			logger.Info("cancelled")
+			return report, nil
+		default:
+		}
+
+		iterationStart := b.clock.Now()
+		err := b.checker.Healthcheck(logger)
+
+		report.Iterations++
+		result := BurnInResult{
+			Iteration: report.Iterations,
+			Passed:    err == nil,
+			Duration:  int64(b.clock.Since(iterationStart)),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			report.Passed++
+		}
+		report.PassRate = float64(report.Passed) / float64(report.Iterations)
+
+		err = encoder.Encode(result)
+		if err != nil {
+			logger.Error("failed-to-write-result", err)
+			return report, err
+		}
+	}
+
+	logger.Info("finished", lager.Data{"passed": report.Passed, "total": report.Iterations})
+	return report, nil
+}
@@ -0,0 +1,153 @@
+package gardenhealth_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	fakeexecutor "code.cloudfoundry.org/executor/fakes"
+	"code.cloudfoundry.org/executor/gardenhealth"
+	"code.cloudfoundry.org/executor/gardenhealth/fakegardenhealth"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BurnIn", func() {
+	var (
+		logger         *lagertest.TestLogger
+		checker        *fakegardenhealth.FakeChecker
+		executorClient *fakeexecutor.FakeClient
+		fakeClock      *fakeclock.FakeClock
+		burnIn         *gardenhealth.BurnIn
+		opts           gardenhealth.BurnInOptions
+		results        *bytes.Buffer
+		cancel         chan struct{}
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		checker = &fakegardenhealth.FakeChecker{}
+		executorClient = &fakeexecutor.FakeClient{}
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		burnIn = gardenhealth.NewBurnIn(checker, executorClient, fakeClock)
+		opts = gardenhealth.BurnInOptions{Iterations: 4}
+		results = &bytes.Buffer{}
+		cancel = make(chan struct{})
+
+		checker.HealthcheckStub = func(lager.Logger) error {
+			if checker.HealthcheckCallCount()%2 == 0 {
+				return errors.New("boom")
+			}
+			return nil
+		}
+	})
+
+	decodeResults := func() []gardenhealth.BurnInResult {
+		var decoded []gardenhealth.BurnInResult
+		decoder := json.NewDecoder(results)
+		for decoder.More() {
+			var result gardenhealth.BurnInResult
+			Expect(decoder.Decode(&result)).To(Succeed())
+			decoded = append(decoded, result)
+		}
+		return decoded
+	}
+
+	It("runs the configured number of healthchecks and reports the pass rate", func() {
+		report, err := burnIn.Run(logger, opts, results, cancel)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(checker.HealthcheckCallCount()).To(Equal(4))
+		Expect(report).To(Equal(gardenhealth.BurnInReport{Iterations: 4, Passed: 2, PassRate: 0.5}))
+
+		decoded := decodeResults()
+		Expect(decoded).To(HaveLen(4))
+		Expect(decoded[0].Iteration).To(Equal(1))
+		Expect(decoded[0].Passed).To(BeTrue())
+		Expect(decoded[1].Passed).To(BeFalse())
+		Expect(decoded[1].Error).To(Equal("boom"))
+	})
+
+	Context("when only a duration is configured", func() {
+		BeforeEach(func() {
+			opts = gardenhealth.BurnInOptions{Duration: 3 * time.Second}
+			checker.HealthcheckStub = func(lager.Logger) error {
+				fakeClock.Increment(time.Second)
+				return nil
+			}
+		})
+
+		It("runs healthchecks until the duration has elapsed", func() {
+			report, err := burnIn.Run(logger, opts, results, cancel)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Iterations).To(Equal(3))
+			Expect(decodeResults()[0].Duration).To(BeEquivalentTo(time.Second))
+		})
+	})
+
+	Context("when neither a duration nor an iteration count is configured", func() {
+		It("refuses to start", func() {
+			_, err := burnIn.Run(logger, gardenhealth.BurnInOptions{}, results, cancel)
+			Expect(err).To(Equal(gardenhealth.ErrBurnInUnbounded))
+			Expect(checker.HealthcheckCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when cancelled", func() {
+		BeforeEach(func() {
+			checker.HealthcheckStub = func(lager.Logger) error {
+				if checker.HealthcheckCallCount() == 2 {
+					close(cancel)
+				}
+				return nil
+			}
+		})
+
+		It("stops after the in-flight healthcheck", func() {
+			report, err := burnIn.Run(logger, opts, results, cancel)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(report.Iterations).To(Equal(2))
+			Expect(decodeResults()).To(HaveLen(2))
+		})
+	})
+
+	Context("when the executor has live containers", func() {
+		BeforeEach(func() {
+			executorClient.ListContainersReturns([]executor.Container{{Guid: "app"}}, nil)
+		})
+
+		It("refuses to start", func() {
+			_, err := burnIn.Run(logger, opts, results, cancel)
+			Expect(err).To(Equal(gardenhealth.ErrBurnInLiveContainers))
+			Expect(checker.HealthcheckCallCount()).To(BeZero())
+		})
+
+		Context("when forced", func() {
+			BeforeEach(func() {
+				opts.Force = true
+			})
+
+			It("runs the burn-in anyway", func() {
+				report, err := burnIn.Run(logger, opts, results, cancel)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(report.Iterations).To(Equal(4))
+			})
+		})
+	})
+
+	Context("when listing containers fails", func() {
+		BeforeEach(func() {
+			executorClient.ListContainersReturns(nil, errors.New("nope"))
+		})
+
+		It("returns the error", func() {
+			_, err := burnIn.Run(logger, opts, results, cancel)
+			Expect(err).To(MatchError("nope"))
+		})
+	})
+})
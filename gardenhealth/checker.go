@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/steps"
 	"code.cloudfoundry.org/executor/guidgen"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden/server"
@@ -60,6 +61,10 @@ func NewChecker(
 	gardenClient garden.Client,
 	guidGenerator guidgen.Generator,
 ) Checker {
+	if len(healthcheckSpec.Env) > 0 {
+		healthcheckSpec.Env = steps.MergeEnvironment(healthcheckSpec.Env)
+	}
+
 	return &checker{
 		rootFSPath:         rootFSPath,
 		containerOwnerName: containerOwnerName,
@@ -308,6 +313,9 @@ func (c *checker) destroyContainer(guid string) error {
 	case garden.ContainerNotFoundError:
 		return err
 	default:
+		if err == ErrGardenCircuitOpen {
+			return err
+		}
 		return UnrecoverableError(err.Error())
 	}
 }
@@ -321,8 +329,8 @@ func retryOnFail(retryInterval time.Duration, cmd func(attempt uint) error) erro
 
 	for i := uint(0); i < maxRetries; i++ {
 		err = cmd(i)
-		if err == nil {
-			return nil
+		if err == nil || isCircuitOpen(err) {
+			return err
 		}
 
 		time.Sleep(retryInterval)
@@ -103,6 +103,22 @@ var _ = Describe("Checker", func() {
 				By("Returns success")
 				Expect(err).Should(BeNil())
 			})
+
+			Context("when the healthcheck environment defines a variable twice", func() {
+				BeforeEach(func() {
+					healthcheckSpec.Env = []string{"ZED=1", "ALPHA=1", "ZED=2"}
+					guidGenerator := &fakeguidgen.FakeGenerator{}
+					guidGenerator.GuidReturns("abc-123")
+					gardenChecker = gardenhealth.NewChecker(rootfsPath, containerOwnerName, 0, healthcheckSpec, gardenClient, guidGenerator)
+				})
+
+				It("runs the process with each variable once, sorted by name", func() {
+					Expect(gardenChecker.Healthcheck(logger)).To(Succeed())
+
+					procSpec, _ := fakeContainer.RunArgsForCall(0)
+					Expect(procSpec.Env).To(Equal([]string{"ALPHA=1", "ZED=2"}))
+				})
+			})
 		})
 
 		Context("when list containers fails", func() {
@@ -0,0 +1,166 @@
+package gardenhealth
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+var ErrGardenCircuitOpen = errors.New("garden circuit breaker is open")
+
+// CircuitBreakerGardenClient wraps a garden.Client and stops calling through
+// to garden once it has seen too many consecutive failures. This keeps the
+// executor's work pools from piling up failed requests while garden restarts.
+//
+// After threshold consecutive failures the circuit opens, and every call
+// returns ErrGardenCircuitOpen until openDuration has elapsed. The next call
+// after that is let through: a success closes the circuit again and a failure
+// re-opens it for another openDuration.
+//
+// Only calls made directly on the client are guarded; calls made on a
+// garden.Container returned by the client go straight to garden.
+type CircuitBreakerGardenClient struct {
+	client       garden.Client
+	threshold    int
+	openDuration time.Duration
+	logger       lager.Logger
+	clock        clock.Clock
+
+	lock      sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+func NewCircuitBreakerGardenClient(
+	logger lager.Logger,
+	client garden.Client,
+	threshold int,
+	openDuration time.Duration,
+	clock clock.Clock,
+) *CircuitBreakerGardenClient {
+	return &CircuitBreakerGardenClient{
+		client:       client,
+		threshold:    threshold,
+		openDuration: openDuration,
+		logger:       logger.Session("garden-circuit-breaker"),
+		clock:        clock,
+	}
+}
+
+func (c *CircuitBreakerGardenClient) Ping() error {
+	return c.call(func() error {
+		return c.client.Ping()
+	})
+}
+
+func (c *CircuitBreakerGardenClient) Capacity() (capacity garden.Capacity, err error) {
+	err = c.call(func() error {
+		capacity, err = c.client.Capacity()
+		return err
+	})
+	return capacity, err
+}
+
+func (c *CircuitBreakerGardenClient) Create(spec garden.ContainerSpec) (container garden.Container, err error) {
+	err = c.call(func() error {
+		container, err = c.client.Create(spec)
+		return err
+	})
+	return container, err
+}
+
+func (c *CircuitBreakerGardenClient) Destroy(handle string) error {
+	return c.call(func() error {
+		return c.client.Destroy(handle)
+	})
+}
+
+func (c *CircuitBreakerGardenClient) Containers(properties garden.Properties) (containers []garden.Container, err error) {
+	err = c.call(func() error {
+		containers, err = c.client.Containers(properties)
+		return err
+	})
+	return containers, err
+}
+
+func (c *CircuitBreakerGardenClient) BulkInfo(handles []string) (infos map[string]garden.ContainerInfoEntry, err error) {
+	err = c.call(func() error {
+		infos, err = c.client.BulkInfo(handles)
+		return err
+	})
+	return infos, err
+}
+
+func (c *CircuitBreakerGardenClient) BulkMetrics(handles []string) (metrics map[string]garden.ContainerMetricsEntry, err error) {
+	err = c.call(func() error {
+		metrics, err = c.client.BulkMetrics(handles)
+		return err
+	})
+	return metrics, err
+}
+
+func (c *CircuitBreakerGardenClient) Lookup(handle string) (container garden.Container, err error) {
+	err = c.call(func() error {
+		container, err = c.client.Lookup(handle)
+		return err
+	})
+	return container, err
+}
+
+func (c *CircuitBreakerGardenClient) call(fn func() error) error {
+	c.lock.Lock()
+	if c.clock.Now().Before(c.openUntil) {
+		c.lock.Unlock()
+		return ErrGardenCircuitOpen
+	}
+	c.lock.Unlock()
+
+	err := fn()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !IsConnectionError(err) {
+		if c.failures >= c.threshold {
+			c.logger.Info("circuit-closed")
+		}
+		c.failures = 0
+		return err
+	}
+
+	c.failures++
+	if c.failures >= c.threshold {
+		c.openUntil = c.clock.Now().Add(c.openDuration)
+		c.logger.Error("circuit-opened", err, lager.Data{
+			"consecutive-failures": c.failures,
+			"open-duration":        c.openDuration.String(),
+		})
+	}
+
+	return err
+}
+
+// IsConnectionError reports whether err indicates that garden itself could
+// not be reached. Errors garden returns for a request, such as a missing
+// container or a bad rootfs, say nothing about garden's health.
+func IsConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+func isCircuitOpen(err error) bool {
+	return err == ErrGardenCircuitOpen
+}
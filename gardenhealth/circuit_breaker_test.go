@@ -0,0 +1,147 @@
+package gardenhealth_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/gardenhealth"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("CircuitBreakerGardenClient", func() {
+	const (
+		threshold    = 3
+		openDuration = 30 * time.Second
+	)
+
+	var (
+		logger       *lagertest.TestLogger
+		gardenClient *gardenfakes.FakeClient
+		fakeClock    *fakeclock.FakeClock
+		client       *gardenhealth.CircuitBreakerGardenClient
+		gardenErr    error
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		gardenClient = &gardenfakes.FakeClient{}
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		gardenErr = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		client = gardenhealth.NewCircuitBreakerGardenClient(logger, gardenClient, threshold, openDuration, fakeClock)
+	})
+
+	failUntilOpen := func() {
+		gardenClient.PingReturns(gardenErr)
+		for i := 0; i < threshold; i++ {
+			Expect(client.Ping()).To(Equal(gardenErr))
+		}
+	}
+
+	It("passes calls and their results through to garden", func() {
+		gardenClient.CapacityReturns(garden.Capacity{MemoryInBytes: 1024}, nil)
+
+		capacity, err := client.Capacity()
+		Expect(err).NotTo(HaveOccurred())
+		Expect(capacity).To(Equal(garden.Capacity{MemoryInBytes: 1024}))
+		Expect(gardenClient.CapacityCallCount()).To(Equal(1))
+	})
+
+	Context("when garden fails fewer times in a row than the threshold", func() {
+		It("keeps calling garden", func() {
+			gardenClient.PingReturnsOnCall(0, gardenErr)
+			gardenClient.PingReturnsOnCall(1, gardenErr)
+			gardenClient.PingReturnsOnCall(2, nil)
+			gardenClient.PingReturnsOnCall(3, gardenErr)
+			gardenClient.PingReturnsOnCall(4, gardenErr)
+
+			for i := 0; i < 5; i++ {
+				client.Ping()
+			}
+
+			Expect(client.Ping()).NotTo(Equal(gardenhealth.ErrGardenCircuitOpen))
+			Expect(gardenClient.PingCallCount()).To(Equal(6))
+		})
+	})
+
+	Context("when garden fails threshold times in a row", func() {
+		BeforeEach(func() {
+			failUntilOpen()
+		})
+
+		It("rejects every call without calling garden", func() {
+			Expect(client.Ping()).To(Equal(gardenhealth.ErrGardenCircuitOpen))
+
+			_, err := client.Lookup("some-handle")
+			Expect(err).To(Equal(gardenhealth.ErrGardenCircuitOpen))
+
+			Expect(client.Destroy("some-handle")).To(Equal(gardenhealth.ErrGardenCircuitOpen))
+
+			Expect(gardenClient.PingCallCount()).To(Equal(threshold))
+			Expect(gardenClient.LookupCallCount()).To(Equal(0))
+			Expect(gardenClient.DestroyCallCount()).To(Equal(0))
+		})
+
+		It("logs that the circuit opened", func() {
+			Expect(logger).To(gbytes.Say("garden-circuit-breaker.circuit-opened"))
+		})
+
+		Context("and the open duration elapses", func() {
+			BeforeEach(func() {
+				fakeClock.Increment(openDuration)
+			})
+
+			It("closes the circuit when the next call succeeds", func() {
+				gardenClient.PingReturns(nil)
+
+				Expect(client.Ping()).To(Succeed())
+				Expect(client.Ping()).To(Succeed())
+				Expect(gardenClient.PingCallCount()).To(Equal(threshold + 2))
+				Expect(logger).To(gbytes.Say("garden-circuit-breaker.circuit-closed"))
+			})
+
+			It("re-opens the circuit when the next call fails", func() {
+				Expect(client.Ping()).To(Equal(gardenErr))
+				Expect(client.Ping()).To(Equal(gardenhealth.ErrGardenCircuitOpen))
+				Expect(gardenClient.PingCallCount()).To(Equal(threshold + 1))
+			})
+		})
+	})
+
+	Context("when garden rejects a request", func() {
+		It("does not count it as a failure", func() {
+			createErr := errors.New("invalid rootfs")
+			gardenClient.CreateReturns(nil, createErr)
+
+			for i := 0; i < threshold; i++ {
+				_, err := client.Create(garden.ContainerSpec{})
+				Expect(err).To(Equal(createErr))
+			}
+
+			Expect(client.Ping()).To(Succeed())
+			Expect(gardenClient.PingCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when garden reports that a container does not exist", func() {
+		It("does not count it as a failure", func() {
+			gardenClient.LookupReturns(nil, garden.ContainerNotFoundError{Handle: "missing"})
+
+			for i := 0; i < threshold; i++ {
+				_, err := client.Lookup("missing")
+				Expect(err).To(Equal(garden.ContainerNotFoundError{Handle: "missing"}))
+			}
+
+			_, err := client.Lookup("missing")
+			Expect(err).NotTo(Equal(gardenhealth.ErrGardenCircuitOpen))
+			Expect(gardenClient.LookupCallCount()).To(Equal(threshold + 1))
+		})
+	})
+})
@@ -10,7 +10,11 @@ import (
 	"code.cloudfoundry.org/lager"
 )
 
-const GardenHealthCheckFailedMetric = "GardenHealthCheckFailed"
+const (
+	GardenHealthCheckFailedMetric       = "GardenHealthCheckFailed"
+	GardenHealthCheckFailureCountMetric = "GardenHealthCheckFailureCount"
+	GardenCircuitOpenMetric             = "GardenCircuitOpen"
+)
 
 type HealthcheckTimeoutError struct{}
 
@@ -25,6 +29,7 @@ func (HealthcheckTimeoutError) Error() string {
 type Runner struct {
 	failures         int
 	healthy          bool
+	circuitOpen      bool
 	checkInterval    time.Duration
 	emissionInterval time.Duration
 	timeoutInterval  time.Duration
@@ -92,6 +97,7 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 		return HealthcheckTimeoutError{}
 
 	case err := <-healthcheckComplete:
+		r.circuitOpen = isCircuitOpen(err)
 		if err != nil {
 			r.setUnhealthy(logger)
 			return err
@@ -127,6 +133,7 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 		case err := <-healthcheckComplete:
 			timeoutOk := healthcheckTimeout.Stop()
+			r.circuitOpen = isCircuitOpen(err)
 			switch err.(type) {
 			case nil:
 				if timeoutOk {
@@ -144,12 +151,14 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 func (r *Runner) setHealthy(logger lager.Logger) {
 	r.logger.Info("set-state-healthy")
+	r.failures = 0
 	r.executorClient.SetHealthy(logger, true)
 	r.emitUnhealthyCellMetric(logger)
 }
 
 func (r *Runner) setUnhealthy(logger lager.Logger) {
 	r.logger.Error("set-state-unhealthy", nil)
+	r.failures++
 	r.executorClient.SetHealthy(logger, false)
 	r.emitUnhealthyCellMetric(logger)
 }
@@ -165,6 +174,22 @@ func (r *Runner) emitUnhealthyCellMetric(logger lager.Logger) {
 	if err != nil {
 		logger.Error("failed-to-send-unhealthy-cell-metric", err)
 	}
+
+	// consecutive failures since the last successful healthcheck
+	err = r.metronClient.SendMetric(GardenHealthCheckFailureCountMetric, r.failures)
+	if err != nil {
+		logger.Error("failed-to-send-failure-count-metric", err)
+	}
+
+	// garden calls are being rejected by the circuit breaker rather than failing
+	circuitOpen := 0
+	if r.circuitOpen {
+		circuitOpen = 1
+	}
+	err = r.metronClient.SendMetric(GardenCircuitOpenMetric, circuitOpen)
+	if err != nil {
+		logger.Error("failed-to-send-circuit-open-metric", err)
+	}
 }
 
 func (r *Runner) healthcheckCycle(logger lager.Logger, healthcheckComplete chan<- error) {
@@ -38,6 +38,8 @@ var _ = Describe("Runner", func() {
 	)
 
 	const GardenHealthCheckFailed = "GardenHealthCheckFailed"
+	const GardenHealthCheckFailureCount = "GardenHealthCheckFailureCount"
+	const GardenCircuitOpen = "GardenCircuitOpen"
 
 	BeforeEach(func() {
 		logger = lagertest.NewTestLogger("test")
@@ -219,6 +221,47 @@ var _ = Describe("Runner", func() {
 				Expect(healthy).Should(Equal(true))
 				Eventually(getMetrics).Should(HaveKeyWithValue(GardenHealthCheckFailed, float64(0)))
 			})
+
+			It("counts consecutive failures and resets the count after a success", func() {
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(1))
+				Eventually(getMetrics).Should(HaveKeyWithValue(GardenHealthCheckFailureCount, float64(0)))
+
+				Expect(healthyValues).To(BeSent(false))
+				checkValues <- errors.New("boom")
+				fakeClock.WaitForWatcherAndIncrement(checkInterval)
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(2))
+				Eventually(getMetrics).Should(HaveKeyWithValue(GardenHealthCheckFailureCount, float64(1)))
+
+				Expect(healthyValues).To(BeSent(false))
+				checkValues <- errors.New("boom")
+				fakeClock.WaitForNWatchersAndIncrement(checkInterval, 2)
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(3))
+				Eventually(getMetrics).Should(HaveKeyWithValue(GardenHealthCheckFailureCount, float64(2)))
+
+				Expect(healthyValues).To(BeSent(true))
+				checkValues <- nil
+				fakeClock.WaitForNWatchersAndIncrement(checkInterval, 2)
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(4))
+				Eventually(getMetrics).Should(HaveKeyWithValue(GardenHealthCheckFailureCount, float64(0)))
+			})
+
+			It("emits a distinct metric while the garden circuit breaker is open", func() {
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(1))
+				Eventually(getMetrics).Should(HaveKeyWithValue(GardenCircuitOpen, float64(0)))
+
+				Expect(healthyValues).To(BeSent(false))
+				checkValues <- gardenhealth.ErrGardenCircuitOpen
+				fakeClock.WaitForWatcherAndIncrement(checkInterval)
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(2))
+				Eventually(getMetrics).Should(HaveKeyWithValue(GardenCircuitOpen, float64(1)))
+				Expect(getMetrics()).To(HaveKeyWithValue(GardenHealthCheckFailed, float64(1)))
+
+				Expect(healthyValues).To(BeSent(true))
+				checkValues <- nil
+				fakeClock.WaitForNWatchersAndIncrement(checkInterval, 2)
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(3))
+				Eventually(getMetrics).Should(HaveKeyWithValue(GardenCircuitOpen, float64(0)))
+			})
 		})
 
 		Context("When the healthcheck times out", func() {
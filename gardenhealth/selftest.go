@@ -0,0 +1,215 @@
+package gardenhealth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/guidgen"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	SelfTestPrefix = "selftest-"
+
+	SelfTestPhaseAllocate    = "allocate"
+	SelfTestPhaseCreate      = "create"
+	SelfTestPhaseRun         = "run"
+	SelfTestPhaseHealthcheck = "healthcheck"
+	SelfTestPhaseStop        = "stop"
+	SelfTestPhaseDelete      = "delete"
+)
+
+var ErrSelfTestInProgress = errors.New("a self-test is already in progress")
+
+// SelfTestPhaseResult reports the outcome of one phase of a self-test.
+type SelfTestPhaseResult struct {
+	Phase      string `json:"phase"`
+	Passed     bool   `json:"passed"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// SelfTestResult reports the outcome of a whole self-test.
+type SelfTestResult struct {
+	Passed     bool                  `json:"passed"`
+	DurationMs int64                 `json:"duration_ms"`
+	Phases     []SelfTestPhaseResult `json:"phases"`
+}
+
+// SelfTester exercises the full container lifecycle against garden on
+// demand, so that operators can prove a cell can run work end-to-end.
+//
+// The synthetic container is owned by the healthcheck container owner and
+// tagged like a healthcheck container, so it never counts towards the
+// executor's allocatable capacity or app metrics, and is cleaned up by the
+// garden health checker if a self-test is interrupted.
+type SelfTester struct {
+	rootFSPath         string
+	containerOwnerName string
+	processUser        string
+	gardenClient       garden.Client
+	guidGenerator      guidgen.Generator
+	clock              clock.Clock
+
+	// running admits one self-test at a time.
+	running chan struct{}
+}
+
+func NewSelfTester(
+	rootFSPath string,
+	containerOwnerName string,
+	processUser string,
+	gardenClient garden.Client,
+	guidGenerator guidgen.Generator,
+	clock clock.Clock,
+) *SelfTester {
+	return &SelfTester{
+		rootFSPath:         rootFSPath,
+		containerOwnerName: containerOwnerName,
+		processUser:        processUser,
+		gardenClient:       gardenClient,
+		guidGenerator:      guidGenerator,
+		clock:              clock,
+		running:            make(chan struct{}, 1),
+	}
+}
+
+// Run allocates, creates, runs a trivial process in, health-checks, stops
+// and deletes a synthetic container. Each phase result is written to
+// progress as a line of JSON as soon as it completes, followed by the overall
+// result. A failed phase skips the phases after it, except delete, which
+// always runs once the container has been created.
+//
+// Only one self-test runs at a time; Run returns ErrSelfTestInProgress if
+// another is already running.
+func (s *SelfTester) Run(logger lager.Logger, progress io.Writer) (SelfTestResult, error) {
+	select {
+	case s.running <- struct{}{}:
+		defer func() { <-s.running }()
+	default:
+		return SelfTestResult{}, ErrSelfTestInProgress
+	}
+
+	logger = logger.Session("self-test")
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	encoder := json.NewEncoder(progress)
+	result := SelfTestResult{Passed: true}
+	started := s.clock.Now()
+
+	phase := func(name string, fn func() error) bool {
+		phaseStarted := s.clock.Now()
+		err := fn()
+
+		phaseResult := SelfTestPhaseResult{
+			Phase:      name,
+			Passed:     err == nil,
+			DurationMs: s.clock.Since(phaseStarted).Nanoseconds() / int64(time.Millisecond),
+		}
+		if err != nil {
+			logger.Error("phase-failed", err, lager.Data{"phase": name})
+			phaseResult.Error = err.Error()
+			result.Passed = false
+		}
+
+		result.Phases = append(result.Phases, phaseResult)
+		if encodeErr := encoder.Encode(phaseResult); encodeErr != nil {
+			logger.Error("failed-to-write-progress", encodeErr, lager.Data{"phase": name})
+		}
+
+		return err == nil
+	}
+
+	var (
+		guid      string
+		container garden.Container
+	)
+
+	passed := phase(SelfTestPhaseAllocate, func() error {
+		guid = SelfTestPrefix + s.guidGenerator.Guid(logger)
+		_, err := s.gardenClient.Capacity()
+		return err
+	})
+
+	if passed {
+		passed = phase(SelfTestPhaseCreate, func() error {
+			var err error
+			container, err = s.gardenClient.Create(garden.ContainerSpec{
+				Handle:     guid,
+				RootFSPath: s.rootFSPath,
+				Properties: garden.Properties{
+					executor.ContainerOwnerProperty: s.containerOwnerName,
+					HealthcheckTag:                  HealthcheckTagValue,
+					HealthcheckNetworkProperty:      "true",
+				},
+			})
+			return err
+		})
+
+		if passed {
+			passed = phase(SelfTestPhaseRun, func() error {
+				return s.runProcess(container)
+			})
+		}
+
+		if passed {
+			passed = phase(SelfTestPhaseHealthcheck, func() error {
+				info, err := container.Info()
+				if err != nil {
+					return err
+				}
+				if info.State != "active" {
+					return fmt.Errorf("container is %q, not active", info.State)
+				}
+				return nil
+			})
+		}
+
+		if passed {
+			phase(SelfTestPhaseStop, func() error {
+				return container.Stop(false)
+			})
+		}
+
+		if container != nil {
+			phase(SelfTestPhaseDelete, func() error {
+				return s.gardenClient.Destroy(guid)
+			})
+		}
+	}
+
+	result.DurationMs = s.clock.Since(started).Nanoseconds() / int64(time.Millisecond)
+	if err := encoder.Encode(result); err != nil {
+		logger.Error("failed-to-write-result", err)
+	}
+
+	return result, nil
+}
+
+func (s *SelfTester) runProcess(container garden.Container) error {
+	process, err := container.Run(garden.ProcessSpec{
+		Path: "echo",
+		Args: []string{"executor self-test"},
+		User: s.processUser,
+	}, garden.ProcessIO{})
+	if err != nil {
+		return err
+	}
+
+	exitCode, err := process.Wait()
+	if err != nil {
+		return err
+	}
+	if exitCode != 0 {
+		return HealthcheckFailedError(exitCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package gardenhealth_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/gardenhealth"
+	"code.cloudfoundry.org/executor/guidgen/fakeguidgen"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SelfTester", func() {
+	var (
+		logger          *lagertest.TestLogger
+		gardenClient    *gardenfakes.FakeClient
+		gardenContainer *gardenfakes.FakeContainer
+		gardenProcess   *gardenfakes.FakeProcess
+		fakeClock       *fakeclock.FakeClock
+		selfTester      *gardenhealth.SelfTester
+		progress        *bytes.Buffer
+		result          gardenhealth.SelfTestResult
+		err             error
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		gardenClient = &gardenfakes.FakeClient{}
+		gardenContainer = &gardenfakes.FakeContainer{}
+		gardenProcess = &gardenfakes.FakeProcess{}
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		progress = new(bytes.Buffer)
+
+		guidGenerator := &fakeguidgen.FakeGenerator{}
+		guidGenerator.GuidReturns("abc-123")
+
+		gardenClient.CreateReturns(gardenContainer, nil)
+		gardenContainer.RunReturns(gardenProcess, nil)
+		gardenContainer.InfoReturns(garden.ContainerInfo{State: "active"}, nil)
+
+		selfTester = gardenhealth.NewSelfTester("test-rootfs-path", "healthcheck-owner", "vcap", gardenClient, guidGenerator, fakeClock)
+	})
+
+	JustBeforeEach(func() {
+		result, err = selfTester.Run(logger, progress)
+	})
+
+	phases := func() []string {
+		names := []string{}
+		for _, phase := range result.Phases {
+			names = append(names, phase.Phase)
+		}
+		return names
+	}
+
+	It("runs every phase against a synthetic healthcheck container", func() {
+		Expect(err).NotTo(HaveOccurred())
+		Expect(result.Passed).To(BeTrue())
+		Expect(phases()).To(Equal([]string{"allocate", "create", "run", "healthcheck", "stop", "delete"}))
+
+		Expect(gardenClient.CapacityCallCount()).To(Equal(1))
+
+		spec := gardenClient.CreateArgsForCall(0)
+		Expect(spec.Handle).To(Equal("selftest-abc-123"))
+		Expect(spec.RootFSPath).To(Equal("test-rootfs-path"))
+		Expect(spec.Properties).To(HaveKeyWithValue(executor.ContainerOwnerProperty, "healthcheck-owner"))
+		Expect(spec.Properties).To(HaveKeyWithValue(gardenhealth.HealthcheckTag, gardenhealth.HealthcheckTagValue))
+
+		processSpec, _ := gardenContainer.RunArgsForCall(0)
+		Expect(processSpec.Path).To(Equal("echo"))
+		Expect(processSpec.User).To(Equal("vcap"))
+
+		Expect(gardenContainer.StopCallCount()).To(Equal(1))
+		Expect(gardenClient.DestroyCallCount()).To(Equal(1))
+		Expect(gardenClient.DestroyArgsForCall(0)).To(Equal("selftest-abc-123"))
+	})
+
+	It("streams each phase and then the overall result as NDJSON", func() {
+		decoder := json.NewDecoder(progress)
+		for _, name := range []string{"allocate", "create", "run", "healthcheck", "stop", "delete"} {
+			var phase gardenhealth.SelfTestPhaseResult
+			Expect(decoder.Decode(&phase)).To(Succeed())
+			Expect(phase.Phase).To(Equal(name))
+			Expect(phase.Passed).To(BeTrue())
+		}
+
+		var overall gardenhealth.SelfTestResult
+		Expect(decoder.Decode(&overall)).To(Succeed())
+		Expect(overall.Passed).To(BeTrue())
+		Expect(overall.Phases).To(HaveLen(6))
+	})
+
+	Context("when a middle phase fails", func() {
+		BeforeEach(func() {
+			gardenProcess.WaitReturns(1, nil)
+		})
+
+		It("fails, skips the remaining phases and still deletes the container", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(result.Passed).To(BeFalse())
+			Expect(phases()).To(Equal([]string{"allocate", "create", "run", "delete"}))
+			Expect(result.Phases[2].Passed).To(BeFalse())
+			Expect(result.Phases[2].Error).To(Equal("Healthcheck exited with 1"))
+
+			Expect(gardenContainer.StopCallCount()).To(Equal(0))
+			Expect(gardenClient.DestroyCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when the container is not active", func() {
+		BeforeEach(func() {
+			gardenContainer.InfoReturns(garden.ContainerInfo{State: "stopped"}, nil)
+		})
+
+		It("fails the healthcheck phase and still deletes the container", func() {
+			Expect(result.Passed).To(BeFalse())
+			Expect(phases()).To(Equal([]string{"allocate", "create", "run", "healthcheck", "delete"}))
+			Expect(gardenClient.DestroyCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when the container cannot be created", func() {
+		BeforeEach(func() {
+			gardenClient.CreateReturns(nil, errors.New("boom"))
+		})
+
+		It("fails without trying to delete anything", func() {
+			Expect(result.Passed).To(BeFalse())
+			Expect(phases()).To(Equal([]string{"allocate", "create"}))
+			Expect(gardenClient.DestroyCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when a self-test is already running", func() {
+		var secondErr error
+
+		BeforeEach(func() {
+			gardenClient.CapacityStub = func() (garden.Capacity, error) {
+				_, secondErr = selfTester.Run(logger, new(bytes.Buffer))
+				return garden.Capacity{}, nil
+			}
+		})
+
+		It("rejects the second self-test", func() {
+			Expect(err).NotTo(HaveOccurred())
+			Expect(secondErr).To(Equal(gardenhealth.ErrSelfTestInProgress))
+			Expect(gardenClient.CapacityCallCount()).To(Equal(1))
+		})
+	})
+})
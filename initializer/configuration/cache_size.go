@@ -0,0 +1,113 @@
+package configuration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// DefaultCacheSizeWarningFraction is the fraction of its filesystem the
+// download cache may be configured to take before a warning is logged.
+const DefaultCacheSizeWarningFraction = 0.8
+
+var ErrFilesystemStatsUnsupported = errors.New("filesystem stats are not supported on this platform")
+
+// FilesystemStats describes the filesystem holding a path.
+type FilesystemStats struct {
+	TotalBytes     uint64
+	AvailableBytes uint64
+}
+
+//go:generate counterfeiter -o configurationfakes/fake_filesystem_stater.go . FilesystemStater
+type FilesystemStater interface {
+	Statfs(path string) (FilesystemStats, error)
+}
+
+// CacheExceedsFilesystemError is returned when the download cache is
+// configured to be larger than the filesystem it is stored on.
+type CacheExceedsFilesystemError struct {
+	CachePath           string
+	MaxCacheSizeInBytes uint64
+	FilesystemBytes     uint64
+}
+
+func (e CacheExceedsFilesystemError) Error() string {
+	return fmt.Sprintf(
+		"max_cache_size_in_bytes (%d) is larger than the filesystem holding cache_path %s (%d bytes)",
+		e.MaxCacheSizeInBytes, e.CachePath, e.FilesystemBytes,
+	)
+}
+
+// CacheUsage returns the number of bytes held in regular files under
+// cachePath. A missing cachePath holds nothing.
+func CacheUsage(cachePath string) (uint64, error) {
+	var usage uint64
+	err := filepath.Walk(cachePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			usage += uint64(info.Size())
+		}
+		return nil
+	})
+	return usage, err
+}
+
+// CheckCacheSize compares the configured cache size with the filesystem
+// holding cachePath. It fails if the cache could never fit and logs a warning
+// if it takes more than warningFraction of the filesystem. It returns the
+// number of bytes the cache can actually grow to: the free space on the
+// filesystem plus the cachedBytes the cache already holds there.
+//
+// If the filesystem cannot be inspected, the configured size is returned
+// unchecked.
+func CheckCacheSize(
+	logger lager.Logger,
+	stater FilesystemStater,
+	cachePath string,
+	maxCacheSizeInBytes uint64,
+	cachedBytes uint64,
+	warningFraction float64,
+) (uint64, error) {
+	logger = logger.Session("check-cache-size", lager.Data{"cache-path": cachePath})
+
+	stats, err := stater.Statfs(cachePath)
+	if err != nil {
+		logger.Error("failed-to-stat-cache-filesystem", err)
+		return maxCacheSizeInBytes, nil
+	}
+
+	logData := lager.Data{
+		"max-cache-size-in-bytes": maxCacheSizeInBytes,
+		"filesystem-bytes":        stats.TotalBytes,
+		"available-bytes":         stats.AvailableBytes,
+		"cached-bytes":            cachedBytes,
+	}
+
+	if maxCacheSizeInBytes > stats.TotalBytes {
+		err := CacheExceedsFilesystemError{
+			CachePath:           cachePath,
+			MaxCacheSizeInBytes: maxCacheSizeInBytes,
+			FilesystemBytes:     stats.TotalBytes,
+		}
+		logger.Error("cache-exceeds-filesystem", err, logData)
+		return 0, err
+	}
+
+	if float64(maxCacheSizeInBytes) > warningFraction*float64(stats.TotalBytes) {
+		logger.Info("cache-may-fill-filesystem", logData)
+	}
+
+	usableBytes := stats.AvailableBytes + cachedBytes
+	if maxCacheSizeInBytes > usableBytes {
+		return usableBytes, nil
+	}
+	return maxCacheSizeInBytes, nil
+}
@@ -2,12 +2,16 @@ package configuration_test
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/fakes"
 	"code.cloudfoundry.org/executor/guidgen/fakeguidgen"
 	"code.cloudfoundry.org/executor/initializer/configuration"
+	"code.cloudfoundry.org/executor/initializer/configuration/configurationfakes"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
@@ -203,6 +207,118 @@ var _ = Describe("configuration", func() {
 		})
 	})
 
+	Describe("CacheUsage", func() {
+		var cachePath string
+
+		BeforeEach(func() {
+			var err error
+			cachePath, err = ioutil.TempDir("", "cache-usage")
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(cachePath)
+		})
+
+		It("sums the sizes of the files in the cache", func() {
+			Expect(os.Mkdir(filepath.Join(cachePath, "dir"), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(cachePath, "a"), make([]byte, 10), 0644)).To(Succeed())
+			Expect(ioutil.WriteFile(filepath.Join(cachePath, "dir", "b"), make([]byte, 5), 0644)).To(Succeed())
+
+			Expect(configuration.CacheUsage(cachePath)).To(BeEquivalentTo(15))
+		})
+
+		It("returns 0 when the cache does not exist", func() {
+			Expect(configuration.CacheUsage(filepath.Join(cachePath, "missing"))).To(BeEquivalentTo(0))
+		})
+	})
+
+	Describe("CheckCacheSize", func() {
+		var (
+			logger              *lagertest.TestLogger
+			stater              *configurationfakes.FakeFilesystemStater
+			maxCacheSizeInBytes uint64
+			cachedBytes         uint64
+			cacheSize           uint64
+			err                 error
+		)
+
+		BeforeEach(func() {
+			logger = lagertest.NewTestLogger("test")
+			cachedBytes = 0
+			stater = new(configurationfakes.FakeFilesystemStater)
+			stater.StatfsReturns(configuration.FilesystemStats{TotalBytes: 1000, AvailableBytes: 600}, nil)
+		})
+
+		JustBeforeEach(func() {
+			cacheSize, err = configuration.CheckCacheSize(logger, stater, "/var/cache", maxCacheSizeInBytes, cachedBytes, 0.8)
+		})
+
+		Context("when the cache fits comfortably", func() {
+			BeforeEach(func() {
+				maxCacheSizeInBytes = 500
+			})
+
+			It("returns the configured size", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cacheSize).To(BeEquivalentTo(500))
+				Expect(stater.StatfsArgsForCall(0)).To(Equal("/var/cache"))
+				Expect(logger).NotTo(gbytes.Say("cache-may-fill-filesystem"))
+			})
+		})
+
+		Context("when the cache takes more than the warning fraction of the filesystem", func() {
+			BeforeEach(func() {
+				maxCacheSizeInBytes = 900
+			})
+
+			It("warns and returns the space the cache can still grow into", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cacheSize).To(BeEquivalentTo(600))
+				Expect(logger).To(gbytes.Say("cache-may-fill-filesystem"))
+			})
+		})
+
+		Context("when the cache already holds bytes on the filesystem", func() {
+			BeforeEach(func() {
+				maxCacheSizeInBytes = 900
+				cachedBytes = 200
+			})
+
+			It("counts them as space the cache can use", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cacheSize).To(BeEquivalentTo(800))
+			})
+		})
+
+		Context("when the cache is larger than the filesystem", func() {
+			BeforeEach(func() {
+				maxCacheSizeInBytes = 1001
+			})
+
+			It("returns a descriptive error", func() {
+				Expect(err).To(Equal(configuration.CacheExceedsFilesystemError{
+					CachePath:           "/var/cache",
+					MaxCacheSizeInBytes: 1001,
+					FilesystemBytes:     1000,
+				}))
+				Expect(err.Error()).To(ContainSubstring("/var/cache"))
+			})
+		})
+
+		Context("when the filesystem cannot be inspected", func() {
+			BeforeEach(func() {
+				maxCacheSizeInBytes = 5000
+				stater.StatfsReturns(configuration.FilesystemStats{}, configuration.ErrFilesystemStatsUnsupported)
+			})
+
+			It("returns the configured size unchecked", func() {
+				Expect(err).NotTo(HaveOccurred())
+				Expect(cacheSize).To(BeEquivalentTo(5000))
+			})
+		})
+	})
+
 	Describe("GetRootFSSizes", func() {
 		var (
 			logger   lager.Logger
@@ -0,0 +1,25 @@
+//go:build !windows
+// +build !windows
+
+package configuration
+
+import "syscall"
+
+type syscallFilesystemStater struct{}
+
+func NewFilesystemStater() FilesystemStater {
+	return syscallFilesystemStater{}
+}
+
+func (syscallFilesystemStater) Statfs(path string) (FilesystemStats, error) {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(path, &stat)
+	if err != nil {
+		return FilesystemStats{}, err
+	}
+
+	return FilesystemStats{
+		TotalBytes:     uint64(stat.Blocks) * uint64(stat.Bsize),
+		AvailableBytes: uint64(stat.Bavail) * uint64(stat.Bsize),
+	}, nil
+}
@@ -0,0 +1,11 @@
+package configuration
+
+type unsupportedFilesystemStater struct{}
+
+func NewFilesystemStater() FilesystemStater {
+	return unsupportedFilesystemStater{}
+}
+
+func (unsupportedFilesystemStater) Statfs(path string) (FilesystemStats, error) {
+	return FilesystemStats{}, ErrFilesystemStatsUnsupported
+}
@@ -5,11 +5,14 @@ import (
 	"crypto/rand"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"time"
@@ -22,11 +25,20 @@ import (
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/containermetrics"
 	"code.cloudfoundry.org/executor/depot"
+	"code.cloudfoundry.org/executor/depot/cacheinventory"
+	"code.cloudfoundry.org/executor/depot/compresseddownloader"
 	"code.cloudfoundry.org/executor/depot/containerstore"
 	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/executor/depot/healthcheckpool"
+	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/executor/depot/metrics"
+	"code.cloudfoundry.org/executor/depot/resourceregistry"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/executor/depot/tarsanitizer"
 	"code.cloudfoundry.org/executor/depot/transformer"
 	"code.cloudfoundry.org/executor/depot/uploader"
+	"code.cloudfoundry.org/executor/depot/workpoolstats"
+	"code.cloudfoundry.org/executor/gardenfailover"
 	"code.cloudfoundry.org/executor/gardenhealth"
 	"code.cloudfoundry.org/executor/guidgen"
 	"code.cloudfoundry.org/executor/initializer/configuration"
@@ -39,16 +51,33 @@ import (
 	"code.cloudfoundry.org/volman/vollocal"
 	"code.cloudfoundry.org/workpool"
 	"github.com/google/shlex"
-	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
+	"golang.org/x/net/http/httpproxy"
 )
 
 const (
-	PingGardenInterval             = time.Second
-	StalledMetricHeartbeatInterval = 5 * time.Second
-	StalledGardenDuration          = "StalledGardenDuration"
-	maxConcurrentUploads           = 5
-	metricsReportInterval          = 1 * time.Minute
+	PingGardenInterval              = time.Second
+	StalledMetricHeartbeatInterval  = 5 * time.Second
+	StalledGardenDuration           = "StalledGardenDuration"
+	maxConcurrentUploads            = 5
+	metricsReportInterval           = 1 * time.Minute
+	DefaultPrunerJitterFraction     = 0.1
+	DefaultPreDestroyHookTimeout    = 30 * time.Second
+	DefaultFinalMetricsTimeout      = time.Second
+	DefaultInternalIPWaitTimeout    = 5 * time.Second
+	DefaultMaxContainerReapInterval = 5 * time.Minute
+	DefaultRestartBackoffBase       = time.Second
+	DefaultRestartBackoffMax        = 5 * time.Minute
+	DefaultResourceRegistrySlack    = 10
+	DefaultCacheDiskCheckInterval   = 30 * time.Second
+	DefaultEventReplayBufferSize    = 1024
+	DefaultProcessWhitelistInterval = 30 * time.Second
+	DefaultGardenFailoverThreshold  = 3
+
+	DefaultCompletionCallbackWorkPoolSize = 8
+	DefaultCompletionCallbackMaxAttempts  = 3
+	DefaultCompletionCallbackBackoff      = time.Second
+	completionCallbackTimeout             = 10 * time.Second
 )
 
 type executorContainers struct {
@@ -79,14 +108,24 @@ func (s systemcertsRetriever) SystemCerts() *x509.CertPool {
 
 type ExecutorConfig struct {
 	AdvertisePreferenceForInstanceAddress bool                  `json:"advertise_preference_for_instance_address"`
+	AsyncContainerDeletion                bool                  `json:"async_container_deletion,omitempty"`
 	AutoDiskOverheadMB                    int                   `json:"auto_disk_capacity_overhead_mb"`
 	CSIMountRootDir                       string                `json:"csi_mount_root_dir"`
 	CSIPaths                              []string              `json:"csi_paths"`
+	CacheDiskCheckInterval                durationjson.Duration `json:"cache_disk_check_interval,omitempty"`
+	CacheDiskPressurePercent              float64               `json:"cache_disk_pressure_percent,omitempty"`
 	CachePath                             string                `json:"cache_path,omitempty"`
+	CacheSizeWarningFraction              float64               `json:"cache_size_warning_fraction,omitempty"`
+	CircuitBreakerOpenDuration            durationjson.Duration `json:"circuit_breaker_open_duration,omitempty"`
+	CircuitBreakerThreshold               int                   `json:"circuit_breaker_threshold,omitempty"`
+	CompletionCallbackBackoff             durationjson.Duration `json:"completion_callback_backoff,omitempty"`
+	CompletionCallbackMaxAttempts         int                   `json:"completion_callback_max_attempts,omitempty"`
+	CompletionCallbackWorkPoolSize        int                   `json:"completion_callback_work_pool_size,omitempty"`
 	ContainerInodeLimit                   uint64                `json:"container_inode_limit,omitempty"`
 	ContainerMaxCpuShares                 uint64                `json:"container_max_cpu_shares,omitempty"`
 	ContainerMetricsReportInterval        durationjson.Duration `json:"container_metrics_report_interval,omitempty"`
 	ContainerOwnerName                    string                `json:"container_owner_name,omitempty"`
+	ContainerPortProbeInterval            durationjson.Duration `json:"container_port_probe_interval,omitempty"`
 	ContainerProxyADSServers              []string              `json:"container_proxy_ads_addresses,omitempty"`
 	ContainerProxyConfigPath              string                `json:"container_proxy_config_path,omitempty"`
 	ContainerProxyPath                    string                `json:"container_proxy_path,omitempty"`
@@ -94,18 +133,33 @@ type ExecutorConfig struct {
 	ContainerProxyTrustedCACerts          []string              `json:"container_proxy_trusted_ca_certs"`
 	ContainerProxyVerifySubjectAltName    []string              `json:"container_proxy_verify_subject_alt_name"`
 	ContainerReapInterval                 durationjson.Duration `json:"container_reap_interval,omitempty"`
+	ContainerRestartBackoffBase           durationjson.Duration `json:"container_restart_backoff_base,omitempty"`
+	ContainerRestartBackoffMax            durationjson.Duration `json:"container_restart_backoff_max,omitempty"`
+	ContainerRestartWindow                durationjson.Duration `json:"container_restart_window,omitempty"`
 	CreateWorkPoolSize                    int                   `json:"create_work_pool_size,omitempty"`
 	DeclarativeHealthcheckPath            string                `json:"declarative_healthcheck_path,omitempty"`
+	DefaultStartTimeout                   durationjson.Duration `json:"default_start_timeout,omitempty"`
 	DeleteWorkPoolSize                    int                   `json:"delete_work_pool_size,omitempty"`
 	DiskMB                                string                `json:"disk_mb,omitempty"`
+	EnableCompressedDownloads             bool                  `json:"enable_compressed_downloads,omitempty"`
+	EnableContainerPortProbe              bool                  `json:"enable_container_port_probe,omitempty"`
 	EnableContainerProxy                  bool                  `json:"enable_container_proxy,omitempty"`
 	EnableDeclarativeHealthcheck          bool                  `json:"enable_declarative_healthcheck,omitempty"`
+	EnableEnvInterpolation                bool                  `json:"enable_env_interpolation,omitempty"`
+	EnableExecutorHTTPHealthcheck         bool                  `json:"enable_executor_http_healthcheck,omitempty"`
+	EnableStoreLockWaitSampling           bool                  `json:"enable_store_lock_wait_sampling,omitempty"`
 	EnableUnproxiedPortMappings           bool                  `json:"enable_unproxied_port_mappings"`
+	EnableUploadResume                    bool                  `json:"enable_upload_resume,omitempty"`
 	EnvoyConfigRefreshDelay               durationjson.Duration `json:"envoy_config_refresh_delay"`
 	EnvoyConfigReloadDuration             durationjson.Duration `json:"envoy_config_reload_duration"`
 	EnvoyDrainTimeout                     durationjson.Duration `json:"envoy_drain_timeout,omitempty"`
-	ExportNetworkEnvVars                  bool                  `json:"export_network_env_vars,omitempty"` // DEPRECATED. Kept around for dusts compatability
+	EventHubDrainTimeout                  durationjson.Duration `json:"event_hub_drain_timeout,omitempty"`
+	EventReplayBufferSize                 int                   `json:"event_replay_buffer_size,omitempty"`
+	ExportNetworkEnvVars                  bool                  `json:"export_network_env_vars,omitempty"` // only exports the proxy settings now; the networking variables are always exported
+	FinalMetricsTimeout                   durationjson.Duration `json:"final_metrics_timeout,omitempty"`
 	GardenAddr                            string                `json:"garden_addr,omitempty"`
+	GardenAddrs                           []string              `json:"garden_addrs,omitempty"`
+	GardenFailoverThreshold               int                   `json:"garden_failover_threshold,omitempty"`
 	GardenHealthcheckCommandRetryPause    durationjson.Duration `json:"garden_healthcheck_command_retry_pause,omitempty"`
 	GardenHealthcheckEmissionInterval     durationjson.Duration `json:"garden_healthcheck_emission_interval,omitempty"`
 	GardenHealthcheckInterval             durationjson.Duration `json:"garden_healthcheck_interval,omitempty"`
@@ -118,36 +172,69 @@ type ExecutorConfig struct {
 	GardenNetwork                         string                `json:"garden_network,omitempty"`
 	GracefulShutdownInterval              durationjson.Duration `json:"graceful_shutdown_interval,omitempty"`
 	HealthCheckContainerOwnerName         string                `json:"healthcheck_container_owner_name,omitempty"`
+	HealthCheckReadinessReservedFraction  float64               `json:"healthcheck_readiness_reserved_fraction,omitempty"`
+	HealthCheckUser                       string                `json:"healthcheck_user,omitempty"`
 	HealthCheckWorkPoolSize               int                   `json:"healthcheck_work_pool_size,omitempty"`
 	HealthyMonitoringInterval             durationjson.Duration `json:"healthy_monitoring_interval,omitempty"`
+	HTTPProxy                             string                `json:"http_proxy,omitempty"`  // not applied to cached downloads, see errCachedDownloadsIgnoreProxy
+	HTTPSProxy                            string                `json:"https_proxy,omitempty"` // not applied to cached downloads, see errCachedDownloadsIgnoreProxy
+	ImportSnapshotPath                    string                `json:"import_snapshot_path,omitempty"`
 	InstanceIdentityCAPath                string                `json:"instance_identity_ca_path,omitempty"`
 	InstanceIdentityCredDir               string                `json:"instance_identity_cred_dir,omitempty"`
+	InstanceIdentityIntermediateCAPaths   []string              `json:"instance_identity_intermediate_ca_paths,omitempty"`
 	InstanceIdentityPrivateKeyPath        string                `json:"instance_identity_private_key_path,omitempty"`
+	InstanceIdentityRenewalWindow         durationjson.Duration `json:"instance_identity_renewal_window,omitempty"`
 	InstanceIdentityValidityPeriod        durationjson.Duration `json:"instance_identity_validity_period,omitempty"`
+	InternalIPWaitTimeout                 durationjson.Duration `json:"internal_ip_wait_timeout,omitempty"`
 	MaxCacheSizeInBytes                   uint64                `json:"max_cache_size_in_bytes,omitempty"`
 	MaxConcurrentDownloads                int                   `json:"max_concurrent_downloads,omitempty"`
+	MaxConcurrentDownloadsPerContainer    int                   `json:"max_concurrent_downloads_per_container,omitempty"`
+	MaxConcurrentGardenCreates            int                   `json:"max_concurrent_garden_creates,omitempty"`
+	MaxContainerInodeLimit                uint64                `json:"max_container_inode_limit,omitempty"`
+	MaxContainerReapInterval              durationjson.Duration `json:"max_container_reap_interval,omitempty"`
+	MaxDownloadSizeBytes                  int64                 `json:"max_download_size_bytes,omitempty"`
+	MaxGardenPropertiesPerContainer       int                   `json:"max_garden_properties_per_container,omitempty"`
+	MaxLogLineLength                      int                   `json:"max_log_line_length,omitempty"`
+	MaxStartTimeout                       durationjson.Duration `json:"max_start_timeout,omitempty"`
 	MemoryMB                              string                `json:"memory_mb,omitempty"`
 	MetricsWorkPoolSize                   int                   `json:"metrics_work_pool_size,omitempty"`
+	NoProxy                               string                `json:"no_proxy,omitempty"`
+	OnUnhealthyActionTimeout              durationjson.Duration `json:"on_unhealthy_action_timeout,omitempty"`
 	PathToCACertsForDownloads             string                `json:"path_to_ca_certs_for_downloads"`
 	PathToTLSCACert                       string                `json:"path_to_tls_ca_cert"`
 	PathToTLSCert                         string                `json:"path_to_tls_cert"`
 	PathToTLSKey                          string                `json:"path_to_tls_key"`
 	PostSetupHook                         string                `json:"post_setup_hook"`
+	PostSetupHookTimeout                  durationjson.Duration `json:"post_setup_hook_timeout,omitempty"`
 	PostSetupUser                         string                `json:"post_setup_user"`
+	PreDestroyHook                        []string              `json:"pre_destroy_hook,omitempty"`
+	PreDestroyHookTimeout                 durationjson.Duration `json:"pre_destroy_hook_timeout,omitempty"`
+	ProcessWhitelist                      []string              `json:"process_whitelist,omitempty"`
+	ProcessWhitelistInterval              durationjson.Duration `json:"process_whitelist_interval,omitempty"`
+	ProcessWrapperPath                    string                `json:"process_wrapper_path,omitempty"`
+	ProxyDrainTimeout                     durationjson.Duration `json:"proxy_drain_timeout,omitempty"`
 	ProxyMemoryAllocationMB               int                   `json:"proxy_memory_allocation_mb,omitempty"`
+	PrunerJitterFraction                  *float64              `json:"pruner_jitter_fraction,omitempty"`
 	ReadWorkPoolSize                      int                   `json:"read_work_pool_size,omitempty"`
 	ReservedExpirationTime                durationjson.Duration `json:"reserved_expiration_time,omitempty"`
+	ResourceRegistrySlack                 int                   `json:"resource_registry_slack,omitempty"`
 	SetCPUWeight                          bool                  `json:"set_cpu_weight,omitempty"`
 	SkipCertVerify                        bool                  `json:"skip_cert_verify,omitempty"`
+	StartTimeoutPolicy                    string                `json:"start_timeout_policy,omitempty"`
+	StartupReportPath                     string                `json:"startup_report_path,omitempty"`
+	TarSymlinkPolicy                      string                `json:"tar_symlink_policy,omitempty"`
 	TempDir                               string                `json:"temp_dir,omitempty"`
 	TrustedSystemCertificatesPath         string                `json:"trusted_system_certificates_path"`
 	UnhealthyMonitoringInterval           durationjson.Duration `json:"unhealthy_monitoring_interval,omitempty"`
 	VolmanDriverPaths                     string                `json:"volman_driver_paths"`
+	WarmupCacheURLs                       []string              `json:"warmup_cache_urls,omitempty"`
+	WarmupContainerCount                  int                   `json:"warmup_container_count,omitempty"`
+	WarmupRootFS                          string                `json:"warmup_rootfs,omitempty"`
 }
 
 var (
-	creationWorkPool, deletionWorkPool *workpool.WorkPool
-	metricsWorkPool, readWorkPool      *workpool.WorkPool
+	creationWorkPool, deletionWorkPool *workpoolstats.WorkPool
+	metricsWorkPool, readWorkPool      *workpoolstats.WorkPool
 )
 
 func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
@@ -166,35 +253,51 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 		return nil, nil, grouper.Members{}, err
 	}
 
-	gardenClient := GardenClient.New(GardenConnection.New(config.GardenNetwork, config.GardenAddr))
+	gardenClient := newGardenClient(logger, config)
 	err = waitForGarden(logger, gardenClient, metronClient, clock)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
+	if config.CircuitBreakerThreshold > 0 {
+		gardenClient = gardenhealth.NewCircuitBreakerGardenClient(
+			logger,
+			gardenClient,
+			config.CircuitBreakerThreshold,
+			time.Duration(config.CircuitBreakerOpenDuration),
+			clock,
+		)
+	}
+
 	containersFetcher := &executorContainers{
 		gardenClient: gardenClient,
 		owner:        config.ContainerOwnerName,
 	}
 
-	creationWorkPool, err = workpool.NewWorkPool(config.CreateWorkPoolSize)
+	creationWorkPool, err = workpoolstats.NewWorkPool(config.CreateWorkPoolSize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	deletionWorkPool, err = workpoolstats.NewWorkPool(config.DeleteWorkPoolSize)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	deletionWorkPool, err = workpool.NewWorkPool(config.DeleteWorkPoolSize)
+	readWorkPool, err = workpoolstats.NewWorkPool(config.ReadWorkPoolSize)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	readWorkPool, err = workpool.NewWorkPool(config.ReadWorkPoolSize)
+	metricsWorkPool, err = workpoolstats.NewWorkPool(config.MetricsWorkPoolSize)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	metricsWorkPool, err = workpool.NewWorkPool(config.MetricsWorkPoolSize)
+
+	snapshot, err := loadSnapshot(logger, config.ImportSnapshotPath)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
-	err = destroyContainers(gardenClient, containersFetcher, logger)
+	startupReport, err := destroyContainers(gardenClient, containersFetcher, snapshotHandles(snapshot), clock, logger)
+	writeStartupReport(logger, config.StartupReportPath, startupReport)
 	if err != nil {
 		return nil, nil, nil, err
 	}
@@ -203,6 +306,7 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 	if err != nil {
 		return nil, nil, grouper.Members{}, err
 	}
+	healthCheckPool := healthcheckpool.New(healthCheckWorkPool, config.HealthCheckWorkPoolSize, config.HealthCheckReadinessReservedFraction)
 
 	certsRetriever := systemcertsRetriever{}
 	assetTLSConfig, err := TLSConfigFromConfig(logger, certsRetriever, config)
@@ -210,15 +314,48 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 		return nil, nil, grouper.Members{}, err
 	}
 
+	proxy := proxyFunc(config)
+
+	// the cached downloader builds its own transport, which resolves proxies
+	// from the process environment rather than from the executor config
+	if config.HTTPProxy != "" || config.HTTPSProxy != "" {
+		logger.Error("cached-downloads-ignore-configured-proxy", errCachedDownloadsIgnoreProxy, lager.Data{
+			"http-proxy":  config.HTTPProxy,
+			"https-proxy": config.HTTPSProxy,
+		})
+	}
 	downloader := cacheddownloader.NewDownloader(10*time.Minute, int(math.MaxInt8), assetTLSConfig)
-	uploader := uploader.New(logger, 10*time.Minute, assetTLSConfig)
+	uploader := uploader.New(logger, 10*time.Minute, assetTLSConfig, proxy, config.EnableUploadResume, metronClient)
+
+	httpDownloader := compresseddownloader.New(10*time.Minute, assetTLSConfig, proxy)
+
+	var compressedFetcher steps.CompressedFetcher
+	if config.EnableCompressedDownloads {
+		compressedFetcher = httpDownloader
+	}
+
+	cacheSizeWarningFraction := config.CacheSizeWarningFraction
+	if cacheSizeWarningFraction == 0 {
+		cacheSizeWarningFraction = configuration.DefaultCacheSizeWarningFraction
+	}
 
-	cache := cacheddownloader.NewCache(config.CachePath, int64(config.MaxCacheSizeInBytes))
-	cachedDownloader := cacheddownloader.New(
+	cachedBytes, err := configuration.CacheUsage(config.CachePath)
+	if err != nil {
+		logger.Error("failed-to-measure-cache-usage", err)
+		cachedBytes = 0
+	}
+
+	cacheSizeInBytes, err := configuration.CheckCacheSize(logger, configuration.NewFilesystemStater(), config.CachePath, config.MaxCacheSizeInBytes, cachedBytes, cacheSizeWarningFraction)
+	if err != nil {
+		return nil, nil, grouper.Members{}, err
+	}
+
+	cache := cacheddownloader.NewCache(config.CachePath, int64(cacheSizeInBytes))
+	cachedDownloader := cacheinventory.New(cacheddownloader.New(
 		downloader,
 		cache,
 		cacheddownloader.TarTransform,
-	)
+	), cache, int64(cacheSizeInBytes), clock)
 
 	err = cachedDownloader.RecoverState(logger.Session("downloader"))
 	if err != nil {
@@ -226,29 +363,66 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 	}
 
 	downloadRateLimiter := make(chan struct{}, uint(config.MaxConcurrentDownloads))
+	uploadRateLimiter := make(chan struct{}, maxConcurrentUploads)
+
+	maxConcurrentDownloadsPerContainer := config.MaxConcurrentDownloadsPerContainer
+	if maxConcurrentDownloadsPerContainer == 0 {
+		maxConcurrentDownloadsPerContainer = config.MaxConcurrentDownloads
+	}
+
+	processWhitelistInterval := time.Duration(config.ProcessWhitelistInterval)
+	if processWhitelistInterval == 0 {
+		processWhitelistInterval = DefaultProcessWhitelistInterval
+	}
 
 	transformer := initializeTransformer(
 		cachedDownloader,
 		setupWorkDir(logger, config.TempDir),
 		downloadRateLimiter,
-		maxConcurrentUploads,
+		uploadRateLimiter,
 		uploader,
 		time.Duration(config.HealthyMonitoringInterval),
 		time.Duration(config.UnhealthyMonitoringInterval),
 		time.Duration(config.GracefulShutdownInterval),
 		healthCheckWorkPool,
+		healthCheckPool,
 		clock,
 		postSetupHook,
 		config.PostSetupUser,
+		time.Duration(config.PostSetupHookTimeout),
 		config.EnableDeclarativeHealthcheck,
+		config.EnableExecutorHTTPHealthcheck,
+		config.EnableEnvInterpolation,
+		config.HealthCheckUser,
 		gardenHealthcheckRootFS,
 		config.EnableContainerProxy,
 		time.Duration(config.EnvoyDrainTimeout),
+		tarsanitizer.SymlinkPolicy(config.TarSymlinkPolicy),
+		maxConcurrentDownloadsPerContainer,
+		config.MaxDownloadSizeBytes,
+		httpDownloader,
+		compressedFetcher,
+		config.ExportNetworkEnvVars,
+		config.HTTPProxy,
+		config.HTTPSProxy,
+		config.NoProxy,
+		config.ProcessWrapperPath,
+		config.ProcessWhitelist,
+		processWhitelistInterval,
+		time.Duration(config.ProxyDrainTimeout),
+		time.Duration(config.OnUnhealthyActionTimeout),
 	)
 
-	hub := event.NewHub()
+	eventReplayBufferSize := config.EventReplayBufferSize
+	if eventReplayBufferSize == 0 {
+		eventReplayBufferSize = DefaultEventReplayBufferSize
+	}
+
+	hub := event.NewHub(logger, eventReplayBufferSize)
+	deliveries := event.NewDeliveries()
+	hub.Emit(executor.NewCellStartupReportEvent(startupReport))
 
-	totalCapacity, err := fetchCapacity(logger, gardenClient, config)
+	totalCapacity, err := fetchCapacity(logger, gardenClient, config, cacheSizeInBytes)
 	if err != nil {
 		return nil, nil, grouper.Members{}, err
 	}
@@ -260,10 +434,61 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 	containerConfig := containerstore.ContainerConfig{
 		OwnerName:              config.ContainerOwnerName,
 		INodeLimit:             config.ContainerInodeLimit,
+		MaxINodeLimit:          config.MaxContainerInodeLimit,
 		MaxCPUShares:           config.ContainerMaxCpuShares,
 		SetCPUWeight:           config.SetCPUWeight,
+		MaxGardenProperties:    config.MaxGardenPropertiesPerContainer,
+		MaxLogLineLength:       config.MaxLogLineLength,
 		ReservedExpirationTime: time.Duration(config.ReservedExpirationTime),
 		ReapInterval:           time.Duration(config.ContainerReapInterval),
+		MaxReapInterval:        time.Duration(config.MaxContainerReapInterval),
+		RestartWindow:          time.Duration(config.ContainerRestartWindow),
+		RestartBackoffBase:     time.Duration(config.ContainerRestartBackoffBase),
+		RestartBackoffMax:      time.Duration(config.ContainerRestartBackoffMax),
+		PrunerJitterFraction:   DefaultPrunerJitterFraction,
+		MaxStartTimeout:        time.Duration(config.MaxStartTimeout),
+		DefaultStartTimeout:    time.Duration(config.DefaultStartTimeout),
+		StartTimeoutPolicy:     config.StartTimeoutPolicy,
+		TarSymlinkPolicy:       tarsanitizer.SymlinkPolicy(config.TarSymlinkPolicy),
+		PreDestroyHook:         config.PreDestroyHook,
+		PreDestroyHookTimeout:  time.Duration(config.PreDestroyHookTimeout),
+		FinalMetricsTimeout:    time.Duration(config.FinalMetricsTimeout),
+		InternalIPWaitTimeout:  time.Duration(config.InternalIPWaitTimeout),
+		StoreMetricsInterval:   metricsReportInterval,
+		LockWaitSampling:       config.EnableStoreLockWaitSampling,
+		ResourceRegistrySlack:  config.ResourceRegistrySlack,
+	}
+
+	if config.PrunerJitterFraction != nil {
+		containerConfig.PrunerJitterFraction = *config.PrunerJitterFraction
+	}
+
+	if containerConfig.MaxReapInterval == 0 {
+		containerConfig.MaxReapInterval = DefaultMaxContainerReapInterval
+	}
+
+	if containerConfig.ResourceRegistrySlack == 0 {
+		containerConfig.ResourceRegistrySlack = DefaultResourceRegistrySlack
+	}
+
+	if containerConfig.RestartBackoffBase == 0 {
+		containerConfig.RestartBackoffBase = DefaultRestartBackoffBase
+	}
+
+	if containerConfig.RestartBackoffMax == 0 {
+		containerConfig.RestartBackoffMax = DefaultRestartBackoffMax
+	}
+
+	if containerConfig.PreDestroyHookTimeout == 0 {
+		containerConfig.PreDestroyHookTimeout = DefaultPreDestroyHookTimeout
+	}
+
+	if containerConfig.FinalMetricsTimeout == 0 {
+		containerConfig.FinalMetricsTimeout = DefaultFinalMetricsTimeout
+	}
+
+	if containerConfig.InternalIPWaitTimeout == 0 {
+		containerConfig.InternalIPWaitTimeout = DefaultInternalIPWaitTimeout
 	}
 
 	driverConfig := vollocal.NewDriverConfig()
@@ -284,6 +509,7 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 			time.Duration(config.EnvoyConfigReloadDuration),
 			clock,
 			config.ContainerProxyADSServers,
+			config.ProxyDrainTimeout > 0,
 		)
 	} else {
 		proxyConfigHandler = containerstore.NewNoopProxyConfigHandler()
@@ -299,6 +525,13 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 		return nil, nil, grouper.Members{}, err
 	}
 
+	completionNotifier, err := initializeCompletionNotifier(config, clock)
+	if err != nil {
+		return nil, nil, grouper.Members{}, err
+	}
+
+	resources := resourceregistry.New()
+
 	containerStore := containerstore.New(
 		containerConfig,
 		&totalCapacity,
@@ -308,6 +541,7 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 		credManager,
 		clock,
 		hub,
+		deliveries,
 		transformer,
 		config.TrustedSystemCertificatesPath,
 		metronClient,
@@ -318,6 +552,8 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 		cellID,
 		config.EnableUnproxiedPortMappings,
 		config.AdvertisePreferenceForInstanceAddress,
+		completionNotifier,
+		resources,
 	)
 
 	depotClient := depot.NewClient(
@@ -330,8 +566,16 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 		deletionWorkPool,
 		readWorkPool,
 		metricsWorkPool,
+		config.MaxConcurrentGardenCreates,
+		config.AsyncContainerDeletion,
 	)
 
+	err = depotClient.Import(logger, snapshot)
+	if err != nil {
+		logger.Error("failed-to-import-snapshot", err)
+		return nil, nil, grouper.Members{}, err
+	}
+
 	healthcheckSpec := garden.ProcessSpec{
 		Path: config.GardenHealthcheckProcessPath,
 		Args: config.GardenHealthcheckProcessArgs,
@@ -357,35 +601,127 @@ func Initialize(logger lager.Logger, config ExecutorConfig, cellID, zone string,
 		config.ProxyMemoryAllocationMB,
 		depotClient,
 		metronClient,
+		resources,
 	)
 
-	return depotClient, statsReporter,
-		grouper.Members{
-			{"volman-driver-syncer", volmanDriverSyncer},
-			{"metrics-reporter", &metrics.Reporter{
-				ExecutorSource: depotClient,
-				Interval:       metricsReportInterval,
-				Clock:          clock,
-				Logger:         logger,
-				MetronClient:   metronClient,
-				Tags:           map[string]string{"zone": zone},
-			}},
-			{"hub-closer", closeHub(logger, hub)},
-			{"container-metrics-reporter", statsReporter},
-			{"garden_health_checker", gardenhealth.NewRunner(
-				time.Duration(config.GardenHealthcheckInterval),
-				time.Duration(config.GardenHealthcheckEmissionInterval),
-				time.Duration(config.GardenHealthcheckTimeout),
-				logger,
-				gardenHealthcheck,
-				depotClient,
-				metronClient,
-				clock,
-			)},
-			{"registry-pruner", containerStore.NewRegistryPruner(logger)},
-			{"container-reaper", containerStore.NewContainerReaper(logger)},
-		},
-		nil
+	members := grouper.Members{
+		{"volman-driver-syncer", volmanDriverSyncer},
+		{"metrics-reporter", &metrics.Reporter{
+			ExecutorSource: depotClient,
+			Interval:       metricsReportInterval,
+			Clock:          clock,
+			Logger:         logger,
+			MetronClient:   metronClient,
+			Tags:           map[string]string{"zone": zone},
+
+			CreateWorkPool:  creationWorkPool,
+			DeleteWorkPool:  deletionWorkPool,
+			ReadWorkPool:    readWorkPool,
+			MetricsWorkPool: metricsWorkPool,
+
+			TransferSource: transformer,
+			CacheSource:    cachedDownloader,
+		}},
+		{"hub-closer", event.NewCloser(logger, hub, deliveries, clock, time.Duration(config.EventHubDrainTimeout))},
+		{"container-metrics-reporter", statsReporter},
+		{"garden_health_checker", gardenhealth.NewRunner(
+			time.Duration(config.GardenHealthcheckInterval),
+			time.Duration(config.GardenHealthcheckEmissionInterval),
+			time.Duration(config.GardenHealthcheckTimeout),
+			logger,
+			gardenHealthcheck,
+			depotClient,
+			metronClient,
+			clock,
+		)},
+		{"registry-pruner", containerStore.NewRegistryPruner(logger)},
+		{"container-reaper", containerStore.NewContainerReaper(logger)},
+		{"store-metrics-reporter", containerStore.NewStoreMetricsReporter(logger)},
+		{"healthcheck-pool-metrics-reporter", healthcheckpool.NewMetricsReporter(healthCheckPool, metricsReportInterval, clock, metronClient, logger)},
+		{"upload-saturation-reporter", steps.NewUploadSaturationReporter(uploadRateLimiter, StalledMetricHeartbeatInterval, clock, metronClient, logger)},
+	}
+
+	if config.EnableContainerPortProbe {
+		portProber := containermetrics.NewPortProber(
+			logger,
+			time.Duration(config.ContainerPortProbeInterval),
+			clock,
+			containermetrics.DefaultDialer,
+			depotClient,
+			metronClient,
+		)
+		members = append(members, grouper.Member{Name: "container-port-prober", Runner: portProber})
+	}
+
+	if config.WarmupContainerCount > 0 {
+		warmupRootFS := config.WarmupRootFS
+		if rootFSPath, ok := rootFSes[warmupRootFS]; ok {
+			warmupRootFS = rootFSPath
+		}
+
+		rootFSWarmer := containerstore.NewRootFSWarmer(
+			logger,
+			gardenClient,
+			clock,
+			guidgen.DefaultGenerator,
+			config.ContainerOwnerName,
+			warmupRootFS,
+			config.WarmupContainerCount,
+		)
+		members = append(members, grouper.Member{Name: "rootfs-warmer", Runner: rootFSWarmer})
+	}
+
+	if len(config.WarmupCacheURLs) > 0 {
+		cacheWarmer := cacheinventory.NewWarmer(logger, cachedDownloader, clock, config.WarmupCacheURLs)
+		members = append(members, grouper.Member{Name: "cache-warmer", Runner: cacheWarmer})
+	}
+
+	if config.CacheDiskPressurePercent > 0 {
+		cacheDiskCheckInterval := time.Duration(config.CacheDiskCheckInterval)
+		if cacheDiskCheckInterval == 0 {
+			cacheDiskCheckInterval = DefaultCacheDiskCheckInterval
+		}
+
+		pressureMonitor := cacheinventory.NewPressureMonitor(
+			logger,
+			configuration.NewFilesystemStater(),
+			config.CachePath,
+			config.CacheDiskPressurePercent,
+			cacheDiskCheckInterval,
+			clock,
+			cachedDownloader,
+			metronClient,
+		)
+		members = append(members, grouper.Member{Name: "cache-pressure-monitor", Runner: pressureMonitor})
+	}
+
+	return depotClient, statsReporter, members, nil
+}
+
+// newGardenClient connects to garden_addr, or to every address of
+// garden_addrs when more than one is given, failing over from one to the
+// next in order.
+func newGardenClient(logger lager.Logger, config ExecutorConfig) GardenClient.Client {
+	if len(config.GardenAddrs) == 0 {
+		return GardenClient.New(GardenConnection.New(config.GardenNetwork, config.GardenAddr))
+	}
+	if len(config.GardenAddrs) == 1 {
+		return GardenClient.New(GardenConnection.New(config.GardenNetwork, config.GardenAddrs[0]))
+	}
+
+	threshold := config.GardenFailoverThreshold
+	if threshold == 0 {
+		threshold = DefaultGardenFailoverThreshold
+	}
+
+	backends := make([]gardenfailover.Backend, len(config.GardenAddrs))
+	for i, addr := range config.GardenAddrs {
+		backends[i] = gardenfailover.Backend{
+			Addr:   addr,
+			Client: GardenClient.New(GardenConnection.New(config.GardenNetwork, addr)),
+		}
+	}
+	return gardenfailover.New(logger, backends, threshold)
 }
 
 // Until we get a successful response from garden,
@@ -436,8 +772,8 @@ func waitForGarden(logger lager.Logger, gardenClient GardenClient.Client, metron
 	}
 }
 
-func fetchCapacity(logger lager.Logger, gardenClient GardenClient.Client, config ExecutorConfig) (executor.ExecutorResources, error) {
-	capacity, err := configuration.ConfigureCapacity(gardenClient, config.MemoryMB, config.DiskMB, config.MaxCacheSizeInBytes, config.AutoDiskOverheadMB)
+func fetchCapacity(logger lager.Logger, gardenClient GardenClient.Client, config ExecutorConfig, cacheSizeInBytes uint64) (executor.ExecutorResources, error) {
+	capacity, err := configuration.ConfigureCapacity(gardenClient, config.MemoryMB, config.DiskMB, cacheSizeInBytes, config.AutoDiskOverheadMB)
 	if err != nil {
 		logger.Error("failed-to-configure-capacity", err)
 		return executor.ExecutorResources{}, err
@@ -450,13 +786,65 @@ func fetchCapacity(logger lager.Logger, gardenClient GardenClient.Client, config
 	return capacity, nil
 }
 
-func destroyContainers(gardenClient garden.Client, containersFetcher *executorContainers, logger lager.Logger) error {
+// loadSnapshot reads the containers another executor handed over at path, as
+// written from its ListContainers. An empty path means there is nothing to
+// import.
+func loadSnapshot(logger lager.Logger, path string) ([]executor.Container, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	logger = logger.Session("load-snapshot", lager.Data{"path": path})
+
+	payload, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Error("failed-to-read", err)
+		return nil, err
+	}
+
+	var snapshot []executor.Container
+	err = json.Unmarshal(payload, &snapshot)
+	if err != nil {
+		logger.Error("failed-to-unmarshal", err)
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func snapshotHandles(snapshot []executor.Container) map[string]struct{} {
+	handles := make(map[string]struct{}, len(snapshot))
+	for _, container := range snapshot {
+		handles[container.Guid] = struct{}{}
+	}
+	return handles
+}
+
+// destroyContainers destroys the garden containers left over from a previous
+// run, except for those in keep, which are about to be imported.
+func destroyContainers(gardenClient garden.Client, containersFetcher *executorContainers, keep map[string]struct{}, clock clock.Clock, logger lager.Logger) (report executor.CellStartupReport, err error) {
+	startTime := clock.Now()
+	defer func() {
+		report.Duration = clock.Since(startTime)
+	}()
+
 	logger.Info("executor-fetching-containers-to-destroy")
 	containers, err := containersFetcher.Containers()
 	if err != nil {
 		logger.Error("executor-failed-to-get-containers", err)
-		return err
+		return report, err
+	}
+	report.ContainersFound = len(containers)
+
+	stray := containers[:0]
+	for _, container := range containers {
+		if _, ok := keep[container.Handle()]; ok {
+			report.ContainersRecovered++
+			continue
+		}
+		stray = append(stray, container)
 	}
+	containers = stray
 
 	logger.Info("executor-fetched-containers-to-destroy", lager.Data{"num-containers": len(containers)})
 
@@ -475,6 +863,7 @@ func destroyContainers(gardenClient garden.Client, containersFetcher *executorCo
 		}(container)
 	}
 
+	var destroyErr error
 	for _, _ = range containers {
 		select {
 		case result := <-errInfoChannel:
@@ -482,16 +871,39 @@ func destroyContainers(gardenClient garden.Client, containersFetcher *executorCo
 				logger.Error("executor-failed-to-destroy-container", result.err, lager.Data{
 					"handle": result.handle,
 				})
-				return result.err
+				report.ContainersFailedToDestroy++
+				if destroyErr == nil {
+					destroyErr = result.err
+				}
 			} else {
 				logger.Info("executor-destroyed-stray-container", lager.Data{
 					"handle": result.handle,
 				})
+				report.ContainersDestroyed++
 			}
 		}
 	}
 
-	return nil
+	return report, destroyErr
+}
+
+func writeStartupReport(logger lager.Logger, path string, report executor.CellStartupReport) {
+	if path == "" {
+		return
+	}
+
+	logger = logger.Session("write-startup-report", lager.Data{"path": path})
+
+	payload, err := json.Marshal(report)
+	if err != nil {
+		logger.Error("failed-to-marshal", err)
+		return
+	}
+
+	err = ioutil.WriteFile(path, payload, 0644)
+	if err != nil {
+		logger.Error("failed-to-write", err)
+	}
 }
 
 func setupWorkDir(logger lager.Logger, tempDir string) string {
@@ -516,34 +928,94 @@ func initializeTransformer(
 	cache cacheddownloader.CachedDownloader,
 	workDir string,
 	downloadRateLimiter chan struct{},
-	maxConcurrentUploads uint,
+	uploadRateLimiter chan struct{},
 	uploader uploader.Uploader,
 	healthyMonitoringInterval time.Duration,
 	unhealthyMonitoringInterval time.Duration,
 	gracefulShutdownInterval time.Duration,
 	healthCheckWorkPool *workpool.WorkPool,
+	healthCheckPool *healthcheckpool.Pool,
 	clock clock.Clock,
 	postSetupHook []string,
 	postSetupUser string,
+	postSetupHookTimeout time.Duration,
 	useDeclarativeHealthCheck bool,
+	useExecutorHTTPHealthCheck bool,
+	interpolateEnv bool,
+	healthCheckUser string,
 	declarativeHealthcheckRootFS string,
 	enableContainerProxy bool,
 	drainWait time.Duration,
+	tarSymlinkPolicy tarsanitizer.SymlinkPolicy,
+	maxConcurrentDownloadsPerContainer int,
+	maxDownloadSizeBytes int64,
+	artifactSizer steps.ArtifactSizer,
+	compressedFetcher steps.CompressedFetcher,
+	exportNetworkEnvVars bool,
+	httpProxy string,
+	httpsProxy string,
+	noProxy string,
+	processWrapperPath string,
+	processWhitelist []string,
+	processWhitelistInterval time.Duration,
+	proxyDrainTimeout time.Duration,
+	onUnhealthyActionTimeout time.Duration,
 ) transformer.Transformer {
 	var options []transformer.Option
 	compressor := compressor.NewTgz()
 
 	options = append(options, transformer.WithSidecarRootfs(declarativeHealthcheckRootFS))
+	options = append(options, transformer.WithHealthCheckPool(healthCheckPool))
 
 	if useDeclarativeHealthCheck {
 		options = append(options, transformer.WithDeclarativeHealthchecks())
 	}
 
+	if useExecutorHTTPHealthCheck {
+		options = append(options, transformer.WithExecutorHTTPHealthchecks())
+	}
+
+	if interpolateEnv {
+		options = append(options, transformer.WithEnvInterpolation())
+	}
+
+	if healthCheckUser != "" {
+		options = append(options, transformer.WithHealthCheckUser(healthCheckUser))
+	}
+
 	if enableContainerProxy {
 		options = append(options, transformer.WithContainerProxy(drainWait))
+
+		if proxyDrainTimeout > 0 {
+			options = append(options, transformer.WithProxyDrain(proxyDrainTimeout, containerstore.ProxyAdminURL))
+		}
 	}
 
 	options = append(options, transformer.WithPostSetupHook(postSetupUser, postSetupHook))
+	options = append(options, transformer.WithPostSetupHookTimeout(postSetupHookTimeout))
+	options = append(options, transformer.WithTarSymlinkPolicy(tarSymlinkPolicy))
+	options = append(options, transformer.WithMaxConcurrentDownloadsPerContainer(maxConcurrentDownloadsPerContainer))
+	options = append(options, transformer.WithMaxDownloadSize(maxDownloadSizeBytes, artifactSizer))
+
+	if compressedFetcher != nil {
+		options = append(options, transformer.WithCompressedFetcher(compressedFetcher))
+	}
+
+	if exportNetworkEnvVars && (httpProxy != "" || httpsProxy != "") {
+		options = append(options, transformer.WithProxyEnv(httpProxy, httpsProxy, noProxy))
+	}
+
+	if processWrapperPath != "" {
+		options = append(options, transformer.WithProcessWrapper(processWrapperPath))
+	}
+
+	if len(processWhitelist) > 0 {
+		options = append(options, transformer.WithProcessWhitelist(processWhitelist, processWhitelistInterval))
+	}
+
+	if onUnhealthyActionTimeout > 0 {
+		options = append(options, transformer.WithOnUnhealthyActionTimeout(onUnhealthyActionTimeout))
+	}
 
 	return transformer.NewTransformer(
 		clock,
@@ -551,7 +1023,7 @@ func initializeTransformer(
 		uploader,
 		compressor,
 		downloadRateLimiter,
-		make(chan struct{}, maxConcurrentUploads),
+		uploadRateLimiter,
 		workDir,
 		healthyMonitoringInterval,
 		unhealthyMonitoringInterval,
@@ -561,15 +1033,59 @@ func initializeTransformer(
 	)
 }
 
-func closeHub(logger lager.Logger, hub event.Hub) ifrit.Runner {
-	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
-		close(ready)
-		signal := <-signals
-		hub.Close()
-		hubLogger := logger.Session("close-hub")
-		hubLogger.Info("signalled", lager.Data{"signal": signal.String()})
-		return nil
-	})
+var errCachedDownloadsIgnoreProxy = errors.New("http_proxy and https_proxy apply to uploads and uncached downloads only; cached downloads use the proxy settings of the process environment")
+
+// proxyFunc resolves proxies for the transports the executor builds for
+// uploads and uncached downloads. It follows the configured proxy settings
+// when any are set, and the process environment otherwise. Other clients,
+// such as HTTP health checks and completion callbacks, are left alone.
+func proxyFunc(config ExecutorConfig) func(*http.Request) (*url.URL, error) {
+	if config.HTTPProxy == "" && config.HTTPSProxy == "" {
+		return http.ProxyFromEnvironment
+	}
+
+	proxyForURL := (&httpproxy.Config{
+		HTTPProxy:  config.HTTPProxy,
+		HTTPSProxy: config.HTTPSProxy,
+		NoProxy:    config.NoProxy,
+	}).ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyForURL(req.URL)
+	}
+}
+
+func validProxyURL(proxy string) bool {
+	u, err := url.Parse(proxy)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+func initializeCompletionNotifier(config ExecutorConfig, clock clock.Clock) (containerstore.CompletionNotifier, error) {
+	workPoolSize := config.CompletionCallbackWorkPoolSize
+	if workPoolSize == 0 {
+		workPoolSize = DefaultCompletionCallbackWorkPoolSize
+	}
+	maxAttempts := config.CompletionCallbackMaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = DefaultCompletionCallbackMaxAttempts
+	}
+	backoff := time.Duration(config.CompletionCallbackBackoff)
+	if backoff == 0 {
+		backoff = DefaultCompletionCallbackBackoff
+	}
+
+	workPool, err := workpool.NewWorkPool(workPoolSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return containerstore.NewCompletionCallbackSender(
+		workPool,
+		&http.Client{Timeout: completionCallbackTimeout},
+		clock,
+		maxAttempts,
+		backoff,
+	), nil
 }
 
 func TLSConfigFromConfig(logger lager.Logger, certsRetriever CertPoolRetriever, config ExecutorConfig) (*tls.Config, error) {
@@ -650,17 +1166,28 @@ func CredManagerFromConfig(logger lager.Logger, metronClient loggingclient.Ingre
 			return nil, err
 		}
 
+		intermediates, err := loadIntermediateCAs(config.InstanceIdentityIntermediateCAPaths, certs[0])
+		if err != nil {
+			return nil, err
+		}
+
 		if config.InstanceIdentityValidityPeriod <= 0 {
 			return nil, errors.New("instance ID validity period needs to be set and positive")
 		}
 
+		if config.InstanceIdentityRenewalWindow < 0 || config.InstanceIdentityRenewalWindow >= config.InstanceIdentityValidityPeriod {
+			return nil, errors.New("instance ID renewal window must be shorter than the validity period")
+		}
+
 		return containerstore.NewCredManager(
 			logger,
 			metronClient,
 			time.Duration(config.InstanceIdentityValidityPeriod),
+			time.Duration(config.InstanceIdentityRenewalWindow),
 			rand.Reader,
 			clock,
 			certs[0],
+			intermediates,
 			privateKey,
 			handlers...,
 		), nil
@@ -670,45 +1197,218 @@ func CredManagerFromConfig(logger lager.Logger, metronClient loggingclient.Ingre
 	return containerstore.NewNoopCredManager(), nil
 }
 
-func (config *ExecutorConfig) Validate(logger lager.Logger) bool {
-	valid := true
+// loadIntermediateCAs parses the PEM-encoded certificates at paths, in order,
+// and checks that each one issued the certificate before it, starting with
+// ca.
+func loadIntermediateCAs(paths []string, ca *x509.Certificate) ([]*x509.Certificate, error) {
+	intermediates := make([]*x509.Certificate, 0, len(paths))
+	issued := ca
+	for _, path := range paths {
+		certData, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		certBlock, _ := pem.Decode(certData)
+		if certBlock == nil {
+			return nil, fmt.Errorf("instance ID intermediate CA %s is not PEM-encoded", path)
+		}
+		certs, err := x509.ParseCertificates(certBlock.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		err = issued.CheckSignatureFrom(certs[0])
+		if err != nil {
+			return nil, fmt.Errorf("instance ID intermediate CA %s did not issue %s: %s", path, issued.Subject.CommonName, err)
+		}
+
+		intermediates = append(intermediates, certs[0])
+		issued = certs[0]
+	}
+	return intermediates, nil
+}
+
+// ValidationError describes an invalid ExecutorConfig field. Field is the
+// field's JSON name.
+type ValidationError struct {
+	Field   string
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Validate logs every invalid field of the config and returns them, along
+// with whether the config is valid.
+func (config *ExecutorConfig) Validate(logger lager.Logger) (bool, []ValidationError) {
+	var validationErrors []ValidationError
+	invalid := func(field, message, logMessage string, err error, data ...lager.Data) {
+		logger.Error(logMessage, err, data...)
+		validationErrors = append(validationErrors, ValidationError{Field: field, Message: message})
+	}
 
 	if config.ContainerMaxCpuShares == 0 {
-		logger.Error("max-cpu-shares-invalid", nil)
-		valid = false
+		invalid("container_max_cpu_shares", "must be greater than zero", "max-cpu-shares-invalid", nil)
 	}
 
 	if config.HealthyMonitoringInterval <= 0 {
-		logger.Error("healthy-monitoring-interval-invalid", nil)
-		valid = false
+		invalid("healthy_monitoring_interval", "must be greater than zero", "healthy-monitoring-interval-invalid", nil)
 	}
 
 	if config.UnhealthyMonitoringInterval <= 0 {
-		logger.Error("unhealthy-monitoring-interval-invalid", nil)
-		valid = false
+		invalid("unhealthy_monitoring_interval", "must be greater than zero", "unhealthy-monitoring-interval-invalid", nil)
 	}
 
 	if config.GardenHealthcheckInterval <= 0 {
-		logger.Error("garden-healthcheck-interval-invalid", nil)
-		valid = false
+		invalid("garden_healthcheck_interval", "must be greater than zero", "garden-healthcheck-interval-invalid", nil)
 	}
 
 	if config.GardenHealthcheckProcessUser == "" {
-		logger.Error("garden-healthcheck-process-user-invalid", nil)
-		valid = false
+		invalid("garden_healthcheck_process_user", "must be set", "garden-healthcheck-process-user-invalid", nil)
 	}
 
 	if config.GardenHealthcheckProcessPath == "" {
-		logger.Error("garden-healthcheck-process-path-invalid", nil)
-		valid = false
+		invalid("garden_healthcheck_process_path", "must be set", "garden-healthcheck-process-path-invalid", nil)
+	}
+
+	if config.EnableContainerPortProbe && config.ContainerPortProbeInterval <= 0 {
+		invalid("container_port_probe_interval", "must be greater than zero when the port probe is enabled", "container-port-probe-interval-invalid", nil)
+	}
+
+	if config.CacheSizeWarningFraction < 0 || config.CacheSizeWarningFraction > 1 {
+		invalid("cache_size_warning_fraction", "must be between 0 and 1", "cache-size-warning-fraction-invalid", nil)
+	}
+
+	if config.CacheDiskPressurePercent < 0 || config.CacheDiskPressurePercent > 100 {
+		invalid("cache_disk_pressure_percent", "must be between 0 and 100", "cache-disk-pressure-percent-invalid", nil)
+	}
+
+	if config.CacheDiskCheckInterval < 0 {
+		invalid("cache_disk_check_interval", "must not be negative", "cache-disk-check-interval-invalid", nil)
+	}
+
+	if config.HTTPProxy != "" && !validProxyURL(config.HTTPProxy) {
+		invalid("http_proxy", "must be an absolute URL", "http-proxy-invalid", nil)
+	}
+
+	if config.HTTPSProxy != "" && !validProxyURL(config.HTTPSProxy) {
+		invalid("https_proxy", "must be an absolute URL", "https-proxy-invalid", nil)
+	}
+
+	if config.EventReplayBufferSize < 0 {
+		invalid("event_replay_buffer_size", "must not be negative", "event-replay-buffer-size-invalid", nil)
+	}
+
+	if config.MaxLogLineLength < 0 || config.MaxLogLineLength > log_streamer.MAX_MESSAGE_SIZE {
+		invalid("max_log_line_length", fmt.Sprintf("must be between 0 and %d", log_streamer.MAX_MESSAGE_SIZE), "max-log-line-length-invalid", nil)
+	}
+
+	for _, warmupURL := range config.WarmupCacheURLs {
+		if _, err := url.ParseRequestURI(warmupURL); err != nil {
+			invalid("warmup_cache_urls", fmt.Sprintf("%q is not a valid URL", warmupURL), "warmup-cache-url-invalid", err, lager.Data{"url": warmupURL})
+		}
+	}
+
+	if config.ProcessWhitelistInterval < 0 {
+		invalid("process_whitelist_interval", "must not be negative", "process-whitelist-interval-invalid", nil)
+	}
+
+	if config.WarmupContainerCount < 0 {
+		invalid("warmup_container_count", "must not be negative", "warmup-container-count-invalid", nil)
+	}
+
+	if config.WarmupContainerCount > 0 && config.WarmupRootFS == "" {
+		invalid("warmup_rootfs", "must be set when warmup_container_count is set", "warmup-rootfs-invalid", nil)
+	}
+
+	if config.CircuitBreakerThreshold < 0 {
+		invalid("circuit_breaker_threshold", "must not be negative", "circuit-breaker-threshold-invalid", nil)
+	}
+
+	if config.CircuitBreakerThreshold > 0 && config.CircuitBreakerOpenDuration <= 0 {
+		invalid("circuit_breaker_open_duration", "must be greater than zero when circuit_breaker_threshold is set", "circuit-breaker-open-duration-invalid", nil)
+	}
+
+	if config.GardenFailoverThreshold < 0 {
+		invalid("garden_failover_threshold", "must not be negative", "garden-failover-threshold-invalid", nil)
+	}
+
+	if config.PrunerJitterFraction != nil && (*config.PrunerJitterFraction < 0 || *config.PrunerJitterFraction >= 1) {
+		invalid("pruner_jitter_fraction", "must be at least 0 and less than 1", "pruner-jitter-fraction-invalid", nil)
+	}
+
+	switch config.StartTimeoutPolicy {
+	case "", containerstore.StartTimeoutPolicyClamp, containerstore.StartTimeoutPolicyReject:
+	default:
+		invalid("start_timeout_policy", fmt.Sprintf("unknown policy %q", config.StartTimeoutPolicy), "start-timeout-policy-invalid", nil, lager.Data{"start-timeout-policy": config.StartTimeoutPolicy})
+	}
+
+	if err := tarsanitizer.SymlinkPolicy(config.TarSymlinkPolicy).Validate(); err != nil {
+		invalid("tar_symlink_policy", err.Error(), "tar-symlink-policy-invalid", err)
+	}
+
+	if config.MaxStartTimeout > 0 && config.DefaultStartTimeout > config.MaxStartTimeout {
+		invalid("default_start_timeout", "must not exceed max_start_timeout", "default-start-timeout-exceeds-max-start-timeout", nil)
+	}
+
+	if config.CompletionCallbackWorkPoolSize < 0 {
+		invalid("completion_callback_work_pool_size", "must not be negative", "completion-callback-config-invalid", nil)
+	}
+
+	if config.CompletionCallbackMaxAttempts < 0 {
+		invalid("completion_callback_max_attempts", "must not be negative", "completion-callback-config-invalid", nil)
+	}
+
+	if config.CompletionCallbackBackoff < 0 {
+		invalid("completion_callback_backoff", "must not be negative", "completion-callback-config-invalid", nil)
+	}
+
+	if config.MaxConcurrentDownloadsPerContainer < 0 {
+		invalid("max_concurrent_downloads_per_container", "must not be negative", "max-concurrent-downloads-per-container-invalid", nil)
+	}
+
+	if config.MaxDownloadSizeBytes < 0 {
+		invalid("max_download_size_bytes", "must not be negative", "max-download-size-bytes-invalid", nil)
+	}
+
+	if config.MaxContainerInodeLimit > 0 && config.MaxContainerInodeLimit < config.ContainerInodeLimit {
+		invalid("max_container_inode_limit", "must not be less than container_inode_limit", "max-container-inode-limit-invalid", nil)
+	}
+
+	if config.MaxConcurrentGardenCreates < 0 {
+		invalid("max_concurrent_garden_creates", "must not be negative", "max-concurrent-garden-creates-invalid", nil)
+	}
+
+	if config.ResourceRegistrySlack < 0 {
+		invalid("resource_registry_slack", "must not be negative", "resource-registry-slack-invalid", nil)
+	}
+
+	if config.EventHubDrainTimeout < 0 {
+		invalid("event_hub_drain_timeout", "must not be negative", "event-hub-drain-timeout-invalid", nil)
+	}
+
+	if config.FinalMetricsTimeout < 0 {
+		invalid("final_metrics_timeout", "must not be negative", "final-metrics-timeout-invalid", nil)
+	}
+
+	if config.InternalIPWaitTimeout < 0 {
+		invalid("internal_ip_wait_timeout", "must not be negative", "internal-ip-wait-timeout-invalid", nil)
+	}
+
+	if config.PreDestroyHookTimeout < 0 {
+		invalid("pre_destroy_hook_timeout", "must not be negative", "pre-destroy-hook-timeout-invalid", nil)
 	}
 
 	if config.PostSetupHook != "" && config.PostSetupUser == "" {
-		logger.Error("post-setup-hook-requires-a-user", nil)
-		valid = false
+		invalid("post_setup_user", "must be set when post_setup_hook is set", "post-setup-hook-requires-a-user", nil)
+	}
+
+	if config.PostSetupHook != "" && config.PostSetupHookTimeout <= 0 {
+		invalid("post_setup_hook_timeout", "must be greater than zero when post_setup_hook is set", "post-setup-hook-timeout-invalid", nil)
 	}
 
-	return valid
+	return len(validationErrors) == 0, validationErrors
 }
 
 func appendCACerts(caCertPool *x509.CertPool, pathToCA string) (*x509.CertPool, error) {
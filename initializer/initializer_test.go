@@ -4,6 +4,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/asn1"
+	"encoding/json"
 	"encoding/pem"
 	"fmt"
 	"io/ioutil"
@@ -29,6 +30,7 @@ import (
 	"code.cloudfoundry.org/lager/lagertest"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
 	"github.com/onsi/gomega/ghttp"
 )
 
@@ -178,6 +180,16 @@ var _ = Describe("Initializer", func() {
 		})
 	})
 
+	Context("when a proxy is configured", func() {
+		BeforeEach(func() {
+			config.HTTPProxy = "http://proxy.example.com:3128"
+		})
+
+		It("warns that cached downloads do not use it", func() {
+			Eventually(logger).Should(gbytes.Say("cached-downloads-ignore-configured-proxy"))
+		})
+	})
+
 	Context("when there are leftover containers while initializing", func() {
 		BeforeEach(func() {
 			fakeGarden.RouteToHandler("GET", "/containers",
@@ -247,6 +259,98 @@ var _ = Describe("Initializer", func() {
 			})
 		})
 
+		Context("when an import snapshot names one of them", func() {
+			var (
+				snapshotDir string
+				deleted     chan string
+			)
+
+			BeforeEach(func() {
+				var err error
+				snapshotDir, err = ioutil.TempDir("", "import-snapshot")
+				Expect(err).NotTo(HaveOccurred())
+
+				payload, err := json.Marshal([]executor.Container{{Guid: "cnr1", State: executor.StateCompleted}})
+				Expect(err).NotTo(HaveOccurred())
+				config.ImportSnapshotPath = filepath.Join(snapshotDir, "snapshot.json")
+				Expect(ioutil.WriteFile(config.ImportSnapshotPath, payload, 0644)).To(Succeed())
+
+				deleted = make(chan string, 2)
+				for _, handle := range []string{"cnr1", "cnr2"} {
+					handle := handle
+					fakeGarden.RouteToHandler("DELETE", "/containers/"+handle,
+						ghttp.CombineHandlers(
+							func(http.ResponseWriter, *http.Request) {
+								deleted <- handle
+							},
+							ghttp.RespondWithJSONEncoded(http.StatusOK, &struct{}{})))
+				}
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(snapshotDir)
+			})
+
+			It("keeps the garden containers it is about to import", func() {
+				Eventually(deleted).Should(Receive(Equal("cnr2")))
+				Consistently(deleted).ShouldNot(Receive())
+			})
+		})
+
+		Context("when a startup report path is configured", func() {
+			var reportPath string
+
+			readReport := func() (executor.CellStartupReport, error) {
+				var report executor.CellStartupReport
+				payload, err := ioutil.ReadFile(reportPath)
+				if err != nil {
+					return report, err
+				}
+				err = json.Unmarshal(payload, &report)
+				return report, err
+			}
+
+			BeforeEach(func() {
+				reportDir, err := ioutil.TempDir("", "startup-report")
+				Expect(err).NotTo(HaveOccurred())
+				reportPath = filepath.Join(reportDir, "report.json")
+				config.StartupReportPath = reportPath
+
+				fakeGarden.RouteToHandler("DELETE", "/containers/cnr1", ghttp.RespondWithJSONEncoded(http.StatusOK, &struct{}{}))
+				fakeGarden.RouteToHandler("DELETE", "/containers/cnr2", ghttp.RespondWith(http.StatusInternalServerError, ""))
+			})
+
+			AfterEach(func() {
+				os.RemoveAll(filepath.Dir(reportPath))
+			})
+
+			It("writes a summary of the reconciliation", func() {
+				Eventually(readReport).Should(Equal(executor.CellStartupReport{
+					ContainersFound:           2,
+					ContainersDestroyed:       1,
+					ContainersFailedToDestroy: 1,
+				}))
+				Eventually(errCh).Should(Receive(HaveOccurred()))
+			})
+
+			Context("when an import snapshot names one of the containers", func() {
+				BeforeEach(func() {
+					payload, err := json.Marshal([]executor.Container{{Guid: "cnr2", State: executor.StateCompleted}})
+					Expect(err).NotTo(HaveOccurred())
+					config.ImportSnapshotPath = filepath.Join(filepath.Dir(reportPath), "snapshot.json")
+					Expect(ioutil.WriteFile(config.ImportSnapshotPath, payload, 0644)).To(Succeed())
+				})
+
+				It("counts it as recovered instead of destroying it", func() {
+					Eventually(readReport).Should(Equal(executor.CellStartupReport{
+						ContainersFound:     2,
+						ContainersRecovered: 1,
+						ContainersDestroyed: 1,
+					}))
+				})
+			})
+		})
+
 		Context("when garden fails to delete leftover containers", func() {
 			BeforeEach(func() {
 				fakeGarden.RouteToHandler(
@@ -667,6 +771,39 @@ var _ = Describe("Initializer", func() {
 				})
 			})
 
+			Context("when intermediate CAs are configured", func() {
+				BeforeEach(func() {
+					config.InstanceIdentityCAPath = "fixtures/instance-id/chained-ca.crt"
+					config.InstanceIdentityPrivateKeyPath = "fixtures/instance-id/chained-ca.key"
+					config.InstanceIdentityIntermediateCAPaths = []string{"fixtures/instance-id/intermediate-ca.crt"}
+				})
+
+				It("returns a credential manager", func() {
+					Expect(err).NotTo(HaveOccurred())
+					Expect(credManager).NotTo(BeNil())
+				})
+
+				Context("when an intermediate did not issue the certificate before it", func() {
+					BeforeEach(func() {
+						config.InstanceIdentityIntermediateCAPaths = []string{"fixtures/instance-id/ca.crt"}
+					})
+
+					It("fails", func() {
+						Expect(err).To(MatchError(ContainSubstring("instance ID intermediate CA fixtures/instance-id/ca.crt did not issue chained-ca")))
+					})
+				})
+
+				Context("when an intermediate is not PEM-encoded", func() {
+					BeforeEach(func() {
+						config.InstanceIdentityIntermediateCAPaths = []string{"fixtures/instance-id/non-pem.crt"}
+					})
+
+					It("fails", func() {
+						Expect(err).To(MatchError(ContainSubstring("instance ID intermediate CA fixtures/instance-id/non-pem.crt is not PEM-encoded")))
+					})
+				})
+			})
+
 			Context("when the validity period is not set", func() {
 				BeforeEach(func() {
 					config.InstanceIdentityValidityPeriod = 0
@@ -676,6 +813,178 @@ var _ = Describe("Initializer", func() {
 					Eventually(err).Should(MatchError(ContainSubstring("instance ID validity period needs to be set and positive")))
 				})
 			})
+
+			Context("when the renewal window is not shorter than the validity period", func() {
+				BeforeEach(func() {
+					config.InstanceIdentityRenewalWindow = config.InstanceIdentityValidityPeriod
+				})
+
+				It("fails", func() {
+					Eventually(err).Should(MatchError(ContainSubstring("instance ID renewal window must be shorter than the validity period")))
+				})
+			})
+		})
+	})
+})
+
+var _ = Describe("ExecutorConfig", func() {
+	Describe("Validate", func() {
+		var config initializer.ExecutorConfig
+
+		BeforeEach(func() {
+			config = initializer.ExecutorConfig{
+				ContainerMaxCpuShares:        1024,
+				GardenHealthcheckInterval:    durationjson.Duration(10 * time.Minute),
+				GardenHealthcheckProcessPath: "/bin/sh",
+				GardenHealthcheckProcessUser: "vcap",
+				HealthyMonitoringInterval:    durationjson.Duration(30 * time.Second),
+				UnhealthyMonitoringInterval:  durationjson.Duration(500 * time.Millisecond),
+			}
+		})
+
+		It("accepts a valid config", func() {
+			valid, validationErrors := config.Validate(lagertest.NewTestLogger("test"))
+			Expect(valid).To(BeTrue())
+			Expect(validationErrors).To(BeEmpty())
+		})
+
+		It("returns an error for every invalid field", func() {
+			config.ContainerMaxCpuShares = 0
+			jitterFraction := 2.0
+			config.PrunerJitterFraction = &jitterFraction
+			config.MaxConcurrentGardenCreates = -1
+			config.ResourceRegistrySlack = -1
+			config.MaxDownloadSizeBytes = -1
+			config.CacheDiskPressurePercent = 101
+			config.HTTPProxy = "proxy.example.com:3128"
+			config.WarmupContainerCount = 1
+			config.EventReplayBufferSize = -1
+			config.MaxLogLineLength = -1
+			config.WarmupCacheURLs = []string{"not a url"}
+			config.GardenFailoverThreshold = -1
+			config.InternalIPWaitTimeout = durationjson.Duration(-time.Second)
+
+			valid, validationErrors := config.Validate(lagertest.NewTestLogger("test"))
+			Expect(valid).To(BeFalse())
+
+			fields := []string{}
+			for _, validationError := range validationErrors {
+				fields = append(fields, validationError.Field)
+				Expect(validationError.Message).NotTo(BeEmpty())
+			}
+			Expect(fields).To(ConsistOf(
+				"container_max_cpu_shares",
+				"pruner_jitter_fraction",
+				"max_concurrent_garden_creates",
+				"resource_registry_slack",
+				"max_download_size_bytes",
+				"cache_disk_pressure_percent",
+				"http_proxy",
+				"warmup_rootfs",
+				"event_replay_buffer_size",
+				"max_log_line_length",
+				"warmup_cache_urls",
+				"garden_failover_threshold",
+				"internal_ip_wait_timeout",
+			))
+		})
+
+		It("requires a post-setup hook timeout when a post-setup hook is set", func() {
+			config.PostSetupHook = "/bin/true"
+			config.PostSetupUser = "vcap"
+
+			valid, validationErrors := config.Validate(lagertest.NewTestLogger("test"))
+			Expect(valid).To(BeFalse())
+			Expect(validationErrors).To(ConsistOf(initializer.ValidationError{
+				Field:   "post_setup_hook_timeout",
+				Message: "must be greater than zero when post_setup_hook is set",
+			}))
+
+			config.PostSetupHookTimeout = durationjson.Duration(time.Minute)
+			Expect(config.Validate(lagertest.NewTestLogger("test"))).To(BeTrue())
+		})
+
+		It("requires an open duration when the garden circuit breaker is enabled", func() {
+			config.CircuitBreakerThreshold = 5
+
+			valid, validationErrors := config.Validate(lagertest.NewTestLogger("test"))
+			Expect(valid).To(BeFalse())
+			Expect(validationErrors).To(ConsistOf(initializer.ValidationError{
+				Field:   "circuit_breaker_open_duration",
+				Message: "must be greater than zero when circuit_breaker_threshold is set",
+			}))
+
+			config.CircuitBreakerOpenDuration = durationjson.Duration(30 * time.Second)
+			Expect(config.Validate(lagertest.NewTestLogger("test"))).To(BeTrue())
+		})
+	})
+
+	Describe("LoadAndValidate", func() {
+		var (
+			configPath string
+			fields     map[string]interface{}
+		)
+
+		BeforeEach(func() {
+			fields = map[string]interface{}{
+				"container_max_cpu_shares":        1024,
+				"garden_healthcheck_interval":     "10m",
+				"garden_healthcheck_process_path": "/bin/sh",
+				"garden_healthcheck_process_user": "vcap",
+				"healthy_monitoring_interval":     "30s",
+				"unhealthy_monitoring_interval":   "500ms",
+			}
+		})
+
+		JustBeforeEach(func() {
+			payload, err := json.Marshal(fields)
+			Expect(err).NotTo(HaveOccurred())
+
+			configFile, err := ioutil.TempFile("", "executor-config")
+			Expect(err).NotTo(HaveOccurred())
+			defer configFile.Close()
+
+			_, err = configFile.Write(payload)
+			Expect(err).NotTo(HaveOccurred())
+			configPath = configFile.Name()
+		})
+
+		AfterEach(func() {
+			os.Remove(configPath)
+		})
+
+		It("loads a valid config", func() {
+			config, err := initializer.LoadAndValidate(configPath, lagertest.NewTestLogger("test"))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(config.ContainerMaxCpuShares).To(BeEquivalentTo(1024))
+			Expect(config.HealthyMonitoringInterval).To(Equal(durationjson.Duration(30 * time.Second)))
+		})
+
+		Context("when a field is misspelled", func() {
+			BeforeEach(func() {
+				fields["max_concurent_downloads"] = 5
+				fields["pruner_jitter_fraction"] = 2
+			})
+
+			It("names the unknown field alongside the invalid ones", func() {
+				_, err := initializer.LoadAndValidate(configPath, lagertest.NewTestLogger("test"))
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("max_concurent_downloads: unknown field"))
+
+				validationErrors, ok := err.(initializer.ValidationErrors)
+				Expect(ok).To(BeTrue())
+				Expect(validationErrors).To(ConsistOf(
+					initializer.ValidationError{Field: "max_concurent_downloads", Message: "unknown field"},
+					initializer.ValidationError{Field: "pruner_jitter_fraction", Message: "must be at least 0 and less than 1"},
+				))
+			})
+		})
+
+		Context("when the file does not exist", func() {
+			It("returns an error", func() {
+				_, err := initializer.LoadAndValidate("/does/not/exist", lagertest.NewTestLogger("test"))
+				Expect(err).To(HaveOccurred())
+			})
 		})
 	})
 })
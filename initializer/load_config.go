@@ -0,0 +1,104 @@
+package initializer
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+
+	"code.cloudfoundry.org/lager"
+)
+
+// ValidationErrors is returned by LoadAndValidate when the config file has
+// unknown or invalid fields.
+type ValidationErrors []ValidationError
+
+func (errs ValidationErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return "invalid executor config: " + strings.Join(messages, "; ")
+}
+
+// LoadAndValidate reads the executor config from the JSON file at path and
+// validates it. Unlike a plain unmarshal, fields the executor does not know
+// about are reported instead of silently ignored, so that a misspelled field
+// does not leave the setting at its default. All unknown fields are reported
+// together with the failures of Validate.
+func LoadAndValidate(path string, logger lager.Logger) (ExecutorConfig, error) {
+	logger = logger.Session("load-config", lager.Data{"path": path})
+
+	var config ExecutorConfig
+
+	payload, err := ioutil.ReadFile(path)
+	if err != nil {
+		logger.Error("failed-to-read-config", err)
+		return config, err
+	}
+
+	var validationErrors ValidationErrors
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.DisallowUnknownFields()
+	err = decoder.Decode(&config)
+	if err != nil {
+		unknownFields, fieldsErr := unknownConfigFields(payload)
+		if fieldsErr != nil || len(unknownFields) == 0 {
+			logger.Error("failed-to-decode-config", err)
+			return config, err
+		}
+
+		for _, field := range unknownFields {
+			logger.Error("unknown-field", nil, lager.Data{"field": field})
+			validationErrors = append(validationErrors, ValidationError{Field: field, Message: "unknown field"})
+		}
+
+		// decode again without the unknown fields, so the remaining fields
+		// are validated as well
+		config = ExecutorConfig{}
+		err = json.Unmarshal(payload, &config)
+		if err != nil {
+			logger.Error("failed-to-decode-config", err)
+			return config, err
+		}
+	}
+
+	_, invalidFields := config.Validate(logger)
+	validationErrors = append(validationErrors, invalidFields...)
+	if len(validationErrors) > 0 {
+		return config, validationErrors
+	}
+
+	return config, nil
+}
+
+// unknownConfigFields returns the top-level fields of the JSON object in
+// payload that do not map to a field of ExecutorConfig, sorted by name. Like
+// encoding/json, it matches field names case-insensitively.
+func unknownConfigFields(payload []byte) ([]string, error) {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(payload, &fields)
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{}
+	configType := reflect.TypeOf(ExecutorConfig{})
+	for i := 0; i < configType.NumField(); i++ {
+		name := strings.Split(configType.Field(i).Tag.Get("json"), ",")[0]
+		known[strings.ToLower(name)] = true
+	}
+
+	var unknown []string
+	for field := range fields {
+		if !known[strings.ToLower(field)] {
+			unknown = append(unknown, field)
+		}
+	}
+	sort.Strings(unknown)
+
+	return unknown, nil
+}
@@ -1,7 +1,9 @@
 package executor
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
 	"time"
 
 	"code.cloudfoundry.org/bbs/models"
@@ -18,6 +20,10 @@ const (
 	StateCreated      State = "created"
 	StateRunning      State = "running"
 	StateCompleted    State = "completed"
+
+	// StateDeleting is reported for a container whose deletion was accepted
+	// but whose garden container has not been destroyed yet.
+	StateDeleting State = "deleting"
 )
 
 const (
@@ -43,6 +49,21 @@ type Container struct {
 	MemoryLimit                           uint64             `json:"memory_limit"`
 	DiskLimit                             uint64             `json:"disk_limit"`
 	AdvertisePreferenceForInstanceAddress bool               `json:"advertise_preference_for_instance_address"`
+	CompletionCallbackDelivered           bool               `json:"completion_callback_delivered,omitempty"`
+
+	// RestartCount is the number of times a container with this guid has been
+	// re-created shortly after crashing, and LastCrashAt is when it last
+	// crashed, in unix nanoseconds. SuggestedBackoffMs is how long downstream
+	// components are advised to wait before restarting it again.
+	RestartCount       int   `json:"restart_count,omitempty"`
+	LastCrashAt        int64 `json:"last_crash_at,omitempty"`
+	SuggestedBackoffMs int64 `json:"suggested_backoff_ms,omitempty"`
+
+	// HealthCheckFailureCount is the number of consecutive failed checks of
+	// the monitor action, and LastHealthCheckAt is when it last ran a check,
+	// in unix nanoseconds.
+	HealthCheckFailureCount int   `json:"health_check_failure_count,omitempty"`
+	LastHealthCheckAt       int64 `json:"last_health_check_at,omitempty"`
 }
 
 func NewContainerFromResource(guid string, resource *Resource, tags Tags) Container {
@@ -53,20 +74,24 @@ func NewContainerFromResource(guid string, resource *Resource, tags Tags) Contai
 	}
 }
 
+// ContainerTransitions lists the states a container may move to from each
+// state. Completion is allowed from every state, so that a container can be
+// stopped or reaped at any point of its lifecycle.
+var ContainerTransitions = map[State][]State{
+	StateReserved:     {StateInitializing, StateCompleted},
+	StateInitializing: {StateCreated, StateCompleted},
+	StateCreated:      {StateRunning, StateCompleted},
+	StateRunning:      {StateCompleted},
+	StateCompleted:    {StateCompleted},
+}
+
 func (c *Container) ValidateTransitionTo(newState State) bool {
-	if newState == StateCompleted {
-		return true
-	}
-	switch c.State {
-	case StateReserved:
-		return newState == StateInitializing
-	case StateInitializing:
-		return newState == StateCreated
-	case StateCreated:
-		return newState == StateRunning
-	default:
-		return false
+	for _, allowed := range ContainerTransitions[c.State] {
+		if allowed == newState {
+			return true
+		}
 	}
+	return false
 }
 
 func (c *Container) TransistionToInitialize(req *RunRequest) error {
@@ -104,6 +129,23 @@ func (c *Container) IsCreated() bool {
 	return c.State != StateReserved && c.State != StateInitializing && c.State != StateCompleted
 }
 
+// instanceHostnameLength is the number of GUID characters used as the
+// container's hostname when no override is given.
+const instanceHostnameLength = 8
+
+// InstanceHostname returns the hostname exposed to the container's processes
+// as CF_INSTANCE_HOSTNAME: the hostname override when one is set, otherwise
+// the first characters of the container's GUID.
+func (c *Container) InstanceHostname() string {
+	if c.HostnameOverride != "" {
+		return c.HostnameOverride
+	}
+	if len(c.Guid) > instanceHostnameLength {
+		return c.Guid[:instanceHostnameLength]
+	}
+	return c.Guid
+}
+
 func (c *Container) HasTags(tags Tags) bool {
 	if c.Tags == nil {
 		return tags == nil
@@ -130,10 +172,25 @@ func NewReservedContainerFromAllocationRequest(req *AllocationRequest, allocated
 	return c
 }
 
+// DiskUnlimited may be requested as a Resource's DiskMB to run a container
+// without a disk limit. Such containers are not counted against the
+// executor's disk capacity. Garden treats a disk limit of 0 as no limit too,
+// so a DiskMB of 0 also runs the container without one.
+const DiskUnlimited = -1
+
 type Resource struct {
 	MemoryMB int `json:"memory_mb"`
 	DiskMB   int `json:"disk_mb"`
 	MaxPids  int `json:"max_pids"`
+
+	// InodeLimit overrides the cell-wide inode limit when non-zero. Once the
+	// container is created it holds the limit garden enforces.
+	InodeLimit uint64 `json:"inode_limit,omitempty"`
+
+	// MemoryReservationMB, when non-zero, is the memory counted against the
+	// executor's capacity instead of MemoryMB, which stays the hard limit. It
+	// lets containers burst above their reservation.
+	MemoryReservationMB int `json:"memory_reservation_mb,omitempty"`
 }
 
 func NewResource(memoryMB, diskMB, maxPids int) Resource {
@@ -180,6 +237,7 @@ type RunInfo struct {
 	EgressRules                   []*models.SecurityGroupRule `json:"egress_rules,omitempty"`
 	Env                           []EnvironmentVariable       `json:"env,omitempty"`
 	TrustedSystemCertificatesPath string                      `json:"trusted_system_certificates_path,omitempty"`
+	ExcludeTrustedSystemCerts     bool                        `json:"exclude_trusted_system_certs,omitempty"`
 	VolumeMounts                  []VolumeMount               `json:"volume_mounts"`
 	Network                       *Network                    `json:"network,omitempty"`
 	CertificateProperties         CertificateProperties       `json:"certificate_properties"`
@@ -187,6 +245,18 @@ type RunInfo struct {
 	ImagePassword                 string                      `json:"image_password"`
 	EnableContainerProxy          bool                        `json:"enable_container_proxy"`
 	Sidecars                      []Sidecar                   `json:"sidecars"`
+	CompletionCallbackURL         string                      `json:"completion_callback_url,omitempty"`
+	CachePartitionTag             string                      `json:"cache_partition_tag,omitempty"`
+	DisableProcessWrapper         bool                        `json:"disable_process_wrapper,omitempty"`
+	SkipDownloadsIfPresent        bool                        `json:"skip_downloads_if_present,omitempty"`
+	OnUnhealthyAction             *models.RunAction           `json:"on_unhealthy_action,omitempty"`
+	ExtraProperties               map[string]string           `json:"extra_properties,omitempty"`
+	HostnameOverride              string                      `json:"hostname_override,omitempty"`
+
+	// CPUPinCores are the host cores the container is pinned to, in addition
+	// to its CPU shares. It is only honoured when garden runs the
+	// garden-cpuset runtime plugin, which reads the executor:cpuset property.
+	CPUPinCores []int `json:"cpu_pin_cores,omitempty"`
 }
 
 type BindMountMode uint8
@@ -220,6 +290,8 @@ type ContainerMetrics struct {
 	DiskUsageInBytes                    uint64        `json:"disk_usage_in_bytes"`
 	MemoryLimitInBytes                  uint64        `json:"memory_limit_in_bytes"`
 	DiskLimitInBytes                    uint64        `json:"disk_limit_in_bytes"`
+	InodeUsage                          uint64        `json:"inode_usage"`
+	InodeLimit                          uint64        `json:"inode_limit"`
 	TimeSpentInCPU                      time.Duration `json:"time_spent_in_cpu"`
 	AbsoluteCPUEntitlementInNanoseconds uint64        `json:"absolute_cpu_entitlement_in_ns"`
 	ContainerAgeInNanoseconds           uint64        `json:"container_age_in_ns"`
@@ -250,9 +322,64 @@ type PortMapping struct {
 	HostTLSProxyPort      uint16 `json:"host_tls_proxy_port,omitempty"`
 }
 
+// ExitReasonCode classifies why a container's run process exited.
+type ExitReasonCode int
+
+const (
+	ExitReasonUnknown ExitReasonCode = iota
+	ExitReasonOOM
+	ExitReasonTimeout
+	ExitReasonCrash
+	ExitReasonStopRequested
+)
+
+func (c ExitReasonCode) String() string {
+	switch c {
+	case ExitReasonOOM:
+		return "oom"
+	case ExitReasonTimeout:
+		return "timeout"
+	case ExitReasonCrash:
+		return "crash"
+	case ExitReasonStopRequested:
+		return "stop-requested"
+	default:
+		return "unknown"
+	}
+}
+
+// MarshalJSON encodes the exit reason as its name.
+func (c ExitReasonCode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+// UnmarshalJSON decodes an exit reason from its name. The numeric encoding
+// written by earlier versions is also accepted.
+func (c *ExitReasonCode) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		var code int
+		if json.Unmarshal(data, &code) != nil {
+			return fmt.Errorf("invalid exit reason: %s", data)
+		}
+		*c = ExitReasonCode(code)
+		return nil
+	}
+
+	for _, reason := range []ExitReasonCode{ExitReasonUnknown, ExitReasonOOM, ExitReasonTimeout, ExitReasonCrash, ExitReasonStopRequested} {
+		if reason.String() == name {
+			*c = reason
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid exit reason: %q", name)
+}
+
 type ContainerRunResult struct {
-	Failed        bool   `json:"failed"`
-	FailureReason string `json:"failure_reason"`
+	Failed        bool           `json:"failed"`
+	FailureReason string         `json:"failure_reason"`
+	FailureType   string         `json:"failure_type,omitempty"`
+	ExitReason    ExitReasonCode `json:"exit_reason"`
 	Retryable     bool
 
 	Stopped bool `json:"stopped"`
@@ -276,23 +403,39 @@ func (e ExecutorResources) Copy() ExecutorResources {
 	return e
 }
 
+// accountedMemoryMB is the amount of memory capacity consumed by res.
+func accountedMemoryMB(res *Resource) int {
+	if res.MemoryReservationMB > 0 {
+		return res.MemoryReservationMB
+	}
+	return res.MemoryMB
+}
+
+// accountedDiskMB is the amount of disk capacity consumed by res.
+func accountedDiskMB(res *Resource) int {
+	if res.DiskMB == DiskUnlimited {
+		return 0
+	}
+	return res.DiskMB
+}
+
 func (r *ExecutorResources) canSubtract(res *Resource) bool {
-	return r.MemoryMB >= res.MemoryMB && r.DiskMB >= res.DiskMB && r.Containers > 0
+	return r.MemoryMB >= accountedMemoryMB(res) && r.DiskMB >= accountedDiskMB(res) && r.Containers > 0
 }
 
 func (r *ExecutorResources) Subtract(res *Resource) bool {
 	if !r.canSubtract(res) {
 		return false
 	}
-	r.MemoryMB -= res.MemoryMB
-	r.DiskMB -= res.DiskMB
+	r.MemoryMB -= accountedMemoryMB(res)
+	r.DiskMB -= accountedDiskMB(res)
 	r.Containers -= 1
 	return true
 }
 
 func (r *ExecutorResources) Add(res *Resource) {
-	r.MemoryMB += res.MemoryMB
-	r.DiskMB += res.DiskMB
+	r.MemoryMB += accountedMemoryMB(res)
+	r.DiskMB += accountedDiskMB(res)
 	r.Containers += 1
 }
 
@@ -327,6 +470,8 @@ const (
 	EventTypeContainerComplete EventType = "container_complete"
 	EventTypeContainerRunning  EventType = "container_running"
 	EventTypeContainerReserved EventType = "container_reserved"
+
+	EventTypeCellStartupReport EventType = "cell_startup_report"
 )
 
 type LifecycleEvent interface {
@@ -336,6 +481,10 @@ type LifecycleEvent interface {
 
 type ContainerCompleteEvent struct {
 	RawContainer Container `json:"container"`
+
+	// FinalMetrics is the last metrics sample taken from the container as it
+	// completed, if one could be taken.
+	FinalMetrics *ContainerMetrics `json:"final_metrics,omitempty"`
 }
 
 func NewContainerCompleteEvent(container Container) ContainerCompleteEvent {
@@ -375,3 +524,25 @@ func NewContainerReservedEvent(container Container) ContainerReservedEvent {
 func (ContainerReservedEvent) EventType() EventType   { return EventTypeContainerReserved }
 func (e ContainerReservedEvent) Container() Container { return e.RawContainer }
 func (ContainerReservedEvent) lifecycleEvent()        {}
+
+// CellStartupReport summarizes the reconciliation of leftover garden
+// containers performed when the executor starts.
+type CellStartupReport struct {
+	ContainersFound           int           `json:"containers_found"`
+	ContainersRecovered       int           `json:"containers_recovered"`
+	ContainersDestroyed       int           `json:"containers_destroyed"`
+	ContainersFailedToDestroy int           `json:"containers_failed_to_destroy"`
+	Duration                  time.Duration `json:"duration_ns"`
+}
+
+type CellStartupReportEvent struct {
+	Report CellStartupReport `json:"report"`
+}
+
+func NewCellStartupReportEvent(report CellStartupReport) CellStartupReportEvent {
+	return CellStartupReportEvent{
+		Report: report,
+	}
+}
+
+func (CellStartupReportEvent) EventType() EventType { return EventTypeCellStartupReport }
@@ -1,6 +1,8 @@
 package executor_test
 
 import (
+	"encoding/json"
+
 	"code.cloudfoundry.org/executor"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -47,6 +49,26 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("InstanceHostname", func() {
+		It("uses the start of the container's guid", func() {
+			container := executor.Container{Guid: "0a1b2c3d-4e5f-6a7b"}
+			Expect(container.InstanceHostname()).To(Equal("0a1b2c3d"))
+		})
+
+		It("uses the whole guid when it is short", func() {
+			container := executor.Container{Guid: "abc"}
+			Expect(container.InstanceHostname()).To(Equal("abc"))
+		})
+
+		Context("when a hostname override is set", func() {
+			It("uses the override", func() {
+				container := executor.Container{Guid: "0a1b2c3d-4e5f-6a7b"}
+				container.HostnameOverride = "my-app-0"
+				Expect(container.InstanceHostname()).To(Equal("my-app-0"))
+			})
+		})
+	})
+
 	Describe("Subtract", func() {
 		const (
 			defaultDiskMB     = 20
@@ -71,5 +93,86 @@ var _ = Describe("Container", func() {
 			resourceToSubtract := executor.NewResource(20, defaultDiskMB-1, -1)
 			Expect(resources.Subtract(&resourceToSubtract)).To(BeFalse())
 		})
+
+		It("consumes no disk when the resource requests 0 disk", func() {
+			resources := executor.NewExecutorResources(defaultMemoryMB, 0, defaultContainers)
+			resourceToSubtract := executor.NewResource(10, 0, -1)
+			Expect(resources.Subtract(&resourceToSubtract)).To(BeTrue())
+			Expect(resources).To(Equal(executor.NewExecutorResources(defaultMemoryMB-10, 0, defaultContainers-1)))
+		})
+
+		It("does not account for disk when the resource requests unlimited disk", func() {
+			resources := executor.NewExecutorResources(defaultMemoryMB, defaultDiskMB, defaultContainers)
+			resourceToSubtract := executor.NewResource(10, executor.DiskUnlimited, -1)
+			Expect(resources.Subtract(&resourceToSubtract)).To(BeTrue())
+			Expect(resources).To(Equal(executor.NewExecutorResources(defaultMemoryMB-10, defaultDiskMB, defaultContainers-1)))
+
+			resources.Add(&resourceToSubtract)
+			Expect(resources).To(Equal(executor.NewExecutorResources(defaultMemoryMB, defaultDiskMB, defaultContainers)))
+		})
+
+		It("accounts for the memory reservation instead of the hard limit", func() {
+			resources := executor.NewExecutorResources(100, defaultDiskMB, defaultContainers)
+			resourceToSubtract := executor.NewResource(150, 10, -1)
+			resourceToSubtract.MemoryReservationMB = 60
+			Expect(resources.Subtract(&resourceToSubtract)).To(BeTrue())
+			Expect(resources).To(Equal(executor.NewExecutorResources(40, defaultDiskMB-10, defaultContainers-1)))
+
+			Expect(resources.Subtract(&resourceToSubtract)).To(BeFalse())
+
+			resources.Add(&resourceToSubtract)
+			Expect(resources).To(Equal(executor.NewExecutorResources(100, defaultDiskMB, defaultContainers)))
+		})
+	})
+
+	Describe("ExcludeTrustedSystemCerts", func() {
+		It("survives a JSON round trip", func() {
+			container := executor.Container{
+				Guid:    "some-guid",
+				RunInfo: executor.RunInfo{ExcludeTrustedSystemCerts: true},
+			}
+
+			payload, err := json.Marshal(container)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(payload)).To(ContainSubstring(`"exclude_trusted_system_certs":true`))
+
+			var decoded executor.Container
+			Expect(json.Unmarshal(payload, &decoded)).To(Succeed())
+			Expect(decoded.ExcludeTrustedSystemCerts).To(BeTrue())
+		})
+
+		It("is omitted by default", func() {
+			payload, err := json.Marshal(executor.Container{Guid: "some-guid"})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(payload)).NotTo(ContainSubstring("exclude_trusted_system_certs"))
+		})
+	})
+
+	Describe("ExitReason", func() {
+		It("is encoded as its name", func() {
+			container := executor.Container{
+				Guid:      "some-guid",
+				RunResult: executor.ContainerRunResult{ExitReason: executor.ExitReasonOOM},
+			}
+
+			payload, err := json.Marshal(container)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(string(payload)).To(ContainSubstring(`"exit_reason":"oom"`))
+
+			var decoded executor.Container
+			Expect(json.Unmarshal(payload, &decoded)).To(Succeed())
+			Expect(decoded.RunResult.ExitReason).To(Equal(executor.ExitReasonOOM))
+		})
+
+		It("decodes the numeric encoding", func() {
+			var result executor.ContainerRunResult
+			Expect(json.Unmarshal([]byte(`{"exit_reason":2}`), &result)).To(Succeed())
+			Expect(result.ExitReason).To(Equal(executor.ExitReasonTimeout))
+		})
+
+		It("rejects unknown names", func() {
+			var result executor.ContainerRunResult
+			Expect(json.Unmarshal([]byte(`{"exit_reason":"bogus"}`), &result)).NotTo(Succeed())
+		})
 	})
 })